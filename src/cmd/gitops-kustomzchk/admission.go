@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/admission"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/source"
+	"github.com/spf13/cobra"
+)
+
+// ADMISSION_SHUTDOWN_TIMEOUT bounds the wait for the admission requests in progress on SIGINT/SIGTERM, the API
+// server gives up on a webhook call after 30 seconds at most
+const ADMISSION_SHUTDOWN_TIMEOUT = 30 * time.Second
+
+// ADMISSION_DATA_REFRESH_INTERVAL is how often the external data of the loaded policies is fetched again, the
+// sources cached for less than their ttl are not requested
+const ADMISSION_DATA_REFRESH_INTERVAL = 5 * time.Minute
+
+// admissionFlags are the flags of the admission command on top of the root ones
+type admissionFlags struct {
+	listen             string
+	tlsCertFile        string
+	tlsKeyFile         string
+	environment        string
+	cluster            string
+	sourcePollInterval time.Duration
+	dataRefresh        time.Duration
+}
+
+// newAdmissionCmd creates the admission command, it shares the flags of the root command
+func newAdmissionCmd(root *cobra.Command, opts *runner.Options) *cobra.Command {
+	var flags admissionFlags
+	cmd := &cobra.Command{
+		Use:   "admission",
+		Short: "Gate the cluster applies with the policies of the pull requests, as a Kubernetes ValidatingWebhook",
+		Long: fmt.Sprintf(`admission serves the ValidatingWebhook endpoint (POST %s, HTTPS) of a Kubernetes cluster: the object of
+each admission request is evaluated with the policies of --policies-path, the ones gating the pull requests, so
+that a direct apply bypassing the pull requests is gated the same way.
+
+A request failing a blocking policy is denied with its messages, the failing warning and recommend policies are
+returned as warnings. The policy context is --environment and --cluster, with data.context.admission (operation,
+namespace, name, user, dryRun); there are no override comments, and the manual and cross-environment policies are
+not evaluated. A request failing to evaluate is answered 500: the failurePolicy of the webhook decides.
+
+The policies are a source like in the server mode (directory, git or OCI), reloaded every --source-poll-interval
+once validated. Each version is loaded and its policies compiled once, for all the requests, and its external
+data fetched again every --external-data-refresh-interval; the TLS key pair is reloaded when its files change,
+e.g. renewed by cert-manager.`, admission.ADMISSION_PATH),
+		Example: `  gitops-kustomzchk admission --listen :8443 --tls-cert-file /tls/tls.crt --tls-key-file /tls/tls.key \
+    --policies-path git+https://github.com/acme/policies?ref=main --environment prod --cluster alpha`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serveAdmission(cmd.Context(), opts, flags)
+		},
+	}
+	cmd.Flags().StringVar(&flags.listen, "listen", ":8443", "Address to serve the admission endpoint on")
+	cmd.Flags().StringVar(&flags.tlsCertFile, "tls-cert-file", "", "PEM certificate of the endpoint, the API server only calls webhooks over HTTPS")
+	cmd.Flags().StringVar(&flags.tlsKeyFile, "tls-key-file", "", "PEM private key of --tls-cert-file")
+	cmd.Flags().StringVar(&flags.environment, "environment", "", "Environment of the cluster in the policy context (data.context.environment), e.g. prod")
+	cmd.Flags().StringVar(&flags.cluster, "cluster", "", "Name of the cluster in the policy context (data.context.cluster)")
+	cmd.Flags().DurationVar(&flags.sourcePollInterval, "source-poll-interval", source.POLL_INTERVAL,
+		"How often the policies source is checked for a new version")
+	cmd.Flags().DurationVar(&flags.dataRefresh, "external-data-refresh-interval", ADMISSION_DATA_REFRESH_INTERVAL,
+		"How often the external data of the policies is fetched again, 0 keeps the data fetched on load")
+	cmd.Flags().AddFlagSet(root.Flags())
+	return cmd
+}
+
+func serveAdmission(ctx context.Context, opts *runner.Options, flags admissionFlags) error {
+	if flags.tlsCertFile == "" || flags.tlsKeyFile == "" {
+		return fmt.Errorf("admission requires --tls-cert-file and --tls-key-file, the API server only calls webhooks over HTTPS")
+	}
+	if opts.EvaluateAt != "" {
+		return fmt.Errorf("--evaluate-at is only for local mode")
+	}
+	certificates := &keyPairReloader{certFile: flags.tlsCertFile, keyFile: flags.tlsKeyFile}
+	if _, err := certificates.GetCertificate(nil); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	evaluators := &admissionEvaluators{byDir: make(map[string]*policy.PolicyEvaluator)}
+	policies, err := source.NewWatcher(ctx, opts.PoliciesPath, func(dir string) error {
+		return evaluators.load(ctx, opts, dir)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load the policies: %w", err)
+	}
+	policies.Removed = evaluators.remove
+	defer policies.Close()
+	go policies.Watch(ctx, flags.sourcePollInterval)
+	if flags.dataRefresh > 0 {
+		go evaluators.refresh(ctx, flags.dataRefresh)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(admission.ADMISSION_PATH, &admission.Handler{
+		Evaluator: func(ctx context.Context) (admission.Evaluator, func(), error) {
+			snapshot, release := policies.Acquire()
+			evaluator := evaluators.of(snapshot.Dir)
+			if evaluator == nil {
+				release()
+				return nil, nil, fmt.Errorf("policies version %s is not loaded", snapshot.Version)
+			}
+			return evaluator, release, nil
+		},
+		Environment: flags.environment,
+		Cluster:     flags.cluster,
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	httpServer := &http.Server{
+		Addr:              flags.listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certificates.GetCertificate},
+	}
+	errs := make(chan error, 1)
+	go func() {
+		logger.WithField("listen", flags.listen).WithField("environment", flags.environment).WithField("cluster", flags.cluster).Info("Serving admission requests")
+		errs <- httpServer.ListenAndServeTLS("", "")
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down, waiting for the admission requests in progress")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ADMISSION_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newAdmissionEvaluator loads the policies of a directory with the evaluation options of the root flags, the
+// config loader and evaluator of the runs
+func newAdmissionEvaluator(ctx context.Context, opts *runner.Options, dir string) (*policy.PolicyEvaluator, error) {
+	evaluator, err := newEvaluator(opts, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := evaluator.LoadAndValidate(); err != nil {
+		return nil, fmt.Errorf("invalid policies: %w", err)
+	}
+	if err := evaluator.FetchExternalData(ctx); err != nil {
+		return nil, fmt.Errorf("failed to fetch the external data: %w", err)
+	}
+	return evaluator, nil
+}
+
+// admissionEvaluators are the evaluators of the versions of the policies, by snapshot dir: loaded when a version is
+// validated, shared by its requests and closed once the snapshot is removed
+type admissionEvaluators struct {
+	mu    sync.Mutex
+	byDir map[string]*policy.PolicyEvaluator
+}
+
+// load is the validation of a new version of the policies, its evaluator is kept for its requests
+func (a *admissionEvaluators) load(ctx context.Context, opts *runner.Options, dir string) error {
+	evaluator, err := newAdmissionEvaluator(ctx, opts, dir)
+	if err != nil {
+		return err
+	}
+	if err := evaluator.VerifyLibraries(ctx); err != nil {
+		_ = evaluator.Close()
+		return err
+	}
+	a.mu.Lock()
+	a.byDir[dir] = evaluator
+	a.mu.Unlock()
+	return nil
+}
+
+// of returns the evaluator of a snapshot, nil if not loaded
+func (a *admissionEvaluators) of(dir string) *policy.PolicyEvaluator {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.byDir[dir]
+}
+
+// remove closes the evaluator of a removed snapshot, no request uses it anymore
+func (a *admissionEvaluators) remove(dir string) {
+	a.mu.Lock()
+	evaluator, ok := a.byDir[dir]
+	delete(a.byDir, dir)
+	a.mu.Unlock()
+	if ok {
+		if err := evaluator.Close(); err != nil {
+			logger.WithField("error", err).Warn("Failed to close the evaluator of a removed policies version")
+		}
+	}
+}
+
+// refresh fetches the external data of the loaded evaluators every interval until ctx is done, a failed fetch is
+// logged and the previous data stays in use
+func (a *admissionEvaluators) refresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		a.mu.Lock()
+		loaded := make(map[string]*policy.PolicyEvaluator, len(a.byDir))
+		maps.Copy(loaded, a.byDir)
+		a.mu.Unlock()
+		for dir, evaluator := range loaded {
+			if err := evaluator.FetchExternalData(ctx); err != nil {
+				logger.WithField("error", err).Error("Failed to refresh the external data, keeping the previous data")
+				continue
+			}
+			// removed during the fetch, the new data goes with it
+			if a.of(dir) == nil {
+				_ = evaluator.Close()
+			}
+		}
+	}
+}
+
+// keyPairReloader loads the TLS key pair again when its certificate file changes
+type keyPairReloader struct {
+	certFile, keyFile string
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	modTime     time.Time
+}
+
+func (k *keyPairReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	info, err := os.Stat(k.certFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat the TLS certificate: %w", err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.certificate != nil && info.ModTime().Equal(k.modTime) {
+		return k.certificate, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(k.certFile, k.keyFile)
+	if err != nil {
+		if k.certificate != nil {
+			// e.g. the certificate is renewed before the key, the next handshake tries again
+			logger.WithField("error", err).Warn("Failed to reload the TLS key pair, keeping the current one")
+			return k.certificate, nil
+		}
+		return nil, fmt.Errorf("failed to load the TLS key pair: %w", err)
+	}
+	k.certificate, k.modTime = &certificate, info.ModTime()
+	return k.certificate, nil
+}
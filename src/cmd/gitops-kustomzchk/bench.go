@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// newBenchCmd creates the bench command, it shares the flags of the root command
+func newBenchCmd(root *cobra.Command, opts *runner.Options) *cobra.Command {
+	var corpus string
+	var iterations int
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Measure build, diff and policy evaluation over a corpus of services",
+		Long: `bench runs the build, diff and policy evaluation stages repeatedly over every
+<service>/environments/<env> overlay of the corpus directory, then prints timing and memory statistics
+per stage. Run it with different engine options (e.g. --diff-engine, --policy-engine) to compare them.`,
+		Example: `  gitops-kustomzchk bench --corpus ./sample/k8s-manifests/services --iterations 5 --diff-engine native`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if iterations < 1 {
+				return fmt.Errorf("iterations must be >= 1, got: %d", iterations)
+			}
+			return runBench(cmd.Context(), opts, corpus, iterations)
+		},
+	}
+	cmd.Flags().StringVar(&corpus, "corpus", "", "Directory of sample services (<service>/environments/<env>)")
+	cmd.Flags().IntVar(&iterations, "iterations", 3, "Number of times each overlay is measured")
+	_ = cmd.MarkFlagRequired("corpus")
+	cmd.Flags().AddFlagSet(root.Flags())
+	return cmd
+}
+
+// validateBenchOptions validates the engine options, bench does not take the path flags of a run
+func validateBenchOptions(opts *runner.Options) error {
+	if err := kustomize.BuildArgs(opts.BuildArgs).Validate(); err != nil {
+		return fmt.Errorf("invalid --build-args: %w", err)
+	}
+	if err := opts.KustomizePlugins.Validate(); err != nil {
+		return fmt.Errorf("invalid kustomize plugin options: %w", err)
+	}
+	if err := opts.KustomizeHelm.Validate(); err != nil {
+		return fmt.Errorf("invalid kustomize helm options: %w", err)
+	}
+	if !slices.Contains(kustomize.KUSTOMIZE_ENGINES, opts.KustomizeEngine) {
+		return fmt.Errorf("kustomize-engine must be one of %v, got: %s", kustomize.KUSTOMIZE_ENGINES, opts.KustomizeEngine)
+	}
+	if !slices.Contains(kustomize.LOAD_RESTRICTORS, opts.KustomizeLoadRestrictor) {
+		return fmt.Errorf("kustomize-load-restrictor must be one of %v, got: %s", kustomize.LOAD_RESTRICTORS, opts.KustomizeLoadRestrictor)
+	}
+	if !slices.Contains(diff.DIFF_ENGINES, opts.DiffEngine) {
+		return fmt.Errorf("diff-engine must be one of %v, got: %s", diff.DIFF_ENGINES, opts.DiffEngine)
+	}
+	if !slices.Contains(diff.DIFF_STYLES, opts.DiffStyle) {
+		return fmt.Errorf("diff-style must be one of %v, got: %s", diff.DIFF_STYLES, opts.DiffStyle)
+	}
+	if !slices.Contains(policy.POLICY_ENGINES, opts.PolicyEngine) {
+		return fmt.Errorf("policy-engine must be one of %v, got: %s", policy.POLICY_ENGINES, opts.PolicyEngine)
+	}
+	if requirements := opts.ExecRequirements(); opts.NoExec && len(requirements) > 0 {
+		return fmt.Errorf("--no-exec: the following features require external binaries:\n  - %s",
+			strings.Join(requirements, "\n  - "))
+	}
+	if conflicts := opts.OfflineConflicts(); opts.Offline && len(conflicts) > 0 {
+		return fmt.Errorf("--offline: the following features require network access:\n  - %s",
+			strings.Join(conflicts, "\n  - "))
+	}
+	return nil
+}
+
+func runBench(ctx context.Context, opts *runner.Options, corpus string, iterations int) error {
+	if opts.Debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	opts.RunMode = RUN_MODE_LOCAL // bench never posts to the SCM
+
+	stopProfiling, err := trace.StartProfiling(opts.OutputDir, opts.ProfileCPU, opts.ProfileMem)
+	if err != nil {
+		return fmt.Errorf("failed to start profiling: %w", err)
+	}
+	defer stopProfiling()
+
+	if err := validateBenchOptions(opts); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	appRunner, err := initialize(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+	defer closeRunner(appRunner)
+	localRunner, ok := appRunner.(*runner.RunnerLocal)
+	if !ok {
+		return fmt.Errorf("bench requires the local runner")
+	}
+	if err := localRunner.Bench(corpus, iterations); err != nil {
+		return fmt.Errorf("failed to bench: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	"github.com/spf13/cobra"
+)
+
+// newDoctorCmd creates the doctor command, it shares the flags of the root command
+func newDoctorCmd(root *cobra.Command, opts *runner.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Show the network access and external binaries a run with the given flags needs",
+		Long: `doctor prints what a run with the same flags is allowed to reach and spawn: the egress allowed by
+--offline and the features it disables, the proxy and CA bundle, and the external binaries of the enabled features.
+It fails if --offline or --no-exec conflict with an enabled feature.`,
+		Example: `  gitops-kustomzchk doctor --offline --run-mode github --decision-log ./decisions.jsonl`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return doctor(cmd.OutOrStdout(), opts)
+		},
+	}
+	cmd.Flags().AddFlagSet(root.Flags())
+	return cmd
+}
+
+func doctor(w io.Writer, opts *runner.Options) error {
+	cfg := opts.NetworkConfig()
+	var problems []string
+
+	fmt.Fprintln(w, "Network:")
+	switch {
+	case cfg.Offline && len(cfg.AllowedHosts) > 0:
+		fmt.Fprintf(w, "  offline, egress allowed to: %s\n", strings.Join(cfg.AllowedHosts, ", "))
+	case cfg.Offline:
+		fmt.Fprintln(w, "  offline, no egress allowed")
+	default:
+		fmt.Fprintln(w, "  online (--offline to forbid egress)")
+	}
+	if cfg.HTTPSProxy != "" {
+		fmt.Fprintf(w, "  proxy: %s (no proxy: %s)\n", cfg.HTTPSProxy, cfg.NoProxy)
+	}
+	if cfg.CABundle != "" {
+		fmt.Fprintf(w, "  CA bundle: %s\n", cfg.CABundle)
+	}
+	if cfg.Offline {
+		fmt.Fprintln(w, "Disabled by --offline:")
+		for _, feature := range runner.OFFLINE_DISABLED_FEATURES {
+			fmt.Fprintf(w, "  - %s\n", feature)
+		}
+		problems = append(problems, opts.OfflineConflicts()...)
+	}
+
+	fmt.Fprintln(w, "External binaries:")
+	requirements := opts.ExecRequirements()
+	for _, requirement := range requirements {
+		fmt.Fprintf(w, "  - %s\n", requirement)
+	}
+	if opts.NoExec {
+		problems = append(problems, requirements...)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the following enabled features conflict with --offline or --no-exec:\n  - %s",
+			strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// newDriftCmd creates the drift command, it shares the flags of the root command
+func newDriftCmd(root *cobra.Command, opts *runner.Options) *cobra.Command {
+	var from, to string
+	var failOnDrift bool
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Report the configuration drift of a service between two environments",
+		Long: `drift builds the overlays of the after manifests like a local mode run, then compares two of them:
+the container images differing (and behind, for lower semver tags) and the resources found in one only.
+Run it on a promotion PR (e.g. stg to prod), or on a schedule with --fail-on-drift to audit the environments.
+--lc-before-manifests-path defaults to the after path, only one tree is compared.`,
+		Example: `  gitops-kustomzchk drift --from stg --to prod \
+    --service my-app --lc-after-manifests-path ./services --enable-export-report`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return drift(cmd.Context(), opts, from, to, failOnDrift)
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "Environment (or overlay key) to compare from, e.g. stg")
+	cmd.Flags().StringVar(&to, "to", "", "Environment (or overlay key) to compare to, e.g. prod")
+	cmd.Flags().BoolVar(&failOnDrift, "fail-on-drift", false, "Exit non-zero when the environments differ")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+	cmd.Flags().AddFlagSet(root.Flags())
+	return cmd
+}
+
+func drift(ctx context.Context, opts *runner.Options, from, to string, failOnDrift bool) error {
+	if opts.Debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	opts.RunMode = RUN_MODE_LOCAL // drift reports never post to the SCM
+	// a single tree is compared
+	if opts.LcBeforeManifestsPath == "" {
+		opts.LcBeforeManifestsPath = opts.LcAfterManifestsPath
+	}
+	if opts.LcBeforeKustomizeBuildPath == "" {
+		opts.LcBeforeKustomizeBuildPath = opts.LcAfterKustomizeBuildPath
+	}
+	if len(opts.Services) > 1 {
+		return fmt.Errorf("drift compares the environments of a single service, got --service %v", opts.Services)
+	}
+	if opts.Service != "" && len(opts.Environments) == 0 {
+		opts.Environments = []string{from, to}
+	}
+
+	if err := validateOptions(opts); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	appRunner, err := initialize(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+	defer closeRunner(appRunner)
+	localRunner, ok := appRunner.(*runner.RunnerLocal)
+	if !ok {
+		return fmt.Errorf("drift requires the local runner")
+	}
+	if err := localRunner.Drift(from, to, failOnDrift); err != nil && !errors.Is(err, runner.ErrDrift) {
+		return fmt.Errorf("failed to report drift: %w", err)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
@@ -3,8 +3,19 @@ package main
 import (
 	"fmt"
 	"os"
+	_ "time/tzdata" // the compliance config timezone is loaded without the system tz database
 
 	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/inventory"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/report"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
 	"github.com/spf13/cobra"
 )
 
@@ -17,8 +28,8 @@ var (
 
 func main() {
 	if err := newRootCmd().Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, failure.Format(err))
+		os.Exit(failure.ExitCode(err))
 	}
 }
 
@@ -32,38 +43,185 @@ func newRootCmd() *cobra.Command {
 		Long: `gitops-kustomzchk enforces policy compliance for k8s GitOps repositories via GitHub PR checks.
 It builds kustomize manifests, diffs them, evaluates OPA policies, and posts detailed comments on PRs.`,
 		Version: fmt.Sprintf("%s (built: %s)", Version, BuildTime),
+		// The network and permissions configurations apply to every subcommand, before any outbound call or write
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := httpclient.Configure(opts.NetworkConfig()); err != nil {
+				return fmt.Errorf("invalid network options: %w", err)
+			}
+			if err := perm.Configure(opts.PermConfig()); err != nil {
+				return fmt.Errorf("invalid permissions options: %w", err)
+			}
+			// The first service of --service is the one of the single-service subcommands
+			if len(opts.Services) > 0 {
+				opts.Service = opts.Services[0]
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return run(cmd.Context(), opts)
 		},
 	}
 
 	// Run mode
-	cmd.Flags().StringVar(&opts.RunMode, "run-mode", "github", "Run mode: github or local")
+	cmd.Flags().StringVar(&opts.RunMode, "run-mode", "github", "Run mode: github, gitlab, bitbucket or local")
 
 	// === New dynamic path flags (v0.5+) - RECOMMENDED ===
 	cmd.Flags().StringVar(&opts.KustomizeBuildPath, "kustomize-build-path", "",
 		"Path template with [VARIABLES] (e.g., 'services/[SERVICE]/clusters/[CLUSTER]/[ENV]')")
 	cmd.Flags().StringVar(&opts.KustomizeBuildValues, "kustomize-build-values", "",
-		"Variable values: 'KEY=v1,v2;KEY2=v3' (e.g., 'SERVICE=my-app;CLUSTER=alpha;ENV=stg,prod')")
+		"Variable values: 'KEY=v1,v2;KEY2=v3' (e.g., 'SERVICE=my-app;CLUSTER=alpha;ENV=stg,prod'), globs like 'prod-*' or 're:<regex>' expand to the matching directories")
 
 	// === Legacy flags (v0.4 backward compatibility) ===
-	cmd.Flags().StringVar(&opts.Service, "service", "", "Service name [DEPRECATED: use --kustomize-build-path]")
+	cmd.Flags().StringSliceVar(&opts.Services, "service", []string{},
+		"Service name, comma-separated or repeated to check several services with one checkout, one comment each [DEPRECATED: use --kustomize-build-path]")
+	cmd.Flags().BoolVar(&opts.AutoDetectServices, "auto-detect-services", false,
+		"Check the services of --manifests-path the PR changes files of, listed with the GitHub API, instead of --service [github mode]")
 	cmd.Flags().StringSliceVar(&opts.Environments, "environments", []string{},
-		"Environments to check (comma-separated) [DEPRECATED: use --kustomize-build-values]")
+		"Environments to check (comma-separated, globs like 'prod-*' or 're:<regex>' expand to the matching overlays) [DEPRECATED: use --kustomize-build-values]")
 
 	// Common flags
 	cmd.Flags().StringVar(&opts.PoliciesPath, "policies-path", "./policies",
 		"Path to policies directory (contains compliance-config.yaml)")
+	cmd.Flags().BoolVar(&opts.NoPolicies, "no-policies", false,
+		"Diff-only local run: ignore --policies-path, evaluate no policy and fetch no external data")
 	cmd.Flags().StringVar(&opts.TemplatesPath, "templates-path", "./templates",
 		"Path to templates directory")
 	cmd.Flags().BoolVar(&opts.Debug, "debug", false, "Debug mode")
 
 	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", "./output",
 		"Output directory in case the tool need to export files. In local mode, the tool will export the report to this directory.")
+	cmd.Flags().StringVar(&opts.OutputFileMode, "output-file-mode", "0644",
+		"Octal mode of the files written to the output dir, e.g. 0600 on shared runners")
+	cmd.Flags().StringVar(&opts.OutputDirMode, "output-dir-mode", "0755",
+		"Octal mode of the output directories, e.g. 0700 on shared runners")
+	cmd.Flags().StringVar(&opts.Umask, "umask", "",
+		"Octal umask of the process and its children (checkouts, builds, temp dirs), e.g. 077 (unix only, default: inherited)")
+	cmd.Flags().BoolVar(&opts.OutputRequireOwner, "output-require-owner", false,
+		"Fail instead of writing to an output dir owned by another user (unix only)")
 	cmd.Flags().BoolVar(&opts.EnableExportReport, "enable-export-report", false, "Enable export report (json file to output dir)")
+	cmd.Flags().StringSliceVar(&opts.ReportFormats, "report-format", []string{report.FORMAT_JSON},
+		"Formats of the exported report, each written to report.<format> in the output dir: json, yaml")
+	cmd.Flags().BoolVar(&opts.ReportPretty, "report-pretty", false, "Indent the exported JSON report")
+	cmd.Flags().StringVar(&opts.MetricsTextfileDir, "metrics-textfile-dir", "",
+		"Write the run metrics to gitops_kustomzchk[_<service>].prom in this node_exporter textfile collector directory")
+	cmd.Flags().StringVar(&opts.DecisionLog, "decision-log", "",
+		"Emit an OPA decision log event per policy evaluation: appended as JSON lines to a file, or posted (gzipped JSON array) to an http(s) URL")
+	cmd.Flags().BoolVar(&opts.DecisionLogOmitInput, "decision-log-omit-input", false,
+		"Leave the evaluated manifests out of the decision log events")
+	cmd.Flags().BoolVar(&opts.EnableExportCSV, "enable-export-csv", false,
+		"Export the policy matrix as a flat CSV to policies.csv in the output dir (service, environment, policy id, level, status, messages count)")
+	cmd.Flags().BoolVar(&opts.EnableExportJUnit, "enable-export-junit", false,
+		"Export the policy matrix as a JUnit XML report to junit.xml in the output dir, a test suite per environment and a test case per policy")
+	cmd.Flags().BoolVar(&opts.EnableExportHTML, "enable-export-html", false,
+		"Export a standalone HTML report to report.html in the output dir: summary tables, policy matrix, failures and collapsible diffs")
+	cmd.Flags().StringSliceVar(&opts.ExportInventory, "export-inventory", []string{},
+		"Export the inventory of the resources of the after manifests to inventory.<format> in the output dir: json, csv")
+	cmd.Flags().StringSliceVar(&opts.InventoryLabels, "inventory-labels", inventory.DEFAULT_LABELS,
+		"Label keys exported in the inventory")
+	cmd.Flags().BoolVar(&opts.ExportManifests, "export-manifests", false,
+		"Export the built before/after manifests of each overlay to manifests/<overlay>/ in the output dir")
+	cmd.Flags().BoolVar(&opts.ExportManifestsGzip, "export-manifests-gzip", false,
+		"Gzip the exported manifests (<side>.yaml.gz)")
+	cmd.Flags().BoolVar(&opts.PublishTranscript, "publish-transcript", false,
+		"Record every SCM mutation of the run (comment bodies, labels, check runs, reviewer requests, pushes) with its outcome to transcript.json in the output dir, written even when the run fails [github, gitlab and bitbucket modes]")
+	cmd.Flags().BoolVar(&opts.NotifySlack, "notify-slack", false,
+		"Post the outcome of the run to the slackChannel of the service.yaml, with the $SLACK_BOT_TOKEN bot token [github, gitlab and bitbucket modes]")
+	cmd.Flags().StringSliceVar(&opts.NotifyWebhooks, "notify-webhook", []string{},
+		"POST the outcome of the run as JSON to these http(s) URLs [github, gitlab and bitbucket modes]")
+	cmd.Flags().StringVar(&opts.NotifyOn, "notify-on", runner.NOTIFY_ON_FAILURE,
+		"Outcomes notified by --notify-slack and --notify-webhook: failure (runs failing or not passing) or always")
+	cmd.Flags().StringVar(&opts.EncryptArtifacts, "encrypt-artifacts", "",
+		"Encrypt the exported reports, diffs and manifests with age or gpg, for the artifactEncryption recipients of the compliance config")
 	cmd.Flags().BoolVar(&opts.EnableExportPerformanceReport, "enable-export-performance-report", false, "Enable export performance report (json file to output dir)")
+	cmd.Flags().BoolVar(&opts.ProfileCPU, "profile-cpu", false, "Write a pprof CPU profile of the run to the output dir (cpu.pprof)")
+	cmd.Flags().BoolVar(&opts.ProfileMem, "profile-mem", false, "Write a pprof memory profile of the run to the output dir (mem.pprof)")
 	cmd.Flags().BoolVar(&opts.FailOnOverlayNotFound, "fail-on-overlay-not-found", false,
 		"Fail the build if an overlay/environment doesn't exist (default: false, will skip missing overlays)")
+	cmd.Flags().StringVar(&opts.ExternalDataCacheDir, "external-data-cache-dir", "",
+		"Cache directory for externalData sources defined in compliance-config.yaml (default: a directory under the system temp dir)")
+
+	cmd.Flags().BoolVar(&opts.RenderGitOpsResources, "render-gitops-resources", false,
+		"Render Flux HelmRelease (requires helm) and Argo ApplicationSet (list generators) found in built manifests, so diffs and policies apply to the final workloads")
+
+	cmd.Flags().BoolVar(&opts.ComponentProvenance, "component-provenance", false,
+		"Annotate built resources with the kustomize components that created or patched them ("+kustomize.COMPONENTS_ANNOTATION+"), so policies can tell them apart")
+	cmd.Flags().BoolVar(&opts.Provenance, "provenance", false,
+		"Build with kustomize origin annotations to map diffs and violations back to their source files (annotations are stripped before diff and evaluation)")
+	cmd.Flags().BoolVar(&opts.KustomizePlugins.Enabled, "kustomize-enable-plugins", false,
+		"Build with kustomize --enable-alpha-plugins, for generator/transformer plugins such as KSOPS (they run within the --exec-* limits with --kustomize-engine binary)")
+	cmd.Flags().BoolVar(&opts.KustomizePlugins.Exec, "kustomize-enable-exec", false,
+		"Allow exec KRM functions (kustomize --enable-exec), requires --kustomize-enable-plugins")
+	cmd.Flags().BoolVar(&opts.KustomizePlugins.Network, "kustomize-fn-network", false,
+		"Give the containerized KRM functions network access (kustomize --network)")
+	cmd.Flags().StringArrayVar(&opts.KustomizePlugins.Mounts, "kustomize-fn-mount", []string{},
+		"Storage mount of the containerized KRM functions, e.g. 'type=bind,src=/keys,dst=/keys,rw=false' (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.KustomizePlugins.Env, "kustomize-fn-env", []string{},
+		"Environment variable of the KRM functions, KEY=value or KEY to forward it, e.g. SOPS_AGE_KEY_FILE (repeatable)")
+	cmd.Flags().BoolVar(&opts.KustomizePlugins.AsCurrentUser, "kustomize-fn-as-current-user", false,
+		"Run the containerized KRM functions as the current user (kustomize --as-current-user)")
+	cmd.Flags().BoolVar(&opts.KustomizeHelm.Enabled, "kustomize-enable-helm", false,
+		"Inflate the helmCharts of the overlays with helm (kustomize --enable-helm), the charts missing from their chart home are pulled into it")
+	cmd.Flags().StringVar(&opts.KustomizeHelm.Command, "kustomize-helm-command", "",
+		"Helm binary of --kustomize-enable-helm (kustomize --helm-command), default "+kustomize.HELM_COMMAND)
+	cmd.Flags().StringVar(&opts.SOPS, "sops", "",
+		"Handling of SOPS-encrypted resources: 'metadata' diffs their keys and recipients only, 'decrypt' decrypts them with sops (keys from its environment) and diffs digests of the values")
+	cmd.Flags().BoolVar(&opts.NoManifestContentInComment, "no-manifest-content-in-comment", false,
+		"Confidential mode: PR comments only contain counts and policy names, full diffs and messages are written to the output dir")
+	cmd.Flags().BoolVar(&opts.RunLock, "run-lock", false,
+		"Lease the tool comment of each service to the newest run of the PR: an older run still in progress stops without publishing, so out-of-order finishes can't overwrite a newer report")
+
+	cmd.Flags().StringVar(&opts.KustomizeEngine, "kustomize-engine", kustomize.KUSTOMIZE_ENGINE_KRUSTY,
+		"Kustomize implementation: 'krusty' builds in-process with the kustomize API (no kustomize binary needed), 'binary' runs a kustomize process per build within the --exec-* limits")
+	cmd.Flags().StringVar(&opts.KustomizeLoadRestrictor, "kustomize-load-restrictor", kustomize.LOAD_RESTRICTOR_ROOT_ONLY,
+		"Kustomize load restrictor: 'LoadRestrictionsRootOnly' only loads the files under the kustomization roots, 'LoadRestrictionsNone' from anywhere (e.g. ../../shared/config.env)")
+	cmd.Flags().StringVar(&opts.DiffEngine, "diff-engine", diff.DefaultEngine(),
+		"Diff implementation: 'external' runs the system diff -u, 'native' uses the built-in Go implementation (no diff binary needed)")
+	cmd.Flags().StringVar(&opts.DiffStyle, "diff-style", diff.DIFF_STYLE_UNIFIED,
+		"Diff format: 'unified' diffs the manifests line by line, 'dyff' lists the changed paths of each resource (e.g. 'spec.replicas: 1 → 3'), easier to review on large manifests (no diff binary needed)")
+	cmd.Flags().StringVar(&opts.PolicyEngine, "policy-engine", policy.POLICY_ENGINE_CONFTEST,
+		"Rego policy engine: 'conftest' runs a conftest process per policy, 'embedded' compiles each policy once and evaluates it in-process with OPA (no conftest binary needed)")
+	cmd.Flags().BoolVar(&opts.NoExec, "no-exec", false,
+		"Fail at startup if any enabled feature needs to spawn an external binary (for minimal container images)")
+	cmd.Flags().StringVar(&opts.EvaluateAt, "evaluate-at", "",
+		"Preview the enforcement levels at an RFC3339 time (e.g., '2026-01-01T00:00:00Z') instead of now [local mode]")
+	cmd.Flags().StringVar((*string)(&opts.OnError), "on-error", string(runner.OnErrorContinue),
+		"When an environment fails to build or a policy fails to evaluate: 'continue' (report the failed parts with the other results) or 'abort'")
+	cmd.Flags().BoolVar(&opts.LintPolicies, "lint-policies", false,
+		"Lint the policies with 'opa check --strict' at startup, failing early with file:line diagnostics")
+	cmd.Flags().BoolVar(&opts.LintRegal, "lint-regal", false,
+		"Also lint the policies with Regal (requires --lint-policies and the regal binary)")
+	cmd.Flags().IntVar(&opts.MaxEnvironments, "max-environments", 0,
+		"Max number of environments/overlays checked per run, above it the run fails unless --sample-environments (0: no limit)")
+	cmd.Flags().Int64Var(&opts.MaxManifestBytes, "max-manifest-bytes", 0,
+		"Max size of each built manifest, above it the overlay fails with 'output too large' instead of being diffed and evaluated (0: no limit)")
+	cmd.Flags().IntVar(&opts.MaxResources, "max-resources", 0,
+		"Max number of resources of each built manifest, above it the overlay fails with 'output too large' (0: no limit)")
+	cmd.Flags().BoolVar(&opts.SampleEnvironments, "sample-environments", false,
+		"Above --max-environments, check the --always-check-environments ones and an even sample of the others, reporting the skipped ones")
+	cmd.Flags().StringSliceVar(&opts.AlwaysCheckEnvironments, "always-check-environments", []string{"prod*"},
+		"Glob patterns of prod-class environments never sampled out, matched against the overlay key or any of its segments")
+	cmd.Flags().StringToStringVar(&opts.BuildArgs, "build-args", map[string]string{},
+		"Overlay parameters injected on build (e.g., 'NAMESPACE=pr-123,IMAGE_TAG=abc'): NAMESPACE sets the namespace of all resources, every arg replaces its ${NAME} placeholders in the built manifests")
+
+	// Sandboxing of external processes (conftest, kustomize and its exec plugins)
+	cmd.Flags().DurationVar(&opts.ExecTimeout, "exec-timeout", sandbox.DEFAULT_TIMEOUT,
+		"Maximum wall clock time of each external process, 0 to disable")
+	cmd.Flags().Int64Var(&opts.ExecMaxOutputBytes, "exec-max-output-bytes", sandbox.DEFAULT_MAX_OUTPUT_BYTES,
+		"Maximum stdout/stderr size of each external process, 0 to disable")
+	cmd.Flags().Uint64Var(&opts.ExecMaxMemoryMB, "exec-max-memory-mb", 0,
+		"Maximum address space of each external process in MiB, 0 to disable (linux only, requires prlimit)")
+	cmd.Flags().Uint64Var(&opts.ExecMaxCPUSeconds, "exec-max-cpu-seconds", 0,
+		"Maximum CPU time of each external process in seconds, 0 to disable (linux only, requires prlimit)")
+
+	// Network access of all outbound calls: GitHub API, git, external data and decision log sinks
+	cmd.Flags().StringVar(&opts.HTTPSProxy, "https-proxy", "",
+		"Proxy URL of all outbound calls, e.g. 'http://proxy.corp:3128' (default: the HTTPS_PROXY environment variable)")
+	cmd.Flags().StringVar(&opts.NoProxy, "no-proxy", "",
+		"Comma-separated hosts, domains and CIDRs reached without the proxy (default: the NO_PROXY environment variable)")
+	cmd.Flags().StringVar(&opts.CABundle, "ca-bundle", "",
+		"PEM file of CAs trusted on top of the system ones, e.g. a TLS-inspecting proxy's (default: the "+httpclient.ENV_CA_BUNDLE+" environment variable)")
+	cmd.Flags().BoolVar(&opts.Offline, "offline", false,
+		"Air-gapped mode: forbid all network calls but to the SCM API (GitHub in github mode, the CI_SERVER_URL instance in gitlab mode, Bitbucket Cloud in bitbucket mode, none in local mode), any other egress fails at once (see the doctor command)")
 
 	// GitHub mode flags
 	cmd.Flags().StringVar(&opts.GhRepo, "gh-repo", "",
@@ -74,6 +232,45 @@ It builds kustomize manifests, diffs them, evaluates OPA policies, and posts det
 		"Path to services directory containing service folders [github mode]")
 	cmd.Flags().StringVar((*string)(&opts.GitCheckoutStrategy), "git-checkout-strategy", "sparse",
 		"Git checkout strategy: 'sparse' (scope to manifests path, faster) or 'shallow' (all files, depth 1) [github mode]")
+	cmd.Flags().BoolVar(&opts.GitSubmodules, "git-submodules", false,
+		"Initialize the git submodules of the checked out path, e.g. shared bases (depth 1) [github mode]")
+	cmd.Flags().BoolVar(&opts.GitLFS, "git-lfs", false,
+		"Pull the Git LFS objects of the checked out path, e.g. large configMapGenerator files (requires git-lfs) [github mode]")
+	cmd.Flags().BoolVar(&opts.InMemoryCheckouts, "in-memory-checkouts", false,
+		"Keep the checked out files in memory instead of ./tmp, copied for each kustomize build; for small sparse checkouts [github mode]")
+	cmd.Flags().StringVar(&opts.EvaluationCacheDir, "evaluation-cache-dir", "",
+		"Cache the evaluation of each PR in this directory; a run triggered by an override comment (issue_comment event) then only re-applies the enforcement levels to the cached results and updates the comment [github mode]")
+	cmd.Flags().StringVar(&opts.AutoFix, "auto-fix", "",
+		"Push the one-line fixes of the policies with autoFix enabled: 'commit' (to the PR head branch, the default without value) or 'pr' (in a follow-up PR against it), requires --provenance [github mode]")
+	cmd.Flags().Lookup("auto-fix").NoOptDefVal = models.AutoFixModeCommit
+	cmd.Flags().StringVar(&opts.AutoMerge, "auto-merge", "",
+		"When all blocking policies pass and the change is low-risk, 'enable' GitHub auto-merge on the PR or 'label' it for a merge bot; withdrawn when the PR stops qualifying [github mode]")
+	cmd.Flags().StringVar(&opts.AutoMergeMethod, "auto-merge-method", "squash",
+		"Merge method of the auto-merge: 'merge', 'squash' or 'rebase' [github mode]")
+	cmd.Flags().StringVar(&opts.AutoMergeLabel, "auto-merge-label", "automerge",
+		"Label of the low-risk PRs with --auto-merge label [github mode]")
+	cmd.Flags().IntVar(&opts.AutoMergeMaxDiffLines, "auto-merge-max-diff-lines", 100,
+		"Maximum manifest diff lines, summed over the environments, of a PR to auto-merge, 0 for no limit [github mode]")
+	cmd.Flags().BoolVar(&opts.AutoMergeImageBumpsOnly, "auto-merge-image-bumps-only", false,
+		"Only auto-merge routine image bumps, the PRs changing nothing but container images (see the imageBumps config) [github mode]")
+	cmd.Flags().BoolVar(&opts.GhSuggestionComments, "gh-suggestion-comments", false,
+		"Post the fixes suggested by policies as review comments with one-click suggested changes, when they map to a line changed by the PR (requires --provenance) [github mode]")
+	cmd.Flags().BoolVar(&opts.GhCheckRun, "gh-check-run", false,
+		"Create a 'gitops-kustomzchk/<service>' check run of the head commit, failing when a blocking policy fails or the check is incomplete, with an annotation per failing policy; require it in branch protection to block merges (needs the checks:write permission) [github mode]")
+	cmd.Flags().StringVar(&opts.GhStepSummary, "gh-step-summary", runner.GH_STEP_SUMMARY_APPEND,
+		"Write the report to the step summary of the job in GitHub Actions ($GITHUB_STEP_SUMMARY): 'off', 'append' (besides the PR comment) or 'only' (instead of it) [github mode]")
+
+	// GitLab mode flags, the checkout flags of the github mode apply
+	cmd.Flags().StringVar(&opts.GlProject, "gl-project", "",
+		"GitLab project path (e.g., group/subgroup/project, $CI_PROJECT_PATH in GitLab CI) [gitlab mode]")
+	cmd.Flags().IntVar(&opts.GlMrIid, "gl-mr-iid", 0,
+		"GitLab merge request iid ($CI_MERGE_REQUEST_IID in GitLab CI) [gitlab mode]")
+
+	// Bitbucket Cloud mode flags, the checkout flags of the github mode apply
+	cmd.Flags().StringVar(&opts.BbRepo, "bb-repo", "",
+		"Bitbucket Cloud repository (e.g., workspace/repo-slug, $BITBUCKET_REPO_FULL_NAME in Bitbucket Pipelines) [bitbucket mode]")
+	cmd.Flags().IntVar(&opts.BbPrId, "bb-pr-id", 0,
+		"Bitbucket Cloud pull request id ($BITBUCKET_PR_ID in Bitbucket Pipelines) [bitbucket mode]")
 
 	// Local mode flags (legacy)
 	cmd.Flags().StringVar(&opts.LcBeforeManifestsPath, "lc-before-manifests-path", "",
@@ -81,6 +278,9 @@ It builds kustomize manifests, diffs them, evaluates OPA policies, and posts det
 	cmd.Flags().StringVar(&opts.LcAfterManifestsPath, "lc-after-manifests-path", "",
 		"Path to after/head services directory [local mode, legacy]")
 
+	cmd.Flags().BoolVar(&opts.LcPrintDiff, "lc-print-diff", false,
+		"Print the diffs to stdout, with ANSI colors when stdout is a terminal and NO_COLOR is unset [local mode]")
+
 	// Local mode flags (v0.5+ dynamic paths with separate before/after)
 	cmd.Flags().StringVar(&opts.LcBeforeKustomizeBuildPath, "lc-before-kustomize-build-path", "",
 		"Before path template with [VARIABLES] [local mode] (e.g., '/path/before/[SERVICE]/[ENV]')")
@@ -90,5 +290,13 @@ It builds kustomize manifests, diffs them, evaluates OPA policies, and posts det
 	// NOTE: No required flags - validation done in validateOptions()
 	// This allows either legacy (--service + --environments) OR new (--kustomize-build-path + --kustomize-build-values)
 
+	cmd.AddCommand(newPolicyCmd(cmd, opts))
+	cmd.AddCommand(newBenchCmd(cmd, opts))
+	cmd.AddCommand(newDriftCmd(cmd, opts))
+	cmd.AddCommand(newReleaseCmd(cmd, opts))
+	cmd.AddCommand(newReportCmd())
+	cmd.AddCommand(newDoctorCmd(cmd, opts))
+	cmd.AddCommand(newServerCmd(cmd, opts))
+	cmd.AddCommand(newAdmissionCmd(cmd, opts))
 	return cmd
 }
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// SIMULATE_AT_FORMATS are the accepted --at formats of policy simulate
+var SIMULATE_AT_FORMATS = []string{time.DateOnly, time.RFC3339}
+
+// newPolicyCmd creates the policy command group, its subcommands share the flags of the root command
+func newPolicyCmd(root *cobra.Command, opts *runner.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy",
+		Short: "Policy authoring tools",
+	}
+
+	var at string
+	simulateCmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Preview the enforcement outcomes of the changes at a future date",
+		Long: `simulate builds and evaluates the manifests like a local mode run, then reports the policies whose
+enforcement level differs at the given date (e.g. "3 currently-warning policies would block").`,
+		Example: `  gitops-kustomzchk policy simulate --at 2025-09-01 \
+    --service my-app --environments stg,prod \
+    --lc-before-manifests-path ./before/services --lc-after-manifests-path ./after/services`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			simulateAt, err := parseSimulateAt(at)
+			if err != nil {
+				return err
+			}
+			return simulate(cmd.Context(), opts, simulateAt)
+		},
+	}
+	simulateCmd.Flags().StringVar(&at, "at", "", "Date to simulate the enforcement at (YYYY-MM-DD or RFC3339)")
+	_ = simulateCmd.MarkFlagRequired("at")
+	simulateCmd.Flags().AddFlagSet(root.Flags())
+
+	var regal bool
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Lint the policies with opa check --strict (and optionally Regal)",
+		Long: `lint runs 'opa check --strict' over the policies directory and prints the diagnostics as file:row:col,
+exiting non-zero if any is an error. Run it before pushing policy changes, or enable the same stage on
+every run with --lint-policies.`,
+		Example: `  gitops-kustomzchk policy lint --policies-path ./policies --regal`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lint(cmd.Context(), opts, regal)
+		},
+	}
+	lintCmd.Flags().BoolVar(&regal, "regal", false, "Also run the Regal linter (requires the regal binary)")
+	lintCmd.Flags().AddFlagSet(root.Flags())
+
+	var against, corpus string
+	impactCmd := &cobra.Command{
+		Use:   "impact",
+		Short: "Report the services a policy change would newly fail",
+		Long: `impact builds every <service>/environments/<env> overlay of the corpus directory and evaluates it with
+both the policies of --policies-path and the same policies directory at the git ref --against, then reports
+the overlays newly failing, newly blocking or newly passing. Run it on a policy repository PR before
+tightening a widely-used policy.`,
+		Example: `  gitops-kustomzchk policy impact --against origin/main \
+    --policies-path ./policies --corpus ./sample/k8s-manifests/services`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return impact(cmd.Context(), opts, against, corpus)
+		},
+	}
+	impactCmd.Flags().StringVar(&against, "against", "", "Git ref of the policies to compare with (e.g. origin/main)")
+	impactCmd.Flags().StringVar(&corpus, "corpus", "", "Directory of services to evaluate (<service>/environments/<env>)")
+	_ = impactCmd.MarkFlagRequired("against")
+	_ = impactCmd.MarkFlagRequired("corpus")
+	impactCmd.Flags().AddFlagSet(root.Flags())
+
+	packsCmd := &cobra.Command{
+		Use:   "packs",
+		Short: "Built-in policy pack tools",
+	}
+	packsListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the built-in policy packs, their versions, rules and deprecations",
+		Long: `list prints the built-in policy packs shipped with this version of the tool, with the version that added
+each rule and the deprecated rules with their migration hints. With --policies-path, it also shows the packs
+enabled by the compliance config: their pinned version and the rules run, disabled or left out by the pin.`,
+		Example: `  gitops-kustomzchk policy packs list --policies-path ./policies`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return listPolicyPacks(opts, cmd.Flags().Changed("policies-path"))
+		},
+	}
+	packsListCmd.Flags().AddFlagSet(root.Flags())
+	packsCmd.AddCommand(packsListCmd)
+
+	cmd.AddCommand(simulateCmd)
+	cmd.AddCommand(lintCmd)
+	cmd.AddCommand(impactCmd)
+	cmd.AddCommand(packsCmd)
+	return cmd
+}
+
+func parseSimulateAt(value string) (time.Time, error) {
+	for _, format := range SIMULATE_AT_FORMATS {
+		if at, err := time.Parse(format, value); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --at %q, expected YYYY-MM-DD or RFC3339", value)
+}
+
+func simulate(ctx context.Context, opts *runner.Options, at time.Time) error {
+	if opts.Debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	opts.RunMode = RUN_MODE_LOCAL // simulations never post to the SCM
+
+	shutdown, err := trace.InitTracer("gitops-kustomz", opts.EnableExportPerformanceReport, opts.OutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracer: %w", err)
+	}
+	defer shutdown()
+
+	stopProfiling, err := trace.StartProfiling(opts.OutputDir, opts.ProfileCPU, opts.ProfileMem)
+	if err != nil {
+		return fmt.Errorf("failed to start profiling: %w", err)
+	}
+	defer stopProfiling()
+
+	if err := validateOptions(opts); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	appRunner, err := initialize(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+	defer closeRunner(appRunner)
+	localRunner, ok := appRunner.(*runner.RunnerLocal)
+	if !ok {
+		return fmt.Errorf("simulate requires the local runner")
+	}
+	if err := localRunner.Simulate(at); err != nil {
+		return fmt.Errorf("failed to simulate: %w", err)
+	}
+	return nil
+}
+
+func lint(ctx context.Context, opts *runner.Options, regal bool) error {
+	if opts.Debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	if opts.PoliciesPath == "" {
+		return fmt.Errorf("policies-path is required")
+	}
+	diagnostics, err := policy.Lint(ctx, opts.PoliciesPath, policy.LintOptions{
+		Regal:      regal || opts.LintRegal,
+		ExecLimits: opts.ExecLimits(),
+	})
+	if err != nil {
+		return failure.PolicyEngine(fmt.Errorf("failed to lint policies: %w", err))
+	}
+	if len(diagnostics) == 0 {
+		fmt.Println("No lint issues found in", opts.PoliciesPath)
+		return nil
+	}
+	fmt.Println(policy.FormatLintDiagnostics(diagnostics))
+	if policy.HasLintErrors(diagnostics) {
+		return failure.PolicyEngine(fmt.Errorf("policy lint found errors in %s", opts.PoliciesPath))
+	}
+	return nil
+}
+
+func impact(ctx context.Context, opts *runner.Options, against, corpus string) error {
+	if opts.Debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	opts.RunMode = RUN_MODE_LOCAL // impact analyses never post to the SCM
+
+	if err := validateBenchOptions(opts); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	if opts.PoliciesPath == "" {
+		return fmt.Errorf("policies-path is required")
+	}
+	againstPath, cleanup, err := policy.CheckoutPoliciesAt(ctx, opts.PoliciesPath, against)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	againstEvaluator, err := newEvaluator(opts, againstPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = againstEvaluator.Close() }()
+
+	appRunner, err := initialize(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to initialize: %w", err)
+	}
+	defer closeRunner(appRunner)
+	localRunner, ok := appRunner.(*runner.RunnerLocal)
+	if !ok {
+		return fmt.Errorf("impact requires the local runner")
+	}
+	if err := localRunner.PolicyImpact(corpus, against, againstEvaluator); err != nil {
+		return fmt.Errorf("failed to analyze the policy impact: %w", err)
+	}
+	return nil
+}
+
+func listPolicyPacks(opts *runner.Options, withConfig bool) error {
+	configured := map[string]models.PolicyPack{}
+	if withConfig {
+		evaluator, err := newEvaluator(opts, opts.PoliciesPath)
+		if err != nil {
+			return err
+		}
+		if err := evaluator.LoadAndValidate(); err != nil {
+			return failure.PolicyEngine(fmt.Errorf("failed to load policy config: %w", err))
+		}
+		for _, pack := range evaluator.PolicyPacks() {
+			configured[pack.Name] = pack
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, pack := range policy.BuiltinPolicyPacks() {
+		enabled, ok := configured[pack.Name]
+		header := fmt.Sprintf("%s v%d", pack.Name, pack.Version)
+		switch {
+		case !withConfig:
+		case !ok:
+			header += " (not enabled)"
+		case enabled.PinnedVersion == 0:
+			header += " (enabled, not pinned)"
+		default:
+			header += fmt.Sprintf(" (enabled, pinned to v%d)", enabled.PinnedVersion)
+		}
+		fmt.Fprintln(w, header)
+		for _, rule := range pack.Rules {
+			status := ""
+			if ok {
+				status = "disabled"
+				if slices.ContainsFunc(enabled.Rules, func(r models.PolicyPackRule) bool { return r.ID == rule.ID }) {
+					status = "run"
+				} else if slices.ContainsFunc(enabled.NewerRules, func(r models.PolicyPackRule) bool { return r.ID == rule.ID }) {
+					status = "not run, newer than the pinned version"
+				}
+			}
+			line := fmt.Sprintf("  %s\tv%d\t%s", rule.ID, rule.Since, rule.Title)
+			if status != "" {
+				line += "\t" + status
+			}
+			fmt.Fprintln(w, line)
+		}
+		for _, deprecation := range pack.Deprecations {
+			replacement := ""
+			if deprecation.ReplacedBy != "" {
+				replacement = ", renamed " + deprecation.ReplacedBy
+			}
+			fmt.Fprintf(w, "  deprecated: %s%s: %s\n", deprecation.Rule, replacement, deprecation.Hint)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/worktree"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// newReleaseCmd creates the release command, it shares the flags of the root command
+func newReleaseCmd(root *cobra.Command, opts *runner.Options) *cobra.Command {
+	var baseTag, headTag, servicesPath string
+	var services []string
+	cmd := &cobra.Command{
+		Use:   "release",
+		Short: "Report what changes between two tags of the repository, across services",
+		Long: `release checks out the repository of --services-path at --base-tag and --head-tag in temporary worktrees,
+then diffs and evaluates each service (<service>/environments/<env>) like a local mode run between the
+two, with its reports in <output dir>/<service>. The consolidated report of the release is printed as
+markdown, and written to release.json and release.md in the output dir, for change-advisory reviews.
+All the services of either tag are checked unless --services is set.`,
+		Example: `  gitops-kustomzchk release --base-tag v1.2.0 --head-tag v1.3.0 \
+    --services-path ./sample/k8s-manifests/services --services my-app,other-app --environments stg,prod`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return release(cmd.Context(), opts, baseTag, headTag, servicesPath, services)
+		},
+	}
+	cmd.Flags().StringVar(&baseTag, "base-tag", "", "Tag (or any git ref) of the previous release, e.g. v1.2.0")
+	cmd.Flags().StringVar(&headTag, "head-tag", "", "Tag (or any git ref) of the release, e.g. v1.3.0")
+	cmd.Flags().StringVar(&servicesPath, "services-path", "", "Directory of the services, in the git repository")
+	cmd.Flags().StringSliceVar(&services, "services", nil, "Services to check, comma-separated (default: all)")
+	_ = cmd.MarkFlagRequired("base-tag")
+	_ = cmd.MarkFlagRequired("head-tag")
+	_ = cmd.MarkFlagRequired("services-path")
+	cmd.Flags().AddFlagSet(root.Flags())
+	return cmd
+}
+
+func release(ctx context.Context, opts *runner.Options, baseTag, headTag, servicesPath string, services []string) error {
+	if opts.Debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	opts.RunMode = RUN_MODE_LOCAL // release reports never post to the SCM
+	if len(opts.Environments) == 0 {
+		opts.Environments = []string{"*"}
+	}
+
+	report := &models.ReleaseReport{BaseTag: baseTag, HeadTag: headTag}
+	var err error
+	if report.BaseCommit, err = worktree.CommitOf(ctx, servicesPath, baseTag); err != nil {
+		return fmt.Errorf("unknown --base-tag %s: %w", baseTag, err)
+	}
+	if report.HeadCommit, err = worktree.CommitOf(ctx, servicesPath, headTag); err != nil {
+		return fmt.Errorf("unknown --head-tag %s: %w", headTag, err)
+	}
+	baseDir, cleanupBase, err := worktree.CheckoutAt(ctx, servicesPath, baseTag)
+	if err != nil {
+		return err
+	}
+	defer cleanupBase()
+	headDir, cleanupHead, err := worktree.CheckoutAt(ctx, servicesPath, headTag)
+	if err != nil {
+		return err
+	}
+	defer cleanupHead()
+
+	if len(services) == 0 {
+		services = runner.ReleaseServices(baseDir, headDir)
+	}
+	if len(services) == 0 {
+		return fmt.Errorf("no services found in %s, expected <service>/environments/<env> directories", servicesPath)
+	}
+	for _, service := range services {
+		report.Services = append(report.Services, releaseService(ctx, *opts, service, baseDir, headDir))
+	}
+
+	fmt.Print(runner.FormatReleaseMarkdown(report))
+	if err := runner.OutputRelease(opts.OutputDir, report); err != nil {
+		return err
+	}
+	for _, service := range report.Services {
+		if service.Error != "" {
+			return fmt.Errorf("service %s failed: %s", service.Service, service.Error)
+		}
+	}
+	return nil
+}
+
+// releaseService runs a service between the two tags, with its own copy of the options and output dir
+func releaseService(ctx context.Context, opts runner.Options, service, baseDir, headDir string) models.ReleaseService {
+	lg := logger.WithField("service", service)
+	opts.Service = service
+	opts.Services = nil
+	opts.LcBeforeManifestsPath = baseDir
+	opts.LcAfterManifestsPath = headDir
+	opts.OutputDir = filepath.Join(opts.OutputDir, service)
+	opts.Environments = append([]string(nil), opts.Environments...)
+
+	failed := func(err error) models.ReleaseService {
+		lg.WithField("error", err).Error("Failed to check the service")
+		return models.ReleaseService{Service: service, Error: err.Error()}
+	}
+	if err := validateOptions(&opts); err != nil {
+		return failed(fmt.Errorf("invalid options: %w", err))
+	}
+	appRunner, err := initialize(ctx, &opts)
+	if err != nil {
+		return failed(err)
+	}
+	defer closeRunner(appRunner)
+	localRunner, ok := appRunner.(*runner.RunnerLocal)
+	if !ok {
+		return failed(fmt.Errorf("release requires the local runner"))
+	}
+	data, err := localRunner.ProcessReport()
+	if err != nil {
+		return failed(err)
+	}
+	return runner.ReleaseServiceOf(service, data)
+}
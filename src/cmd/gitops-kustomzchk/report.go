@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/preview"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/report"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+	"github.com/spf13/cobra"
+)
+
+// newReportCmd creates the report command group, tools for the report.json files of past runs
+func newReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Report file tools",
+	}
+
+	var output string
+	var inPlace bool
+	migrateCmd := &cobra.Command{
+		Use:   "migrate <report.json>",
+		Short: "Convert a report.json of an older version to the current schema",
+		Long: fmt.Sprintf(`migrate upgrades a report.json written by any earlier version of the tool to the current
+schema (version %d) and prints it, or writes it to --output. Fields unknown to the current schema are dropped.`,
+			models.REPORT_SCHEMA_VERSION),
+		Example: `  gitops-kustomzchk report migrate ./archive/report.json --output ./report.v2.json`,
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if inPlace {
+				if output != "" {
+					return fmt.Errorf("--in-place and --output are mutually exclusive")
+				}
+				output = args[0]
+			}
+			return migrateReport(args[0], output)
+		},
+	}
+	migrateCmd.Flags().StringVarP(&output, "output", "o", "", "File to write the migrated report to (default: stdout)")
+	migrateCmd.Flags().BoolVar(&inPlace, "in-place", false, "Overwrite the report file with the migrated report")
+
+	var templatesPath, listen, reportsDir string
+	previewCmd := &cobra.Command{
+		Use:   "preview [report.json]",
+		Short: "Render the comment templates with a stored report, or the sample data",
+		Long: fmt.Sprintf(`preview prints the PR comment rendered by the templates of --templates-path with a report.json,
+or with the sample data if none is given.
+
+With --listen, it serves the same render over HTTP instead, re-reading the templates on every request:
+  GET %[1]s?repo=<owner/repo>&pr=<number>   latest report of the PR in --reports-dir
+  GET %[1]s?sample=true                     sample data
+Requests must carry "Authorization: Bearer $%[2]s".`, preview.PREVIEW_PATH, preview.ENV_PREVIEW_TOKEN),
+		Example: `  gitops-kustomzchk report preview ./output/report.json --templates-path ./templates
+  GITOPS_KUSTOMZCHK_PREVIEW_TOKEN=... gitops-kustomzchk report preview --listen 127.0.0.1:8080 --reports-dir ./reports`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if listen != "" {
+				if len(args) > 0 {
+					return fmt.Errorf("--listen serves the reports of --reports-dir, it takes no report file")
+				}
+				return servePreview(listen, templatesPath, reportsDir)
+			}
+			return previewReport(args, templatesPath)
+		},
+	}
+	previewCmd.Flags().StringVar(&templatesPath, "templates-path", "./templates", "Path to the templates directory")
+	previewCmd.Flags().StringVar(&listen, "listen", "", "Serve the preview endpoint on this address, e.g. 127.0.0.1:8080")
+	previewCmd.Flags().StringVar(&reportsDir, "reports-dir", "",
+		"Directory of the stored reports served with --listen, as <owner>/<repo>/<pr>[/<run>]/report.json")
+
+	cmd.AddCommand(migrateCmd)
+	cmd.AddCommand(previewCmd)
+	return cmd
+}
+
+func previewReport(args []string, templatesPath string) error {
+	var data *models.ReportData
+	var err error
+	if len(args) == 0 {
+		data, err = preview.SampleReport()
+	} else {
+		data, err = report.Load(args[0])
+	}
+	if err != nil {
+		return err
+	}
+	rendered, err := template.NewRenderer().RenderWithTemplates(templatesPath, template.NewTemplateData(data))
+	if err != nil {
+		return err
+	}
+	fmt.Print(rendered)
+	return nil
+}
+
+func servePreview(listen, templatesPath, reportsDir string) error {
+	token := os.Getenv(preview.ENV_PREVIEW_TOKEN)
+	if token == "" {
+		return fmt.Errorf("--listen requires the %s bearer token", preview.ENV_PREVIEW_TOKEN)
+	}
+	handler := &preview.Handler{Renderer: template.NewRenderer(), TemplatesPath: templatesPath, Token: token}
+	if reportsDir != "" {
+		handler.Store = &preview.DirStore{Dir: reportsDir}
+	}
+	mux := http.NewServeMux()
+	mux.Handle(preview.PREVIEW_PATH, handler)
+	server := &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	fmt.Fprintf(os.Stderr, "Serving template previews of %s on http://%s%s\n", templatesPath, listen, preview.PREVIEW_PATH)
+	return server.ListenAndServe()
+}
+
+func migrateReport(path, output string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read report: %w", err)
+	}
+	version, err := report.SchemaVersionOf(data)
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+	migrated, err := report.Migrate(data)
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s: %w", path, err)
+	}
+
+	if output == "" {
+		fmt.Println(string(migrated))
+		return nil
+	}
+	if err := perm.WriteFile(output, append(migrated, '\n')); err != nil {
+		return fmt.Errorf("failed to write migrated report: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Migrated %s from schema version %d to %d: %s\n", path, version, models.REPORT_SCHEMA_VERSION, output)
+	return nil
+}
@@ -3,14 +3,28 @@ package main
 import (
 	"context"
 	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/bitbucket"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/encrypt"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitlab"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/inventory"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/notify"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/pathbuilder"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/report"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/transcript"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,31 +33,83 @@ var logger *log.Entry = log.New().WithFields(log.Fields{
 })
 
 const (
-	RUN_MODE_GITHUB = "github"
-	RUN_MODE_LOCAL  = "local"
+	RUN_MODE_GITHUB    = "github"
+	RUN_MODE_GITLAB    = "gitlab"
+	RUN_MODE_BITBUCKET = "bitbucket"
+	RUN_MODE_LOCAL     = "local"
 )
 
+// RUN_MODES are the supported run modes
+var RUN_MODES = []string{RUN_MODE_GITHUB, RUN_MODE_GITLAB, RUN_MODE_BITBUCKET, RUN_MODE_LOCAL}
+
+// AUTO_MERGE_METHODS are the merge methods of GitHub auto-merge
+var AUTO_MERGE_METHODS = []string{"merge", "squash", "rebase"}
+
 // Initialize creates and initializes the appropriate runner
 func createRunner(ctx context.Context, opts *runner.Options) (runner.RunnerInterface, error) {
 	logger.WithField("opts", opts).Debug("Creating runner..")
 
 	builder := kustomize.NewBuilderWithOptions(opts.FailOnOverlayNotFound)
-	differ := diff.NewDiffer()
-	evaluator := policy.NewPolicyEvaluator(opts.PoliciesPath)
+	builder.ExecLimits = opts.ExecLimits()
+	builder.BuildArgs = opts.BuildArgs
+	builder.ComponentProvenance = opts.ComponentProvenance
+	builder.Provenance = opts.Provenance
+	builder.SOPS = opts.SOPS
+	builder.Plugins = opts.KustomizePlugins
+	builder.Helm = opts.KustomizeHelm
+	builder.Engine = opts.KustomizeEngine
+	builder.LoadRestrictor = opts.KustomizeLoadRestrictor
+	builder.MaxManifestBytes = opts.MaxManifestBytes
+	builder.MaxResources = opts.MaxResources
+	builder.FS = opts.CheckoutFS()
+	differ := diff.NewDifferWithOptions(opts.DiffEngine)
+	differ.Style = opts.DiffStyle
+	evaluator, err := newEvaluator(opts, opts.PoliciesPath)
+	if err != nil {
+		return nil, err
+	}
 	renderer := template.NewRenderer()
 
 	switch opts.RunMode {
 	case RUN_MODE_GITHUB:
-		ghClient, err := github.NewClient()
+		ghClient, err := newGitHubClient(opts)
 		if err != nil {
-			return nil, fmt.Errorf("GitHub authentication failed: %w", err)
+			return nil, failure.Auth(fmt.Errorf("GitHub authentication failed: %w", err))
 		}
+		ghClient.FS = builder.FS
+		ghClient.Transcript = opts.Transcript
 		runner, err := runner.NewRunnerGitHub(
 			ctx, opts, ghClient, builder, differ, evaluator, renderer)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create GitHub runner: %w", err)
 		}
 		return runner, nil
+	case RUN_MODE_GITLAB:
+		glClient, err := gitlab.NewClient()
+		if err != nil {
+			return nil, failure.Auth(fmt.Errorf("GitLab authentication failed: %w", err))
+		}
+		glClient.FS = builder.FS
+		glClient.Transcript = opts.Transcript
+		runner, err := runner.NewRunnerGitLab(
+			ctx, opts, glClient, builder, differ, evaluator, renderer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GitLab runner: %w", err)
+		}
+		return runner, nil
+	case RUN_MODE_BITBUCKET:
+		bbClient, err := bitbucket.NewClient()
+		if err != nil {
+			return nil, failure.Auth(fmt.Errorf("Bitbucket authentication failed: %w", err))
+		}
+		bbClient.FS = builder.FS
+		bbClient.Transcript = opts.Transcript
+		runner, err := runner.NewRunnerBitbucket(
+			ctx, opts, bbClient, builder, differ, evaluator, renderer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Bitbucket runner: %w", err)
+		}
+		return runner, nil
 	case RUN_MODE_LOCAL:
 		runner, err := runner.NewRunnerLocal(
 			ctx, opts, builder, differ, evaluator, renderer,
@@ -57,17 +123,52 @@ func createRunner(ctx context.Context, opts *runner.Options) (runner.RunnerInter
 	}
 }
 
+// newEvaluator creates the policy evaluator of a policies directory with the evaluation options of the run. A
+// diff-only run (--no-policies) gets an evaluator loading and evaluating nothing, without engine, clock or decision log
+func newEvaluator(opts *runner.Options, policiesPath string) (*policy.PolicyEvaluator, error) {
+	if opts.NoPolicies {
+		return policy.NewPolicyEvaluatorWithOptions(policiesPath, policy.EvaluatorOptions{NoPolicies: true}), nil
+	}
+	evaluatorOptions := policy.EvaluatorOptions{
+		ExternalDataCacheDir: opts.ExternalDataCacheDir,
+		Engine:               opts.PolicyEngine,
+		ExecLimits:           opts.ExecLimits(),
+		ContinueOnError:      opts.ContinueOnError(),
+	}
+	if opts.DecisionLog != "" {
+		evaluatorOptions.DecisionLogger = policy.NewDecisionLogger(opts.DecisionLog)
+		evaluatorOptions.DecisionLogLabels = map[string]string{"id": "gitops-kustomzchk", "version": Version}
+		evaluatorOptions.DecisionLogOmitInput = opts.DecisionLogOmitInput
+	}
+	if opts.EvaluateAt != "" {
+		evaluateAt, err := time.Parse(time.RFC3339, opts.EvaluateAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --evaluate-at: %w", err)
+		}
+		evaluatorOptions.Clock = policy.FixedClock(evaluateAt)
+	}
+	return policy.NewPolicyEvaluatorWithOptions(policiesPath, evaluatorOptions), nil
+}
+
 func initialize(ctx context.Context, opts *runner.Options) (runner.RunnerInterface, error) {
 	runner, err := createRunner(ctx, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create runner: %w", err)
 	}
 	if err := runner.Initialize(); err != nil {
+		closeRunner(runner)
 		return nil, fmt.Errorf("failed to initialize runner: %w", err)
 	}
 	return runner, nil
 }
 
+// closeRunner releases the resources of a run, a failure is only logged: the outcome is already published
+func closeRunner(appRunner runner.RunnerInterface) {
+	if err := appRunner.Close(); err != nil {
+		logger.WithField("error", err).Warn("Failed to clean up the run")
+	}
+}
+
 func run(ctx context.Context, opts *runner.Options) error {
 	logger.WithField("opts", opts).Info("Running..")
 	if opts.Debug {
@@ -81,16 +182,45 @@ func run(ctx context.Context, opts *runner.Options) error {
 	}
 	defer shutdown()
 
+	stopProfiling, err := trace.StartProfiling(opts.OutputDir, opts.ProfileCPU, opts.ProfileMem)
+	if err != nil {
+		return fmt.Errorf("failed to start profiling: %w", err)
+	}
+	defer stopProfiling()
+
+	return runPipeline(ctx, opts)
+}
+
+// runPipeline validates the options then builds, diffs, evaluates and publishes: the run of the root command, or of
+// a pull request in the server mode
+func runPipeline(ctx context.Context, opts *runner.Options) error {
 	// Validate options
 	if err := validateOptions(opts); err != nil {
 		return fmt.Errorf("invalid options: %w", err)
 	}
 
+	// Written last, whether the run fails or not, to audit what was published before the failure
+	if opts.PublishTranscript {
+		opts.Transcript = transcript.New()
+		defer writeTranscript(opts.Transcript, opts.OutputDir)
+	}
+
+	// The server shares its dispatcher with all the runs, a CLI run sends its notifications before exiting
+	if opts.Notifier == nil {
+		notifier, err := opts.NewNotifier()
+		if err != nil {
+			return fmt.Errorf("failed to create the notifier: %w", err)
+		}
+		opts.Notifier = notifier
+		defer closeNotifier(notifier)
+	}
+
 	// Initialize runner
 	appRunner, err := initialize(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("failed to initialize: %w", err)
 	}
+	defer closeRunner(appRunner)
 
 	err = appRunner.Process()
 	if err != nil {
@@ -100,16 +230,232 @@ func run(ctx context.Context, opts *runner.Options) error {
 	return nil
 }
 
+// newGitHubClient creates the GitHub client of the token of the run, or of GH_TOKEN
+func newGitHubClient(opts *runner.Options) (*github.Client, error) {
+	if opts.GhToken != "" {
+		return github.NewClientWithToken(opts.GhToken), nil
+	}
+	return github.NewClient()
+}
+
+// closeNotifier sends the queued notifications, a failure is only logged: the outcome is in the comment and outputs
+func closeNotifier(notifier *notify.Dispatcher) {
+	if err := notifier.Close(); err != nil {
+		logger.WithField("error", err).Warn("Failed to send notifications")
+	}
+}
+
+// writeTranscript writes the SCM mutations of the run to the output dir, a failure is only logged
+func writeTranscript(t *transcript.Transcript, outputDir string) {
+	filePath, err := t.Write(outputDir)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to write the SCM transcript")
+		return
+	}
+	logger.WithField("filePath", filePath).WithField("mutations", len(t.Entries())).Info("Written SCM transcript to file")
+}
+
+// validateServices checks the services of a multi-service run (--service a,b)
+func validateServices(opts *runner.Options) error {
+	if len(opts.Services) <= 1 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, service := range opts.Services {
+		if service == "" || seen[service] {
+			return fmt.Errorf("--service must list distinct services, got: %v", opts.Services)
+		}
+		seen[service] = true
+	}
+	// The fixes and the merge gate act on the whole pull request, once
+	if opts.AutoFix != "" || opts.AutoMerge != "" {
+		return fmt.Errorf("--auto-fix and --auto-merge check a single service, got --service %v", opts.Services)
+	}
+	return nil
+}
+
 func validateOptions(opts *runner.Options) error {
 	// Validate run mode
-	if opts.RunMode != "github" && opts.RunMode != "local" {
-		return fmt.Errorf("run-mode must be 'github' or 'local', got: %s", opts.RunMode)
+	if !slices.Contains(RUN_MODES, opts.RunMode) {
+		return fmt.Errorf("run-mode must be one of %v, got: %s", RUN_MODES, opts.RunMode)
+	}
+
+	if err := kustomize.BuildArgs(opts.BuildArgs).Validate(); err != nil {
+		return fmt.Errorf("invalid --build-args: %w", err)
+	}
+
+	if err := opts.KustomizePlugins.Validate(); err != nil {
+		return fmt.Errorf("invalid kustomize plugin options: %w", err)
+	}
+	if err := opts.KustomizeHelm.Validate(); err != nil {
+		return fmt.Errorf("invalid kustomize helm options: %w", err)
+	}
+	if opts.SOPS != "" && !slices.Contains(kustomize.SOPS_MODES, opts.SOPS) {
+		return fmt.Errorf("sops must be one of %v, got: %s", kustomize.SOPS_MODES, opts.SOPS)
+	}
+
+	if !slices.Contains(kustomize.KUSTOMIZE_ENGINES, opts.KustomizeEngine) {
+		return fmt.Errorf("kustomize-engine must be one of %v, got: %s", kustomize.KUSTOMIZE_ENGINES, opts.KustomizeEngine)
+	}
+	if !slices.Contains(kustomize.LOAD_RESTRICTORS, opts.KustomizeLoadRestrictor) {
+		return fmt.Errorf("kustomize-load-restrictor must be one of %v, got: %s", kustomize.LOAD_RESTRICTORS, opts.KustomizeLoadRestrictor)
+	}
+	if !slices.Contains(diff.DIFF_ENGINES, opts.DiffEngine) {
+		return fmt.Errorf("diff-engine must be one of %v, got: %s", diff.DIFF_ENGINES, opts.DiffEngine)
+	}
+	if !slices.Contains(diff.DIFF_STYLES, opts.DiffStyle) {
+		return fmt.Errorf("diff-style must be one of %v, got: %s", diff.DIFF_STYLES, opts.DiffStyle)
+	}
+	if !slices.Contains(policy.POLICY_ENGINES, opts.PolicyEngine) {
+		return fmt.Errorf("policy-engine must be one of %v, got: %s", policy.POLICY_ENGINES, opts.PolicyEngine)
+	}
+
+	if opts.EvaluateAt != "" {
+		if opts.RunMode != RUN_MODE_LOCAL {
+			return fmt.Errorf("--evaluate-at is only for local mode")
+		}
+		if _, err := time.Parse(time.RFC3339, opts.EvaluateAt); err != nil {
+			return fmt.Errorf("evaluate-at must be an RFC3339 time, got: %s", opts.EvaluateAt)
+		}
+	}
+
+	if (opts.GhSuggestionComments || opts.GhCheckRun) && (opts.RunMode == RUN_MODE_GITLAB || opts.RunMode == RUN_MODE_BITBUCKET) {
+		return fmt.Errorf("--gh-suggestion-comments and --gh-check-run are only for github mode")
+	}
+	if !slices.Contains(runner.GH_STEP_SUMMARY_MODES, opts.GhStepSummary) {
+		return fmt.Errorf("gh-step-summary must be one of %v, got: %s", runner.GH_STEP_SUMMARY_MODES, opts.GhStepSummary)
+	}
+	if opts.GhStepSummary == runner.GH_STEP_SUMMARY_ONLY && opts.RunLock {
+		return fmt.Errorf("--run-lock leases the PR comment, it cannot be used with --gh-step-summary only")
+	}
+	if opts.GhSuggestionComments && !opts.Provenance {
+		return fmt.Errorf("--gh-suggestion-comments requires --provenance, to map the fixes to source lines")
+	}
+	if opts.AutoFix != "" {
+		if opts.AutoFix != models.AutoFixModeCommit && opts.AutoFix != models.AutoFixModePR {
+			return fmt.Errorf("auto-fix must be '%s' or '%s', got: %s", models.AutoFixModeCommit, models.AutoFixModePR, opts.AutoFix)
+		}
+		if opts.RunMode != "github" {
+			return fmt.Errorf("--auto-fix is only for github mode")
+		}
+		if !opts.Provenance {
+			return fmt.Errorf("--auto-fix requires --provenance, to map the fixes to source lines")
+		}
+		if opts.InMemoryCheckouts {
+			return fmt.Errorf("--auto-fix commits to the checkout, it cannot be used with --in-memory-checkouts")
+		}
+	}
+	if (opts.NotifySlack || len(opts.NotifyWebhooks) > 0) && opts.RunMode == RUN_MODE_LOCAL {
+		return fmt.Errorf("--notify-slack and --notify-webhook are only for github, gitlab and bitbucket modes")
+	}
+	if !slices.Contains(runner.NOTIFY_ON_MODES, opts.NotifyOn) {
+		return fmt.Errorf("notify-on must be one of %v, got: %s", runner.NOTIFY_ON_MODES, opts.NotifyOn)
+	}
+	for _, url := range opts.NotifyWebhooks {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return fmt.Errorf("notify-webhook must be an http(s) URL, got: %s", url)
+		}
+	}
+	if opts.PublishTranscript && opts.RunMode == RUN_MODE_LOCAL {
+		return fmt.Errorf("--publish-transcript is only for github, gitlab and bitbucket modes, local mode publishes nothing")
+	}
+	if opts.InMemoryCheckouts && opts.RunMode == RUN_MODE_LOCAL {
+		return fmt.Errorf("--in-memory-checkouts is only for github, gitlab and bitbucket modes")
+	}
+	if opts.EvaluationCacheDir != "" && opts.RunMode != "github" {
+		return fmt.Errorf("--evaluation-cache-dir is only for github mode")
+	}
+
+	if opts.AutoMerge != "" {
+		if opts.AutoMerge != models.AutoMergeModeEnable && opts.AutoMerge != models.AutoMergeModeLabel {
+			return fmt.Errorf("auto-merge must be '%s' or '%s', got: %s", models.AutoMergeModeEnable, models.AutoMergeModeLabel, opts.AutoMerge)
+		}
+		if opts.RunMode != "github" {
+			return fmt.Errorf("--auto-merge is only for github mode")
+		}
+		if !slices.Contains(AUTO_MERGE_METHODS, opts.AutoMergeMethod) {
+			return fmt.Errorf("auto-merge-method must be one of %v, got: %s", AUTO_MERGE_METHODS, opts.AutoMergeMethod)
+		}
+		if opts.AutoMerge == models.AutoMergeModeLabel && opts.AutoMergeLabel == "" {
+			return fmt.Errorf("--auto-merge label requires --auto-merge-label")
+		}
+		if opts.AutoMergeMaxDiffLines < 0 {
+			return fmt.Errorf("auto-merge-max-diff-lines must be >= 0, got: %d", opts.AutoMergeMaxDiffLines)
+		}
+	}
+
+	if len(opts.ReportFormats) == 0 {
+		return fmt.Errorf("report-format must not be empty")
+	}
+	for _, format := range opts.ReportFormats {
+		if !slices.Contains(report.FORMATS, format) {
+			return fmt.Errorf("report-format formats must be among %v, got: %s", report.FORMATS, format)
+		}
+	}
+
+	for _, format := range opts.ExportInventory {
+		if !slices.Contains(inventory.FORMATS, format) {
+			return fmt.Errorf("export-inventory formats must be among %v, got: %s", inventory.FORMATS, format)
+		}
+	}
+
+	if opts.ExportManifestsGzip && !opts.ExportManifests {
+		return fmt.Errorf("export-manifests-gzip requires export-manifests")
+	}
+	if opts.EncryptArtifacts != "" && !slices.Contains(encrypt.TOOLS, opts.EncryptArtifacts) {
+		return fmt.Errorf("encrypt-artifacts must be among %v, got: %s", encrypt.TOOLS, opts.EncryptArtifacts)
+	}
+
+	if opts.NoPolicies && opts.RunMode != RUN_MODE_LOCAL {
+		return fmt.Errorf("--no-policies is only for local mode, a pull request report without policies would pass every check")
+	}
+	if opts.NoPolicies && opts.LintPolicies {
+		return fmt.Errorf("--lint-policies lints the policies of --policies-path, it cannot be used with --no-policies")
+	}
+	if opts.LintRegal && !opts.LintPolicies {
+		return fmt.Errorf("lint-regal requires lint-policies")
+	}
+	if opts.OnError != runner.OnErrorContinue && opts.OnError != runner.OnErrorAbort {
+		return fmt.Errorf("on-error must be 'continue' or 'abort', got: %s", opts.OnError)
+	}
+
+	if opts.MaxEnvironments < 0 {
+		return fmt.Errorf("max-environments must be >= 0, got: %d", opts.MaxEnvironments)
+	}
+	if opts.MaxManifestBytes < 0 || opts.MaxResources < 0 {
+		return fmt.Errorf("max-manifest-bytes and max-resources must be >= 0")
+	}
+	if opts.SampleEnvironments && opts.MaxEnvironments == 0 {
+		return fmt.Errorf("--sample-environments requires --max-environments")
+	}
+	for _, pattern := range opts.AlwaysCheckEnvironments {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --always-check-environments pattern %q: %w", pattern, err)
+		}
+	}
+
+	if requirements := opts.ExecRequirements(); opts.NoExec && len(requirements) > 0 {
+		return fmt.Errorf("--no-exec: the following features require external binaries:\n  - %s",
+			strings.Join(requirements, "\n  - "))
+	}
+	if conflicts := opts.OfflineConflicts(); opts.Offline && len(conflicts) > 0 {
+		return fmt.Errorf("--offline: the following features require network access:\n  - %s",
+			strings.Join(conflicts, "\n  - "))
+	}
+
+	if opts.AutoDetectServices {
+		if opts.RunMode != RUN_MODE_GITHUB {
+			return fmt.Errorf("--auto-detect-services is only for github mode")
+		}
+		if len(opts.Services) > 0 || opts.KustomizeBuildPath != "" {
+			return fmt.Errorf("--auto-detect-services detects the services of --manifests-path, it cannot be used with --service or --kustomize-build-path")
+		}
 	}
 
 	// Check which flag set is being used
 	useDynamicShared := opts.KustomizeBuildPath != "" || opts.KustomizeBuildValues != ""
 	useLocalDynamic := opts.LcBeforeKustomizeBuildPath != "" || opts.LcAfterKustomizeBuildPath != ""
-	useLegacy := opts.Service != "" || len(opts.Environments) > 0
+	useLegacy := opts.Service != "" || len(opts.Environments) > 0 || opts.AutoDetectServices
 
 	// Count how many modes are being used
 	modesUsed := 0
@@ -166,12 +512,19 @@ func validateOptions(opts *runner.Options) error {
 
 	// Validate legacy flags
 	if useLegacy {
-		if opts.Service == "" {
+		if opts.Service == "" && !opts.AutoDetectServices {
 			return fmt.Errorf("--service is required when using legacy flags")
 		}
+		if err := validateServices(opts); err != nil {
+			return err
+		}
 		if len(opts.Environments) == 0 {
 			return fmt.Errorf("--environments is required when using legacy flags")
 		}
+		envs := &pathbuilder.PathBuilder{Template: "[ENV]", Variables: map[string][]string{"ENV": opts.Environments}}
+		if err := envs.Validate(); err != nil {
+			return fmt.Errorf("invalid --environments: %w", err)
+		}
 	}
 
 	// Validate that at least one mode is used
@@ -188,12 +541,29 @@ func validateOptions(opts *runner.Options) error {
 			}
 		}
 	} else {
-		// GitHub mode
-		if opts.GhRepo == "" {
-			return fmt.Errorf("github mode requires --gh-repo")
-		}
-		if opts.GhPrNumber == 0 {
-			return fmt.Errorf("github mode requires --gh-pr-number")
+		switch opts.RunMode {
+		case RUN_MODE_GITLAB:
+			if opts.GlProject == "" {
+				return fmt.Errorf("gitlab mode requires --gl-project")
+			}
+			if opts.GlMrIid == 0 {
+				return fmt.Errorf("gitlab mode requires --gl-mr-iid")
+			}
+		case RUN_MODE_BITBUCKET:
+			if opts.BbRepo == "" {
+				return fmt.Errorf("bitbucket mode requires --bb-repo")
+			}
+			if opts.BbPrId == 0 {
+				return fmt.Errorf("bitbucket mode requires --bb-pr-id")
+			}
+		default:
+			// GitHub mode
+			if opts.GhRepo == "" {
+				return fmt.Errorf("github mode requires --gh-repo")
+			}
+			if opts.GhPrNumber == 0 {
+				return fmt.Errorf("github mode requires --gh-pr-number")
+			}
 		}
 		// Validate git checkout strategy
 		if opts.GitCheckoutStrategy == "" {
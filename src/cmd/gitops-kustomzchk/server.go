@@ -0,0 +1,406 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/notify"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/preview"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/source"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/tenant"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/webhook"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// SERVER_SHUTDOWN_TIMEOUT bounds the wait for the runs in progress on SIGINT/SIGTERM
+const SERVER_SHUTDOWN_TIMEOUT = 10 * time.Minute
+
+// newServerCmd creates the server command, it shares the flags of the root command
+func newServerCmd(root *cobra.Command, opts *runner.Options) *cobra.Command {
+	var flags serverFlags
+	cmd := &cobra.Command{
+		Use:   "server",
+		Short: "Check the pull requests of GitHub webhook events, instead of a CI workflow per repository",
+		Long: fmt.Sprintf(`server listens for the GitHub webhook deliveries (POST %[1]s, signed with $%[2]s) of the
+pull_request (opened, synchronize, reopened, ready_for_review) and issue_comment (created on a pull request)
+events, and runs the github mode on their pull request with the flags of the root command, posting the comment.
+Runs of the same pull request are serialized, the events received meanwhile coalesced into one next run.
+The outputs of a run are written to <output dir>/<owner>/<repo>/<pr>/<delivery>. With --evaluation-cache-dir,
+an override comment re-applies the enforcement levels to the cached evaluation at once.
+
+With --tenants-dir, the repositories are checked with the settings of their tenant (credentials, policies,
+templates, notification targets), one YAML file per tenant reloaded when it changes; the repositories of no
+tenant are not checked.
+
+The policies and templates (--policies-path, --templates-path and those of the tenants) are a directory, a git
+repository (git+https://<host>/<repo>?ref=<ref>&path=<dir>, with $%[5]s for private ones) or an OCI artifact
+(oci://<registry>/<repository>:<tag>, pulled with conftest). They are polled every --source-poll-interval, a new
+version is loaded once validated and used by the next runs, the runs in progress keep theirs; every report records
+the versions it was produced with.
+
+GET %[3]s serves the template previews of the stored reports when $%[4]s is set, GET /healthz the liveness.`,
+			webhook.WEBHOOK_PATH, webhook.ENV_WEBHOOK_SECRET, preview.PREVIEW_PATH, preview.ENV_PREVIEW_TOKEN, source.ENV_SOURCE_TOKEN),
+		Example: `  GITOPS_KUSTOMZCHK_WEBHOOK_SECRET=... GH_TOKEN=... gitops-kustomzchk server --listen :8080 \
+    --kustomize-build-path "services/[SERVICE]/clusters/[CLUSTER]/[ENV]" \
+    --kustomize-build-values "SERVICE=my-app;CLUSTER=alpha,beta;ENV=stg,prod" \
+    --evaluation-cache-dir /var/lib/gitops-kustomzchk/cache --output-dir /var/lib/gitops-kustomzchk/runs`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return serve(cmd.Context(), opts, flags)
+		},
+	}
+	cmd.Flags().StringVar(&flags.listen, "listen", ":8080", "Address to serve the webhook endpoint on")
+	cmd.Flags().IntVar(&flags.maxConcurrentRuns, "max-concurrent-runs", 2,
+		"Maximum number of pull requests checked at the same time, the other events wait")
+	cmd.Flags().StringVar(&flags.tenantsDir, "tenants-dir", "",
+		"Directory of the tenant files (<name>.yaml): the settings of the repositories of each tenant, reloaded when they change")
+	cmd.Flags().DurationVar(&flags.sourcePollInterval, "source-poll-interval", source.POLL_INTERVAL,
+		"How often the policies and templates sources are checked for a new version")
+	cmd.Flags().AddFlagSet(root.Flags())
+	return cmd
+}
+
+// serverFlags are the flags of the server command on top of the root ones
+type serverFlags struct {
+	listen             string
+	maxConcurrentRuns  int
+	tenantsDir         string
+	sourcePollInterval time.Duration
+}
+
+// SOURCE_POLICIES and SOURCE_TEMPLATES are the kinds of the watched sources of the runs
+const (
+	SOURCE_POLICIES  = "policies"
+	SOURCE_TEMPLATES = "templates"
+)
+
+// server runs the checks of the webhook events
+type server struct {
+	opts    runner.Options
+	tenants *tenant.Store // nil without --tenants-dir
+
+	mu sync.Mutex
+	// notifiers are the dispatchers of the runs by tenant and notification settings, the runs of a tenant share
+	// the rate limits of its credentials
+	notifiers map[string]*notify.Dispatcher
+
+	// watchers are the policies and templates sources of the runs by kind and source, polled until watchCtx is done
+	watchersMu   sync.Mutex
+	watchers     map[string]*source.Watcher
+	watchCtx     context.Context
+	pollInterval time.Duration
+}
+
+func serve(ctx context.Context, opts *runner.Options, flags serverFlags) error {
+	if opts.Debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	if opts.RunMode != RUN_MODE_GITHUB {
+		return fmt.Errorf("server only serves GitHub webhooks, got --run-mode %s", opts.RunMode)
+	}
+	secret := os.Getenv(webhook.ENV_WEBHOOK_SECRET)
+	if secret == "" {
+		return fmt.Errorf("server requires the %s webhook secret", webhook.ENV_WEBHOOK_SECRET)
+	}
+	// The options of every run, with the pull request of its event
+	placeholder := webhook.Event{Repo: "owner/repo", Number: 1, Delivery: "startup"}
+	check := serverRunOptions(*opts, placeholder)
+	if err := validateOptions(&check); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+
+	// The signals stop the server only, the runs accepted go on to publish their outcome
+	runCtx := context.WithoutCancel(ctx)
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &server{opts: *opts, notifiers: map[string]*notify.Dispatcher{}, watchers: map[string]*source.Watcher{},
+		watchCtx: ctx, pollInterval: flags.sourcePollInterval}
+	defer srv.closeWatchers()
+	// The sources of the server flags are loaded at once, those of the tenants with them
+	if _, err := srv.watcher(SOURCE_POLICIES, opts.PoliciesPath); err != nil {
+		return err
+	}
+	if _, err := srv.watcher(SOURCE_TEMPLATES, opts.TemplatesPath); err != nil {
+		return err
+	}
+	if flags.tenantsDir != "" {
+		tenants, err := tenant.NewStore(flags.tenantsDir, func(t *tenant.Tenant) error {
+			return srv.validateTenant(serverRunOptions(*opts, placeholder), t)
+		})
+		if err != nil {
+			return err
+		}
+		logger.WithField("dir", flags.tenantsDir).WithField("tenants", len(tenants.Current().Tenants)).Info("Loaded tenants")
+		srv.tenants = tenants
+		go srv.tenants.Watch(ctx, tenant.RELOAD_INTERVAL)
+	} else if _, err := github.NewClient(); err != nil {
+		return err
+	}
+	queue := webhook.NewQueue(flags.maxConcurrentRuns, srv.run)
+
+	mux := http.NewServeMux()
+	mux.Handle(webhook.WEBHOOK_PATH, &webhook.Handler{
+		Secret: secret,
+		Dispatch: func(event webhook.Event) {
+			if _, ok := srv.tenantOf(event); ok {
+				queue.Add(runCtx, event)
+			}
+		},
+	})
+	if token := os.Getenv(preview.ENV_PREVIEW_TOKEN); token != "" {
+		handler := &preview.Handler{
+			Renderer: template.NewRenderer(),
+			Templates: func() (string, func()) {
+				w, _ := srv.watcher(SOURCE_TEMPLATES, opts.TemplatesPath) // loaded at startup
+				snapshot, release := w.Acquire()
+				return snapshot.Dir, release
+			},
+			Store: &preview.DirStore{Dir: opts.OutputDir},
+			Token: token,
+		}
+		if opts.NoManifestContentInComment {
+			handler.Redact = runner.RedactManifestContent
+		}
+		mux.Handle(preview.PREVIEW_PATH, handler)
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	httpServer := &http.Server{Addr: flags.listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() {
+		logger.WithField("listen", flags.listen).Info("Serving GitHub webhooks")
+		errs <- httpServer.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Stop accepting deliveries, then let the runs in progress and queued finish
+	logger.Info("Shutting down, waiting for the runs in progress")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SERVER_SHUTDOWN_TIMEOUT)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	queue.Wait()
+	srv.closeNotifiers()
+	return nil
+}
+
+// serverRunOptions returns the options of the run of an event, a copy of the server options
+func serverRunOptions(opts runner.Options, event webhook.Event) runner.Options {
+	opts.GhRepo = event.Repo
+	opts.GhPrNumber = event.Number
+	opts.CommentEvent = event.Comment
+	opts.OutputDir = filepath.Join(opts.OutputDir, filepath.FromSlash(event.Repo), strconv.Itoa(event.Number), event.Delivery)
+	opts.Environments = append([]string(nil), opts.Environments...)
+	return opts
+}
+
+// tenantRunOptions applies the settings of a tenant to the options of a run, its token is set by the run
+func tenantRunOptions(opts runner.Options, t *tenant.Tenant) runner.Options {
+	if t.PoliciesPath != "" {
+		opts.PoliciesPath = t.PoliciesPath
+	}
+	if t.TemplatesPath != "" {
+		opts.TemplatesPath = t.TemplatesPath
+	}
+	if t.Notify != nil {
+		opts.NotifySlack = t.Notify.Slack
+		opts.NotifyWebhooks = t.Notify.Webhooks
+		opts.NotifyOn = cmp.Or(t.Notify.On, runner.NOTIFY_ON_FAILURE)
+	}
+	return opts
+}
+
+// validateTenant checks the options of the runs of a tenant, on load so that a bad tenant file is rejected
+// before any run. Its policies and templates sources are loaded
+func (s *server) validateTenant(opts runner.Options, t *tenant.Tenant) error {
+	check := tenantRunOptions(opts, t)
+	if _, err := s.watcher(SOURCE_POLICIES, check.PoliciesPath); err != nil {
+		return err
+	}
+	if _, err := s.watcher(SOURCE_TEMPLATES, check.TemplatesPath); err != nil {
+		return err
+	}
+	if t.GitHubApp != nil {
+		if _, err := os.Stat(t.GitHubApp.PrivateKeyPath); err != nil {
+			return err
+		}
+	}
+	return validateOptions(&check)
+}
+
+// watcher returns the watcher of a policies or templates source, loaded and watched on first use. A source
+// stays watched once loaded, the runs of a tenant reloaded with it use it again
+func (s *server) watcher(kind, spec string) (*source.Watcher, error) {
+	s.watchersMu.Lock()
+	defer s.watchersMu.Unlock()
+	key := kind + "|" + spec
+	if w, ok := s.watchers[key]; ok {
+		return w, nil
+	}
+	validate := validatePolicies
+	if kind == SOURCE_TEMPLATES {
+		validate = validateTemplates
+	}
+	w, err := source.NewWatcher(s.watchCtx, spec, validate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the %s: %w", kind, err)
+	}
+	s.watchers[key] = w
+	go w.Watch(s.watchCtx, s.pollInterval)
+	return w, nil
+}
+
+// closeWatchers removes the snapshots of the sources
+func (s *server) closeWatchers() {
+	s.watchersMu.Lock()
+	defer s.watchersMu.Unlock()
+	for _, w := range s.watchers {
+		w.Close()
+	}
+}
+
+// acquireSources points the policies and templates of the options of a run at the current snapshots of their
+// sources, and records their versions. release frees the snapshots once the run is done
+func (s *server) acquireSources(opts *runner.Options) (func(), error) {
+	policies, err := s.watcher(SOURCE_POLICIES, opts.PoliciesPath)
+	if err != nil {
+		return nil, err
+	}
+	templates, err := s.watcher(SOURCE_TEMPLATES, opts.TemplatesPath)
+	if err != nil {
+		return nil, err
+	}
+	policiesSnapshot, releasePolicies := policies.Acquire()
+	templatesSnapshot, releaseTemplates := templates.Acquire()
+	opts.PoliciesPath, opts.TemplatesPath = policiesSnapshot.Dir, templatesSnapshot.Dir
+	opts.Sources = &models.Sources{
+		Policies:  &models.SourceVersion{Source: policiesSnapshot.Source, Version: policiesSnapshot.Version},
+		Templates: &models.SourceVersion{Source: templatesSnapshot.Source, Version: templatesSnapshot.Version},
+	}
+	return func() {
+		releasePolicies()
+		releaseTemplates()
+	}, nil
+}
+
+// validatePolicies checks the compliance config and policy files of a new version of the policies
+func validatePolicies(dir string) error {
+	return policy.NewPolicyEvaluator(dir).LoadAndValidate()
+}
+
+// validateTemplates renders the sample report with a new version of the templates
+func validateTemplates(dir string) error {
+	sample, err := preview.SampleReport()
+	if err != nil {
+		return err
+	}
+	_, err = template.NewRenderer().RenderWithTemplates(dir, template.NewTemplateData(sample))
+	return err
+}
+
+// tenantOf returns the tenant of the repository of an event, nil without tenants. The events of the
+// repositories of no tenant are logged and not checked
+func (s *server) tenantOf(event webhook.Event) (*tenant.Tenant, bool) {
+	if s.tenants == nil {
+		return nil, true
+	}
+	t := s.tenants.Current().Match(event.Repo)
+	if t == nil {
+		logger.WithField("repo", event.Repo).WithField("delivery", event.Delivery).Warn("Repository of no tenant, event ignored")
+		return nil, false
+	}
+	return t, true
+}
+
+// notifier returns the dispatcher of the runs of a tenant with the notification settings of opts
+func (s *server) notifier(tenantName string, opts *runner.Options) (*notify.Dispatcher, error) {
+	key := fmt.Sprintf("%s|%t|%s|%s", tenantName, opts.NotifySlack, strings.Join(opts.NotifyWebhooks, ","), opts.NotifyOn)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if notifier, ok := s.notifiers[key]; ok {
+		return notifier, nil
+	}
+	notifier, err := opts.NewNotifier()
+	if err != nil {
+		return nil, err
+	}
+	s.notifiers[key] = notifier
+	return notifier, nil
+}
+
+// closeNotifiers sends the queued notifications of all the runs
+func (s *server) closeNotifiers() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, notifier := range s.notifiers {
+		closeNotifier(notifier)
+	}
+}
+
+// run checks the pull request of an event, its failure is logged: the comment and the outputs report it
+func (s *server) run(ctx context.Context, event webhook.Event) {
+	lg := logger.WithField("pr", event.Key()).WithField("event", event.Name).WithField("delivery", event.Delivery)
+	start := time.Now()
+	runOpts := serverRunOptions(s.opts, event)
+	// The tenant is matched again, the tenants may have been reloaded since the delivery
+	t, ok := s.tenantOf(event)
+	if !ok {
+		return
+	}
+	tenantName := ""
+	if t != nil {
+		tenantName = t.Name
+		lg = lg.WithField("tenant", t.Name)
+		runOpts = tenantRunOptions(runOpts, t)
+		token, err := t.Token(ctx)
+		if err != nil {
+			lg.WithField("error", err).Error("Failed to get the GitHub token of the tenant")
+			return
+		}
+		runOpts.GhToken = token
+	}
+	notifier, err := s.notifier(tenantName, &runOpts)
+	if err != nil {
+		lg.WithField("error", err).Error("Failed to create the notifier")
+		return
+	}
+	runOpts.Notifier = notifier
+	release, err := s.acquireSources(&runOpts)
+	if err != nil {
+		lg.WithField("error", err).Error("Failed to load the policies and templates")
+		return
+	}
+	defer release()
+	lg = lg.WithField("policies", runOpts.Sources.Policies.Version).WithField("templates", runOpts.Sources.Templates.Version)
+
+	lg.Info("Checking pull request")
+	if err := runPipeline(ctx, &runOpts); err != nil {
+		lg.WithField("error", err).WithField("durationMs", time.Since(start).Milliseconds()).Warn("Pull request check failed")
+		return
+	}
+	lg.WithField("durationMs", time.Since(start).Milliseconds()).Info("Pull request check passed")
+}
@@ -0,0 +1,139 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// AUTO_FIX_BRANCH_PREFIX names the branch of the follow-up PR of --auto-fix pr, followed by the PR number
+const AUTO_FIX_BRANCH_PREFIX = "gitops-kustomzchk/auto-fix/pr-"
+
+// autoFix applies the source fixes of the policies with autoFix enabled to the head checkout, and pushes them
+// to the head branch (commit mode) or to a follow-up PR against it (pr mode)
+// returns: the outcome for the report, nil if there is nothing to fix. Failing to push is reported, not returned.
+func (r *RunnerGitHub) autoFix(rs *models.BuildManifestResult, data *models.ReportData, checkoutRoot string) *models.AutoFix {
+	config := r.Evaluator.Config()
+	var fixes []sourceFix
+	for _, fix := range sourceFixesOf(rs, data, checkoutRoot) {
+		if config.Policies[fix.PolicyId].AutoFix {
+			fixes = append(fixes, fix)
+		}
+	}
+	if len(fixes) == 0 {
+		logger.Info("AutoFix: nothing to fix")
+		return nil
+	}
+	logger.WithField("count", len(fixes)).Info("AutoFix: starting...")
+
+	result := &models.AutoFix{Mode: r.options.AutoFix}
+	files, policyNames := r.applySourceFixes(fixes, checkoutRoot, result)
+	if len(result.Fixes) == 0 {
+		return nil
+	}
+
+	branch, force := r.prInfo.HeadRef, false
+	if result.Mode == models.AutoFixModePR {
+		branch, force = fmt.Sprintf("%s%d", AUTO_FIX_BRANCH_PREFIX, r.options.GhPrNumber), true
+	}
+	message := fmt.Sprintf("Apply gitops-kustomzchk fixes for %s", strings.Join(policyNames, ", "))
+	sha, err := r.ghclient.CommitAndPush(r.Context, checkoutRoot, files, message, branch, force)
+	if err != nil {
+		logger.WithField("error", err).Warn("AutoFix: failed to push the fixes")
+		result.Error = failure.Excerpt(err)
+		return result
+	}
+	result.Branch, result.Commit = branch, sha
+
+	if result.Mode == models.AutoFixModePR {
+		result.PullRequestURL, err = r.autoFixPullRequest(branch, policyNames)
+		if err != nil {
+			logger.WithField("error", err).Warn("AutoFix: failed to open the pull request")
+			result.Error = failure.Excerpt(err)
+		}
+	}
+	logger.WithField("branch", branch).WithField("commit", sha).Info("AutoFix: done.")
+	return result
+}
+
+// applySourceFixes rewrites the fixed lines of the checkout, a line with conflicting fixes (e.g. from two
+// overlays sharing a base) is left to the developers
+// returns: the changed files and the names of the fixed policies, the applied fixes are added to result
+func (r *RunnerGitHub) applySourceFixes(fixes []sourceFix, checkoutRoot string, result *models.AutoFix) ([]string, []string) {
+	replacements := make(map[string]map[int]string)
+	conflicts := make(map[string]map[int]bool)
+	for _, fix := range fixes {
+		if replacements[fix.Path] == nil {
+			replacements[fix.Path] = make(map[int]string)
+			conflicts[fix.Path] = make(map[int]bool)
+		}
+		if replacement, ok := replacements[fix.Path][fix.Line]; ok && replacement != fix.Replacement {
+			logger.WithField("file", fix.Path).WithField("line", fix.Line).Warn("AutoFix: conflicting fixes, line left unchanged")
+			conflicts[fix.Path][fix.Line] = true
+		}
+		replacements[fix.Path][fix.Line] = fix.Replacement
+	}
+
+	var files []string
+	for _, fix := range fixes {
+		if slices.Contains(files, fix.Path) || replacements[fix.Path] == nil {
+			continue
+		}
+		for line := range conflicts[fix.Path] {
+			delete(replacements[fix.Path], line)
+		}
+		if len(replacements[fix.Path]) == 0 {
+			continue
+		}
+		if err := rewriteLines(filepath.Join(checkoutRoot, filepath.FromSlash(fix.Path)), replacements[fix.Path]); err != nil {
+			logger.WithField("file", fix.Path).WithField("error", err).Warn("AutoFix: failed to apply the fixes of the file")
+			replacements[fix.Path] = nil
+			continue
+		}
+		files = append(files, fix.Path)
+	}
+
+	var policyNames []string
+	for _, fix := range fixes {
+		if !slices.Contains(files, fix.Path) || conflicts[fix.Path][fix.Line] {
+			continue
+		}
+		if !slices.ContainsFunc(result.Fixes, func(f models.AppliedFix) bool { return f.File == fix.Path && f.Line == fix.Line }) {
+			result.Fixes = append(result.Fixes, models.AppliedFix{PolicyId: fix.PolicyId, File: fix.Path, Line: fix.Line})
+		}
+		if !slices.Contains(policyNames, fix.PolicyName) {
+			policyNames = append(policyNames, fix.PolicyName)
+		}
+	}
+	return files, policyNames
+}
+
+func rewriteLines(path string, replacements map[int]string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fixed, err := kustomize.ReplaceLines(content, replacements)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, fixed, 0644)
+}
+
+// autoFixPullRequest returns the follow-up PR of the auto-fix branch, opened if needed
+func (r *RunnerGitHub) autoFixPullRequest(branch string, policyNames []string) (string, error) {
+	url, err := r.ghclient.FindPullRequestURL(r.Context, r.options.GhRepo, branch)
+	if err != nil || url != "" {
+		return url, err
+	}
+	title := fmt.Sprintf("Apply gitops-kustomzchk fixes to #%d", r.options.GhPrNumber)
+	body := fmt.Sprintf("Fixes suggested by the policies %s for #%d, merge this PR into `%s` to apply them.",
+		strings.Join(policyNames, ", "), r.options.GhPrNumber, r.prInfo.HeadRef)
+	return r.ghclient.CreatePullRequest(r.Context, r.options.GhRepo, branch, r.prInfo.HeadRef, title, body)
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// autoMergeBlockersOf returns why the report does not qualify the PR for auto-merge, none if it does:
+// the PR qualifies when every environment was checked without error, no blocking policy fails,
+// no high-risk resource changes, no review is escalated and the diffs sum up to at most AutoMergeMaxDiffLines;
+// with AutoMergeImageBumpsOnly every changed environment must also be a routine image bump
+func autoMergeBlockersOf(data *models.ReportData, opts *Options) []string {
+	var reasons []string
+	if n := len(data.Errors); n > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d part(s) of the check failed", n))
+	}
+	if n := len(data.SkippedOverlays); n > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d environment(s) not checked", n))
+	}
+
+	blocking, highRisk, lines, notBumps := 0, 0, 0, 0
+	for _, overlayKey := range reportOverlayKeys(data) {
+		envDiff := data.ManifestChanges[overlayKey]
+		blocking += data.PolicyEvaluation.EnvironmentSummary[overlayKey].PolicyCounts.BlockingFailedCount
+		highRisk += len(data.HighRiskChanges[overlayKey])
+		lines += envDiff.LineCount
+		if envDiff.LineCount > 0 && len(envDiff.ImageBumps) == 0 {
+			notBumps++
+		}
+	}
+	if blocking > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d blocking policy failure(s)", blocking))
+	}
+	if highRisk > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d high-risk change(s)", highRisk))
+	}
+	if n := len(data.ReviewerEscalations); n > 0 {
+		reasons = append(reasons, fmt.Sprintf("review escalated to %d team(s)", n))
+	}
+	if opts.AutoMergeMaxDiffLines > 0 && lines > opts.AutoMergeMaxDiffLines {
+		reasons = append(reasons, fmt.Sprintf("%d diff lines, above the limit of %d", lines, opts.AutoMergeMaxDiffLines))
+	}
+	if opts.AutoMergeImageBumpsOnly && notBumps > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d environment(s) changing more than container images", notBumps))
+	}
+	if fix := data.AutoFix; fix != nil && fix.Mode == models.AutoFixModeCommit && fix.Commit != "" {
+		reasons = append(reasons, "fixes were pushed to the PR, the next run checks them")
+	}
+	return reasons
+}
+
+// autoMerge applies the merge gate to the PR: auto-merge is enabled (or the label added) when the report
+// qualifies it, and withdrawn when it does not, so that a later commit making the PR risky stops the merge
+func (r *RunnerGitHub) autoMerge(data *models.ReportData) *models.AutoMerge {
+	result := &models.AutoMerge{Mode: r.options.AutoMerge}
+	result.Reasons = autoMergeBlockersOf(data, r.options)
+	result.Eligible = len(result.Reasons) == 0
+	logger.WithField("mode", result.Mode).WithField("reasons", result.Reasons).Info("AutoMerge: starting...")
+
+	var err error
+	switch result.Mode {
+	case models.AutoMergeModeEnable:
+		result.Method = r.options.AutoMergeMethod
+		switch {
+		case result.Eligible && !r.prInfo.AutoMerge:
+			err = r.ghclient.EnableAutoMerge(r.Context, r.prInfo.NodeID, result.Method, r.prInfo.HeadSHA)
+			result.Action = models.AutoMergeActionEnabled
+		case !result.Eligible && r.prInfo.AutoMerge:
+			err = r.ghclient.DisableAutoMerge(r.Context, r.prInfo.NodeID)
+			result.Action = models.AutoMergeActionDisabled
+		}
+	case models.AutoMergeModeLabel:
+		result.Label = r.options.AutoMergeLabel
+		labeled := slices.Contains(r.prInfo.Labels, result.Label)
+		switch {
+		case result.Eligible && !labeled:
+			err = r.ghclient.AddLabel(r.Context, r.options.GhRepo, r.options.GhPrNumber, result.Label)
+			result.Action = models.AutoMergeActionLabeled
+		case !result.Eligible && labeled:
+			err = r.ghclient.RemoveLabel(r.Context, r.options.GhRepo, r.options.GhPrNumber, result.Label)
+			result.Action = models.AutoMergeActionUnlabeled
+		}
+	}
+	if err != nil {
+		logger.WithField("error", err).Warn("AutoMerge: failed to update the PR")
+		result.Action = ""
+		result.Error = failure.Excerpt(err)
+		return result
+	}
+	logger.WithField("action", result.Action).Info("AutoMerge: done.")
+	return result
+}
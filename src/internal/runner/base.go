@@ -2,17 +2,26 @@ package runner
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"io/fs"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/encrypt"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitops"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/inventory"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/pathbuilder"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/report"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
 
@@ -33,6 +42,27 @@ type RunnerBase struct {
 	Differ    *diff.Differ
 	Evaluator *policy.PolicyEvaluator
 	Renderer  *template.Renderer
+	Expander  *gitops.Expander
+
+	// Classifier is created from the compliance config on Initialize
+	Classifier *diff.RiskClassifier
+	// ImageBumps recognizes routine image bumps, from the compliance config on Initialize (nil to disable)
+	ImageBumps *models.ImageBumpConfig
+	// Timings records the stage durations of the run, reported in ReportData.Timings
+	Timings *models.Timings
+	// ServiceMetadata is the service.yaml of the checked service, loaded on BuildManifests (nil if none)
+	ServiceMetadata *models.ServiceMetadata
+	// FS holds the manifests of both sides, the filesystem of Builder (nil for the disk)
+	FS fsys.FS
+	// ExportedManifests are the manifests written by --export-manifests, described in index.json
+	ExportedManifests []models.OutputFile
+	// Encryptor encrypts the output files with --encrypt-artifacts, from the compliance config on Initialize
+	Encryptor *encrypt.Encryptor
+
+	// checkouts of the pull request in the SCM modes, shared by the services of the run
+	checkouts *checkouts
+	// lease of the run on the tool comments with --run-lock, taken by the first service
+	lease *runLease
 
 	Instance RunnerInterface
 }
@@ -56,6 +86,11 @@ func NewRunnerBase(
 		Differ:    differ,
 		Evaluator: evaluator,
 		Renderer:  renderer,
+		Expander:  gitops.NewExpander(options.RenderGitOpsResources, options.ExecLimits()),
+		Timings:   newTimings(),
+	}
+	if builder != nil {
+		runner.FS = builder.FS
 	}
 	return runner, nil
 }
@@ -72,13 +107,79 @@ func (r *RunnerBase) Initialize() error {
 	// load and validate policy configuration
 	err := r.Evaluator.LoadAndValidate()
 	if err != nil {
-		return fmt.Errorf("failed to load policy config: %w", err)
+		return failure.PolicyEngine(fmt.Errorf("failed to load policy config: %w", err))
+	}
+
+	if r.Options.LintPolicies {
+		logger.Info("Initalize runner: Linting policies")
+		_, lintSpan := trace.StartSpan(r.Context, "LintPolicies")
+		err = r.lintPolicies()
+		lintSpan.End()
+		if err != nil {
+			return failure.PolicyEngine(err)
+		}
+	}
+
+	r.Classifier = diff.NewRiskClassifier(r.Evaluator.Config().RiskClassification)
+	r.ImageBumps = r.Evaluator.Config().ImageBumps
+	if r.Options.EncryptArtifacts != "" {
+		r.Encryptor, err = encrypt.New(r.Options.EncryptArtifacts, r.Evaluator.Config().ArtifactEncryption, r.Options.ExecLimits())
+		if err != nil {
+			return failure.PolicyEngine(fmt.Errorf("invalid --encrypt-artifacts: %w", err))
+		}
+	}
+
+	// A diff-only run has no policy to fetch data for nor library to verify
+	if !r.Options.NoPolicies {
+		logger.Info("Initalize runner: Evaluator: Fetching external data")
+		_, dataSpan := trace.StartSpan(r.Context, "FetchExternalData")
+		err = r.Evaluator.FetchExternalData(r.Context)
+		dataSpan.End()
+		if err != nil {
+			return failure.PolicyEngine(fmt.Errorf("failed to fetch external data: %w", err))
+		}
+
+		logger.Info("Initalize runner: Evaluator: Verifying policy libraries")
+		_, libSpan := trace.StartSpan(r.Context, "VerifyLibraries")
+		err = r.Evaluator.VerifyLibraries(r.Context)
+		libSpan.End()
+		if err != nil {
+			return failure.PolicyEngine(fmt.Errorf("failed to verify policy libraries: %w", err))
+		}
 	}
 
+	r.Evaluator.SetPolicyContext(models.PolicyContext{Service: r.Options.Service})
+
 	logger.Info("Initalize runner: done.")
 	return nil
 }
 
+// Close removes the external data fetched by Initialize
+func (r *RunnerBase) Close() error {
+	if r.Evaluator == nil {
+		return nil
+	}
+	return r.Evaluator.Close()
+}
+
+// lintPolicies runs the lint stage, warnings are logged and errors fail the run with their diagnostics
+func (r *RunnerBase) lintPolicies() error {
+	diagnostics, err := policy.Lint(r.Context, r.Options.PoliciesPath, policy.LintOptions{
+		Regal:      r.Options.LintRegal,
+		ExecLimits: r.Options.ExecLimits(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to lint policies: %w", err)
+	}
+	if policy.HasLintErrors(diagnostics) {
+		return fmt.Errorf("policy lint found errors:\n%s", policy.FormatLintDiagnostics(diagnostics))
+	}
+	for _, d := range diagnostics {
+		logger.Warnf("Policy lint: %s", d)
+	}
+	return nil
+}
+
 func (r *RunnerBase) BuildManifests(beforePath, afterPath string) (*models.BuildManifestResult, error) {
 	ctx, span := trace.StartSpan(r.Context, "BuildManifests")
 	defer span.End()
@@ -86,35 +187,218 @@ func (r *RunnerBase) BuildManifests(beforePath, afterPath string) (*models.Build
 	logger.Info("BuildManifests: starting...")
 
 	// Check if using dynamic paths or legacy mode
+	var rs *models.BuildManifestResult
+	var err error
 	if r.Options.UseDynamicPaths() {
-		return r.buildManifestsDynamic(ctx, beforePath, afterPath)
+		rs, err = r.buildManifestsDynamic(ctx, beforePath, afterPath)
+	} else {
+		rs, err = r.buildManifestsLegacy(ctx, beforePath, afterPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadServiceMetadata(beforePath, afterPath); err != nil {
+		return nil, err
+	}
+	if err := r.extractProvenance(ctx, rs); err != nil {
+		return nil, err
+	}
+	if err := r.expandGitOpsResources(ctx, rs); err != nil {
+		return nil, err
+	}
+	r.detectComponents(ctx, rs)
+	r.detectConfigChanges(ctx, rs)
+	return rs, nil
+}
+
+// extractProvenance moves the source annotations added by the provenance build mode out of the manifests,
+// so that neither diffs nor policies see them
+func (r *RunnerBase) extractProvenance(ctx context.Context, rs *models.BuildManifestResult) error {
+	if !r.Options.Provenance {
+		return nil
+	}
+	_, span := trace.StartSpan(ctx, "ExtractProvenance")
+	defer span.End()
+
+	for key, envResult := range rs.EnvManifestBuild {
+		if envResult.Skipped {
+			continue
+		}
+		beforeManifest, beforeOrigins, err := kustomize.ExtractProvenance(envResult.BeforeManifest)
+		if err != nil {
+			return fmt.Errorf("failed to extract provenance of %s (before): %w", key, err)
+		}
+		afterManifest, afterOrigins, err := kustomize.ExtractProvenance(envResult.AfterManifest)
+		if err != nil {
+			return fmt.Errorf("failed to extract provenance of %s (after): %w", key, err)
+		}
+		envResult.BeforeManifest = beforeManifest
+		envResult.AfterManifest = afterManifest
+		envResult.BeforeOrigins = beforeOrigins
+		envResult.AfterOrigins = afterOrigins
+		rs.EnvManifestBuild[key] = envResult
+	}
+	return nil
+}
+
+// detectComponents lists the kustomize components used by each overlay and whether they changed
+// Detection is best-effort: unreadable kustomizations are logged and yield no components
+func (r *RunnerBase) detectComponents(ctx context.Context, rs *models.BuildManifestResult) {
+	_, span := trace.StartSpan(ctx, "DetectComponents")
+	defer span.End()
+
+	for key, envResult := range rs.EnvManifestBuild {
+		if envResult.Skipped {
+			continue
+		}
+		components, err := kustomize.CompareComponents(r.FS, envResult.BeforeBuildPath, envResult.AfterBuildPath)
+		if err != nil {
+			logger.WithField("overlayKey", key).WithField("error", err).Warn("Failed to detect kustomize components")
+			continue
+		}
+		envResult.Components = components
+		rs.EnvManifestBuild[key] = envResult
+	}
+}
+
+// detectConfigChanges compares the images, replicas and patches of each overlay kustomization
+// Like detectComponents, it is best-effort
+func (r *RunnerBase) detectConfigChanges(ctx context.Context, rs *models.BuildManifestResult) {
+	_, span := trace.StartSpan(ctx, "DetectConfigChanges")
+	defer span.End()
+
+	for key, envResult := range rs.EnvManifestBuild {
+		if envResult.Skipped {
+			continue
+		}
+		changes, err := kustomize.CompareKustomizations(r.FS, envResult.BeforeBuildPath, envResult.AfterBuildPath)
+		if err != nil {
+			logger.WithField("overlayKey", key).WithField("error", err).Warn("Failed to compare kustomizations")
+			continue
+		}
+		envResult.ConfigChanges = changes
+		rs.EnvManifestBuild[key] = envResult
 	}
-	return r.buildManifestsLegacy(ctx, beforePath, afterPath)
+}
+
+// configChangesOf collects the configuration-level changes per overlay key for the report, nil if there are none
+func configChangesOf(rs *models.BuildManifestResult) map[string][]models.ConfigChange {
+	var results map[string][]models.ConfigChange
+	for key, envResult := range rs.EnvManifestBuild {
+		if len(envResult.ConfigChanges) == 0 {
+			continue
+		}
+		if results == nil {
+			results = make(map[string][]models.ConfigChange)
+		}
+		results[key] = envResult.ConfigChanges
+	}
+	return results
+}
+
+// componentsOf collects the components used per overlay key for the report, nil if none are used
+func componentsOf(rs *models.BuildManifestResult) map[string][]models.ComponentUsage {
+	var results map[string][]models.ComponentUsage
+	for key, envResult := range rs.EnvManifestBuild {
+		if len(envResult.Components) == 0 {
+			continue
+		}
+		if results == nil {
+			results = make(map[string][]models.ComponentUsage)
+		}
+		results[key] = envResult.Components
+	}
+	return results
+}
+
+// expandGitOpsResources detects (and optionally renders) HelmRelease/ApplicationSet in the built manifests
+// Both sides are expanded so that diffs stay consistent with what the policies evaluate
+func (r *RunnerBase) expandGitOpsResources(ctx context.Context, rs *models.BuildManifestResult) error {
+	ctx, span := trace.StartSpan(ctx, "ExpandGitOpsResources")
+	defer span.End()
+
+	for key, envResult := range rs.EnvManifestBuild {
+		if envResult.Skipped {
+			continue
+		}
+		beforeManifest, _, err := r.Expander.Expand(ctx, envResult.BeforeManifest)
+		if err != nil {
+			return fmt.Errorf("failed to expand GitOps resources of %s (before): %w", key, err)
+		}
+		afterManifest, gitopsResources, err := r.Expander.Expand(ctx, envResult.AfterManifest)
+		if err != nil {
+			return fmt.Errorf("failed to expand GitOps resources of %s (after): %w", key, err)
+		}
+		if len(gitopsResources) > 0 {
+			logger.WithField("overlayKey", key).WithField("count", len(gitopsResources)).Info("Found GitOps resources")
+		}
+		envResult.BeforeManifest = beforeManifest
+		envResult.AfterManifest = afterManifest
+		envResult.GitOpsResources = gitopsResources
+		rs.EnvManifestBuild[key] = envResult
+	}
+	return nil
+}
+
+// gitopsResourcesOf collects the GitOps resources per overlay key for the report, nil if none were found
+func gitopsResourcesOf(rs *models.BuildManifestResult) map[string][]models.GitOpsResource {
+	var results map[string][]models.GitOpsResource
+	for key, envResult := range rs.EnvManifestBuild {
+		if len(envResult.GitOpsResources) == 0 {
+			continue
+		}
+		if results == nil {
+			results = make(map[string][]models.GitOpsResource)
+		}
+		results[key] = envResult.GitOpsResources
+	}
+	return results
 }
 
 // buildManifestsLegacy handles the legacy --service + --environments mode
 func (r *RunnerBase) buildManifestsLegacy(ctx context.Context, beforePath, afterPath string) (*models.BuildManifestResult, error) {
+	envs, err := r.expandEnvironments(beforePath, afterPath)
+	if err != nil {
+		return nil, err
+	}
+	envs, skippedOverlays, err := r.Options.selectOverlays(envs)
+	if err != nil {
+		return nil, err
+	}
+	r.Options.Environments = envs // Report the concrete environments
+
 	results := make(map[string]models.BuildEnvManifestResult)
-	envs := r.Options.Environments
 	for _, env := range envs {
 		envCtx, envSpan := trace.StartSpan(ctx, fmt.Sprintf("BuildManifests.%s", env))
 
 		// Build before manifest
 		logger.WithField("env", env).WithField("beforePath", beforePath).Info("Building before manifest...")
+		buildStart := time.Now()
 		beforeManifest, beforeErr := r.Builder.Build(envCtx, beforePath, env)
+		recordDuration(r.Timings.BuildMs, env, buildStart)
 		beforeNotFound := beforeErr != nil && errors.Is(beforeErr, kustomize.ErrOverlayNotFound)
 		if beforeErr != nil && !beforeNotFound {
 			envSpan.End()
-			return nil, beforeErr
+			if !r.Options.ContinueOnError() {
+				return nil, beforeErr
+			}
+			results[env] = failedBuild(env, fmt.Errorf("failed to build the before manifest: %w", beforeErr))
+			continue
 		}
 
 		// Build after manifest
 		logger.WithField("env", env).WithField("afterPath", afterPath).Info("Building after manifest...")
+		buildStart = time.Now()
 		afterManifest, afterErr := r.Builder.Build(envCtx, afterPath, env)
+		recordDuration(r.Timings.BuildMs, env, buildStart)
 		afterNotFound := afterErr != nil && errors.Is(afterErr, kustomize.ErrOverlayNotFound)
 		if afterErr != nil && !afterNotFound {
 			envSpan.End()
-			return nil, afterErr
+			if !r.Options.ContinueOnError() {
+				return nil, afterErr
+			}
+			results[env] = failedBuild(env, fmt.Errorf("failed to build the after manifest: %w", afterErr))
+			continue
 		}
 
 		// Handle different scenarios
@@ -141,13 +425,20 @@ func (r *RunnerBase) buildManifestsLegacy(ctx context.Context, beforePath, after
 			afterManifest = []byte{} // Treat as empty manifest
 		}
 
-		results[env] = models.BuildEnvManifestResult{
+		buildEnvResult := models.BuildEnvManifestResult{
 			OverlayKey:     env,
 			Environment:    env,
 			BeforeManifest: beforeManifest,
 			AfterManifest:  afterManifest,
 			Skipped:        false,
 		}
+		if !beforeNotFound {
+			buildEnvResult.BeforeBuildPath = filepath.Join(beforePath, kustomize.KUSTOMIZE_OVERLAY_DIR_NAME, env)
+		}
+		if !afterNotFound {
+			buildEnvResult.AfterBuildPath = filepath.Join(afterPath, kustomize.KUSTOMIZE_OVERLAY_DIR_NAME, env)
+		}
+		results[env] = buildEnvResult
 		logger.WithField("env", env).WithField("beforeManifest", string(beforeManifest)).Debug("Built Manifest")
 		logger.WithField("env", env).WithField("afterManifest", string(afterManifest)).Debug("Built Manifest")
 
@@ -158,15 +449,46 @@ func (r *RunnerBase) buildManifestsLegacy(ctx context.Context, beforePath, after
 	return &models.BuildManifestResult{
 		EnvManifestBuild: results,
 		OverlayKeys:      envs, // Preserve the order from --environments flag
+		SkippedOverlays:  skippedOverlays,
 	}, nil
 }
 
+// expandEnvironments resolves glob/regex --environments values against the overlays of both sides
+func (r *RunnerBase) expandEnvironments(beforePath, afterPath string) ([]string, error) {
+	template := filepath.Join(kustomize.KUSTOMIZE_OVERLAY_DIR_NAME, "[ENV]")
+	pb := &pathbuilder.PathBuilder{Template: template, Variables: map[string][]string{"ENV": r.Options.Environments}, FS: r.FS}
+	expanded, err := pb.ExpandPatterns(filepath.Join(beforePath, template), filepath.Join(afterPath, template))
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand environments: %w", err)
+	}
+	return expanded.Variables["ENV"], nil
+}
+
 // buildManifestsDynamic handles the new --kustomize-build-path + --kustomize-build-values mode
 func (r *RunnerBase) buildManifestsDynamic(ctx context.Context, beforeRoot, afterRoot string) (*models.BuildManifestResult, error) {
+	r.Options.PathBuilder.FS = r.FS
+	pb, err := r.Options.PathBuilder.ExpandPatterns(
+		filepath.Join(beforeRoot, r.Options.PathBuilder.Template), filepath.Join(afterRoot, r.Options.PathBuilder.Template))
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand kustomize build values: %w", err)
+	}
+	r.Options.PathBuilder = pb
+
 	pathCombos, err := r.Options.PathBuilder.GenerateAllPaths()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate path combinations: %w", err)
 	}
+	comboKeys := make([]string, len(pathCombos))
+	for i, combo := range pathCombos {
+		comboKeys[i] = combo.OverlayKey
+	}
+	selectedKeys, skippedOverlays, err := r.Options.selectOverlays(comboKeys)
+	if err != nil {
+		return nil, err
+	}
+	pathCombos = slices.DeleteFunc(pathCombos, func(combo pathbuilder.PathCombination) bool {
+		return !slices.Contains(selectedKeys, combo.OverlayKey)
+	})
 
 	results := make(map[string]models.BuildEnvManifestResult)
 	overlayKeys := make([]string, 0, len(pathCombos)) // Preserve order
@@ -179,20 +501,38 @@ func (r *RunnerBase) buildManifestsDynamic(ctx context.Context, beforeRoot, afte
 
 		// Build before manifest
 		logger.WithField("overlayKey", combo.OverlayKey).WithField("beforePath", beforeFullPath).Info("Building before manifest...")
+		buildStart := time.Now()
 		beforeManifest, beforeErr := r.Builder.BuildAtFullPath(comboCtx, beforeFullPath)
+		recordDuration(r.Timings.BuildMs, combo.OverlayKey, buildStart)
 		beforeNotFound := beforeErr != nil && errors.Is(beforeErr, kustomize.ErrOverlayNotFound)
 		if beforeErr != nil && !beforeNotFound {
 			comboSpan.End()
-			return nil, beforeErr
+			if !r.Options.ContinueOnError() {
+				return nil, beforeErr
+			}
+			failed := failedBuild(combo.OverlayKey, fmt.Errorf("failed to build the before manifest: %w", beforeErr))
+			failed.FullBuildPath = combo.Path
+			results[combo.OverlayKey] = failed
+			overlayKeys = append(overlayKeys, combo.OverlayKey)
+			continue
 		}
 
 		// Build after manifest
 		logger.WithField("overlayKey", combo.OverlayKey).WithField("afterPath", afterFullPath).Info("Building after manifest...")
+		buildStart = time.Now()
 		afterManifest, afterErr := r.Builder.BuildAtFullPath(comboCtx, afterFullPath)
+		recordDuration(r.Timings.BuildMs, combo.OverlayKey, buildStart)
 		afterNotFound := afterErr != nil && errors.Is(afterErr, kustomize.ErrOverlayNotFound)
 		if afterErr != nil && !afterNotFound {
 			comboSpan.End()
-			return nil, afterErr
+			if !r.Options.ContinueOnError() {
+				return nil, afterErr
+			}
+			failed := failedBuild(combo.OverlayKey, fmt.Errorf("failed to build the after manifest: %w", afterErr))
+			failed.FullBuildPath = combo.Path
+			results[combo.OverlayKey] = failed
+			overlayKeys = append(overlayKeys, combo.OverlayKey)
+			continue
 		}
 
 		// Handle different scenarios
@@ -221,14 +561,22 @@ func (r *RunnerBase) buildManifestsDynamic(ctx context.Context, beforeRoot, afte
 			afterManifest = []byte{} // Treat as empty manifest
 		}
 
-		results[combo.OverlayKey] = models.BuildEnvManifestResult{
+		buildEnvResult := models.BuildEnvManifestResult{
 			OverlayKey:     combo.OverlayKey,
 			Environment:    combo.OverlayKey, // For backward compat
 			FullBuildPath:  combo.Path,
+			Variables:      combo.Values,
 			BeforeManifest: beforeManifest,
 			AfterManifest:  afterManifest,
 			Skipped:        false,
 		}
+		if !beforeNotFound {
+			buildEnvResult.BeforeBuildPath = beforeFullPath
+		}
+		if !afterNotFound {
+			buildEnvResult.AfterBuildPath = afterFullPath
+		}
+		results[combo.OverlayKey] = buildEnvResult
 		overlayKeys = append(overlayKeys, combo.OverlayKey) // Preserve order
 		logger.WithField("overlayKey", combo.OverlayKey).Debug("Built Manifest")
 
@@ -239,6 +587,7 @@ func (r *RunnerBase) buildManifestsDynamic(ctx context.Context, beforeRoot, afte
 	return &models.BuildManifestResult{
 		EnvManifestBuild: results,
 		OverlayKeys:      overlayKeys, // Preserve the order from path combinations
+		SkippedOverlays:  skippedOverlays,
 	}, nil
 }
 
@@ -251,7 +600,7 @@ func (r *RunnerBase) DiffManifests(result *models.BuildManifestResult) (map[stri
 	results := make(map[string]models.EnvironmentDiff)
 
 	for env, envResult := range result.EnvManifestBuild {
-		_, envSpan := trace.StartSpan(ctx, fmt.Sprintf("DiffManifests.%s", env))
+		envCtx, envSpan := trace.StartSpan(ctx, fmt.Sprintf("DiffManifests.%s", env))
 
 		// Skip diff if environment was skipped during build
 		if envResult.Skipped {
@@ -264,7 +613,9 @@ func (r *RunnerBase) DiffManifests(result *models.BuildManifestResult) (map[stri
 			continue
 		}
 
-		diffContent, err := r.Differ.Diff(envResult.BeforeManifest, envResult.AfterManifest)
+		diffStart := time.Now()
+		diffContent, err := r.Differ.DiffContext(envCtx, envResult.BeforeManifest, envResult.AfterManifest)
+		recordDuration(r.Timings.DiffMs, env, diffStart)
 		if err != nil {
 			logger.WithField("env", envResult.Environment).WithField("error", err).Error("Failed to diff manifests")
 			envSpan.End()
@@ -273,12 +624,18 @@ func (r *RunnerBase) DiffManifests(result *models.BuildManifestResult) (map[stri
 		logger.WithField("env", envResult.Environment).WithField("diffContent", diffContent).Debug("Diffed Manifest")
 
 		addedLines, deletedLines, totalLines := diff.CalcLineChangesFromDiffContent(diffContent)
+		resourceChanges, blastRadius, prunedResources, imageBumps := r.analyzeResourceChanges(envResult)
 		results[env] = models.EnvironmentDiff{
 			ContentType:      models.DiffContentTypeText,
 			LineCount:        totalLines,
 			AddedLineCount:   addedLines,
 			DeletedLineCount: deletedLines,
 			Content:          diffContent,
+			Lines:            diff.ParseUnifiedDiff(diffContent),
+			ResourceChanges:  resourceChanges,
+			BlastRadius:      blastRadius,
+			PrunedResources:  prunedResources,
+			ImageBumps:       imageBumps,
 		}
 
 		envSpan.End()
@@ -288,6 +645,137 @@ func (r *RunnerBase) DiffManifests(result *models.BuildManifestResult) (map[stri
 	return results, nil
 }
 
+// analyzeResourceChanges returns the classified resource-level changes of an overlay, their blast radius, the
+// removed resources and, if they are a routine image bump, its image updates
+// Analysis is best-effort: unparseable manifests are logged and yield no changes
+func (r *RunnerBase) analyzeResourceChanges(
+	envResult models.BuildEnvManifestResult,
+) ([]models.ResourceChange, models.BlastRadius, []models.PrunedResource, []models.ImageBump) {
+	if r.Classifier == nil {
+		r.Classifier = diff.NewRiskClassifier(nil)
+	}
+	changes, err := diff.ParseChanges(envResult.BeforeManifest, envResult.AfterManifest)
+	if err != nil {
+		logger.WithField("env", envResult.Environment).WithField("error", err).Warn("Failed to analyze resource changes")
+		return nil, models.BlastRadius{}, nil, nil
+	}
+	resourceChanges := r.Classifier.ResourceChanges(changes)
+	for i := range resourceChanges {
+		resourceChanges[i].Origin = envResult.Origin(resourceChanges[i].ID)
+	}
+	return resourceChanges, diff.EstimateBlastRadius(changes), diff.PrunedResources(changes), diff.ImageBumpsOf(changes, r.ImageBumps)
+}
+
+// linkPolicyViolations cross-links the policy violations with the resource changes of each overlay,
+// and maps violations (and their suggested fixes) to their source files (read from files) when provenance is known
+// Must be called before highRiskChangesOf so that the report carries the links everywhere
+func linkPolicyViolations(files fsys.FS, rs *models.BuildManifestResult, diffs map[string]models.EnvironmentDiff, policyEval *models.PolicyEvaluation) {
+	for key, matrix := range policyEval.PolicyMatrix {
+		if envDiff, ok := diffs[key]; ok {
+			diff.LinkViolations(key, &envDiff, &matrix)
+			diffs[key] = envDiff
+		}
+
+		envResult := rs.EnvManifestBuild[key]
+		if envResult.AfterOrigins == nil {
+			continue
+		}
+		var resources map[string]manifest.Resource // parsed on the first suggestion only
+		for _, policies := range [][]models.PolicyResult{
+			matrix.BlockingPolicies, matrix.WarningPolicies, matrix.RecommendPolicies,
+			matrix.OverriddenPolicies, matrix.NotInEffectPolicies,
+		} {
+			for p := range policies {
+				for v := range policies[p].Violations {
+					violation := &policies[p].Violations[v]
+					if violation.ResourceID == "" {
+						continue
+					}
+					violation.Origin = envResult.Origin(violation.ResourceID)
+					if violation.Origin == nil || violation.Suggestion == nil {
+						continue
+					}
+					if resources == nil {
+						resources = resourcesByID(envResult.AfterManifest)
+					}
+					if res, ok := resources[violation.ResourceID]; ok {
+						violation.Suggestion.Source = kustomize.SourceSuggestionOf(
+							files, envResult.AfterBuildPath, *violation.Origin, res, violation.Suggestion.Patch)
+					}
+				}
+			}
+		}
+	}
+}
+
+// resourcesByID parses a built manifest, best-effort: empty if it cannot be parsed
+func resourcesByID(mf []byte) map[string]manifest.Resource {
+	results := make(map[string]manifest.Resource)
+	resources, err := manifest.Parse(mf)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to parse manifest for suggestions")
+		return results
+	}
+	for _, res := range resources {
+		results[res.ID()] = res
+	}
+	return results
+}
+
+// highRiskChangesOf collects the high-risk resource changes per overlay key for the report, nil if none
+func highRiskChangesOf(diffs map[string]models.EnvironmentDiff) map[string][]models.ResourceChange {
+	var results map[string][]models.ResourceChange
+	for key, envDiff := range diffs {
+		highRisk := diff.HighRiskChanges(envDiff.ResourceChanges)
+		if len(highRisk) == 0 {
+			continue
+		}
+		if results == nil {
+			results = make(map[string][]models.ResourceChange)
+		}
+		results[key] = highRisk
+	}
+	return results
+}
+
+// destructiveChangesOf collects the removed resources failing the built-in destructive changes policy per overlay
+// key for the report, nil if none. They are confirmed when the policy is overridden
+func destructiveChangesOf(data *models.ReportData) *models.DestructiveChanges {
+	var result *models.DestructiveChanges
+	for _, key := range reportOverlayKeys(data) {
+		matrix := data.PolicyEvaluation.PolicyMatrix[key]
+		levels := []struct {
+			results    []models.PolicyResult
+			overridden bool
+		}{
+			{matrix.BlockingPolicies, false}, {matrix.WarningPolicies, false}, {matrix.RecommendPolicies, false},
+			{matrix.OverriddenPolicies, true},
+		}
+		for _, level := range levels {
+			for _, res := range level.results {
+				if res.PolicyId != policy.DESTRUCTIVE_CHANGES_POLICY_ID || len(res.Violations) == 0 {
+					continue
+				}
+				if result == nil {
+					result = &models.DestructiveChanges{Changes: make(map[string][]models.ResourceChange)}
+				}
+				result.OverrideCommand = res.OverrideCommand
+				result.Overridden = result.Overridden || level.overridden
+				for _, v := range res.Violations {
+					change := models.ResourceChange{ID: v.ResourceID, Action: models.ResourceChangeRemoved, Anchor: v.DiffAnchor}
+					for _, c := range data.ManifestChanges[key].ResourceChanges {
+						if c.ID == v.ResourceID {
+							change = c
+						}
+					}
+					result.Changes[key] = append(result.Changes[key], change)
+				}
+			}
+		}
+	}
+	return result
+}
+
 func (r *RunnerBase) EvaluatePolicies(mf *models.BuildManifestResult) (*models.PolicyEvaluateResult, error) {
 	ctx, span := trace.StartSpan(r.Context, "EvaluatePolicies")
 	defer span.End()
@@ -343,6 +831,12 @@ func (r *RunnerBase) Process() error {
 		return err
 	}
 	logger.WithField("results", rs).Debug("Built Manifests")
+	if err := r.exportInventory(rs); err != nil {
+		return err
+	}
+	if err := r.exportManifests(rs); err != nil {
+		return err
+	}
 
 	diffs, err := r.DiffManifests(rs)
 	if err != nil {
@@ -350,26 +844,49 @@ func (r *RunnerBase) Process() error {
 	}
 	logger.WithField("results", diffs).Debug("Diffed Manifests")
 
-	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(r.Context, *rs, []string{})
+	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(r.Context, *rs, nil)
 	if err != nil {
 		return err
 	}
 	logger.WithField("results", policyEval).Debug("Evaluated Policies")
 
+	linkPolicyViolations(r.FS, rs, diffs, policyEval)
 	reportData := models.ReportData{
+		SchemaVersion:    models.REPORT_SCHEMA_VERSION,
 		Service:          r.Options.Service,
+		ServiceMetadata:  r.ServiceMetadata,
 		Timestamp:        time.Now(),
 		BaseCommit:       "base",
 		HeadCommit:       "head",
 		Environments:     r.Options.Environments,
 		ManifestChanges:  diffs,
+		HighRiskChanges:  highRiskChangesOf(diffs),
 		PolicyEvaluation: *policyEval,
+		Timings:          r.timings(),
+		GitOpsResources:  gitopsResourcesOf(rs),
+		Components:       componentsOf(rs),
+		ConfigChanges:    configChangesOf(rs),
+		SkippedOverlays:  rs.SkippedOverlays,
+		Errors:           partialErrorsOf(rs, policyEval),
+		ExternalData:     r.Evaluator.ExternalDataRecords(),
+		Sources:          r.Options.Sources,
 	}
+	applyEnvironmentNames(&reportData, r.Evaluator.Config())
+	reportData.Timezone = r.Evaluator.Config().Timezone
+	reportData.Checklist = r.Evaluator.Checklist()
+	reportData.PolicyPacks = r.Evaluator.PolicyPacks()
+	reportData.DestructiveChanges = destructiveChangesOf(&reportData)
+	reportData.NextSteps = nextStepsOf(&reportData, r.Evaluator.Config())
+	reportData.ReviewerEscalations = reviewerEscalationsOf(&reportData, r.Evaluator.Config())
+	reportData.Profiles = profilesOf(&reportData, r.Evaluator.Config())
 
 	if err := r.Output(&reportData); err != nil {
 		return err
 	}
-	return nil
+	if err := partialFailureOf(reportData.Errors); err != nil {
+		return err
+	}
+	return enforceProfiles(&reportData, r.Evaluator.Config())
 }
 
 func (r *RunnerBase) Output(data *models.ReportData) error {
@@ -377,34 +894,265 @@ func (r *RunnerBase) Output(data *models.ReportData) error {
 	defer span.End()
 
 	logger.Info("Output: starting...")
-	if err := r.outputReportJson(data); err != nil {
+	if err := r.outputReport(data); err != nil {
+		return err
+	}
+	if err := r.outputPolicyCSV(data); err != nil {
+		return err
+	}
+	if err := r.outputPolicyJUnit(data); err != nil {
+		return err
+	}
+	if err := r.outputHTMLReport(data); err != nil {
+		return err
+	}
+	if err := r.outputMetrics(data); err != nil {
 		return err
 	}
 	logger.Info("Output: done.")
 	return nil
 }
 
-// Exporting report json file to output directory if enabled
-func (r *RunnerBase) outputReportJson(data *models.ReportData) error {
-	if !r.Options.EnableExportReport {
-		logger.Info("OutputJson: option was disabled")
+// outputPolicyCSV writes the policy matrix to policies.csv in the output directory if enabled
+func (r *RunnerBase) outputPolicyCSV(data *models.ReportData) error {
+	if !r.Options.EnableExportCSV {
+		return nil
+	}
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	encoded, err := report.PolicyMatrixCSV(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode policy matrix csv: %w", err)
+	}
+	filePath := filepath.Join(r.Options.OutputDir, "policies.csv")
+	if err := perm.WriteFile(filePath, encoded); err != nil {
+		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write policy matrix csv to file")
+		return err
+	}
+	logger.WithField("filePath", filePath).Info("Written policy matrix csv to file")
+	return nil
+}
+
+// outputPolicyJUnit writes the policy matrix to junit.xml in the output directory if enabled
+func (r *RunnerBase) outputPolicyJUnit(data *models.ReportData) error {
+	if !r.Options.EnableExportJUnit {
 		return nil
 	}
-	logger.Info("OutputJson: starting...")
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	encoded, err := report.PolicyMatrixJUnit(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode policy matrix junit: %w", err)
+	}
+	filePath := filepath.Join(r.Options.OutputDir, report.JUNIT_FILE_NAME)
+	if err := perm.WriteFile(filePath, encoded); err != nil {
+		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write policy matrix junit to file")
+		return err
+	}
+	logger.WithField("filePath", filePath).Info("Written policy matrix junit to file")
+	return nil
+}
 
-	if err := os.MkdirAll(r.Options.OutputDir, 0755); err != nil {
+// outputHTMLReport writes the standalone HTML report to report.html in the output directory if enabled
+func (r *RunnerBase) outputHTMLReport(data *models.ReportData) error {
+	if !r.Options.EnableExportHTML {
+		return nil
+	}
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	resultsJson, err := json.Marshal(data)
+	rendered, err := r.Renderer.RenderHTML(template.NewTemplateData(data))
 	if err != nil {
+		return failure.Render(err)
+	}
+	filePath := filepath.Join(r.Options.OutputDir, template.HTML_REPORT_FILE_NAME)
+	if err := perm.WriteFile(filePath, []byte(rendered)); err != nil {
+		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write html report to file")
 		return err
 	}
-	filePath := filepath.Join(r.Options.OutputDir, "report.json")
-	if err := os.WriteFile(filePath, resultsJson, 0644); err != nil {
-		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write report data to file")
+	logger.WithField("filePath", filePath).Info("Written html report to file")
+	return nil
+}
+
+// outputMetrics writes the metrics of the run to the node_exporter textfile collector directory if enabled
+func (r *RunnerBase) outputMetrics(data *models.ReportData) error {
+	if r.Options.MetricsTextfileDir == "" {
+		return nil
+	}
+	filePath, err := report.WriteMetrics(r.Options.MetricsTextfileDir, data)
+	if err != nil {
+		logger.WithField("dir", r.Options.MetricsTextfileDir).WithField("error", err).Error("Failed to write metrics")
 		return err
 	}
-	logger.WithField("filePath", filePath).Info("Written report data to file")
+	logger.WithField("filePath", filePath).Info("Written metrics to file")
+	return nil
+}
+
+// exportInventory writes the resource inventory of the after manifests of the built overlays to
+// inventory.<format> in the output directory, for each format of --export-inventory
+func (r *RunnerBase) exportInventory(rs *models.BuildManifestResult) error {
+	if len(r.Options.ExportInventory) == 0 {
+		return nil
+	}
+	logger.Info("ExportInventory: starting...")
+
+	items := []models.InventoryItem{}
+	for _, overlayKey := range rs.OverlayKeys {
+		envResult, ok := rs.EnvManifestBuild[overlayKey]
+		if !ok || envResult.Skipped {
+			continue
+		}
+		overlayItems, err := inventory.Of(overlayKey, envResult.AfterManifest, r.Options.InventoryLabels)
+		if err != nil {
+			return err
+		}
+		items = append(items, overlayItems...)
+	}
+
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	for _, format := range r.Options.ExportInventory {
+		content, err := inventory.Encode(items, format, r.Options.InventoryLabels)
+		if err != nil {
+			return err
+		}
+		filePath := filepath.Join(r.Options.OutputDir, "inventory."+format)
+		if err := perm.WriteFile(filePath, content); err != nil {
+			return fmt.Errorf("failed to write inventory: %w", err)
+		}
+		logger.WithField("filePath", filePath).WithField("resources", len(items)).Info("Written inventory to file")
+	}
+	return nil
+}
+
+// exportManifests writes the before/after manifests of the built overlays to manifests/<overlay key>/ in the
+// output directory, the exact inputs of the evaluation for downstream jobs
+func (r *RunnerBase) exportManifests(rs *models.BuildManifestResult) error {
+	if !r.Options.ExportManifests {
+		return nil
+	}
+	logger.Info("ExportManifests: starting...")
+
+	r.ExportedManifests = nil
+	for _, overlayKey := range rs.OverlayKeys {
+		envResult, ok := rs.EnvManifestBuild[overlayKey]
+		if !ok || envResult.Skipped {
+			continue
+		}
+		sides := []struct {
+			name     string
+			manifest []byte
+		}{{models.ManifestSideBefore, envResult.BeforeManifest}, {models.ManifestSideAfter, envResult.AfterManifest}}
+		for _, side := range sides {
+			file, err := report.WriteManifest(r.Options.OutputDir, overlayKey, side.name, side.manifest, r.Options.ExportManifestsGzip)
+			if err != nil {
+				return err
+			}
+			r.ExportedManifests = append(r.ExportedManifests, file)
+		}
+	}
+	logger.WithField("files", len(r.ExportedManifests)).Info("Written manifests to files")
+	return nil
+}
+
+// encryptArtifacts replaces the reports, diffs, inventories and manifests of the output directory by their
+// encrypted versions with --encrypt-artifacts, before they are indexed
+func (r *RunnerBase) encryptArtifacts() error {
+	if r.Encryptor == nil {
+		return nil
+	}
+	logger.Info("EncryptArtifacts: starting...")
+
+	var paths []string
+	err := filepath.WalkDir(r.Options.OutputDir, func(filePath string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && filePath == r.Options.OutputDir {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(r.Options.OutputDir, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if encrypt.ToolOf(rel) == "" && rel != report.INDEX_FILE_NAME && report.OutputKindOf(rel) != models.OutputKindOther {
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list the artifacts to encrypt: %w", err)
+	}
+
+	encrypted := map[string]string{}
+	for _, rel := range paths {
+		encryptedPath, err := r.Encryptor.EncryptFile(r.Context, filepath.Join(r.Options.OutputDir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		encryptedRel, err := filepath.Rel(r.Options.OutputDir, encryptedPath)
+		if err != nil {
+			return err
+		}
+		encrypted[rel] = filepath.ToSlash(encryptedRel)
+	}
+	for i, file := range r.ExportedManifests {
+		if path, ok := encrypted[file.Path]; ok {
+			r.ExportedManifests[i].Path = path
+		}
+	}
+	logger.WithField("files", len(paths)).WithField("tool", r.Encryptor.Tool).Info("Encrypted artifacts")
+	return nil
+}
+
+// outputIndex writes index.json describing the files of the output directory, last of the outputs.
+// Written whenever the run exports report or manifest files
+func (r *RunnerBase) outputIndex(data *models.ReportData) error {
+	if !r.Options.EnableExportReport && !r.Options.ExportManifests {
+		return nil
+	}
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	filePath, err := report.WriteIndex(r.Options.OutputDir, data, r.ExportedManifests)
+	if err != nil {
+		logger.WithField("error", err).Error("Failed to write output index")
+		return err
+	}
+	logger.WithField("filePath", filePath).Info("Written output index to file")
+	return nil
+}
+
+// Exporting the report to the output directory if enabled, one report.<format> file per --report-format
+func (r *RunnerBase) outputReport(data *models.ReportData) error {
+	if !r.Options.EnableExportReport {
+		logger.Info("OutputReport: option was disabled")
+		return nil
+	}
+	logger.Info("OutputReport: starting...")
+
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	for _, format := range r.Options.ReportFormats {
+		encoded, err := report.Encode(data, format, r.Options.ReportPretty)
+		if err != nil {
+			return err
+		}
+		filePath := filepath.Join(r.Options.OutputDir, "report."+format)
+		if err := perm.WriteFile(filePath, encoded); err != nil {
+			logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write report data to file")
+			return err
+		}
+		logger.WithField("filePath", filePath).Info("Written report data to file")
+	}
 	return nil
 }
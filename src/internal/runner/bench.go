@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/bench"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+)
+
+// BENCH_STAGE_* are the measured stages of a bench run
+const (
+	BENCH_STAGE_BUILD    = "build"
+	BENCH_STAGE_DIFF     = "diff"
+	BENCH_STAGE_EVALUATE = "evaluate"
+)
+
+// benchOverlay is a service overlay of the bench corpus
+type benchOverlay struct {
+	ServicePath string
+	Environment string
+}
+
+// BenchReport is the result of a bench run, exported as bench.json
+type BenchReport struct {
+	Corpus          string             `json:"corpus"`
+	Overlays        int                `json:"overlays"`
+	Iterations      int                `json:"iterations"`
+	KustomizeEngine string             `json:"kustomizeEngine"`
+	DiffEngine      string             `json:"diffEngine"`
+	PolicyEngine    string             `json:"policyEngine"`
+	BuildArgs       map[string]string  `json:"buildArgs,omitempty"`
+	Stages          []bench.StageStats `json:"stages"`
+}
+
+// Bench builds, diffs and evaluates every overlay of the corpus (<corpus>/<service>/environments/<env>)
+// the given number of times, then prints the timing and memory statistics of each stage.
+// The diff stage compares each manifest against an empty one, so it measures a whole-manifest diff.
+func (r *RunnerLocal) Bench(corpus string, iterations int) error {
+	overlays, err := discoverBenchOverlays(corpus)
+	if err != nil {
+		return err
+	}
+	logger.WithField("corpus", corpus).WithField("overlays", len(overlays)).WithField("iterations", iterations).Info("Bench: starting...")
+
+	ctx := r.Context
+	recorder := bench.NewRecorder()
+	for i := 0; i < iterations; i++ {
+		for _, overlay := range overlays {
+			name := filepath.Base(overlay.ServicePath) + "/" + overlay.Environment
+			var manifest []byte
+			if err := recorder.Measure(BENCH_STAGE_BUILD, func() error {
+				var err error
+				manifest, err = r.Builder.Build(ctx, overlay.ServicePath, overlay.Environment)
+				return err
+			}); err != nil {
+				return failure.Build(fmt.Errorf("failed to build %s: %w", name, err))
+			}
+			if err := recorder.Measure(BENCH_STAGE_DIFF, func() error {
+				_, err := r.Differ.DiffContext(ctx, nil, manifest)
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to diff %s: %w", name, err)
+			}
+			if err := recorder.Measure(BENCH_STAGE_EVALUATE, func() error {
+				_, err := r.Evaluator.EvaluateViolations(ctx, manifest)
+				return err
+			}); err != nil {
+				return failure.PolicyEngine(fmt.Errorf("failed to evaluate %s: %w", name, err))
+			}
+		}
+	}
+
+	report := &BenchReport{
+		Corpus:          corpus,
+		Overlays:        len(overlays),
+		Iterations:      iterations,
+		KustomizeEngine: r.Options.KustomizeEngine,
+		DiffEngine:      r.Options.DiffEngine,
+		PolicyEngine:    r.Options.PolicyEngine,
+		BuildArgs:       r.Options.BuildArgs,
+		Stages:          recorder.Stats(),
+	}
+	fmt.Printf("Bench of %d overlay(s) x %d iteration(s), kustomize engine %s, diff engine %s, policy engine %s\n\n",
+		report.Overlays, report.Iterations, report.KustomizeEngine, report.DiffEngine, report.PolicyEngine)
+	fmt.Print(bench.Format(report.Stages))
+	return r.outputBenchJson(report)
+}
+
+// discoverBenchOverlays lists the overlays of the corpus services, sorted by service then environment
+func discoverBenchOverlays(corpus string) ([]benchOverlay, error) {
+	services, err := os.ReadDir(corpus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read corpus %s: %w", corpus, err)
+	}
+	var overlays []benchOverlay
+	for _, service := range services {
+		if !service.IsDir() {
+			continue
+		}
+		servicePath := filepath.Join(corpus, service.Name())
+		envs, err := os.ReadDir(filepath.Join(servicePath, "environments"))
+		if err != nil {
+			logger.WithField("service", service.Name()).Debug("Bench: skipping directory without environments")
+			continue
+		}
+		for _, env := range envs {
+			if env.IsDir() {
+				overlays = append(overlays, benchOverlay{ServicePath: servicePath, Environment: env.Name()})
+			}
+		}
+	}
+	if len(overlays) == 0 {
+		return nil, fmt.Errorf("no overlays found in corpus %s, expected <service>/environments/<env> directories", corpus)
+	}
+	return overlays, nil
+}
+
+// Exporting bench json file to output directory if enabled
+func (r *RunnerLocal) outputBenchJson(report *BenchReport) error {
+	if !r.Options.EnableExportReport {
+		return nil
+	}
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	benchJson, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	filePath := filepath.Join(r.Options.OutputDir, "bench.json")
+	if err := perm.WriteFile(filePath, benchJson); err != nil {
+		return fmt.Errorf("failed to write bench report to %s: %w", filePath, err)
+	}
+	logger.WithField("filePath", filePath).Info("Written bench report to file")
+	return nil
+}
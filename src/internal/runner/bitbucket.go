@@ -0,0 +1,341 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/bitbucket"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+)
+
+const (
+	// The diffs of Bitbucket comments are kept as short as on GitHub to stay readable
+	BB_COMMENT_MAX_DIFF_LENGTH = GH_COMMENT_MAX_DIFF_LENGTH
+)
+
+var (
+	bitbucketCommentMaxDiffLength = BB_COMMENT_MAX_DIFF_LENGTH
+)
+
+// RunnerBitbucket checks a pull request of a Bitbucket Cloud repository, its outcome is published as a comment of the
+// pull request. The GitHub-only features (check runs, auto-fix, auto-merge, reviewer requests, suggestions) are not supported
+type RunnerBitbucket struct {
+	RunnerBase
+
+	options  *Options
+	bbclient *bitbucket.Client
+
+	prInfo *models.PullRequest
+}
+
+func NewRunnerBitbucket(
+	ctx context.Context,
+	options *Options,
+	bbclient *bitbucket.Client,
+	builder *kustomize.Builder,
+	differ *diff.Differ,
+	evaluator *policy.PolicyEvaluator,
+	renderer *template.Renderer,
+) (*RunnerBitbucket, error) {
+	if bbclient == nil {
+		return nil, fmt.Errorf("Bitbucket client is not initialized")
+	}
+	baseRunner, err := NewRunnerBase(ctx, options, builder, differ, evaluator, renderer)
+	if err != nil {
+		return nil, err
+	}
+	runner := &RunnerBitbucket{
+		RunnerBase: *baseRunner,
+		bbclient:   bbclient,
+		options:    options,
+	}
+	return runner, nil
+}
+
+func (r *RunnerBitbucket) Initialize() error {
+	lg := logger.WithField("func", "RunnerBitbucket.Initialize()")
+	lg.Info("Initializing runner: starting...")
+
+	pr, err := r.bbclient.GetPR(r.Context, r.options.BbRepo, r.options.BbPrId)
+	if err != nil {
+		return outputErrorReport(nil, fmt.Errorf("failed to fetch pull request info: %w", authError(err)), r.outputReport)
+	}
+	r.prInfo = pr
+
+	if maxDiffLengthStr := os.Getenv("BITBUCKET_COMMENT_MAX_DIFF_LENGTH"); maxDiffLengthStr != "" {
+		if _, err := fmt.Sscanf(maxDiffLengthStr, "%d", &bitbucketCommentMaxDiffLength); err != nil {
+			lg.WithField("BITBUCKET_COMMENT_MAX_DIFF_LENGTH", maxDiffLengthStr).WithField("error", err).Warn("BITBUCKET_COMMENT_MAX_DIFF_LENGTH env was set but failed to parse into int. Will use default value of 10,000.")
+			bitbucketCommentMaxDiffLength = BB_COMMENT_MAX_DIFF_LENGTH
+		}
+	}
+	lg.Info("Initializing runner: done.")
+	if err := r.RunnerBase.Initialize(); err != nil {
+		return outputErrorReport(nil, err, r.outputReport)
+	}
+	r.Evaluator.SetPolicyContext(models.PolicyContext{
+		Service: r.options.Service,
+		PullRequest: &models.PullRequestContext{
+			Repo:    r.options.BbRepo,
+			Number:  r.prInfo.Number,
+			Title:   r.prInfo.Title,
+			BaseRef: r.prInfo.BaseRef,
+			HeadRef: r.prInfo.HeadRef,
+		},
+	})
+	return nil
+}
+
+func (r *RunnerBitbucket) BuildManifests(beforePath, afterPath string) (*models.BuildManifestResult, error) {
+	return r.RunnerBase.BuildManifests(beforePath, afterPath)
+}
+
+func (r *RunnerBitbucket) DiffManifests(result *models.BuildManifestResult) (map[string]models.EnvironmentDiff, error) {
+	diffs, err := r.RunnerBase.DiffManifests(result)
+	if err != nil {
+		return nil, err
+	}
+	return r.offloadLongDiffs(result, diffs, bitbucketCommentMaxDiffLength, r.options.BbPrId, bitbucket.GetPipelineURL)
+}
+
+func (r *RunnerBitbucket) Process() error {
+	defer r.removeCheckouts()
+	return r.processServices(r.processService)
+}
+
+// processService checks the service of the run, its outputs report the failure of a part
+func (r *RunnerBitbucket) processService() error {
+	if !r.acquireRunLease(r.toolCommentAPI()) {
+		logger.Warn("Superseded by a newer run of the service, skipping")
+		return nil
+	}
+	reportData, err := r.process()
+	if errors.Is(err, errRunSuperseded) {
+		logger.Warn("Superseded by a newer run of the service, its outputs are not published")
+		return nil
+	}
+	r.notifyOutcome(fmt.Sprintf("%s#%d", r.options.BbRepo, r.options.BbPrId), reportData, err)
+	if err != nil {
+		return outputErrorReport(reportData, err, r.outputReport)
+	}
+	// The outputs report the failed parts, the run still fails
+	if err := partialFailureOf(reportData.Errors); err != nil {
+		return err
+	}
+	return enforceProfiles(reportData, r.Evaluator.Config())
+}
+
+// process checks out, builds, diffs and evaluates the manifests then publishes the outputs,
+// on failure it returns the report data if it was built
+func (r *RunnerBitbucket) process() (*models.ReportData, error) {
+	ctx, span := trace.StartSpan(r.Context, "Process")
+	defer span.End()
+
+	logger.Info("Process: starting...")
+	checkedOutBeforePath, checkedOutAfterPath, err := r.checkoutOnce(func() (string, string, error) {
+		return r.checkout(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := r.BuildManifests(r.options.scmBuildPath(checkedOutBeforePath), r.options.scmBuildPath(checkedOutAfterPath))
+	if err != nil {
+		return nil, failure.Build(err)
+	}
+	logger.WithField("results", rs).Debug("Built Manifests")
+	if err := r.exportInventory(rs); err != nil {
+		return nil, err
+	}
+	if err := r.exportManifests(rs); err != nil {
+		return nil, err
+	}
+
+	diffs, err := r.DiffManifests(rs)
+	if err != nil {
+		return nil, err
+	}
+	logger.WithField("results", diffs).Debug("Diffed Manifests")
+
+	bbComments, err := r.bbclient.GetComments(r.Context, r.options.BbRepo, r.options.BbPrId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", authError(err))
+	}
+	r.readChecklist(bbComments, r.commentSignature())
+
+	_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
+	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, bbComments)
+	evalSpan.End()
+	if err != nil {
+		return nil, failure.PolicyEngine(err)
+	}
+	logger.WithField("results", policyEval).Debug("Evaluated Policies")
+
+	reportData := r.pullRequestReportData(rs, diffs, policyEval, r.prInfo)
+	if err := r.Output(&reportData); err != nil {
+		return &reportData, err
+	}
+	return &reportData, nil
+}
+
+// checkout checks out the base and head commits of the pull request, for all the services of the run
+func (r *RunnerBitbucket) checkout(ctx context.Context) (string, string, error) {
+	checkoutPath := r.options.scmCheckoutPath()
+
+	logger.WithField("repo", r.options.BbRepo).WithField("branch", r.prInfo.BaseRef).Debug("Process: Calling CheckoutAtPath for base commit")
+	checkoutStart := time.Now()
+	checkoutBaseCtx, checkoutBaseSpan := trace.StartSpan(ctx, "GitCheckout.Base")
+	checkedOutBeforePath, err := r.bbclient.CheckoutAtPath(
+		checkoutBaseCtx, r.options.BbRepo, r.prInfo.BaseRef, checkoutPath, r.options.checkoutOptions())
+	checkoutBaseSpan.End()
+	if err != nil {
+		return "", "", failure.Checkout(fmt.Errorf("failed to checkout base commit: %w", err))
+	}
+
+	logger.WithField("repo", r.options.BbRepo).WithField("headRef", r.prInfo.HeadRef).Info("Checking out manifests")
+	checkoutHeadCtx, checkoutHeadSpan := trace.StartSpan(ctx, "GitCheckout.Head")
+	checkedOutAfterPath, err := r.bbclient.CheckoutAtPath(
+		checkoutHeadCtx, r.options.BbRepo, r.prInfo.HeadRef, checkoutPath, r.options.checkoutOptions())
+	checkoutHeadSpan.End()
+	if err != nil {
+		_ = fsys.OrOS(r.FS).RemoveAll(checkedOutBeforePath)
+		return "", "", failure.Checkout(fmt.Errorf("failed to checkout head commit: %w", err))
+	}
+	r.Timings.CheckoutMs = msSince(checkoutStart)
+	return checkedOutBeforePath, checkedOutAfterPath, nil
+}
+
+// commentSignature returns the marker of the tool comment of the service, Bitbucket renders no HTML comment
+func (r *RunnerBitbucket) commentSignature() string {
+	return strings.ReplaceAll(template.ToolCommentSignatureMarkdown, template.ToolCommentServiceToken, r.options.serviceIdentifier())
+}
+
+// toolCommentAPI returns the API of the tool comment of the service, for its lease and the stale reports
+func (r *RunnerBitbucket) toolCommentAPI() toolCommentAPI {
+	signature := r.commentSignature()
+	return toolCommentAPI{
+		destination: NOTIFY_BITBUCKET_API,
+		markdown:    true,
+		signature:   signature,
+		find: func() (*models.Comment, error) {
+			return r.bbclient.FindToolComment(r.Context, r.options.BbRepo, r.options.BbPrId, signature)
+		},
+		create: func(body string) error {
+			_, err := r.bbclient.CreateComment(r.Context, r.options.BbRepo, r.options.BbPrId, body)
+			return err
+		},
+		update: func(commentID int64, body string) error {
+			return r.bbclient.UpdateComment(r.Context, r.options.BbRepo, r.options.BbPrId, commentID, body)
+		},
+		headCommit: func() (string, error) {
+			pr, err := r.bbclient.GetPR(r.Context, r.options.BbRepo, r.options.BbPrId)
+			if err != nil {
+				return "", err
+			}
+			return pr.HeadSHA, nil
+		},
+	}
+}
+
+func (r *RunnerBitbucket) Output(data *models.ReportData) error {
+	_, span := trace.StartSpan(r.Context, "Output")
+	defer span.End()
+
+	logger.Info("Output: starting...")
+	if !r.holdsRunLease(r.toolCommentAPI()) {
+		return errRunSuperseded
+	}
+	if err := r.checkStaleResult(r.toolCommentAPI(), data); err != nil {
+		return err
+	}
+	// The comment goes first for the report files to have its render and publish timings
+	if err := r.outputBitbucketComment(data); err != nil {
+		return err
+	}
+	if err := r.outputReport(data); err != nil {
+		return err
+	}
+	if err := r.outputPolicyCSV(data); err != nil {
+		return err
+	}
+	if err := r.outputPolicyJUnit(data); err != nil {
+		return err
+	}
+	if err := r.outputHTMLReport(data); err != nil {
+		return err
+	}
+	if err := r.outputMetrics(data); err != nil {
+		return err
+	}
+	if err := r.encryptArtifacts(); err != nil {
+		return err
+	}
+	if err := r.outputIndex(data); err != nil {
+		return err
+	}
+	logger.Info("Output: done.")
+	return nil
+}
+
+// Post comment to Bitbucket PR, updating the comment of the previous run of the service
+func (r *RunnerBitbucket) outputBitbucketComment(data *models.ReportData) error {
+	logger.Info("OutputBitbucketComment: starting...")
+
+	if r.Options.NoManifestContentInComment {
+		logger.Info("OutputBitbucketComment: confidential mode, redacting manifest content")
+		data = RedactManifestContent(data)
+	}
+
+	renderStart := time.Now()
+	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, template.NewTemplateData(data))
+	r.Timings.RenderMs = msSince(renderStart)
+	if err != nil {
+		logger.WithField("error", err).Error("Failed to render markdown template")
+		return failure.Render(err)
+	}
+	logger.WithField("renderedMarkdown", renderedMarkdown).Debug("Rendered markdown")
+
+	commentSignature := r.commentSignature()
+	finalComment := r.toolCommentBody(commentSignature, renderedMarkdown, data, true)
+
+	publishStart := time.Now()
+	defer func() { r.Timings.PublishMs = msSince(publishStart) }()
+
+	existingComment, err := r.bbclient.FindToolComment(r.Context, r.options.BbRepo, r.options.BbPrId, commentSignature)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to find existing comment, will create new one")
+	}
+
+	if existingComment != nil {
+		err := r.publish(NOTIFY_BITBUCKET_API, func() error {
+			return r.bbclient.UpdateComment(r.Context, r.options.BbRepo, r.options.BbPrId, existingComment.ID, finalComment)
+		})
+		if err != nil {
+			logger.WithField("error", err).Error("Failed to update existing comment")
+			return failure.SCMPublish(authError(err))
+		}
+		logger.Info("Updated existing Bitbucket comment")
+	} else {
+		err := r.publish(NOTIFY_BITBUCKET_API, func() error {
+			_, err := r.bbclient.CreateComment(r.Context, r.options.BbRepo, r.options.BbPrId, finalComment)
+			return err
+		})
+		if err != nil {
+			logger.WithField("error", err).Error("Failed to create new comment")
+			return failure.SCMPublish(authError(err))
+		}
+		logger.Info("Created new Bitbucket comment")
+	}
+
+	return nil
+}
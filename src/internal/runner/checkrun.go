@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+)
+
+// CHECK_RUN_NAME_PREFIX prefixes the name of the check run of a service, e.g. "gitops-kustomzchk/my-app"
+const CHECK_RUN_NAME_PREFIX = "gitops-kustomzchk/"
+
+// MAX_CHECK_ANNOTATION_MESSAGE_LENGTH bounds the message of an annotation, GitHub rejects those over 64 KB
+const MAX_CHECK_ANNOTATION_MESSAGE_LENGTH = 4096
+
+// overlayFilesOf returns the kustomization file of each built overlay, relative to the repository root, for
+// the check run annotations. Overlays without one are left out
+func overlayFilesOf(files fsys.FS, rs *models.BuildManifestResult, checkoutRoot string) map[string]string {
+	overlayFiles := make(map[string]string)
+	for key, build := range rs.EnvManifestBuild {
+		if build.AfterBuildPath == "" {
+			continue
+		}
+		for _, name := range kustomize.KUSTOMIZE_FILE_NAMES {
+			path := filepath.Join(build.AfterBuildPath, name)
+			if !fsys.Exists(files, path) {
+				continue
+			}
+			if rel, err := filepath.Rel(checkoutRoot, path); err == nil && !strings.HasPrefix(rel, "..") {
+				overlayFiles[key] = filepath.ToSlash(rel)
+			}
+			break
+		}
+	}
+	return overlayFiles
+}
+
+// outputCheckRun publishes the outcome of the run as a check run of the head commit, failing on blocking
+// failures and incomplete runs so that branch protection can require it. No-op without --gh-check-run
+func (r *RunnerGitHub) outputCheckRun(data *models.ReportData) error {
+	if !r.options.GhCheckRun {
+		return nil
+	}
+	logger.Info("OutputCheckRun: starting...")
+	confidential := r.Options.NoManifestContentInComment
+	if confidential {
+		logger.Info("OutputCheckRun: confidential mode, no annotation is added")
+		data = RedactManifestContent(data)
+	}
+	run := checkRunOf(template.NewTemplateData(data), r.overlayFiles, confidential)
+	run.Name = CHECK_RUN_NAME_PREFIX + r.options.serviceIdentifier()
+	run.HeadSHA = r.prInfo.HeadSHA
+	if r.runId != 0 {
+		if url, err := github.GetWorkflowRunUrl(r.options.GhRepo, r.runId); err == nil {
+			run.DetailsURL = url
+		}
+	}
+	if err := r.ghclient.CreateCheckRun(r.Context, r.options.GhRepo, run); err != nil {
+		logger.WithField("error", err).Error("Failed to create check run")
+		return failure.SCMPublish(authError(err))
+	}
+	logger.WithField("name", run.Name).WithField("conclusion", run.Conclusion).Info("Created check run")
+	return nil
+}
+
+// checkRunOf composes the check run of a report: its conclusion, the status title of the report as title (shown in
+// the checks list of the PR), the policy counts as summary and an annotation on the overlay kustomization file per
+// failing policy and overlay. Confidential check runs have no annotation
+func checkRunOf(td *template.TemplateData, overlayFiles map[string]string, confidential bool) models.CheckRun {
+	run := models.CheckRun{Conclusion: models.CheckConclusionSuccess, Title: td.StatusTitle}
+	if td.OverallStatus == template.STATUS_FAIL || td.OverallStatus == template.STATUS_ERROR {
+		run.Conclusion = models.CheckConclusionFailure
+	}
+	run.Summary = fmt.Sprintf("**%s**: %s\n\n%s", td.OverallStatus, td.PerEnvStatusLine, td.SummaryTable)
+	if len(td.Errors) > 0 {
+		run.Summary += fmt.Sprintf("\n\n%d part(s) of this check failed, their results are missing or incomplete.", len(td.Errors))
+	}
+	if confidential {
+		return run
+	}
+
+	for _, overlayKey := range reportOverlayKeys(td.ReportData) {
+		path, ok := overlayFiles[overlayKey]
+		if !ok {
+			continue
+		}
+		matrix := td.PolicyEvaluation.PolicyMatrix[overlayKey]
+		for _, level := range []struct {
+			policies   []models.PolicyResult
+			annotation string
+		}{
+			{matrix.BlockingPolicies, models.CheckAnnotationFailure},
+			{matrix.WarningPolicies, models.CheckAnnotationWarning},
+			{matrix.RecommendPolicies, models.CheckAnnotationNotice},
+		} {
+			for _, policy := range level.policies {
+				if policy.IsPassing {
+					continue
+				}
+				message := strings.Join(policy.FailMessages, "\n")
+				if len(message) > MAX_CHECK_ANNOTATION_MESSAGE_LENGTH {
+					message = strings.ToValidUTF8(message[:MAX_CHECK_ANNOTATION_MESSAGE_LENGTH], "") + "…"
+				}
+				run.Annotations = append(run.Annotations, models.CheckAnnotation{
+					Path:    path,
+					Line:    1,
+					Level:   level.annotation,
+					Title:   fmt.Sprintf("%s (%s)", policy.PolicyName, td.DisplayName(overlayKey)),
+					Message: message,
+				})
+			}
+		}
+	}
+	return run
+}
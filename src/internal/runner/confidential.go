@@ -0,0 +1,181 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const REDACTED_MESSAGE = "(redacted: see the protected output)"
+
+// RedactManifestContent returns a copy of the report data safe to publish in SCM comments when
+// --no-manifest-content-in-comment is set: diffs, resource names and policy messages (which quote
+// manifest values) are removed, only counts, kinds and policy names remain
+// The original data is left untouched so the protected output dir still gets the full content
+func RedactManifestContent(data *models.ReportData) *models.ReportData {
+	redacted := *data
+
+	redacted.ManifestChanges = make(map[string]models.EnvironmentDiff, len(data.ManifestChanges))
+	for key, envDiff := range data.ManifestChanges {
+		// the artifact URL only points to the protected output, it is kept as Content
+		if envDiff.ContentType != models.DiffContentTypeGHArtifact {
+			envDiff.Content = ""
+		}
+		envDiff.ContentType = models.DiffContentTypeRedacted
+		envDiff.ContentGHFilePath = nil
+		envDiff.Lines = nil
+		envDiff.ResourceChanges = redactResourceChanges(envDiff.ResourceChanges)
+		if envDiff.ImageBumps != nil {
+			bumps := make([]models.ImageBump, len(envDiff.ImageBumps))
+			for i, bump := range envDiff.ImageBumps {
+				kind, _, _ := strings.Cut(bump.ResourceID, "/")
+				bumps[i] = models.ImageBump{ResourceID: kind}
+			}
+			envDiff.ImageBumps = bumps
+		}
+		envDiff.BlastRadius = models.BlastRadius{
+			RolloutWorkloads:    redactResourceIDs(envDiff.BlastRadius.RolloutWorkloads),
+			AvailabilityChanges: redactResourceIDs(envDiff.BlastRadius.AvailabilityChanges),
+			TrafficChanges:      redactResourceIDs(envDiff.BlastRadius.TrafficChanges),
+		}
+		if envDiff.PrunedResources != nil {
+			pruned := make([]models.PrunedResource, len(envDiff.PrunedResources))
+			for i, res := range envDiff.PrunedResources {
+				kind, _, _ := strings.Cut(res.ID, "/")
+				pruned[i] = models.PrunedResource{ID: kind, PruneDisabledBy: res.PruneDisabledBy}
+			}
+			envDiff.PrunedResources = pruned
+		}
+		redacted.ManifestChanges[key] = envDiff
+	}
+
+	if data.HighRiskChanges != nil {
+		redacted.HighRiskChanges = make(map[string][]models.ResourceChange, len(data.HighRiskChanges))
+		for key, changes := range data.HighRiskChanges {
+			redacted.HighRiskChanges[key] = redactResourceChanges(changes)
+		}
+	}
+
+	if data.DestructiveChanges != nil {
+		destructive := *data.DestructiveChanges
+		destructive.Changes = make(map[string][]models.ResourceChange, len(data.DestructiveChanges.Changes))
+		for key, changes := range data.DestructiveChanges.Changes {
+			destructive.Changes[key] = redactResourceChanges(changes)
+		}
+		redacted.DestructiveChanges = &destructive
+	}
+
+	if data.GitOpsResources != nil {
+		redacted.GitOpsResources = make(map[string][]models.GitOpsResource, len(data.GitOpsResources))
+		for key, resources := range data.GitOpsResources {
+			results := make([]models.GitOpsResource, len(resources))
+			for i, res := range resources {
+				results[i] = models.GitOpsResource{
+					Kind:                  res.Kind,
+					Rendered:              res.Rendered,
+					RenderedResourceCount: res.RenderedResourceCount,
+				}
+				if res.RenderError != "" {
+					results[i].RenderError = REDACTED_MESSAGE
+				}
+			}
+			redacted.GitOpsResources[key] = results
+		}
+	}
+
+	if data.ConfigChanges != nil {
+		redacted.ConfigChanges = make(map[string][]models.ConfigChange, len(data.ConfigChanges))
+		for key, changes := range data.ConfigChanges {
+			results := make([]models.ConfigChange, len(changes))
+			for i, change := range changes {
+				results[i] = models.ConfigChange{Field: change.Field, Action: change.Action}
+			}
+			redacted.ConfigChanges[key] = results
+		}
+	}
+
+	if data.Errors != nil {
+		// build and evaluation errors may quote manifest content
+		redacted.Errors = make([]models.RunError, len(data.Errors))
+		for i, runErr := range data.Errors {
+			runErr.Message = REDACTED_MESSAGE
+			redacted.Errors[i] = runErr
+		}
+	}
+
+	if data.ReviewerEscalations != nil {
+		redacted.ReviewerEscalations = make([]models.ReviewerEscalation, len(data.ReviewerEscalations))
+		for i, escalation := range data.ReviewerEscalations {
+			reasons := make([]models.EscalationReason, len(escalation.Reasons))
+			for j, reason := range escalation.Reasons {
+				if reason.ResourceID != "" {
+					reason.ResourceID, _, _ = strings.Cut(reason.ResourceID, "/")
+				}
+				reasons[j] = reason
+			}
+			escalation.Reasons = reasons
+			redacted.ReviewerEscalations[i] = escalation
+		}
+	}
+
+	redacted.PolicyEvaluation.PolicyMatrix = make(map[string]models.PolicyMatrix, len(data.PolicyEvaluation.PolicyMatrix))
+	for key, matrix := range data.PolicyEvaluation.PolicyMatrix {
+		redacted.PolicyEvaluation.PolicyMatrix[key] = models.PolicyMatrix{
+			BlockingPolicies:    redactPolicyResults(matrix.BlockingPolicies),
+			WarningPolicies:     redactPolicyResults(matrix.WarningPolicies),
+			RecommendPolicies:   redactPolicyResults(matrix.RecommendPolicies),
+			OverriddenPolicies:  redactPolicyResults(matrix.OverriddenPolicies),
+			NotInEffectPolicies: redactPolicyResults(matrix.NotInEffectPolicies),
+		}
+	}
+
+	return &redacted
+}
+
+// redactResourceChanges keeps the kind, action and classification of each change, dropping its name and namespace
+func redactResourceChanges(changes []models.ResourceChange) []models.ResourceChange {
+	if changes == nil {
+		return nil
+	}
+	results := make([]models.ResourceChange, len(changes))
+	for i, change := range changes {
+		results[i] = models.ResourceChange{
+			ID:               change.Kind,
+			APIVersion:       change.APIVersion,
+			Kind:             change.Kind,
+			Action:           change.Action,
+			Categories:       change.Categories,
+			HighRisk:         change.HighRisk,
+			ViolatedPolicies: change.ViolatedPolicies,
+		}
+	}
+	return results
+}
+
+// redactResourceIDs replaces "Kind/namespace/name" IDs with their kind
+func redactResourceIDs(ids []string) []string {
+	if ids == nil {
+		return nil
+	}
+	results := make([]string, len(ids))
+	for i, id := range ids {
+		results[i], _, _ = strings.Cut(id, "/")
+	}
+	return results
+}
+
+// redactPolicyResults keeps the policy names and outcomes, replacing the fail messages
+func redactPolicyResults(policies []models.PolicyResult) []models.PolicyResult {
+	results := make([]models.PolicyResult, len(policies))
+	for i, policy := range policies {
+		policy.Violations = nil
+		if len(policy.FailMessages) > 0 {
+			policy.FailMessages = []string{REDACTED_MESSAGE}
+		}
+		if policy.EvalError != "" {
+			policy.EvalError = REDACTED_MESSAGE
+		}
+		results[i] = policy
+	}
+	return results
+}
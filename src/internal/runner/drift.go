@@ -0,0 +1,174 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+)
+
+// ErrDrift is returned by Drift with failOnDrift when the environments differ
+var ErrDrift = errors.New("the environments have drifted")
+
+// Drift builds the after manifests like Process, then prints the configuration drift of the service between two
+// of its environments (images behind, missing resources) instead of the diff of the changes. from and to are
+// overlay keys, or environment names when a single overlay has it
+func (r *RunnerLocal) Drift(from, to string, failOnDrift bool) error {
+	ctx, span := trace.StartSpan(r.Context, "Drift")
+	defer span.End()
+
+	logger.WithField("from", from).WithField("to", to).Info("Drift: starting...")
+
+	rs, err := r.buildLocalManifests(ctx)
+	if err != nil {
+		return failure.Build(err)
+	}
+	fromManifest, err := overlayManifestOf(rs, from)
+	if err != nil {
+		return err
+	}
+	toManifest, err := overlayManifestOf(rs, to)
+	if err != nil {
+		return err
+	}
+	report, err := diff.EnvironmentDrift(fromManifest.AfterManifest, toManifest.AfterManifest)
+	if err != nil {
+		return fmt.Errorf("failed to compare %s and %s: %w", from, to, err)
+	}
+	report.From, report.To = fromManifest.OverlayKey, toManifest.OverlayKey
+
+	fmt.Print(formatDrift(report))
+	if err := r.outputDrift(report); err != nil {
+		return err
+	}
+	if failOnDrift && report.HasDrift() {
+		return ErrDrift
+	}
+	return nil
+}
+
+// overlayManifestOf returns the built overlay of an overlay key, or of the only overlay of an environment
+func overlayManifestOf(rs *models.BuildManifestResult, name string) (*models.BuildEnvManifestResult, error) {
+	if result, ok := rs.EnvManifestBuild[name]; ok {
+		return checkDriftOverlay(name, result)
+	}
+	var found []models.BuildEnvManifestResult
+	for _, key := range rs.OverlayKeys {
+		if result := rs.EnvManifestBuild[key]; result.Environment == name {
+			found = append(found, result)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no overlay %s, built overlays: %s", name, strings.Join(rs.OverlayKeys, ", "))
+	case 1:
+		return checkDriftOverlay(name, found[0])
+	default:
+		return nil, fmt.Errorf("environment %s has %d overlays, use an overlay key: %s", name, len(found), strings.Join(rs.OverlayKeys, ", "))
+	}
+}
+
+func checkDriftOverlay(name string, result models.BuildEnvManifestResult) (*models.BuildEnvManifestResult, error) {
+	switch {
+	case result.BuildError != "":
+		return nil, failure.Build(fmt.Errorf("failed to build %s: %s", name, result.BuildError))
+	case result.Skipped:
+		return nil, fmt.Errorf("overlay %s not found: %s", name, result.SkipReason)
+	}
+	return &result, nil
+}
+
+// formatDrift renders the drift summary for the terminal
+func formatDrift(report *models.EnvironmentDriftReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Drift of %s from %s\n", report.To, report.From)
+	if !report.HasDrift() {
+		sb.WriteString("No drift.\n")
+		return sb.String()
+	}
+	if len(report.Images) > 0 {
+		fmt.Fprintf(&sb, "\nImages (%d):\n", len(report.Images))
+		for _, image := range report.Images {
+			status := "differs"
+			if image.Behind {
+				status = "behind"
+			}
+			fmt.Fprintf(&sb, "  - %s [%s]: %s %s (%s has %s)\n", image.ResourceID, image.Container, image.To, status, report.From, image.From)
+		}
+	}
+	for _, group := range []struct {
+		title string
+		ids   []string
+	}{
+		{"Missing in " + report.To, report.MissingResources},
+		{"Only in " + report.To, report.ExtraResources},
+	} {
+		if len(group.ids) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n%s (%d):\n", group.title, len(group.ids))
+		for _, id := range group.ids {
+			fmt.Fprintf(&sb, "  - %s\n", id)
+		}
+	}
+	return sb.String()
+}
+
+// formatDriftMarkdown renders the drift summary for a pull request comment, e.g. of a promotion PR
+func formatDriftMarkdown(report *models.EnvironmentDriftReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### 🔀 Drift of `%s` from `%s`\n\n", report.To, report.From)
+	if !report.HasDrift() {
+		sb.WriteString("No drift.\n")
+		return sb.String()
+	}
+	if len(report.Images) > 0 {
+		fmt.Fprintf(&sb, "| Resource | Container | `%s` | `%s` | |\n|---|---|---|---|---|\n", report.From, report.To)
+		for _, image := range report.Images {
+			status := ""
+			if image.Behind {
+				status = "⚠️ behind"
+			}
+			fmt.Fprintf(&sb, "| `%s` | `%s` | `%s` | `%s` | %s |\n", image.ResourceID, image.Container, image.From, image.To, status)
+		}
+		sb.WriteString("\n")
+	}
+	if len(report.MissingResources) > 0 {
+		fmt.Fprintf(&sb, "**Missing in `%s`**: `%s`\n\n", report.To, strings.Join(report.MissingResources, "`, `"))
+	}
+	if len(report.ExtraResources) > 0 {
+		fmt.Fprintf(&sb, "**Only in `%s`**: `%s`\n", report.To, strings.Join(report.ExtraResources, "`, `"))
+	}
+	return sb.String()
+}
+
+// Exporting drift json and markdown files to output directory if enabled
+func (r *RunnerLocal) outputDrift(report *models.EnvironmentDriftReport) error {
+	if !r.Options.EnableExportReport {
+		return nil
+	}
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	driftJson, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	filePath := filepath.Join(r.Options.OutputDir, "drift.json")
+	if err := perm.WriteFile(filePath, driftJson); err != nil {
+		return fmt.Errorf("failed to write drift report to %s: %w", filePath, err)
+	}
+	mdPath := filepath.Join(r.Options.OutputDir, "drift.md")
+	if err := perm.WriteFile(mdPath, []byte(formatDriftMarkdown(report))); err != nil {
+		return fmt.Errorf("failed to write drift report to %s: %w", mdPath, err)
+	}
+	logger.WithField("filePath", filePath).WithField("markdownPath", mdPath).Info("Written drift report to files")
+	return nil
+}
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/bitbucket"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitlab"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// runErrorOf converts err to its entry of the report errors section
+func runErrorOf(err error) models.RunError {
+	entry := models.RunError{Message: err.Error()}
+	if categorized, ok := failure.As(err); ok {
+		entry.Category = string(categorized.Category)
+		entry.Hint = categorized.Hint()
+	}
+	return entry
+}
+
+// outputErrorReport logs err and records it in the errors section of report.json, on top of the
+// partial report if one was built, then returns err
+func outputErrorReport(data *models.ReportData, err error, outputJson func(*models.ReportData) error) error {
+	entry := runErrorOf(err)
+	logger.WithField("category", entry.Category).WithField("hint", entry.Hint).WithField("error", err).Error("Run failed")
+
+	if data == nil {
+		data = &models.ReportData{SchemaVersion: models.REPORT_SCHEMA_VERSION, Timestamp: time.Now()}
+	}
+	data.Errors = append(data.Errors, entry)
+	if outErr := outputJson(data); outErr != nil {
+		logger.WithField("error", outErr).Warn("Failed to write the run errors to the report")
+	}
+	return err
+}
+
+// authError categorizes GitHub, GitLab and Bitbucket API credential rejections as AuthErrors, other errors are
+// returned as is
+func authError(err error) error {
+	if github.IsAuthError(err) || gitlab.IsAuthError(err) || bitbucket.IsAuthError(err) {
+		return failure.Auth(err)
+	}
+	return err
+}
+
+// failedBuild is the result of an overlay that failed to build when the run continues past it (--on-error=continue),
+// it is skipped by the diff and policy evaluation and reported with an excerpt of the error
+func failedBuild(overlayKey string, err error) models.BuildEnvManifestResult {
+	logger.WithField("overlayKey", overlayKey).WithField("error", err).Error("Overlay failed to build, continuing with the others")
+	reason := "build failed"
+	if errors.Is(err, kustomize.ErrOutputTooLarge) {
+		reason = kustomize.ErrOutputTooLarge.Error()
+	}
+	return models.BuildEnvManifestResult{
+		OverlayKey:  overlayKey,
+		Environment: overlayKey,
+		Skipped:     true,
+		SkipReason:  reason,
+		BuildError:  failure.Excerpt(err),
+	}
+}
+
+// partialErrorsOf lists the overlays that failed to build and the policies that failed to evaluate, in overlay order
+func partialErrorsOf(rs *models.BuildManifestResult, policyEval *models.PolicyEvaluation) []models.RunError {
+	var results []models.RunError
+	for _, overlayKey := range rs.OverlayKeys {
+		if msg := rs.EnvManifestBuild[overlayKey].BuildError; msg != "" {
+			results = append(results, models.RunError{
+				Category:   string(failure.CATEGORY_BUILD),
+				Message:    msg,
+				Hint:       failure.Hints[failure.CATEGORY_BUILD],
+				OverlayKey: overlayKey,
+			})
+		}
+	}
+	for _, overlayKey := range rs.OverlayKeys {
+		matrix := policyEval.PolicyMatrix[overlayKey]
+		for _, policies := range [][]models.PolicyResult{matrix.BlockingPolicies, matrix.WarningPolicies,
+			matrix.RecommendPolicies, matrix.OverriddenPolicies, matrix.NotInEffectPolicies} {
+			for _, policy := range policies {
+				if policy.EvalError == "" {
+					continue
+				}
+				results = append(results, models.RunError{
+					Category:   string(failure.CATEGORY_POLICY_ENGINE),
+					Message:    policy.EvalError,
+					Hint:       failure.Hints[failure.CATEGORY_POLICY_ENGINE],
+					OverlayKey: overlayKey,
+					PolicyId:   policy.PolicyId,
+				})
+			}
+		}
+	}
+	return results
+}
+
+// partialFailureOf returns the error of a run that reported failed parts, nil if none failed
+// It has the category of the first failed part
+func partialFailureOf(errs []models.RunError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	parts := make([]string, len(errs))
+	for i, e := range errs {
+		parts[i] = e.OverlayKey
+		if e.PolicyId != "" {
+			parts[i] += " (" + e.PolicyId + ")"
+		}
+	}
+	err := fmt.Errorf("%d part(s) of the run failed: %s", len(errs), strings.Join(parts, ", "))
+	return failure.New(failure.Category(errs[0].Category), err)
+}
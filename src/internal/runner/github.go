@@ -2,14 +2,15 @@ package runner
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
@@ -32,11 +33,14 @@ type RunnerGitHub struct {
 	RunnerBase
 
 	options  *Options
-	ghclient *github.Client
+	ghclient github.GitHubClient
 
 	runId    int
 	prInfo   *models.PullRequest
 	comments []*models.Comment
+
+	overlayFiles map[string]string // kustomization file of each overlay, relative to the repository root
+	checked      bool              // the lease and head were checked before the pull request changes
 }
 
 func NewRunnerGitHub(
@@ -68,7 +72,12 @@ func (r *RunnerGitHub) Initialize() error {
 	lg.Info("Initializing runner: starting...")
 
 	if err := r.fetchAndSetPullRequestInfo(); err != nil {
-		return fmt.Errorf("failed to fetch pull request info: %w", err)
+		return outputErrorReport(nil, fmt.Errorf("failed to fetch pull request info: %w", authError(err)), r.outputReport)
+	}
+	if r.options.AutoDetectServices {
+		if err := r.detectServices(); err != nil {
+			return outputErrorReport(nil, err, r.outputReport)
+		}
 	}
 	r.runId = 0
 	runIdStr := os.Getenv("GITHUB_RUN_ID")
@@ -87,7 +96,20 @@ func (r *RunnerGitHub) Initialize() error {
 		}
 	}
 	lg.Info("Initializing runner: done.")
-	return r.RunnerBase.Initialize()
+	if err := r.RunnerBase.Initialize(); err != nil {
+		return outputErrorReport(nil, err, r.outputReport)
+	}
+	r.Evaluator.SetPolicyContext(models.PolicyContext{
+		Service: r.options.Service,
+		PullRequest: &models.PullRequestContext{
+			Repo:    r.options.GhRepo,
+			Number:  r.prInfo.Number,
+			Title:   r.prInfo.Title,
+			BaseRef: r.prInfo.BaseRef,
+			HeadRef: r.prInfo.HeadRef,
+		},
+	})
+	return nil
 }
 
 // Fetch and set pull request data into struct from GitHub
@@ -151,244 +173,273 @@ func (r *RunnerGitHub) DiffManifests(result *models.BuildManifestResult) (map[st
 	if err != nil {
 		return nil, err
 	}
+	return r.offloadLongDiffs(result, diffs, githubCommentMaxDiffLength, r.options.GhPrNumber, func() (string, error) {
+		return github.GetWorkflowRunUrl(r.options.GhRepo, r.runId)
+	})
+}
 
-	for env, envDiff := range diffs {
-		if len(envDiff.Content) > githubCommentMaxDiffLength {
-			logger.WithFields(map[string]interface{}{
-				"env":        env,
-				"diffLength": len(envDiff.Content),
-				"maxLength":  githubCommentMaxDiffLength,
-			}).Info("Diff is too long, uploading as artifact")
-
-			// Create filename for this diff
-			// Use overlay key directly (which is env in this context)
-			serviceIdentifier := r.options.Service
-			if serviceIdentifier == "" {
-				serviceIdentifier = "dynamic"
-			}
-
-			uncleanFileName := fmt.Sprintf("diff-pr%d-%s-%s.txt", r.options.GhPrNumber, env, serviceIdentifier)
-			filename := strings.ReplaceAll(uncleanFileName, "/", "-")
-
-			// Save diff content to file
-			outputDir := r.Options.OutputDir
-			if err := os.MkdirAll(outputDir, 0755); err != nil {
-				return nil, fmt.Errorf("failed to create output directory: %w", err)
-			}
-
-			filepath := filepath.Join(outputDir, filename)
-			if err := os.WriteFile(filepath, []byte(envDiff.Content), 0644); err != nil {
-				return nil, fmt.Errorf("failed to write diff file: %w", err)
-			}
-
-			// Upload file as artifact and get URL
-			artifactURL, err := github.GetWorkflowRunUrl(r.options.GhRepo, r.runId)
-			if err != nil {
-				logger.WithField("error", err).Error("Failed to get workflow run URL, leaving content as text")
-				artifactURL = ""
-			}
+func (r *RunnerGitHub) Process() error {
+	defer r.removeCheckouts()
+	return r.processServices(r.processService)
+}
 
-			// Update the diff result to point to the artifact URL
-			envDiff.ContentGHFilePath = &filepath
-			envDiff.ContentType = models.DiffContentTypeGHArtifact
-			envDiff.Content = artifactURL
-			diffs[env] = envDiff
-
-			logger.WithFields(map[string]interface{}{
-				"env":         env,
-				"filename":    filename,
-				"artifactURL": artifactURL,
-			}).Info("Diff uploaded as artifact successfully")
-		}
+// processService checks the service of the run, its outputs report the failure of a part
+func (r *RunnerGitHub) processService() error {
+	if !r.acquireRunLease(r.toolCommentAPI()) {
+		logger.Warn("Superseded by a newer run of the service, skipping")
+		return nil
 	}
-
-	return diffs, nil
+	var reportData *models.ReportData
+	var err error
+	if cached := r.cachedReevaluation(); cached != nil {
+		reportData, err = r.reevaluate(cached)
+	} else {
+		reportData, err = r.process()
+	}
+	if errors.Is(err, errRunSuperseded) {
+		logger.Warn("Superseded by a newer run of the service, its outputs are not published")
+		return nil
+	}
+	r.notifyOutcome(fmt.Sprintf("%s#%d", r.options.GhRepo, r.options.GhPrNumber), reportData, err)
+	if err != nil {
+		return outputErrorReport(reportData, err, r.outputReport)
+	}
+	// The outputs report the failed parts, the run still fails
+	if err := partialFailureOf(reportData.Errors); err != nil {
+		return err
+	}
+	return enforceProfiles(reportData, r.Evaluator.Config())
 }
 
-func (r *RunnerGitHub) Process() error {
+// process checks out, builds, diffs and evaluates the manifests then publishes the outputs,
+// on failure it returns the report data if it was built
+func (r *RunnerGitHub) process() (*models.ReportData, error) {
 	ctx, span := trace.StartSpan(r.Context, "Process")
 	defer span.End()
 
 	logger.Info("Process: starting...")
 
-	// Determine paths for git checkout
-	var beforeCheckoutPath, afterCheckoutPath string
-
-	if r.options.UseDynamicPaths() {
-		// For dynamic paths, extract the base path from the template
-		// e.g., "manifests-nested/services/[SERVICE]/clusters/[CLUSTER]/[ENV]"
-		//    -> checkout "manifests-nested" or "manifests-nested/services"
-
-		// Find the first variable in the template
-		templatePath := r.options.KustomizeBuildPath
-		varIdx := strings.Index(templatePath, "[")
-
-		if varIdx > 0 {
-			// Get path before first variable
-			basePath := templatePath[:varIdx]
-			// Remove trailing slash
-			basePath = strings.TrimSuffix(basePath, "/")
-
-			// If there's a path separator, take everything up to the last one
-			// to get a meaningful directory to checkout
-			if lastSlash := strings.LastIndex(basePath, "/"); lastSlash > 0 {
-				basePath = basePath[:lastSlash]
-			}
-
-			beforeCheckoutPath = basePath
-			afterCheckoutPath = basePath
-		} else {
-			// No variables or variable at start - checkout from manifests-path or root
-			if r.options.ManifestsPath != "" {
-				beforeCheckoutPath = r.options.ManifestsPath
-				afterCheckoutPath = r.options.ManifestsPath
-			} else {
-				beforeCheckoutPath = "."
-				afterCheckoutPath = "."
-			}
-		}
-
-		logger.WithFields(map[string]interface{}{
-			"templatePath":       templatePath,
-			"beforeCheckoutPath": beforeCheckoutPath,
-			"afterCheckoutPath":  afterCheckoutPath,
-			"strategy":           r.options.GitCheckoutStrategy,
-		}).Debug("Using dynamic paths - checking out manifests")
-	} else {
-		// Legacy mode: use service-based path
-		beforeCheckoutPath = filepath.Join(r.options.ManifestsPath, r.options.Service)
-		afterCheckoutPath = filepath.Join(r.options.ManifestsPath, r.options.Service)
-		logger.WithFields(map[string]interface{}{
-			"service":            r.options.Service,
-			"beforeCheckoutPath": beforeCheckoutPath,
-			"afterCheckoutPath":  afterCheckoutPath,
-		}).Debug("Using legacy mode - checking out service manifests")
-	}
-
-	logger.WithField("repo", r.options.GhRepo).WithField("branch", r.prInfo.BaseRef).Debug("Process: Calling CheckoutAtPath for base commit")
-	_, checkoutBaseSpan := trace.StartSpan(ctx, "GitCheckout.Base")
-	checkedOutBeforePath, err := r.ghclient.CheckoutAtPath(
-		r.Context, r.options.GhRepo, r.prInfo.BaseRef, beforeCheckoutPath, string(r.options.GitCheckoutStrategy))
-	if err != nil {
-		checkoutBaseSpan.End()
-		return fmt.Errorf("failed to checkout base commit: %w", err)
-	}
-	checkoutBaseSpan.End()
-	defer func() {
-		_ = os.RemoveAll(checkedOutBeforePath)
-	}()
-
-	logger.WithField("repo", r.options.GhRepo).WithField("headRef", r.prInfo.HeadRef).Info("Checking out manifests")
-	_, checkoutHeadSpan := trace.StartSpan(ctx, "GitCheckout.Head")
-	checkedOutAfterPath, err := r.ghclient.CheckoutAtPath(
-		r.Context, r.options.GhRepo, r.prInfo.HeadRef, afterCheckoutPath, string(r.options.GitCheckoutStrategy))
+	checkedOutBeforePath, checkedOutAfterPath, err := r.checkoutOnce(func() (string, string, error) {
+		return r.checkout(ctx)
+	})
 	if err != nil {
-		checkoutHeadSpan.End()
-		return fmt.Errorf("failed to checkout head commit: %w", err)
+		return nil, err
 	}
-	checkoutHeadSpan.End()
-	defer func() {
-		_ = os.RemoveAll(checkedOutAfterPath)
-	}()
 
 	// Determine the base paths for building manifests
-	var beforePath, afterPath string
-
-	if r.options.UseDynamicPaths() {
-		// For dynamic paths, the PathBuilder will handle the full path construction
-		// We just provide the checkout root
-		beforePath = checkedOutBeforePath
-		afterPath = checkedOutAfterPath
-		logger.WithFields(map[string]interface{}{
-			"beforePath": beforePath,
-			"afterPath":  afterPath,
-		}).Debug("Using dynamic paths - PathBuilder will construct full paths")
-	} else {
-		// Legacy mode: append manifests-path and service to checkout root
-		beforePath = filepath.Join(checkedOutBeforePath, r.options.ManifestsPath, r.options.Service)
-		afterPath = filepath.Join(checkedOutAfterPath, r.options.ManifestsPath, r.options.Service)
-		logger.WithFields(map[string]interface{}{
-			"service":    r.options.Service,
-			"beforePath": beforePath,
-			"afterPath":  afterPath,
-		}).Debug("Using legacy mode - constructing service paths")
-	}
+	beforePath := r.options.scmBuildPath(checkedOutBeforePath)
+	afterPath := r.options.scmBuildPath(checkedOutAfterPath)
 
 	rs, err := r.BuildManifests(beforePath, afterPath)
 	if err != nil {
-		return err
+		return nil, failure.Build(err)
 	}
 	logger.WithField("results", rs).Debug("Built Manifests")
+	r.overlayFiles = overlayFilesOf(r.FS, rs, checkedOutAfterPath)
+	if err := r.exportInventory(rs); err != nil {
+		return nil, err
+	}
+	if err := r.exportManifests(rs); err != nil {
+		return nil, err
+	}
 
 	diffs, err := r.DiffManifests(rs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	logger.WithField("results", diffs).Debug("Diffed Manifests")
 
-	ghComments, err := r.ghclient.GetComments(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	ghComments, err := r.currentComments()
 	if err != nil {
-		return fmt.Errorf("failed to get comments: %w", err)
-	}
-	ghCommentStrings := make([]string, len(ghComments))
-	for i, comment := range ghComments {
-		ghCommentStrings[i] = comment.Body
+		return nil, err
 	}
+	r.readChecklist(ghComments, r.commentSignature())
 
 	_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
-	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, ghCommentStrings)
+	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, ghComments)
 	if err != nil {
 		evalSpan.End()
-		return err
+		return nil, failure.PolicyEngine(err)
 	}
 	evalSpan.End()
 	logger.WithField("results", policyEval).Debug("Evaluated Policies")
 
 	reportData := r.buildReportData(rs, diffs, policyEval)
+	if err := r.updatePullRequest(rs, &reportData, checkedOutAfterPath); err != nil {
+		return &reportData, err
+	}
 
 	if err := r.Output(&reportData); err != nil {
+		return &reportData, err
+	}
+	r.writeEvaluationCache(&reportData, r.Evaluator.ImageBumpEnvironments())
+	if r.options.GhSuggestionComments {
+		r.outputSuggestionComments(rs, &reportData, checkedOutAfterPath)
+	}
+	return &reportData, nil
+}
+
+// updatePullRequest pushes the fixes, requests the reviewers and updates auto-merge of the pull request from the
+// report. The lease and head are checked first: a superseded run returns errRunSuperseded, and a stale report
+// changes nothing, so that the results of an older commit never act on the pull request
+func (r *RunnerGitHub) updatePullRequest(rs *models.BuildManifestResult, data *models.ReportData, checkoutRoot string) error {
+	if err := r.checkCurrent(data); err != nil {
 		return err
 	}
+	if data.Stale != nil {
+		logger.Warn("The report is stale, skipping the auto-fix, reviewer requests and auto-merge")
+		return nil
+	}
+	if r.options.AutoFix != "" {
+		data.AutoFix = r.autoFix(rs, data, checkoutRoot)
+	}
+	r.requestReviewers(data.ReviewerEscalations)
+	if r.options.AutoMerge != "" {
+		data.AutoMerge = r.autoMerge(data)
+	}
 	return nil
 }
 
+// checkCurrent checks that the run holds the lease of the tool comment and marks a stale report, once per run
+func (r *RunnerGitHub) checkCurrent(data *models.ReportData) error {
+	if r.checked {
+		return nil
+	}
+	r.checked = true
+	if !r.holdsRunLease(r.toolCommentAPI()) {
+		return errRunSuperseded
+	}
+	return r.checkStaleResult(r.toolCommentAPI(), data)
+}
+
+// checkout checks out the base and head commits of the pull request, for all the services of the run
+func (r *RunnerGitHub) checkout(ctx context.Context) (string, string, error) {
+	// Determine paths for git checkout
+	beforeCheckoutPath := r.options.scmCheckoutPath()
+	afterCheckoutPath := beforeCheckoutPath
+
+	logger.WithField("repo", r.options.GhRepo).WithField("branch", r.prInfo.BaseRef).Debug("Process: Calling CheckoutAtPath for base commit")
+	checkoutStart := time.Now()
+	checkoutBaseCtx, checkoutBaseSpan := trace.StartSpan(ctx, "GitCheckout.Base")
+	checkedOutBeforePath, err := r.ghclient.CheckoutAtPath(
+		checkoutBaseCtx, r.options.GhRepo, r.prInfo.BaseRef, beforeCheckoutPath, r.options.checkoutOptions())
+	if err != nil {
+		checkoutBaseSpan.End()
+		return "", "", failure.Checkout(fmt.Errorf("failed to checkout base commit: %w", err))
+	}
+	checkoutBaseSpan.End()
+
+	logger.WithField("repo", r.options.GhRepo).WithField("headRef", r.prInfo.HeadRef).Info("Checking out manifests")
+	checkoutHeadCtx, checkoutHeadSpan := trace.StartSpan(ctx, "GitCheckout.Head")
+	checkedOutAfterPath, err := r.ghclient.CheckoutAtPath(
+		checkoutHeadCtx, r.options.GhRepo, r.prInfo.HeadRef, afterCheckoutPath, r.options.checkoutOptions())
+	if err != nil {
+		checkoutHeadSpan.End()
+		_ = fsys.OrOS(r.FS).RemoveAll(checkedOutBeforePath)
+		return "", "", failure.Checkout(fmt.Errorf("failed to checkout head commit: %w", err))
+	}
+	checkoutHeadSpan.End()
+	r.Timings.CheckoutMs = msSince(checkoutStart)
+	return checkedOutBeforePath, checkedOutAfterPath, nil
+}
+
+// detectServices sets the services of the run to the service directories of --manifests-path the pull request
+// changes files of
+func (r *RunnerGitHub) detectServices() error {
+	files, err := r.ghclient.ListChangedFiles(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	if err != nil {
+		return fmt.Errorf("failed to detect the changed services: %w", authError(err))
+	}
+	services := changedServicesOf(files, r.options.ManifestsPath)
+	logger.WithField("files", len(files)).WithField("services", services).Info("Detected the changed services")
+	if len(services) > 1 && (r.options.AutoFix != "" || r.options.AutoMerge != "") {
+		return fmt.Errorf("--auto-fix and --auto-merge check a single service, the pull request changes %v", services)
+	}
+	r.options.Services = services
+	if len(services) > 0 {
+		r.options.Service = services[0]
+	}
+	return nil
+}
+
+// commentSignature returns the marker of the tool comment of the service
+func (r *RunnerGitHub) commentSignature() string {
+	return strings.ReplaceAll(template.ToolCommentSignature, template.ToolCommentServiceToken, r.options.serviceIdentifier())
+}
+
+// toolCommentAPI returns the API of the tool comment of the service, for its lease and the stale reports
+func (r *RunnerGitHub) toolCommentAPI() toolCommentAPI {
+	signature := r.commentSignature()
+	return toolCommentAPI{
+		destination: NOTIFY_GITHUB_API,
+		markdown:    false,
+		signature:   signature,
+		find: func() (*models.Comment, error) {
+			return r.ghclient.FindToolComment(r.Context, r.options.GhRepo, r.options.GhPrNumber, signature)
+		},
+		create: func(body string) error {
+			_, err := r.ghclient.CreateComment(r.Context, r.options.GhRepo, r.options.GhPrNumber, body)
+			return err
+		},
+		update: func(commentID int64, body string) error {
+			return r.ghclient.UpdateComment(r.Context, r.options.GhRepo, commentID, body)
+		},
+		headCommit: func() (string, error) {
+			pr, err := r.ghclient.GetPR(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+			if err != nil {
+				return "", err
+			}
+			return pr.HeadSHA, nil
+		},
+	}
+}
+
+// currentComments returns the current comments of the pull request, for the override commands
+func (r *RunnerGitHub) currentComments() ([]*models.Comment, error) {
+	ghComments, err := r.ghclient.GetComments(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", authError(err))
+	}
+	return ghComments, nil
+}
+
 func (r *RunnerGitHub) Output(data *models.ReportData) error {
 	_, span := trace.StartSpan(r.Context, "Output")
 	defer span.End()
 
 	logger.Info("Output: starting...")
-	if err := r.outputReportJson(data); err != nil {
+	if err := r.checkCurrent(data); err != nil {
 		return err
 	}
+	// The comment goes first for the report files to have its render and publish timings
 	if err := r.outputGitHubComment(data); err != nil {
 		return err
 	}
-	logger.Info("Output: done.")
-	return nil
-}
-
-// Exporting report json file to output directory if enabled
-func (r *RunnerGitHub) outputReportJson(data *models.ReportData) error {
-	if !r.Options.EnableExportReport {
-		logger.Info("OutputJson: option was disabled")
-		return nil
+	if err := r.outputCheckRun(data); err != nil {
+		return err
 	}
-	logger.Info("OutputJson: starting...")
-
-	if err := os.MkdirAll(r.Options.OutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	if err := r.outputReport(data); err != nil {
+		return err
 	}
-
-	resultsJson, err := json.Marshal(data)
-	if err != nil {
+	if err := r.outputPolicyCSV(data); err != nil {
+		return err
+	}
+	if err := r.outputPolicyJUnit(data); err != nil {
+		return err
+	}
+	if err := r.outputHTMLReport(data); err != nil {
+		return err
+	}
+	if err := r.outputMetrics(data); err != nil {
+		return err
+	}
+	if err := r.encryptArtifacts(); err != nil {
 		return err
 	}
-	filePath := filepath.Join(r.Options.OutputDir, "report.json")
-	if err := os.WriteFile(filePath, resultsJson, 0644); err != nil {
-		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write report data to file")
+	if err := r.outputIndex(data); err != nil {
 		return err
 	}
-	logger.WithField("filePath", filePath).Info("Written report data to file")
+	logger.Info("Output: done.")
 	return nil
 }
 
@@ -396,23 +447,45 @@ func (r *RunnerGitHub) outputReportJson(data *models.ReportData) error {
 func (r *RunnerGitHub) outputGitHubComment(data *models.ReportData) error {
 	logger.Info("OutputGitHubComment: starting...")
 
+	if r.Options.NoManifestContentInComment {
+		logger.Info("OutputGitHubComment: confidential mode, redacting manifest content")
+		data = RedactManifestContent(data)
+	}
+
 	// Render the markdown using templates
-	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, data)
+	renderStart := time.Now()
+	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, template.NewTemplateData(data))
+	r.Timings.RenderMs = msSince(renderStart)
 	if err != nil {
 		logger.WithField("error", err).Error("Failed to render markdown template")
-		return err
+		return failure.Render(err)
 	}
 	logger.WithField("renderedMarkdown", renderedMarkdown).Debug("Rendered markdown")
 
-	// Add the comment marker and replace the service token
-	// For dynamic paths, we'll use a generic signature or the first overlay key
-	serviceIdentifier := r.Options.Service
-	if serviceIdentifier == "" && r.options.UseDynamicPaths() {
-		// Use a generic identifier for dynamic paths
-		serviceIdentifier = "dynamic-paths"
+	// Write the step summary first, for the workflow UI to show the report even if the comment fails to post
+	if path := r.stepSummaryPath(); path != "" {
+		if err := writeStepSummary(path, staleNoteOf(data)+renderedMarkdown); err != nil {
+			if r.options.GhStepSummary == GH_STEP_SUMMARY_ONLY {
+				logger.WithField("error", err).Error("Failed to write the step summary")
+				return failure.SCMPublish(err)
+			}
+			logger.WithField("error", err).Warn("Failed to write the step summary")
+		} else {
+			logger.WithField("filePath", path).Info("Written report to the step summary")
+			if r.options.GhStepSummary == GH_STEP_SUMMARY_ONLY {
+				return nil
+			}
+		}
+	} else if r.options.GhStepSummary == GH_STEP_SUMMARY_ONLY {
+		logger.Warnf("OutputGitHubComment: %s is not set, posting the PR comment instead of the step summary", GH_STEP_SUMMARY_ENV)
 	}
-	commentSignature := strings.ReplaceAll(template.ToolCommentSignature, template.ToolCommentServiceToken, serviceIdentifier)
-	finalComment := commentSignature + "\n\n" + renderedMarkdown
+
+	// Add the comment marker
+	commentSignature := r.commentSignature()
+	finalComment := r.toolCommentBody(commentSignature, renderedMarkdown, data, false)
+
+	publishStart := time.Now()
+	defer func() { r.Timings.PublishMs = msSince(publishStart) }()
 
 	// Check if there's an existing comment from this tool for this specific service
 	// We search for the comment signature to find the right comment
@@ -423,16 +496,23 @@ func (r *RunnerGitHub) outputGitHubComment(data *models.ReportData) error {
 
 	if existingComment != nil {
 		// Update existing comment
-		if err := r.ghclient.UpdateComment(r.Context, r.options.GhRepo, existingComment.ID, finalComment); err != nil {
+		err := r.publish(NOTIFY_GITHUB_API, func() error {
+			return r.ghclient.UpdateComment(r.Context, r.options.GhRepo, existingComment.ID, finalComment)
+		})
+		if err != nil {
 			logger.WithField("error", err).Error("Failed to update existing comment")
-			return err
+			return failure.SCMPublish(authError(err))
 		}
 		logger.Info("Updated existing GitHub comment")
 	} else {
 		// Create new comment
-		if _, err := r.ghclient.CreateComment(r.Context, r.options.GhRepo, r.options.GhPrNumber, finalComment); err != nil {
-			logger.WithField("error", err).Error("Failed to create new comment")
+		err := r.publish(NOTIFY_GITHUB_API, func() error {
+			_, err := r.ghclient.CreateComment(r.Context, r.options.GhRepo, r.options.GhPrNumber, finalComment)
 			return err
+		})
+		if err != nil {
+			logger.WithField("error", err).Error("Failed to create new comment")
+			return failure.SCMPublish(authError(err))
 		}
 		logger.Info("Created new GitHub comment")
 	}
@@ -446,36 +526,5 @@ func (r *RunnerGitHub) buildReportData(
 	diffs map[string]models.EnvironmentDiff,
 	policyEval *models.PolicyEvaluation,
 ) models.ReportData {
-	reportData := models.ReportData{
-		Timestamp:        time.Now(),
-		BaseCommit:       r.prInfo.BaseSHA,
-		HeadCommit:       r.prInfo.HeadSHA,
-		ManifestChanges:  diffs,
-		PolicyEvaluation: *policyEval,
-	}
-
-	if r.options.UseDynamicPaths() {
-		// Dynamic paths mode
-		// Use the ordered OverlayKeys from BuildManifestResult to preserve ordering
-		reportData.OverlayKeys = rs.OverlayKeys
-		reportData.KustomizeBuildPath = r.options.KustomizeBuildPath
-		reportData.KustomizeBuildValues = r.options.KustomizeBuildValues
-
-		// Add parsed build values if PathBuilder is available
-		if r.options.PathBuilder != nil {
-			reportData.ParsedKustomizeBuildValues = r.options.PathBuilder.Variables
-		}
-
-		// Set Service to empty for dynamic mode (or extract from path if needed)
-		reportData.Service = ""
-		// Keep Environments for backward compat in templates, same as OverlayKeys
-		reportData.Environments = rs.OverlayKeys
-	} else {
-		// Legacy mode
-		reportData.Service = r.options.Service
-		reportData.Environments = r.options.Environments
-		reportData.OverlayKeys = r.options.Environments
-	}
-
-	return reportData
+	return r.pullRequestReportData(rs, diffs, policyEval, r.prInfo)
 }
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// fakeGitHubClient answers the head and tool comment reads, and records the pull request changes
+type fakeGitHubClient struct {
+	github.GitHubClient
+	head  string
+	calls []string
+}
+
+func (c *fakeGitHubClient) GetPR(ctx context.Context, repo string, number int) (*models.PullRequest, error) {
+	return &models.PullRequest{HeadSHA: c.head}, nil
+}
+
+func (c *fakeGitHubClient) FindToolComment(ctx context.Context, repo string, prNumber int, searchString string) (*models.Comment, error) {
+	return nil, nil
+}
+
+func (c *fakeGitHubClient) CommitAndPush(ctx context.Context, dir string, files []string, message, branch string, force bool) (string, error) {
+	c.calls = append(c.calls, "CommitAndPush")
+	return "fixed", nil
+}
+
+func (c *fakeGitHubClient) RequestTeamReviewers(ctx context.Context, repo string, number int, teams []string) error {
+	c.calls = append(c.calls, "RequestTeamReviewers")
+	return nil
+}
+
+func (c *fakeGitHubClient) EnableAutoMerge(ctx context.Context, nodeID, method, headSHA string) error {
+	c.calls = append(c.calls, "EnableAutoMerge")
+	return nil
+}
+
+// TestUpdatePullRequest checks that the report of a commit that is no longer the head changes nothing on the PR
+func TestUpdatePullRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		head        string
+		autoFix     string
+		escalations []models.ReviewerEscalation
+		wantStale   bool
+		wantCalls   []string
+	}{
+		{name: "current head", head: "abc", wantCalls: []string{"EnableAutoMerge"}},
+		{name: "current head escalated", head: "abc", escalations: []models.ReviewerEscalation{{Team: "org/platform"}}, wantCalls: []string{"RequestTeamReviewers"}},
+		{name: "new commits pushed", head: "def", autoFix: models.AutoFixModeCommit, escalations: []models.ReviewerEscalation{{Team: "org/platform"}}, wantStale: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeGitHubClient{head: tt.head}
+			opts := &Options{AutoFix: tt.autoFix, AutoMerge: models.AutoMergeModeEnable, AutoMergeMethod: "squash"}
+			r := &RunnerGitHub{
+				RunnerBase: RunnerBase{Context: context.Background(), Options: opts},
+				options:    opts,
+				ghclient:   client,
+				prInfo:     &models.PullRequest{HeadSHA: "abc", NodeID: "PR_1"},
+			}
+			data := &models.ReportData{
+				HeadCommit:          "abc",
+				ReviewerEscalations: tt.escalations,
+			}
+
+			if err := r.updatePullRequest(&models.BuildManifestResult{}, data, t.TempDir()); err != nil {
+				t.Fatalf("updatePullRequest() error = %v", err)
+			}
+			if (data.Stale != nil) != tt.wantStale {
+				t.Errorf("updatePullRequest() stale = %v, want %v", data.Stale, tt.wantStale)
+			}
+			if len(client.calls) != len(tt.wantCalls) {
+				t.Fatalf("updatePullRequest() calls = %v, want %v", client.calls, tt.wantCalls)
+			}
+			for i, call := range tt.wantCalls {
+				if client.calls[i] != call {
+					t.Errorf("updatePullRequest() calls = %v, want %v", client.calls, tt.wantCalls)
+				}
+			}
+		})
+	}
+}
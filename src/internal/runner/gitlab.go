@@ -0,0 +1,341 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitlab"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+)
+
+const (
+	// GitLab note body length limit is 1,000,000 characters, the diffs are kept as short as on GitHub to stay readable
+	GL_COMMENT_MAX_DIFF_LENGTH = GH_COMMENT_MAX_DIFF_LENGTH
+)
+
+var (
+	gitlabCommentMaxDiffLength = GL_COMMENT_MAX_DIFF_LENGTH
+)
+
+// RunnerGitLab checks a merge request of a GitLab project, its outcome is published as a note of the merge request.
+// The GitHub-only features (check runs, auto-fix, auto-merge, reviewer requests, suggestions) are not supported
+type RunnerGitLab struct {
+	RunnerBase
+
+	options  *Options
+	glclient *gitlab.Client
+
+	mrInfo *models.PullRequest
+}
+
+func NewRunnerGitLab(
+	ctx context.Context,
+	options *Options,
+	glclient *gitlab.Client,
+	builder *kustomize.Builder,
+	differ *diff.Differ,
+	evaluator *policy.PolicyEvaluator,
+	renderer *template.Renderer,
+) (*RunnerGitLab, error) {
+	if glclient == nil {
+		return nil, fmt.Errorf("GitLab client is not initialized")
+	}
+	baseRunner, err := NewRunnerBase(ctx, options, builder, differ, evaluator, renderer)
+	if err != nil {
+		return nil, err
+	}
+	runner := &RunnerGitLab{
+		RunnerBase: *baseRunner,
+		glclient:   glclient,
+		options:    options,
+	}
+	return runner, nil
+}
+
+func (r *RunnerGitLab) Initialize() error {
+	lg := logger.WithField("func", "RunnerGitLab.Initialize()")
+	lg.Info("Initializing runner: starting...")
+
+	mr, err := r.glclient.GetMR(r.Context, r.options.GlProject, r.options.GlMrIid)
+	if err != nil {
+		return outputErrorReport(nil, fmt.Errorf("failed to fetch merge request info: %w", authError(err)), r.outputReport)
+	}
+	r.mrInfo = mr
+
+	if maxDiffLengthStr := os.Getenv("GITLAB_COMMENT_MAX_DIFF_LENGTH"); maxDiffLengthStr != "" {
+		if _, err := fmt.Sscanf(maxDiffLengthStr, "%d", &gitlabCommentMaxDiffLength); err != nil {
+			lg.WithField("GITLAB_COMMENT_MAX_DIFF_LENGTH", maxDiffLengthStr).WithField("error", err).Warn("GITLAB_COMMENT_MAX_DIFF_LENGTH env was set but failed to parse into int. Will use default value of 10,000.")
+			gitlabCommentMaxDiffLength = GL_COMMENT_MAX_DIFF_LENGTH
+		}
+	}
+	lg.Info("Initializing runner: done.")
+	if err := r.RunnerBase.Initialize(); err != nil {
+		return outputErrorReport(nil, err, r.outputReport)
+	}
+	r.Evaluator.SetPolicyContext(models.PolicyContext{
+		Service: r.options.Service,
+		PullRequest: &models.PullRequestContext{
+			Repo:    r.options.GlProject,
+			Number:  r.mrInfo.Number,
+			Title:   r.mrInfo.Title,
+			BaseRef: r.mrInfo.BaseRef,
+			HeadRef: r.mrInfo.HeadRef,
+		},
+	})
+	return nil
+}
+
+func (r *RunnerGitLab) BuildManifests(beforePath, afterPath string) (*models.BuildManifestResult, error) {
+	return r.RunnerBase.BuildManifests(beforePath, afterPath)
+}
+
+func (r *RunnerGitLab) DiffManifests(result *models.BuildManifestResult) (map[string]models.EnvironmentDiff, error) {
+	diffs, err := r.RunnerBase.DiffManifests(result)
+	if err != nil {
+		return nil, err
+	}
+	return r.offloadLongDiffs(result, diffs, gitlabCommentMaxDiffLength, r.options.GlMrIid, gitlab.GetJobURL)
+}
+
+func (r *RunnerGitLab) Process() error {
+	defer r.removeCheckouts()
+	return r.processServices(r.processService)
+}
+
+// processService checks the service of the run, its outputs report the failure of a part
+func (r *RunnerGitLab) processService() error {
+	if !r.acquireRunLease(r.toolCommentAPI()) {
+		logger.Warn("Superseded by a newer run of the service, skipping")
+		return nil
+	}
+	reportData, err := r.process()
+	if errors.Is(err, errRunSuperseded) {
+		logger.Warn("Superseded by a newer run of the service, its outputs are not published")
+		return nil
+	}
+	r.notifyOutcome(fmt.Sprintf("%s!%d", r.options.GlProject, r.options.GlMrIid), reportData, err)
+	if err != nil {
+		return outputErrorReport(reportData, err, r.outputReport)
+	}
+	// The outputs report the failed parts, the run still fails
+	if err := partialFailureOf(reportData.Errors); err != nil {
+		return err
+	}
+	return enforceProfiles(reportData, r.Evaluator.Config())
+}
+
+// process checks out, builds, diffs and evaluates the manifests then publishes the outputs,
+// on failure it returns the report data if it was built
+func (r *RunnerGitLab) process() (*models.ReportData, error) {
+	ctx, span := trace.StartSpan(r.Context, "Process")
+	defer span.End()
+
+	logger.Info("Process: starting...")
+	checkedOutBeforePath, checkedOutAfterPath, err := r.checkoutOnce(func() (string, string, error) {
+		return r.checkout(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := r.BuildManifests(r.options.scmBuildPath(checkedOutBeforePath), r.options.scmBuildPath(checkedOutAfterPath))
+	if err != nil {
+		return nil, failure.Build(err)
+	}
+	logger.WithField("results", rs).Debug("Built Manifests")
+	if err := r.exportInventory(rs); err != nil {
+		return nil, err
+	}
+	if err := r.exportManifests(rs); err != nil {
+		return nil, err
+	}
+
+	diffs, err := r.DiffManifests(rs)
+	if err != nil {
+		return nil, err
+	}
+	logger.WithField("results", diffs).Debug("Diffed Manifests")
+
+	glComments, err := r.glclient.GetComments(r.Context, r.options.GlProject, r.options.GlMrIid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notes: %w", authError(err))
+	}
+	r.readChecklist(glComments, r.commentSignature())
+
+	_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
+	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, glComments)
+	evalSpan.End()
+	if err != nil {
+		return nil, failure.PolicyEngine(err)
+	}
+	logger.WithField("results", policyEval).Debug("Evaluated Policies")
+
+	reportData := r.pullRequestReportData(rs, diffs, policyEval, r.mrInfo)
+	if err := r.Output(&reportData); err != nil {
+		return &reportData, err
+	}
+	return &reportData, nil
+}
+
+// checkout checks out the base and head commits of the merge request, for all the services of the run
+func (r *RunnerGitLab) checkout(ctx context.Context) (string, string, error) {
+	checkoutPath := r.options.scmCheckoutPath()
+
+	logger.WithField("project", r.options.GlProject).WithField("branch", r.mrInfo.BaseRef).Debug("Process: Calling CheckoutAtPath for base commit")
+	checkoutStart := time.Now()
+	checkoutBaseCtx, checkoutBaseSpan := trace.StartSpan(ctx, "GitCheckout.Base")
+	checkedOutBeforePath, err := r.glclient.CheckoutAtPath(
+		checkoutBaseCtx, r.options.GlProject, r.mrInfo.BaseRef, checkoutPath, r.options.checkoutOptions())
+	checkoutBaseSpan.End()
+	if err != nil {
+		return "", "", failure.Checkout(fmt.Errorf("failed to checkout base commit: %w", err))
+	}
+
+	logger.WithField("project", r.options.GlProject).WithField("headRef", r.mrInfo.HeadRef).Info("Checking out manifests")
+	checkoutHeadCtx, checkoutHeadSpan := trace.StartSpan(ctx, "GitCheckout.Head")
+	checkedOutAfterPath, err := r.glclient.CheckoutAtPath(
+		checkoutHeadCtx, r.options.GlProject, r.mrInfo.HeadRef, checkoutPath, r.options.checkoutOptions())
+	checkoutHeadSpan.End()
+	if err != nil {
+		_ = fsys.OrOS(r.FS).RemoveAll(checkedOutBeforePath)
+		return "", "", failure.Checkout(fmt.Errorf("failed to checkout head commit: %w", err))
+	}
+	r.Timings.CheckoutMs = msSince(checkoutStart)
+	return checkedOutBeforePath, checkedOutAfterPath, nil
+}
+
+// commentSignature returns the marker of the tool note of the service
+func (r *RunnerGitLab) commentSignature() string {
+	return strings.ReplaceAll(template.ToolCommentSignature, template.ToolCommentServiceToken, r.options.serviceIdentifier())
+}
+
+// toolCommentAPI returns the API of the tool comment of the service, for its lease and the stale reports
+func (r *RunnerGitLab) toolCommentAPI() toolCommentAPI {
+	signature := r.commentSignature()
+	return toolCommentAPI{
+		destination: NOTIFY_GITLAB_API,
+		markdown:    false,
+		signature:   signature,
+		find: func() (*models.Comment, error) {
+			return r.glclient.FindToolComment(r.Context, r.options.GlProject, r.options.GlMrIid, signature)
+		},
+		create: func(body string) error {
+			_, err := r.glclient.CreateComment(r.Context, r.options.GlProject, r.options.GlMrIid, body)
+			return err
+		},
+		update: func(commentID int64, body string) error {
+			return r.glclient.UpdateComment(r.Context, r.options.GlProject, r.options.GlMrIid, commentID, body)
+		},
+		headCommit: func() (string, error) {
+			pr, err := r.glclient.GetMR(r.Context, r.options.GlProject, r.options.GlMrIid)
+			if err != nil {
+				return "", err
+			}
+			return pr.HeadSHA, nil
+		},
+	}
+}
+
+func (r *RunnerGitLab) Output(data *models.ReportData) error {
+	_, span := trace.StartSpan(r.Context, "Output")
+	defer span.End()
+
+	logger.Info("Output: starting...")
+	if !r.holdsRunLease(r.toolCommentAPI()) {
+		return errRunSuperseded
+	}
+	if err := r.checkStaleResult(r.toolCommentAPI(), data); err != nil {
+		return err
+	}
+	// The note goes first for the report files to have its render and publish timings
+	if err := r.outputGitLabComment(data); err != nil {
+		return err
+	}
+	if err := r.outputReport(data); err != nil {
+		return err
+	}
+	if err := r.outputPolicyCSV(data); err != nil {
+		return err
+	}
+	if err := r.outputPolicyJUnit(data); err != nil {
+		return err
+	}
+	if err := r.outputHTMLReport(data); err != nil {
+		return err
+	}
+	if err := r.outputMetrics(data); err != nil {
+		return err
+	}
+	if err := r.encryptArtifacts(); err != nil {
+		return err
+	}
+	if err := r.outputIndex(data); err != nil {
+		return err
+	}
+	logger.Info("Output: done.")
+	return nil
+}
+
+// Post note to GitLab MR, updating the note of the previous run of the service
+func (r *RunnerGitLab) outputGitLabComment(data *models.ReportData) error {
+	logger.Info("OutputGitLabComment: starting...")
+
+	if r.Options.NoManifestContentInComment {
+		logger.Info("OutputGitLabComment: confidential mode, redacting manifest content")
+		data = RedactManifestContent(data)
+	}
+
+	renderStart := time.Now()
+	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, template.NewTemplateData(data))
+	r.Timings.RenderMs = msSince(renderStart)
+	if err != nil {
+		logger.WithField("error", err).Error("Failed to render markdown template")
+		return failure.Render(err)
+	}
+	logger.WithField("renderedMarkdown", renderedMarkdown).Debug("Rendered markdown")
+
+	commentSignature := r.commentSignature()
+	finalComment := r.toolCommentBody(commentSignature, renderedMarkdown, data, false)
+
+	publishStart := time.Now()
+	defer func() { r.Timings.PublishMs = msSince(publishStart) }()
+
+	existingComment, err := r.glclient.FindToolComment(r.Context, r.options.GlProject, r.options.GlMrIid, commentSignature)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to find existing note, will create new one")
+	}
+
+	if existingComment != nil {
+		err := r.publish(NOTIFY_GITLAB_API, func() error {
+			return r.glclient.UpdateComment(r.Context, r.options.GlProject, r.options.GlMrIid, existingComment.ID, finalComment)
+		})
+		if err != nil {
+			logger.WithField("error", err).Error("Failed to update existing note")
+			return failure.SCMPublish(authError(err))
+		}
+		logger.Info("Updated existing GitLab note")
+	} else {
+		err := r.publish(NOTIFY_GITLAB_API, func() error {
+			_, err := r.glclient.CreateComment(r.Context, r.options.GlProject, r.options.GlMrIid, finalComment)
+			return err
+		})
+		if err != nil {
+			logger.WithField("error", err).Error("Failed to create new note")
+			return failure.SCMPublish(authError(err))
+		}
+		logger.Info("Created new GitLab note")
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+)
+
+// PolicyImpact builds every overlay of the corpus (<corpus>/<service>/environments/<env>) and evaluates it with
+// both the policies of the runner and the old policies of against, then prints the overlays the policy
+// changes newly fail, newly block or newly pass
+func (r *RunnerLocal) PolicyImpact(corpus, ref string, against *policy.PolicyEvaluator) error {
+	ctx, span := trace.StartSpan(r.Context, "PolicyImpact")
+	defer span.End()
+
+	if err := against.LoadAndValidate(); err != nil {
+		return failure.PolicyEngine(fmt.Errorf("failed to load the policy config at %s: %w", ref, err))
+	}
+	if err := against.FetchExternalData(ctx); err != nil {
+		return failure.PolicyEngine(fmt.Errorf("failed to fetch external data of the policies at %s: %w", ref, err))
+	}
+	if err := against.VerifyLibraries(ctx); err != nil {
+		return failure.PolicyEngine(fmt.Errorf("failed to verify policy libraries at %s: %w", ref, err))
+	}
+
+	overlays, err := discoverBenchOverlays(corpus)
+	if err != nil {
+		return err
+	}
+	logger.WithField("corpus", corpus).WithField("overlays", len(overlays)).WithField("against", ref).Info("PolicyImpact: starting...")
+
+	rs := &models.BuildManifestResult{EnvManifestBuild: make(map[string]models.BuildEnvManifestResult)}
+	for _, overlay := range overlays {
+		overlayKey := filepath.Base(overlay.ServicePath) + "/" + overlay.Environment
+		manifest, err := r.Builder.Build(ctx, overlay.ServicePath, overlay.Environment)
+		if err != nil {
+			return failure.Build(fmt.Errorf("failed to build %s: %w", overlayKey, err))
+		}
+		rs.OverlayKeys = append(rs.OverlayKeys, overlayKey)
+		rs.EnvManifestBuild[overlayKey] = models.BuildEnvManifestResult{
+			OverlayKey:    overlayKey,
+			Environment:   overlay.Environment,
+			Variables:     map[string]string{"SERVICE": filepath.Base(overlay.ServicePath), "ENV": overlay.Environment},
+			AfterManifest: manifest,
+		}
+	}
+
+	after, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, nil)
+	if err != nil {
+		return failure.PolicyEngine(err)
+	}
+	before, err := against.GeneratePolicyEvalResultForManifests(ctx, *rs, nil)
+	if err != nil {
+		return failure.PolicyEngine(fmt.Errorf("failed to evaluate the policies at %s: %w", ref, err))
+	}
+
+	report := &models.PolicyImpactReport{
+		Against:  ref,
+		Corpus:   corpus,
+		Overlays: len(overlays),
+		Changes:  policy.CompareEvaluations(before, after, rs.OverlayKeys),
+	}
+	fmt.Print(formatPolicyImpact(report))
+	return r.outputImpactJson(report)
+}
+
+// formatPolicyImpact renders the impact summary for the terminal, grouped by kind of impact
+func formatPolicyImpact(report *models.PolicyImpactReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Policy impact against %s over %d overlay(s) of %s\n", report.Against, report.Overlays, report.Corpus)
+	if len(report.Changes) == 0 {
+		sb.WriteString("No policy result changes.\n")
+		return sb.String()
+	}
+	for _, kind := range []struct{ kind, title string }{
+		{policy.IMPACT_NEWLY_FAILING, "Newly failing"},
+		{policy.IMPACT_NEWLY_BLOCKING, "Newly blocking"},
+		{policy.IMPACT_NEWLY_PASSING, "Newly passing"},
+	} {
+		var lines []string
+		services := make(map[string]bool)
+		for _, change := range report.Changes {
+			if change.Kind != kind.kind {
+				continue
+			}
+			service, _, _ := strings.Cut(change.OverlayKey, "/")
+			services[service] = true
+			line := fmt.Sprintf("  - %s: policy %s (%s)", change.OverlayKey, change.PolicyId, levelChangeOf(change))
+			if len(change.Messages) > 0 {
+				line += fmt.Sprintf(": %s", change.Messages[0])
+				if more := len(change.Messages) - 1; more > 0 {
+					line += fmt.Sprintf(" (and %d more)", more)
+				}
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n%s: %d service(s), %d overlay policy result(s)\n", kind.title, len(services), len(lines))
+		sb.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+	return sb.String()
+}
+
+// levelChangeOf describes the enforcement levels of a policy change, e.g. "WARNING -> BLOCK" or "new, BLOCK"
+func levelChangeOf(change models.PolicyImpact) string {
+	switch {
+	case change.BeforeLevel == "":
+		return "new, " + change.AfterLevel
+	case change.AfterLevel == "":
+		return "removed"
+	case change.BeforeLevel == change.AfterLevel:
+		return change.AfterLevel
+	default:
+		return change.BeforeLevel + " -> " + change.AfterLevel
+	}
+}
+
+// Exporting impact json file to output directory if enabled
+func (r *RunnerLocal) outputImpactJson(report *models.PolicyImpactReport) error {
+	if !r.Options.EnableExportReport {
+		return nil
+	}
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	impactJson, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	filePath := filepath.Join(r.Options.OutputDir, "impact.json")
+	if err := perm.WriteFile(filePath, impactJson); err != nil {
+		return fmt.Errorf("failed to write impact report to %s: %w", filePath, err)
+	}
+	logger.WithField("filePath", filePath).Info("Written impact report to file")
+	return nil
+}
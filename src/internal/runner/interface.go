@@ -18,4 +18,7 @@ type RunnerInterface interface {
 
 	// Handling the export
 	Output(data *models.ReportData) error
+
+	// Release the resources of the run, e.g. the external data fetched for the policies
+	Close() error
 }
@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+)
+
+// RUN_LEASE_IN_PROGRESS is the body of the tool comment created by a run taking its lease, until its outputs
+const RUN_LEASE_IN_PROGRESS = "⏳ Checking the changes, the report is posted here once done."
+
+// runLeaseHolderEnvs are the variables identifying the CI run holding a lease, the host and process otherwise
+var runLeaseHolderEnvs = []string{"GITHUB_RUN_ID", "CI_JOB_ID", "BITBUCKET_BUILD_NUMBER"}
+
+// runLeasePattern matches the lease marker of a tool comment, HTML or link reference definition
+var runLeasePattern = regexp.MustCompile(`(?m)^(?:<!-- |\[//\]: # \()gitops-kustomzchk-lease: (\S+) (\S+)(?: -->|\))$`)
+
+// errRunSuperseded is returned by the outputs of a run whose tool comment was taken by a newer run, or has the
+// report of a newer head commit
+var errRunSuperseded = errors.New("a newer run of the service holds or published the tool comment, its outputs are not published")
+
+// runLease is the lease of a run on the tool comments of its services: the newest run, by start time, holds them
+type runLease struct {
+	startedAt time.Time
+	holder    string
+}
+
+// toolCommentAPI reads and writes the tool comment of the service of the run on the pull request of an SCM, and
+// reads the head commit of the pull request
+type toolCommentAPI struct {
+	destination string // NOTIFY_*_API
+	markdown    bool   // the SCM renders no HTML comment
+	signature   string
+	find        func() (*models.Comment, error)
+	create      func(body string) error
+	update      func(commentID int64, body string) error
+	headCommit  func() (string, error)
+}
+
+// acquireRunLease takes the lease of the tool comment of the service with --run-lock, creating the comment if
+// there is none. It returns false if a newer run holds it, the run is then superseded. The lease is best effort:
+// a failed API call is logged and the run goes on
+func (r *RunnerBase) acquireRunLease(api toolCommentAPI) bool {
+	if !r.Options.RunLock {
+		return true
+	}
+	if r.lease == nil {
+		r.lease = &runLease{startedAt: time.Now().UTC(), holder: runLeaseHolder()}
+	}
+	comment, err := api.find()
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to read the lease of the tool comment, running without it")
+		return true
+	}
+	if r.supersededBy(comment) {
+		return false
+	}
+
+	marker := r.leaseMarker(api.markdown)
+	if comment == nil {
+		err = r.publish(api.destination, func() error {
+			return api.create(api.signature + "\n\n" + RUN_LEASE_IN_PROGRESS + "\n\n" + marker)
+		})
+	} else {
+		body := runLeasePattern.ReplaceAllLiteralString(comment.Body, marker)
+		if body == comment.Body {
+			body = strings.TrimRight(body, "\n") + "\n\n" + marker
+		}
+		err = r.publish(api.destination, func() error { return api.update(comment.ID, body) })
+	}
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to take the lease of the tool comment, running without it")
+		return true
+	}
+	logger.WithField("holder", r.lease.holder).Info("Took the lease of the tool comment")
+	return true
+}
+
+// holdsRunLease reports whether the run still holds the lease of the tool comment of the service, false once a
+// newer run took it. Always true without --run-lock
+func (r *RunnerBase) holdsRunLease(api toolCommentAPI) bool {
+	if !r.Options.RunLock || r.lease == nil {
+		return true
+	}
+	comment, err := api.find()
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to read the lease of the tool comment, publishing the outputs")
+		return true
+	}
+	return !r.supersededBy(comment)
+}
+
+// supersededBy reports whether the lease of a tool comment is held by a run started after this one
+func (r *RunnerBase) supersededBy(comment *models.Comment) bool {
+	if comment == nil {
+		return false
+	}
+	match := runLeasePattern.FindStringSubmatch(comment.Body)
+	if match == nil {
+		return false
+	}
+	startedAt, err := time.Parse(time.RFC3339Nano, match[1])
+	if err != nil || !startedAt.After(r.lease.startedAt) {
+		return false
+	}
+	logger.WithField("holder", match[2]).WithField("startedAt", match[1]).Warn("A newer run holds the lease of the tool comment")
+	return true
+}
+
+// leaseMarker returns the lease marker of the run, empty without --run-lock
+func (r *RunnerBase) leaseMarker(markdown bool) string {
+	if !r.Options.RunLock || r.lease == nil {
+		return ""
+	}
+	signature := template.ToolLeaseSignature
+	if markdown {
+		signature = template.ToolLeaseSignatureMarkdown
+	}
+	lease := r.lease.startedAt.Format(time.RFC3339Nano) + " " + r.lease.holder
+	return strings.ReplaceAll(signature, template.ToolLeaseKeyToken, lease)
+}
+
+// withLeaseMarker appends the lease marker of the run to a tool comment body, for the runs after it to see it
+func (r *RunnerBase) withLeaseMarker(body string, markdown bool) string {
+	if marker := r.leaseMarker(markdown); marker != "" {
+		return strings.TrimRight(body, "\n") + "\n\n" + marker
+	}
+	return body
+}
+
+// runLeaseHolder identifies the run in its lease, by its CI run id or its host and process
+func runLeaseHolder() string {
+	for _, name := range runLeaseHolderEnvs {
+		if value := os.Getenv(name); value != "" && !strings.ContainsAny(value, " \t\n") {
+			return value
+		}
+	}
+	host, err := os.Hostname()
+	if err != nil || strings.ContainsAny(host, " \t\n") {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -2,7 +2,6 @@ package runner
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -10,8 +9,10 @@ import (
 	"time"
 
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
@@ -43,7 +44,10 @@ func NewRunnerLocal(
 }
 
 func (r *RunnerLocal) Initialize() error {
-	return r.RunnerBase.Initialize()
+	if err := r.RunnerBase.Initialize(); err != nil {
+		return outputErrorReport(nil, err, r.outputReport)
+	}
+	return nil
 }
 
 func (r *RunnerLocal) BuildManifests(beforePath, afterPath string) (*models.BuildManifestResult, error) {
@@ -55,45 +59,53 @@ func (r *RunnerLocal) DiffManifests(result *models.BuildManifestResult) (map[str
 }
 
 func (r *RunnerLocal) Process() error {
+	return r.processServices(r.processService)
+}
+
+// processService checks the service of the run, its outputs report the failure of a part
+func (r *RunnerLocal) processService() error {
+	reportData, err := r.process()
+	if err != nil {
+		return outputErrorReport(reportData, err, r.outputReport)
+	}
+	// The outputs report the failed parts, the run still fails
+	if err := partialFailureOf(reportData.Errors); err != nil {
+		return err
+	}
+	return enforceProfiles(reportData, r.Evaluator.Config())
+}
+
+// process builds, diffs and evaluates the manifests then writes the outputs,
+// on failure it returns the report data if it was built
+func (r *RunnerLocal) process() (*models.ReportData, error) {
 	ctx, span := trace.StartSpan(r.Context, "Process")
 	defer span.End()
 
 	logger.Info("Process: starting...")
 
-	var rs *models.BuildManifestResult
-	var err error
-
-	if r.Options.UseLocalDynamicPaths() {
-		// Local dynamic mode with separate before/after path templates
-		rs, err = r.buildManifestsLocalDynamic(ctx)
-	} else if r.Options.UseDynamicPaths() {
-		// Shared dynamic mode: use the before/after paths directly as roots
-		beforePath := r.Options.LcBeforeManifestsPath
-		afterPath := r.Options.LcAfterManifestsPath
-		rs, err = r.BuildManifests(beforePath, afterPath)
-	} else {
-		// Legacy mode: append service name to paths
-		beforePath := filepath.Join(r.Options.LcBeforeManifestsPath, r.Options.Service)
-		afterPath := filepath.Join(r.Options.LcAfterManifestsPath, r.Options.Service)
-		rs, err = r.BuildManifests(beforePath, afterPath)
-	}
-
+	rs, err := r.buildLocalManifests(ctx)
 	if err != nil {
-		return err
+		return nil, failure.Build(err)
 	}
 	logger.WithField("results", rs).Debug("Built Manifests")
+	if err := r.exportInventory(rs); err != nil {
+		return nil, err
+	}
+	if err := r.exportManifests(rs); err != nil {
+		return nil, err
+	}
 
 	diffs, err := r.DiffManifests(rs)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	logger.WithField("results", diffs).Debug("Diffed Manifests")
 
 	_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
-	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, []string{})
+	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, nil)
 	if err != nil {
 		evalSpan.End()
-		return err
+		return nil, failure.PolicyEngine(err)
 	}
 	evalSpan.End()
 	logger.WithField("results", policyEval).Debug("Evaluated Policies")
@@ -102,9 +114,25 @@ func (r *RunnerLocal) Process() error {
 	reportData := r.buildReportData(rs, diffs, policyEval)
 
 	if err := r.Output(&reportData); err != nil {
-		return err
+		return &reportData, err
 	}
-	return nil
+	return &reportData, nil
+}
+
+// buildLocalManifests builds the manifests with the path mode selected by the flags
+func (r *RunnerLocal) buildLocalManifests(ctx context.Context) (*models.BuildManifestResult, error) {
+	if r.Options.UseLocalDynamicPaths() {
+		// Local dynamic mode with separate before/after path templates
+		return r.buildManifestsLocalDynamic(ctx)
+	}
+	if r.Options.UseDynamicPaths() {
+		// Shared dynamic mode: use the before/after paths directly as roots
+		return r.BuildManifests(r.Options.LcBeforeManifestsPath, r.Options.LcAfterManifestsPath)
+	}
+	// Legacy mode: append service name to paths
+	beforePath := filepath.Join(r.Options.LcBeforeManifestsPath, r.Options.Service)
+	afterPath := filepath.Join(r.Options.LcAfterManifestsPath, r.Options.Service)
+	return r.BuildManifests(beforePath, afterPath)
 }
 
 // buildManifestsLocalDynamic handles local mode with separate before/after path templates
@@ -114,6 +142,15 @@ func (r *RunnerLocal) buildManifestsLocalDynamic(ctx context.Context) (*models.B
 
 	logger.Info("BuildManifestsLocalDynamic: starting...")
 
+	// Expand glob/regex values against both trees, so that both sides share the same overlays
+	expanded, err := r.Options.BeforePathBuilder.ExpandPatterns(
+		r.Options.BeforePathBuilder.Template, r.Options.AfterPathBuilder.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand kustomize build values: %w", err)
+	}
+	r.Options.BeforePathBuilder.Variables = expanded.Variables
+	r.Options.AfterPathBuilder.Variables = expanded.Variables
+
 	// Generate paths from before path builder
 	beforeCombos, err := r.Options.BeforePathBuilder.GenerateAllPaths()
 	if err != nil {
@@ -138,6 +175,12 @@ func (r *RunnerLocal) buildManifestsLocalDynamic(ctx context.Context) (*models.B
 		beforePathMap[combo.OverlayKey] = combo.Path
 	}
 
+	// Both builders share the variables, so the values of an overlay key are the same on both sides
+	valuesMap := make(map[string]map[string]string)
+	for _, combo := range append(beforeCombos, afterCombos...) {
+		valuesMap[combo.OverlayKey] = combo.Values
+	}
+
 	// Collect all unique overlay keys from both before and after
 	allOverlayKeys := make(map[string]bool)
 	for _, combo := range beforeCombos {
@@ -159,6 +202,10 @@ func (r *RunnerLocal) buildManifestsLocalDynamic(ctx context.Context) (*models.B
 			overlayKeys = append(overlayKeys, afterCombo.OverlayKey)
 		}
 	}
+	overlayKeys, skippedOverlays, err := r.Options.selectOverlays(overlayKeys)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, overlayKey := range overlayKeys {
 		comboCtx, comboSpan := trace.StartSpan(ctx, fmt.Sprintf("BuildManifests.%s", overlayKey))
@@ -168,20 +215,32 @@ func (r *RunnerLocal) buildManifestsLocalDynamic(ctx context.Context) (*models.B
 
 		// Build before manifest
 		logger.WithField("overlayKey", overlayKey).WithField("beforePath", beforePath).Info("Building before manifest...")
+		buildStart := time.Now()
 		beforeManifest, beforeErr := r.Builder.BuildAtFullPath(comboCtx, beforePath)
+		recordDuration(r.Timings.BuildMs, overlayKey, buildStart)
 		beforeNotFound := beforeErr != nil && errors.Is(beforeErr, kustomize.ErrOverlayNotFound)
 		if beforeErr != nil && !beforeNotFound {
 			comboSpan.End()
-			return nil, beforeErr
+			if !r.Options.ContinueOnError() {
+				return nil, beforeErr
+			}
+			results[overlayKey] = failedBuild(overlayKey, fmt.Errorf("failed to build the before manifest: %w", beforeErr))
+			continue
 		}
 
 		// Build after manifest
 		logger.WithField("overlayKey", overlayKey).WithField("afterPath", afterPath).Info("Building after manifest...")
+		buildStart = time.Now()
 		afterManifest, afterErr := r.Builder.BuildAtFullPath(comboCtx, afterPath)
+		recordDuration(r.Timings.BuildMs, overlayKey, buildStart)
 		afterNotFound := afterErr != nil && errors.Is(afterErr, kustomize.ErrOverlayNotFound)
 		if afterErr != nil && !afterNotFound {
 			comboSpan.End()
-			return nil, afterErr
+			if !r.Options.ContinueOnError() {
+				return nil, afterErr
+			}
+			results[overlayKey] = failedBuild(overlayKey, fmt.Errorf("failed to build the after manifest: %w", afterErr))
+			continue
 		}
 
 		// Handle different scenarios
@@ -209,24 +268,43 @@ func (r *RunnerLocal) buildManifestsLocalDynamic(ctx context.Context) (*models.B
 			afterManifest = []byte{} // Treat as empty manifest
 		}
 
-		results[overlayKey] = models.BuildEnvManifestResult{
+		buildEnvResult := models.BuildEnvManifestResult{
 			OverlayKey:     overlayKey,
 			Environment:    overlayKey,
 			FullBuildPath:  afterPath, // Store the after path
+			Variables:      valuesMap[overlayKey],
 			BeforeManifest: beforeManifest,
 			AfterManifest:  afterManifest,
 			Skipped:        false,
 		}
+		if !beforeNotFound {
+			buildEnvResult.BeforeBuildPath = beforePath
+		}
+		if !afterNotFound {
+			buildEnvResult.AfterBuildPath = afterPath
+		}
+		results[overlayKey] = buildEnvResult
 		logger.WithField("overlayKey", overlayKey).Debug("Built Manifest")
 
 		comboSpan.End()
 	}
 
-	logger.Info("BuildManifestsLocalDynamic: done.")
-	return &models.BuildManifestResult{
+	rs := &models.BuildManifestResult{
 		EnvManifestBuild: results,
 		OverlayKeys:      overlayKeys, // Preserve the order
-	}, nil
+		SkippedOverlays:  skippedOverlays,
+	}
+	if err := r.extractProvenance(ctx, rs); err != nil {
+		return nil, err
+	}
+	if err := r.expandGitOpsResources(ctx, rs); err != nil {
+		return nil, err
+	}
+	r.detectComponents(ctx, rs)
+	r.detectConfigChanges(ctx, rs)
+
+	logger.Info("BuildManifestsLocalDynamic: done.")
+	return rs, nil
 }
 
 // buildReportData constructs the ReportData based on legacy or dynamic mode
@@ -235,12 +313,24 @@ func (r *RunnerLocal) buildReportData(
 	diffs map[string]models.EnvironmentDiff,
 	policyEval *models.PolicyEvaluation,
 ) models.ReportData {
+	linkPolicyViolations(r.FS, rs, diffs, policyEval)
 	reportData := models.ReportData{
+		SchemaVersion:    models.REPORT_SCHEMA_VERSION,
+		ServiceMetadata:  r.ServiceMetadata,
 		Timestamp:        time.Now(),
 		BaseCommit:       "base",
 		HeadCommit:       "head",
 		ManifestChanges:  diffs,
+		HighRiskChanges:  highRiskChangesOf(diffs),
 		PolicyEvaluation: *policyEval,
+		Timings:          r.timings(),
+		GitOpsResources:  gitopsResourcesOf(rs),
+		Components:       componentsOf(rs),
+		ConfigChanges:    configChangesOf(rs),
+		SkippedOverlays:  rs.SkippedOverlays,
+		Errors:           partialErrorsOf(rs, policyEval),
+		ExternalData:     r.Evaluator.ExternalDataRecords(),
+		Sources:          r.Options.Sources,
 	}
 
 	if r.Options.UseLocalDynamicPaths() {
@@ -277,6 +367,14 @@ func (r *RunnerLocal) buildReportData(
 		reportData.OverlayKeys = r.Options.Environments // For consistency
 	}
 
+	applyEnvironmentNames(&reportData, r.Evaluator.Config())
+	reportData.Timezone = r.Evaluator.Config().Timezone
+	reportData.Checklist = r.Evaluator.Checklist()
+	reportData.PolicyPacks = r.Evaluator.PolicyPacks()
+	reportData.DestructiveChanges = destructiveChangesOf(&reportData)
+	reportData.NextSteps = nextStepsOf(&reportData, r.Evaluator.Config())
+	reportData.ReviewerEscalations = reviewerEscalationsOf(&reportData, r.Evaluator.Config())
+	reportData.Profiles = profilesOf(&reportData, r.Evaluator.Config())
 	return reportData
 }
 
@@ -285,38 +383,35 @@ func (r *RunnerLocal) Output(data *models.ReportData) error {
 	defer span.End()
 
 	logger.Info("Output: starting...")
-	if err := r.outputReportJson(data); err != nil {
+	// The markdown goes first for the report files to have its render timing
+	if err := r.outputReportMarkdown(data); err != nil {
 		return err
 	}
-	if err := r.outputReportMarkdown(data); err != nil {
+	if err := r.outputReport(data); err != nil {
 		return err
 	}
-	logger.Info("Output: done.")
-	return nil
-}
-
-// Exporting report json file to output directory if enabled
-func (r *RunnerLocal) outputReportJson(data *models.ReportData) error {
-	if !r.Options.EnableExportReport {
-		logger.Info("OutputJson: option was disabled")
-		return nil
+	if err := r.outputPolicyCSV(data); err != nil {
+		return err
 	}
-	logger.Info("OutputJson: starting...")
-
-	if err := os.MkdirAll(r.Options.OutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	if err := r.outputPolicyJUnit(data); err != nil {
+		return err
 	}
-
-	resultsJson, err := json.Marshal(data)
-	if err != nil {
+	if err := r.outputHTMLReport(data); err != nil {
 		return err
 	}
-	filePath := filepath.Join(r.Options.OutputDir, "report.json")
-	if err := os.WriteFile(filePath, resultsJson, 0644); err != nil {
-		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write report data to file")
+	if err := r.outputMetrics(data); err != nil {
 		return err
 	}
-	logger.WithField("filePath", filePath).Info("Written report data to file")
+	if err := r.encryptArtifacts(); err != nil {
+		return err
+	}
+	if err := r.outputIndex(data); err != nil {
+		return err
+	}
+	if r.Options.LcPrintDiff {
+		r.outputTerminalDiff(data)
+	}
+	logger.Info("Output: done.")
 	return nil
 }
 
@@ -325,15 +420,17 @@ func (r *RunnerLocal) outputReportMarkdown(data *models.ReportData) error {
 	logger.Info("OutputMarkdown: starting...")
 
 	// Render the markdown using templates
-	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, data)
+	renderStart := time.Now()
+	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, template.NewTemplateData(data))
+	r.Timings.RenderMs = msSince(renderStart)
 	if err != nil {
 		logger.WithField("error", err).Error("Failed to render markdown template")
-		return err
+		return failure.Render(err)
 	}
 
 	// Write the rendered markdown to file
 	filePath := filepath.Join(r.Options.OutputDir, "report.md")
-	if err := os.WriteFile(filePath, []byte(renderedMarkdown), 0644); err != nil {
+	if err := perm.WriteFile(filePath, []byte(renderedMarkdown)); err != nil {
 		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write markdown report to file")
 		return err
 	}
@@ -341,3 +438,24 @@ func (r *RunnerLocal) outputReportMarkdown(data *models.ReportData) error {
 	logger.WithField("filePath", filePath).Info("Written markdown report to file")
 	return nil
 }
+
+// Printing the diffs to stdout, ANSI colored only for terminals so redirected output stays plain
+func (r *RunnerLocal) outputTerminalDiff(data *models.ReportData) {
+	sink := diff.DIFF_SINK_TEXT
+	if isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == "" && enableANSI(os.Stdout) {
+		sink = diff.DIFF_SINK_ANSI
+	}
+	for _, overlayKey := range data.OverlayKeys {
+		envDiff, ok := data.ManifestChanges[overlayKey]
+		if !ok || envDiff.LineCount == 0 {
+			continue
+		}
+		fmt.Printf("=== %s: %d lines (+%d/-%d)\n", overlayKey, envDiff.LineCount, envDiff.AddedLineCount, envDiff.DeletedLineCount)
+		fmt.Print(diff.RenderEnvironmentDiff(envDiff, sink))
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
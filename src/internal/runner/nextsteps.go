@@ -0,0 +1,161 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+)
+
+// nextStepsOf composes the "what to do next" footer from the report state, in a stable order:
+// failing blocking policies (with their override command if any), upcoming enforcement changes, then artifact links
+// It is assembled here rather than in templates so that every template and output gets the same wording
+func nextStepsOf(data *models.ReportData, cfg *models.ComplianceConfig) []models.NextStep {
+	overlayKeys := reportOverlayKeys(data)
+
+	results := map[string]models.PolicyResult{}
+	blockingEnvs, warningEnvs, recommendEnvs := map[string][]string{}, map[string][]string{}, map[string][]string{}
+	collect := func(policies []models.PolicyResult, envs map[string][]string, overlayKey string) {
+		for _, policy := range policies {
+			if policy.IsPassing {
+				continue
+			}
+			results[policy.PolicyId] = policy
+			envs[policy.PolicyId] = append(envs[policy.PolicyId], data.DisplayName(overlayKey))
+		}
+	}
+	for _, overlayKey := range overlayKeys {
+		matrix := data.PolicyEvaluation.PolicyMatrix[overlayKey]
+		collect(matrix.BlockingPolicies, blockingEnvs, overlayKey)
+		collect(matrix.WarningPolicies, warningEnvs, overlayKey)
+		collect(matrix.RecommendPolicies, recommendEnvs, overlayKey)
+	}
+	policyIDs := orderedPolicyIDs(cfg, results)
+
+	var steps []models.NextStep
+	for _, id := range policyIDs {
+		envs, result := blockingEnvs[id], results[id]
+		if len(envs) == 0 {
+			continue
+		}
+		if cfg != nil && cfg.Policies[id].Type == policy.POLICY_TYPE_MANUAL {
+			steps = append(steps, models.NextStep{
+				Kind: models.NextStepChecklist,
+				Text: fmt.Sprintf("Tick **%s** in the checklist once done (blocking in %s)", result.PolicyName, formatEnvs(envs)),
+			})
+			continue
+		}
+		if result.OverrideCommand == "" {
+			steps = append(steps, models.NextStep{
+				Kind: models.NextStepFix,
+				Text: fmt.Sprintf("Fix **%s** (blocking in %s), it can't be overridden", result.PolicyName, formatEnvs(envs)),
+			})
+			continue
+		}
+		command := result.OverrideCommand
+		if cfg != nil && cfg.OverrideTicketRequired(id) {
+			command += " <ticket>"
+		}
+		steps = append(steps, models.NextStep{
+			Kind: models.NextStepOverride,
+			Text: fmt.Sprintf("Fix **%s** (blocking in %s), or comment `%s` to override it (anyone who can comment on the PR)",
+				result.PolicyName, formatEnvs(envs), command),
+		})
+	}
+
+	if cfg != nil {
+		evaluatedAt := data.Timestamp
+		if data.PolicyEvaluation.EvaluatedAt != nil {
+			evaluatedAt = *data.PolicyEvaluation.EvaluatedAt
+		}
+		for _, id := range policyIDs {
+			enforcement := cfg.Policies[id].Enforcement
+			policy := results[id]
+			switch {
+			case len(warningEnvs[id]) > 0 && enforcement.IsBlockingAfter != nil && enforcement.IsBlockingAfter.After(evaluatedAt):
+				steps = append(steps, models.NextStep{
+					Kind: models.NextStepEnforcement,
+					Text: fmt.Sprintf("**%s** fails in %s and becomes blocking on %s",
+						policy.PolicyName, formatEnvs(warningEnvs[id]), data.FormatDate(*enforcement.IsBlockingAfter)),
+				})
+			case len(recommendEnvs[id]) > 0 && enforcement.IsWarningAfter != nil && enforcement.IsWarningAfter.After(evaluatedAt):
+				steps = append(steps, models.NextStep{
+					Kind: models.NextStepEnforcement,
+					Text: fmt.Sprintf("**%s** fails in %s and becomes a warning on %s",
+						policy.PolicyName, formatEnvs(recommendEnvs[id]), data.FormatDate(*enforcement.IsWarningAfter)),
+				})
+			}
+		}
+	}
+
+	for _, overlayKey := range overlayKeys {
+		envDiff := data.ManifestChanges[overlayKey]
+		isArtifact := envDiff.ContentType == models.DiffContentTypeGHArtifact || envDiff.ContentType == models.DiffContentTypeRedacted
+		if isArtifact && envDiff.Content != "" {
+			steps = append(steps, models.NextStep{
+				Kind: models.NextStepArtifact,
+				Text: fmt.Sprintf("Review the full diff of `%s` [in the workflow run's artifacts](%s)", data.DisplayName(overlayKey), envDiff.Content),
+			})
+		}
+	}
+	return steps
+}
+
+// applyEnvironmentNames orders the overlays of the report and sets their display names, per the
+// environments of the compliance config
+func applyEnvironmentNames(data *models.ReportData, cfg *models.ComplianceConfig) {
+	if cfg == nil || len(cfg.Environments) == 0 {
+		return
+	}
+	data.OverlayKeys = cfg.SortOverlayKeys(data.OverlayKeys)
+	data.Environments = cfg.SortOverlayKeys(data.Environments)
+	for _, overlayKey := range data.OverlayKeys {
+		if name := cfg.DisplayNameOf(overlayKey); name != overlayKey {
+			if data.DisplayNames == nil {
+				data.DisplayNames = make(map[string]string)
+			}
+			data.DisplayNames[overlayKey] = name
+		}
+	}
+}
+
+// reportOverlayKeys returns the overlay keys of the report, in report order when known
+func reportOverlayKeys(data *models.ReportData) []string {
+	if len(data.OverlayKeys) > 0 {
+		return data.OverlayKeys
+	}
+	keys := make([]string, 0, len(data.PolicyEvaluation.PolicyMatrix))
+	for key := range data.PolicyEvaluation.PolicyMatrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// orderedPolicyIDs returns the IDs of results in the compliance config order, unknown IDs last
+func orderedPolicyIDs(cfg *models.ComplianceConfig, results map[string]models.PolicyResult) []string {
+	ids := make([]string, 0, len(results))
+	seen := map[string]bool{}
+	if cfg != nil {
+		for _, id := range cfg.PolicyIDs {
+			if _, ok := results[id]; ok {
+				ids = append(ids, id)
+				seen[id] = true
+			}
+		}
+	}
+	var rest []string
+	for id := range results {
+		if !seen[id] {
+			rest = append(rest, id)
+		}
+	}
+	sort.Strings(rest)
+	return append(ids, rest...)
+}
+
+func formatEnvs(envs []string) string {
+	return "`" + strings.Join(envs, "`, `") + "`"
+}
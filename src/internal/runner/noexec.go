@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+)
+
+// ExecRequirements lists the enabled features that spawn external processes, with the binary they need
+// Used by --no-exec to fail at startup, before anything runs, with everything left to disable or replace
+func (o *Options) ExecRequirements() []string {
+	var requirements []string
+	if o.KustomizeEngine == kustomize.KUSTOMIZE_ENGINE_BINARY {
+		requirements = append(requirements, "--kustomize-engine binary: `kustomize` binary (use --kustomize-engine krusty)")
+	}
+	if o.PolicyEngine != policy.POLICY_ENGINE_EMBEDDED && !o.NoPolicies {
+		requirements = append(requirements, "--policy-engine "+o.PolicyEngine+": `conftest` binary (use --policy-engine embedded)")
+	}
+	if o.DiffEngine != diff.DIFF_ENGINE_NATIVE && o.DiffStyle != diff.DIFF_STYLE_DYFF {
+		requirements = append(requirements, "--diff-engine "+o.DiffEngine+": `diff` binary (use --diff-engine native)")
+	}
+	if o.KustomizePlugins.Enabled {
+		requirements = append(requirements, "--kustomize-enable-plugins: the plugin binaries spawned by kustomize, and `docker` for containerized KRM functions")
+	}
+	if o.KustomizeHelm.Enabled {
+		requirements = append(requirements, "--kustomize-enable-helm: `helm` binary for the helmCharts inflation")
+	}
+	if o.SOPS == kustomize.SOPS_MODE_DECRYPT {
+		requirements = append(requirements, "--sops decrypt: `sops` binary")
+	}
+	if o.RenderGitOpsResources {
+		requirements = append(requirements, "--render-gitops-resources: `helm` binary for HelmRelease rendering")
+	}
+	if o.EncryptArtifacts != "" {
+		requirements = append(requirements, "--encrypt-artifacts "+o.EncryptArtifacts+": `"+o.EncryptArtifacts+"` binary")
+	}
+	if o.LintPolicies {
+		requirements = append(requirements, "--lint-policies: `opa` binary")
+	}
+	if o.LintRegal {
+		requirements = append(requirements, "--lint-regal: `regal` binary")
+	}
+	if o.RunMode == "github" || o.RunMode == "gitlab" || o.RunMode == "bitbucket" {
+		requirements = append(requirements, o.RunMode+" mode checkout: `git` binary")
+		if o.GitLFS {
+			requirements = append(requirements, "--git-lfs: `git-lfs` binary")
+		}
+	}
+	return requirements
+}
@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/bitbucket"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitlab"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/notify"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+)
+
+const (
+	// NOTIFY_ON_FAILURE notifies the runs that fail, or whose report does not pass
+	NOTIFY_ON_FAILURE = "failure"
+	// NOTIFY_ON_ALWAYS notifies every run
+	NOTIFY_ON_ALWAYS = "always"
+
+	// The SCM API calls of all the runs of a dispatcher share a rate limit per SCM, the token's
+	NOTIFY_GITHUB_API    = "github:api"
+	NOTIFY_GITLAB_API    = "gitlab:api"
+	NOTIFY_BITBUCKET_API = "bitbucket:api"
+
+	// NOTIFY_SCM_INTERVAL spaces the comments published, below the secondary rate limits of the SCM APIs
+	NOTIFY_SCM_INTERVAL = time.Second
+	// NOTIFY_BATCH_WINDOW gathers the notifications of a destination in one Slack message or webhook call
+	NOTIFY_BATCH_WINDOW = 5 * time.Second
+	// NOTIFY_MAX_BATCH bounds the notifications of a Slack message or webhook call
+	NOTIFY_MAX_BATCH = 50
+)
+
+var NOTIFY_ON_MODES = []string{NOTIFY_ON_FAILURE, NOTIFY_ON_ALWAYS}
+
+// NewNotifier creates the dispatcher of the SCM comments and of the notifications enabled by the options, one is
+// shared by all the runs of a server so that their calls share the rate limits
+func (o *Options) NewNotifier() (*notify.Dispatcher, error) {
+	scm := notify.Route{Interval: NOTIFY_SCM_INTERVAL, MaxAttempts: 3, Backoff: 5 * time.Second, MaxWait: time.Minute, Retryable: scmRetryable}
+	routes := map[string]notify.Route{"github": scm, "gitlab": scm, "bitbucket": scm}
+	if o.NotifySlack {
+		sender, err := notify.NewSlackSender()
+		if err != nil {
+			return nil, err
+		}
+		// Slack allows about one message per second per channel
+		routes[notify.KIND_SLACK] = notify.Route{Sender: sender, Interval: time.Second, BatchWindow: NOTIFY_BATCH_WINDOW,
+			MaxBatch: NOTIFY_MAX_BATCH, MaxAttempts: 3, Backoff: 2 * time.Second, MaxWait: time.Minute, Retryable: notify.RetryableHTTP}
+	}
+	if len(o.NotifyWebhooks) > 0 {
+		routes[notify.KIND_WEBHOOK] = notify.Route{Sender: notify.NewWebhookSender(), BatchWindow: NOTIFY_BATCH_WINDOW,
+			MaxBatch: NOTIFY_MAX_BATCH, MaxAttempts: 3, Backoff: 2 * time.Second, MaxWait: time.Minute, Retryable: notify.RetryableHTTP}
+	}
+	return notify.NewDispatcher(routes), nil
+}
+
+// scmRetryable retries the SCM API calls throttled by GitHub, GitLab or Bitbucket, the other failures are not:
+// retrying a comment that may have been created would duplicate it
+func scmRetryable(err error) (time.Duration, bool) {
+	if after, ok := github.RetryAfter(err); ok {
+		return after, true
+	}
+	if after, ok := gitlab.RetryAfter(err); ok {
+		return after, true
+	}
+	return bitbucket.RetryAfter(err)
+}
+
+// publish runs an SCM API call of the outputs in the rate limit of its destination, at once without a notifier
+func (r *RunnerBase) publish(destination string, call func() error) error {
+	if r.Options.Notifier == nil {
+		return call()
+	}
+	return r.Options.Notifier.Do(r.Context, destination, call)
+}
+
+// notifyOutcome posts the outcome of the run of a pull request to the Slack channel of the service and to the
+// webhooks, data is nil if the run failed before building the report. A notification that cannot be posted is logged
+func (r *RunnerBase) notifyOutcome(pullRequest string, data *models.ReportData, runErr error) {
+	if r.Options.Notifier == nil || (!r.Options.NotifySlack && len(r.Options.NotifyWebhooks) == 0) {
+		return
+	}
+	status, title := template.STATUS_ERROR, ""
+	if data != nil {
+		td := template.NewTemplateData(data)
+		status, title = td.OverallStatus, td.StatusTitle
+	}
+	if runErr != nil {
+		status, title = template.STATUS_ERROR, runErr.Error()
+	}
+	if status == template.STATUS_PASS && r.Options.NotifyOn != NOTIFY_ON_ALWAYS {
+		return
+	}
+
+	service := r.Options.serviceIdentifier()
+	text := fmt.Sprintf("[%s] %s (%s): %s", status, pullRequest, service, title)
+	document := map[string]string{"pullRequest": pullRequest, "service": service, "status": status, "title": title}
+	var destinations []string
+	if r.Options.NotifySlack && r.ServiceMetadata != nil && r.ServiceMetadata.SlackChannel != "" {
+		destinations = append(destinations, notify.KIND_SLACK+":"+r.ServiceMetadata.SlackChannel)
+	}
+	for _, url := range r.Options.NotifyWebhooks {
+		destinations = append(destinations, notify.KIND_WEBHOOK+":"+url)
+	}
+	for _, destination := range destinations {
+		msg := notify.Message{Destination: destination, Text: text, Data: document}
+		if err := r.Options.Notifier.Post(r.Context, msg); err != nil {
+			logger.WithField("destination", destination).WithField("error", err).Warn("Failed to post notification")
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/source"
+)
+
+// OFFLINE_DISABLED_FEATURES are the features reaching hosts other than the SCM API, and how --offline affects them
+var OFFLINE_DISABLED_FEATURES = []string{
+	"externalData sources of the compliance config: served from a fresh --external-data-cache-dir cache, else the run fails",
+	"--decision-log http(s) sinks: rejected at startup, use a file sink",
+	"kustomize remote resources and bases (URLs, git repositories): the build fails",
+	"--render-gitops-resources: HelmRelease charts of remote repositories fail to render",
+	"--git-submodules: submodules hosted outside the SCM fail the checkout",
+	"--https-proxy: rejected at startup, the SCM API must be reachable directly",
+	"--notify-slack and --notify-webhook: rejected at startup",
+	"git and OCI --policies-path and --templates-path sources of the server mode: rejected at startup",
+}
+
+// OfflineConflicts lists the enabled features that cannot work without network access
+// Used by --offline to fail at startup instead of on the first blocked call
+func (o *Options) OfflineConflicts() []string {
+	var conflicts []string
+	if strings.HasPrefix(o.DecisionLog, "http://") || strings.HasPrefix(o.DecisionLog, "https://") {
+		conflicts = append(conflicts, "--decision-log "+o.DecisionLog+": http(s) sink (use a file sink)")
+	}
+	if o.NotifySlack {
+		conflicts = append(conflicts, "--notify-slack: posts to the Slack API")
+	}
+	for _, url := range o.NotifyWebhooks {
+		conflicts = append(conflicts, "--notify-webhook "+url+": posts to the webhook")
+	}
+	for _, flag := range [][2]string{{"--policies-path", o.PoliciesPath}, {"--templates-path", o.TemplatesPath}} {
+		if flag[0] == "--policies-path" && o.NoPolicies {
+			continue
+		}
+		if strings.HasPrefix(flag[1], source.GIT_PREFIX+"http") || strings.HasPrefix(flag[1], source.OCI_PREFIX) {
+			conflicts = append(conflicts, flag[0]+" "+flag[1]+": fetched from a remote source")
+		}
+	}
+	if o.KustomizePlugins.Network {
+		conflicts = append(conflicts, "--kustomize-fn-network: network access of the containerized KRM functions")
+	}
+	return conflicts
+}
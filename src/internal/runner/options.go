@@ -1,6 +1,21 @@
 package runner
 
-import "github.com/gh-nvat/gitops-kustomzchk/src/pkg/pathbuilder"
+import (
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/bitbucket"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitlab"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/notify"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/pathbuilder"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/transcript"
+)
 
 type GitCheckoutStrategy string
 
@@ -9,22 +24,98 @@ const (
 	GitCheckoutStrategyShallow GitCheckoutStrategy = "shallow"
 )
 
+// OnErrorMode is what a run does when an overlay fails to build or a policy fails to evaluate
+type OnErrorMode string
+
+const (
+	OnErrorContinue OnErrorMode = "continue" // report the failed parts along with the results of the others
+	OnErrorAbort    OnErrorMode = "abort"    // fail the whole run on the first error
+)
+
 type Options struct {
 	// Run mode
-	RunMode string // "github" or "local"
+	RunMode string // "github", "gitlab", "bitbucket" or "local"
 	Debug   bool   // Debug mode
 
 	// Common options
 	PoliciesPath                  string
 	TemplatesPath                 string
 	OutputDir                     string
+	OutputFileMode                string // Octal mode of the files written to the output dirs (default 0644)
+	OutputDirMode                 string // Octal mode of the output dirs created (default 0755)
+	Umask                         string // Octal process umask, empty keeps the inherited one
+	OutputRequireOwner            bool   // Refuse to write to output dirs owned by another user
 	EnableExportReport            bool
+	ReportFormats                 []string // Formats (report.FORMATS) the report is exported in, to report.<format>
+	ReportPretty                  bool     // Indent the JSON report
+	EnableExportCSV               bool     // Export the policy matrix as a flat CSV (policies.csv) for spreadsheet analysis
+	EnableExportHTML              bool     // Export a standalone HTML report (report.html) to share as a CI artifact
+	EnableExportJUnit             bool     // Export the policy matrix as a JUnit XML report (junit.xml) for the CI test report UIs
+	MetricsTextfileDir            string   // node_exporter textfile collector directory to write the run metrics to, empty to disable
+	DecisionLog                   string   // File or http(s) URL receiving an OPA decision log event per policy evaluation
+	DecisionLogOmitInput          bool     // Leave the evaluated manifests out of the decision log events
 	EnableExportPerformanceReport bool
-	FailOnOverlayNotFound         bool // Fail if overlay doesn't exist (default: false, skip gracefully)
+	ProfileCPU                    bool   // Write a pprof CPU profile of the run to the output dir
+	ProfileMem                    bool   // Write a pprof memory (allocations) profile of the run to the output dir
+	FailOnOverlayNotFound         bool   // Fail if overlay doesn't exist (default: false, skip gracefully)
+	ExternalDataCacheDir          string // Cache directory for externalData sources of compliance-config (default: temp dir)
+	RenderGitOpsResources         bool   // Render HelmRelease/ApplicationSet found in built manifests and evaluate their outputs
+	ComponentProvenance           bool   // Annotate built resources with the kustomize components that created or patched them
+	Provenance                    bool   // Track the source file of each built resource, to map diffs and violations back to it
+	SOPS                          string // Handling of the SOPS-encrypted resources (kustomize.SOPS_MODES), empty leaves them as built
+	KustomizePlugins              kustomize.PluginOptions
+	KustomizeHelm                 kustomize.HelmOptions
+	KustomizeEngine               string   // "krusty" (the kustomize API in-process) or "binary" (a kustomize process per build)
+	KustomizeLoadRestrictor       string   // kustomize.LOAD_RESTRICTORS, LoadRestrictionsRootOnly by default
+	NoManifestContentInComment    bool     // Never publish manifest content (diffs, resource names, policy messages) in SCM comments
+	RunLock                       bool     // Lease the tool comment of each service to the newest run, the older runs of the PR stop without publishing
+	PublishTranscript             bool     // Record every SCM mutation of the run (comment bodies, labels, check runs) to transcript.json in the output dir
+	NotifySlack                   bool     // Post the outcome to the slackChannel of the service.yaml, with SLACK_BOT_TOKEN
+	NotifyWebhooks                []string // POST the outcome as JSON to these URLs
+	NotifyOn                      string   // Outcomes notified: NOTIFY_ON_FAILURE (not passing) or NOTIFY_ON_ALWAYS
+	DiffEngine                    string   // "external" (system diff) or "native" (pure Go, same output)
+	DiffStyle                     string   // "unified" (line by line) or "dyff" (path by path of each resource)
+	PolicyEngine                  string   // "conftest" (a process per policy) or "embedded" (OPA in-process, same results)
+	NoPolicies                    bool     // Diff-only run: no compliance config is read, no external data fetched and no policy evaluated
+	NoExec                        bool     // Refuse to run if any enabled feature spawns an external process
+	EvaluateAt                    string   // RFC3339 time to evaluate enforcement levels at instead of now [local mode]
+	OnError                       OnErrorMode
+	LintPolicies                  bool     // Run `opa check --strict` over the policies before evaluating anything
+	LintRegal                     bool     // Also run the Regal linter in the lint stage
+	ExportInventory               []string // Formats (inventory.FORMATS) of the resource inventory of the after manifests written to the output dir
+	InventoryLabels               []string // Label keys kept in the inventory
+	ExportManifests               bool     // Write the built before/after manifests of each overlay to manifests/ in the output dir
+	ExportManifestsGzip           bool     // Gzip the exported manifests
+	EncryptArtifacts              string   // "age" or "gpg" to encrypt the exported files for the artifactEncryption recipients, empty to disable
+
+	// Bound the number of overlays checked per run, 0 disables the limit. Above it the run fails,
+	// unless sampling keeps the always-checked overlays and an even sample of the others
+	MaxEnvironments         int
+	MaxManifestBytes        int64 // Bound the size of each built manifest, above it the overlay fails (0: no limit)
+	MaxResources            int   // Bound the resource count of each built manifest, above it the overlay fails (0: no limit)
+	SampleEnvironments      bool
+	AlwaysCheckEnvironments []string // glob patterns of prod-class overlay keys (or key segments), never sampled out
+
+	// Runtime overlay parameters injected on every kustomize build (e.g. NAMESPACE=pr-123 for preview environments)
+	BuildArgs map[string]string
+
+	// Resource limits for external processes (conftest, kustomize), 0 disables a limit
+	ExecTimeout        time.Duration
+	ExecMaxOutputBytes int64
+	ExecMaxMemoryMB    uint64
+	ExecMaxCPUSeconds  uint64
+
+	// Network access of all outbound calls (GitHub API, git, external data, decision logs), empty keeps the environment
+	HTTPSProxy string
+	NoProxy    string
+	CABundle   string
+	Offline    bool // Forbid all egress but to the SCM API (none in local mode)
 
 	// === Legacy flags (v0.4 backward compatibility) ===
-	Service      string   // Deprecated: use KustomizeBuildPath + KustomizeBuildValues
-	Environments []string // Deprecated: use KustomizeBuildPath + KustomizeBuildValues
+	Service            string   // Deprecated: use KustomizeBuildPath + KustomizeBuildValues
+	Services           []string // Services of --service, checked in turn with Service set to each one when several
+	AutoDetectServices bool     // Check the services of --manifests-path the PR changes files of, set as Services [github mode]
+	Environments       []string // Deprecated: use KustomizeBuildPath + KustomizeBuildValues
 
 	// === New dynamic path flags (v0.5+) ===
 	// For GitHub mode: single path template (before/after determined by git refs)
@@ -36,16 +127,49 @@ type Options struct {
 	BeforePathBuilder *pathbuilder.PathBuilder // For local mode with separate before path
 	AfterPathBuilder  *pathbuilder.PathBuilder // For local mode with separate after path
 
+	// Recorder of the SCM mutations with PublishTranscript, set by the CLI for the clients of the run
+	Transcript *transcript.Transcript
+	// Dispatcher of the comments and notifications, rate-limited and batched, set by the CLI and shared by the runs of a server
+	Notifier *notify.Dispatcher
+	// Versions of the policies and templates of the run, set by the server mode and recorded in the reports
+	Sources *models.Sources
+
 	// GitHub mode options
-	GhRepo              string
-	GhPrNumber          int
-	ManifestsPath       string              // Path to services directory (default: ./services)
-	GitCheckoutStrategy GitCheckoutStrategy // Git checkout strategy: sparse (scoped) or shallow (all files)
+	GhRepo                  string
+	GhToken                 string // GitHub token of the run, set by the server mode for the tenant of the repository; empty reads GH_TOKEN
+	GhPrNumber              int
+	ManifestsPath           string               // Path to services directory (default: ./services)
+	GitCheckoutStrategy     GitCheckoutStrategy  // Git checkout strategy: sparse (scoped) or shallow (all files)
+	GitSubmodules           bool                 // Initialize the submodules of the checked out path
+	GitLFS                  bool                 // Pull the Git LFS objects of the checked out path
+	InMemoryCheckouts       bool                 // Keep the checkouts in memory (fsys.Mem) instead of on disk
+	EvaluationCacheDir      string               // Cache the evaluations per PR, for override comments to re-apply the enforcement levels only
+	CommentEvent            *github.CommentEvent // Comment that triggered the run, set by the server mode; nil reads GITHUB_EVENT_PATH
+	GhSuggestionComments    bool                 // Post the policy fixes mapping to a source line as review comments with suggested changes
+	GhCheckRun              bool                 // Create a check run of the head commit, failing on blocking failures, to require in branch protection
+	GhStepSummary           string               // Write the report to $GITHUB_STEP_SUMMARY: GH_STEP_SUMMARY_OFF, GH_STEP_SUMMARY_APPEND or GH_STEP_SUMMARY_ONLY
+	AutoFix                 string               // Push the fixes of the autoFix policies: "" (disabled), "commit" or "pr" (models.AutoFixMode*)
+	AutoMerge               string               // Merge gate action on low-risk PRs: "" (disabled), "enable" or "label" (models.AutoMergeMode*)
+	AutoMergeMethod         string               // Merge method of the enabled auto-merge: merge, squash or rebase
+	AutoMergeLabel          string               // Label added to low-risk PRs in label mode
+	AutoMergeMaxDiffLines   int                  // Maximum manifest diff lines of a low-risk PR, 0 for no limit
+	AutoMergeImageBumpsOnly bool                 // Only routine image bumps are low-risk PRs
+
+	// GitLab mode options, the checkout options of the GitHub mode apply
+	GlProject string // Project path, e.g. "group/subgroup/project"
+	GlMrIid   int    // Merge request iid, its number in the project
+
+	// Bitbucket Cloud mode options, the checkout options of the GitHub mode apply
+	BbRepo string // Repository, e.g. "workspace/repo-slug"
+	BbPrId int    // Pull request id
 
 	// Local mode options (legacy)
 	LcBeforeManifestsPath string
 	LcAfterManifestsPath  string
 
+	// Print the diffs to stdout, colored when it is a terminal [local mode]
+	LcPrintDiff bool
+
 	// Local mode options (v0.5+ dynamic paths)
 	LcBeforeKustomizeBuildPath string // Template for before path (e.g., "/path/before/services/$SERVICE/$ENV")
 	LcAfterKustomizeBuildPath  string // Template for after path (e.g., "/path/after/services/$SERVICE/$ENV")
@@ -98,3 +222,65 @@ func (o *Options) InitializePathBuilder() error {
 	}
 	return nil
 }
+
+// ExecLimits returns the sandbox limits configured for external processes
+func (o *Options) ExecLimits() sandbox.Limits {
+	return sandbox.Limits{
+		Timeout:        o.ExecTimeout,
+		MaxOutputBytes: o.ExecMaxOutputBytes,
+		MaxMemoryBytes: o.ExecMaxMemoryMB << 20,
+		MaxCPUSeconds:  o.ExecMaxCPUSeconds,
+	}
+}
+
+// NetworkConfig returns the proxy, CA and offline configuration of the outbound calls
+func (o *Options) NetworkConfig() httpclient.Config {
+	cfg := httpclient.Config{
+		HTTPSProxy: o.HTTPSProxy,
+		NoProxy:    o.NoProxy,
+		CABundle:   o.CABundle,
+		Offline:    o.Offline,
+	}
+	if o.Offline && o.RunMode == "github" {
+		cfg.AllowedHosts = github.API_HOSTS
+	}
+	if o.Offline && o.RunMode == "gitlab" {
+		cfg.AllowedHosts = gitlab.APIHosts()
+	}
+	if o.Offline && o.RunMode == "bitbucket" {
+		cfg.AllowedHosts = bitbucket.API_HOSTS
+	}
+	return cfg
+}
+
+// PermConfig returns the permissions of the written files and directories
+func (o *Options) PermConfig() perm.Config {
+	return perm.Config{
+		FileMode:     o.OutputFileMode,
+		DirMode:      o.OutputDirMode,
+		Umask:        o.Umask,
+		RequireOwner: o.OutputRequireOwner,
+	}
+}
+
+// checkoutOptions returns the options of the github, gitlab and bitbucket mode checkouts
+func (o *Options) checkoutOptions() github.CheckoutOptions {
+	return github.CheckoutOptions{
+		Strategy:   string(o.GitCheckoutStrategy),
+		Submodules: o.GitSubmodules,
+		LFS:        o.GitLFS,
+	}
+}
+
+// CheckoutFS returns a new filesystem for the checkouts, in memory with --in-memory-checkouts, nil for the disk
+func (o *Options) CheckoutFS() fsys.FS {
+	if !o.InMemoryCheckouts {
+		return nil
+	}
+	return fsys.NewMem()
+}
+
+// ContinueOnError returns true if failed overlay builds and policy evaluations are reported instead of aborting the run
+func (o *Options) ContinueOnError() bool {
+	return o.OnError != OnErrorAbort
+}
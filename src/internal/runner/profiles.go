@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// ErrPolicyCheckFailed is returned by Process, after the outputs were written, when a policy fails
+// at or above the failOn level of its environment profile
+var ErrPolicyCheckFailed = errors.New("policy check failed")
+
+// profilesOf maps each overlay key of the report to the name of its profile, nil if no profile applies
+func profilesOf(data *models.ReportData, cfg *models.ComplianceConfig) map[string]string {
+	var results map[string]string
+	for _, overlayKey := range reportOverlayKeys(data) {
+		name, profile := cfg.ProfileFor(overlayKey)
+		if profile == nil {
+			continue
+		}
+		if results == nil {
+			results = make(map[string]string)
+		}
+		results[overlayKey] = name
+	}
+	return results
+}
+
+// enforceProfiles returns ErrPolicyCheckFailed (wrapped, with the failing environments) if any environment
+// fails the failOn level of its profile
+func enforceProfiles(data *models.ReportData, cfg *models.ComplianceConfig) error {
+	var failures []string
+	for _, overlayKey := range reportOverlayKeys(data) {
+		name, profile := cfg.ProfileFor(overlayKey)
+		if profile == nil {
+			continue
+		}
+		counts := data.PolicyEvaluation.EnvironmentSummary[overlayKey].PolicyCounts
+		failed := 0
+		switch profile.FailOn {
+		case models.FailOnRecommend:
+			failed += counts.RecommendFailedCount
+			fallthrough
+		case models.FailOnWarning:
+			failed += counts.WarningFailedCount
+			fallthrough
+		case models.FailOnBlock:
+			failed += counts.BlockingFailedCount
+		}
+		if failed > 0 {
+			failures = append(failures, fmt.Sprintf("%s: %d failing policies (profile %s, failOn %s)", overlayKey, failed, name, profile.FailOn))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%w: %s", ErrPolicyCheckFailed, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// diffLimitFor returns the max inline diff length of an overlay, from its profile or the default
+func diffLimitFor(cfg *models.ComplianceConfig, overlayKey string, defaultLimit int) int {
+	if _, profile := cfg.ProfileFor(overlayKey); profile != nil && profile.DiffLimit > 0 {
+		return profile.DiffLimit
+	}
+	return defaultLimit
+}
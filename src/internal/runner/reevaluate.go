@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/report"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+)
+
+// EVALUATION_CACHE_FILE_NAME is the cached evaluation of a pull request, in <cache dir>/<owner>/<repo>/<pr>/,
+// <cache dir>/<owner>/<repo>/<pr>/<service>/ for each service of a multi-service run
+const EVALUATION_CACHE_FILE_NAME = "evaluation.json"
+
+// evaluationCacheEntry is the evaluation of a full run, re-used by the runs of the override comments
+type evaluationCacheEntry struct {
+	PoliciesDigest        string            `json:"policiesDigest"`
+	ImageBumpEnvironments []string          `json:"imageBumpEnvironments,omitempty"`
+	OverlayFiles          map[string]string `json:"overlayFiles,omitempty"` // annotated by the check run
+	Report                json.RawMessage   `json:"report"`
+
+	report *models.ReportData
+}
+
+func (r *RunnerGitHub) evaluationCachePath() string {
+	dir := filepath.Join(r.options.EvaluationCacheDir, filepath.FromSlash(r.options.GhRepo), strconv.Itoa(r.options.GhPrNumber))
+	if r.options.multiService() {
+		dir = filepath.Join(dir, r.options.Service)
+	}
+	return filepath.Join(dir, EVALUATION_CACHE_FILE_NAME)
+}
+
+// writeEvaluationCache caches the evaluation of a run for the override comments, no-op without --evaluation-cache-dir.
+// A failure is logged only, the next comment runs a full evaluation
+func (r *RunnerGitHub) writeEvaluationCache(data *models.ReportData, imageBumpEnvs []string) {
+	if r.options.EvaluationCacheDir == "" {
+		return
+	}
+	lg := logger.WithField("path", r.evaluationCachePath())
+	if err := r.writeEvaluationCacheEntry(data, imageBumpEnvs); err != nil {
+		lg.WithField("error", err).Warn("Failed to cache the evaluation, override comments will run a full evaluation")
+		return
+	}
+	lg.Info("Cached the evaluation for override comments")
+}
+
+func (r *RunnerGitHub) writeEvaluationCacheEntry(data *models.ReportData, imageBumpEnvs []string) error {
+	digest, err := r.Evaluator.PoliciesDigest()
+	if err != nil {
+		return err
+	}
+	encoded, err := report.Encode(data, report.FORMAT_JSON, false)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(&evaluationCacheEntry{
+		PoliciesDigest: digest, ImageBumpEnvironments: imageBumpEnvs, OverlayFiles: r.overlayFiles, Report: encoded,
+	})
+	if err != nil {
+		return err
+	}
+	path := r.evaluationCachePath()
+	if err := perm.MkdirAll(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create evaluation cache directory: %w", err)
+	}
+	return perm.WriteFile(path, content)
+}
+
+// cachedReevaluation returns the cached evaluation to re-apply the enforcement levels of, when the run was
+// triggered by a new override comment on the pull request and the cache holds the evaluation of its current
+// commits and policies. nil runs a full evaluation
+func (r *RunnerGitHub) cachedReevaluation() *evaluationCacheEntry {
+	if r.options.EvaluationCacheDir == "" {
+		return nil
+	}
+	lg := logger.WithField("func", "RunnerGitHub.cachedReevaluation()")
+	event := r.options.CommentEvent
+	if event == nil {
+		var err error
+		if event, err = github.CommentEventFromEnv(); err != nil {
+			lg.WithField("error", err).Warn("Failed to read the comment event, running a full evaluation")
+			return nil
+		}
+	}
+	if event == nil || event.Action != github.COMMENT_ACTION_CREATED || !event.IsPullRequest ||
+		event.Number != r.options.GhPrNumber || !r.Evaluator.IsOverrideCommand(event.Body) {
+		return nil
+	}
+
+	lg = lg.WithField("path", r.evaluationCachePath()).WithField("user", event.User)
+	entry, err := r.loadEvaluationCache()
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		lg.Info("Override comment without cached evaluation, running a full evaluation")
+		return nil
+	case err != nil:
+		lg.WithField("error", err).Warn("Failed to load the cached evaluation, running a full evaluation")
+		return nil
+	case entry.report.HeadCommit != r.prInfo.HeadSHA || entry.report.BaseCommit != r.prInfo.BaseSHA:
+		lg.Info("Cached evaluation is of other commits, running a full evaluation")
+		return nil
+	}
+	if digest, err := r.Evaluator.PoliciesDigest(); err != nil || digest != entry.PoliciesDigest {
+		lg.Info("Cached evaluation is of other policies, running a full evaluation")
+		return nil
+	}
+	lg.Info("Override comment, re-applying the enforcement levels of the cached evaluation")
+	return entry
+}
+
+func (r *RunnerGitHub) loadEvaluationCache() (*evaluationCacheEntry, error) {
+	content, err := os.ReadFile(r.evaluationCachePath())
+	if err != nil {
+		return nil, err
+	}
+	var entry evaluationCacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cached evaluation: %w", err)
+	}
+	if entry.report, err = report.Parse(entry.Report); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// reevaluate re-applies the enforcement levels of a cached evaluation with the current comments of the pull
+// request, then publishes the outputs. Nothing is checked out, built nor evaluated
+func (r *RunnerGitHub) reevaluate(entry *evaluationCacheEntry) (*models.ReportData, error) {
+	_, span := trace.StartSpan(r.Context, "Reevaluate")
+	defer span.End()
+
+	data := entry.report
+	ghComments, err := r.currentComments()
+	if err != nil {
+		return nil, err
+	}
+	r.ServiceMetadata = data.ServiceMetadata
+	r.overlayFiles = entry.OverlayFiles
+	r.Evaluator.SetServiceMetadata(data.ServiceMetadata)
+	policyEval, err := r.Evaluator.ReapplyEnforcement(r.Context, data.PolicyEvaluation, entry.ImageBumpEnvironments, ghComments)
+	if err != nil {
+		return nil, failure.PolicyEngine(err)
+	}
+
+	cfg := r.Evaluator.Config()
+	data.PolicyEvaluation = *policyEval
+	data.Timestamp = time.Now()
+	data.Timings = r.timings()
+	data.Sources = r.Options.Sources
+	data.DestructiveChanges = destructiveChangesOf(data)
+	data.NextSteps = nextStepsOf(data, cfg)
+	data.ReviewerEscalations = reviewerEscalationsOf(data, cfg)
+	data.Profiles = profilesOf(data, cfg)
+
+	if err := r.Output(data); err != nil {
+		return data, err
+	}
+	r.writeEvaluationCache(data, entry.ImageBumpEnvironments)
+	return data, nil
+}
@@ -0,0 +1,163 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+)
+
+// ProcessReport builds, diffs and evaluates the manifests like Process, and returns the report data instead of
+// failing on the policies, for the reports consolidating several runs
+func (r *RunnerLocal) ProcessReport() (*models.ReportData, error) {
+	return r.process()
+}
+
+// ReleaseServices returns the services (directories with an environments directory) of any of the services
+// directories, sorted: a release can add or remove services
+func ReleaseServices(servicesDirs ...string) []string {
+	var services []string
+	for _, dir := range servicesDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // the services directory itself can be new in the release
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || slices.Contains(services, entry.Name()) {
+				continue
+			}
+			if info, err := os.Stat(filepath.Join(dir, entry.Name(), kustomize.KUSTOMIZE_OVERLAY_DIR_NAME)); err == nil && info.IsDir() {
+				services = append(services, entry.Name())
+			}
+		}
+	}
+	slices.Sort(services)
+	return services
+}
+
+// ReleaseServiceOf summarizes the report of a service run between the two tags of a release
+func ReleaseServiceOf(service string, data *models.ReportData) models.ReleaseService {
+	release := models.ReleaseService{Service: service}
+	for _, key := range data.OverlayKeys {
+		envDiff := data.ManifestChanges[key]
+		env := models.ReleaseEnvironment{
+			OverlayKey:       data.DisplayName(key),
+			AddedLineCount:   envDiff.AddedLineCount,
+			DeletedLineCount: envDiff.DeletedLineCount,
+		}
+		for _, change := range envDiff.ResourceChanges {
+			switch change.Action {
+			case models.ResourceChangeAdded:
+				env.AddedResources = append(env.AddedResources, change.ID)
+			case models.ResourceChangeRemoved:
+				env.RemovedResources = append(env.RemovedResources, change.ID)
+			default:
+				env.ModifiedResources = append(env.ModifiedResources, change.ID)
+			}
+			if change.HighRisk {
+				env.HighRiskResources = append(env.HighRiskResources, change.ID)
+			}
+		}
+		matrix := data.PolicyEvaluation.PolicyMatrix[key]
+		env.BlockingPolicies = failingPolicyNames(matrix.BlockingPolicies)
+		env.WarningPolicies = failingPolicyNames(matrix.WarningPolicies)
+		if envDiff.LineCount == 0 && len(envDiff.ResourceChanges) == 0 && len(env.BlockingPolicies) == 0 && len(env.WarningPolicies) == 0 {
+			continue // unchanged and compliant, nothing to review
+		}
+		release.Environments = append(release.Environments, env)
+	}
+	return release
+}
+
+func failingPolicyNames(results []models.PolicyResult) []string {
+	var names []string
+	for _, result := range results {
+		if !result.IsPassing {
+			names = append(names, result.PolicyName)
+		}
+	}
+	return names
+}
+
+// FormatReleaseMarkdown renders the release report for a change-advisory review
+func FormatReleaseMarkdown(report *models.ReleaseReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Changes from `%s` to `%s`\n\n", report.BaseTag, report.HeadTag)
+	fmt.Fprintf(&sb, "Commits `%s`..`%s`, %d service(s) checked.\n\n", shortSHA(report.BaseCommit), shortSHA(report.HeadCommit), len(report.Services))
+	fmt.Fprintf(&sb, "| Service | Environment | Lines | Added | Removed | Modified | High-risk | Blocking | Warning |\n")
+	fmt.Fprintf(&sb, "|---|---|---|---|---|---|---|---|---|\n")
+	var unchanged []string
+	for _, service := range report.Services {
+		if service.Error != "" {
+			fmt.Fprintf(&sb, "| `%s` | ❌ failed: %s | | | | | | | |\n", service.Service, strings.ReplaceAll(service.Error, "|", `\|`))
+			continue
+		}
+		if len(service.Environments) == 0 {
+			unchanged = append(unchanged, "`"+service.Service+"`")
+			continue
+		}
+		for _, env := range service.Environments {
+			fmt.Fprintf(&sb, "| `%s` | %s | +%d -%d | %d | %d | %d | %d | %s | %s |\n",
+				service.Service, env.OverlayKey, env.AddedLineCount, env.DeletedLineCount,
+				len(env.AddedResources), len(env.RemovedResources), len(env.ModifiedResources), len(env.HighRiskResources),
+				namesOrDash(env.BlockingPolicies), namesOrDash(env.WarningPolicies))
+		}
+	}
+	if len(unchanged) > 0 {
+		fmt.Fprintf(&sb, "\nUnchanged: %s\n", strings.Join(unchanged, ", "))
+	}
+
+	var highRisk []string
+	for _, service := range report.Services {
+		for _, env := range service.Environments {
+			for _, id := range env.HighRiskResources {
+				highRisk = append(highRisk, fmt.Sprintf("- [`%s`/%s] `%s`", service.Service, env.OverlayKey, id))
+			}
+		}
+	}
+	if len(highRisk) > 0 {
+		fmt.Fprintf(&sb, "\n## High-risk changes\n\n%s\n", strings.Join(highRisk, "\n"))
+	}
+	return sb.String()
+}
+
+func namesOrDash(names []string) string {
+	if len(names) == 0 {
+		return "-"
+	}
+	return strings.Join(names, ", ")
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// OutputRelease writes the release report to release.json and release.md in the output directory
+func OutputRelease(outputDir string, report *models.ReleaseReport) error {
+	if err := perm.MkdirAll(outputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	releaseJson, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	filePath := filepath.Join(outputDir, "release.json")
+	if err := perm.WriteFile(filePath, releaseJson); err != nil {
+		return fmt.Errorf("failed to write release report to %s: %w", filePath, err)
+	}
+	mdPath := filepath.Join(outputDir, "release.md")
+	if err := perm.WriteFile(mdPath, []byte(FormatReleaseMarkdown(report))); err != nil {
+		return fmt.Errorf("failed to write release report to %s: %w", mdPath, err)
+	}
+	logger.WithField("filePath", filePath).WithField("markdownPath", mdPath).Info("Written release report to files")
+	return nil
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// MAX_ESCALATION_REASONS bounds the reasons listed per team in the comment, the others are counted
+const MAX_ESCALATION_REASONS = 5
+
+// reviewerEscalationsOf matches the compliance config reviewer rules against the resource changes and the
+// failing policies of the report, per team in rule order. Teams are deduplicated, reasons listed in overlay order.
+func reviewerEscalationsOf(data *models.ReportData, cfg *models.ComplianceConfig) []models.ReviewerEscalation {
+	if cfg == nil || len(cfg.Reviewers) == 0 {
+		return nil
+	}
+	var escalations []models.ReviewerEscalation
+	add := func(teams []string, reason models.EscalationReason) {
+		for _, team := range teams {
+			i := slices.IndexFunc(escalations, func(e models.ReviewerEscalation) bool { return e.Team == team })
+			if i < 0 {
+				escalations = append(escalations, models.ReviewerEscalation{Team: team})
+				i = len(escalations) - 1
+			}
+			if slices.Contains(escalations[i].Reasons, reason) {
+				continue
+			}
+			if len(escalations[i].Reasons) < MAX_ESCALATION_REASONS {
+				escalations[i].Reasons = append(escalations[i].Reasons, reason)
+			} else {
+				escalations[i].MoreReasons++
+			}
+		}
+	}
+
+	for _, rule := range cfg.Reviewers {
+		for _, overlayKey := range reportOverlayKeys(data) {
+			for _, change := range data.ManifestChanges[overlayKey].ResourceChanges {
+				for _, category := range change.Categories {
+					if slices.Contains(rule.Categories, category) {
+						add(rule.Teams, models.EscalationReason{
+							Trigger: models.EscalationTriggerCategory, Value: category, OverlayKey: overlayKey, ResourceID: change.ID,
+						})
+					}
+				}
+				if slices.Contains(rule.Kinds, change.Kind) {
+					add(rule.Teams, models.EscalationReason{
+						Trigger: models.EscalationTriggerKind, Value: change.Kind, OverlayKey: overlayKey, ResourceID: change.ID,
+					})
+				}
+			}
+
+			matrix := data.PolicyEvaluation.PolicyMatrix[overlayKey]
+			for _, policies := range [][]models.PolicyResult{
+				matrix.BlockingPolicies, matrix.WarningPolicies, matrix.RecommendPolicies,
+			} {
+				for _, policy := range policies {
+					if !policy.IsPassing && slices.Contains(rule.Policies, policy.PolicyId) {
+						add(rule.Teams, models.EscalationReason{
+							Trigger: models.EscalationTriggerPolicy, Value: policy.PolicyId, OverlayKey: overlayKey,
+						})
+					}
+				}
+			}
+		}
+	}
+	return escalations
+}
+
+// requestReviewers requests the reviews of the escalated teams on the PR, failures are recorded per team
+func (r *RunnerGitHub) requestReviewers(escalations []models.ReviewerEscalation) {
+	if len(escalations) == 0 {
+		return
+	}
+	teams := make([]string, len(escalations))
+	for i, escalation := range escalations {
+		teams[i] = teamSlug(escalation.Team)
+	}
+	logger.WithField("teams", teams).Info("RequestReviewers: starting...")
+
+	err := r.ghclient.RequestTeamReviewers(r.Context, r.options.GhRepo, r.options.GhPrNumber, teams)
+	for i := range escalations {
+		if err != nil {
+			escalations[i].Error = failure.Excerpt(err)
+			continue
+		}
+		escalations[i].Requested = true
+	}
+	if err != nil {
+		logger.WithField("error", err).Warn("RequestReviewers: failed to request the reviews")
+		return
+	}
+	logger.Info("RequestReviewers: done.")
+}
+
+// teamSlug strips the organization of an "org/team" reviewer, the API takes the slug alone
+func teamSlug(team string) string {
+	if _, slug, ok := strings.Cut(team, "/"); ok {
+		return slug
+	}
+	return team
+}
@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// selectOverlays bounds the overlays to check to --max-environments. Above the limit the run fails,
+// unless sampling is enabled: the always-checked (prod-class) overlays are kept, and the remaining budget
+// is spread evenly across the others in their order, so that reruns check the same overlays
+func (o *Options) selectOverlays(overlayKeys []string) ([]string, []models.SkippedOverlay, error) {
+	if o.MaxEnvironments <= 0 || len(overlayKeys) <= o.MaxEnvironments {
+		return overlayKeys, nil, nil
+	}
+	if !o.SampleEnvironments {
+		return nil, nil, fmt.Errorf("%d environments matched, more than --max-environments %d (narrow the values or enable --sample-environments)",
+			len(overlayKeys), o.MaxEnvironments)
+	}
+
+	keep := make(map[string]bool)
+	var others []string
+	for _, overlayKey := range overlayKeys {
+		if o.alwaysChecked(overlayKey) {
+			keep[overlayKey] = true
+		} else {
+			others = append(others, overlayKey)
+		}
+	}
+	budget := o.MaxEnvironments - len(keep)
+	if budget < 0 {
+		logger.WithField("alwaysChecked", len(keep)).WithField("maxEnvironments", o.MaxEnvironments).
+			Warn("More always-checked environments than --max-environments, checking all of them and no others")
+		budget = 0
+	}
+	for i := 0; i < budget; i++ {
+		keep[others[i*len(others)/budget]] = true
+	}
+
+	var kept []string
+	var skipped []models.SkippedOverlay
+	for _, overlayKey := range overlayKeys {
+		if keep[overlayKey] {
+			kept = append(kept, overlayKey)
+			continue
+		}
+		skipped = append(skipped, models.SkippedOverlay{
+			OverlayKey: overlayKey,
+			Reason:     fmt.Sprintf("not sampled (--max-environments %d)", o.MaxEnvironments),
+		})
+	}
+	logger.WithField("checked", len(kept)).WithField("skipped", len(skipped)).Info("Sampled environments")
+	return kept, skipped, nil
+}
+
+// alwaysChecked reports whether the overlay key, or any of its segments, matches --always-check-environments
+func (o *Options) alwaysChecked(overlayKey string) bool {
+	for _, pattern := range o.AlwaysCheckEnvironments {
+		if ok, _ := path.Match(pattern, overlayKey); ok {
+			return true
+		}
+		for _, segment := range strings.Split(overlayKey, "/") {
+			if ok, _ := path.Match(pattern, segment); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+)
+
+// serviceIdentifier identifies the service of the run in its comment and check run
+func (o *Options) serviceIdentifier() string {
+	// For dynamic paths, we'll use a generic signature or the first overlay key
+	serviceIdentifier := o.Service
+	if serviceIdentifier == "" && o.UseDynamicPaths() {
+		// Use a generic identifier for dynamic paths
+		serviceIdentifier = "dynamic-paths"
+	}
+	return serviceIdentifier
+}
+
+// readChecklist sets the checklist items ticked in the tool comment of the previous run, the one with the
+// signature, on the evaluator
+func (r *RunnerBase) readChecklist(comments []*models.Comment, signature string) {
+	for _, comment := range comments {
+		if strings.Contains(comment.Body, signature) {
+			checked := template.ParseChecklist(comment.Body)
+			logger.WithField("checked", checked).Debug("Read the checklist of the tool comment")
+			r.Evaluator.SetChecklist(checked)
+			return
+		}
+	}
+}
+
+// scmCheckoutPath returns the path of the repository checked out in the SCM modes (github, gitlab, bitbucket): the
+// directory above the first variable of the dynamic path template, else the service directory
+func (o *Options) scmCheckoutPath() string {
+	if !o.UseDynamicPaths() && o.multiService() {
+		// Legacy mode with several services: the services directory, checked out once for all of them
+		logger.WithField("services", o.Services).WithField("checkoutPath", o.ManifestsPath).
+			Debug("Using legacy mode - checking out the services directory")
+		return o.ManifestsPath
+	}
+	if !o.UseDynamicPaths() {
+		// Legacy mode: use service-based path
+		checkoutPath := filepath.Join(o.ManifestsPath, o.Service)
+		logger.WithField("service", o.Service).WithField("checkoutPath", checkoutPath).
+			Debug("Using legacy mode - checking out service manifests")
+		return checkoutPath
+	}
+
+	// For dynamic paths, extract the base path from the template
+	// e.g., "manifests-nested/services/[SERVICE]/clusters/[CLUSTER]/[ENV]"
+	//    -> checkout "manifests-nested" or "manifests-nested/services"
+	checkoutPath := "."
+	templatePath := o.KustomizeBuildPath
+	if varIdx := strings.Index(templatePath, "["); varIdx > 0 {
+		// Get path before first variable, without trailing slash
+		basePath := strings.TrimSuffix(templatePath[:varIdx], "/")
+
+		// If there's a path separator, take everything up to the last one
+		// to get a meaningful directory to checkout
+		if lastSlash := strings.LastIndex(basePath, "/"); lastSlash > 0 {
+			basePath = basePath[:lastSlash]
+		}
+		checkoutPath = basePath
+	} else if o.ManifestsPath != "" {
+		// No variables or variable at start - checkout from manifests-path or root
+		checkoutPath = o.ManifestsPath
+	}
+	logger.WithFields(map[string]interface{}{
+		"templatePath": templatePath,
+		"checkoutPath": checkoutPath,
+		"strategy":     o.GitCheckoutStrategy,
+	}).Debug("Using dynamic paths - checking out manifests")
+	return checkoutPath
+}
+
+// scmBuildPath returns the path the manifests of a checkout are built from: the checkout root for dynamic
+// paths, the PathBuilder constructs the full paths, else the service directory of the checkout
+func (o *Options) scmBuildPath(checkoutRoot string) string {
+	if o.UseDynamicPaths() {
+		return checkoutRoot
+	}
+	return filepath.Join(checkoutRoot, o.ManifestsPath, o.Service)
+}
+
+// pullRequestReportData constructs the ReportData of a pull (or merge) request, based on whether dynamic or
+// legacy paths are used
+func (r *RunnerBase) pullRequestReportData(
+	rs *models.BuildManifestResult,
+	diffs map[string]models.EnvironmentDiff,
+	policyEval *models.PolicyEvaluation,
+	pr *models.PullRequest,
+) models.ReportData {
+	linkPolicyViolations(r.FS, rs, diffs, policyEval)
+	reportData := models.ReportData{
+		SchemaVersion:    models.REPORT_SCHEMA_VERSION,
+		ServiceMetadata:  r.ServiceMetadata,
+		Timestamp:        time.Now(),
+		BaseCommit:       pr.BaseSHA,
+		HeadCommit:       pr.HeadSHA,
+		ManifestChanges:  diffs,
+		HighRiskChanges:  highRiskChangesOf(diffs),
+		PolicyEvaluation: *policyEval,
+		Timings:          r.timings(),
+		GitOpsResources:  gitopsResourcesOf(rs),
+		Components:       componentsOf(rs),
+		ConfigChanges:    configChangesOf(rs),
+		SkippedOverlays:  rs.SkippedOverlays,
+		Errors:           partialErrorsOf(rs, policyEval),
+		ExternalData:     r.Evaluator.ExternalDataRecords(),
+		Sources:          r.Options.Sources,
+	}
+
+	if r.Options.UseDynamicPaths() {
+		// Dynamic paths mode
+		// Use the ordered OverlayKeys from BuildManifestResult to preserve ordering
+		reportData.OverlayKeys = rs.OverlayKeys
+		reportData.KustomizeBuildPath = r.Options.KustomizeBuildPath
+		reportData.KustomizeBuildValues = r.Options.KustomizeBuildValues
+
+		// Add parsed build values if PathBuilder is available
+		if r.Options.PathBuilder != nil {
+			reportData.ParsedKustomizeBuildValues = r.Options.PathBuilder.Variables
+		}
+
+		// Set Service to empty for dynamic mode (or extract from path if needed)
+		reportData.Service = ""
+		// Keep Environments for backward compat in templates, same as OverlayKeys
+		reportData.Environments = rs.OverlayKeys
+	} else {
+		// Legacy mode
+		reportData.Service = r.Options.Service
+		reportData.Environments = r.Options.Environments
+		reportData.OverlayKeys = r.Options.Environments
+	}
+
+	applyEnvironmentNames(&reportData, r.Evaluator.Config())
+	reportData.Timezone = r.Evaluator.Config().Timezone
+	reportData.Checklist = r.Evaluator.Checklist()
+	reportData.PolicyPacks = r.Evaluator.PolicyPacks()
+	reportData.DestructiveChanges = destructiveChangesOf(&reportData)
+	reportData.NextSteps = nextStepsOf(&reportData, r.Evaluator.Config())
+	reportData.ReviewerEscalations = reviewerEscalationsOf(&reportData, r.Evaluator.Config())
+	reportData.Profiles = profilesOf(&reportData, r.Evaluator.Config())
+	return reportData
+}
+
+// offloadLongDiffs writes the diffs longer than the comment limit of their overlay, every diff in confidential
+// mode, to the output dir, the comment links to the artifacts of the CI run instead
+func (r *RunnerBase) offloadLongDiffs(
+	result *models.BuildManifestResult,
+	diffs map[string]models.EnvironmentDiff,
+	defaultMaxDiffLength int,
+	prNumber int,
+	artifactURLOf func() (string, error),
+) (map[string]models.EnvironmentDiff, error) {
+	for env, envDiff := range diffs {
+		// In confidential mode every diff goes to the output dir, never inline in the comment
+		maxDiffLength := diffLimitFor(r.Evaluator.Config(), env, defaultMaxDiffLength)
+		if len(envDiff.Content) <= maxDiffLength && (!r.Options.NoManifestContentInComment || result.EnvManifestBuild[env].Skipped || envDiff.Content == "") {
+			continue
+		}
+		logger.WithFields(map[string]interface{}{
+			"env":        env,
+			"diffLength": len(envDiff.Content),
+			"maxLength":  maxDiffLength,
+		}).Info("Diff is too long, uploading as artifact")
+
+		// Create filename for this diff
+		// Use overlay key directly (which is env in this context)
+		serviceIdentifier := r.Options.Service
+		if serviceIdentifier == "" {
+			serviceIdentifier = "dynamic"
+		}
+
+		uncleanFileName := fmt.Sprintf("diff-pr%d-%s-%s.txt", prNumber, env, serviceIdentifier)
+		filename := strings.ReplaceAll(uncleanFileName, "/", "-")
+
+		// Save diff content to file
+		outputDir := r.Options.OutputDir
+		if err := perm.MkdirAll(outputDir); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		filepath := filepath.Join(outputDir, filename)
+		if err := perm.WriteFile(filepath, []byte(envDiff.Content)); err != nil {
+			return nil, fmt.Errorf("failed to write diff file: %w", err)
+		}
+
+		// Upload file as artifact and get URL
+		artifactURL, err := artifactURLOf()
+		if err != nil {
+			logger.WithField("error", err).Error("Failed to get CI run URL, leaving content as text")
+			artifactURL = ""
+		}
+
+		// Update the diff result to point to the artifact URL
+		envDiff.ContentGHFilePath = &filepath
+		envDiff.ContentType = models.DiffContentTypeGHArtifact
+		envDiff.Content = artifactURL
+		envDiff.Lines = nil
+		diffs[env] = envDiff
+
+		logger.WithFields(map[string]interface{}{
+			"env":         env,
+			"filename":    filename,
+			"artifactURL": artifactURL,
+		}).Info("Diff uploaded as artifact successfully")
+	}
+
+	return diffs, nil
+}
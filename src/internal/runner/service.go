@@ -0,0 +1,158 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/service"
+)
+
+// SERVICE_VARIABLE is the path variable of the service directory in dynamic mode
+const SERVICE_VARIABLE = "SERVICE"
+
+// loadServiceMetadata loads the service.yaml of the checked service and scopes the evaluation with it.
+// The base revision is read first so that a PR cannot relax the checks of its own service, the head
+// revision only for a service the PR adds
+func (r *RunnerBase) loadServiceMetadata(beforeRoot, afterRoot string) error {
+	beforeDir, ok := r.serviceDirOf(beforeRoot)
+	if !ok {
+		return nil
+	}
+	afterDir, _ := r.serviceDirOf(afterRoot)
+
+	metadata, err := service.LoadMetadata(r.FS, beforeDir)
+	if err == nil && metadata == nil {
+		metadata, err = service.LoadMetadata(r.FS, afterDir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load service metadata: %w", err)
+	}
+	if metadata == nil {
+		return nil
+	}
+	logger.WithField("owner", metadata.Owner).WithField("tier", metadata.Tier).Info("Loaded service metadata")
+	r.ServiceMetadata = metadata
+	r.Evaluator.SetServiceMetadata(metadata)
+	return nil
+}
+
+// serviceDirOf returns the service directory under a manifests root: the root itself in legacy mode, the
+// build path template up to its [SERVICE] variable in dynamic mode, when a single service is built
+func (r *RunnerBase) serviceDirOf(root string) (string, bool) {
+	if !r.Options.UseDynamicPaths() {
+		return root, true
+	}
+	pb := r.Options.PathBuilder
+	if pb == nil {
+		return "", false
+	}
+	prefix, _, found := strings.Cut(pb.Template, fmt.Sprintf("[%s]", SERVICE_VARIABLE))
+	services := pb.Variables[SERVICE_VARIABLE]
+	if !found || len(services) != 1 || strings.Contains(prefix, "[") {
+		logger.Debug("No single service directory in the build path, skipping service metadata")
+		return "", false
+	}
+	return filepath.Join(root, prefix+services[0]), true
+}
+
+// multiService reports whether the run checks several services (--service a,b) in turn
+func (o *Options) multiService() bool {
+	return len(o.Services) > 1
+}
+
+// processServices runs processService for each service of a multi-service run, with the service set on the options
+// and evaluator and its outputs in <output dir>/<service>; the checkouts and the loaded policies are shared. All the
+// services are processed, the run fails with the errors of the failed ones. A single service is processed as is
+func (r *RunnerBase) processServices(processService func() error) error {
+	if r.Options.AutoDetectServices && len(r.Options.Services) == 0 {
+		logger.Info("The pull request changes no service, nothing to check")
+		return nil
+	}
+	if !r.Options.multiService() {
+		return processService()
+	}
+	outputDir, service := r.Options.OutputDir, r.Options.Service
+	defer func() {
+		r.Options.OutputDir, r.Options.Service = outputDir, service
+	}()
+
+	var errs []error
+	for _, svc := range r.Options.Services {
+		lg := logger.WithField("service", svc)
+		lg.Info("Processing service")
+		r.Options.Service = svc
+		r.Options.OutputDir = filepath.Join(outputDir, svc)
+		r.Timings = newTimings()
+		r.ServiceMetadata = nil
+		r.ExportedManifests = nil
+		r.Evaluator.SetService(svc)
+		err := perm.MkdirAll(r.Options.OutputDir)
+		if err == nil {
+			err = processService()
+		}
+		if err != nil {
+			lg.WithField("error", err).Error("Service failed, continuing with the others")
+			errs = append(errs, fmt.Errorf("service %s: %w", svc, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkouts are the base and head checkouts of a pull request, shared by the services of the run
+type checkouts struct {
+	before, after string
+}
+
+// checkoutOnce checks out the base and head revisions with checkout on the first call, then returns the same
+// checkouts to the next services of the run. They are removed by removeCheckouts
+func (r *RunnerBase) checkoutOnce(checkout func() (before, after string, err error)) (string, string, error) {
+	if r.checkouts == nil {
+		before, after, err := checkout()
+		if err != nil {
+			return "", "", err
+		}
+		r.checkouts = &checkouts{before: before, after: after}
+	}
+	return r.checkouts.before, r.checkouts.after, nil
+}
+
+// removeCheckouts removes the checkouts of the run, if any
+func (r *RunnerBase) removeCheckouts() {
+	if r.checkouts == nil {
+		return
+	}
+	_ = fsys.OrOS(r.FS).RemoveAll(r.checkouts.before)
+	_ = fsys.OrOS(r.FS).RemoveAll(r.checkouts.after)
+	r.checkouts = nil
+}
+
+// changedServicesOf returns the service directories under manifestsPath of changed files (slash-separated paths
+// from the repository root), sorted. The files of manifestsPath itself are of no service
+func changedServicesOf(files []string, manifestsPath string) []string {
+	root := path.Clean(filepath.ToSlash(manifestsPath))
+	seen := make(map[string]bool)
+	for _, file := range files {
+		rel := file
+		if root != "." {
+			var ok bool
+			if rel, ok = strings.CutPrefix(file, root+"/"); !ok {
+				continue
+			}
+		}
+		if service, _, ok := strings.Cut(rel, "/"); ok && service != "" {
+			seen[service] = true
+		}
+	}
+	services := make([]string, 0, len(seen))
+	for service := range seen {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
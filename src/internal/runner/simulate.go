@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+)
+
+// SIMULATION_DATE_FORMAT is the date format of the simulation summary
+const SIMULATION_DATE_FORMAT = "2006-01-02 15:04 MST"
+
+// Simulate builds and evaluates the manifests like Process, then prints how the enforcement outcomes
+// would change at the given time instead of writing the report
+func (r *RunnerLocal) Simulate(at time.Time) error {
+	ctx, span := trace.StartSpan(r.Context, "Simulate")
+	defer span.End()
+
+	logger.WithField("at", at).Info("Simulate: starting...")
+
+	rs, err := r.buildLocalManifests(ctx)
+	if err != nil {
+		return failure.Build(err)
+	}
+
+	_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
+	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, nil)
+	evalSpan.End()
+	if err != nil {
+		return failure.PolicyEngine(err)
+	}
+
+	sim, err := r.Evaluator.Simulate(policyEval, at, nil)
+	if err != nil {
+		return fmt.Errorf("failed to simulate enforcement: %w", err)
+	}
+	fmt.Print(formatSimulation(sim))
+	return r.outputSimulationJson(sim)
+}
+
+// formatSimulation renders the simulation summary for the terminal
+func formatSimulation(sim *models.EnforcementSimulation) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Enforcement at %s (evaluated at %s)\n",
+		sim.SimulatedAt.Format(SIMULATION_DATE_FORMAT), sim.EvaluatedAt.Format(SIMULATION_DATE_FORMAT))
+	if len(sim.Policies) == 0 {
+		sb.WriteString("No enforcement level changes.\n")
+		return sb.String()
+	}
+	for _, outcome := range sim.Outcomes {
+		fmt.Fprintf(&sb, "- %s\n", outcome)
+	}
+	sb.WriteString("\n")
+	for _, policy := range sim.Policies {
+		status := "passing"
+		if len(policy.FailingOverlays) > 0 {
+			status = "failing in " + strings.Join(policy.FailingOverlays, ", ")
+		}
+		fmt.Fprintf(&sb, "  %s: %s -> %s (%s)\n", policy.PolicyName, levelOrNone(policy.CurrentLevel), levelOrNone(policy.SimulatedLevel), status)
+	}
+	return sb.String()
+}
+
+func levelOrNone(level string) string {
+	if level == "" {
+		return "NONE"
+	}
+	return level
+}
+
+// Exporting simulation json file to output directory if enabled
+func (r *RunnerLocal) outputSimulationJson(sim *models.EnforcementSimulation) error {
+	if !r.Options.EnableExportReport {
+		return nil
+	}
+	if err := perm.MkdirAll(r.Options.OutputDir); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	simJson, err := json.Marshal(sim)
+	if err != nil {
+		return err
+	}
+	filePath := filepath.Join(r.Options.OutputDir, "simulation.json")
+	if err := perm.WriteFile(filePath, simJson); err != nil {
+		return fmt.Errorf("failed to write simulation to %s: %w", filePath, err)
+	}
+	logger.WithField("filePath", filePath).Info("Written simulation to file")
+	return nil
+}
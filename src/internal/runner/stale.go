@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+)
+
+// STALE_RESULT_NOTE heads the tool comment of a run whose commit is no longer the head of the pull request, with
+// the evaluated and head commits
+const STALE_RESULT_NOTE = "> ⚠️ **Outdated — new commits pushed.** These results are for `%s`, the head of the pull request is now `%s`; the run of the new head updates this report."
+
+// headMarkerPattern matches the head marker of a tool comment, HTML or link reference definition
+var headMarkerPattern = regexp.MustCompile(`(?m)^(?:<!-- |\[//\]: # \()gitops-kustomzchk-head: (\S+)(?: -->|\))$`)
+
+// checkStaleResult compares the evaluated commit of the report with the current head of the pull request. A
+// stale report is marked as such, unless the tool comment already has the report of the new head: the run is
+// then superseded and publishes nothing. The check is best effort: a failed API call is logged and the report
+// published as current
+func (r *RunnerBase) checkStaleResult(api toolCommentAPI, data *models.ReportData) error {
+	if data.HeadCommit == "" {
+		return nil
+	}
+	head, err := api.headCommit()
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to get the head of the pull request, publishing the report as current")
+		return nil
+	}
+	if head == "" || head == data.HeadCommit {
+		return nil
+	}
+	lg := logger.WithField("evaluatedCommit", data.HeadCommit).WithField("headCommit", head)
+
+	comment, err := api.find()
+	if err != nil {
+		lg.WithField("error", err).Warn("Failed to read the head of the tool comment")
+	} else if comment != nil {
+		if match := headMarkerPattern.FindStringSubmatch(comment.Body); match != nil && match[1] == head {
+			lg.Warn("The tool comment has the report of the new head")
+			return errRunSuperseded
+		}
+	}
+	lg.Warn("New commits were pushed during the run, marking the report as outdated")
+	data.Stale = &models.StaleResult{EvaluatedCommit: data.HeadCommit, HeadCommit: head}
+	return nil
+}
+
+// staleNoteOf returns the note heading the report of a stale run, empty if the report is current
+func staleNoteOf(data *models.ReportData) string {
+	if data.Stale == nil {
+		return ""
+	}
+	return fmt.Sprintf(STALE_RESULT_NOTE, shortSHA(data.Stale.EvaluatedCommit), shortSHA(data.Stale.HeadCommit)) + "\n\n"
+}
+
+// toolCommentBody assembles the tool comment of a rendered report: the signature, the note of a stale report,
+// then the head and lease markers for the runs after it
+func (r *RunnerBase) toolCommentBody(signature, rendered string, data *models.ReportData, markdown bool) string {
+	body := signature + "\n\n" + staleNoteOf(data) + rendered
+	if data.HeadCommit != "" {
+		marker := template.ToolHeadSignature
+		if markdown {
+			marker = template.ToolHeadSignatureMarkdown
+		}
+		body = strings.TrimRight(body, "\n") + "\n\n" + strings.ReplaceAll(marker, template.ToolHeadKeyToken, data.HeadCommit)
+	}
+	return r.withLeaseMarker(body, markdown)
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// GH_STEP_SUMMARY_OFF never writes the step summary
+	GH_STEP_SUMMARY_OFF = "off"
+	// GH_STEP_SUMMARY_APPEND writes the report to the step summary of the job, besides the PR comment
+	GH_STEP_SUMMARY_APPEND = "append"
+	// GH_STEP_SUMMARY_ONLY writes the report to the step summary instead of the PR comment
+	GH_STEP_SUMMARY_ONLY = "only"
+
+	// GH_STEP_SUMMARY_ENV is the file of the step summary, set by GitHub Actions
+	GH_STEP_SUMMARY_ENV = "GITHUB_STEP_SUMMARY"
+	// GH_STEP_SUMMARY_MAX_SIZE is the size limit of the step summary of a step, GitHub rejects larger ones
+	GH_STEP_SUMMARY_MAX_SIZE = 1024 * 1024
+)
+
+var GH_STEP_SUMMARY_MODES = []string{GH_STEP_SUMMARY_OFF, GH_STEP_SUMMARY_APPEND, GH_STEP_SUMMARY_ONLY}
+
+// stepSummaryTruncatedNote ends a report truncated to the room left in the step summary
+const stepSummaryTruncatedNote = "\n\n> [!WARNING]\n> The report was truncated to the size limit of the step summary, see the PR comment or the output files.\n"
+
+// stepSummaryPath returns the step summary file of the job, empty when disabled or not running in GitHub Actions
+func (r *RunnerGitHub) stepSummaryPath() string {
+	if r.options.GhStepSummary == GH_STEP_SUMMARY_OFF {
+		return ""
+	}
+	return os.Getenv(GH_STEP_SUMMARY_ENV)
+}
+
+// writeStepSummary appends the rendered markdown of a service to the step summary, the runs of several services
+// of a job each adding theirs. The report is truncated to the room left below GH_STEP_SUMMARY_MAX_SIZE
+func writeStepSummary(path, markdown string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the step summary: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat the step summary: %w", err)
+	}
+
+	content := markdown
+	if info.Size() > 0 {
+		content = "\n\n" + content
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	room := GH_STEP_SUMMARY_MAX_SIZE - int(info.Size())
+	if len(content) > room {
+		if room < len(stepSummaryTruncatedNote) {
+			return fmt.Errorf("the step summary is full, %d of %d bytes used", info.Size(), GH_STEP_SUMMARY_MAX_SIZE)
+		}
+		content = strings.ToValidUTF8(content[:room-len(stepSummaryTruncatedNote)], "") + stepSummaryTruncatedNote
+	}
+	if _, err := f.WriteString(content); err != nil {
+		return fmt.Errorf("failed to write the step summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+)
+
+// sourceFix is a one-line policy fix of a source file of the repository
+type sourceFix struct {
+	Key         string // hash of the change, to apply or post it once across runs and overlays
+	PolicyId    string
+	PolicyName  string
+	OverlayKey  string
+	Message     string
+	Path        string // relative to the repository root
+	Line        int
+	Replacement string
+}
+
+// outputSuggestionComments posts the policy fixes mapping to a source line as review comments with GitHub
+// suggested changes, once per line and replacement. Publishing is best-effort: GitHub rejects lines that
+// are not part of the PR diff, failures are logged.
+func (r *RunnerGitHub) outputSuggestionComments(rs *models.BuildManifestResult, data *models.ReportData, checkoutRoot string) {
+	if r.Options.NoManifestContentInComment {
+		logger.Info("OutputSuggestionComments: confidential mode, no suggestion is posted")
+		return
+	}
+	fixes := sourceFixesOf(rs, data, checkoutRoot)
+	if data.AutoFix != nil && data.AutoFix.Error == "" {
+		fixes = withoutAppliedFixes(fixes, data.AutoFix.Fixes)
+	}
+	if len(fixes) == 0 {
+		return
+	}
+	logger.WithField("count", len(fixes)).Info("OutputSuggestionComments: starting...")
+
+	existing, err := r.ghclient.GetReviewComments(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to get review comments, no suggestion is posted")
+		return
+	}
+	for _, fix := range fixes {
+		signature := strings.ReplaceAll(template.ToolSuggestionSignature, template.ToolSuggestionKeyToken, fix.Key)
+		if hasCommentContaining(existing, signature) {
+			logger.WithField("path", fix.Path).WithField("line", fix.Line).Debug("Suggestion already posted")
+			continue
+		}
+		body := fmt.Sprintf("%s\n**%s** (`%s`): %s\n\n```suggestion\n%s\n```",
+			signature, fix.PolicyName, fix.OverlayKey, fix.Message, fix.Replacement)
+		err := r.publish(NOTIFY_GITHUB_API, func() error {
+			return r.ghclient.CreateReviewComment(r.Context, r.options.GhRepo, r.options.GhPrNumber,
+				r.prInfo.HeadSHA, fix.Path, fix.Line, body)
+		})
+		if err != nil {
+			logger.WithField("path", fix.Path).WithField("line", fix.Line).WithField("error", err).Warn("Failed to post suggestion")
+		}
+	}
+	logger.Info("OutputSuggestionComments: done.")
+}
+
+// sourceFixesOf collects the source suggestions of the failing policies in overlay order, once per change
+func sourceFixesOf(rs *models.BuildManifestResult, data *models.ReportData, checkoutRoot string) []sourceFix {
+	var fixes []sourceFix
+	seen := make(map[string]bool)
+	for _, key := range data.OverlayKeys {
+		matrix := data.PolicyEvaluation.PolicyMatrix[key]
+		overlayPath := rs.EnvManifestBuild[key].AfterBuildPath
+		for _, policies := range [][]models.PolicyResult{
+			matrix.BlockingPolicies, matrix.WarningPolicies, matrix.RecommendPolicies,
+		} {
+			for _, policy := range policies {
+				for _, violation := range policy.Violations {
+					if violation.Suggestion == nil || violation.Suggestion.Source == nil {
+						continue
+					}
+					source := violation.Suggestion.Source
+					path, err := filepath.Rel(checkoutRoot, filepath.Join(overlayPath, source.File))
+					if err != nil || strings.HasPrefix(path, "..") {
+						continue
+					}
+					path = filepath.ToSlash(path)
+					change := fmt.Sprintf("%s:%d:%s", path, source.Line, source.Replacement)
+					fixKey := fmt.Sprintf("%x", sha256.Sum256([]byte(change)))[:16]
+					if seen[fixKey] {
+						continue
+					}
+					seen[fixKey] = true
+					fixes = append(fixes, sourceFix{
+						Key:         fixKey,
+						PolicyId:    policy.PolicyId,
+						PolicyName:  policy.PolicyName,
+						OverlayKey:  key,
+						Message:     violation.Message,
+						Path:        path,
+						Line:        source.Line,
+						Replacement: source.Replacement,
+					})
+				}
+			}
+		}
+	}
+	return fixes
+}
+
+// withoutAppliedFixes drops the fixes of the lines already changed by --auto-fix
+func withoutAppliedFixes(fixes []sourceFix, applied []models.AppliedFix) []sourceFix {
+	appliedLines := make(map[string]bool, len(applied))
+	for _, fix := range applied {
+		appliedLines[fmt.Sprintf("%s:%d", fix.File, fix.Line)] = true
+	}
+	var results []sourceFix
+	for _, fix := range fixes {
+		if !appliedLines[fmt.Sprintf("%s:%d", fix.Path, fix.Line)] {
+			results = append(results, fix)
+		}
+	}
+	return results
+}
+
+func hasCommentContaining(comments []*models.Comment, s string) bool {
+	for _, comment := range comments {
+		if strings.Contains(comment.Body, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package runner
+
+import "os"
+
+// enableANSI is a no-op outside Windows, terminals interpret ANSI escapes
+func enableANSI(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,38 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// msSince returns the milliseconds elapsed since start
+func msSince(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000
+}
+
+// recordDuration adds the time elapsed since start to the duration of a key, a stage running twice
+// for an overlay (before and after builds) sums up
+func recordDuration(durations map[string]float64, key string, start time.Time) {
+	durations[key] += msSince(start)
+}
+
+// newTimings returns empty run timings
+func newTimings() *models.Timings {
+	return &models.Timings{
+		BuildMs:      make(map[string]float64),
+		DiffMs:       make(map[string]float64),
+		PolicyEvalMs: make(map[string]map[string]float64),
+	}
+}
+
+// timings returns the run timings for the report, with the policy evaluation durations of the evaluator
+func (r *RunnerBase) timings() *models.Timings {
+	for overlayKey, durations := range r.Evaluator.EvalDurations() {
+		r.Timings.PolicyEvalMs[overlayKey] = make(map[string]float64, len(durations))
+		for id, d := range durations {
+			r.Timings.PolicyEvalMs[overlayKey][id] = float64(d.Microseconds()) / 1000
+		}
+	}
+	return r.Timings
+}
@@ -0,0 +1,177 @@
+// Package admission serves the Kubernetes ValidatingWebhook of the admission mode: the objects of the admission
+// requests are evaluated with the policies of the compliance config, the same ones gating the pull requests
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "admission")
+
+const (
+	// ADMISSION_PATH is the path of the ValidatingWebhook endpoint
+	ADMISSION_PATH = "/validate"
+	// API_VERSION and KIND are of the AdmissionReview objects, request and response
+	API_VERSION = "admission.k8s.io/v1"
+	KIND        = "AdmissionReview"
+
+	// MAX_PAYLOAD_BYTES is the maximum AdmissionReview, objects are at most 1.5 MB in etcd and UPDATE carries two
+	MAX_PAYLOAD_BYTES = 4 << 20
+	// MAX_WARNING_LENGTH truncates the warnings, the API server drops the ones above 256 characters
+	MAX_WARNING_LENGTH = 256
+)
+
+// Review is an AdmissionReview, with the request to decide or the response to it
+type Review struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Request    *Request  `json:"request,omitempty"`
+	Response   *Response `json:"response,omitempty"`
+}
+
+// Request is the part of an AdmissionRequest the policies are evaluated with
+type Request struct {
+	UID  string `json:"uid"`
+	Kind struct {
+		Group   string `json:"group"`
+		Version string `json:"version"`
+		Kind    string `json:"kind"`
+	} `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Operation string `json:"operation"`
+	UserInfo  struct {
+		Username string `json:"username"`
+	} `json:"userInfo"`
+	Object json.RawMessage `json:"object,omitempty"`
+	DryRun *bool           `json:"dryRun,omitempty"`
+}
+
+// Response is an AdmissionResponse
+type Response struct {
+	UID      string   `json:"uid"`
+	Allowed  bool     `json:"allowed"`
+	Status   *Status  `json:"status,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Status is the reason of a denied request, shown to the client
+type Status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Evaluator evaluates the policies against an object, see policy.PolicyEvaluator.EvaluateObject
+type Evaluator interface {
+	EvaluateObject(ctx context.Context, mf []byte, pc models.PolicyContext) (*policy.ObjectEvaluation, error)
+}
+
+// Handler answers the AdmissionReviews: the requests failing a blocking policy are denied, the warning and
+// recommend ones are allowed with warnings. A request failing to evaluate is answered 500, the failurePolicy
+// of the webhook configuration decides
+type Handler struct {
+	// Evaluator returns the evaluator of a request, e.g. of the current policies, and releases it once evaluated
+	Evaluator func(ctx context.Context) (Evaluator, func(), error)
+	// Environment and Cluster are the policy context of the cluster, e.g. "prod" and "alpha"
+	Environment string
+	Cluster     string
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_PAYLOAD_BYTES))
+	if err != nil {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var review Review
+	if err := json.Unmarshal(payload, &review); err != nil || review.Request == nil {
+		http.Error(w, "expected an AdmissionReview with a request", http.StatusBadRequest)
+		return
+	}
+	req := review.Request
+	lg := logger.WithField("uid", req.UID).WithField("kind", req.Kind.Kind).WithField("namespace", req.Namespace).
+		WithField("name", req.Name).WithField("operation", req.Operation).WithField("user", req.UserInfo.Username)
+
+	response, err := h.decide(r.Context(), req)
+	if err != nil {
+		lg.WithField("error", err).Error("Failed to evaluate admission request")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lg.WithField("allowed", response.Allowed).WithField("warnings", len(response.Warnings)).Info("Decided admission request")
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(Review{APIVersion: API_VERSION, Kind: KIND, Response: response})
+}
+
+// decide evaluates the object of a request, the requests without object (DELETE) are allowed
+func (h *Handler) decide(ctx context.Context, req *Request) (*Response, error) {
+	response := &Response{UID: req.UID, Allowed: true}
+	if len(req.Object) == 0 || string(req.Object) == "null" {
+		return response, nil
+	}
+	evaluator, release, err := h.Evaluator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	pc := models.PolicyContext{
+		Environment: h.Environment,
+		OverlayKey:  h.Environment,
+		Cluster:     h.Cluster,
+		Admission: &models.AdmissionContext{
+			Operation: req.Operation,
+			Namespace: req.Namespace,
+			Name:      req.Name,
+			User:      req.UserInfo.Username,
+			DryRun:    req.DryRun != nil && *req.DryRun,
+		},
+	}
+	evaluation, err := evaluator.EvaluateObject(ctx, req.Object, pc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate the policies: %w", err)
+	}
+	return responseOf(req.UID, evaluation), nil
+}
+
+// responseOf denies the requests failing a blocking policy, with their messages, the other failing policies
+// are warnings
+func responseOf(uid string, evaluation *policy.ObjectEvaluation) *Response {
+	response := &Response{UID: uid, Allowed: len(evaluation.Blocking) == 0}
+	if !response.Allowed {
+		var reasons []string
+		for _, result := range evaluation.Blocking {
+			reasons = append(reasons, fmt.Sprintf("[%s] %s", result.PolicyId, strings.Join(result.FailMessages, "; ")))
+		}
+		response.Status = &Status{Code: http.StatusForbidden, Message: "denied by gitops-kustomzchk policies: " + strings.Join(reasons, ", ")}
+	}
+	levels := []struct {
+		name    string
+		results []models.PolicyResult
+	}{{"warning", evaluation.Warning}, {"recommend", evaluation.Recommend}}
+	for _, level := range levels {
+		for _, result := range level.results {
+			for _, message := range result.FailMessages {
+				warning := fmt.Sprintf("%s policy %s: %s", level.name, result.PolicyId, message)
+				if len(warning) > MAX_WARNING_LENGTH {
+					warning = strings.ToValidUTF8(warning[:MAX_WARNING_LENGTH-3], "") + "..."
+				}
+				response.Warnings = append(response.Warnings, warning)
+			}
+		}
+	}
+	return response
+}
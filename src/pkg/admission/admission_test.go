@@ -0,0 +1,104 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/policy"
+)
+
+// fakeEvaluator fails the policies of the objects named "bad" and "risky", recording the policy context
+type fakeEvaluator struct {
+	pc  models.PolicyContext
+	err error
+}
+
+func (f *fakeEvaluator) EvaluateObject(ctx context.Context, mf []byte, pc models.PolicyContext) (*policy.ObjectEvaluation, error) {
+	f.pc = pc
+	evaluation := &policy.ObjectEvaluation{}
+	if strings.Contains(string(mf), `"bad"`) {
+		evaluation.Blocking = []models.PolicyResult{{PolicyId: "no-latest", FailMessages: []string{"image nginx:latest"}}}
+	}
+	if strings.Contains(string(mf), `"risky"`) {
+		evaluation.Warning = []models.PolicyResult{{PolicyId: "limits", FailMessages: []string{strings.Repeat("x", 300)}}}
+	}
+	return evaluation, f.err
+}
+
+func review(t *testing.T, h *Handler, body string) (int, *Response) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ADMISSION_PATH, strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		return rec.Code, nil
+	}
+	var out Review
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.APIVersion != API_VERSION || out.Kind != KIND {
+		t.Errorf("response review = %s %s", out.APIVersion, out.Kind)
+	}
+	return rec.Code, out.Response
+}
+
+func TestHandler(t *testing.T) {
+	evaluator := &fakeEvaluator{}
+	h := &Handler{
+		Evaluator:   func(context.Context) (Evaluator, func(), error) { return evaluator, func() {}, nil },
+		Environment: "prod",
+		Cluster:     "alpha",
+	}
+	request := func(name, operation, object string) string {
+		return `{"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview", "request": {"uid": "u-1", "operation": "` +
+			operation + `", "namespace": "shop", "name": "` + name + `", "userInfo": {"username": "alice"}, "object": ` + object + `}}`
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantAllowed  bool
+		wantWarnings int
+	}{
+		{"passing", request("good", "CREATE", `{"metadata": {"name": "good"}}`), true, 0},
+		{"blocking", request("bad", "CREATE", `{"metadata": {"name": "bad"}}`), false, 0},
+		{"warning", request("risky", "UPDATE", `{"metadata": {"name": "risky"}}`), true, 1},
+		{"delete", request("bad", "DELETE", `null`), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, response := review(t, h, tt.body)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if response.UID != "u-1" || response.Allowed != tt.wantAllowed || len(response.Warnings) != tt.wantWarnings {
+				t.Errorf("response = %+v, want allowed %v with %d warnings", response, tt.wantAllowed, tt.wantWarnings)
+			}
+			if !tt.wantAllowed && (response.Status == nil || !strings.Contains(response.Status.Message, "[no-latest] image nginx:latest")) {
+				t.Errorf("status = %+v, want the blocking policy message", response.Status)
+			}
+			for _, warning := range response.Warnings {
+				if len(warning) > MAX_WARNING_LENGTH {
+					t.Errorf("warning of %d characters, want at most %d", len(warning), MAX_WARNING_LENGTH)
+				}
+			}
+		})
+	}
+	if pc := evaluator.pc; pc.Environment != "prod" || pc.Cluster != "alpha" || pc.Admission.User != "alice" || pc.Admission.Operation != "UPDATE" {
+		t.Errorf("policy context = %+v %+v", pc, pc.Admission)
+	}
+
+	if code, _ := review(t, h, `{"kind": "AdmissionReview"}`); code != http.StatusBadRequest {
+		t.Errorf("review without request: status = %d, want 400", code)
+	}
+	evaluator.err = errors.New("conftest failed")
+	if code, _ := review(t, h, request("good", "CREATE", `{}`)); code != http.StatusInternalServerError {
+		t.Errorf("failed evaluation: status = %d, want 500 for the failurePolicy", code)
+	}
+}
@@ -0,0 +1,135 @@
+package bench
+
+import (
+	"fmt"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Sample is one measured run of a stage
+type Sample struct {
+	Duration   time.Duration
+	AllocBytes uint64 // heap allocated by the process during the run (external binaries are not included)
+}
+
+// StageStats are the statistics of the samples of a stage
+type StageStats struct {
+	Stage            string        `json:"stage"`
+	Runs             int           `json:"runs"`
+	Min              time.Duration `json:"minNs"`
+	Median           time.Duration `json:"medianNs"`
+	P90              time.Duration `json:"p90Ns"`
+	Max              time.Duration `json:"maxNs"`
+	Mean             time.Duration `json:"meanNs"`
+	AllocBytesPerRun uint64        `json:"allocBytesPerRun"`
+}
+
+// Recorder measures the duration and allocations of repeated stages
+type Recorder struct {
+	samples map[string][]Sample
+	stages  []string // first-measured order
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{samples: make(map[string][]Sample)}
+}
+
+// Measure runs fn and records it as a sample of the stage, failed runs are not recorded
+func (r *Recorder) Measure(stage string, fn func() error) error {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	err := fn()
+	duration := time.Since(start)
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		return err
+	}
+	r.Add(stage, Sample{Duration: duration, AllocBytes: after.TotalAlloc - before.TotalAlloc})
+	return nil
+}
+
+// Add records a sample of the stage
+func (r *Recorder) Add(stage string, sample Sample) {
+	if _, ok := r.samples[stage]; !ok {
+		r.stages = append(r.stages, stage)
+	}
+	r.samples[stage] = append(r.samples[stage], sample)
+}
+
+// Stats returns the statistics of each stage, in first-measured order
+func (r *Recorder) Stats() []StageStats {
+	results := make([]StageStats, 0, len(r.stages))
+	for _, stage := range r.stages {
+		results = append(results, statsOf(stage, r.samples[stage]))
+	}
+	return results
+}
+
+func statsOf(stage string, samples []Sample) StageStats {
+	durations := make([]time.Duration, len(samples))
+	var total time.Duration
+	var allocs uint64
+	for i, sample := range samples {
+		durations[i] = sample.Duration
+		total += sample.Duration
+		allocs += sample.AllocBytes
+	}
+	slices.Sort(durations)
+	n := len(durations)
+	return StageStats{
+		Stage:            stage,
+		Runs:             n,
+		Min:              durations[0],
+		Median:           percentile(durations, 50),
+		P90:              percentile(durations, 90),
+		Max:              durations[n-1],
+		Mean:             total / time.Duration(n),
+		AllocBytesPerRun: allocs / uint64(n),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Format renders the statistics as an aligned table
+func Format(stats []StageStats) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "stage\truns\tmin\tmedian\tp90\tmax\tmean\talloc/run\t")
+	for _, s := range stats {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n", s.Stage, s.Runs,
+			round(s.Min), round(s.Median), round(s.P90), round(s.Max), round(s.Mean), formatBytes(s.AllocBytesPerRun))
+	}
+	_ = w.Flush()
+	return sb.String()
+}
+
+func round(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+func formatBytes(n uint64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGiB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
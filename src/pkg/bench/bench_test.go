@@ -0,0 +1,29 @@
+package bench
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRecorder_Stats(t *testing.T) {
+	r := NewRecorder()
+	for _, ms := range []int{5, 1, 4, 2, 3, 6, 7, 8, 9, 10} {
+		r.Add("build", Sample{Duration: time.Duration(ms) * time.Millisecond, AllocBytes: 100})
+	}
+	r.Add("diff", Sample{Duration: time.Millisecond, AllocBytes: 10})
+	if err := r.Measure("evaluate", func() error { return errors.New("boom") }); err == nil {
+		t.Fatal("Measure() error = nil, want the stage error")
+	}
+
+	want := []StageStats{
+		{Stage: "build", Runs: 10, Min: time.Millisecond, Median: 5 * time.Millisecond, P90: 9 * time.Millisecond,
+			Max: 10 * time.Millisecond, Mean: 5500 * time.Microsecond, AllocBytesPerRun: 100},
+		{Stage: "diff", Runs: 1, Min: time.Millisecond, Median: time.Millisecond, P90: time.Millisecond,
+			Max: time.Millisecond, Mean: time.Millisecond, AllocBytesPerRun: 10},
+	}
+	if got := r.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,335 @@
+// Package bitbucket is the Bitbucket Cloud API client of the bitbucket run mode: pull requests, their comments and
+// the checkouts of their branches
+package bitbucket
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitclone"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/transcript"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "bitbucket")
+
+const (
+	API_URL    = "https://api.bitbucket.org/2.0"
+	SERVER_URL = "https://bitbucket.org"
+)
+
+// API_HOSTS are the hosts of the Bitbucket Cloud API, git remotes and LFS objects, with their subdomains
+var API_HOSTS = []string{"bitbucket.org"}
+
+// COMMENTS_PER_PAGE is the page size of the comments listing, the maximum of the API
+const COMMENTS_PER_PAGE = 100
+
+// BitbucketClient defines the interface for Bitbucket Cloud API operations
+type BitbucketClient interface {
+	// GetPR retrieves pull request information
+	GetPR(ctx context.Context, repo string, id int) (*models.PullRequest, error)
+	// CreateComment creates a new comment on a pull request
+	CreateComment(ctx context.Context, repo string, id int, body string) (*models.Comment, error)
+	// UpdateComment updates an existing comment of a pull request
+	UpdateComment(ctx context.Context, repo string, id int, commentID int64, body string) error
+	// GetComments retrieves all comments of a pull request
+	GetComments(ctx context.Context, repo string, id int) ([]*models.Comment, error)
+	// FindToolComment finds an existing tool-generated comment containing the search string
+	FindToolComment(ctx context.Context, repo string, id int, searchString string) (*models.Comment, error)
+	// CheckoutAtPath clones and checks out specific ref at path with the specified options
+	CheckoutAtPath(ctx context.Context, repo, ref, path string, opts gitclone.Options) (string, error)
+}
+
+// Client handles Bitbucket Cloud REST API (2.0) interactions
+type Client struct {
+	apiURL    string
+	serverURL string
+	// token is a repository, project or workspace access token, else username and appPassword authenticate
+	token       string
+	username    string
+	appPassword string
+	http        *http.Client
+
+	// Executor runs the git commands of the checkouts, nil uses sandbox.DefaultExecutor
+	Executor sandbox.Executor
+	// FS receives the checkouts, nil for the disk, see gitclone.Cloner
+	FS fsys.FS
+	// Transcript records the comments published on the pull requests, nil records nothing
+	Transcript *transcript.Transcript
+}
+
+// SCM_NAME is the SCM of the transcript entries of the client
+const SCM_NAME = "bitbucket"
+
+// Ensure Client implements BitbucketClient
+var _ BitbucketClient = (*Client)(nil)
+
+// NewClient creates a new Bitbucket Cloud client, authenticated with BITBUCKET_TOKEN (an access token), else
+// with BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD
+func NewClient() (*Client, error) {
+	client := &Client{
+		apiURL:      API_URL,
+		serverURL:   SERVER_URL,
+		token:       os.Getenv("BITBUCKET_TOKEN"),
+		username:    os.Getenv("BITBUCKET_USERNAME"),
+		appPassword: os.Getenv("BITBUCKET_APP_PASSWORD"),
+		http:        httpclient.New(0),
+	}
+	if client.token == "" && (client.username == "" || client.appPassword == "") {
+		return nil, fmt.Errorf("Bitbucket credentials not found. Set BITBUCKET_TOKEN, or BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD environment variables")
+	}
+	return client, nil
+}
+
+// APIError is an error response of the Bitbucket API
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration // the Retry-After of a throttled response, 0 if none
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Bitbucket API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// IsAuthError reports whether err is the Bitbucket API rejecting the credentials (401 or 403)
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+}
+
+// RetryAfter reports whether err is the Bitbucket API throttling the requests (429), with the wait it asks for
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	return apiErr.RetryAfter, true
+}
+
+// endpoint is a side of a pull request, its branch and commit
+type endpoint struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+}
+
+// pullRequest is the pull request of the API, the fields used only
+type pullRequest struct {
+	ID          int       `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	State       string    `json:"state"`
+	Source      endpoint  `json:"source"`
+	Destination endpoint  `json:"destination"`
+	CreatedOn   time.Time `json:"created_on"`
+	UpdatedOn   time.Time `json:"updated_on"`
+}
+
+// comment is a comment of a pull request, inline comments are on a line of its diff
+type comment struct {
+	ID      int64 `json:"id"`
+	Content struct {
+		Raw string `json:"raw"`
+	} `json:"content"`
+	Deleted bool            `json:"deleted"`
+	Inline  json.RawMessage `json:"inline,omitempty"`
+	User    struct {
+		Nickname string `json:"nickname"`
+	} `json:"user"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+func (c *comment) comment() *models.Comment {
+	return &models.Comment{
+		ID:        c.ID,
+		Body:      c.Content.Raw,
+		User:      c.User.Nickname,
+		CreatedAt: c.CreatedOn,
+		UpdatedAt: c.UpdatedOn,
+	}
+}
+
+// page is a page of a listing, next is the URL of the next page
+type page[T any] struct {
+	Values []T    `json:"values"`
+	Next   string `json:"next"`
+}
+
+// GetPR retrieves pull request information. The commits are the ones of the API, abbreviated
+func (c *Client) GetPR(ctx context.Context, repo string, id int) (*models.PullRequest, error) {
+	var pr pullRequest
+	if err := c.do(ctx, http.MethodGet, c.pullRequestURL(repo, id), nil, &pr); err != nil {
+		return nil, fmt.Errorf("failed to get PR: %w", err)
+	}
+	return &models.PullRequest{
+		Number:  pr.ID,
+		Title:   pr.Title,
+		Body:    pr.Description,
+		BaseSHA: pr.Destination.Commit.Hash,
+		HeadSHA: pr.Source.Commit.Hash,
+		BaseRef: pr.Destination.Branch.Name,
+		HeadRef: pr.Source.Branch.Name,
+		State:   pr.State,
+		Merged:  pr.State == "MERGED",
+		Created: pr.CreatedOn,
+		Updated: pr.UpdatedOn,
+	}, nil
+}
+
+// CreateComment creates a new comment on a pull request
+func (c *Client) CreateComment(ctx context.Context, repo string, id int, body string) (*models.Comment, error) {
+	var created comment
+	err := c.do(ctx, http.MethodPost, c.pullRequestURL(repo, id)+"/comments", contentOf(body), &created)
+	c.record(transcript.OP_CREATE_COMMENT, fmt.Sprintf("%s#%d", repo, id), body, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	return created.comment(), nil
+}
+
+// UpdateComment updates an existing comment of a pull request
+func (c *Client) UpdateComment(ctx context.Context, repo string, id int, commentID int64, body string) error {
+	url := fmt.Sprintf("%s/comments/%d", c.pullRequestURL(repo, id), commentID)
+	err := c.do(ctx, http.MethodPut, url, contentOf(body), nil)
+	c.record(transcript.OP_UPDATE_COMMENT, fmt.Sprintf("%s#%d comment %d", repo, id, commentID), body, err)
+	if err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+	return nil
+}
+
+// GetComments retrieves all comments of a pull request, oldest first. Deleted and inline comments are left out
+func (c *Client) GetComments(ctx context.Context, repo string, id int) ([]*models.Comment, error) {
+	var allComments []*models.Comment
+	url := fmt.Sprintf("%s/comments?pagelen=%d&sort=created_on", c.pullRequestURL(repo, id), COMMENTS_PER_PAGE)
+	for url != "" {
+		var comments page[comment]
+		if err := c.do(ctx, http.MethodGet, url, nil, &comments); err != nil {
+			return nil, fmt.Errorf("failed to get comments: %w", err)
+		}
+		for i := range comments.Values {
+			if !comments.Values[i].Deleted && len(comments.Values[i].Inline) == 0 {
+				allComments = append(allComments, comments.Values[i].comment())
+			}
+		}
+		url = comments.Next
+	}
+	return allComments, nil
+}
+
+// FindToolComment finds an existing tool-generated comment containing the search string
+// If multiple comments with the same marker exist, returns the first one found
+func (c *Client) FindToolComment(ctx context.Context, repo string, id int, searchString string) (*models.Comment, error) {
+	comments, err := c.GetComments(ctx, repo, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		if strings.Contains(comment.Body, searchString) {
+			return comment, nil
+		}
+	}
+	return nil, nil // Returns nil if not found
+}
+
+// CheckoutAtPath clones and checks out specific ref of a repository at path with the specified options, see
+// gitclone.Cloner. returns the directory containing the checked out files
+func (c *Client) CheckoutAtPath(ctx context.Context, repo, branch, path string, opts gitclone.Options) (string, error) {
+	cloner := &gitclone.Cloner{Executor: c.Executor, FS: c.FS}
+	// Access tokens authenticate git over HTTPS with the x-token-auth username, app passwords with their user
+	remote := gitclone.Remote{URL: fmt.Sprintf("%s/%s.git", c.serverURL, strings.Trim(repo, "/")), Username: "x-token-auth", Token: c.token}
+	if c.token == "" {
+		remote.Username, remote.Token = c.username, c.appPassword
+	}
+	return cloner.CheckoutAtPath(ctx, remote, branch, path, opts)
+}
+
+// pullRequestURL returns the API URL of a pull request of a repository, e.g. "workspace/repo-slug"
+func (c *Client) pullRequestURL(repo string, id int) string {
+	return fmt.Sprintf("%s/repositories/%s/pullrequests/%d", c.apiURL, strings.Trim(repo, "/"), id)
+}
+
+// GetPipelineURL returns the URL of the Bitbucket Pipelines run, from the BITBUCKET_GIT_HTTP_ORIGIN and
+// BITBUCKET_BUILD_NUMBER variables of the pipeline
+func GetPipelineURL() (string, error) {
+	origin, build := os.Getenv("BITBUCKET_GIT_HTTP_ORIGIN"), os.Getenv("BITBUCKET_BUILD_NUMBER")
+	if origin == "" || build == "" {
+		return "", fmt.Errorf("BITBUCKET_GIT_HTTP_ORIGIN or BITBUCKET_BUILD_NUMBER is not set")
+	}
+	return fmt.Sprintf("%s/pipelines/results/%s", strings.TrimSuffix(origin, "/"), build), nil
+}
+
+// contentOf is the request body of a comment, its markdown content
+func contentOf(body string) map[string]interface{} {
+	return map[string]interface{}{"content": map[string]string{"raw": body}}
+}
+
+// do sends a request to the API URL with a JSON body, if not nil, and decodes the JSON response into out, if not nil
+func (c *Client) do(ctx context.Context, method, url string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else {
+		req.SetBasicAuth(c.username, c.appPassword)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	logger.WithField("method", method).WithField("url", url).WithField("status", resp.StatusCode).Debug("Bitbucket API call")
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &APIError{StatusCode: resp.StatusCode, RetryAfter: time.Duration(retryAfter) * time.Second, Message: strings.TrimSpace(string(message))}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// record records a comment mutation with its outcome on the transcript of the client, if any
+func (c *Client) record(operation, target, body string, err error) {
+	c.Transcript.Record(transcript.Entry{SCM: SCM_NAME, Operation: operation, Target: target, Body: body}, err)
+}
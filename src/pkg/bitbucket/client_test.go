@@ -0,0 +1,64 @@
+package bitbucket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetComments(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer bb-token" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path != "/repositories/ws/repo/pullrequests/5/comments" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("page") == "" {
+			_, _ = w.Write([]byte(`{"values": [
+				{"id": 1, "content": {"raw": "first"}, "user": {"nickname": "reviewer"}},
+				{"id": 2, "content": {"raw": "nit"}, "inline": {"path": "app.yaml", "to": 3}},
+				{"id": 3, "content": {"raw": "removed"}, "deleted": true}
+			], "next": "` + srv.URL + `/repositories/ws/repo/pullrequests/5/comments?page=2"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"values": [{"id": 4, "content": {"raw": "[//]: # (marker)\n\nreport"}}]}`))
+	}))
+	defer srv.Close()
+	c := &Client{apiURL: srv.URL, token: "bb-token", http: srv.Client()}
+
+	comments, err := c.GetComments(context.Background(), "ws/repo", 5)
+	if err != nil {
+		t.Fatalf("GetComments() error = %v", err)
+	}
+	if len(comments) != 2 || comments[0].ID != 1 || comments[0].User != "reviewer" || comments[1].ID != 4 {
+		t.Errorf("GetComments() = %+v, want the comments of both pages but the inline and deleted ones", comments)
+	}
+	found, err := c.FindToolComment(context.Background(), "ws/repo", 5, "[//]: # (marker)")
+	if err != nil || found == nil || found.ID != 4 {
+		t.Errorf("FindToolComment() = %+v, %v", found, err)
+	}
+}
+
+func TestGetPR(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "bot" || password != "app-password" {
+			t.Errorf("basic auth = %q, %q", user, password)
+		}
+		_, _ = w.Write([]byte(`{"id": 5, "title": "Bump web", "state": "OPEN",
+			"source": {"branch": {"name": "bump-web"}, "commit": {"hash": "abc123"}},
+			"destination": {"branch": {"name": "main"}, "commit": {"hash": "def456"}}}`))
+	}))
+	defer srv.Close()
+	c := &Client{apiURL: srv.URL, username: "bot", appPassword: "app-password", http: srv.Client()}
+
+	pr, err := c.GetPR(context.Background(), "ws/repo", 5)
+	if err != nil {
+		t.Fatalf("GetPR() error = %v", err)
+	}
+	if pr.Number != 5 || pr.HeadRef != "bump-web" || pr.HeadSHA != "abc123" || pr.BaseRef != "main" || pr.BaseSHA != "def456" {
+		t.Errorf("GetPR() = %+v", pr)
+	}
+}
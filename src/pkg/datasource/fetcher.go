@@ -0,0 +1,185 @@
+package datasource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "datasource")
+
+const (
+	DEFAULT_TTL           = time.Hour
+	DEFAULT_TIMEOUT       = 10 * time.Second
+	MAX_RESPONSE_BYTES    = 10 << 20 // 10MiB, external data is meant to be small lookup tables
+	CACHE_FILE_NAME_FMT   = "%s-%s.json"
+	DATA_FILE_NAME_FMT    = "%s.json"
+	DEFAULT_CACHE_DIRNAME = "gitops-kustomzchk-external-data"
+)
+
+// Fetcher fetches external data sources over HTTP and caches them on disk with a TTL
+type Fetcher struct {
+	cacheDir string
+	client   *http.Client
+}
+
+// NewFetcher creates a new fetcher, an empty cacheDir uses a directory under os.TempDir()
+func NewFetcher(cacheDir string) *Fetcher {
+	if cacheDir == "" {
+		cacheDir = filepath.Join(os.TempDir(), DEFAULT_CACHE_DIRNAME)
+	}
+	return &Fetcher{
+		cacheDir: cacheDir,
+		client:   httpclient.New(0),
+	}
+}
+
+// FetchAll fetches all sources and writes each one into dataDir as <name>.json, wrapped as {"<name>": <document>}
+// so that conftest/OPA exposes it as data.<name>
+// returns: one record per source, in config order
+func (f *Fetcher) FetchAll(ctx context.Context, sources []models.ExternalDataSource, dataDir string) ([]models.ExternalDataRecord, error) {
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	records := make([]models.ExternalDataRecord, 0, len(sources))
+	for _, src := range sources {
+		content, record, err := f.Fetch(ctx, src)
+		if err != nil {
+			return nil, fmt.Errorf("external data %s: %w", src.Name, err)
+		}
+
+		wrapped, err := json.Marshal(map[string]json.RawMessage{src.Name: content})
+		if err != nil {
+			return nil, fmt.Errorf("external data %s: failed to wrap document: %w", src.Name, err)
+		}
+		dataPath := filepath.Join(dataDir, fmt.Sprintf(DATA_FILE_NAME_FMT, src.Name))
+		if err := os.WriteFile(dataPath, wrapped, 0644); err != nil {
+			return nil, fmt.Errorf("external data %s: failed to write data file: %w", src.Name, err)
+		}
+		records = append(records, *record)
+	}
+	return records, nil
+}
+
+// Fetch returns the JSON document of a single source, served from cache if younger than its TTL
+func (f *Fetcher) Fetch(ctx context.Context, src models.ExternalDataSource) ([]byte, *models.ExternalDataRecord, error) {
+	ttl, err := parseDurationOrDefault(src.TTL, DEFAULT_TTL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ttl %q: %w", src.TTL, err)
+	}
+	timeout, err := parseDurationOrDefault(src.Timeout, DEFAULT_TIMEOUT)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timeout %q: %w", src.Timeout, err)
+	}
+
+	cachePath := f.cachePath(src)
+	if ttl > 0 {
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < ttl {
+			content, err := os.ReadFile(cachePath)
+			if err == nil {
+				logger.WithField("name", src.Name).WithField("cachePath", cachePath).Info("Using cached external data")
+				return content, newRecord(src, content, info.ModTime(), true), nil
+			}
+			logger.WithField("name", src.Name).WithField("error", err).Warn("Failed to read cached external data, refetching")
+		}
+	}
+
+	content, err := f.download(ctx, src, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !json.Valid(content) {
+		return nil, nil, fmt.Errorf("response from %s is not valid JSON", src.URL)
+	}
+
+	fetchedAt := time.Now()
+	if ttl > 0 {
+		if err := os.MkdirAll(f.cacheDir, 0755); err != nil {
+			logger.WithField("error", err).Warn("Failed to create external data cache directory, not caching")
+		} else if err := os.WriteFile(cachePath, content, 0644); err != nil {
+			logger.WithField("error", err).Warn("Failed to write external data cache, not caching")
+		}
+	}
+	logger.WithField("name", src.Name).WithField("url", src.URL).WithField("sizeBytes", len(content)).Info("Fetched external data")
+	return content, newRecord(src, content, fetchedAt, false), nil
+}
+
+func (f *Fetcher) download(ctx context.Context, src models.ExternalDataSource, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range src.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", src.URL, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status %s", src.URL, resp.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, MAX_RESPONSE_BYTES+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", src.URL, err)
+	}
+	if len(content) > MAX_RESPONSE_BYTES {
+		return nil, fmt.Errorf("response from %s exceeds %d bytes", src.URL, MAX_RESPONSE_BYTES)
+	}
+	return content, nil
+}
+
+// cachePath is keyed by name and url so changing the url in config invalidates the cache
+func (f *Fetcher) cachePath(src models.ExternalDataSource) string {
+	urlSum := sha256.Sum256([]byte(src.URL))
+	return filepath.Join(f.cacheDir, fmt.Sprintf(CACHE_FILE_NAME_FMT, src.Name, hex.EncodeToString(urlSum[:])[:12]))
+}
+
+func newRecord(src models.ExternalDataSource, content []byte, fetchedAt time.Time, fromCache bool) *models.ExternalDataRecord {
+	return &models.ExternalDataRecord{
+		Name:      src.Name,
+		URL:       src.URL,
+		Digest:    Digest(content),
+		SizeBytes: len(content),
+		FetchedAt: fetchedAt,
+		FromCache: fromCache,
+	}
+}
+
+// Digest returns the sha256 digest of content in the form "sha256:<hex>"
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func parseDurationOrDefault(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	if value == "0" {
+		return 0, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,98 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestFetcher_FetchAll(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"images":["nginx:1.25"]}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("TEST_TOKEN", "secret")
+	sources := []models.ExternalDataSource{{
+		Name:    "allowlist",
+		URL:     server.URL,
+		Headers: map[string]string{"X-Token": "$TEST_TOKEN"},
+	}}
+
+	f := NewFetcher(t.TempDir())
+	dataDir := t.TempDir()
+
+	records, err := f.FetchAll(context.Background(), sources, dataDir)
+	if err != nil {
+		t.Fatalf("FetchAll() error = %v", err)
+	}
+	if len(records) != 1 || records[0].FromCache {
+		t.Fatalf("FetchAll() records = %+v, want 1 fresh record", records)
+	}
+	if records[0].Digest != Digest([]byte(`{"images":["nginx:1.25"]}`)) {
+		t.Errorf("FetchAll() digest = %s", records[0].Digest)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dataDir, "allowlist.json"))
+	if err != nil {
+		t.Fatalf("data file not written: %v", err)
+	}
+	var wrapped map[string]map[string][]string
+	if err := json.Unmarshal(content, &wrapped); err != nil {
+		t.Fatalf("data file is not valid JSON: %v", err)
+	}
+	if got := wrapped["allowlist"]["images"]; len(got) != 1 || got[0] != "nginx:1.25" {
+		t.Errorf("data file content = %s", string(content))
+	}
+
+	// Second fetch is served from cache
+	records, err = f.FetchAll(context.Background(), sources, dataDir)
+	if err != nil {
+		t.Fatalf("FetchAll() second call error = %v", err)
+	}
+	if !records[0].FromCache || hits != 1 {
+		t.Errorf("second FetchAll() fromCache = %v, hits = %d, want cached with 1 hit", records[0].FromCache, hits)
+	}
+}
+
+func TestFetcher_Fetch_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invalid":
+			_, _ = w.Write([]byte("not json"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		src  models.ExternalDataSource
+	}{
+		{name: "server error", src: models.ExternalDataSource{Name: "a", URL: server.URL + "/error", TTL: "0"}},
+		{name: "invalid json", src: models.ExternalDataSource{Name: "b", URL: server.URL + "/invalid", TTL: "0"}},
+		{name: "invalid ttl", src: models.ExternalDataSource{Name: "c", URL: server.URL, TTL: "forever"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := NewFetcher(t.TempDir())
+			if _, _, err := f.Fetch(context.Background(), tt.src); err == nil {
+				t.Errorf("Fetch() error = nil, want error")
+			}
+		})
+	}
+}
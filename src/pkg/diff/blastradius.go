@@ -0,0 +1,44 @@
+package diff
+
+import (
+	"reflect"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+var (
+	// rolloutKinds restart their pods when spec.template changes
+	rolloutKinds = map[string]bool{"Deployment": true, "StatefulSet": true, "DaemonSet": true}
+	// availabilityKinds change how many pods may be disrupted or scheduled
+	availabilityKinds = map[string]bool{"PodDisruptionBudget": true, "HorizontalPodAutoscaler": true}
+	// trafficKinds change how requests reach the pods
+	trafficKinds = map[string]bool{"Service": true, "Ingress": true, "HTTPRoute": true, "GRPCRoute": true, "Gateway": true}
+)
+
+// EstimateBlastRadius computes which changes have a runtime impact once applied
+// Added or removed workloads are not counted as rollouts, they show up in the resource changes
+func EstimateBlastRadius(changes []manifest.Change) models.BlastRadius {
+	var result models.BlastRadius
+	for _, change := range changes {
+		kind := change.Current().Kind
+		switch {
+		case rolloutKinds[kind]:
+			if change.Before != nil && change.After != nil && podTemplateChanged(*change.Before, *change.After) {
+				result.RolloutWorkloads = append(result.RolloutWorkloads, change.ID)
+			}
+		case availabilityKinds[kind]:
+			result.AvailabilityChanges = append(result.AvailabilityChanges, change.ID)
+		case trafficKinds[kind]:
+			result.TrafficChanges = append(result.TrafficChanges, change.ID)
+		}
+	}
+	return result
+}
+
+func podTemplateChanged(before, after manifest.Resource) bool {
+	return !reflect.DeepEqual(
+		manifest.Nested(before.Object, "spec", "template"),
+		manifest.Nested(after.Object, "spec", "template"),
+	)
+}
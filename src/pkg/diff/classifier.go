@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	RISK_CATEGORY_CRD            = "crd"
+	RISK_CATEGORY_RBAC           = "rbac"
+	RISK_CATEGORY_NAMESPACE      = "namespace"
+	RISK_CATEGORY_CLUSTER_SCOPED = "cluster-scoped"
+	RISK_CATEGORY_WORKLOAD       = "workload"
+)
+
+// DefaultRiskClassification is used for categories not overridden in the compliance config
+func DefaultRiskClassification() map[string]models.RiskCategoryConfig {
+	return map[string]models.RiskCategoryConfig{
+		RISK_CATEGORY_CRD: {
+			Kinds:    []string{"CustomResourceDefinition"},
+			HighRisk: true,
+		},
+		RISK_CATEGORY_RBAC: {
+			Kinds:     []string{"ServiceAccount"},
+			APIGroups: []string{"rbac.authorization.k8s.io"},
+			HighRisk:  true,
+		},
+		RISK_CATEGORY_NAMESPACE: {
+			Kinds:    []string{"Namespace"},
+			HighRisk: true,
+		},
+		RISK_CATEGORY_CLUSTER_SCOPED: {
+			Kinds: []string{
+				"ClusterRole", "ClusterRoleBinding", "CustomResourceDefinition", "Namespace",
+				"PersistentVolume", "StorageClass", "PriorityClass", "IngressClass", "RuntimeClass",
+				"MutatingWebhookConfiguration", "ValidatingWebhookConfiguration", "APIService",
+			},
+			HighRisk: true,
+		},
+		RISK_CATEGORY_WORKLOAD: {
+			Kinds: []string{"Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob", "Pod"},
+		},
+	}
+}
+
+// RiskClassifier assigns risk categories to resource changes
+type RiskClassifier struct {
+	categories map[string]models.RiskCategoryConfig
+	names      []string // sorted category names, for a stable output
+}
+
+// NewRiskClassifier creates a classifier from the default categories, overridden by name with the given ones
+// An override with neither kinds nor apiGroups disables the category
+func NewRiskClassifier(overrides map[string]models.RiskCategoryConfig) *RiskClassifier {
+	categories := DefaultRiskClassification()
+	for name, category := range overrides {
+		if len(category.Kinds) == 0 && len(category.APIGroups) == 0 {
+			delete(categories, name)
+			continue
+		}
+		categories[name] = category
+	}
+
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return &RiskClassifier{categories: categories, names: names}
+}
+
+// Classify returns the categories matched by the resource and whether any of them is high-risk
+func (c *RiskClassifier) Classify(res manifest.Resource) ([]string, bool) {
+	var matched []string
+	highRisk := false
+	for _, name := range c.names {
+		category := c.categories[name]
+		if !contains(category.Kinds, res.Kind) && !contains(category.APIGroups, res.Group()) {
+			continue
+		}
+		matched = append(matched, name)
+		highRisk = highRisk || category.HighRisk
+	}
+	return matched, highRisk
+}
+
+// ParseChanges parses both manifests and returns the resources added, removed or modified
+func ParseChanges(before, after []byte) ([]manifest.Change, error) {
+	beforeResources, err := manifest.Parse(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse before manifest: %w", err)
+	}
+	afterResources, err := manifest.Parse(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse after manifest: %w", err)
+	}
+	return manifest.Compare(beforeResources, afterResources), nil
+}
+
+// ResourceChanges returns the classified resource-level changes
+func (c *RiskClassifier) ResourceChanges(changes []manifest.Change) []models.ResourceChange {
+	results := make([]models.ResourceChange, 0, len(changes))
+	for _, change := range changes {
+		res := change.Current()
+		categories, highRisk := c.Classify(res)
+		results = append(results, models.ResourceChange{
+			ID:         change.ID,
+			APIVersion: res.APIVersion,
+			Kind:       res.Kind,
+			Name:       res.Name,
+			Namespace:  res.Namespace,
+			Action:     changeAction(change),
+			Categories: categories,
+			HighRisk:   highRisk,
+		})
+	}
+	return results
+}
+
+func changeAction(change manifest.Change) string {
+	if change.Before == nil {
+		return models.ResourceChangeAdded
+	}
+	if change.After == nil {
+		return models.ResourceChangeRemoved
+	}
+	return models.ResourceChangeModified
+}
+
+// HighRiskChanges filters the high-risk changes
+func HighRiskChanges(changes []models.ResourceChange) []models.ResourceChange {
+	var results []models.ResourceChange
+	for _, change := range changes {
+		if change.HighRisk {
+			results = append(results, change)
+		}
+	}
+	return results
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
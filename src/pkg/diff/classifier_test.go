@@ -0,0 +1,203 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const classifierBefore = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  replicas: 1
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+rules: []
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: legacy
+  namespace: app
+`
+
+const classifierAfter = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  replicas: 2
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+rules: []
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`
+
+func TestRiskClassifier_ResourceChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]models.RiskCategoryConfig
+		want      []models.ResourceChange
+	}{
+		{
+			name: "default classification",
+			want: []models.ResourceChange{
+				{ID: "Deployment/app/web", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Namespace: "app", Action: models.ResourceChangeModified, Categories: []string{"workload"}},
+				{ID: "Namespace/app", APIVersion: "v1", Kind: "Namespace", Name: "app", Action: models.ResourceChangeAdded, Categories: []string{"cluster-scoped", "namespace"}, HighRisk: true},
+				{ID: "ConfigMap/app/legacy", APIVersion: "v1", Kind: "ConfigMap", Name: "legacy", Namespace: "app", Action: models.ResourceChangeRemoved},
+			},
+		},
+		{
+			name: "overridden classification",
+			overrides: map[string]models.RiskCategoryConfig{
+				"workload":       {Kinds: []string{"Deployment"}, HighRisk: true},
+				"cluster-scoped": {},
+				"config":         {APIGroups: []string{""}, Kinds: []string{"ConfigMap"}},
+			},
+			want: []models.ResourceChange{
+				{ID: "Deployment/app/web", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Namespace: "app", Action: models.ResourceChangeModified, Categories: []string{"workload"}, HighRisk: true},
+				{ID: "Namespace/app", APIVersion: "v1", Kind: "Namespace", Name: "app", Action: models.ResourceChangeAdded, Categories: []string{"config", "namespace"}, HighRisk: true},
+				{ID: "ConfigMap/app/legacy", APIVersion: "v1", Kind: "ConfigMap", Name: "legacy", Namespace: "app", Action: models.ResourceChangeRemoved, Categories: []string{"config"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := ParseChanges([]byte(classifierBefore), []byte(classifierAfter))
+			if err != nil {
+				t.Fatalf("ParseChanges() error = %v", err)
+			}
+			got := NewRiskClassifier(tt.overrides).ResourceChanges(changes)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResourceChanges() = %+v, want %+v", got, tt.want)
+			}
+			if len(HighRiskChanges(got)) == 0 {
+				t.Errorf("HighRiskChanges() should not be empty")
+			}
+		})
+	}
+}
+
+func TestEstimateBlastRadius(t *testing.T) {
+	before := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: scaled
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers: [{name: app, image: app:1}]
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: bumped
+spec:
+  template:
+    spec:
+      containers: [{name: app, image: app:1}]
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports: [{port: 80}]
+`
+	after := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: scaled
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers: [{name: app, image: app:1}]
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: bumped
+spec:
+  template:
+    spec:
+      containers: [{name: app, image: app:2}]
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports: [{port: 8080}]
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: web
+spec:
+  minAvailable: 1
+`
+	changes, err := ParseChanges([]byte(before), []byte(after))
+	if err != nil {
+		t.Fatalf("ParseChanges() error = %v", err)
+	}
+	got := EstimateBlastRadius(changes)
+	want := models.BlastRadius{
+		RolloutWorkloads:    []string{"Deployment/bumped"},
+		AvailabilityChanges: []string{"PodDisruptionBudget/web"},
+		TrafficChanges:      []string{"Service/web"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EstimateBlastRadius() = %+v, want %+v", got, want)
+	}
+	if !got.TriggersRollout() {
+		t.Errorf("TriggersRollout() = false, want true")
+	}
+}
+
+func TestLinkViolations(t *testing.T) {
+	envDiff := models.EnvironmentDiff{
+		ResourceChanges: []models.ResourceChange{{ID: "Deployment/app/web"}, {ID: "Service/app/web"}},
+	}
+	matrix := models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{
+			PolicyName: "High Availability",
+			Violations: []models.PolicyViolation{
+				{Message: "too few replicas", ResourceID: "Deployment/app/web"},
+				{Message: "unchanged resource", ResourceID: "Deployment/app/api"},
+				{Message: "unknown resource"},
+			},
+		}},
+	}
+
+	LinkViolations("alpha/stg", &envDiff, &matrix)
+
+	wantAnchor := "diff-alpha-stg-deployment-app-web"
+	if got := envDiff.ResourceChanges[0]; got.Anchor != wantAnchor || !reflect.DeepEqual(got.ViolatedPolicies, []string{"High Availability"}) {
+		t.Errorf("linked change = %+v", got)
+	}
+	if got := envDiff.ResourceChanges[1]; got.ViolatedPolicies != nil {
+		t.Errorf("unrelated change should not be linked, got %+v", got)
+	}
+	violations := matrix.BlockingPolicies[0].Violations
+	if violations[0].DiffAnchor != wantAnchor || violations[1].DiffAnchor != "" || violations[2].DiffAnchor != "" {
+		t.Errorf("linked violations = %+v", violations)
+	}
+}
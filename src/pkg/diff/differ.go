@@ -2,28 +2,64 @@ package diff
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
+	"runtime"
 	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
 )
 
 // ManifestDiffer defines the interface for comparing Kubernetes manifests
 type ManifestDiffer interface {
 	// Diff compares two manifests and returns a unified diff
 	Diff(before, after []byte) (string, error)
+	// DiffContext is Diff, tracing the external diff process as a child span of ctx
+	DiffContext(ctx context.Context, before, after []byte) (string, error)
 	DiffText(before, after string) (string, error)
 }
 
+const (
+	DIFF_ENGINE_EXTERNAL = "external" // system `diff -u`
+	DIFF_ENGINE_NATIVE   = "native"   // pure Go implementation with the same output, for runners without GNU diff
+)
+
+var DIFF_ENGINES = []string{DIFF_ENGINE_EXTERNAL, DIFF_ENGINE_NATIVE}
+
+const (
+	DIFF_STYLE_UNIFIED = "unified" // line by line, with the engine
+	DIFF_STYLE_DYFF    = "dyff"    // path by path of each changed resource, see DyffDiff
+)
+
+var DIFF_STYLES = []string{DIFF_STYLE_UNIFIED, DIFF_STYLE_DYFF}
+
 // Differ handles manifest diffing
-type Differ struct{}
+type Differ struct {
+	Engine   string           // DIFF_ENGINE_EXTERNAL or DIFF_ENGINE_NATIVE, see DefaultEngine
+	Style    string           // DIFF_STYLE_UNIFIED (empty) or DIFF_STYLE_DYFF, which needs no engine
+	Executor sandbox.Executor // Runs the external diff, nil uses sandbox.DefaultExecutor
+}
 
 // Ensure Differ implements ManifestDiffer
 var _ ManifestDiffer = (*Differ)(nil)
 
-// NewDiffer creates a new differ
+// DefaultEngine returns the default engine of the platform: native on Windows, which has no diff binary
+func DefaultEngine() string {
+	if runtime.GOOS == "windows" {
+		return DIFF_ENGINE_NATIVE
+	}
+	return DIFF_ENGINE_EXTERNAL
+}
+
+// NewDiffer creates a new differ using the default engine of the platform
 func NewDiffer() *Differ {
-	return &Differ{}
+	return &Differ{Engine: DefaultEngine()}
+}
+
+// NewDifferWithOptions creates a new differ using the given engine
+func NewDifferWithOptions(engine string) *Differ {
+	return &Differ{Engine: engine}
 }
 
 // Convert text to bytes and call Diff
@@ -33,12 +69,23 @@ func (d *Differ) DiffText(before, after string) (string, error) {
 
 // Diff compares two manifests and returns a unified diff
 func (d *Differ) Diff(before, after []byte) (string, error) {
+	return d.DiffContext(context.Background(), before, after)
+}
+
+// DiffContext compares two manifests and returns a unified diff, or a dyff diff with DIFF_STYLE_DYFF
+func (d *Differ) DiffContext(ctx context.Context, before, after []byte) (string, error) {
+	if d.Style == DIFF_STYLE_DYFF {
+		return DyffDiff(before, after)
+	}
+	if d.Engine == DIFF_ENGINE_NATIVE {
+		return d.nativeUnifiedDiff(before, after)
+	}
 	// Use system diff -u for unified diff with context
-	return d.unifiedDiff(before, after)
+	return d.unifiedDiff(ctx, before, after)
 }
 
 // unifiedDiff uses system diff -u command for proper unified diff with context
-func (d *Differ) unifiedDiff(before, after []byte) (string, error) {
+func (d *Differ) unifiedDiff(ctx context.Context, before, after []byte) (string, error) {
 	if bytes.Equal(before, after) {
 		return "", nil
 	}
@@ -78,20 +125,19 @@ func (d *Differ) unifiedDiff(before, after []byte) (string, error) {
 	}
 
 	// Run diff -u
-	cmd := exec.Command("diff", "-u", beforeFile.Name(), afterFile.Name())
-	output, err := cmd.CombinedOutput()
-
-	// diff returns exit code 1 when files differ (not an error)
+	res, err := sandbox.OrDefault(d.Executor).Run(ctx, sandbox.Command{
+		Name: "diff", Args: []string{"-u", beforeFile.Name(), afterFile.Name()}, Limits: sandbox.DefaultLimits(),
+	})
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			// This is expected when files differ
-		} else {
-			return "", fmt.Errorf("diff command failed: %w", err)
-		}
+		return "", fmt.Errorf("diff command failed: %w", err)
+	}
+	// diff returns exit code 1 when files differ (not an error)
+	if res.ExitCode > 1 {
+		return "", fmt.Errorf("diff command failed: exit status %d: %s", res.ExitCode, strings.TrimSpace(string(res.Stderr)))
 	}
 
 	// Replace temp file names with "before" and "after"
-	diffOutput := string(output)
+	diffOutput := string(res.Stdout)
 	diffOutput = strings.ReplaceAll(diffOutput, beforeFile.Name(), "before")
 	diffOutput = strings.ReplaceAll(diffOutput, afterFile.Name(), "after")
 
@@ -1,15 +1,18 @@
 package diff
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
 )
 
 // normalizeTimestamps replaces timestamps in diff output with a placeholder
 func normalizeTimestamps(diff string) string {
-	// Replace timestamps like "2025-10-23 00:45:23" with "TIMESTAMP"
-	re := regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`)
+	// Replace timestamps like "2025-10-23 00:45:23.123456789 +0000" with "TIMESTAMP"
+	re := regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}(\.\d+)?( [+-]\d{4})?`)
 	return re.ReplaceAllString(diff, "TIMESTAMP")
 }
 
@@ -123,7 +126,7 @@ func TestDiffer_unifiedDiff(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			d := NewDiffer()
-			result, err := d.unifiedDiff(tt.before, tt.after)
+			result, err := d.unifiedDiff(context.Background(), tt.before, tt.after)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("unifiedDiff() error = %v, wantErr %v", err, tt.wantErr)
@@ -293,3 +296,30 @@ func BenchmarkDiffer_Diff(b *testing.B) {
 		}
 	}
 }
+
+// TestDiffer_Executor tests that the external diff runs with the injected executor
+func TestDiffer_Executor(t *testing.T) {
+	fake := &sandbox.FakeExecutor{Handler: func(cmd sandbox.Command) (*sandbox.Result, error) {
+		output := "--- " + cmd.Args[1] + "\n+++ " + cmd.Args[2] + "\n@@ -1 +1 @@\n-old\n+new\n"
+		return &sandbox.Result{Stdout: []byte(output), ExitCode: 1}, nil
+	}}
+	d := &Differ{Engine: DIFF_ENGINE_EXTERNAL, Executor: fake}
+
+	result, err := d.Diff([]byte("old\n"), []byte("new\n"))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if want := "--- before\n+++ after\n@@ -1 +1 @@\n-old\n+new\n"; result != want {
+		t.Errorf("Diff() = %q, want %q", result, want)
+	}
+	if commands := fake.Commands(); len(commands) != 1 || commands[0].Name != "diff" || commands[0].Args[0] != "-u" {
+		t.Errorf("expected a single diff -u, got %v", commands)
+	}
+
+	fake.Handler = func(cmd sandbox.Command) (*sandbox.Result, error) {
+		return &sandbox.Result{Stderr: []byte("diff: memory exhausted"), ExitCode: 2}, nil
+	}
+	if _, err := d.Diff([]byte("old\n"), []byte("new\n")); err == nil || !strings.Contains(err.Error(), "memory exhausted") {
+		t.Errorf("expected exit code 2 to be an error with stderr, got: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package diff
+
+import (
+	"slices"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// EnvironmentDrift compares the manifests of two environments of a service: the images of the containers found
+// in both, and the resources found in one only. Resources are matched by Kind/name, ignoring their namespace
+func EnvironmentDrift(from, to []byte) (*models.EnvironmentDriftReport, error) {
+	fromResources, err := manifest.Parse(from)
+	if err != nil {
+		return nil, err
+	}
+	toResources, err := manifest.Parse(to)
+	if err != nil {
+		return nil, err
+	}
+	toByID := make(map[string]manifest.Resource, len(toResources))
+	for _, res := range toResources {
+		toByID[driftID(res)] = res
+	}
+
+	report := &models.EnvironmentDriftReport{}
+	seen := make(map[string]bool, len(fromResources))
+	for _, res := range fromResources {
+		id := driftID(res)
+		seen[id] = true
+		target, ok := toByID[id]
+		if !ok {
+			report.MissingResources = append(report.MissingResources, id)
+			continue
+		}
+		report.Images = append(report.Images, imageDriftsOf(id, res, target)...)
+	}
+	for _, res := range toResources {
+		if id := driftID(res); !seen[id] {
+			report.ExtraResources = append(report.ExtraResources, id)
+		}
+	}
+	slices.Sort(report.MissingResources)
+	slices.Sort(report.ExtraResources)
+	return report, nil
+}
+
+// driftID identifies a resource across environments: "Kind/name"
+func driftID(res manifest.Resource) string {
+	return res.Kind + "/" + res.Name
+}
+
+// imageDriftsOf returns the containers of a resource, by name, whose image differs in the target environment
+func imageDriftsOf(id string, from, to manifest.Resource) []models.ImageDrift {
+	toImages := make(map[string]string)
+	for _, image := range manifest.ContainerImages(to.Object) {
+		toImages[image.Container] = image.Image
+	}
+	var drifts []models.ImageDrift
+	for _, image := range manifest.ContainerImages(from.Object) {
+		target, ok := toImages[image.Container]
+		if !ok || target == image.Image {
+			continue
+		}
+		fromRef, toRef := manifest.ParseImage(image.Image), manifest.ParseImage(target)
+		drifts = append(drifts, models.ImageDrift{
+			ResourceID: id,
+			Container:  image.Container,
+			From:       image.Image,
+			To:         target,
+			Behind:     fromRef.Name == toRef.Name && semverLess(toRef.Tag, fromRef.Tag),
+		})
+	}
+	return drifts
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestEnvironmentDrift(t *testing.T) {
+	stg := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app-stg
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: ghcr.io/acme/web:v1.3.0
+        - name: proxy
+          image: envoyproxy/envoy:v1.30.0
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: feature-flags
+  namespace: app-stg
+`
+	prod := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app-prod
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: ghcr.io/acme/web:v1.2.3
+        - name: proxy
+          image: ghcr.io/acme/envoy:v1.30.0
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: web
+  namespace: app-prod
+`
+	got, err := EnvironmentDrift([]byte(stg), []byte(prod))
+	if err != nil {
+		t.Fatalf("EnvironmentDrift() error = %v", err)
+	}
+	want := &models.EnvironmentDriftReport{
+		Images: []models.ImageDrift{
+			{ResourceID: "Deployment/web", Container: "web", From: "ghcr.io/acme/web:v1.3.0", To: "ghcr.io/acme/web:v1.2.3", Behind: true},
+			{ResourceID: "Deployment/web", Container: "proxy", From: "envoyproxy/envoy:v1.30.0", To: "ghcr.io/acme/envoy:v1.30.0"},
+		},
+		MissingResources: []string{"ConfigMap/feature-flags"},
+		ExtraResources:   []string{"PodDisruptionBudget/web"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvironmentDrift() = %+v, want %+v", got, want)
+	}
+
+	same, err := EnvironmentDrift([]byte(stg), []byte(stg))
+	if err != nil || same.HasDrift() {
+		t.Errorf("EnvironmentDrift() of the same manifest = %+v, %v, want no drift", same, err)
+	}
+}
@@ -0,0 +1,199 @@
+package diff
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Prefixes of the lines of a dyff diff, the resource headers are "@@ <id> (<action>) @@" so that the diff
+// highlighting of the SCMs colors them as the unified diffs
+const (
+	DYFF_ADDED    = "+ "
+	DYFF_REMOVED  = "- "
+	DYFF_MODIFIED = "! "
+)
+
+// dyffArrow separates the before and after values of a modified path
+const dyffArrow = " → "
+
+// DyffDiff compares two manifests path by path, like dyff: each changed resource has a header followed by its
+// added, removed and modified leaf paths, e.g. "! spec.replicas: 1 → 3". List items with a unique name are matched
+// by name, multi-line strings get a line diff. Resources are in the order of manifest.Compare
+func DyffDiff(before, after []byte) (string, error) {
+	changes, err := ParseChanges(before, after)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, change := range changes {
+		var lines []string
+		var beforeObj, afterObj interface{}
+		if change.Before != nil {
+			beforeObj = change.Before.Object
+		}
+		if change.After != nil {
+			afterObj = change.After.Object
+		}
+		lines = dyffLines(lines, "", beforeObj, afterObj)
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "@@ %s (%s) @@\n", change.ID, changeAction(change))
+		for _, line := range lines {
+			sb.WriteString(line)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String(), nil
+}
+
+// dyffLines appends the changes of a value at path, nil before or after for an added or a removed value
+func dyffLines(lines []string, path string, before, after interface{}) []string {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	switch {
+	case beforeIsMap && afterIsMap, beforeIsMap && after == nil, before == nil && afterIsMap:
+		keys := slices.Collect(maps.Keys(beforeMap))
+		for key := range afterMap {
+			if _, ok := beforeMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			lines = dyffLines(lines, dyffPath(path, key), beforeMap[key], afterMap[key])
+		}
+		if len(keys) > 0 {
+			return lines
+		}
+	}
+
+	beforeList, beforeIsList := before.([]interface{})
+	afterList, afterIsList := after.([]interface{})
+	if (beforeIsList || before == nil) && (afterIsList || after == nil) && len(beforeList)+len(afterList) > 0 {
+		if names, ok := dyffListNames(beforeList, afterList); ok {
+			for _, name := range names {
+				lines = dyffLines(lines, dyffPath(path, name), dyffNamed(beforeList, name), dyffNamed(afterList, name))
+			}
+			return lines
+		}
+		if !dyffScalars(beforeList) || !dyffScalars(afterList) {
+			for i := range max(len(beforeList), len(afterList)) {
+				var b, a interface{}
+				if i < len(beforeList) {
+					b = beforeList[i]
+				}
+				if i < len(afterList) {
+					a = afterList[i]
+				}
+				lines = dyffLines(lines, fmt.Sprintf("%s[%d]", path, i), b, a)
+			}
+			return lines
+		}
+	}
+
+	switch {
+	case reflect.DeepEqual(before, after):
+		return lines
+	case before == nil:
+		return append(lines, DYFF_ADDED+path+": "+dyffValue(after))
+	case after == nil:
+		return append(lines, DYFF_REMOVED+path+": "+dyffValue(before))
+	}
+	beforeText, beforeIsText := before.(string)
+	afterText, afterIsText := after.(string)
+	if beforeIsText && afterIsText && (strings.Contains(beforeText, "\n") || strings.Contains(afterText, "\n")) {
+		lines = append(lines, DYFF_MODIFIED+path+": (multi-line)")
+		for _, line := range strings.Split(strings.TrimSuffix(Hunks([]byte(beforeText), []byte(afterText)), "\n"), "\n") {
+			if line == "" || strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "\\") {
+				continue
+			}
+			lines = append(lines, line[:1]+"   "+line[1:])
+		}
+		return lines
+	}
+	return append(lines, DYFF_MODIFIED+path+": "+dyffValue(before)+dyffArrow+dyffValue(after))
+}
+
+// dyffPath appends a key to a path, in brackets if it has dots or spaces (e.g. labels["app.kubernetes.io/name"])
+func dyffPath(path, key string) string {
+	if key == "" || strings.ContainsAny(key, ". []\"") {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// dyffListNames returns the names of the items of two lists of named objects (containers, env, ports...), in
+// before then added order. False if an item has no name or a name is not unique
+func dyffListNames(before, after []interface{}) ([]string, bool) {
+	var names []string
+	for _, list := range [][]interface{}{before, after} {
+		seen := map[string]bool{}
+		for _, item := range list {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			name, ok := obj["name"].(string)
+			if !ok || seen[name] {
+				return nil, false
+			}
+			seen[name] = true
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, true
+}
+
+// dyffNamed returns the item of a list of named objects with a name, nil if none
+func dyffNamed(list []interface{}, name string) interface{} {
+	for _, item := range list {
+		if item.(map[string]interface{})["name"] == name {
+			return item
+		}
+	}
+	return nil
+}
+
+// dyffScalars reports whether a list has no map or list items, such lists are compared as a whole
+func dyffScalars(list []interface{}) bool {
+	return !slices.ContainsFunc(list, func(item interface{}) bool {
+		switch item.(type) {
+		case map[string]interface{}, []interface{}:
+			return true
+		}
+		return false
+	})
+}
+
+// dyffValue formats a leaf value on one line: scalars as is, lists of scalars inline, empty strings and
+// collections quoted
+func dyffValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		if v == "" || strings.Contains(v, "\n") {
+			return fmt.Sprintf("%q", v)
+		}
+		return v
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, dyffValue(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]interface{}:
+		return "{}"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,96 @@
+package diff
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestDyffDiff checks the path by path diffs of added, removed and modified resources
+func TestDyffDiff(t *testing.T) {
+	deployment := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+  labels:
+    app.kubernetes.io/name: web
+spec:
+  replicas: %s
+  template:
+    spec:
+      containers:
+      - name: sidecar
+        image: envoy:1.0
+      - name: web
+        image: %s
+        args: [--port, "8080"]
+`
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   string
+	}{
+		{
+			name:   "identical",
+			before: fmt.Sprintf(deployment, "1", "web:1.0"),
+			after:  fmt.Sprintf(deployment, "1", "web:1.0"),
+			want:   "",
+		},
+		{
+			name:   "replicas and container matched by name",
+			before: fmt.Sprintf(deployment, "1", "web:1.0"),
+			after:  fmt.Sprintf(deployment, "3", "web:1.1"),
+			want: "@@ Deployment/app/web (modified) @@\n" +
+				"! spec.replicas: 1 → 3\n" +
+				"! spec.template.spec.containers.web.image: web:1.0 → web:1.1\n",
+		},
+		{
+			name:   "label added, dotted key",
+			before: "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n",
+			after:  "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  labels:\n    app.kubernetes.io/name: web\n    team: payments\n",
+			want: "@@ Service/web (modified) @@\n" +
+				"+ metadata.labels[\"app.kubernetes.io/name\"]: web\n" +
+				"+ metadata.labels.team: payments\n",
+		},
+		{
+			name:   "scalar list compared as a whole",
+			before: "apiVersion: v1\nkind: Pod\nmetadata:\n  name: p\nspec:\n  args: [a, b]\n",
+			after:  "apiVersion: v1\nkind: Pod\nmetadata:\n  name: p\nspec:\n  args: [a, c]\n",
+			want: "@@ Pod/p (modified) @@\n" +
+				"! spec.args: [a, b] → [a, c]\n",
+		},
+		{
+			name:   "resource removed",
+			before: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  key: value\n",
+			after:  "",
+			want: "@@ ConfigMap/cfg (removed) @@\n" +
+				"- apiVersion: v1\n" +
+				"- data.key: value\n" +
+				"- kind: ConfigMap\n" +
+				"- metadata.name: cfg\n",
+		},
+		{
+			name:   "multi-line string",
+			before: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  app.conf: |\n    a=1\n    b=2\n",
+			after:  "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cfg\ndata:\n  app.conf: |\n    a=1\n    b=3\n",
+			want: "@@ ConfigMap/cfg (modified) @@\n" +
+				"! data[\"app.conf\"]: (multi-line)\n" +
+				"    a=1\n" +
+				"-   b=2\n" +
+				"+   b=3\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DyffDiff([]byte(tt.before), []byte(tt.after))
+			if err != nil {
+				t.Fatalf("DyffDiff() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DyffDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package diff
+
+import (
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+var semverPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// RoutineImageBump reports whether every change between two manifests is a routine image bump per cfg
+func RoutineImageBump(before, after []byte, cfg *models.ImageBumpConfig) bool {
+	changes, err := ParseChanges(before, after)
+	if err != nil {
+		return false
+	}
+	return ImageBumpsOf(changes, cfg) != nil
+}
+
+// ImageBumpsOf returns the image updates of the changes when all of them are routine image bumps per cfg:
+// modified resources differing only by container images, each keeping its repository (matching cfg.Repositories
+// if set) and, with cfg.Semver, moving to a higher semver tag. Nil if any change is something else, or none.
+func ImageBumpsOf(changes []manifest.Change, cfg *models.ImageBumpConfig) []models.ImageBump {
+	if cfg == nil || len(changes) == 0 {
+		return nil
+	}
+	var bumps []models.ImageBump
+	for _, change := range changes {
+		if change.Before == nil || change.After == nil {
+			return nil
+		}
+		var found []models.ImageBump
+		if !diffImages(change.Before.Object, change.After.Object, false, &found) || len(found) == 0 {
+			return nil
+		}
+		for _, bump := range found {
+			if !isRoutineBump(bump.Before, bump.After, cfg) {
+				return nil
+			}
+			bump.ResourceID = change.ID
+			bumps = append(bumps, bump)
+		}
+	}
+	return bumps
+}
+
+// diffImages compares two decoded documents, collecting the container image updates into found,
+// and returns false if anything else differs. inContainers is set for the elements of a container list.
+func diffImages(before, after any, inContainers bool, found *[]models.ImageBump) bool {
+	switch b := before.(type) {
+	case map[string]any:
+		a, ok := after.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, bv := range b {
+			av, ok := a[key]
+			if !ok {
+				return false
+			}
+			if inContainers && key == "image" {
+				bs, bok := bv.(string)
+				as, aok := av.(string)
+				if bok && aok && bs != as {
+					name, _ := b["name"].(string)
+					*found = append(*found, models.ImageBump{Container: name, Before: bs, After: as})
+					continue
+				}
+			}
+			if !diffImages(bv, av, manifest.IsContainerList(key), found) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := after.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range b {
+			if !diffImages(b[i], a[i], inContainers, found) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(before, after)
+	}
+}
+
+// isRoutineBump checks that an image update keeps its (allowed) repository and, if required, increments its semver tag
+func isRoutineBump(before, after string, cfg *models.ImageBumpConfig) bool {
+	beforeRef, afterRef := manifest.ParseImage(before), manifest.ParseImage(after)
+	if beforeRef.Name != afterRef.Name {
+		return false
+	}
+	if len(cfg.Repositories) > 0 {
+		allowed := false
+		for _, pattern := range cfg.Repositories {
+			if ok, _ := path.Match(pattern, afterRef.Name); ok {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return !cfg.Semver || semverLess(beforeRef.Tag, afterRef.Tag)
+}
+
+// semverLess reports whether both tags are semantic versions (with an optional "v" prefix) and a < b
+// Prereleases are lower than their release and compared as strings with one another
+func semverLess(a, b string) bool {
+	am, bm := semverPattern.FindStringSubmatch(a), semverPattern.FindStringSubmatch(b)
+	if am == nil || bm == nil {
+		return false
+	}
+	for i := 1; i <= 3; i++ {
+		an, _ := strconv.Atoi(am[i])
+		bn, _ := strconv.Atoi(bm[i])
+		if an != bn {
+			return an < bn
+		}
+	}
+	switch {
+	case am[4] == "":
+		return false
+	case bm[4] == "":
+		return true
+	default:
+		return am[4] < bm[4]
+	}
+}
@@ -0,0 +1,109 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const imageBumpDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  replicas: 2
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: ghcr.io/acme/migrate:1.0.0
+      containers:
+        - name: web
+          image: ghcr.io/acme/web:v1.2.3
+`
+
+func TestImageBumpsOf(t *testing.T) {
+	tests := []struct {
+		name  string
+		after string
+		cfg   *models.ImageBumpConfig
+		want  []models.ImageBump
+	}{
+		{
+			name:  "tag bump",
+			after: strings.Replace(imageBumpDeployment, "web:v1.2.3", "web:v1.3.0", 1),
+			cfg:   &models.ImageBumpConfig{Semver: true},
+			want:  []models.ImageBump{{ResourceID: "Deployment/app/web", Container: "web", Before: "ghcr.io/acme/web:v1.2.3", After: "ghcr.io/acme/web:v1.3.0"}},
+		},
+		{
+			name:  "init container bump",
+			after: strings.Replace(imageBumpDeployment, "migrate:1.0.0", "migrate:1.0.1@sha256:abc", 1),
+			cfg:   &models.ImageBumpConfig{Repositories: []string{"ghcr.io/acme/*"}},
+			want:  []models.ImageBump{{ResourceID: "Deployment/app/web", Container: "migrate", Before: "ghcr.io/acme/migrate:1.0.0", After: "ghcr.io/acme/migrate:1.0.1@sha256:abc"}},
+		},
+		{
+			name:  "semver downgrade",
+			after: strings.Replace(imageBumpDeployment, "web:v1.2.3", "web:v1.2.3-rc.1", 1),
+			cfg:   &models.ImageBumpConfig{Semver: true},
+		},
+		{
+			name:  "non-semver tag with semver required",
+			after: strings.Replace(imageBumpDeployment, "web:v1.2.3", "web:latest", 1),
+			cfg:   &models.ImageBumpConfig{Semver: true},
+		},
+		{
+			name:  "repository change",
+			after: strings.Replace(imageBumpDeployment, "ghcr.io/acme/web", "docker.io/acme/web", 1),
+			cfg:   &models.ImageBumpConfig{},
+		},
+		{
+			name:  "repository not allowed",
+			after: strings.Replace(imageBumpDeployment, "web:v1.2.3", "web:v1.3.0", 1),
+			cfg:   &models.ImageBumpConfig{Repositories: []string{"ghcr.io/other/*"}},
+		},
+		{
+			name:  "other field changed",
+			after: strings.Replace(strings.Replace(imageBumpDeployment, "web:v1.2.3", "web:v1.3.0", 1), "replicas: 2", "replicas: 3", 1),
+			cfg:   &models.ImageBumpConfig{},
+		},
+		{
+			name:  "no config",
+			after: strings.Replace(imageBumpDeployment, "web:v1.2.3", "web:v1.3.0", 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := ParseChanges([]byte(imageBumpDeployment), []byte(tt.after))
+			if err != nil {
+				t.Fatalf("ParseChanges() error = %v", err)
+			}
+			if got := ImageBumpsOf(changes, tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ImageBumpsOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSemverLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.3", "1.2.4", true},
+		{"v1.9.0", "v1.10.0", true},
+		{"2.0.0", "1.9.9", false},
+		{"1.2.3", "1.2.3", false},
+		{"1.2.3-rc.1", "1.2.3", true},
+		{"1.2.3-rc.1", "1.2.3-rc.2", true},
+		{"1.2.3", "1.2.3-rc.1", false},
+		{"latest", "1.2.3", false},
+	}
+	for _, tt := range tests {
+		if got := semverLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("semverLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// NATIVE_DIFF_CONTEXT is the number of context lines around changes, as `diff -u`
+	NATIVE_DIFF_CONTEXT = 3
+	// nativeDiffTimeLayout is the timestamp layout of `diff -u` file headers
+	nativeDiffTimeLayout = "2006-01-02 15:04:05.000000000 -0700"
+)
+
+// nativeUnifiedDiff produces the same output as `diff -u` without the external binary,
+// using Myers' linear space algorithm on lines (a line includes its trailing newline, if any)
+func (d *Differ) nativeUnifiedDiff(before, after []byte) (string, error) {
+	if bytes.Equal(before, after) {
+		return "", nil
+	}
+
+	now := time.Now().Format(nativeDiffTimeLayout)
+	return fmt.Sprintf("--- before\t%s\n+++ after\t%s\n", now, now) + Hunks(before, after), nil
+}
+
+// Hunks returns the hunks of the unified diff of before and after, without the file headers
+func Hunks(before, after []byte) string {
+	a, b := splitLines(before), splitLines(after)
+	e := newEditScript(a, b)
+	e.compare(0, len(a), 0, len(b))
+
+	var out strings.Builder
+	for _, h := range e.hunks(NATIVE_DIFF_CONTEXT) {
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(h.aStart, h.aCount), hunkRange(h.bStart, h.bCount))
+		for _, l := range h.lines {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+	}
+	return out.String()
+}
+
+// splitLines splits data after each newline, the last line may not end with one
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i+1]))
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// hunkRange formats a hunk range as `diff -u`: "start,count", "start" for one line, and the line before for none
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
+
+// editScript marks the lines deleted from a and inserted in b, lines are compared by interned IDs
+type editScript struct {
+	a, b     []int
+	aLines   []string
+	bLines   []string
+	deleted  []bool
+	inserted []bool
+	vf, vb   []int
+}
+
+func newEditScript(aLines, bLines []string) *editScript {
+	ids := map[string]int{}
+	intern := func(lines []string) []int {
+		results := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			results[i] = id
+		}
+		return results
+	}
+	size := len(aLines) + len(bLines) + 3
+	return &editScript{
+		a: intern(aLines), b: intern(bLines),
+		aLines: aLines, bLines: bLines,
+		deleted: make([]bool, len(aLines)), inserted: make([]bool, len(bLines)),
+		vf: make([]int, 2*size), vb: make([]int, 2*size),
+	}
+}
+
+// compare finds a shortest edit script between a[aLo:aHi] and b[bLo:bHi], splitting around a middle snake
+func (e *editScript) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && e.a[aLo] == e.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && e.a[aHi-1] == e.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			e.inserted[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			e.deleted[i] = true
+		}
+	default:
+		x0, y0, x1, y1 := e.middleSnake(aLo, aHi, bLo, bHi)
+		e.compare(aLo, x0, bLo, y0)
+		e.compare(x1, aHi, y1, bHi)
+	}
+}
+
+// middleSnake returns the start and end of the middle snake of an optimal path in absolute coordinates
+// Both ranges are non-empty and differ on their first and last lines, so the edit distance is at least 2
+// and the snake always splits the problem in two smaller ones
+func (e *editScript) middleSnake(aLo, aHi, bLo, bHi int) (int, int, int, int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	vf, vb := e.vf, e.vb
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	for d := 0; d <= maxD; d++ {
+		// forward paths from (0, 0), vf holds the furthest x per diagonal k = x - y
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && e.a[aLo+x] == e.b[bLo+y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			if kr := delta - k; odd && kr >= -(d-1) && kr <= d-1 && x+vb[offset+kr] >= n {
+				return aLo + x0, bLo + y0, aLo + x, bLo + y
+			}
+		}
+		// backward paths from (n, m), vb holds the furthest distance from the end per reversed diagonal
+		for kr := -d; kr <= d; kr += 2 {
+			var xr int
+			if kr == -d || (kr != d && vb[offset+kr-1] < vb[offset+kr+1]) {
+				xr = vb[offset+kr+1]
+			} else {
+				xr = vb[offset+kr-1] + 1
+			}
+			yr := xr - kr
+			xr0, yr0 := xr, yr
+			for xr < n && yr < m && e.a[aHi-1-xr] == e.b[bHi-1-yr] {
+				xr++
+				yr++
+			}
+			vb[offset+kr] = xr
+			if k := delta - kr; !odd && k >= -d && k <= d && xr+vf[offset+k] >= n {
+				return aHi - xr, bHi - yr, aHi - xr0, bHi - yr0
+			}
+		}
+	}
+	// unreachable: paths always overlap within maxD steps
+	return aLo, bLo, aHi, bHi
+}
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+	ai   int // index in a of the next line of a at this point
+	bi   int
+}
+
+type hunk struct {
+	aStart, aCount int // 1-based
+	bStart, bCount int
+	lines          []diffLine
+}
+
+// hunks groups the edit script in `diff -u` hunks, changes closer than 2*context lines share a hunk
+func (e *editScript) hunks(context int) []hunk {
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(e.a) || j < len(e.b) {
+		switch {
+		case i < len(e.a) && e.deleted[i]:
+			lines = append(lines, diffLine{op: '-', text: e.aLines[i], ai: i, bi: j})
+			i++
+		case j < len(e.b) && e.inserted[j]:
+			lines = append(lines, diffLine{op: '+', text: e.bLines[j], ai: i, bi: j})
+			j++
+		default:
+			lines = append(lines, diffLine{op: ' ', text: e.aLines[i], ai: i, bi: j})
+			i++
+			j++
+		}
+	}
+
+	var hunks []hunk
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// extend the hunk while the next change is within 2*context equal lines
+		end, equal := start, 0
+		for k := start; k < len(lines) && equal <= 2*context; k++ {
+			if lines[k].op == ' ' {
+				equal++
+				continue
+			}
+			end, equal = k, 0
+		}
+		from := max(start-context, 0)
+		to := min(end+context+1, len(lines))
+
+		h := hunk{aStart: lines[from].ai + 1, bStart: lines[from].bi + 1, lines: lines[from:to]}
+		for _, l := range h.lines {
+			if l.op != '+' {
+				h.aCount++
+			}
+			if l.op != '-' {
+				h.bCount++
+			}
+		}
+		hunks = append(hunks, h)
+		start = to
+	}
+	return hunks
+}
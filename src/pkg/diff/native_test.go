@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"fmt"
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestDiffer_nativeUnifiedDiff checks that the native engine produces the same output as `diff -u`
+func TestDiffer_nativeUnifiedDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff binary not available")
+	}
+
+	tests := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{name: "identical", before: "a\nb\n", after: "a\nb\n"},
+		{name: "modified line", before: "line1\nline2\nline3", after: "line1\nline2_modified\nline3"},
+		{name: "empty before", before: "", after: "new content"},
+		{name: "empty after", before: "old content\n", after: ""},
+		{name: "newline added at end", before: "a\nb", after: "a\nb\n"},
+		{name: "separate hunks", before: strings.Repeat("x\n", 5) + "a\n" + strings.Repeat("y\n", 10) + "b\n",
+			after: strings.Repeat("x\n", 5) + "A\n" + strings.Repeat("y\n", 10) + "B\n"},
+		{name: "merged hunks", before: "a\n1\n2\n3\n4\n5\n6\nb\n", after: "A\n1\n2\n3\n4\n5\n6\nB\n"},
+	}
+
+	external := NewDiffer()
+	native := NewDifferWithOptions(DIFF_ENGINE_NATIVE)
+	for _, tt := range tests {
+		want, err := external.DiffText(tt.before, tt.after)
+		if err != nil {
+			t.Fatalf("%s: external Diff() error = %v", tt.name, err)
+		}
+		got, err := native.DiffText(tt.before, tt.after)
+		if err != nil {
+			t.Fatalf("%s: native Diff() error = %v", tt.name, err)
+		}
+		if normalizeTimestamps(got) != normalizeTimestamps(want) {
+			t.Errorf("%s: native Diff(%q, %q) =\n%s\nwant\n%s", tt.name, tt.before, tt.after, got, want)
+		}
+	}
+}
+
+// TestDiffer_nativeUnifiedDiff_random checks that native diffs of random edits apply cleanly
+// Alignments are not compared: with repeated lines several shortest edit scripts exist, and `diff -u` picks its own
+func TestDiffer_nativeUnifiedDiff_random(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() string {
+		var sb strings.Builder
+		for i := rng.Intn(30); i > 0; i-- {
+			sb.WriteString(string(rune('a'+rng.Intn(4))) + "\n")
+		}
+		if rng.Intn(4) == 0 {
+			sb.WriteString("last")
+		}
+		return sb.String()
+	}
+
+	native := NewDifferWithOptions(DIFF_ENGINE_NATIVE)
+	for i := 0; i < 500; i++ {
+		before, after := randomLines(), randomLines()
+		got, err := native.DiffText(before, after)
+		if err != nil {
+			t.Fatalf("native Diff() error = %v", err)
+		}
+		if patched := applyUnifiedDiff(t, before, got); patched != after {
+			t.Fatalf("native Diff(%q, %q) does not apply, got %q from:\n%s", before, after, patched, got)
+		}
+	}
+}
+
+// applyUnifiedDiff applies a single-file unified diff to before
+func applyUnifiedDiff(t *testing.T, before, patch string) string {
+	t.Helper()
+	if patch == "" {
+		return before
+	}
+	src := splitLines([]byte(before))
+	var out []string
+	next := 0 // next line of src to copy
+	lines := strings.SplitAfter(patch, "\n")
+	for i := 2; i < len(lines) && lines[i] != ""; i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "@@") {
+			var aStart, aCount int
+			header := strings.Fields(line)[1][1:]
+			if n, _ := fmt.Sscanf(header, "%d,%d", &aStart, &aCount); n == 1 {
+				aCount = 1
+			}
+			if aCount > 0 {
+				aStart--
+			}
+			out = append(out, src[next:aStart]...)
+			next = aStart
+			continue
+		}
+		text := line[1:]
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\\ No newline") {
+			text = strings.TrimSuffix(text, "\n")
+			i++
+		}
+		switch line[0] {
+		case ' ', '-':
+			if next >= len(src) || src[next] != text {
+				t.Fatalf("patch context %q does not match line %d of %q", text, next, before)
+			}
+			if line[0] == ' ' {
+				out = append(out, text)
+			}
+			next++
+		case '+':
+			out = append(out, text)
+		}
+	}
+	out = append(out, src[next:]...)
+	return strings.Join(out, "")
+}
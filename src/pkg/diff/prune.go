@@ -0,0 +1,45 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	// ARGOCD_SYNC_OPTIONS_ANNOTATION disables the pruning of a resource by Argo CD with the Prune=false option
+	ARGOCD_SYNC_OPTIONS_ANNOTATION = "argocd.argoproj.io/sync-options"
+	// FLUX_PRUNE_ANNOTATION disables the pruning of a resource by Flux with the "disabled" value
+	FLUX_PRUNE_ANNOTATION = "kustomize.toolkit.fluxcd.io/prune"
+)
+
+// PrunedResources returns the resources removed by the changes, in manifest order, with the annotation disabling
+// their pruning if any
+func PrunedResources(changes []manifest.Change) []models.PrunedResource {
+	var results []models.PrunedResource
+	for _, change := range changes {
+		if change.After != nil {
+			continue
+		}
+		results = append(results, models.PrunedResource{ID: change.ID, PruneDisabledBy: pruneDisabledBy(*change.Before)})
+	}
+	return results
+}
+
+// pruneDisabledBy returns the annotation of a resource disabling its pruning by Argo CD or Flux, empty if none
+func pruneDisabledBy(res manifest.Resource) string {
+	annotations := manifest.NestedMap(res.Object, "metadata", "annotations")
+	if options, ok := annotations[ARGOCD_SYNC_OPTIONS_ANNOTATION].(string); ok {
+		for _, option := range strings.Split(options, ",") {
+			if strings.TrimSpace(option) == "Prune=false" {
+				return fmt.Sprintf("%s: %s", ARGOCD_SYNC_OPTIONS_ANNOTATION, options)
+			}
+		}
+	}
+	if prune, ok := annotations[FLUX_PRUNE_ANNOTATION].(string); ok && prune == "disabled" {
+		return fmt.Sprintf("%s: %s", FLUX_PRUNE_ANNOTATION, prune)
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestPrunedResources(t *testing.T) {
+	before := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: app
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+  namespace: app
+  annotations:
+    argocd.argoproj.io/sync-options: ServerSideApply=true, Prune=false
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: token
+  namespace: app
+  annotations:
+    kustomize.toolkit.fluxcd.io/prune: disabled
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: app
+`)
+	after := []byte(`apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: app
+`)
+	changes, err := ParseChanges(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.PrunedResource{
+		{ID: "ConfigMap/app/settings"},
+		{ID: "PersistentVolumeClaim/app/data", PruneDisabledBy: "argocd.argoproj.io/sync-options: ServerSideApply=true, Prune=false"},
+		{ID: "Secret/app/token", PruneDisabledBy: "kustomize.toolkit.fluxcd.io/prune: disabled"},
+	}
+	if got := PrunedResources(changes); !reflect.DeepEqual(got, want) {
+		t.Errorf("PrunedResources() = %v, want %v", got, want)
+	}
+}
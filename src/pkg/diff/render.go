@@ -0,0 +1,123 @@
+package diff
+
+import (
+	"html"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// Sinks a diff can be rendered for
+const (
+	DIFF_SINK_TEXT     = "text"     // plain unified diff
+	DIFF_SINK_MARKDOWN = "markdown" // fenced ```diff block, for SCM comments
+	DIFF_SINK_ANSI     = "ansi"     // colored for terminals
+	DIFF_SINK_HTML     = "html"     // <pre> with one span per line, classed by kind
+)
+
+var ansiColors = map[string]string{
+	models.DiffLineFileHeader: "\x1b[1m",
+	models.DiffLineHunkHeader: "\x1b[36m",
+	models.DiffLineAdded:      "\x1b[32m",
+	models.DiffLineDeleted:    "\x1b[31m",
+	models.DiffLineNote:       "\x1b[2m",
+	models.DiffLineModified:   "\x1b[33m",
+}
+
+const ansiReset = "\x1b[0m"
+
+// ParseUnifiedDiff splits a unified diff in classified lines, or a dyff diff
+func ParseUnifiedDiff(content string) []models.DiffLine {
+	if content == "" {
+		return nil
+	}
+	rawLines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	lines := make([]models.DiffLine, 0, len(rawLines))
+	for _, text := range rawLines {
+		lines = append(lines, models.DiffLine{Kind: lineKind(text), Text: text})
+	}
+	return lines
+}
+
+func lineKind(text string) string {
+	switch {
+	case strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "):
+		return models.DiffLineFileHeader
+	case strings.HasPrefix(text, "@@"):
+		return models.DiffLineHunkHeader
+	case strings.HasPrefix(text, "+"):
+		return models.DiffLineAdded
+	case strings.HasPrefix(text, "-"):
+		return models.DiffLineDeleted
+	case strings.HasPrefix(text, "\\"):
+		return models.DiffLineNote
+	case strings.HasPrefix(text, DYFF_MODIFIED):
+		return models.DiffLineModified
+	default:
+		return models.DiffLineContext
+	}
+}
+
+// Render renders diff lines for a sink, unknown sinks get the plain text
+func Render(lines []models.DiffLine, sink string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	switch sink {
+	case DIFF_SINK_MARKDOWN:
+		fence := markdownFence(lines)
+		sb.WriteString(fence + "diff\n")
+		writeLines(&sb, lines, func(l models.DiffLine) string { return l.Text })
+		sb.WriteString(fence)
+	case DIFF_SINK_ANSI:
+		writeLines(&sb, lines, func(l models.DiffLine) string {
+			if color, ok := ansiColors[l.Kind]; ok {
+				return color + l.Text + ansiReset
+			}
+			return l.Text
+		})
+	case DIFF_SINK_HTML:
+		sb.WriteString(`<pre class="diff">`)
+		writeLines(&sb, lines, func(l models.DiffLine) string {
+			return `<span class="diff-` + l.Kind + `">` + html.EscapeString(l.Text) + `</span>`
+		})
+		sb.WriteString(`</pre>`)
+	default:
+		writeLines(&sb, lines, func(l models.DiffLine) string { return l.Text })
+	}
+	return sb.String()
+}
+
+func writeLines(sb *strings.Builder, lines []models.DiffLine, format func(models.DiffLine) string) {
+	for _, l := range lines {
+		sb.WriteString(format(l))
+		sb.WriteByte('\n')
+	}
+}
+
+// markdownFence returns a backtick fence longer than any backtick run in the lines, so manifests can't close it
+func markdownFence(lines []models.DiffLine) string {
+	longest := 0
+	for _, l := range lines {
+		run := 0
+		for _, c := range l.Text {
+			if c != '`' {
+				run = 0
+				continue
+			}
+			run++
+			longest = max(longest, run)
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// RenderEnvironmentDiff renders the text diff of an environment for a sink, parsing the content if the lines are not set
+func RenderEnvironmentDiff(envDiff models.EnvironmentDiff, sink string) string {
+	lines := envDiff.Lines
+	if lines == nil && envDiff.ContentType == models.DiffContentTypeText {
+		lines = ParseUnifiedDiff(envDiff.Content)
+	}
+	return Render(lines, sink)
+}
@@ -0,0 +1,48 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestRender(t *testing.T) {
+	content := "--- before\tT\n+++ after\tT\n@@ -1,2 +1,2 @@\n-a: <old>\n+a: ```new```\n b\n\\ No newline at end of file\n"
+	lines := ParseUnifiedDiff(content)
+	wantKinds := []string{
+		models.DiffLineFileHeader, models.DiffLineFileHeader, models.DiffLineHunkHeader,
+		models.DiffLineDeleted, models.DiffLineAdded, models.DiffLineContext, models.DiffLineNote,
+	}
+	if len(lines) != len(wantKinds) {
+		t.Fatalf("ParseUnifiedDiff() = %d lines, want %d", len(lines), len(wantKinds))
+	}
+	for i, kind := range wantKinds {
+		if lines[i].Kind != kind {
+			t.Errorf("ParseUnifiedDiff() line %d kind = %s, want %s", i, lines[i].Kind, kind)
+		}
+	}
+
+	tests := []struct {
+		sink string
+		want string
+	}{
+		{sink: DIFF_SINK_TEXT, want: content},
+		{sink: DIFF_SINK_MARKDOWN, want: "````diff\n" + content + "````"},
+		{sink: DIFF_SINK_ANSI, want: "\x1b[1m--- before\tT\x1b[0m\n\x1b[1m+++ after\tT\x1b[0m\n\x1b[36m@@ -1,2 +1,2 @@\x1b[0m\n" +
+			"\x1b[31m-a: <old>\x1b[0m\n\x1b[32m+a: ```new```\x1b[0m\n b\n\x1b[2m\\ No newline at end of file\x1b[0m\n"},
+		{sink: DIFF_SINK_HTML, want: `<pre class="diff"><span class="diff-file">--- before` + "\tT</span>\n" +
+			`<span class="diff-file">+++ after` + "\tT</span>\n" +
+			`<span class="diff-hunk">@@ -1,2 +1,2 @@</span>` + "\n" +
+			`<span class="diff-deleted">-a: &lt;old&gt;</span>` + "\n" +
+			`<span class="diff-added">+a: ` + "```new```" + `</span>` + "\n" +
+			`<span class="diff-context"> b</span>` + "\n" +
+			`<span class="diff-note">\ No newline at end of file</span>` + "\n</pre>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sink, func(t *testing.T) {
+			if got := Render(lines, tt.sink); got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
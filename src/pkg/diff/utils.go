@@ -15,6 +15,11 @@ func CalcLineChangesFromDiffContent(diffContent string) (int, int, int) {
 		if strings.HasPrefix(line, "- ") {
 			deletedLines++
 		}
+		// a modified path of a dyff diff, "! spec.replicas: 1 → 3", replaces a line
+		if strings.HasPrefix(line, DYFF_MODIFIED) && strings.Contains(line, dyffArrow) {
+			addedLines++
+			deletedLines++
+		}
 	}
 	return addedLines, deletedLines, addedLines + deletedLines
 }
@@ -94,6 +94,19 @@ func TestCalcLineChangesFromDiffContent(t *testing.T) {
 			expectedDeleted: 2, // line2, line3
 			expectedTotal:   5,
 		},
+		{
+			name: "dyff diff",
+			diffContent: `@@ Deployment/app/web (modified) @@
+! spec.replicas: 1 → 3
++ metadata.labels.team: payments
+! data["app.conf"]: (multi-line)
+-   b=2
++   b=3
+`,
+			expectedAdded:   3,
+			expectedDeleted: 2,
+			expectedTotal:   5,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,47 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+var anchorUnsafeChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// ResourceAnchor returns the HTML anchor of a changed resource in the diff section of an overlay
+// e.g. ("alpha/stg", "Deployment/app/web") -> "diff-alpha-stg-deployment-app-web"
+func ResourceAnchor(overlayKey, resourceID string) string {
+	return "diff-" + strings.Trim(anchorUnsafeChars.ReplaceAllString(strings.ToLower(overlayKey+"-"+resourceID), "-"), "-")
+}
+
+// LinkViolations cross-links the policy violations and resource changes of an overlay sharing the same resource ID:
+// changes get the names of the policies failing on them, violations get the anchor of the change in the diff
+func LinkViolations(overlayKey string, envDiff *models.EnvironmentDiff, matrix *models.PolicyMatrix) {
+	changeIdx := make(map[string]int, len(envDiff.ResourceChanges))
+	for i := range envDiff.ResourceChanges {
+		change := &envDiff.ResourceChanges[i]
+		change.Anchor = ResourceAnchor(overlayKey, change.ID)
+		changeIdx[change.ID] = i
+	}
+
+	for _, policies := range [][]models.PolicyResult{
+		matrix.BlockingPolicies, matrix.WarningPolicies, matrix.RecommendPolicies,
+		matrix.OverriddenPolicies, matrix.NotInEffectPolicies,
+	} {
+		for p := range policies {
+			for v := range policies[p].Violations {
+				violation := &policies[p].Violations[v]
+				i, ok := changeIdx[violation.ResourceID]
+				if violation.ResourceID == "" || !ok {
+					continue
+				}
+				change := &envDiff.ResourceChanges[i]
+				violation.DiffAnchor = change.Anchor
+				if !contains(change.ViolatedPolicies, policies[p].PolicyName) {
+					change.ViolatedPolicies = append(change.ViolatedPolicies, policies[p].PolicyName)
+				}
+			}
+		}
+	}
+}
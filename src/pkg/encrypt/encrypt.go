@@ -0,0 +1,107 @@
+// Package encrypt encrypts the output files of a run with age or gpg, for the recipients of the compliance
+// config, so that reports and rendered manifests can be archived in shared artifact stores
+package encrypt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "encrypt")
+
+const (
+	TOOL_AGE = "age"
+	TOOL_GPG = "gpg"
+)
+
+// TOOLS are the supported encryption tools, each a binary of the same name
+var TOOLS = []string{TOOL_AGE, TOOL_GPG}
+
+// EXTENSIONS are the file extensions of the encrypted files, by tool
+var EXTENSIONS = map[string]string{TOOL_AGE: ".age", TOOL_GPG: ".gpg"}
+
+// ToolOf returns the tool a file was encrypted with from its extension, empty if not encrypted
+func ToolOf(path string) string {
+	for _, tool := range TOOLS {
+		if strings.HasSuffix(path, EXTENSIONS[tool]) {
+			return tool
+		}
+	}
+	return ""
+}
+
+// TrimExtension returns the path of a file before its encryption
+func TrimExtension(path string) string {
+	if tool := ToolOf(path); tool != "" {
+		return strings.TrimSuffix(path, EXTENSIONS[tool])
+	}
+	return path
+}
+
+// Encryptor encrypts files for its recipients
+type Encryptor struct {
+	Tool       string
+	Recipients []string
+	Executor   sandbox.Executor // nil for sandbox.DefaultExecutor
+	Limits     sandbox.Limits
+}
+
+// New returns the encryptor of a tool for the recipients of the compliance config, which must have some for it
+func New(tool string, cfg *models.ArtifactEncryptionConfig, limits sandbox.Limits) (*Encryptor, error) {
+	if !slices.Contains(TOOLS, tool) {
+		return nil, fmt.Errorf("unsupported encryption tool %q, expected one of %v", tool, TOOLS)
+	}
+	field, recipients := "ageRecipients", []string(nil)
+	if tool == TOOL_GPG {
+		field = "gpgRecipients"
+	}
+	if cfg != nil {
+		recipients = cfg.AgeRecipients
+		if tool == TOOL_GPG {
+			recipients = cfg.GPGRecipients
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("artifactEncryption.%s of the compliance config is empty, nobody could decrypt the artifacts", field)
+	}
+	return &Encryptor{Tool: tool, Recipients: recipients, Limits: limits}, nil
+}
+
+// args returns the arguments of the command encrypting a file to stdout
+func (e *Encryptor) args(path string) []string {
+	var args []string
+	if e.Tool == TOOL_GPG {
+		args = append(args, "--batch", "--yes", "--trust-model", "always", "--encrypt", "--output", "-")
+	} else {
+		args = append(args, "--encrypt")
+	}
+	for _, recipient := range e.Recipients {
+		args = append(args, "--recipient", recipient)
+	}
+	return append(args, path)
+}
+
+// EncryptFile replaces a file by its encrypted version, the file path with the tool extension. Returns its path
+func (e *Encryptor) EncryptFile(ctx context.Context, path string) (string, error) {
+	encrypted, err := sandbox.Output(ctx, e.Executor, sandbox.Command{Name: e.Tool, Args: e.args(path), Limits: e.Limits})
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt %s: %w", path, err)
+	}
+	encryptedPath := path + EXTENSIONS[e.Tool]
+	if err := perm.WriteFile(encryptedPath, encrypted); err != nil {
+		return "", fmt.Errorf("failed to write encrypted %s: %w", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("failed to remove plaintext %s: %w", path, err)
+	}
+	logger.WithField("path", encryptedPath).Debug("Encrypted file")
+	return encryptedPath, nil
+}
@@ -0,0 +1,64 @@
+package encrypt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+)
+
+func TestNew(t *testing.T) {
+	cfg := &models.ArtifactEncryptionConfig{AgeRecipients: []string{"age1abc"}}
+	tests := []struct {
+		name    string
+		tool    string
+		cfg     *models.ArtifactEncryptionConfig
+		wantErr bool
+	}{
+		{"age recipients", TOOL_AGE, cfg, false},
+		{"no gpg recipients", TOOL_GPG, cfg, true},
+		{"no config", TOOL_AGE, nil, true},
+		{"unsupported tool", "openssl", cfg, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.tool, tt.cfg, sandbox.Limits{}); (err != nil) != tt.wantErr {
+				t.Errorf("New(%q) error = %v, wantErr %v", tt.tool, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fake := &sandbox.FakeExecutor{Handler: func(cmd sandbox.Command) (*sandbox.Result, error) {
+		return &sandbox.Result{Stdout: []byte("ciphertext")}, nil
+	}}
+	e := &Encryptor{Tool: TOOL_GPG, Recipients: []string{"sec@acme.com", "ops@acme.com"}, Executor: fake}
+
+	encryptedPath, err := e.EncryptFile(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encryptedPath != path+".gpg" || ToolOf(encryptedPath) != TOOL_GPG || TrimExtension(encryptedPath) != path {
+		t.Errorf("unexpected encrypted path %s", encryptedPath)
+	}
+	if content, _ := os.ReadFile(encryptedPath); string(content) != "ciphertext" {
+		t.Errorf("encrypted file = %q", content)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the plaintext to be removed, got %v", err)
+	}
+	want := []string{"--batch", "--yes", "--trust-model", "always", "--encrypt", "--output", "-",
+		"--recipient", "sec@acme.com", "--recipient", "ops@acme.com", path}
+	if got := fake.Commands()[0]; got.Name != "gpg" || !reflect.DeepEqual(got.Args, want) {
+		t.Errorf("ran %s %v, want gpg %v", got.Name, got.Args, want)
+	}
+}
@@ -0,0 +1,131 @@
+package failure
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Category classifies a run failure by the stage that failed
+type Category string
+
+const (
+	CATEGORY_AUTH          Category = "AuthError"
+	CATEGORY_CHECKOUT      Category = "CheckoutError"
+	CATEGORY_BUILD         Category = "BuildError"
+	CATEGORY_POLICY_ENGINE Category = "PolicyEngineError"
+	CATEGORY_RENDER        Category = "RenderError"
+	CATEGORY_SCM_PUBLISH   Category = "SCMPublishError"
+)
+
+// EXCERPT_MAX_LENGTH is the maximum length of the error excerpts shown in reports
+const EXCERPT_MAX_LENGTH = 300
+
+// EXIT_CODE_UNCATEGORIZED is the exit code of errors without a category (including failing policies)
+const EXIT_CODE_UNCATEGORIZED = 1
+
+// ExitCodes are the process exit codes of each category
+var ExitCodes = map[Category]int{
+	CATEGORY_AUTH:          10,
+	CATEGORY_CHECKOUT:      11,
+	CATEGORY_BUILD:         12,
+	CATEGORY_POLICY_ENGINE: 13,
+	CATEGORY_RENDER:        14,
+	CATEGORY_SCM_PUBLISH:   15,
+}
+
+// Hints are the short remediation hints of each category
+var Hints = map[Category]string{
+	CATEGORY_AUTH:          "Set GH_TOKEN or GITHUB_TOKEN to a token that can read the repository and write pull request comments",
+	CATEGORY_CHECKOUT:      "Check that the base and head refs exist and the token can clone the repository, or try --git-checkout-strategy shallow",
+	CATEGORY_BUILD:         "Run `kustomize build` on the failing overlay locally, and check --build-args and the overlay paths",
+	CATEGORY_POLICY_ENGINE: "Check compliance-config.yaml and the policies under --policies-path, and that conftest is installed (`conftest verify`)",
+	CATEGORY_RENDER:        "Check the templates under --templates-path against the bundled ones (Go text/template syntax and field names)",
+	CATEGORY_SCM_PUBLISH:   "Check that the token can write pull request comments (pull-requests: write) and that the pull request still exists",
+}
+
+// Error is a run failure of a category
+type Error struct {
+	Category Category
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Hint returns the remediation hint of the category
+func (e *Error) Hint() string {
+	return Hints[e.Category]
+}
+
+// ExitCode returns the process exit code of the category
+func (e *Error) ExitCode() int {
+	if code, ok := ExitCodes[e.Category]; ok {
+		return code
+	}
+	return EXIT_CODE_UNCATEGORIZED
+}
+
+// New categorizes err, nil stays nil and an already categorized error keeps its (more specific) category
+func New(category Category, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := As(err); ok {
+		return err
+	}
+	return &Error{Category: category, Err: err}
+}
+
+func Auth(err error) error         { return New(CATEGORY_AUTH, err) }
+func Checkout(err error) error     { return New(CATEGORY_CHECKOUT, err) }
+func Build(err error) error        { return New(CATEGORY_BUILD, err) }
+func PolicyEngine(err error) error { return New(CATEGORY_POLICY_ENGINE, err) }
+func Render(err error) error       { return New(CATEGORY_RENDER, err) }
+func SCMPublish(err error) error   { return New(CATEGORY_SCM_PUBLISH, err) }
+
+// As returns the categorized error in err's chain
+func As(err error) (*Error, bool) {
+	var categorized *Error
+	ok := errors.As(err, &categorized)
+	return categorized, ok
+}
+
+// ExitCode returns the process exit code of err, 0 if nil
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if categorized, ok := As(err); ok {
+		return categorized.ExitCode()
+	}
+	return EXIT_CODE_UNCATEGORIZED
+}
+
+// Format renders err for the terminal, with its category and hint when categorized
+func Format(err error) string {
+	categorized, ok := As(err)
+	if !ok {
+		return err.Error()
+	}
+	return fmt.Sprintf("%s: %s\nHint: %s", categorized.Category, err.Error(), categorized.Hint())
+}
+
+// Excerpt returns the message of err on a single line, cut to EXCERPT_MAX_LENGTH, for the failed parts of a report
+func Excerpt(err error) string {
+	msg := strings.ReplaceAll(strings.Join(strings.Fields(err.Error()), " "), "`", "'")
+	if len(msg) <= EXCERPT_MAX_LENGTH {
+		return msg
+	}
+	cut := EXCERPT_MAX_LENGTH
+	for cut > 0 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	return msg[:cut] + "…"
+}
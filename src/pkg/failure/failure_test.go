@@ -0,0 +1,61 @@
+package failure
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	base := errors.New("kustomize: accumulating resources")
+	tests := []struct {
+		name         string
+		err          error
+		wantCategory Category
+		wantExitCode int
+	}{
+		{name: "nil", err: New(CATEGORY_BUILD, nil), wantExitCode: 0},
+		{name: "uncategorized", err: base, wantExitCode: EXIT_CODE_UNCATEGORIZED},
+		{name: "categorized", err: Build(base), wantCategory: CATEGORY_BUILD, wantExitCode: 12},
+		{name: "wrapped", err: fmt.Errorf("failed to process: %w", Render(base)), wantCategory: CATEGORY_RENDER, wantExitCode: 14},
+		{name: "keeps inner category", err: SCMPublish(fmt.Errorf("publish: %w", Auth(base))), wantCategory: CATEGORY_AUTH, wantExitCode: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.wantExitCode {
+				t.Errorf("ExitCode() = %d, want %d", got, tt.wantExitCode)
+			}
+			categorized, ok := As(tt.err)
+			if ok != (tt.wantCategory != "") {
+				t.Fatalf("As() ok = %v, want category %q", ok, tt.wantCategory)
+			}
+			if ok && categorized.Category != tt.wantCategory {
+				t.Errorf("Category = %s, want %s", categorized.Category, tt.wantCategory)
+			}
+			if ok && !errors.Is(tt.err, base) {
+				t.Errorf("errors.Is(err, base) = false, want the cause kept in the chain")
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("é", EXCERPT_MAX_LENGTH)
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "single line", err: errors.New("  boom \n"), want: "boom"},
+		{name: "multi line with backticks", err: errors.New("build failed:\n  `kustomization.yaml`\tnot found"), want: "build failed: 'kustomization.yaml' not found"},
+		{name: "cut on a rune boundary", err: errors.New(long), want: long[:EXCERPT_MAX_LENGTH] + "…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Excerpt(tt.err); got != tt.want {
+				t.Errorf("Excerpt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Package fsys abstracts the filesystem the checkouts are read from, so that they can live on disk or in memory
+package fsys
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "fsys")
+
+// SKIPPED_DIRS are not copied by CopyFromDisk, nothing reads them once checked out
+var SKIPPED_DIRS = []string{".git"}
+
+// FS is a filesystem of OS paths (separators and volumes of the platform, relative to the working directory)
+// Errors are *fs.PathError wrapping fs.ErrNotExist, fs.ErrExist... like the os package
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+	// ReadDir returns the entries of a directory sorted by name
+	ReadDir(name string) ([]fs.DirEntry, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	RemoveAll(name string) error
+}
+
+// OS is the filesystem of the disk
+var OS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (osFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (osFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+func (osFS) RemoveAll(name string) error { return os.RemoveAll(name) }
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+// OrOS returns the filesystem, or OS if nil
+func OrOS(files FS) FS {
+	if files == nil {
+		return OS
+	}
+	return files
+}
+
+// IsOS returns true if the filesystem is the disk (or nil)
+func IsOS(files FS) bool {
+	_, ok := OrOS(files).(osFS)
+	return ok
+}
+
+// Exists returns true if the path exists
+func Exists(files FS, name string) bool {
+	_, err := OrOS(files).Stat(name)
+	return err == nil
+}
+
+// Glob returns the paths matching a pattern, with the syntax and semantics of filepath.Glob
+func Glob(files FS, pattern string) ([]string, error) {
+	if IsOS(files) {
+		return filepath.Glob(pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if !Exists(files, pattern) {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = cleanGlobPath(dir)
+	if !hasMeta(dir) {
+		return globDir(files, dir, file, nil), nil
+	}
+	dirs, err := Glob(files, dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches = globDir(files, d, file, matches)
+	}
+	return matches, nil
+}
+
+// globDir appends the entries of dir matching pattern, a missing or unreadable dir matches nothing
+func globDir(files FS, dir, pattern string, matches []string) []string {
+	entries, err := files.ReadDir(dir)
+	if err != nil {
+		return matches
+	}
+	for _, entry := range entries {
+		if ok, _ := filepath.Match(pattern, entry.Name()); ok {
+			matches = append(matches, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return matches
+}
+
+// hasMeta reports whether a path contains the magic characters of filepath.Match
+func hasMeta(path string) bool {
+	magicChars := `*?[\`
+	if runtime.GOOS == "windows" {
+		magicChars = `*?[`
+	}
+	return strings.ContainsAny(path, magicChars)
+}
+
+// cleanGlobPath prepares the directory part of a pattern, like filepath.Glob does
+func cleanGlobPath(path string) string {
+	switch {
+	case path == "":
+		return "."
+	case path == string(filepath.Separator), path == filepath.VolumeName(path)+string(filepath.Separator):
+		return path
+	default:
+		return path[:len(path)-1] // chop off the trailing separator
+	}
+}
+
+// WalkDir walks the tree rooted at root like filepath.WalkDir, in lexical order
+func WalkDir(files FS, root string, fn fs.WalkDirFunc) error {
+	if IsOS(files) {
+		return filepath.WalkDir(root, fn)
+	}
+	info, err := files.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(files, root, fs.FileInfoToDirEntry(info), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func walkDir(files FS, path string, d fs.DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(path, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := files.ReadDir(path)
+	if err != nil {
+		if err = fn(path, d, err); err != nil {
+			if err == filepath.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := walkDir(files, filepath.Join(path, entry.Name()), entry, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyFromDisk copies a directory of the disk into a filesystem at the same path, but SKIPPED_DIRS
+func CopyFromDisk(files FS, dir string) error {
+	return copyTree(OS, dir, files, func(path string) string { return path })
+}
+
+// CopyDir copies a directory of the disk to another directory of the disk, but SKIPPED_DIRS
+func CopyDir(src, dst string) error {
+	return copyTree(OS, src, OS, func(path string) string {
+		rel, _ := filepath.Rel(src, path) // path is under src
+		return filepath.Join(dst, rel)
+	})
+}
+
+// OnDisk returns a path of the disk with the content of path in a filesystem, for the external binaries
+// (kustomize, helm) that cannot read it. The disk itself is returned as is, the content of other filesystems
+// is copied to a temp directory with the same layout, so that references outside path (../../base) resolve.
+// cleanup removes the copy
+func OnDisk(files FS, path string) (string, func(), error) {
+	if IsOS(files) {
+		return path, func() {}, nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	tmpDir, err := os.MkdirTemp("", "kustomzchk-fs-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create the disk copy of %s: %w", path, err)
+	}
+	cleanup := func() { _ = os.RemoveAll(tmpDir) }
+
+	volume := filepath.VolumeName(absPath)
+	onDisk := func(path string) string { return filepath.Join(tmpDir, strings.TrimPrefix(path, volume)) }
+	if err := copyTree(files, volume+string(filepath.Separator), OS, onDisk); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to copy %s to disk: %w", path, err)
+	}
+	logger.WithField("path", absPath).WithField("tmpDir", tmpDir).Debug("Copied filesystem to disk")
+	return onDisk(absPath), cleanup, nil
+}
+
+// copyTree copies the files under root of src to dst, at the paths given by dstPath
+func copyTree(src FS, root string, dst FS, dstPath func(string) string) error {
+	return WalkDir(src, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && slices.Contains(SKIPPED_DIRS, d.Name()) {
+				return filepath.SkipDir
+			}
+			return dst.MkdirAll(dstPath(path), 0755)
+		}
+		info, err := src.Stat(path) // follows symlinks, copied as the files they point to
+		if err != nil || !info.Mode().IsRegular() {
+			return nil // dangling symlinks and special files are not part of the manifests
+		}
+		content, err := src.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return dst.WriteFile(dstPath(path), content, info.Mode().Perm())
+	})
+}
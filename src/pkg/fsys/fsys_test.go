@@ -0,0 +1,137 @@
+package fsys
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTree writes the same files to each filesystem, under root
+func writeTree(t *testing.T, root string, files map[string]string, filesystems ...FS) {
+	t.Helper()
+	for _, f := range filesystems {
+		for name, content := range files {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			if err := f.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestMem(t *testing.T) {
+	m := NewMem()
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{"app/base/kustomization.yaml": "resources: []\n", "app/README.md": "app"}, m)
+
+	content, err := m.ReadFile(filepath.Join(root, "app/base/kustomization.yaml"))
+	if err != nil || string(content) != "resources: []\n" {
+		t.Fatalf("ReadFile() = %q, %v", content, err)
+	}
+	if info, err := m.Stat(filepath.Join(root, "app", "base")); err != nil || !info.IsDir() {
+		t.Fatalf("Stat() of a parent created by WriteFile = %v, %v, want a directory", info, err)
+	}
+	entries, err := m.ReadDir(filepath.Join(root, "app"))
+	if err != nil || len(entries) != 2 || entries[0].Name() != "README.md" || !entries[1].IsDir() {
+		t.Fatalf("ReadDir() = %v, %v, want README.md then base/", entries, err)
+	}
+
+	if _, err := m.ReadFile(filepath.Join(root, "missing.yaml")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile() of a missing file error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := m.ReadFile(filepath.Join(root, "app")); err == nil {
+		t.Errorf("expected ReadFile() of a directory to fail")
+	}
+	if err := m.WriteFile(filepath.Join(root, "app/README.md/nested"), nil, 0644); err == nil {
+		t.Errorf("expected WriteFile() under a file to fail")
+	}
+
+	if err := m.RemoveAll(filepath.Join(root, "app")); err != nil {
+		t.Fatal(err)
+	}
+	if Exists(m, filepath.Join(root, "app/base/kustomization.yaml")) || m.Size() != 0 {
+		t.Errorf("expected RemoveAll() to free the tree, %d bytes left", m.Size())
+	}
+	if entries, _ := m.ReadDir(root); len(entries) != 0 {
+		t.Errorf("expected the removed directory to leave its parent, got %v", entries)
+	}
+}
+
+func TestGlob(t *testing.T) {
+	root := t.TempDir()
+	m := NewMem()
+	writeTree(t, root, map[string]string{
+		"a/environments/stg/kustomization.yaml":  "",
+		"a/environments/prod/kustomization.yaml": "",
+		"b/environments/prod/kustomization.yaml": "",
+		"b/environments/prod.yaml":               "",
+	}, OS, m)
+
+	for _, pattern := range []string{
+		filepath.Join(root, "*", "environments", "prod*"),
+		filepath.Join(root, "a", "environments", "*"),
+		filepath.Join(root, "?", "environments", "[ps]*", "kustomization.yaml"),
+		filepath.Join(root, "a", "environments", "stg"),
+		filepath.Join(root, "c", "*"),
+	} {
+		want, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Glob(m, pattern)
+		if err != nil {
+			t.Fatalf("Glob(%s) error = %v", pattern, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Glob(%s) = %v, want %v like on disk", pattern, got, want)
+		}
+	}
+	if _, err := Glob(m, filepath.Join(root, "[")); err == nil {
+		t.Errorf("expected a malformed pattern to fail")
+	}
+}
+
+func TestCopyFromDiskAndOnDisk(t *testing.T) {
+	checkout := t.TempDir()
+	writeTree(t, checkout, map[string]string{
+		"app/base/kustomization.yaml":              "resources: [deployment.yaml]\n",
+		"app/base/deployment.yaml":                 "kind: Deployment\n",
+		"app/environments/prod/kustomization.yaml": "resources: [../../base]\n",
+		".git/HEAD": "ref: refs/heads/main\n",
+	}, OS)
+
+	m := NewMem()
+	if err := CopyFromDisk(m, checkout); err != nil {
+		t.Fatalf("CopyFromDisk() error = %v", err)
+	}
+	if Exists(m, filepath.Join(checkout, ".git")) {
+		t.Errorf("expected the .git directory not to be copied")
+	}
+
+	overlay := filepath.Join(checkout, "app", "environments", "prod")
+	path, cleanup, err := OnDisk(m, overlay)
+	if err != nil {
+		t.Fatalf("OnDisk() error = %v", err)
+	}
+	if path == overlay {
+		t.Fatalf("expected OnDisk() to copy the in-memory files, got the original path")
+	}
+	content, err := os.ReadFile(filepath.Join(path, "..", "..", "base", "deployment.yaml"))
+	if err != nil || string(content) != "kind: Deployment\n" {
+		t.Errorf("expected the files outside the path to be copied as well, got %q, %v", content, err)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected cleanup to remove the disk copy, got %v", err)
+	}
+
+	if path, _, err := OnDisk(nil, overlay); err != nil || path != overlay {
+		t.Errorf("OnDisk() on disk = %s, %v, want the path as is", path, err)
+	}
+}
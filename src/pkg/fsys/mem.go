@@ -0,0 +1,210 @@
+package fsys
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	errIsDir  = errors.New("is a directory")
+	errNotDir = errors.New("not a directory")
+)
+
+// Mem is an in-memory filesystem, safe for concurrent use. Relative paths are resolved against the
+// working directory like on disk, and WriteFile creates the missing parent directories
+type Mem struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode // by absolute clean path, the volume root always exists
+}
+
+type memNode struct {
+	data     []byte
+	mode     fs.FileMode
+	modTime  time.Time
+	children map[string]bool // names of the entries, nil for files
+}
+
+var _ FS = (*Mem)(nil)
+
+// NewMem creates an empty in-memory filesystem
+func NewMem() *Mem {
+	return &Mem{nodes: map[string]*memNode{}}
+}
+
+// key returns the absolute clean path of a name
+func (m *Mem) key(op, name string) (string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return abs, nil
+}
+
+// node returns the node of a path, creating the volume root on first use. Callers hold the lock
+func (m *Mem) node(path string) *memNode {
+	if n, ok := m.nodes[path]; ok {
+		return n
+	}
+	if filepath.Dir(path) == path {
+		n := &memNode{mode: fs.ModeDir | 0755, children: map[string]bool{}}
+		m.nodes[path] = n
+		return n
+	}
+	return nil
+}
+
+func (m *Mem) Stat(name string) (fs.FileInfo, error) {
+	path, err := m.key("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.node(path)
+	if n == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &memInfo{name: filepath.Base(path), node: n}, nil
+}
+
+func (m *Mem) ReadFile(name string) ([]byte, error) {
+	path, err := m.key("open", name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n, ok := m.nodes[path]
+	switch {
+	case !ok:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case n.children != nil:
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+func (m *Mem) ReadDir(name string) ([]fs.DirEntry, error) {
+	path, err := m.key("open", name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.node(path)
+	switch {
+	case n == nil:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case n.children == nil:
+		return nil, &fs.PathError{Op: "readdirent", Path: name, Err: errNotDir}
+	}
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(&memInfo{name: child, node: m.nodes[filepath.Join(path, child)]}))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *Mem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	path, err := m.key("open", name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n, ok := m.nodes[path]; ok && n.children != nil {
+		return &fs.PathError{Op: "open", Path: name, Err: errIsDir}
+	}
+	if err := m.mkdirAll(filepath.Dir(path), 0755); err != nil {
+		return &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	m.nodes[path] = &memNode{data: append([]byte(nil), data...), mode: perm.Perm(), modTime: time.Now()}
+	m.nodes[filepath.Dir(path)].children[filepath.Base(path)] = true
+	return nil
+}
+
+func (m *Mem) MkdirAll(name string, perm fs.FileMode) error {
+	path, err := m.key("mkdir", name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.mkdirAll(path, perm); err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+// mkdirAll creates a directory and its missing parents. Callers hold the lock
+func (m *Mem) mkdirAll(path string, perm fs.FileMode) error {
+	if n := m.node(path); n != nil {
+		if n.children == nil {
+			return errNotDir
+		}
+		return nil
+	}
+	parent := filepath.Dir(path)
+	if err := m.mkdirAll(parent, perm); err != nil {
+		return err
+	}
+	m.nodes[path] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now(), children: map[string]bool{}}
+	m.nodes[parent].children[filepath.Base(path)] = true
+	return nil
+}
+
+// RemoveAll removes a path and its content, freeing its memory. A missing path is not an error
+func (m *Mem) RemoveAll(name string) error {
+	path, err := m.key("unlinkat", name)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.nodes[path]
+	if !ok {
+		return nil
+	}
+	m.remove(path, n)
+	if parent, ok := m.nodes[filepath.Dir(path)]; ok && parent != n {
+		delete(parent.children, filepath.Base(path))
+	}
+	return nil
+}
+
+func (m *Mem) remove(path string, n *memNode) {
+	for child := range n.children {
+		childPath := filepath.Join(path, child)
+		m.remove(childPath, m.nodes[childPath])
+	}
+	delete(m.nodes, path)
+}
+
+// Size returns the number of bytes of the files in memory
+func (m *Mem) Size() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var size int64
+	for _, n := range m.nodes {
+		size += int64(len(n.data))
+	}
+	return size
+}
+
+// memInfo is the fs.FileInfo of a node
+type memInfo struct {
+	name string
+	node *memNode
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i *memInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i *memInfo) ModTime() time.Time { return i.node.modTime }
+func (i *memInfo) IsDir() bool        { return i.node.children != nil }
+func (i *memInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,213 @@
+// Package gitclone checks out a path of a remote repository over HTTPS, for the checkouts of the SCM clients
+package gitclone
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "gitclone")
+
+// Options are the options of CheckoutAtPath
+type Options struct {
+	Strategy   string // "sparse" (scoped to path) or "shallow" (all files, depth 1)
+	Submodules bool   // initialize the submodules (under path when sparse), at depth 1
+	LFS        bool   // pull the Git LFS objects (under path when sparse)
+}
+
+// Remote is the HTTPS clone URL of a repository and its credentials. Without token, the clone is anonymous
+type Remote struct {
+	URL      string
+	Username string // e.g. "x-access-token" on GitHub, "oauth2" on GitLab
+	Token    string
+}
+
+// authenticated returns the clone URL with the credentials of the remote
+func (r Remote) authenticated() string {
+	if r.Token == "" {
+		return r.URL
+	}
+	return strings.Replace(r.URL, "https://", fmt.Sprintf("https://%s:%s@", r.Username, r.Token), 1)
+}
+
+// Cloner checks out the remote repositories
+type Cloner struct {
+	// Executor runs the git commands, nil uses sandbox.DefaultExecutor
+	Executor sandbox.Executor
+	// FS receives the checkouts, nil for the disk. Other filesystems get a copy of the checkout, without its
+	// .git directory, at the same path, and the clone is removed from disk
+	FS fsys.FS
+}
+
+// GIT_CLONE_CONFIG is set in the clones so that checkouts are the same on every platform: no CRLF
+// conversion of the manifests on Windows, and paths longer than MAX_PATH
+var GIT_CLONE_CONFIG = []string{"-c", "core.autocrlf=false", "-c", "core.longpaths=true"}
+
+// unsafeDirNameChars matches the characters of a branch name that are not portable in a directory name
+var unsafeDirNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// CheckoutAtPath clones and checks out specific ref at path with the specified options
+// returns the directory containing the checked out files
+// For sparse strategy, it does the following commands:
+// 1. git clone --filter=blob:none --depth 1 --no-checkout --single-branch -b branch cloneURL directory
+// 2. git sparse-checkout set --no-cone path
+// 3. git checkout branch
+// 4. return directory
+// For shallow strategy, it does:
+// 1. git clone --depth 1 --single-branch -b branch cloneURL directory
+// 2. return directory
+// Submodules and LFS objects are then fetched if enabled, see fetchCheckoutExtras, and the checkout moved to c.FS
+func (c *Cloner) CheckoutAtPath(ctx context.Context, remote Remote, branch, path string, opts Options) (string, error) {
+	strategy := opts.Strategy
+	path = filepath.ToSlash(path) // sparse patterns and pathspecs are slash-separated on every platform
+	logger.WithField("url", remote.URL).WithField("branch", branch).WithField("path", path).WithField("strategy", strategy).Info("CheckoutAtPath()")
+
+	// create /tmp at pwd if not exists
+	pwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get pwd: %w", err)
+	}
+	tmpdir := filepath.Join(pwd, "tmp")
+	if err := os.MkdirAll(tmpdir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create tmpdir at %s: %w", tmpdir, err)
+	}
+
+	chkoutName := unsafeDirNameChars.ReplaceAllString(branch, "_")
+	checkoutDir := fmt.Sprintf("chk-%s-%d", chkoutName, time.Now().Unix())
+	cloneURL := remote.authenticated()
+
+	if strategy == "shallow" {
+		// Shallow checkout: all files, depth 1
+		logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Shallow cloning (all files)...")
+		args := append([]string{"clone"}, GIT_CLONE_CONFIG...)
+		args = append(args, "--depth", "1", "--single-branch", "-b", branch, cloneURL, checkoutDir)
+		if _, err := c.runGit(ctx, tmpdir, args...); err != nil {
+			logger.WithField("error", err).Error("Shallow clone failed")
+			return "", fmt.Errorf("failed to shallow clone: %w", err)
+		}
+		logger.Debug("Shallow clone succeeded")
+
+		absPath, err := filepath.Abs(filepath.Join(tmpdir, checkoutDir))
+		logger.WithField("checkoutDir", checkoutDir).WithField("absPath", absPath).Debug("Absolute path...")
+		if err != nil {
+			_ = os.RemoveAll(filepath.Join(tmpdir, checkoutDir))
+			return "", fmt.Errorf("failed to get absolute path: %w", err)
+		}
+		if err := c.fetchCheckoutExtras(ctx, absPath, "", opts, remote); err != nil {
+			_ = os.RemoveAll(absPath)
+			return "", err
+		}
+		return c.moveToFS(absPath)
+	}
+
+	// Sparse checkout (default): scoped to path
+	// 1. git clone --filter=blob:none --depth 1 --no-checkout --single-branch -b branch cloneURL directory
+	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Sparse cloning...")
+	args := append([]string{"clone"}, GIT_CLONE_CONFIG...)
+	args = append(args, "--filter=blob:none", "--depth", "1", "--no-checkout", "--single-branch", "-b", branch, cloneURL, checkoutDir)
+	if _, err := c.runGit(ctx, tmpdir, args...); err != nil {
+		logger.WithField("error", err).Error("Clone failed")
+		return "", fmt.Errorf("failed to clone: %w", err)
+	}
+	logger.Debug("Clone succeeded")
+
+	// 2. git sparse-checkout set --no-cone path
+	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Set path sparse-checkout...")
+	if _, err := c.runGit(ctx, filepath.Join(tmpdir, checkoutDir), "sparse-checkout", "set", "--no-cone", path); err != nil {
+		logger.WithField("error", err).Error("Sparse checkout set failed")
+		_ = os.RemoveAll(filepath.Join(tmpdir, checkoutDir))
+		return "", fmt.Errorf("failed to set sparse checkout: %w", err)
+	}
+	logger.Debug("Sparse checkout set succeeded")
+
+	// 3. git checkout branch
+	logger.WithField("tmpdir", tmpdir).WithField("branch", branch).WithField("checkoutDir", checkoutDir).Debug("Check out branch...")
+	if _, err := c.runGit(ctx, filepath.Join(tmpdir, checkoutDir), "checkout", branch); err != nil {
+		logger.WithField("error", err).Error("Checkout failed")
+		_ = os.RemoveAll(filepath.Join(tmpdir, checkoutDir))
+		return "", fmt.Errorf("failed to checkout: %w", err)
+	}
+	logger.Debug("Checkout succeeded")
+
+	// 4. return directory
+	absPath, err := filepath.Abs(filepath.Join(tmpdir, checkoutDir))
+	logger.WithField("checkoutDir", checkoutDir).WithField("absPath", absPath).Debug("Absolute path...")
+	if err != nil {
+		_ = os.RemoveAll(filepath.Join(tmpdir, checkoutDir))
+		return "", fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	if err := c.fetchCheckoutExtras(ctx, absPath, path, opts, remote); err != nil {
+		_ = os.RemoveAll(absPath)
+		return "", err
+	}
+	return c.moveToFS(absPath)
+}
+
+// moveToFS moves a checkout of the disk to c.FS, at the same path
+func (c *Cloner) moveToFS(dir string) (string, error) {
+	if fsys.IsOS(c.FS) {
+		return dir, nil
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	if err := fsys.CopyFromDisk(c.FS, dir); err != nil {
+		_ = c.FS.RemoveAll(dir)
+		return "", fmt.Errorf("failed to move checkout %s: %w", dir, err)
+	}
+	logger.WithField("dir", dir).Debug("Moved checkout off disk")
+	return dir, nil
+}
+
+// fetchCheckoutExtras initializes the submodules and pulls the LFS objects of a checkout, if enabled, scoped
+// to path unless empty. Submodules of the same host as the remote are fetched with its credentials
+func (c *Cloner) fetchCheckoutExtras(ctx context.Context, dir, path string, opts Options, remote Remote) error {
+	if opts.Submodules {
+		var args []string
+		if u, err := url.Parse(remote.URL); err == nil && remote.Token != "" && u.Host != "" {
+			host := "https://" + u.Host + "/"
+			args = append(args, "-c", fmt.Sprintf("url.https://%s:%s@%s/.insteadOf=%s", remote.Username, remote.Token, u.Host, host))
+		}
+		args = append(args, "submodule", "update", "--init", "--recursive", "--depth", "1")
+		if path != "" {
+			args = append(args, "--", path)
+		}
+		logger.WithField("dir", dir).WithField("path", path).Debug("Initializing submodules...")
+		if _, err := c.runGit(ctx, dir, args...); err != nil {
+			return fmt.Errorf("failed to initialize submodules: %w", err)
+		}
+	}
+	if opts.LFS {
+		if _, err := exec.LookPath("git-lfs"); err != nil {
+			return fmt.Errorf("--git-lfs requires the git-lfs binary: %w", err)
+		}
+		args := []string{"lfs", "pull"}
+		if path != "" {
+			args = append(args, "--include", filepath.ToSlash(filepath.Join(path, "**")))
+		}
+		logger.WithField("dir", dir).WithField("path", path).Debug("Pulling LFS objects...")
+		if _, err := c.runGit(ctx, dir, args...); err != nil {
+			return fmt.Errorf("failed to pull LFS objects: %w", err)
+		}
+	}
+	return nil
+}
+
+// runGit runs a git command in dir with the executor of the cloner, returns its stdout
+func (c *Cloner) runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	stdout, err := sandbox.Output(ctx, c.Executor, sandbox.Command{Name: "git", Args: args, Dir: dir})
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+	"github.com/google/go-github/v66/github"
+)
+
+// APP_JWT_LIFETIME is the validity of the JWTs of the GitHub App, GitHub accepts at most 10 minutes
+const APP_JWT_LIFETIME = 9 * time.Minute
+
+// AppCredentials authenticate as an installation of a GitHub App, instead of a token
+type AppCredentials struct {
+	AppID          int64  `yaml:"appId"`
+	InstallationID int64  `yaml:"installationId"`
+	PrivateKeyPath string `yaml:"privateKeyPath"` // PEM private key of the app (PKCS#1 or PKCS#8)
+}
+
+// InstallationToken creates a token of the installation, valid for an hour
+func (a AppCredentials) InstallationToken(ctx context.Context) (string, error) {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return "", err
+	}
+	client := github.NewClient(httpclient.New(0)).WithAuthToken(jwt)
+	token, _, err := client.Apps.CreateInstallationToken(ctx, a.InstallationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create a token of installation %d of GitHub App %d: %w", a.InstallationID, a.AppID, err)
+	}
+	return token.GetToken(), nil
+}
+
+// jwt signs the JWT authenticating as the app (RS256), backdated a minute for clock drift
+func (a AppCredentials) jwt(now time.Time) (string, error) {
+	content, err := os.ReadFile(a.PrivateKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the private key of GitHub App %d: %w", a.AppID, err)
+	}
+	key, err := parseRSAPrivateKey(content)
+	if err != nil {
+		return "", fmt.Errorf("invalid private key of GitHub App %d: %w", a.AppID, err)
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(APP_JWT_LIFETIME).Unix(),
+		"iss": strconv.FormatInt(a.AppID, 10),
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the JWT of GitHub App %d: %w", a.AppID, err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func parseRSAPrivateKey(content []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -1,16 +1,19 @@
 package github
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"net/http"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitclone"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/transcript"
 	"github.com/google/go-github/v66/github"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
@@ -18,6 +21,12 @@ import (
 
 var logger = log.WithField("package", "github")
 
+// COMMIT_AUTHOR_NAME and COMMIT_AUTHOR_EMAIL author the commits pushed by the tool
+const (
+	COMMIT_AUTHOR_NAME  = "gitops-kustomzchk"
+	COMMIT_AUTHOR_EMAIL = "gitops-kustomzchk@users.noreply.github.com"
+)
+
 // GitHubClient defines the interface for GitHub API operations
 type GitHubClient interface {
 	// GetPR retrieves pull request information
@@ -28,17 +37,57 @@ type GitHubClient interface {
 	UpdateComment(ctx context.Context, repo string, commentID int64, body string) error
 	// GetComments retrieves all comments for a pull request
 	GetComments(ctx context.Context, repo string, number int) ([]*models.Comment, error)
+	// ListChangedFiles lists the files a pull request changes
+	ListChangedFiles(ctx context.Context, repo string, number int) ([]string, error)
 	// FindToolComment finds an existing tool-generated comment containing the search string
 	FindToolComment(ctx context.Context, repo string, prNumber int, searchString string) (*models.Comment, error)
-	// CheckoutAtPath clones and checks out specific ref at path with the specified strategy
-	CheckoutAtPath(ctx context.Context, cloneURL, ref, path, strategy string) (string, error)
+	// CheckoutAtPath clones and checks out specific ref at path with the specified options
+	CheckoutAtPath(ctx context.Context, cloneURL, ref, path string, opts CheckoutOptions) (string, error)
+	// CreateReviewComment comments a line of a file of the pull request, at the given commit
+	CreateReviewComment(ctx context.Context, repo string, number int, commitID, path string, line int, body string) error
+	// GetReviewComments retrieves all review comments of a pull request
+	GetReviewComments(ctx context.Context, repo string, number int) ([]*models.Comment, error)
+	// CommitAndPush commits files of a checkout and pushes the commit to a branch
+	CommitAndPush(ctx context.Context, dir string, files []string, message, branch string, force bool) (string, error)
+	// FindPullRequestURL finds the open pull request of a head branch, empty if none
+	FindPullRequestURL(ctx context.Context, repo, head string) (string, error)
+	// CreatePullRequest opens a pull request of head into base
+	CreatePullRequest(ctx context.Context, repo, head, base, title, body string) (string, error)
+	// RequestTeamReviewers requests the reviews of teams on a pull request
+	RequestTeamReviewers(ctx context.Context, repo string, number int, teams []string) error
+	// EnableAutoMerge enables auto-merge on a pull request, by its GraphQL node ID, if its head is still headSHA
+	EnableAutoMerge(ctx context.Context, nodeID, method, headSHA string) error
+	// DisableAutoMerge disables auto-merge on a pull request, by its GraphQL node ID
+	DisableAutoMerge(ctx context.Context, nodeID string) error
+	// AddLabel adds a label to a pull request
+	AddLabel(ctx context.Context, repo string, number int, label string) error
+	// RemoveLabel removes a label from a pull request
+	RemoveLabel(ctx context.Context, repo string, number int, label string) error
+	// CreateCheckRun creates a completed check run of a commit
+	CreateCheckRun(ctx context.Context, repo string, run models.CheckRun) error
 }
 
+// CHECK_ANNOTATIONS_PER_REQUEST is the maximum number of annotations of a check run request, the others are
+// added by updating the check run
+const CHECK_ANNOTATIONS_PER_REQUEST = 50
+
 // Client handles GitHub API interactions using go-github
 type Client struct {
 	client *github.Client
+	token  string
+
+	// Executor runs the git commands of the checkouts and pushes, nil uses sandbox.DefaultExecutor
+	Executor sandbox.Executor
+	// FS receives the checkouts, nil for the disk. Other filesystems get a copy of the checkout, without its
+	// .git directory, at the same path, and the clone is removed from disk
+	FS fsys.FS
+	// Transcript records the mutations of the pull requests, nil records nothing
+	Transcript *transcript.Transcript
 }
 
+// SCM_NAME is the SCM of the transcript entries of the client
+const SCM_NAME = "github"
+
 // Ensure Client implements GitHubClient
 var _ GitHubClient = (*Client)(nil)
 
@@ -51,14 +100,20 @@ func NewClient() (*Client, error) {
 	if token == "" {
 		return nil, fmt.Errorf("GitHub token not found. Set GH_TOKEN or GITHUB_TOKEN environment variable")
 	}
+	return NewClientWithToken(token), nil
+}
 
+// NewClientWithToken creates a GitHub client authenticated with token, for the API calls and the checkouts
+func NewClientWithToken(token string) *Client {
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	tc := oauth2.NewClient(context.Background(), ts)
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, httpclient.New(0))
+	tc := oauth2.NewClient(ctx, ts)
 	client := github.NewClient(tc)
 
 	return &Client{
 		client: client,
-	}, nil
+		token:  token,
+	}
 }
 
 // GetPR retrieves pull request information
@@ -72,12 +127,20 @@ func (c *Client) GetPR(ctx context.Context, repo string, number int) (*models.Pu
 		return nil, fmt.Errorf("failed to get PR: %w", err)
 	}
 
+	labels := make([]string, len(pr.Labels))
+	for i, label := range pr.Labels {
+		labels[i] = label.GetName()
+	}
+
 	return &models.PullRequest{
-		Number:  pr.GetNumber(),
-		BaseRef: pr.GetBase().GetRef(),
-		BaseSHA: pr.GetBase().GetSHA(),
-		HeadRef: pr.GetHead().GetRef(),
-		HeadSHA: pr.GetHead().GetSHA(),
+		Number:    pr.GetNumber(),
+		BaseRef:   pr.GetBase().GetRef(),
+		BaseSHA:   pr.GetBase().GetSHA(),
+		HeadRef:   pr.GetHead().GetRef(),
+		HeadSHA:   pr.GetHead().GetSHA(),
+		NodeID:    pr.GetNodeID(),
+		Labels:    labels,
+		AutoMerge: pr.AutoMerge != nil,
 	}, nil
 }
 
@@ -92,6 +155,7 @@ func (c *Client) CreateComment(ctx context.Context, repo string, number int, bod
 	}
 
 	created, _, err := c.client.Issues.CreateComment(ctx, owner, repo, number, comment)
+	c.record(transcript.OP_CREATE_COMMENT, fmt.Sprintf("%s/%s#%d", owner, repo, number), body, nil, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create comment: %w", err)
 	}
@@ -114,6 +178,7 @@ func (c *Client) UpdateComment(ctx context.Context, repo string, commentID int64
 
 	commentRes, res, err := c.client.Issues.EditComment(ctx, owner, repo, commentID, comment)
 	log.WithField("comment", commentRes).WithField("response", res).Debug("Updated comment")
+	c.record(transcript.OP_UPDATE_COMMENT, fmt.Sprintf("%s/%s comment %d", owner, repo, commentID), body, nil, err)
 	if err != nil {
 		return fmt.Errorf("failed to update comment: %w", err)
 	}
@@ -121,6 +186,35 @@ func (c *Client) UpdateComment(ctx context.Context, repo string, commentID int64
 	return nil
 }
 
+// ListChangedFiles lists the paths of the files a pull request changes, with the previous path of the renamed files
+// GitHub lists at most 3000 files
+func (c *Client) ListChangedFiles(ctx context.Context, repo string, number int) ([]string, error) {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository: %w", err)
+	}
+	opts := &github.ListOptions{PerPage: 100}
+
+	var paths []string
+	for {
+		files, resp, err := c.client.PullRequests.ListFiles(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list changed files: %w", err)
+		}
+		for _, f := range files {
+			paths = append(paths, f.GetFilename())
+			if previous := f.GetPreviousFilename(); previous != "" {
+				paths = append(paths, previous)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return paths, nil
+}
+
 // GetComments retrieves all comments for a pull request
 // Current limitation it will only fetch first 200 comments, hopefully it contains override messages..
 func (c *Client) GetComments(ctx context.Context, repo string, prNumber int) ([]*models.Comment, error) {
@@ -155,6 +249,63 @@ func (c *Client) GetComments(ctx context.Context, repo string, prNumber int) ([]
 	return allComments, nil
 }
 
+// CreateReviewComment comments a line of a file of the pull request (right side of the diff), at the given commit
+// The line must be part of the pull request diff, else the API rejects the comment
+func (c *Client) CreateReviewComment(ctx context.Context, repo string, number int, commitID, path string, line int, body string) error {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository: %w", err)
+	}
+	comment := &github.PullRequestComment{
+		Body:     github.String(body),
+		CommitID: github.String(commitID),
+		Path:     github.String(path),
+		Line:     github.Int(line),
+		Side:     github.String("RIGHT"),
+	}
+
+	_, _, err = c.client.PullRequests.CreateComment(ctx, owner, repo, number, comment)
+	c.record(transcript.OP_CREATE_REVIEW_COMMENT, fmt.Sprintf("%s/%s#%d", owner, repo, number), body,
+		map[string]string{"commit": commitID, "path": path, "line": fmt.Sprint(line)}, err)
+	if err != nil {
+		return fmt.Errorf("failed to create review comment on %s:%d: %w", path, line, err)
+	}
+	return nil
+}
+
+// GetReviewComments retrieves all review comments of a pull request
+func (c *Client) GetReviewComments(ctx context.Context, repo string, prNumber int) ([]*models.Comment, error) {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository: %w", err)
+	}
+	opts := &github.PullRequestListCommentsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var allComments []*models.Comment
+	for {
+		comments, resp, err := c.client.PullRequests.ListComments(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get review comments: %w", err)
+		}
+
+		for _, c := range comments {
+			allComments = append(allComments, &models.Comment{
+				ID:   c.GetID(),
+				Body: c.GetBody(),
+			})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allComments, nil
+}
+
 // FindToolComment finds an existing tool-generated comment containing the search string
 // If multiple comments with the same marker exist, returns the first one found
 func (c *Client) FindToolComment(ctx context.Context, repo string, prNumber int, searchString string) (*models.Comment, error) {
@@ -172,137 +323,248 @@ func (c *Client) FindToolComment(ctx context.Context, repo string, prNumber int,
 	return nil, nil // Returns nil if not found
 }
 
-// CheckoutAtPath clones and checks out specific ref at path with the specified strategy
-// strategy: "sparse" (scoped to path) or "shallow" (all files, depth 1)
+// CheckoutOptions are the options of CheckoutAtPath
+type CheckoutOptions = gitclone.Options
+
+// CheckoutAtPath clones and checks out specific ref at path with the specified options, see gitclone.Cloner
 // returns the directory containing the checked out files
-// For sparse strategy, it does the following commands:
-// 1. git clone --filter=blob:none --depth 1 --no-checkout --single-branch -b branch cloneURL directory
-// 2. git sparse-checkout set --no-cone path
-// 3. git checkout branch
-// 4. return directory
-// For shallow strategy, it does:
-// 1. git clone --depth 1 --single-branch -b branch cloneURL directory
-// 2. return directory
-func (c *Client) CheckoutAtPath(ctx context.Context, repo, branch, path, strategy string) (string, error) {
-	logger.WithField("repo", repo).WithField("branch", branch).WithField("path", path).WithField("strategy", strategy).Info("CheckoutAtPath()")
-
-	// create /tmp at pwd if not exists
-	pwd, err := os.Getwd()
+func (c *Client) CheckoutAtPath(ctx context.Context, repo, branch, path string, opts CheckoutOptions) (string, error) {
+	cloneURL, err := GetHTTPSCloneURLForRepo(repo)
 	if err != nil {
-		return "", fmt.Errorf("failed to get pwd: %w", err)
+		return "", fmt.Errorf("failed to get clone URL: %w", err)
 	}
-	tmpdir := filepath.Join(pwd, "tmp")
-	if err := os.MkdirAll(tmpdir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create tmpdir at %s: %w", tmpdir, err)
+
+	// Use GitHub token for authentication, x-access-token as username with token as password
+	cloner := &gitclone.Cloner{Executor: c.Executor, FS: c.FS}
+	return cloner.CheckoutAtPath(ctx, gitclone.Remote{URL: cloneURL, Username: "x-access-token", Token: c.token}, branch, path, opts)
+}
+
+// CommitAndPush commits files of a checkout made by CheckoutAtPath and pushes the commit to branch,
+// with the checkout credentials. The commit is authored by COMMIT_AUTHOR_NAME.
+// returns: the SHA of the pushed commit
+func (c *Client) CommitAndPush(ctx context.Context, dir string, files []string, message, branch string, force bool) (string, error) {
+	logger.WithField("dir", dir).WithField("branch", branch).WithField("files", files).Info("CommitAndPush()")
+
+	if _, err := c.runGit(ctx, dir, append([]string{"add", "--"}, files...)...); err != nil {
+		return "", fmt.Errorf("failed to stage files: %w", err)
+	}
+	if _, err := c.runGit(ctx, dir, "-c", "user.name="+COMMIT_AUTHOR_NAME, "-c", "user.email="+COMMIT_AUTHOR_EMAIL,
+		"commit", "-m", message); err != nil {
+		return "", fmt.Errorf("failed to commit: %w", err)
+	}
+	sha, err := c.runGit(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit SHA: %w", err)
+	}
+	pushArgs := []string{"push"}
+	if force {
+		pushArgs = append(pushArgs, "--force")
 	}
+	_, err = c.runGit(ctx, dir, append(pushArgs, "origin", "HEAD:refs/heads/"+branch)...)
+	c.record(transcript.OP_PUSH, branch, message, map[string]string{
+		"commit": strings.TrimSpace(sha), "files": strings.Join(files, ","), "force": fmt.Sprint(force),
+	}, err)
+	if err != nil {
+		return "", fmt.Errorf("failed to push to %s: %w", branch, err)
+	}
+	return strings.TrimSpace(sha), nil
+}
 
-	chkoutName := strings.ReplaceAll(branch, "/", "_")
-	checkoutDir := fmt.Sprintf("chk-%s-%d", chkoutName, time.Now().Unix())
-	cloneURL, err := GetHTTPSCloneURLForRepo(repo)
+// runGit runs a git command in dir with the executor of the client, returns its stdout
+func (c *Client) runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	stdout, err := sandbox.Output(ctx, c.Executor, sandbox.Command{Name: "git", Args: args, Dir: dir})
 	if err != nil {
-		return "", fmt.Errorf("failed to get clone URL: %w", err)
+		return "", err
 	}
+	return string(stdout), nil
+}
 
-	// Use GitHub token for authentication
-	token := os.Getenv("GH_TOKEN")
-	if token == "" {
-		token = os.Getenv("GITHUB_TOKEN")
+// FindPullRequestURL finds the open pull request of a head branch of the repository, empty if none
+func (c *Client) FindPullRequestURL(ctx context.Context, repo, head string) (string, error) {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse repository: %w", err)
+	}
+	prs, _, err := c.client.PullRequests.List(ctx, owner, repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  owner + ":" + head,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	if len(prs) == 0 {
+		return "", nil
+	}
+	return prs[0].GetHTMLURL(), nil
+}
+
+// CreatePullRequest opens a pull request of head into base, returns its URL
+func (c *Client) CreatePullRequest(ctx context.Context, repo, head, base, title, body string) (string, error) {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse repository: %w", err)
+	}
+	pr, _, err := c.client.PullRequests.Create(ctx, owner, repo, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(head),
+		Base:  github.String(base),
+		Body:  github.String(body),
+	})
+	c.record(transcript.OP_CREATE_PULL_REQUEST, owner+"/"+repo, body, map[string]string{"head": head, "base": base, "title": title}, err)
+	if err != nil {
+		return "", fmt.Errorf("failed to create pull request: %w", err)
+	}
+	return pr.GetHTMLURL(), nil
+}
+
+// RequestTeamReviewers requests the reviews of teams (slugs of the repository organization) on a pull request
+// Teams already requested or having reviewed are left as is by the API
+func (c *Client) RequestTeamReviewers(ctx context.Context, repo string, number int, teams []string) error {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository: %w", err)
+	}
+	_, _, err = c.client.PullRequests.RequestReviewers(ctx, owner, repo, number, github.ReviewersRequest{TeamReviewers: teams})
+	c.record(transcript.OP_REQUEST_REVIEWERS, fmt.Sprintf("%s/%s#%d", owner, repo, number), "", map[string]string{"teams": strings.Join(teams, ",")}, err)
+	if err != nil {
+		return fmt.Errorf("failed to request reviewers %v: %w", teams, err)
+	}
+	return nil
+}
+
+// EnableAutoMerge enables auto-merge on a pull request with a merge method (merge, squash or rebase),
+// so that it merges once its requirements pass. Auto-merge must be allowed in the repository settings.
+// GitHub rejects the mutation if the head of the pull request is no longer headSHA, the evaluated commit
+func (c *Client) EnableAutoMerge(ctx context.Context, nodeID, method, headSHA string) error {
+	mutation := `mutation($id: ID!, $method: PullRequestMergeMethod!, $head: GitObjectID!) {
+  enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method, expectedHeadOid: $head}) { clientMutationId }
+}`
+	vars := map[string]any{"id": nodeID, "method": strings.ToUpper(method), "head": headSHA}
+	err := c.graphql(ctx, mutation, vars)
+	c.record(transcript.OP_ENABLE_AUTO_MERGE, nodeID, "", map[string]string{"method": method, "head": headSHA}, err)
+	if err != nil {
+		return fmt.Errorf("failed to enable auto-merge: %w", err)
+	}
+	return nil
+}
+
+// DisableAutoMerge disables auto-merge on a pull request
+func (c *Client) DisableAutoMerge(ctx context.Context, nodeID string) error {
+	mutation := `mutation($id: ID!) {
+  disablePullRequestAutoMerge(input: {pullRequestId: $id}) { clientMutationId }
+}`
+	err := c.graphql(ctx, mutation, map[string]any{"id": nodeID})
+	c.record(transcript.OP_DISABLE_AUTO_MERGE, nodeID, "", nil, err)
+	if err != nil {
+		return fmt.Errorf("failed to disable auto-merge: %w", err)
+	}
+	return nil
+}
+
+// graphql runs a GraphQL mutation, auto-merge has no REST endpoint
+func (c *Client) graphql(ctx context.Context, query string, vars map[string]any) error {
+	req, err := c.client.NewRequest(http.MethodPost, "graphql", map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if _, err := c.client.Do(ctx, req, &resp); err != nil {
+		return err
 	}
-	if token != "" {
-		// Use x-access-token as username with token as password
-		cloneURL = strings.Replace(cloneURL, "https://", fmt.Sprintf("https://x-access-token:%s@", token), 1)
-	}
-
-	if strategy == "shallow" {
-		// Shallow checkout: all files, depth 1
-		logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Shallow cloning (all files)...")
-		cloneCmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--single-branch", "-b", branch, cloneURL, checkoutDir)
-		logger.WithField("cloneCmd", cloneCmd.String()).Debug("Showing clone command")
-		cloneCmd.Dir = tmpdir
-		var cloneStdout, cloneStderr bytes.Buffer
-		cloneCmd.Stdout = &cloneStdout
-		cloneCmd.Stderr = &cloneStderr
-		if err := cloneCmd.Run(); err != nil {
-			logger.WithField("stdout", cloneStdout.String()).WithField("stderr", cloneStderr.String()).Error("Shallow clone failed")
-			return "", fmt.Errorf("failed to shallow clone: %w\nStdout: %s\nStderr: %s", err, cloneStdout.String(), cloneStderr.String())
+	if len(resp.Errors) > 0 {
+		messages := make([]string, len(resp.Errors))
+		for i, e := range resp.Errors {
+			messages[i] = e.Message
 		}
-		logger.WithField("stdout", cloneStdout.String()).WithField("stderr", cloneStderr.String()).Debug("Shallow clone succeeded")
+		return fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+	return nil
+}
 
-		absPath, err := filepath.Abs(filepath.Join(tmpdir, checkoutDir))
-		logger.WithField("checkoutDir", checkoutDir).WithField("absPath", absPath).Debug("Absolute path...")
-		if err != nil {
-			_ = os.RemoveAll(filepath.Join(tmpdir, checkoutDir))
-			return "", fmt.Errorf("failed to get absolute path: %w", err)
+// AddLabel adds a label to a pull request, the label is created by the API if the repository has none of the name
+func (c *Client) AddLabel(ctx context.Context, repo string, number int, label string) error {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository: %w", err)
+	}
+	_, _, err = c.client.Issues.AddLabelsToIssue(ctx, owner, repo, number, []string{label})
+	c.record(transcript.OP_ADD_LABEL, fmt.Sprintf("%s/%s#%d", owner, repo, number), "", map[string]string{"label": label}, err)
+	if err != nil {
+		return fmt.Errorf("failed to add label %s: %w", label, err)
+	}
+	return nil
+}
+
+// RemoveLabel removes a label from a pull request
+func (c *Client) RemoveLabel(ctx context.Context, repo string, number int, label string) error {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository: %w", err)
+	}
+	_, err = c.client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, label)
+	c.record(transcript.OP_REMOVE_LABEL, fmt.Sprintf("%s/%s#%d", owner, repo, number), "", map[string]string{"label": label}, err)
+	if err != nil {
+		return fmt.Errorf("failed to remove label %s: %w", label, err)
+	}
+	return nil
+}
+
+// CreateCheckRun creates a completed check run of a commit with its annotations, sent by batches of
+// CHECK_ANNOTATIONS_PER_REQUEST. A check run of the same name replaces the previous one of the commit in the
+// branch protection. The token needs the checks:write permission
+func (c *Client) CreateCheckRun(ctx context.Context, repo string, run models.CheckRun) error {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository: %w", err)
+	}
+	outputOf := func(annotations []models.CheckAnnotation) *github.CheckRunOutput {
+		output := &github.CheckRunOutput{Title: github.String(run.Title), Summary: github.String(run.Summary)}
+		for _, a := range annotations {
+			output.Annotations = append(output.Annotations, &github.CheckRunAnnotation{
+				Path:            github.String(a.Path),
+				StartLine:       github.Int(a.Line),
+				EndLine:         github.Int(a.Line),
+				AnnotationLevel: github.String(a.Level),
+				Title:           github.String(a.Title),
+				Message:         github.String(a.Message),
+			})
 		}
-		return absPath, nil
-	}
-
-	// Sparse checkout (default): scoped to path
-	// 1. git clone --filter=blob:none --depth 1 --no-checkout --single-branch -b branch cloneURL directory
-	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Sparse cloning...")
-	cloneCmd := exec.CommandContext(ctx, "git", "clone", "--filter=blob:none", "--depth", "1", "--no-checkout", "--single-branch", "-b", branch, cloneURL, checkoutDir)
-	logger.WithField("cloneCmd", cloneCmd.String()).Debug("Showing clone command")
-	cloneCmd.Dir = tmpdir
-	var cloneStdout, cloneStderr bytes.Buffer
-	cloneCmd.Stdout = &cloneStdout
-	cloneCmd.Stderr = &cloneStderr
-	if err := cloneCmd.Run(); err != nil {
-		logger.WithField("stdout", cloneStdout.String()).WithField("stderr", cloneStderr.String()).Error("Clone failed")
-		return "", fmt.Errorf("failed to clone: %w\nStdout: %s\nStderr: %s", err, cloneStdout.String(), cloneStderr.String())
-	}
-	logger.WithField("stdout", cloneStdout.String()).WithField("stderr", cloneStderr.String()).Debug("Clone succeeded")
-
-	// 2. git sparse-checkout set --no-cone path
-	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Set path sparse-checkout...")
-	sparseCmd := exec.CommandContext(ctx, "git", "sparse-checkout", "set", "--no-cone", path)
-	sparseCmd.Dir = filepath.Join(tmpdir, checkoutDir)
-	logger.WithField("sparseCmd", sparseCmd.String()).Debug("Showing sparse-checkout command")
-	var sparseStdout, sparseStderr bytes.Buffer
-	sparseCmd.Stdout = &sparseStdout
-	sparseCmd.Stderr = &sparseStderr
-	if err := sparseCmd.Run(); err != nil {
-		logger.WithField("stdout", sparseStdout.String()).WithField("stderr", sparseStderr.String()).Error("Sparse checkout set failed")
-		_ = os.RemoveAll(filepath.Join(tmpdir, checkoutDir))
-		return "", fmt.Errorf("failed to set sparse checkout: %w\nStdout: %s\nStderr: %s", err, sparseStdout.String(), sparseStderr.String())
-	}
-	logger.WithField("stdout", sparseStdout.String()).WithField("stderr", sparseStderr.String()).Debug("Sparse checkout set succeeded")
-
-	// 3. git checkout branch
-	logger.WithField("tmpdir", tmpdir).WithField("branch", branch).WithField("checkoutDir", checkoutDir).Debug("Check out branch...")
-	checkoutCmd := exec.CommandContext(ctx, "git", "checkout", branch)
-	checkoutCmd.Dir = filepath.Join(tmpdir, checkoutDir)
-	logger.WithField("checkoutCmd", checkoutCmd.String()).Debug("Showing checkout command")
-	var checkoutStdout, checkoutStderr bytes.Buffer
-	checkoutCmd.Stdout = &checkoutStdout
-	checkoutCmd.Stderr = &checkoutStderr
-	if err := checkoutCmd.Run(); err != nil {
-		logger.WithField("stdout", checkoutStdout.String()).WithField("stderr", checkoutStderr.String()).Error("Checkout failed")
-		_ = os.RemoveAll(filepath.Join(tmpdir, checkoutDir))
-		return "", fmt.Errorf("failed to checkout: %w\nStdout: %s\nStderr: %s", err, checkoutStdout.String(), checkoutStderr.String())
-	}
-	logger.WithField("stdout", checkoutStdout.String()).WithField("stderr", checkoutStderr.String()).Debug("Checkout succeeded")
-
-	// 4. return directory
-	absPath, err := filepath.Abs(filepath.Join(tmpdir, checkoutDir))
-	logger.WithField("checkoutDir", checkoutDir).WithField("absPath", absPath).Debug("Absolute path...")
+		return output
+	}
+	batch := run.Annotations[:min(len(run.Annotations), CHECK_ANNOTATIONS_PER_REQUEST)]
+	opts := github.CreateCheckRunOptions{
+		Name:        run.Name,
+		HeadSHA:     run.HeadSHA,
+		Status:      github.String("completed"),
+		Conclusion:  github.String(run.Conclusion),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output:      outputOf(batch),
+	}
+	if run.DetailsURL != "" {
+		opts.DetailsURL = github.String(run.DetailsURL)
+	}
+	checkRun, _, err := c.client.Checks.CreateCheckRun(ctx, owner, repo, opts)
+	c.record(transcript.OP_CREATE_CHECK_RUN, fmt.Sprintf("%s/%s@%s", owner, repo, run.HeadSHA), run.Summary, map[string]string{
+		"name": run.Name, "title": run.Title, "conclusion": run.Conclusion, "annotations": fmt.Sprint(len(run.Annotations)),
+	}, err)
 	if err != nil {
-		_ = os.RemoveAll(filepath.Join(tmpdir, checkoutDir))
-		return "", fmt.Errorf("failed to get absolute path: %w", err)
-	}
-
-	// // this spots the case that --manfifest-path has a ./ prefix didn't work
-	// list files with permissions in the following directory [pwd, tmpdir, checkoutDir]
-	// logger.Info("DEBUGGING: LISTING FILES IN THE FOLLOWING DIRECTORIES [pwd, tmpdir, absPath]")
-	// dirs := []string{pwd, tmpdir, absPath}
-	// for _, dir := range dirs {
-	// 	logger.WithField("dir", dir).Debug("Started list ls -la...")
-	// 	lsCmd := exec.CommandContext(ctx, "ls", "-la", dir)
-	// 	output, err := lsCmd.CombinedOutput()
-	// 	if err != nil {
-	// 		return "", fmt.Errorf("failed to list directory %s: %w\nOutput: %s", dir, err, string(output))
-	// 	}
-	// 	logger.WithField("dir", dir).WithField("output", string(output)).Debug("Listed directory...")
-	// }
-
-	return absPath, nil
+		return fmt.Errorf("failed to create check run %s: %w", run.Name, err)
+	}
+	for i := len(batch); i < len(run.Annotations); i += CHECK_ANNOTATIONS_PER_REQUEST {
+		batch := run.Annotations[i:min(len(run.Annotations), i+CHECK_ANNOTATIONS_PER_REQUEST)]
+		update := github.UpdateCheckRunOptions{Name: run.Name, Output: outputOf(batch)}
+		if _, _, err := c.client.Checks.UpdateCheckRun(ctx, owner, repo, checkRun.GetID(), update); err != nil {
+			return fmt.Errorf("failed to add annotations to check run %s: %w", run.Name, err)
+		}
+	}
+	return nil
+}
+
+// record records a mutation of the repository with its outcome on the transcript of the client, if any
+func (c *Client) record(operation, target, body string, fields map[string]string, err error) {
+	c.Transcript.Record(transcript.Entry{SCM: SCM_NAME, Operation: operation, Target: target, Body: body, Fields: fields}, err)
 }
@@ -0,0 +1,45 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestEnableAutoMerge checks that auto-merge is enabled for the evaluated head commit only
+func TestEnableAutoMerge(t *testing.T) {
+	var request struct {
+		Query     string         `json:"query"`
+		Variables map[string]any `json:"variables"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" {
+			t.Errorf("request path = %s, want /graphql", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode the request: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"data": {}}`))
+	}))
+	defer server.Close()
+
+	c := NewClientWithToken("token")
+	c.client.BaseURL, _ = url.Parse(server.URL + "/")
+	if err := c.EnableAutoMerge(context.Background(), "PR_1", "squash", "abc123"); err != nil {
+		t.Fatalf("EnableAutoMerge() error = %v", err)
+	}
+
+	if !strings.Contains(request.Query, "expectedHeadOid: $head") {
+		t.Errorf("EnableAutoMerge() query %q does not pass expectedHeadOid", request.Query)
+	}
+	want := map[string]any{"id": "PR_1", "method": "SQUASH", "head": "abc123"}
+	for name, value := range want {
+		if request.Variables[name] != value {
+			t.Errorf("EnableAutoMerge() variable %s = %v, want %v", name, request.Variables[name], value)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	// EVENT_ISSUE_COMMENT is the GITHUB_EVENT_NAME of the workflows triggered by a pull request (or issue) comment
+	EVENT_ISSUE_COMMENT = "issue_comment"
+	// COMMENT_ACTION_CREATED is the action of a new comment
+	COMMENT_ACTION_CREATED = "created"
+)
+
+// CommentEvent is the issue_comment event payload of a workflow run
+type CommentEvent struct {
+	Action        string
+	Number        int // number of the issue or pull request
+	Body          string
+	User          string
+	IsPullRequest bool // the comment is on a pull request, not an issue
+}
+
+// CommentEventFromEnv reads the comment event that triggered the workflow run from GITHUB_EVENT_PATH,
+// nil if the run was not triggered by a comment
+func CommentEventFromEnv() (*CommentEvent, error) {
+	if os.Getenv("GITHUB_EVENT_NAME") != EVENT_ISSUE_COMMENT {
+		return nil, nil
+	}
+	path := os.Getenv("GITHUB_EVENT_PATH")
+	if path == "" {
+		return nil, fmt.Errorf("GITHUB_EVENT_PATH is not set")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the event payload: %w", err)
+	}
+
+	var payload struct {
+		Action string `json:"action"`
+		Issue  struct {
+			Number      int              `json:"number"`
+			PullRequest *json.RawMessage `json:"pull_request"`
+		} `json:"issue"`
+		Comment struct {
+			Body string `json:"body"`
+			User struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		} `json:"comment"`
+	}
+	if err := json.Unmarshal(content, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse the event payload: %w", err)
+	}
+	return &CommentEvent{
+		Action:        payload.Action,
+		Number:        payload.Issue.Number,
+		Body:          payload.Comment.Body,
+		User:          payload.Comment.User.Login,
+		IsPullRequest: payload.Issue.PullRequest != nil,
+	}, nil
+}
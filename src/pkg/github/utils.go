@@ -1,10 +1,41 @@
 package github
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
+
+	"github.com/google/go-github/v66/github"
 )
 
+// API_HOSTS are the hosts of the GitHub API, git remotes and LFS objects, with their subdomains
+var API_HOSTS = []string{"github.com", "githubusercontent.com"}
+
+// IsAuthError reports whether err is the GitHub API rejecting the token (401, or 403 without a rate limit)
+func IsAuthError(err error) bool {
+	var errResp *github.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return false
+	}
+	return errResp.Response.StatusCode == http.StatusUnauthorized || errResp.Response.StatusCode == http.StatusForbidden
+}
+
+// RetryAfter reports whether err is the GitHub API throttling the requests, primary or secondary rate limit,
+// with the wait until the limit resets
+func RetryAfter(err error) (time.Duration, bool) {
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		return abuseErr.GetRetryAfter(), true
+	}
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return max(time.Until(rateErr.Rate.Reset.Time), 0), true
+	}
+	return 0, false
+}
+
 // ParseRepo parses a repository string into owner and repository
 // Example: "owner/repository" -> "owner", "repository"
 // Example: "owner/repository/subpath" -> "owner", "repository"
@@ -0,0 +1,327 @@
+// Package gitlab is the GitLab API client of the gitlab run mode: merge requests, their notes and the
+// checkouts of their branches
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/gitclone"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/transcript"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "gitlab")
+
+// DEFAULT_SERVER_URL is the GitLab instance without CI_SERVER_URL, set by GitLab CI to that of the pipeline
+const DEFAULT_SERVER_URL = "https://gitlab.com"
+
+// NOTES_PER_PAGE is the page size of the notes listing, the maximum of the API
+const NOTES_PER_PAGE = 100
+
+// GitLabClient defines the interface for GitLab API operations
+type GitLabClient interface {
+	// GetMR retrieves merge request information
+	GetMR(ctx context.Context, project string, iid int) (*models.PullRequest, error)
+	// CreateComment creates a new note on a merge request
+	CreateComment(ctx context.Context, project string, iid int, body string) (*models.Comment, error)
+	// UpdateComment updates an existing note of a merge request
+	UpdateComment(ctx context.Context, project string, iid int, noteID int64, body string) error
+	// GetComments retrieves all user notes of a merge request
+	GetComments(ctx context.Context, project string, iid int) ([]*models.Comment, error)
+	// FindToolComment finds an existing tool-generated note containing the search string
+	FindToolComment(ctx context.Context, project string, iid int, searchString string) (*models.Comment, error)
+	// CheckoutAtPath clones and checks out specific ref at path with the specified options
+	CheckoutAtPath(ctx context.Context, project, ref, path string, opts gitclone.Options) (string, error)
+}
+
+// Client handles GitLab REST API (v4) interactions
+type Client struct {
+	serverURL string
+	token     string
+	http      *http.Client
+
+	// Executor runs the git commands of the checkouts, nil uses sandbox.DefaultExecutor
+	Executor sandbox.Executor
+	// FS receives the checkouts, nil for the disk, see gitclone.Cloner
+	FS fsys.FS
+	// Transcript records the comments published on the merge requests, nil records nothing
+	Transcript *transcript.Transcript
+}
+
+// SCM_NAME is the SCM of the transcript entries of the client
+const SCM_NAME = "gitlab"
+
+// Ensure Client implements GitLabClient
+var _ GitLabClient = (*Client)(nil)
+
+// NewClient creates a new GitLab client of the instance of ServerURL
+func NewClient() (*Client, error) {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("GitLab token not found. Set GITLAB_TOKEN environment variable (a project or personal access token with the api scope)")
+	}
+	return &Client{
+		serverURL: ServerURL(),
+		token:     token,
+		http:      httpclient.New(0),
+	}, nil
+}
+
+// ServerURL returns the URL of the GitLab instance, from CI_SERVER_URL
+func ServerURL() string {
+	if serverURL := os.Getenv("CI_SERVER_URL"); serverURL != "" {
+		return strings.TrimSuffix(serverURL, "/")
+	}
+	return DEFAULT_SERVER_URL
+}
+
+// APIHosts returns the host of the GitLab instance, serving the API, git remotes and LFS objects
+func APIHosts() []string {
+	u, err := url.Parse(ServerURL())
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	return []string{u.Hostname()}
+}
+
+// APIError is an error response of the GitLab API
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration // the Retry-After of a throttled response, 0 if none
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GitLab API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// IsAuthError reports whether err is the GitLab API rejecting the token (401 or 403)
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+}
+
+// RetryAfter reports whether err is the GitLab API throttling the requests (429), with the wait it asks for
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	return apiErr.RetryAfter, true
+}
+
+// mergeRequest is the merge request of the API, the fields used only
+type mergeRequest struct {
+	IID          int      `json:"iid"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	State        string   `json:"state"`
+	SourceBranch string   `json:"source_branch"`
+	TargetBranch string   `json:"target_branch"`
+	SHA          string   `json:"sha"`
+	Labels       []string `json:"labels"`
+	DiffRefs     struct {
+		BaseSHA string `json:"base_sha"`
+		HeadSHA string `json:"head_sha"`
+	} `json:"diff_refs"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// note is a comment of a merge request, system notes are the activity of the merge request
+type note struct {
+	ID     int64  `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+	Author struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (n *note) comment() *models.Comment {
+	return &models.Comment{
+		ID:        n.ID,
+		Body:      n.Body,
+		User:      n.Author.Username,
+		CreatedAt: n.CreatedAt,
+		UpdatedAt: n.UpdatedAt,
+	}
+}
+
+// GetMR retrieves merge request information, its number is the iid of the merge request in the project
+func (c *Client) GetMR(ctx context.Context, project string, iid int) (*models.PullRequest, error) {
+	var mr mergeRequest
+	if _, err := c.do(ctx, http.MethodGet, mergeRequestPath(project, iid), nil, &mr); err != nil {
+		return nil, fmt.Errorf("failed to get MR: %w", err)
+	}
+
+	headSHA := mr.DiffRefs.HeadSHA
+	if headSHA == "" {
+		headSHA = mr.SHA
+	}
+	return &models.PullRequest{
+		Number:  mr.IID,
+		Title:   mr.Title,
+		Body:    mr.Description,
+		BaseSHA: mr.DiffRefs.BaseSHA,
+		HeadSHA: headSHA,
+		BaseRef: mr.TargetBranch,
+		HeadRef: mr.SourceBranch,
+		State:   mr.State,
+		Merged:  mr.State == "merged",
+		Labels:  mr.Labels,
+		Created: mr.CreatedAt,
+		Updated: mr.UpdatedAt,
+	}, nil
+}
+
+// CreateComment creates a new note on a merge request
+func (c *Client) CreateComment(ctx context.Context, project string, iid int, body string) (*models.Comment, error) {
+	var created note
+	_, err := c.do(ctx, http.MethodPost, mergeRequestPath(project, iid)+"/notes", map[string]string{"body": body}, &created)
+	c.record(transcript.OP_CREATE_COMMENT, fmt.Sprintf("%s!%d", project, iid), body, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create note: %w", err)
+	}
+	return created.comment(), nil
+}
+
+// UpdateComment updates an existing note of a merge request
+func (c *Client) UpdateComment(ctx context.Context, project string, iid int, noteID int64, body string) error {
+	path := fmt.Sprintf("%s/notes/%d", mergeRequestPath(project, iid), noteID)
+	_, err := c.do(ctx, http.MethodPut, path, map[string]string{"body": body}, nil)
+	c.record(transcript.OP_UPDATE_COMMENT, fmt.Sprintf("%s!%d note %d", project, iid, noteID), body, err)
+	if err != nil {
+		return fmt.Errorf("failed to update note: %w", err)
+	}
+	return nil
+}
+
+// GetComments retrieves all user notes of a merge request, oldest first. System notes are left out
+func (c *Client) GetComments(ctx context.Context, project string, iid int) ([]*models.Comment, error) {
+	var allComments []*models.Comment
+	for page := "1"; page != ""; {
+		path := fmt.Sprintf("%s/notes?sort=asc&order_by=created_at&per_page=%d&page=%s", mergeRequestPath(project, iid), NOTES_PER_PAGE, page)
+		var notes []note
+		header, err := c.do(ctx, http.MethodGet, path, nil, &notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get notes: %w", err)
+		}
+		for i := range notes {
+			if !notes[i].System {
+				allComments = append(allComments, notes[i].comment())
+			}
+		}
+		page = header.Get("X-Next-Page")
+	}
+	return allComments, nil
+}
+
+// FindToolComment finds an existing tool-generated note containing the search string
+// If multiple notes with the same marker exist, returns the first one found
+func (c *Client) FindToolComment(ctx context.Context, project string, iid int, searchString string) (*models.Comment, error) {
+	comments, err := c.GetComments(ctx, project, iid)
+	if err != nil {
+		return nil, err
+	}
+	for _, comment := range comments {
+		if strings.Contains(comment.Body, searchString) {
+			return comment, nil
+		}
+	}
+	return nil, nil // Returns nil if not found
+}
+
+// CheckoutAtPath clones and checks out specific ref of a project at path with the specified options, see
+// gitclone.Cloner. returns the directory containing the checked out files
+func (c *Client) CheckoutAtPath(ctx context.Context, project, branch, path string, opts gitclone.Options) (string, error) {
+	cloner := &gitclone.Cloner{Executor: c.Executor, FS: c.FS}
+	// Access tokens authenticate git over HTTPS with any username, oauth2 by convention
+	remote := gitclone.Remote{URL: c.cloneURL(project), Username: "oauth2", Token: c.token}
+	return cloner.CheckoutAtPath(ctx, remote, branch, path, opts)
+}
+
+// cloneURL returns the HTTPS clone URL of a project, e.g. "group/subgroup/project"
+func (c *Client) cloneURL(project string) string {
+	return fmt.Sprintf("%s/%s.git", c.serverURL, strings.Trim(project, "/"))
+}
+
+// mergeRequestPath returns the API path of a merge request, the project path is URL-encoded as its id
+func mergeRequestPath(project string, iid int) string {
+	return fmt.Sprintf("/projects/%s/merge_requests/%d", url.PathEscape(strings.Trim(project, "/")), iid)
+}
+
+// GetJobURL returns the URL of the CI job of the run, from CI_JOB_URL
+func GetJobURL() (string, error) {
+	jobURL := os.Getenv("CI_JOB_URL")
+	if jobURL == "" {
+		return "", fmt.Errorf("CI_JOB_URL is not set")
+	}
+	return jobURL, nil
+}
+
+// do sends a request to the API with a JSON body, if not nil, and decodes the JSON response into out, if not nil.
+// returns the response headers, for the pagination
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(content)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.serverURL+"/api/v4"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	logger.WithField("method", method).WithField("path", path).WithField("status", resp.StatusCode).Debug("GitLab API call")
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, &APIError{StatusCode: resp.StatusCode, RetryAfter: time.Duration(retryAfter) * time.Second, Message: strings.TrimSpace(string(message))}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp.Header, nil
+}
+
+// record records a comment mutation with its outcome on the transcript of the client, if any
+func (c *Client) record(operation, target, body string, err error) {
+	c.Transcript.Record(transcript.Entry{SCM: SCM_NAME, Operation: operation, Target: target, Body: body}, err)
+}
@@ -0,0 +1,98 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/transcript"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return &Client{serverURL: srv.URL, token: "glpat-test", http: srv.Client()}
+}
+
+func TestGetMR(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/group%2Fsub%2Fproject/merge_requests/7" {
+			t.Errorf("path = %s", r.URL.EscapedPath())
+		}
+		if r.Header.Get("PRIVATE-TOKEN") != "glpat-test" {
+			t.Errorf("PRIVATE-TOKEN = %q", r.Header.Get("PRIVATE-TOKEN"))
+		}
+		_, _ = w.Write([]byte(`{"iid": 7, "title": "Bump web", "state": "opened", "source_branch": "bump-web",
+			"target_branch": "main", "sha": "h1", "labels": ["gitops"], "diff_refs": {"base_sha": "b1", "head_sha": "h1"}}`))
+	})
+
+	mr, err := c.GetMR(context.Background(), "group/sub/project", 7)
+	if err != nil {
+		t.Fatalf("GetMR() error = %v", err)
+	}
+	if mr.Number != 7 || mr.BaseRef != "main" || mr.HeadRef != "bump-web" || mr.BaseSHA != "b1" || mr.HeadSHA != "h1" {
+		t.Errorf("GetMR() = %+v", mr)
+	}
+}
+
+func TestGetComments(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("X-Next-Page", "2")
+			_, _ = w.Write([]byte(`[{"id": 1, "body": "added 1 commit", "system": true}, {"id": 2, "body": "first", "author": {"username": "alice"}}]`))
+		case "2":
+			_, _ = w.Write([]byte(`[{"id": 3, "body": "<!-- marker --> second"}]`))
+		default:
+			t.Errorf("unexpected page %q", r.URL.Query().Get("page"))
+		}
+	})
+
+	comments, err := c.GetComments(context.Background(), "group/project", 7)
+	if err != nil {
+		t.Fatalf("GetComments() error = %v", err)
+	}
+	if len(comments) != 2 || comments[0].ID != 2 || comments[0].User != "alice" || comments[1].ID != 3 {
+		t.Errorf("GetComments() = %+v, want the user notes of both pages", comments)
+	}
+	found, err := c.FindToolComment(context.Background(), "group/project", 7, "<!-- marker -->")
+	if err != nil || found == nil || found.ID != 3 {
+		t.Errorf("FindToolComment() = %+v, %v", found, err)
+	}
+}
+
+func TestUpdateComment(t *testing.T) {
+	var body map[string]string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/api/v4/projects/group/project/merge_requests/7/notes/3" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	c.Transcript = transcript.New()
+
+	if err := c.UpdateComment(context.Background(), "group/project", 7, 3, "updated"); err != nil {
+		t.Fatalf("UpdateComment() error = %v", err)
+	}
+	if body["body"] != "updated" {
+		t.Errorf("body = %v", body)
+	}
+	entries := c.Transcript.Entries()
+	if len(entries) != 1 || entries[0].Operation != transcript.OP_UPDATE_COMMENT || entries[0].Target != "group/project!7 note 3" || entries[0].Body != "updated" {
+		t.Errorf("transcript = %+v, want the note update", entries)
+	}
+}
+
+func TestIsAuthError(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+	})
+
+	_, err := c.GetMR(context.Background(), "group/project", 7)
+	if !IsAuthError(err) {
+		t.Errorf("IsAuthError(%v) = false, want true", err)
+	}
+}
@@ -0,0 +1,143 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ARGO_APPLICATION_API_VERSION = "argoproj.io/v1alpha1"
+	ARGO_APPLICATION_KIND        = "Application"
+)
+
+// fasttemplatePattern matches the default (non goTemplate) ApplicationSet parameters, e.g. {{cluster}} or {{ values.env }}
+var fasttemplatePattern = regexp.MustCompile(`{{\s*([A-Za-z0-9_.\-]+)\s*}}`)
+
+func describeApplicationSet(appset manifest.Resource) models.GitOpsResource {
+	generators := manifest.NestedSlice(appset.Object, "spec", "generators")
+	parts := make([]string, 0, len(generators))
+	for _, g := range generators {
+		gen, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for name := range gen {
+			if name == "list" {
+				parts = append(parts, fmt.Sprintf("list generator (%d elements)", len(manifest.NestedSlice(gen, "list", "elements"))))
+			} else {
+				parts = append(parts, name+" generator")
+			}
+		}
+	}
+	sort.Strings(parts)
+	return models.GitOpsResource{
+		Kind:      appset.Kind,
+		Name:      appset.Name,
+		Namespace: appset.Namespace,
+		Source:    strings.Join(parts, ", "),
+	}
+}
+
+// renderApplicationSet generates the Applications of an ApplicationSet
+// Only list generators can be rendered offline, other generators need cluster or SCM access
+func renderApplicationSet(appset manifest.Resource) ([]manifest.Resource, error) {
+	tmpl := manifest.NestedMap(appset.Object, "spec", "template")
+	if tmpl == nil {
+		return nil, fmt.Errorf("spec.template is required")
+	}
+	tmplBytes, err := yaml.Marshal(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode spec.template: %w", err)
+	}
+	goTemplate, _ := manifest.Nested(appset.Object, "spec", "goTemplate").(bool)
+
+	apps := []manifest.Resource{}
+	for i, g := range manifest.NestedSlice(appset.Object, "spec", "generators") {
+		gen, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := gen["list"]; !ok {
+			return nil, fmt.Errorf("generator %d is not supported (only list generators can be rendered)", i)
+		}
+		for j, el := range manifest.NestedSlice(gen, "list", "elements") {
+			params, ok := el.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("generator %d element %d is not an object", i, j)
+			}
+			app, err := renderApplication(string(tmplBytes), params, goTemplate, appset.Namespace)
+			if err != nil {
+				return nil, fmt.Errorf("generator %d element %d: %w", i, j, err)
+			}
+			apps = append(apps, app)
+		}
+	}
+	return apps, nil
+}
+
+func renderApplication(tmpl string, params map[string]interface{}, goTemplate bool, namespace string) (manifest.Resource, error) {
+	var rendered string
+	if goTemplate {
+		t, err := template.New("application").Option("missingkey=error").Parse(tmpl)
+		if err != nil {
+			return manifest.Resource{}, fmt.Errorf("failed to parse template: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, params); err != nil {
+			return manifest.Resource{}, fmt.Errorf("failed to execute template: %w", err)
+		}
+		rendered = buf.String()
+	} else {
+		flat := map[string]string{}
+		flattenParams("", params, flat)
+		rendered = fasttemplatePattern.ReplaceAllStringFunc(tmpl, func(match string) string {
+			key := fasttemplatePattern.FindStringSubmatch(match)[1]
+			if value, ok := flat[key]; ok {
+				return value
+			}
+			return match // unresolved parameters are kept as is, same as Argo CD
+		})
+	}
+
+	var spec map[string]interface{}
+	if err := yaml.Unmarshal([]byte(rendered), &spec); err != nil {
+		return manifest.Resource{}, fmt.Errorf("rendered template is not valid YAML: %w", err)
+	}
+	metadata := manifest.NestedMap(spec, "metadata")
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	if _, ok := metadata["namespace"]; !ok && namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	obj := map[string]interface{}{
+		"apiVersion": ARGO_APPLICATION_API_VERSION,
+		"kind":       ARGO_APPLICATION_KIND,
+		"metadata":   metadata,
+		"spec":       spec["spec"],
+	}
+	return manifest.NewResource(obj), nil
+}
+
+// flattenParams flattens nested element values into dotted keys, e.g. {"values": {"env": "prod"}} -> "values.env"
+func flattenParams(prefix string, params map[string]interface{}, out map[string]string) {
+	for key, value := range params {
+		fullKey := key
+		if prefix != "" {
+			fullKey = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenParams(fullKey, nested, out)
+			continue
+		}
+		out[fullKey] = fmt.Sprint(value)
+	}
+}
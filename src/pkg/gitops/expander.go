@@ -0,0 +1,95 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "gitops")
+
+const (
+	FLUX_HELM_GROUP   = "helm.toolkit.fluxcd.io"
+	FLUX_SOURCE_GROUP = "source.toolkit.fluxcd.io"
+	ARGO_GROUP        = "argoproj.io"
+)
+
+// Expander recognizes GitOps controller CRs (Flux HelmRelease, Argo ApplicationSet) in built manifests
+// and optionally renders them, so that policies apply to the final workload specs rather than the intermediate CRs
+type Expander struct {
+	Render     bool             // If true, rendered outputs are appended to the manifest; otherwise resources are only reported
+	ExecLimits sandbox.Limits   // Resource limits applied to `helm template`
+	Executor   sandbox.Executor // Runs helm, nil uses sandbox.DefaultExecutor
+}
+
+// NewExpander creates a new expander
+func NewExpander(render bool, execLimits sandbox.Limits) *Expander {
+	return &Expander{
+		Render:     render,
+		ExecLimits: execLimits,
+	}
+}
+
+// Expand detects GitOps resources in the manifest and, if rendering is enabled, appends their rendered outputs
+// Rendering failures are reported per resource and never fail the build
+// returns: the (possibly) expanded manifest, the GitOps resources found
+func (x *Expander) Expand(ctx context.Context, mf []byte) ([]byte, []models.GitOpsResource, error) {
+	if len(mf) == 0 {
+		return mf, nil, nil
+	}
+	resources, err := manifest.Parse(mf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse built manifest: %w", err)
+	}
+
+	found := []models.GitOpsResource{}
+	rendered := []manifest.Resource{}
+	for _, res := range resources {
+		var info models.GitOpsResource
+		var outputs []manifest.Resource
+		var renderErr error
+
+		switch {
+		case res.Kind == models.GitOpsKindHelmRelease && res.Group() == FLUX_HELM_GROUP:
+			info = describeHelmRelease(res, resources)
+			if x.Render {
+				outputs, renderErr = x.renderHelmRelease(ctx, res, resources)
+			}
+		case res.Kind == models.GitOpsKindApplicationSet && res.Group() == ARGO_GROUP:
+			info = describeApplicationSet(res)
+			if x.Render {
+				outputs, renderErr = renderApplicationSet(res)
+			}
+		default:
+			continue
+		}
+
+		if renderErr != nil {
+			logger.WithField("resource", res.ID()).WithField("error", renderErr).Warn("Failed to render GitOps resource")
+			info.RenderError = renderErr.Error()
+		} else if x.Render {
+			info.Rendered = true
+			info.RenderedResourceCount = len(outputs)
+			rendered = append(rendered, outputs...)
+		}
+		found = append(found, info)
+	}
+
+	if len(rendered) == 0 {
+		return mf, found, nil
+	}
+
+	renderedBytes, err := manifest.Marshal(rendered)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode rendered resources: %w", err)
+	}
+	expanded := bytes.TrimRight(mf, "\n")
+	expanded = append(expanded, []byte("\n---\n")...)
+	expanded = append(expanded, renderedBytes...)
+	return expanded, found, nil
+}
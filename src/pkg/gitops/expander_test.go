@@ -0,0 +1,107 @@
+package gitops
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+)
+
+const applicationSetManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: unrelated
+---
+apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: guestbook
+  namespace: argocd
+spec:
+  generators:
+    - list:
+        elements:
+          - cluster: alpha
+            url: https://alpha.example.com
+          - cluster: beta
+            url: https://beta.example.com
+  template:
+    metadata:
+      name: '{{cluster}}-guestbook'
+    spec:
+      destination:
+        server: '{{ url }}'
+        namespace: guestbook
+`
+
+func TestExpander_Expand_ApplicationSet(t *testing.T) {
+	tests := []struct {
+		name          string
+		render        bool
+		wantResources int
+		wantApps      []string
+	}{
+		{name: "detect only", render: false, wantResources: 2},
+		{name: "render list generator", render: true, wantResources: 4, wantApps: []string{"alpha-guestbook", "beta-guestbook"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := NewExpander(tt.render, sandbox.DefaultLimits())
+			expanded, found, err := x.Expand(context.Background(), []byte(applicationSetManifest))
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if len(found) != 1 || found[0].Name != "guestbook" || found[0].Source != "list generator (2 elements)" {
+				t.Fatalf("Expand() found = %+v", found)
+			}
+			if found[0].Rendered != tt.render {
+				t.Errorf("Expand() rendered = %v, want %v", found[0].Rendered, tt.render)
+			}
+
+			resources, err := manifest.Parse(expanded)
+			if err != nil {
+				t.Fatalf("expanded manifest is not parseable: %v", err)
+			}
+			if len(resources) != tt.wantResources {
+				t.Fatalf("expanded manifest has %d resources, want %d", len(resources), tt.wantResources)
+			}
+			for i, name := range tt.wantApps {
+				app := resources[2+i]
+				if app.Kind != "Application" || app.Name != name || app.Namespace != "argocd" {
+					t.Errorf("rendered app %d = %s, want Application/argocd/%s", i, app.ID(), name)
+				}
+				if server := manifest.NestedString(app.Object, "spec", "destination", "server"); server == "" || server[0] == '{' {
+					t.Errorf("rendered app %d has unresolved server %q", i, server)
+				}
+			}
+		})
+	}
+}
+
+func TestExpander_Expand_UnsupportedGenerator(t *testing.T) {
+	mf := `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: from-git
+spec:
+  generators:
+    - git:
+        repoURL: https://example.com/repo.git
+  template:
+    metadata:
+      name: app
+`
+	x := NewExpander(true, sandbox.DefaultLimits())
+	expanded, found, err := x.Expand(context.Background(), []byte(mf))
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+	if len(found) != 1 || found[0].Rendered || found[0].RenderError == "" {
+		t.Errorf("Expand() found = %+v, want a render error", found)
+	}
+	if string(expanded) != mf {
+		t.Errorf("Expand() should leave the manifest untouched when rendering fails")
+	}
+}
@@ -0,0 +1,153 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+// helmChartRef is the chart a HelmRelease points to, resolved through its HelmRepository source
+type helmChartRef struct {
+	RepoURL string // https://... or oci://...
+	Chart   string
+	Version string
+}
+
+func (c helmChartRef) String() string {
+	s := strings.TrimSpace(c.RepoURL + " " + c.Chart)
+	if c.Version != "" {
+		s += "@" + c.Version
+	}
+	return s
+}
+
+func describeHelmRelease(hr manifest.Resource, all []manifest.Resource) models.GitOpsResource {
+	info := models.GitOpsResource{
+		Kind:      hr.Kind,
+		Name:      hr.Name,
+		Namespace: hr.Namespace,
+	}
+	ref, err := resolveHelmChart(hr, all)
+	if err != nil {
+		info.Source = manifest.NestedString(hr.Object, "spec", "chart", "spec", "chart")
+		return info
+	}
+	info.Source = ref.String()
+	return info
+}
+
+// resolveHelmChart finds the chart and the HelmRepository url of the release in the same manifest
+func resolveHelmChart(hr manifest.Resource, all []manifest.Resource) (*helmChartRef, error) {
+	chartSpec := manifest.NestedMap(hr.Object, "spec", "chart", "spec")
+	if chartSpec == nil {
+		return nil, fmt.Errorf("spec.chart.spec is required (spec.chartRef is not supported)")
+	}
+	chart := manifest.NestedString(chartSpec, "chart")
+	if chart == "" {
+		return nil, fmt.Errorf("spec.chart.spec.chart is empty")
+	}
+
+	sourceKind := manifest.NestedString(chartSpec, "sourceRef", "kind")
+	sourceName := manifest.NestedString(chartSpec, "sourceRef", "name")
+	sourceNamespace := manifest.NestedString(chartSpec, "sourceRef", "namespace")
+	if sourceNamespace == "" {
+		sourceNamespace = hr.Namespace
+	}
+	if sourceKind != "HelmRepository" {
+		return nil, fmt.Errorf("chart source kind %q is not supported (only HelmRepository)", sourceKind)
+	}
+
+	for _, res := range all {
+		if res.Kind != "HelmRepository" || res.Group() != FLUX_SOURCE_GROUP || res.Name != sourceName {
+			continue
+		}
+		if res.Namespace != "" && res.Namespace != sourceNamespace {
+			continue
+		}
+		url := manifest.NestedString(res.Object, "spec", "url")
+		if url == "" {
+			return nil, fmt.Errorf("HelmRepository %s has no spec.url", res.ID())
+		}
+		return &helmChartRef{
+			RepoURL: url,
+			Chart:   chart,
+			Version: manifest.NestedString(chartSpec, "version"),
+		}, nil
+	}
+	return nil, fmt.Errorf("HelmRepository %s/%s not found in the built manifest", sourceNamespace, sourceName)
+}
+
+// renderHelmRelease runs `helm template` for the release with its inline values
+// valuesFrom (ConfigMaps/Secrets) is not resolved
+func (x *Expander) renderHelmRelease(ctx context.Context, hr manifest.Resource, all []manifest.Resource) ([]manifest.Resource, error) {
+	ref, err := resolveHelmChart(hr, all)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifest.NestedSlice(hr.Object, "spec", "valuesFrom")) > 0 {
+		logger.WithField("resource", hr.ID()).Warn("spec.valuesFrom is not supported, rendering with inline values only")
+	}
+
+	// Same defaults as the Flux helm-controller
+	targetNamespace := manifest.NestedString(hr.Object, "spec", "targetNamespace")
+	releaseNamespace := hr.Namespace
+	releaseName := hr.Name
+	if targetNamespace != "" {
+		releaseNamespace = targetNamespace
+		releaseName = targetNamespace + "-" + hr.Name
+	}
+	if name := manifest.NestedString(hr.Object, "spec", "releaseName"); name != "" {
+		releaseName = name
+	}
+
+	valuesFile, err := os.CreateTemp("", "helmrelease-values-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create values file: %w", err)
+	}
+	defer func() {
+		_ = valuesFile.Close()
+		_ = os.Remove(valuesFile.Name())
+	}()
+	values := manifest.NestedMap(hr.Object, "spec", "values")
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+	if err := yaml.NewEncoder(valuesFile).Encode(values); err != nil {
+		return nil, fmt.Errorf("failed to write values file: %w", err)
+	}
+
+	args := []string{"template", releaseName}
+	if strings.HasPrefix(ref.RepoURL, "oci://") {
+		args = append(args, strings.TrimSuffix(ref.RepoURL, "/")+"/"+ref.Chart)
+	} else {
+		args = append(args, ref.Chart, "--repo", ref.RepoURL)
+	}
+	if ref.Version != "" {
+		args = append(args, "--version", ref.Version)
+	}
+	if releaseNamespace != "" {
+		args = append(args, "--namespace", releaseNamespace)
+	}
+	args = append(args, "--values", valuesFile.Name())
+
+	logger.WithField("resource", hr.ID()).WithField("chart", ref.String()).Info("Rendering HelmRelease...")
+	res, err := sandbox.OrDefault(x.Executor).Run(ctx, sandbox.Command{Name: "helm", Args: args, Limits: x.ExecLimits})
+	if err != nil {
+		return nil, fmt.Errorf("helm template failed: %w", err)
+	}
+	if res.ExitCode != 0 {
+		return nil, fmt.Errorf("helm template failed: exit status %d: %s", res.ExitCode, strings.TrimSpace(string(res.Stderr)))
+	}
+
+	rendered, err := manifest.Parse(res.Stdout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse helm template output: %w", err)
+	}
+	return rendered, nil
+}
@@ -0,0 +1,162 @@
+// Package httpclient configures the network access of all outbound calls: the HTTP clients of the tool
+// (GitHub API, external data, decision logs) and the processes it runs (git, kustomize, helm, conftest)
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "httpclient")
+
+const (
+	ENV_CA_BUNDLE    = "GITOPS_KUSTOMZCHK_CA_BUNDLE"
+	CA_FILE_NAME_FMT = "gitops-kustomzchk-ca-%s.pem"
+)
+
+// PROXY_SCHEMES are the supported schemes of a proxy URL
+var PROXY_SCHEMES = []string{"http", "https", "socks5"}
+
+// SYSTEM_CA_FILES are the usual locations of the system CA bundle, the first one found is merged with the
+// custom bundle for the child processes
+var SYSTEM_CA_FILES = []string{
+	"/etc/ssl/certs/ca-certificates.crt", // Debian, Ubuntu, Alpine
+	"/etc/pki/tls/certs/ca-bundle.crt",   // Fedora, RHEL
+	"/etc/ssl/ca-bundle.pem",             // OpenSUSE
+	"/etc/ssl/cert.pem",                  // macOS, Alpine
+}
+
+// Config is the network configuration, empty fields keep the defaults of the environment
+type Config struct {
+	HTTPSProxy string // Proxy URL of all requests, sets HTTPS_PROXY and HTTP_PROXY
+	NoProxy    string // Comma-separated hosts, domains and CIDRs not proxied, sets NO_PROXY
+	CABundle   string // PEM file of CAs trusted on top of the system ones
+
+	// Offline forbids all egress but to the AllowedHosts (and their subdomains), see offline.go
+	Offline      bool
+	AllowedHosts []string
+}
+
+// transport is shared by all clients so that they reuse connections, Configure replaces it
+var transport http.RoundTripper = http.DefaultTransport
+
+// New returns an HTTP client using the configured proxy and CAs, a zero timeout means no timeout
+func New(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: transport, Timeout: timeout}
+}
+
+// Configure applies a network configuration, it must be called before any outbound call.
+// The proxy is exported as HTTPS_PROXY/HTTP_PROXY/NO_PROXY and the CAs as SSL_CERT_FILE/GIT_SSL_CAINFO,
+// which the tool's clients and all child processes honor
+func Configure(cfg Config) error {
+	if cfg.CABundle == "" {
+		cfg.CABundle = os.Getenv(ENV_CA_BUNDLE)
+	}
+	if cfg.Offline && cfg.HTTPSProxy != "" {
+		return fmt.Errorf("--offline does not support a proxy, the allowed hosts must be reachable directly")
+	}
+	if cfg.HTTPSProxy != "" {
+		if err := validateProxy(cfg.HTTPSProxy); err != nil {
+			return err
+		}
+		for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY", "https_proxy", "http_proxy"} {
+			if err := os.Setenv(key, cfg.HTTPSProxy); err != nil {
+				return fmt.Errorf("failed to set %s: %w", key, err)
+			}
+		}
+	}
+	if cfg.NoProxy != "" {
+		for _, key := range []string{"NO_PROXY", "no_proxy"} {
+			if err := os.Setenv(key, cfg.NoProxy); err != nil {
+				return fmt.Errorf("failed to set %s: %w", key, err)
+			}
+		}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := certPoolOf(pem)
+		if err != nil {
+			return fmt.Errorf("invalid CA bundle %s: %w", cfg.CABundle, err)
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+		caFile, err := writeCombinedBundle(pem)
+		if err != nil {
+			return err
+		}
+		for _, key := range []string{"SSL_CERT_FILE", "GIT_SSL_CAINFO"} {
+			if err := os.Setenv(key, caFile); err != nil {
+				return fmt.Errorf("failed to set %s: %w", key, err)
+			}
+		}
+		logger.WithField("caBundle", cfg.CABundle).WithField("caFile", caFile).Debug("Trusting custom CAs")
+	}
+	if cfg.Offline {
+		return blockEgress(t, cfg.AllowedHosts)
+	}
+	transport = t
+	return nil
+}
+
+// validateProxy checks that a proxy is an absolute URL of a supported scheme
+func validateProxy(proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	if !slices.Contains(PROXY_SCHEMES, u.Scheme) || u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q, must be <scheme>://<host>[:<port>] with a scheme among %v", proxy, PROXY_SCHEMES)
+	}
+	return nil
+}
+
+// certPoolOf returns the system CAs plus the certificates of a PEM bundle
+func certPoolOf(pem []byte) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to load the system CAs, trusting only the CA bundle")
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return pool, nil
+}
+
+// writeCombinedBundle writes the system CA bundle followed by the custom one to a temp file: SSL_CERT_FILE
+// replaces the system CAs of the child processes rather than adding to them. The file is named after its
+// content so that runs share it
+func writeCombinedBundle(pem []byte) (string, error) {
+	var combined []byte
+	for _, file := range SYSTEM_CA_FILES {
+		if system, err := os.ReadFile(file); err == nil {
+			combined = append(system, '\n')
+			break
+		}
+	}
+	combined = append(combined, pem...)
+
+	sum := sha256.Sum256(combined)
+	path := filepath.Join(os.TempDir(), fmt.Sprintf(CA_FILE_NAME_FMT, hex.EncodeToString(sum[:])[:12]))
+	if err := os.WriteFile(path, combined, 0644); err != nil {
+		return "", fmt.Errorf("failed to write CA bundle: %w", err)
+	}
+	return path, nil
+}
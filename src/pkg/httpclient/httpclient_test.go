@@ -0,0 +1,167 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isolateEnv restores the variables Configure sets once the test ends
+func isolateEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"HTTPS_PROXY", "HTTP_PROXY", "https_proxy", "http_proxy", "NO_PROXY", "no_proxy",
+		"ALL_PROXY", "all_proxy", "SSL_CERT_FILE", "GIT_SSL_CAINFO", ENV_CA_BUNDLE} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	previous := transport
+	t.Cleanup(func() { transport = previous })
+}
+
+func TestConfigure_CABundle(t *testing.T) {
+	isolateEnv(t)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, certPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(5 * time.Second).Get(server.URL); err == nil {
+		t.Fatal("expected the server certificate to be untrusted before configuring the CA bundle")
+	}
+
+	t.Setenv(ENV_CA_BUNDLE, bundle)
+	if err := Configure(Config{}); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	resp, err := New(5 * time.Second).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the server certificate to be trusted, got: %v", err)
+	}
+	resp.Body.Close()
+
+	caFile := os.Getenv("SSL_CERT_FILE")
+	if caFile == "" || os.Getenv("GIT_SSL_CAINFO") != caFile {
+		t.Fatalf("expected SSL_CERT_FILE and GIT_SSL_CAINFO to be set to the same file, got %q and %q",
+			caFile, os.Getenv("GIT_SSL_CAINFO"))
+	}
+	combined, err := os.ReadFile(caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(combined), string(certPEM)) {
+		t.Errorf("expected the combined bundle to end with the custom CAs")
+	}
+}
+
+func TestConfigure_Proxy(t *testing.T) {
+	isolateEnv(t)
+	if err := Configure(Config{HTTPSProxy: "http://proxy.corp:3128", NoProxy: "localhost,.internal"}); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+	for key, want := range map[string]string{
+		"HTTPS_PROXY": "http://proxy.corp:3128",
+		"http_proxy":  "http://proxy.corp:3128",
+		"NO_PROXY":    "localhost,.internal",
+		"no_proxy":    "localhost,.internal",
+	} {
+		if got := os.Getenv(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestConfigure_Invalid(t *testing.T) {
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{"proxy without scheme", Config{HTTPSProxy: "proxy.corp:3128"}, "invalid proxy URL"},
+		{"proxy of unsupported scheme", Config{HTTPSProxy: "ftp://proxy.corp"}, "invalid proxy URL"},
+		{"missing CA bundle", Config{CABundle: filepath.Join(t.TempDir(), "missing.pem")}, "failed to read CA bundle"},
+		{"CA bundle without certificates", Config{CABundle: notPEM}, "no PEM certificate found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateEnv(t)
+			err := Configure(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Configure() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigure_Offline(t *testing.T) {
+	isolateEnv(t)
+	if err := Configure(Config{Offline: true, AllowedHosts: []string{"github.com"}}); err != nil {
+		t.Fatalf("Configure() error = %v", err)
+	}
+
+	_, err := New(5 * time.Second).Get("https://example.com/data.json")
+	if !errors.Is(err, ErrEgressBlocked) {
+		t.Errorf("expected the request to an unallowed host to be blocked, got: %v", err)
+	}
+
+	// child processes reach the blocking proxy, which refuses at once
+	proxyURL, err := url.Parse(os.Getenv("HTTPS_PROXY"))
+	if err != nil || proxyURL.Hostname() != "127.0.0.1" {
+		t.Fatalf("expected HTTPS_PROXY to be the local blocking proxy, got %q", os.Getenv("HTTPS_PROXY"))
+	}
+	child := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+	resp, err := child.Get("http://example.com/chart.tgz")
+	if err != nil {
+		t.Fatalf("expected the blocking proxy to answer, got: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected the blocking proxy to answer 403, got %d", resp.StatusCode)
+	}
+	if !strings.Contains(os.Getenv("NO_PROXY"), "github.com") {
+		t.Errorf("expected the allowed hosts in NO_PROXY, got %q", os.Getenv("NO_PROXY"))
+	}
+	if blocked := BlockedHosts(); len(blocked) < 2 || blocked[len(blocked)-1] != "example.com" {
+		t.Errorf("expected the blocked hosts to be recorded, got %v", blocked)
+	}
+
+	if err := Configure(Config{Offline: true, HTTPSProxy: "http://proxy.corp:3128"}); err == nil {
+		t.Errorf("expected --offline with a proxy to be rejected")
+	}
+}
+
+func TestIsAllowed(t *testing.T) {
+	allowed := []string{"github.com", ".githubusercontent.com"}
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"github.com", true},
+		{"api.github.com", true},
+		{"GitHub.com.", true},
+		{"objects.githubusercontent.com", true},
+		{"notgithub.com", false},
+		{"github.com.evil.io", false},
+		{"registry.npmjs.org", false},
+	}
+	for _, tt := range tests {
+		if got := isAllowed(tt.host, allowed); got != tt.want {
+			t.Errorf("isAllowed(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
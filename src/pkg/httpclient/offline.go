@@ -0,0 +1,111 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// LOOPBACK_HOSTS are always allowed offline, they are not egress
+var LOOPBACK_HOSTS = []string{"localhost", "127.0.0.1", "::1"}
+
+// ErrEgressBlocked is returned by the clients of an offline run for a host that is not allowed
+var ErrEgressBlocked = errors.New("egress blocked by --offline")
+
+var (
+	blockedMu    sync.Mutex
+	blockedHosts []string
+)
+
+// BlockedHosts returns the hosts an offline run refused to reach so far, in the order of the attempts
+func BlockedHosts() []string {
+	blockedMu.Lock()
+	defer blockedMu.Unlock()
+	return append([]string(nil), blockedHosts...)
+}
+
+// blockEgress restricts the tool's clients to the allowed hosts, and points the child processes at a local
+// proxy refusing everything: the allowed hosts are reached directly through NO_PROXY, any other request
+// fails at once instead of timing out on a firewall
+func blockEgress(t *http.Transport, allowedHosts []string) error {
+	allowed := append(append([]string(nil), LOOPBACK_HOSTS...), allowedHosts...)
+
+	proxyURL, err := startBlockingProxy()
+	if err != nil {
+		return err
+	}
+	env := map[string]string{
+		"HTTPS_PROXY": proxyURL, "HTTP_PROXY": proxyURL, "https_proxy": proxyURL, "http_proxy": proxyURL,
+		"ALL_PROXY": proxyURL, "all_proxy": proxyURL,
+		"NO_PROXY": strings.Join(allowed, ","), "no_proxy": strings.Join(allowed, ","),
+	}
+	for key, value := range env {
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+	}
+
+	t.Proxy = nil
+	transport = &offlineTransport{allowed: allowed, next: t}
+	logger.WithField("allowedHosts", allowedHosts).Info("Offline: egress is blocked but to the allowed hosts")
+	return nil
+}
+
+// offlineTransport fails the requests to the hosts that are not allowed
+type offlineTransport struct {
+	allowed []string
+	next    http.RoundTripper
+}
+
+func (o *offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if !isAllowed(host, o.allowed) {
+		recordBlocked(host)
+		return nil, fmt.Errorf("%w: %s", ErrEgressBlocked, host)
+	}
+	return o.next.RoundTrip(req)
+}
+
+// isAllowed returns true if a host is one of the allowed hosts or a subdomain of one, like NO_PROXY matches
+func isAllowed(host string, allowed []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimPrefix(a, "."))
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return true
+		}
+	}
+	return false
+}
+
+// startBlockingProxy serves a proxy on a loopback port, answering every request (and CONNECT tunnel) with
+// 403 so that child processes honoring HTTPS_PROXY fail with the reason. It lives as long as the process
+func startBlockingProxy() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to start the offline proxy: %w", err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.URL.Hostname()
+		if r.Method == http.MethodConnect {
+			host, _, _ = net.SplitHostPort(r.Host)
+		}
+		recordBlocked(host)
+		logger.WithField("host", host).Warn("Offline: blocked egress of an external process")
+		http.Error(w, fmt.Sprintf("gitops-kustomzchk: %s: %s", ErrEgressBlocked, host), http.StatusForbidden)
+	})
+	go func() {
+		_ = http.Serve(listener, handler)
+	}()
+	return "http://" + listener.Addr().String(), nil
+}
+
+func recordBlocked(host string) {
+	blockedMu.Lock()
+	defer blockedMu.Unlock()
+	blockedHosts = append(blockedHosts, host)
+}
@@ -0,0 +1,107 @@
+// Package inventory exports the declared state of the after manifests: one normalized record per resource,
+// for downstream asset-tracking systems to ingest straight from the checks
+package inventory
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_CSV  = "csv"
+)
+
+// FORMATS are the supported export formats, each written to inventory.<format>
+var FORMATS = []string{FORMAT_JSON, FORMAT_CSV}
+
+// DEFAULT_LABELS are the key labels exported by default, the recommended Kubernetes labels
+var DEFAULT_LABELS = []string{
+	"app.kubernetes.io/name",
+	"app.kubernetes.io/instance",
+	"app.kubernetes.io/version",
+	"app.kubernetes.io/component",
+	"app.kubernetes.io/part-of",
+}
+
+// Of returns the inventory of a built manifest, in manifest order, keeping only the given label keys
+func Of(overlayKey string, mf []byte, labels []string) ([]models.InventoryItem, error) {
+	resources, err := manifest.Parse(mf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest of %s: %w", overlayKey, err)
+	}
+	items := make([]models.InventoryItem, 0, len(resources))
+	for _, res := range resources {
+		item := models.InventoryItem{
+			OverlayKey: overlayKey,
+			APIVersion: res.APIVersion,
+			Kind:       res.Kind,
+			Name:       res.Name,
+			Namespace:  res.Namespace,
+		}
+		for _, image := range manifest.ContainerImages(res.Object) {
+			if !slices.Contains(item.Images, image.Image) {
+				item.Images = append(item.Images, image.Image)
+			}
+		}
+		if replicas, ok := manifest.Nested(res.Object, "spec", "replicas").(int); ok {
+			item.Replicas = &replicas
+		}
+		for _, key := range labels {
+			value, ok := manifest.Nested(res.Object, "metadata", "labels", key).(string)
+			if !ok {
+				continue
+			}
+			if item.Labels == nil {
+				item.Labels = make(map[string]string)
+			}
+			item.Labels[key] = value
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Encode serializes an inventory in one of FORMATS. The CSV has a header row, one column per label key,
+// and the images joined by spaces
+func Encode(items []models.InventoryItem, format string, labels []string) ([]byte, error) {
+	switch format {
+	case FORMAT_JSON:
+		return json.MarshalIndent(items, "", "  ")
+	case FORMAT_CSV:
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		header := []string{"overlayKey", "apiVersion", "kind", "namespace", "name", "images", "replicas"}
+		if err := w.Write(append(header, labels...)); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			replicas := ""
+			if item.Replicas != nil {
+				replicas = strconv.Itoa(*item.Replicas)
+			}
+			row := []string{
+				item.OverlayKey, item.APIVersion, item.Kind, item.Namespace, item.Name,
+				strings.Join(item.Images, " "), replicas,
+			}
+			for _, key := range labels {
+				row = append(row, item.Labels[key])
+			}
+			if err := w.Write(row); err != nil {
+				return nil, err
+			}
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	default:
+		return nil, fmt.Errorf("unsupported inventory format %q (must be one of %v)", format, FORMATS)
+	}
+}
@@ -0,0 +1,74 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const inventoryManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+  labels:
+    app.kubernetes.io/name: web
+    team: payments
+spec:
+  replicas: 3
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: ghcr.io/acme/web:v2
+      containers:
+        - name: web
+          image: ghcr.io/acme/web:v2
+        - name: proxy
+          image: envoyproxy/envoy:v1.30
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`
+
+func TestOf(t *testing.T) {
+	replicas := 3
+	want := []models.InventoryItem{
+		{
+			OverlayKey: "prod", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Namespace: "app",
+			Images: []string{"ghcr.io/acme/web:v2", "envoyproxy/envoy:v1.30"}, Replicas: &replicas,
+			Labels: map[string]string{"app.kubernetes.io/name": "web"},
+		},
+		{OverlayKey: "prod", APIVersion: "v1", Kind: "Namespace", Name: "app"},
+	}
+	got, err := Of("prod", []byte(inventoryManifest), DEFAULT_LABELS)
+	if err != nil {
+		t.Fatalf("Of() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Of() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEncode_CSV(t *testing.T) {
+	replicas := 2
+	items := []models.InventoryItem{{
+		OverlayKey: "stg", APIVersion: "apps/v1", Kind: "Deployment", Name: "web", Namespace: "app",
+		Images: []string{"a:1", "b:2"}, Replicas: &replicas, Labels: map[string]string{"team": "payments, core"},
+	}}
+	got, err := Encode(items, FORMAT_CSV, []string{"team"})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := "overlayKey,apiVersion,kind,namespace,name,images,replicas,team\n" +
+		"stg,apps/v1,Deployment,app,web,a:1 b:2,2,\"payments, core\"\n"
+	if string(got) != want {
+		t.Errorf("Encode() = %q, want %q", got, want)
+	}
+	if _, err := Encode(items, "xml", nil); err == nil {
+		t.Error("Encode() with an unsupported format should fail")
+	}
+}
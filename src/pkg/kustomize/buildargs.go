@@ -0,0 +1,46 @@
+package kustomize
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// BUILD_ARG_NAMESPACE is applied with the kustomize namespace transformer instead of a placeholder substitution
+	BUILD_ARG_NAMESPACE = "NAMESPACE"
+)
+
+var buildArgNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// BuildArgs are runtime overlay parameters (e.g. NAMESPACE=pr-123 for PR-preview environments)
+// - NAMESPACE sets the namespace of all resources before the build, like `namespace:` in a kustomization
+// - every arg replaces its `${NAME}` placeholders after the build, like Flux postBuild.substitute, so kustomize
+// computes name hashes, replacements and patches from the placeholders, not the values
+type BuildArgs map[string]string
+
+// Validate checks that every arg name can be used as a placeholder
+func (a BuildArgs) Validate() error {
+	for name := range a {
+		if !buildArgNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid build arg name %q: must match %s", name, buildArgNamePattern.String())
+		}
+	}
+	return nil
+}
+
+// substitute replaces the `${NAME}` placeholders of the given args, unknown placeholders are kept as is
+func (a BuildArgs) substitute(manifest []byte) []byte {
+	names := make([]string, 0, len(a))
+	for name := range a {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, 2*len(names))
+	for _, name := range names {
+		pairs = append(pairs, "${"+name+"}", a[name])
+	}
+	return []byte(strings.NewReplacer(pairs...).Replace(string(manifest)))
+}
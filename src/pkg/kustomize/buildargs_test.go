@@ -0,0 +1,51 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildArgs_Substitute(t *testing.T) {
+	args := BuildArgs{"NAMESPACE": "pr-123", "IMAGE_TAG": "abc"}
+	manifest := "namespace: ${NAMESPACE}\nimage: app:${IMAGE_TAG}\nother: ${UNKNOWN} $NAMESPACE\n"
+	want := "namespace: pr-123\nimage: app:abc\nother: ${UNKNOWN} $NAMESPACE\n"
+	if got := string(args.substitute([]byte(manifest))); got != want {
+		t.Errorf("substitute() = %q, want %q", got, want)
+	}
+}
+
+func TestBuildArgs_Validate(t *testing.T) {
+	if err := (BuildArgs{"PR_NUMBER": "1"}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if err := (BuildArgs{"PR-NUMBER": "1"}).Validate(); err == nil {
+		t.Errorf("Validate() expected an error for an invalid name")
+	}
+}
+
+func TestWrapOverlay(t *testing.T) {
+	overlay := t.TempDir()
+	wrapperDir, cleanup, err := wrapOverlay(overlay, wrapperOptions{Namespace: "pr-123", BuildMetadata: []string{"originAnnotations"}})
+	if err != nil {
+		t.Fatalf("wrapOverlay() error = %v", err)
+	}
+	defer cleanup()
+
+	content, err := os.ReadFile(filepath.Join(wrapperDir, "kustomization.yaml"))
+	if err != nil {
+		t.Fatalf("failed to read wrapper kustomization: %v", err)
+	}
+	rel, _ := filepath.Rel(wrapperDir, overlay)
+	for _, want := range []string{"namespace: pr-123", "- " + filepath.ToSlash(rel), "- originAnnotations"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("wrapper kustomization %q does not contain %q", string(content), want)
+		}
+	}
+
+	cleanup()
+	if _, err := os.Stat(wrapperDir); !os.IsNotExist(err) {
+		t.Errorf("cleanup() should remove the wrapper directory")
+	}
+}
@@ -1,14 +1,17 @@
 package kustomize
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
 	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
 var logger = log.WithField("package", "kustomize")
@@ -16,6 +19,8 @@ var logger = log.WithField("package", "kustomize")
 var (
 	// ErrOverlayNotFound indicates that the requested overlay/environment doesn't exist
 	ErrOverlayNotFound = errors.New("overlay not found")
+	// ErrOutputTooLarge indicates that a built manifest exceeds Builder.MaxManifestBytes or Builder.MaxResources
+	ErrOutputTooLarge = errors.New("output too large")
 )
 
 const (
@@ -27,6 +32,25 @@ var (
 	KUSTOMIZE_FILE_NAMES = []string{"kustomization.yaml", "kustomization.yml"}
 )
 
+const (
+	KUSTOMIZE_ENGINE_KRUSTY = "krusty" // the kustomize API in-process (sigs.k8s.io/kustomize/api/krusty)
+	KUSTOMIZE_ENGINE_BINARY = "binary" // a kustomize process per build, within the exec limits
+)
+
+// KUSTOMIZE_ENGINES are the supported engines of the builds
+var KUSTOMIZE_ENGINES = []string{KUSTOMIZE_ENGINE_KRUSTY, KUSTOMIZE_ENGINE_BINARY}
+
+const (
+	LOAD_RESTRICTOR_ROOT_ONLY = "LoadRestrictionsRootOnly" // files are loaded from under the kustomization roots only
+	LOAD_RESTRICTOR_NONE      = "LoadRestrictionsNone"     // files are loaded from anywhere, e.g. ../../shared/config.env
+)
+
+// LOAD_RESTRICTORS are the kustomize --load-restrictor values
+var LOAD_RESTRICTORS = []string{LOAD_RESTRICTOR_ROOT_ONLY, LOAD_RESTRICTOR_NONE}
+
+// HELM_COMMAND is the helm binary of the chart inflation, unless HelmOptions.Command
+const HELM_COMMAND = "helm"
+
 // Expected structure for Kustomize building:
 // - <manifestRoot>/
 // |-- <service>/
@@ -53,7 +77,122 @@ type KustomizeBuilder interface {
 
 // Builder handles kustomize builds
 type Builder struct {
-	FailOnOverlayNotFound bool // If true, fail when overlay doesn't exist; if false, skip gracefully
+	FailOnOverlayNotFound bool             // If true, fail when overlay doesn't exist; if false, skip gracefully
+	ExecLimits            sandbox.Limits   // Resource limits applied to each kustomize process (and its exec plugins)
+	Executor              sandbox.Executor // Runs kustomize, nil uses sandbox.DefaultExecutor
+	BuildArgs             BuildArgs        // Runtime overlay parameters injected on every build, e.g. NAMESPACE=pr-123
+
+	// Engine is the kustomize of the builds (KUSTOMIZE_ENGINES), empty for krusty. krusty builds are only bounded
+	// by the timeout of ExecLimits, the binary ones by all the limits
+	Engine string
+
+	// LoadRestrictor is the kustomize load restrictor (LOAD_RESTRICTORS), empty for LoadRestrictionsRootOnly
+	LoadRestrictor string
+
+	// FS holds the overlays, nil for the disk. krusty builds other filesystems from an in-memory copy, the binary
+	// and helm from a copy on disk (fsys.OnDisk), for each build
+	FS fsys.FS
+
+	// ComponentProvenance annotates resources with the components that created or patched them (COMPONENTS_ANNOTATION)
+	// Built manifests are re-encoded in this mode, so it must be the same for both sides of a diff
+	ComponentProvenance bool
+
+	// Provenance annotates resources with their source file (SOURCE_ANNOTATION, PATCHED_BY_ANNOTATION)
+	// Callers must remove them with ExtractProvenance, built manifests are re-encoded in this mode as well
+	Provenance bool
+
+	// SOPS is the handling of the SOPS-encrypted resources (SOPS_MODES), empty leaves them as built
+	// Built manifests are re-encoded in both modes
+	SOPS string
+
+	// Plugins enables the generator and transformer plugins (e.g. KSOPS), disabled by default
+	Plugins PluginOptions
+
+	// Helm enables the inflation of the helmCharts of the kustomizations, disabled by default
+	Helm HelmOptions
+
+	// MaxManifestBytes and MaxResources bound the size and resource count of each built manifest, 0 disables them.
+	// kustomize is killed as soon as its output goes above MaxManifestBytes
+	MaxManifestBytes int64
+	MaxResources     int
+}
+
+// PluginOptions are the kustomize plugin flags of the builds. Exec plugins run as child processes of kustomize:
+// of the binary, in its process group and within ExecLimits, or of this process with krusty; containerized KRM
+// functions run in docker
+type PluginOptions struct {
+	Enabled       bool     // --enable-alpha-plugins: legacy exec plugins and KRM functions
+	Exec          bool     // --enable-exec: exec KRM functions (e.g. KSOPS), requires Enabled
+	Network       bool     // --network: network access of the containerized KRM functions
+	Mounts        []string // --mount: storage mounts of the containerized KRM functions, e.g. "type=bind,src=/keys,dst=/keys"
+	Env           []string // --env: environment variables passed to the KRM functions, KEY=value or KEY to forward it
+	AsCurrentUser bool     // --as-current-user: run the containerized KRM functions as the current user
+}
+
+// Validate checks that the function permissions are only set with plugins enabled
+func (p PluginOptions) Validate() error {
+	if p.Enabled {
+		return nil
+	}
+	if p.Exec || p.Network || len(p.Mounts) > 0 || len(p.Env) > 0 || p.AsCurrentUser {
+		return fmt.Errorf("the exec, network, mounts, env and as-current-user settings require the plugins to be enabled")
+	}
+	return nil
+}
+
+// args returns the kustomize build flags of the options
+func (p PluginOptions) args() []string {
+	if !p.Enabled {
+		return nil
+	}
+	args := []string{"--enable-alpha-plugins"}
+	if p.Exec {
+		args = append(args, "--enable-exec")
+	}
+	if p.Network {
+		args = append(args, "--network")
+	}
+	for _, mount := range p.Mounts {
+		args = append(args, "--mount", mount)
+	}
+	for _, env := range p.Env {
+		args = append(args, "--env", env)
+	}
+	if p.AsCurrentUser {
+		args = append(args, "--as-current-user")
+	}
+	return args
+}
+
+// HelmOptions are the kustomize helm flags of the builds. helm runs as a child process of kustomize, within
+// ExecLimits with the binary engine only, and pulls the charts missing from the chart home of a kustomization
+// (charts/ by default) into it. The overlays are built from the disk with helm, which reads the charts itself
+type HelmOptions struct {
+	Enabled bool   // --enable-helm: inflate the helmCharts of the kustomizations
+	Command string // --helm-command: the helm binary, empty for HELM_COMMAND
+}
+
+// Validate checks that the helm command is only set with helm enabled
+func (h HelmOptions) Validate() error {
+	if !h.Enabled && h.Command != "" {
+		return fmt.Errorf("the helm command requires helm to be enabled")
+	}
+	return nil
+}
+
+// args returns the kustomize build flags of the options
+func (h HelmOptions) args() []string {
+	if !h.Enabled {
+		return nil
+	}
+	return []string{"--enable-helm", "--helm-command", h.command()}
+}
+
+func (h HelmOptions) command() string {
+	if h.Command == "" {
+		return HELM_COMMAND
+	}
+	return h.Command
 }
 
 // Ensure Builder implements KustomizeBuilder
@@ -63,6 +202,7 @@ var _ KustomizeBuilder = (*Builder)(nil)
 func NewBuilder() *Builder {
 	return &Builder{
 		FailOnOverlayNotFound: false,
+		ExecLimits:            sandbox.DefaultLimits(),
 	}
 }
 
@@ -70,6 +210,7 @@ func NewBuilder() *Builder {
 func NewBuilderWithOptions(failOnOverlayNotFound bool) *Builder {
 	return &Builder{
 		FailOnOverlayNotFound: failOnOverlayNotFound,
+		ExecLimits:            sandbox.DefaultLimits(),
 	}
 }
 
@@ -110,7 +251,7 @@ func (b *Builder) validateFullPath(fullPath string) error {
 	logger.WithField("fullPath", fullPath).Info("Validating full path...")
 
 	// Check if path exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+	if !fsys.Exists(b.FS, fullPath) {
 		if b.FailOnOverlayNotFound {
 			return fmt.Errorf("path '%s' not found", fullPath)
 		}
@@ -133,20 +274,146 @@ func (b *Builder) validateFullPath(fullPath string) error {
 // Build runs kustomize build on the specified path
 // path here is fullpath to a service (manifestRoot + service)
 func (b *Builder) buildAtPath(ctx context.Context, path string) ([]byte, error) {
-	logger.WithField("path", path).Info("Building at path...")
-	cmd := exec.CommandContext(ctx, "kustomize", "build", path)
+	logger.WithField("path", path).WithField("engine", b.engine()).Info("Building at path...")
+	files := fsys.OrOS(b.FS)
+	// krusty builds an in-memory copy of the tree of the overlay, helm and the binary (and the plugins of a disk
+	// checkout) read the disk
+	var memFS filesys.FileSystem // nil when built from the disk
+	if b.Engine == KUSTOMIZE_ENGINE_BINARY || b.Helm.Enabled || (b.Plugins.Enabled && fsys.IsOS(files)) {
+		onDisk, cleanupDisk, err := fsys.OnDisk(b.FS, path)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanupDisk()
+		path, files = onDisk, fsys.OS
+	} else {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+		}
+		if memFS, err = inMemory(files, absPath); err != nil {
+			return nil, err
+		}
+		path = absPath
+	}
+
+	buildPath := path
+	wrapper := wrapperOptions{Namespace: b.BuildArgs[BUILD_ARG_NAMESPACE]}
+	var components []string
+	var err error
+	if b.ComponentProvenance {
+		if components, err = ResolveComponents(files, path); err != nil {
+			return nil, err
+		}
+	}
+	rewriteMetadata := b.ComponentProvenance || b.Provenance
+	if rewriteMetadata {
+		wrapper.BuildMetadata = []string{"originAnnotations", "transformerAnnotations"}
+	}
+	if len(b.BuildArgs) > 0 || rewriteMetadata {
+		if memFS != nil {
+			if buildPath, err = wrapOverlayInMemory(memFS, path, wrapper); err != nil {
+				return nil, err
+			}
+		} else {
+			wrapperDir, cleanup, err := wrapOverlay(path, wrapper)
+			if err != nil {
+				return nil, err
+			}
+			defer cleanup()
+			buildPath = wrapperDir
+		}
+	}
 
-	// Use Output() instead of CombinedOutput() to avoid stderr warnings in the output
-	output, err := cmd.Output()
+	var output []byte
+	if b.Engine == KUSTOMIZE_ENGINE_BINARY {
+		output, err = b.runBinary(ctx, buildPath)
+	} else {
+		krustyFS := memFS
+		if krustyFS == nil {
+			krustyFS = filesys.MakeFsOnDisk()
+		}
+		output, err = b.runKrusty(ctx, krustyFS, buildPath)
+	}
 	if err != nil {
-		// On error, get stderr for debugging
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("kustomize build failed: %w\nStderr: %s", err, string(exitErr.Stderr))
+		return nil, err
+	}
+
+	if rewriteMetadata {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve overlay path: %w", err)
 		}
+		options := metadataOptions{Components: components, Sources: b.Provenance}
+		if output, err = rewriteBuildMetadata(output, buildPath, absPath, options); err != nil {
+			return nil, err
+		}
+	}
+	if b.SOPS != "" {
+		if output, err = b.handleSOPS(ctx, output); err != nil {
+			return nil, err
+		}
+	}
+	if len(b.BuildArgs) > 0 {
+		output = b.BuildArgs.substitute(output)
+	}
+	if err := b.checkOutputSize(output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// runBinary builds a path of the disk with a kustomize process
+func (b *Builder) runBinary(ctx context.Context, buildPath string) ([]byte, error) {
+	limits := b.ExecLimits
+	if b.MaxManifestBytes > 0 && (limits.MaxOutputBytes == 0 || b.MaxManifestBytes < limits.MaxOutputBytes) {
+		limits.MaxOutputBytes = b.MaxManifestBytes
+	}
+	args := []string{"build", buildPath}
+	if b.LoadRestrictor == LOAD_RESTRICTOR_NONE {
+		args = append(args, "--load-restrictor", LOAD_RESTRICTOR_NONE)
+	}
+
+	// Only stdout is used to avoid stderr warnings in the output
+	res, err := sandbox.OrDefault(b.Executor).Run(ctx, sandbox.Command{
+		Name: "kustomize", Args: slices.Concat(args, b.Plugins.args(), b.Helm.args()), Limits: limits,
+	})
+	if errors.Is(err, sandbox.ErrOutputLimitExceeded) && limits.MaxOutputBytes == b.MaxManifestBytes {
+		return nil, fmt.Errorf("%w: the built manifest is above the %d bytes of --max-manifest-bytes", ErrOutputTooLarge, b.MaxManifestBytes)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("kustomize build failed: %w", err)
 	}
+	if res.ExitCode != 0 {
+		// On error, get stderr for debugging
+		return nil, fmt.Errorf("kustomize build failed: exit status %d\nStderr: %s", res.ExitCode, string(res.Stderr))
+	}
+	return res.Stdout, nil
+}
 
-	return output, nil
+func (b *Builder) engine() string {
+	if b.Engine == "" {
+		return KUSTOMIZE_ENGINE_KRUSTY
+	}
+	return b.Engine
+}
+
+// checkOutputSize fails with ErrOutputTooLarge if a built manifest is above MaxManifestBytes or MaxResources
+// Resources are counted by their top-level kind, kustomize writes one per document
+func (b *Builder) checkOutputSize(output []byte) error {
+	if b.MaxManifestBytes > 0 && int64(len(output)) > b.MaxManifestBytes {
+		return fmt.Errorf("%w: the built manifest has %d bytes, above the %d of --max-manifest-bytes", ErrOutputTooLarge, len(output), b.MaxManifestBytes)
+	}
+	if b.MaxResources > 0 {
+		count := bytes.Count(output, []byte("\nkind: "))
+		if bytes.HasPrefix(output, []byte("kind: ")) {
+			count++
+		}
+		if count > b.MaxResources {
+			return fmt.Errorf("%w: the built manifest has %d resources, above the %d of --max-resources", ErrOutputTooLarge, count, b.MaxResources)
+		}
+	}
+	return nil
 }
 
 // GetServiceEnvironmentPath returns the path to build for a service/environment
@@ -164,13 +431,13 @@ func (b *Builder) validateBuildPath(path, overlayName string) error {
 	logger.WithField("path", path).WithField("overlayName", overlayName).Info("Validating build path...")
 
 	// Check if service exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
+	if !fsys.Exists(b.FS, path) {
 		return fmt.Errorf("path '%s' not found", path)
 	}
 
 	// Check if base exists
 	basePath := filepath.Join(path, KUSTOMIZE_BASE_DIR)
-	if _, err := os.Stat(basePath); os.IsNotExist(err) {
+	if !fsys.Exists(b.FS, basePath) {
 		return fmt.Errorf("base directory not found for path '%s'", path)
 	}
 
@@ -180,7 +447,7 @@ func (b *Builder) validateBuildPath(path, overlayName string) error {
 
 	// Check if environment exists
 	envPath := filepath.Join(path, KUSTOMIZE_OVERLAY_DIR_NAME, overlayName)
-	if _, err := os.Stat(envPath); os.IsNotExist(err) {
+	if !fsys.Exists(b.FS, envPath) {
 		// Handle missing overlay based on configuration
 		if b.FailOnOverlayNotFound {
 			return fmt.Errorf("environment '%s' not found for path '%s'", overlayName, path)
@@ -202,7 +469,7 @@ func (b *Builder) isKustomizeFileInPath(kustomizeBuildPath string) bool {
 	found := false
 	for _, kustomizeFileName := range KUSTOMIZE_FILE_NAMES {
 		kustomizeFilePath := filepath.Join(kustomizeBuildPath, kustomizeFileName)
-		if _, err := os.Stat(kustomizeFilePath); err == nil {
+		if fsys.Exists(b.FS, kustomizeFilePath) {
 			found = true
 			break
 		}
@@ -0,0 +1,245 @@
+package kustomize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+)
+
+func TestBuilder_Plugins(t *testing.T) {
+	overlay := t.TempDir()
+	if err := os.WriteFile(filepath.Join(overlay, "kustomization.yaml"), []byte("generators: [ksops.yaml]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		plugins PluginOptions
+		want    []string
+		wantErr bool
+	}{
+		{"disabled", PluginOptions{}, []string{"build", overlay}, false},
+		{"exec functions", PluginOptions{Enabled: true, Exec: true, Env: []string{"SOPS_AGE_KEY_FILE"}},
+			[]string{"build", overlay, "--enable-alpha-plugins", "--enable-exec", "--env", "SOPS_AGE_KEY_FILE"}, false},
+		{"containerized functions", PluginOptions{Enabled: true, Network: true, Mounts: []string{"type=bind,src=/keys,dst=/keys"}},
+			[]string{"build", overlay, "--enable-alpha-plugins", "--network", "--mount", "type=bind,src=/keys,dst=/keys"}, false},
+		{"permissions without plugins", PluginOptions{Exec: true}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.plugins.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			fake := &sandbox.FakeExecutor{}
+			b := &Builder{Engine: KUSTOMIZE_ENGINE_BINARY, Executor: fake, Plugins: tt.plugins}
+			if _, err := b.BuildAtFullPath(context.Background(), overlay); err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.Commands()[0].Args; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kustomize args = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuilder_OutputTooLarge(t *testing.T) {
+	overlay := t.TempDir()
+	if err := os.WriteFile(filepath.Join(overlay, "kustomization.yaml"), []byte("resources: [all.yaml]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"
+
+	tests := []struct {
+		name      string
+		maxBytes  int64
+		maxCount  int
+		overflow  bool // the process hit its output limit
+		wantLimit int64
+		wantErr   bool
+	}{
+		{"no limits", 0, 0, false, sandbox.DEFAULT_MAX_OUTPUT_BYTES, false},
+		{"within limits", 1000, 2, false, 1000, false},
+		{"too many resources", 0, 1, false, sandbox.DEFAULT_MAX_OUTPUT_BYTES, true},
+		{"too large", 10, 0, true, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &sandbox.FakeExecutor{Handler: func(cmd sandbox.Command) (*sandbox.Result, error) {
+				if tt.overflow {
+					return &sandbox.Result{ExitCode: -1}, fmt.Errorf("kustomize: %w", sandbox.ErrOutputLimitExceeded)
+				}
+				return &sandbox.Result{Stdout: []byte(manifest)}, nil
+			}}
+			b := &Builder{Engine: KUSTOMIZE_ENGINE_BINARY, Executor: fake, ExecLimits: sandbox.DefaultLimits(), MaxManifestBytes: tt.maxBytes, MaxResources: tt.maxCount}
+			_, err := b.BuildAtFullPath(context.Background(), overlay)
+			if tt.wantErr != errors.Is(err, ErrOutputTooLarge) {
+				t.Errorf("BuildAtFullPath() error = %v, want output too large: %v", err, tt.wantErr)
+			}
+			if got := fake.Commands()[0].Limits.MaxOutputBytes; got != tt.wantLimit {
+				t.Errorf("kustomize output limit = %d, want %d", got, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestBuilder_Krusty(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"app/base/kustomization.yaml":                "resources: [deployment.yaml]\n",
+		"app/base/deployment.yaml":                   "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+		"app/environments/prod/kustomization.yaml":   "resources: [../../base]\nnamePrefix: prod-\n",
+		"app/environments/shared/kustomization.yaml": "resources: [../../base]\nconfigMapGenerator:\n- name: settings\n  files: [../../../shared.env]\n",
+		"shared.env": "LEVEL=debug\n",
+	}
+	mem := fsys.NewMem()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := mem.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		fs             fsys.FS
+		overlay        string
+		loadRestrictor string
+		want           []string
+		wantErr        bool
+	}{
+		{"disk", nil, "prod", "", []string{"name: prod-web", "namespace: pr-1"}, false},
+		{"in memory", mem, "prod", "", []string{"name: prod-web", "namespace: pr-1"}, false},
+		{"file outside the root", mem, "shared", "", nil, true},
+		{"file outside the root without restrictions", mem, "shared", LOAD_RESTRICTOR_NONE, []string{"LEVEL=debug"}, false},
+		{"file outside the root without restrictions on disk", nil, "shared", LOAD_RESTRICTOR_NONE, []string{"LEVEL=debug"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &sandbox.FakeExecutor{}
+			b := &Builder{FS: tt.fs, Executor: fake, LoadRestrictor: tt.loadRestrictor, BuildArgs: BuildArgs{BUILD_ARG_NAMESPACE: "pr-1"}}
+			got, err := b.Build(context.Background(), filepath.Join(root, "app"), tt.overlay)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Build() does not contain %q:\n%s", want, got)
+				}
+			}
+			if len(fake.Commands()) > 0 {
+				t.Errorf("krusty ran %v, want no process", fake.Commands())
+			}
+		})
+	}
+}
+
+func TestInMemory(t *testing.T) {
+	mem := fsys.NewMem()
+	for name, content := range map[string]string{
+		"/repo/app/base/kustomization.yaml":              "resources: [deployment.yaml]\n",
+		"/repo/app/base/deployment.yaml":                 "kind: Deployment\n",
+		"/repo/app/environments/prod/kustomization.yaml": "resources: [../../base, ../../../extra.yaml]\npatches:\n- path: ../../../patch.yaml\n",
+		"/repo/app/environments/stg/kustomization.yaml":  "resources: [../../base]\n",
+		"/repo/extra.yaml":                               "kind: ConfigMap\n",
+		"/repo/patch.yaml":                               "kind: Deployment\n",
+		"/repo/other/big.yaml":                           "kind: Secret\n",
+	} {
+		if err := mem.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	memFS, err := inMemory(mem, "/repo/app/environments/prod")
+	if err != nil {
+		t.Fatalf("inMemory() error = %v", err)
+	}
+	for _, path := range []string{"/repo/app/environments/prod/kustomization.yaml", "/repo/app/base/deployment.yaml", "/repo/extra.yaml", "/repo/patch.yaml"} {
+		if !memFS.Exists(path) {
+			t.Errorf("%s not copied, the build reads it", path)
+		}
+	}
+	for _, path := range []string{"/repo/app/environments/stg", "/repo/other/big.yaml"} {
+		if memFS.Exists(path) {
+			t.Errorf("%s copied, the build does not read it", path)
+		}
+	}
+}
+
+func TestBuilder_Helm(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake helm is a shell script")
+	}
+	root := t.TempDir()
+	helm := filepath.Join(root, "helm")
+	script := `#!/bin/sh
+case "$1" in
+version) echo v3.14.0 ;;
+template) printf 'apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s-chart\n' "$2" ;;
+esac
+`
+	files := map[string]string{
+		"app/environments/prod/kustomization.yaml":     "helmCharts:\n- name: web\n  releaseName: web\n",
+		"app/environments/prod/charts/web/Chart.yaml":  "apiVersion: v2\nname: web\nversion: 1.0.0\n",
+		"app/environments/prod/charts/web/values.yaml": "{}\n",
+	}
+	mem := fsys.NewMem()
+	for name, content := range files {
+		if err := mem.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(helm, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		helm    HelmOptions
+		wantErr bool
+	}{
+		{"inflated", HelmOptions{Enabled: true, Command: helm}, false},
+		{"helm disabled", HelmOptions{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Builder{FS: mem, Helm: tt.helm}
+			got, err := b.BuildAtFullPath(context.Background(), filepath.Join(root, "app/environments/prod"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildAtFullPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !strings.Contains(string(got), "name: web-chart") {
+				t.Errorf("BuildAtFullPath() does not contain the chart resources:\n%s", got)
+			}
+		})
+	}
+
+	fake := &sandbox.FakeExecutor{}
+	b := &Builder{FS: mem, Engine: KUSTOMIZE_ENGINE_BINARY, Executor: fake, Helm: HelmOptions{Enabled: true}}
+	if _, err := b.BuildAtFullPath(context.Background(), filepath.Join(root, "app/environments/prod")); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.Commands()[0].Args[2:]; !reflect.DeepEqual(got, []string{"--enable-helm", "--helm-command", HELM_COMMAND}) {
+		t.Errorf("kustomize helm args = %v, want --enable-helm --helm-command %s", got, HELM_COMMAND)
+	}
+	if err := (HelmOptions{Command: helm}).Validate(); err == nil {
+		t.Errorf("Validate() of a helm command without helm succeeded, want an error")
+	}
+}
@@ -0,0 +1,224 @@
+package kustomize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// kustomizationRefs are the fields of a kustomization referencing other local directories
+type kustomizationRefs struct {
+	Resources  []string `yaml:"resources"`
+	Bases      []string `yaml:"bases"` // deprecated by kustomize, still supported
+	Components []string `yaml:"components"`
+}
+
+// ResolveComponents walks the kustomization tree of an overlay and returns the absolute paths of the
+// kustomize components it uses, directly or through its resources. Remote references are ignored.
+// The tree is read from files, nil for the disk
+func ResolveComponents(files fsys.FS, overlayPath string) ([]string, error) {
+	absOverlayPath, err := filepath.Abs(overlayPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve overlay path: %w", err)
+	}
+
+	components := map[string]bool{}
+	visited := map[string]bool{}
+	if err := walkKustomization(fsys.OrOS(files), absOverlayPath, false, components, visited); err != nil {
+		return nil, err
+	}
+
+	results := make([]string, 0, len(components))
+	for path := range components {
+		results = append(results, path)
+	}
+	sort.Strings(results)
+	return results, nil
+}
+
+func walkKustomization(files fsys.FS, dir string, isComponent bool, components, visited map[string]bool) error {
+	if visited[dir] {
+		return nil
+	}
+	visited[dir] = true
+	if isComponent {
+		components[dir] = true
+	}
+
+	refs, err := readKustomizationRefs(files, dir)
+	if err != nil || refs == nil {
+		return err
+	}
+
+	walk := func(entries []string, isComponent bool) error {
+		for _, entry := range entries {
+			if isRemoteRef(entry) {
+				continue
+			}
+			path := filepath.Join(dir, entry)
+			if info, err := files.Stat(path); err != nil || !info.IsDir() {
+				continue // plain resource files, or missing paths reported by kustomize itself
+			}
+			if err := walkKustomization(files, path, isComponent, components, visited); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(refs.Resources, false); err != nil {
+		return err
+	}
+	if err := walk(refs.Bases, false); err != nil {
+		return err
+	}
+	return walk(refs.Components, true)
+}
+
+// readKustomizationRefs returns nil if the directory has no kustomization file
+func readKustomizationRefs(files fsys.FS, dir string) (*kustomizationRefs, error) {
+	refs := &kustomizationRefs{}
+	found, err := readKustomization(files, dir, refs)
+	if err != nil || !found {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// readKustomization decodes the kustomization file of dir into out, false if there is none
+func readKustomization(files fsys.FS, dir string, out interface{}) (bool, error) {
+	for _, name := range KUSTOMIZE_FILE_NAMES {
+		data, err := files.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read kustomization in %s: %w", dir, err)
+		}
+		if err := yaml.Unmarshal(data, out); err != nil {
+			return false, fmt.Errorf("failed to parse kustomization in %s: %w", dir, err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func isRemoteRef(entry string) bool {
+	return strings.Contains(entry, "://") || strings.Contains(entry, "?ref=") ||
+		strings.HasPrefix(entry, "github.com/") || strings.HasPrefix(entry, "git@")
+}
+
+// CompareComponents lists the components used by the before and/or after overlay, and whether their content changed
+// Components are identified by their path relative to the overlay, so both sides can live in different roots
+// An empty overlay path means the overlay does not exist on that side, both are read from files (nil for the disk)
+func CompareComponents(files fsys.FS, beforeOverlayPath, afterOverlayPath string) ([]models.ComponentUsage, error) {
+	files = fsys.OrOS(files)
+	beforeComponents, err := relativeComponents(files, beforeOverlayPath)
+	if err != nil {
+		return nil, err
+	}
+	afterComponents, err := relativeComponents(files, afterOverlayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(beforeComponents)+len(afterComponents))
+	for path := range afterComponents {
+		paths = append(paths, path)
+	}
+	for path := range beforeComponents {
+		if _, ok := afterComponents[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	results := make([]models.ComponentUsage, 0, len(paths))
+	for _, path := range paths {
+		beforeDir, inBefore := beforeComponents[path]
+		afterDir, inAfter := afterComponents[path]
+		usage := models.ComponentUsage{Path: path, Status: models.ComponentStatusUnchanged}
+		switch {
+		case !inBefore:
+			usage.Status = models.ComponentStatusAdded
+		case !inAfter:
+			usage.Status = models.ComponentStatusRemoved
+		default:
+			beforeDigest, err := dirDigest(files, beforeDir)
+			if err != nil {
+				return nil, err
+			}
+			afterDigest, err := dirDigest(files, afterDir)
+			if err != nil {
+				return nil, err
+			}
+			if beforeDigest != afterDigest {
+				usage.Status = models.ComponentStatusModified
+			}
+		}
+		results = append(results, usage)
+	}
+	return results, nil
+}
+
+// relativeComponents maps the components of an overlay by their slash path relative to it
+func relativeComponents(files fsys.FS, overlayPath string) (map[string]string, error) {
+	results := map[string]string{}
+	if overlayPath == "" {
+		return results, nil
+	}
+	if _, err := files.Stat(overlayPath); errors.Is(err, fs.ErrNotExist) {
+		return results, nil
+	}
+	absOverlayPath, err := filepath.Abs(overlayPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve overlay path: %w", err)
+	}
+	components, err := ResolveComponents(files, absOverlayPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range components {
+		rel, err := filepath.Rel(absOverlayPath, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve component path: %w", err)
+		}
+		results[filepath.ToSlash(rel)] = dir
+	}
+	return results, nil
+}
+
+// dirDigest hashes the relative paths and contents of all files under dir
+func dirDigest(files fsys.FS, dir string) (string, error) {
+	h := sha256.New()
+	err := fsys.WalkDir(files, dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := files.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, _ = io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+		_, _ = h.Write(content)
+		_, _ = h.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash component %s: %w", dir, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,149 @@
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// writeTree creates files under root, keys are slash paths
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCompareComponents(t *testing.T) {
+	common := map[string]string{
+		"base/kustomization.yaml":                  "resources: [deployment.yaml]\ncomponents: [../components/logging]\n",
+		"base/deployment.yaml":                     "kind: Deployment\n",
+		"components/logging/kustomization.yaml":    "kind: Component\n",
+		"components/monitoring/kustomization.yaml": "kind: Component\n",
+		"components/monitoring/patch.yaml":         "replicas: 1\n",
+		"components/tracing/kustomization.yaml":    "kind: Component\n",
+	}
+	before, after := t.TempDir(), t.TempDir()
+	writeTree(t, before, common)
+	writeTree(t, after, common)
+	writeTree(t, before, map[string]string{
+		"overlays/prod/kustomization.yaml": "resources: [../../base, https://example.com/remote.yaml]\ncomponents: [../../components/monitoring, ../../components/tracing]\n",
+	})
+	writeTree(t, after, map[string]string{
+		"overlays/prod/kustomization.yaml":    "resources: [../../base]\ncomponents: [../../components/monitoring, ../../components/istio]\n",
+		"components/monitoring/patch.yaml":    "replicas: 2\n",
+		"components/istio/kustomization.yaml": "kind: Component\n",
+	})
+
+	got, err := CompareComponents(nil, filepath.Join(before, "overlays/prod"), filepath.Join(after, "overlays/prod"))
+	if err != nil {
+		t.Fatalf("CompareComponents() error = %v", err)
+	}
+	want := []models.ComponentUsage{
+		{Path: "../../components/istio", Status: models.ComponentStatusAdded},
+		{Path: "../../components/logging", Status: models.ComponentStatusUnchanged},
+		{Path: "../../components/monitoring", Status: models.ComponentStatusModified},
+		{Path: "../../components/tracing", Status: models.ComponentStatusRemoved},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CompareComponents() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRewriteBuildMetadata_Components(t *testing.T) {
+	root := t.TempDir()
+	overlay := filepath.Join(root, "overlays", "prod")
+	monitoring := filepath.Join(root, "components", "monitoring")
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    config.kubernetes.io/origin: |
+      path: ../../base/deployment.yaml
+    alpha.config.kubernetes.io/transformations: |
+      - path: ../../components/monitoring/kustomization.yaml
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  annotations:
+    config.kubernetes.io/origin: |
+      path: ../../base/service.yaml
+`
+	got, err := rewriteBuildMetadata([]byte(manifest), overlay, overlay, metadataOptions{Components: []string{monitoring}})
+	if err != nil {
+		t.Fatalf("rewriteBuildMetadata() error = %v", err)
+	}
+	output := string(got)
+	if strings.Contains(output, ORIGIN_ANNOTATION) || strings.Contains(output, TRANSFORMATIONS_ANNOTATION) {
+		t.Errorf("kustomize annotations should be removed, got:\n%s", output)
+	}
+	if !strings.Contains(output, COMPONENTS_ANNOTATION+": ../../components/monitoring") {
+		t.Errorf("patched resource should be annotated with its component, got:\n%s", output)
+	}
+	if strings.Count(output, COMPONENTS_ANNOTATION) != 1 || strings.Contains(output, "annotations: {}") {
+		t.Errorf("resources without component should not be annotated, got:\n%s", output)
+	}
+	if strings.Contains(output, SOURCE_ANNOTATION) {
+		t.Errorf("source annotations should only be added when enabled, got:\n%s", output)
+	}
+}
+
+func TestExtractProvenance(t *testing.T) {
+	root := t.TempDir()
+	overlay := filepath.Join(root, "overlays", "prod")
+	manifest := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  annotations:
+    config.kubernetes.io/origin: |
+      path: ../../base/deployment.yaml
+    alpha.config.kubernetes.io/transformations: |
+      - path: kustomization.yaml
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  annotations:
+    team: payments
+    config.kubernetes.io/origin: |
+      path: configmap.yaml
+`
+	built, err := rewriteBuildMetadata([]byte(manifest), overlay, overlay, metadataOptions{Sources: true})
+	if err != nil {
+		t.Fatalf("rewriteBuildMetadata() error = %v", err)
+	}
+	got, origins, err := ExtractProvenance(built)
+	if err != nil {
+		t.Fatalf("ExtractProvenance() error = %v", err)
+	}
+	output := string(got)
+	if strings.Contains(output, SOURCE_ANNOTATION) || strings.Contains(output, PATCHED_BY_ANNOTATION) {
+		t.Errorf("source annotations should be removed, got:\n%s", output)
+	}
+	if strings.Count(output, "annotations:") != 1 || !strings.Contains(output, "team: payments") {
+		t.Errorf("other annotations should be kept, got:\n%s", output)
+	}
+	want := map[string]models.ResourceOrigin{
+		"Deployment/prod/web": {SourceFile: "../../base/deployment.yaml", PatchedBy: []string{"kustomization.yaml"}},
+		"ConfigMap/settings":  {SourceFile: "configmap.yaml"},
+	}
+	if !reflect.DeepEqual(origins, want) {
+		t.Errorf("ExtractProvenance() origins = %+v, want %+v", origins, want)
+	}
+}
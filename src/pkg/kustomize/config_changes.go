@@ -0,0 +1,217 @@
+package kustomize
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// kustomizationConfig are the semantic fields of a kustomization compared by CompareKustomizations
+type kustomizationConfig struct {
+	Images                []imageConfig   `yaml:"images"`
+	Replicas              []replicaConfig `yaml:"replicas"`
+	Patches               []patchConfig   `yaml:"patches"`
+	PatchesStrategicMerge []string        `yaml:"patchesStrategicMerge"` // deprecated by kustomize, still supported
+	PatchesJson6902       []patchConfig   `yaml:"patchesJson6902"`       // deprecated by kustomize, still supported
+}
+
+type imageConfig struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName"`
+	NewTag  string `yaml:"newTag"`
+	Digest  string `yaml:"digest"`
+}
+
+type replicaConfig struct {
+	Name  string `yaml:"name"`
+	Count int    `yaml:"count"`
+}
+
+type patchConfig struct {
+	Path   string       `yaml:"path"`
+	Patch  string       `yaml:"patch"`
+	Target *patchTarget `yaml:"target"`
+}
+
+type patchTarget struct {
+	Group     string `yaml:"group"`
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+	Selector  string `yaml:"labelSelector"`
+}
+
+// CompareKustomizations compares the images, replicas and patches declared by the before and after overlay
+// kustomizations (not the bases they include), changes are sorted by field then name
+// An empty overlay path means the overlay does not exist on that side, both are read from files (nil for the disk)
+func CompareKustomizations(files fsys.FS, beforeOverlayPath, afterOverlayPath string) ([]models.ConfigChange, error) {
+	files = fsys.OrOS(files)
+	before, err := readKustomizationConfig(files, beforeOverlayPath)
+	if err != nil {
+		return nil, err
+	}
+	after, err := readKustomizationConfig(files, afterOverlayPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []models.ConfigChange
+	changes = append(changes, compareValues(models.ConfigFieldImages, imageValues(before), imageValues(after), true)...)
+	changes = append(changes, compareValues(models.ConfigFieldReplicas, replicaValues(before), replicaValues(after), true)...)
+
+	beforePatches, err := patchValues(files, beforeOverlayPath, before)
+	if err != nil {
+		return nil, err
+	}
+	afterPatches, err := patchValues(files, afterOverlayPath, after)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, compareValues(models.ConfigFieldPatches, beforePatches, afterPatches, false)...)
+	return changes, nil
+}
+
+// readKustomizationConfig returns an empty config if the overlay or its kustomization file does not exist
+func readKustomizationConfig(files fsys.FS, overlayPath string) (*kustomizationConfig, error) {
+	config := &kustomizationConfig{}
+	if overlayPath == "" {
+		return config, nil
+	}
+	if _, err := readKustomization(files, overlayPath, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// compareValues diffs two name -> value maps, values are reported only if showValues is set
+func compareValues(field string, before, after map[string]string, showValues bool) []models.ConfigChange {
+	names := make([]string, 0, len(before)+len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []models.ConfigChange
+	for _, name := range names {
+		beforeValue, inBefore := before[name]
+		afterValue, inAfter := after[name]
+		change := models.ConfigChange{Field: field, Name: name}
+		switch {
+		case !inBefore:
+			change.Action = models.ResourceChangeAdded
+		case !inAfter:
+			change.Action = models.ResourceChangeRemoved
+		case beforeValue != afterValue:
+			change.Action = models.ResourceChangeModified
+		default:
+			continue
+		}
+		if showValues {
+			change.Before, change.After = beforeValue, afterValue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// imageValues maps image names to their effective reference, e.g. "nginx" -> "registry/nginx:1.25@sha256:..."
+func imageValues(config *kustomizationConfig) map[string]string {
+	values := make(map[string]string, len(config.Images))
+	for _, image := range config.Images {
+		ref := image.Name
+		if image.NewName != "" {
+			ref = image.NewName
+		}
+		if image.NewTag != "" {
+			ref += ":" + image.NewTag
+		}
+		if image.Digest != "" {
+			ref += "@" + image.Digest
+		}
+		values[image.Name] = ref
+	}
+	return values
+}
+
+func replicaValues(config *kustomizationConfig) map[string]string {
+	values := make(map[string]string, len(config.Replicas))
+	for _, replica := range config.Replicas {
+		values[replica.Name] = strconv.Itoa(replica.Count)
+	}
+	return values
+}
+
+// patchValues maps patches to their content: file patches by path (content read from the overlay),
+// inline patches by target
+func patchValues(files fsys.FS, overlayPath string, config *kustomizationConfig) (map[string]string, error) {
+	values := map[string]string{}
+	add := func(patch patchConfig) error {
+		target := describePatchTarget(patch.Target)
+		if patch.Path == "" {
+			name := "inline patch"
+			if target != "" {
+				name += " on " + target
+			}
+			values[name] += patch.Patch
+			return nil
+		}
+		content, err := files.ReadFile(filepath.Join(overlayPath, patch.Path))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read patch %s: %w", patch.Path, err)
+		}
+		values[patch.Path] = target + "\n" + string(content)
+		return nil
+	}
+
+	for _, patch := range config.Patches {
+		if err := add(patch); err != nil {
+			return nil, err
+		}
+	}
+	for _, patch := range config.PatchesJson6902 {
+		if err := add(patch); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range config.PatchesStrategicMerge {
+		// entries can be inline patches as well, kustomize tells them apart by looking for a newline
+		if strings.Contains(path, "\n") {
+			values["inline patch"] += path
+			continue
+		}
+		if err := add(patchConfig{Path: path}); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// describePatchTarget formats a patch target as "group/Kind/namespace/name (selector)", with the fields that are set
+func describePatchTarget(target *patchTarget) string {
+	if target == nil {
+		return ""
+	}
+	var parts []string
+	for _, part := range []string{target.Group, target.Kind, target.Namespace, target.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	description := strings.Join(parts, "/")
+	if target.Selector != "" {
+		description += " (" + target.Selector + ")"
+	}
+	return description
+}
@@ -0,0 +1,71 @@
+package kustomize
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestCompareKustomizations(t *testing.T) {
+	before, after := t.TempDir(), t.TempDir()
+	writeTree(t, before, map[string]string{
+		"prod/kustomization.yaml": `images:
+- name: nginx
+  newTag: "1.25"
+- name: redis
+  newName: registry.example.com/redis
+replicas:
+- name: web
+  count: 2
+patches:
+- path: resources.yaml
+- path: probes.yaml
+- patch: '[{"op": "remove", "path": "/spec/strategy"}]'
+  target: {kind: Deployment, name: web}
+`,
+		"prod/resources.yaml": "cpu: 100m\n",
+		"prod/probes.yaml":    "readinessProbe: {}\n",
+	})
+	writeTree(t, after, map[string]string{
+		"prod/kustomization.yaml": `images:
+- name: nginx
+  newTag: "1.27"
+- name: redis
+  newName: registry.example.com/redis
+- name: busybox
+  digest: sha256:abc
+replicas:
+- name: web
+  count: 2
+patchesStrategicMerge:
+- resources.yaml
+- probes.yaml
+`,
+		"prod/resources.yaml": "cpu: 200m\n",
+		"prod/probes.yaml":    "readinessProbe: {}\n",
+	})
+
+	got, err := CompareKustomizations(nil, filepath.Join(before, "prod"), filepath.Join(after, "prod"))
+	if err != nil {
+		t.Fatalf("CompareKustomizations() error = %v", err)
+	}
+	want := []models.ConfigChange{
+		{Field: models.ConfigFieldImages, Name: "busybox", Action: models.ResourceChangeAdded, After: "busybox@sha256:abc"},
+		{Field: models.ConfigFieldImages, Name: "nginx", Action: models.ResourceChangeModified, Before: "nginx:1.25", After: "nginx:1.27"},
+		{Field: models.ConfigFieldPatches, Name: "inline patch on Deployment/web", Action: models.ResourceChangeRemoved},
+		{Field: models.ConfigFieldPatches, Name: "resources.yaml", Action: models.ResourceChangeModified},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CompareKustomizations() = %+v, want %+v", got, want)
+	}
+
+	got, err = CompareKustomizations(nil, "", filepath.Join(after, "prod"))
+	if err != nil {
+		t.Fatalf("CompareKustomizations() error = %v", err)
+	}
+	if len(got) != 6 || got[0].Action != models.ResourceChangeAdded {
+		t.Errorf("CompareKustomizations() on a new overlay = %+v, want only additions", got)
+	}
+}
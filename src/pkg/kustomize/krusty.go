@@ -0,0 +1,232 @@
+package kustomize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// runKrusty builds a path with the kustomize API, the options of the binary flags of the builder
+// The build cannot be interrupted: on timeout it is left to finish in the background, its result discarded
+func (b *Builder) runKrusty(ctx context.Context, files filesys.FileSystem, buildPath string) ([]byte, error) {
+	if timeout := b.ExecLimits.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resources, err := krusty.MakeKustomizer(b.krustyOptions()).Run(files, buildPath)
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		output, err := resources.AsYaml()
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, fmt.Errorf("kustomize build failed: %w", res.err)
+		}
+		return res.output, nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("kustomize build failed: %w (%s)", sandbox.ErrTimeout, b.ExecLimits.Timeout)
+		}
+		return nil, fmt.Errorf("kustomize build failed: %w", ctx.Err())
+	}
+}
+
+// krustyOptions returns the kustomize API options of the load restrictor, the plugins and helm, the defaults of
+// kustomize build
+func (b *Builder) krustyOptions() *krusty.Options {
+	options := krusty.MakeDefaultOptions()
+	options.Reorder = krusty.ReorderOptionUnspecified
+	if b.LoadRestrictor == LOAD_RESTRICTOR_NONE {
+		options.LoadRestrictions = types.LoadRestrictionsNone
+	}
+	if b.Plugins.Enabled {
+		options.PluginConfig = types.EnabledPluginConfig(types.BploUseStaticallyLinked)
+		options.PluginConfig.FnpLoadingOptions = types.FnPluginLoadingOptions{
+			EnableExec:    b.Plugins.Exec,
+			Network:       b.Plugins.Network,
+			Mounts:        b.Plugins.Mounts,
+			Env:           b.Plugins.Env,
+			AsCurrentUser: b.Plugins.AsCurrentUser,
+		}
+	}
+	options.PluginConfig.HelmConfig = types.HelmConfig{Enabled: b.Helm.Enabled, Command: b.Helm.command()}
+	return options
+}
+
+// inMemory copies the tree an overlay build reads into a kustomize in-memory filesystem, at the same paths: the
+// directories of the kustomizations reached through resources, bases and components, and the files they reference
+// out of them (--load-restrictor none). Remote references are left to kustomize
+func inMemory(files fsys.FS, path string) (filesys.FileSystem, error) {
+	tree := &buildTree{files: files, dirs: make(map[string]bool)}
+	if err := tree.visit(path); err != nil {
+		return nil, err
+	}
+	memFS := filesys.MakeFsInMemory()
+	// the nested kustomizations are copied with the directory of their parent
+	var copied []string
+	for _, dir := range slices.Sorted(maps.Keys(tree.dirs)) {
+		if isUnder(dir, copied) {
+			continue
+		}
+		copied = append(copied, dir)
+		if err := copyToMemory(files, dir, memFS); err != nil {
+			return nil, fmt.Errorf("failed to copy %s to memory: %w", dir, err)
+		}
+	}
+	for _, file := range tree.paths {
+		if isUnder(file, copied) {
+			continue
+		}
+		if err := copyToMemory(files, file, memFS); err != nil {
+			return nil, fmt.Errorf("failed to copy %s to memory: %w", file, err)
+		}
+	}
+	return memFS, nil
+}
+
+// kustomizationFiles are the fields of a kustomization referencing local directories and files
+type kustomizationFiles struct {
+	kustomizationRefs     `yaml:",inline"`
+	Patches               []struct{ Path string } `yaml:"patches"`
+	PatchesStrategicMerge []string                `yaml:"patchesStrategicMerge"` // file names or inline patches
+	PatchesJson6902       []struct{ Path string } `yaml:"patchesJson6902"`
+	Replacements          []struct{ Path string } `yaml:"replacements"`
+	ConfigMapGenerator    []generatorFiles        `yaml:"configMapGenerator"`
+	SecretGenerator       []generatorFiles        `yaml:"secretGenerator"`
+	Crds                  []string                `yaml:"crds"`
+	Generators            []string                `yaml:"generators"` // file names or inline configs, as the two below
+	Transformers          []string                `yaml:"transformers"`
+	Validators            []string                `yaml:"validators"`
+	OpenAPI               struct{ Path string }   `yaml:"openapi"`
+}
+
+// generatorFiles are the files of a configMapGenerator or secretGenerator, "key=path" or path
+type generatorFiles struct {
+	Files []string `yaml:"files"`
+	Envs  []string `yaml:"envs"`
+	Env   string   `yaml:"env"`
+}
+
+// buildTree collects the directories and files an overlay build reads
+type buildTree struct {
+	files fsys.FS
+	dirs  map[string]bool // kustomization directories
+	paths []string        // referenced files, possibly out of dirs
+}
+
+func (t *buildTree) visit(dir string) error {
+	if t.dirs[dir] {
+		return nil
+	}
+	t.dirs[dir] = true
+	var k kustomizationFiles
+	if found, err := readKustomization(t.files, dir, &k); err != nil || !found {
+		return err
+	}
+
+	for _, entries := range [][]string{k.Resources, k.Bases, k.Components} {
+		for _, entry := range entries {
+			if isRemoteRef(entry) {
+				continue
+			}
+			path := filepath.Join(dir, entry)
+			info, err := t.files.Stat(path)
+			if err != nil {
+				continue // missing paths are reported by kustomize itself
+			}
+			if !info.IsDir() {
+				t.paths = append(t.paths, path)
+				continue
+			}
+			if err := t.visit(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	refs := slices.Concat(k.PatchesStrategicMerge, k.Crds, k.Generators, k.Transformers, k.Validators, []string{k.OpenAPI.Path})
+	for _, patch := range slices.Concat(k.Patches, k.PatchesJson6902, k.Replacements) {
+		refs = append(refs, patch.Path)
+	}
+	for _, generator := range slices.Concat(k.ConfigMapGenerator, k.SecretGenerator) {
+		for _, file := range generator.Files {
+			if _, path, ok := strings.Cut(file, "="); ok {
+				file = path
+			}
+			refs = append(refs, file)
+		}
+		refs = append(append(refs, generator.Envs...), generator.Env)
+	}
+	for _, ref := range refs {
+		if ref == "" || strings.Contains(ref, "\n") || isRemoteRef(ref) {
+			continue // inline patches and configs
+		}
+		t.paths = append(t.paths, filepath.Join(dir, ref))
+	}
+	return nil
+}
+
+// isUnder reports whether path is one of dirs or under one of them
+func isUnder(path string, dirs []string) bool {
+	return slices.ContainsFunc(dirs, func(dir string) bool {
+		rel, err := filepath.Rel(dir, path)
+		return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	})
+}
+
+// copyToMemory copies a file or directory tree into memFS at the same paths, but fsys.SKIPPED_DIRS
+func copyToMemory(files fsys.FS, root string, memFS filesys.FileSystem) error {
+	return fsys.WalkDir(files, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil // missing files are reported by kustomize itself
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != root && slices.Contains(fsys.SKIPPED_DIRS, d.Name()) {
+				return filepath.SkipDir
+			}
+			return memFS.MkdirAll(path)
+		}
+		info, err := files.Stat(path)
+		if err == nil && info.IsDir() {
+			// a symlinked directory, copied as the directory it points to unless it is an ancestor
+			if target, err := filepath.EvalSymlinks(path); err == nil && isUnder(path, []string{target}) {
+				return nil
+			}
+			return copyToMemory(files, path+string(filepath.Separator), memFS)
+		}
+		if err != nil || !info.Mode().IsRegular() {
+			return nil // dangling symlinks and special files are not part of the manifests
+		}
+		content, err := files.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return memFS.WriteFile(path, content)
+	})
+}
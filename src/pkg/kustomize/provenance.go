@@ -0,0 +1,251 @@
+package kustomize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// COMPONENTS_ANNOTATION lists the components (relative to the overlay) that created or patched a resource
+	COMPONENTS_ANNOTATION = "gitops-kustomzchk/components"
+	// SOURCE_ANNOTATION and PATCHED_BY_ANNOTATION record the file a resource comes from and the kustomizations patching it
+	// (relative to the overlay), they only live between the build and ExtractProvenance
+	SOURCE_ANNOTATION     = "gitops-kustomzchk/source"
+	PATCHED_BY_ANNOTATION = "gitops-kustomzchk/patched-by"
+
+	// Annotations added by kustomize buildMetadata
+	ORIGIN_ANNOTATION          = "config.kubernetes.io/origin"
+	TRANSFORMATIONS_ANNOTATION = "alpha.config.kubernetes.io/transformations"
+)
+
+// origin is the value of the kustomize origin annotation, and each entry of the transformations one
+type origin struct {
+	Path string `yaml:"path"`
+	Repo string `yaml:"repo,omitempty"`
+}
+
+// metadataOptions selects what rewriteBuildMetadata derives from the kustomize build metadata
+type metadataOptions struct {
+	Components []string // absolute paths of the overlay components, for COMPONENTS_ANNOTATION
+	Sources    bool     // add SOURCE_ANNOTATION and PATCHED_BY_ANNOTATION
+}
+
+// rewriteBuildMetadata replaces the kustomize origin/transformations annotations of each resource with
+// COMPONENTS_ANNOTATION (components among the origins) and, if enabled, the source annotations
+// originRoot is the directory origin paths are relative to (the built kustomization), overlayPath is absolute
+func rewriteBuildMetadata(manifest []byte, originRoot, overlayPath string, options metadataOptions) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse built manifest: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		metadata := mappingValue(doc.Content[0], "metadata")
+		annotations := mappingValue(metadata, "annotations")
+		if annotations != nil {
+			var source string
+			var patchedBy []string
+			if value := removeMappingKey(annotations, ORIGIN_ANNOTATION); value != "" {
+				var o origin
+				if err := yaml.Unmarshal([]byte(value), &o); err == nil && o.Repo == "" {
+					source = o.Path
+				}
+			}
+			if value := removeMappingKey(annotations, TRANSFORMATIONS_ANNOTATION); value != "" {
+				var transformations []origin
+				if err := yaml.Unmarshal([]byte(value), &transformations); err == nil {
+					for _, o := range transformations {
+						if o.Repo == "" && o.Path != "" && !contains(patchedBy, o.Path) {
+							patchedBy = append(patchedBy, o.Path)
+						}
+					}
+				}
+			}
+
+			paths := append([]string{source}, patchedBy...)
+			if contributors := matchComponents(paths, originRoot, overlayPath, options.Components); len(contributors) > 0 {
+				appendMappingValue(annotations, COMPONENTS_ANNOTATION, strings.Join(contributors, ","))
+			}
+			if options.Sources {
+				if source != "" {
+					appendMappingValue(annotations, SOURCE_ANNOTATION, relativeToOverlay(source, originRoot, overlayPath))
+				}
+				if len(patchedBy) > 0 {
+					for i, path := range patchedBy {
+						patchedBy[i] = relativeToOverlay(path, originRoot, overlayPath)
+					}
+					appendMappingValue(annotations, PATCHED_BY_ANNOTATION, strings.Join(patchedBy, ","))
+				}
+			}
+			if len(annotations.Content) == 0 {
+				removeMappingKey(metadata, "annotations")
+			}
+		}
+
+		if err := encoder.Encode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to encode built manifest: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// relativeToOverlay converts an origin path (relative to originRoot) to a slash path relative to the overlay
+func relativeToOverlay(path, originRoot, overlayPath string) string {
+	rel, err := filepath.Rel(overlayPath, filepath.Join(originRoot, filepath.FromSlash(path)))
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// matchComponents returns the components (relative to the overlay) containing any of the origin paths
+func matchComponents(paths []string, originRoot, overlayPath string, components []string) []string {
+	matched := map[string]bool{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		absPath := filepath.Join(originRoot, filepath.FromSlash(path))
+		for _, component := range components {
+			if absPath != component && !strings.HasPrefix(absPath, component+string(filepath.Separator)) {
+				continue
+			}
+			rel, err := filepath.Rel(overlayPath, component)
+			if err != nil {
+				continue
+			}
+			matched[filepath.ToSlash(rel)] = true
+		}
+	}
+
+	results := make([]string, 0, len(matched))
+	for component := range matched {
+		results = append(results, component)
+	}
+	sort.Strings(results)
+	return results
+}
+
+// mappingValue returns the value node of key in a mapping node, nil if not found
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// appendMappingValue adds a string entry to a mapping node
+func appendMappingValue(node *yaml.Node, key, value string) {
+	node.Content = append(node.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Value: value},
+	)
+}
+
+// removeMappingKey deletes key from a mapping node and returns its scalar value
+func removeMappingKey(node *yaml.Node, key string) string {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			value := node.Content[i+1].Value
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return value
+		}
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractProvenance removes SOURCE_ANNOTATION and PATCHED_BY_ANNOTATION from a manifest built with Provenance,
+// and returns them by resource ID. Manifests without them are returned as is.
+func ExtractProvenance(mf []byte) ([]byte, map[string]models.ResourceOrigin, error) {
+	if !bytes.Contains(mf, []byte(SOURCE_ANNOTATION)) && !bytes.Contains(mf, []byte(PATCHED_BY_ANNOTATION)) {
+		return mf, nil, nil
+	}
+
+	origins := make(map[string]models.ResourceOrigin)
+	decoder := yaml.NewDecoder(bytes.NewReader(mf))
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse built manifest: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		metadata := mappingValue(doc.Content[0], "metadata")
+		annotations := mappingValue(metadata, "annotations")
+		source := removeMappingKey(annotations, SOURCE_ANNOTATION)
+		patchedBy := removeMappingKey(annotations, PATCHED_BY_ANNOTATION)
+		if annotations != nil && len(annotations.Content) == 0 {
+			removeMappingKey(metadata, "annotations")
+		}
+		if source != "" || patchedBy != "" {
+			var obj map[string]interface{}
+			if err := doc.Decode(&obj); err != nil {
+				return nil, nil, fmt.Errorf("failed to decode built resource: %w", err)
+			}
+			o := models.ResourceOrigin{SourceFile: source}
+			if patchedBy != "" {
+				o.PatchedBy = strings.Split(patchedBy, ",")
+			}
+			origins[manifest.NewResource(obj).ID()] = o
+		}
+
+		if err := encoder.Encode(&doc); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode built manifest: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), origins, nil
+}
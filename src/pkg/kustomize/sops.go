@@ -0,0 +1,199 @@
+package kustomize
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SOPS_MODE_METADATA keeps the SOPS-encrypted resources encrypted: their values are replaced by
+	// SOPS_REDACTED_VALUE and the metadata changing on every re-encryption is dropped, only keys and recipients diff
+	SOPS_MODE_METADATA = "metadata"
+	// SOPS_MODE_DECRYPT decrypts them with the sops binary (keys from its environment: SOPS_AGE_KEY_FILE, KMS
+	// credentials...), then replaces the decrypted values by a digest, so that diffs show which values changed
+	SOPS_MODE_DECRYPT = "decrypt"
+
+	// SOPS_METADATA_KEY is the top-level key of a SOPS-encrypted document
+	SOPS_METADATA_KEY = "sops"
+	// SOPS_REDACTED_VALUE replaces the encrypted values in metadata mode
+	SOPS_REDACTED_VALUE = "ENC[redacted]"
+	// SOPS_DIGEST_PREFIX prefixes the digest replacing a decrypted value in decrypt mode
+	SOPS_DIGEST_PREFIX = "sops-sha256:"
+)
+
+// SOPS_MODES are the modes of the SOPS-encrypted resources handling, empty leaves them as built
+var SOPS_MODES = []string{SOPS_MODE_METADATA, SOPS_MODE_DECRYPT}
+
+// SOPS_VOLATILE_KEYS are the SOPS metadata keys changing on every re-encryption, dropped in metadata mode
+var SOPS_VOLATILE_KEYS = []string{"mac", "lastmodified", "enc"}
+
+// sopsEncryptedValue matches a value encrypted by SOPS, e.g. ENC[AES256_GCM,data:...,iv:...,tag:...,type:str]
+var sopsEncryptedValue = regexp.MustCompile(`^ENC\[[A-Z0-9_]+,data:.*\]$`)
+
+// sopsDigestKey keys the digests of the decrypted values, random per run so that they can be compared between
+// the sides of a diff but not guessed from a dictionary across runs
+var sopsDigestKey = func() []byte {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key) // never fails
+	return key
+}()
+
+// handleSOPS applies the SOPS mode to the SOPS-encrypted documents of a built manifest. The whole manifest is
+// re-encoded in both modes, so the mode must be the same for both sides of a diff
+func (b *Builder) handleSOPS(ctx context.Context, manifest []byte) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse built manifest: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		root := doc.Content[0]
+		if metadata := mappingValue(root, SOPS_METADATA_KEY); metadata != nil && metadata.Kind == yaml.MappingNode {
+			switch b.SOPS {
+			case SOPS_MODE_METADATA:
+				redactSOPSValues(root)
+				for _, key := range SOPS_VOLATILE_KEYS {
+					removeSOPSKey(metadata, key)
+				}
+			case SOPS_MODE_DECRYPT:
+				decrypted, err := b.decryptSOPS(ctx, &doc)
+				if err != nil {
+					return nil, err
+				}
+				digestDecryptedValues(root, decrypted)
+				doc.Content[0] = decrypted
+			}
+		}
+		if err := encoder.Encode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to encode built manifest: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// redactSOPSValues replaces the encrypted values of a node, the SOPS metadata aside
+func redactSOPSValues(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if sopsEncryptedValue.MatchString(node.Value) {
+			node.Value, node.Tag, node.Style = SOPS_REDACTED_VALUE, "!!str", 0
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value != SOPS_METADATA_KEY {
+				redactSOPSValues(node.Content[i+1])
+			}
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			redactSOPSValues(child)
+		}
+	}
+}
+
+// removeSOPSKey deletes a key from the SOPS metadata, at any depth (e.g. the data keys of each recipient)
+func removeSOPSKey(node *yaml.Node, key string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		removeMappingKey(node, key)
+		for i := 1; i < len(node.Content); i += 2 {
+			removeSOPSKey(node.Content[i], key)
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			removeSOPSKey(child, key)
+		}
+	}
+}
+
+// decryptSOPS decrypts a SOPS-encrypted document with the sops binary. The MAC is not checked, kustomize
+// transformers (namespace, labels) change the document after its encryption
+func (b *Builder) decryptSOPS(ctx context.Context, doc *yaml.Node) (*yaml.Node, error) {
+	encoded, err := yaml.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SOPS document: %w", err)
+	}
+	// os.CreateTemp is private to the user, the decrypted content only goes through stdout
+	f, err := os.CreateTemp("", "sops-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SOPS document file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(f.Name())
+	}()
+	_, err = f.Write(encoded)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write SOPS document file: %w", err)
+	}
+
+	decrypted, err := sandbox.Output(ctx, b.Executor, sandbox.Command{
+		Name:   "sops",
+		Args:   []string{"--decrypt", "--ignore-mac", "--input-type", "yaml", "--output-type", "yaml", f.Name()},
+		Limits: b.ExecLimits,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sops decrypt failed: %w", err)
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(decrypted, &node); err != nil || len(node.Content) == 0 {
+		return nil, fmt.Errorf("failed to parse sops decrypt output: %v", err)
+	}
+	return node.Content[0], nil
+}
+
+// digestDecryptedValues replaces the values of the decrypted node that were encrypted in the original one by
+// their digest, the values left in clear text (unencrypted_suffix...) are kept
+func digestDecryptedValues(original, decrypted *yaml.Node) {
+	if original == nil || decrypted == nil {
+		return
+	}
+	switch {
+	case original.Kind == yaml.ScalarNode && decrypted.Kind == yaml.ScalarNode:
+		if sopsEncryptedValue.MatchString(original.Value) {
+			mac := hmac.New(sha256.New, sopsDigestKey)
+			mac.Write([]byte(decrypted.Value))
+			decrypted.Value = SOPS_DIGEST_PREFIX + hex.EncodeToString(mac.Sum(nil))[:16]
+			decrypted.Tag, decrypted.Style = "!!str", 0
+		}
+	case original.Kind == yaml.MappingNode && decrypted.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(decrypted.Content); i += 2 {
+			digestDecryptedValues(mappingValue(original, decrypted.Content[i].Value), decrypted.Content[i+1])
+		}
+	case original.Kind == yaml.SequenceNode && decrypted.Kind == yaml.SequenceNode:
+		for i, child := range decrypted.Content {
+			if i < len(original.Content) {
+				digestDecryptedValues(original.Content[i], child)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package kustomize
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+)
+
+const sopsManifest = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+stringData:
+  password: ENC[AES256_GCM,data:Zm9v,iv:aXY=,tag:dGFn,type:str]
+  user_unencrypted: admin
+sops:
+  age:
+    - recipient: age1abc
+      enc: |
+        -----BEGIN AGE ENCRYPTED FILE-----
+  lastmodified: "2026-01-01T00:00:00Z"
+  mac: ENC[AES256_GCM,data:bWFj,iv:aXY=,tag:dGFn,type:str]
+  version: 3.9.0
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  key: value
+`
+
+func TestHandleSOPS_Metadata(t *testing.T) {
+	b := &Builder{SOPS: SOPS_MODE_METADATA}
+	got, err := b.handleSOPS(context.Background(), []byte(sopsManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"password: " + SOPS_REDACTED_VALUE, "user_unencrypted: admin", "recipient: age1abc", "version: 3.9.0", "key: value"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("handleSOPS() does not contain %q:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"data:Zm9v", "lastmodified", "mac:", "AGE ENCRYPTED"} {
+		if strings.Contains(string(got), unwanted) {
+			t.Errorf("handleSOPS() still contains %q:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestHandleSOPS_Decrypt(t *testing.T) {
+	secret := "s3cret"
+	fake := &sandbox.FakeExecutor{Handler: func(cmd sandbox.Command) (*sandbox.Result, error) {
+		out := "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\n  namespace: prod\nstringData:\n  password: " + secret + "\n  user_unencrypted: admin\n"
+		return &sandbox.Result{Stdout: []byte(out)}, nil
+	}}
+	b := &Builder{SOPS: SOPS_MODE_DECRYPT, Executor: fake}
+	first, err := b.handleSOPS(context.Background(), []byte(sopsManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(first), secret) || strings.Contains(string(first), "sops:") ||
+		!strings.Contains(string(first), "password: "+SOPS_DIGEST_PREFIX) || !strings.Contains(string(first), "user_unencrypted: admin") {
+		t.Errorf("unexpected decrypted manifest:\n%s", first)
+	}
+	if cmd := fake.Commands()[0]; cmd.Name != "sops" || cmd.Args[0] != "--decrypt" {
+		t.Errorf("ran %s %v, want sops --decrypt", cmd.Name, cmd.Args)
+	}
+
+	// the same value has the same digest, another value another one
+	again, _ := b.handleSOPS(context.Background(), []byte(sopsManifest))
+	secret = "changed"
+	changed, _ := b.handleSOPS(context.Background(), []byte(sopsManifest))
+	if string(again) != string(first) || string(changed) == string(first) {
+		t.Errorf("expected digests to follow the decrypted values:\n%s\n%s", first, changed)
+	}
+}
@@ -0,0 +1,159 @@
+package kustomize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceSuggestionOf maps a suggested patch of a built resource to a one-line change of its source file
+// It applies to a single replace of a scalar that the source file declares with the built value on one line,
+// nil otherwise (e.g. the value is set by an overlay patch, or the resource is generated). The source file is
+// read from files, nil for the disk
+func SourceSuggestionOf(files fsys.FS, overlayPath string, origin models.ResourceOrigin, res manifest.Resource, patch []models.PatchOperation) *models.SourceSuggestion {
+	if origin.SourceFile == "" || len(patch) != 1 || patch[0].Op != "replace" {
+		return nil
+	}
+	op := patch[0]
+	switch op.Value.(type) {
+	case string, bool, float64, int:
+	default:
+		return nil
+	}
+	builtValue, ok := manifest.Lookup(res.Object, op.Path)
+	if !ok {
+		return nil
+	}
+	tokens, err := manifest.ParsePointer(op.Path)
+	if err != nil || len(tokens) == 0 {
+		return nil
+	}
+
+	source, err := fsys.OrOS(files).ReadFile(filepath.Join(overlayPath, origin.SourceFile))
+	if err != nil {
+		return nil
+	}
+	doc := sourceDocumentOf(source, res)
+	if doc == nil {
+		return nil
+	}
+	node := nodeAt(doc, tokens)
+	if node == nil || node.Kind != yaml.ScalarNode || node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return nil
+	}
+	var sourceValue interface{}
+	if err := node.Decode(&sourceValue); err != nil || fmt.Sprint(sourceValue) != fmt.Sprint(builtValue) {
+		return nil // the built value does not come from this line
+	}
+
+	lines := strings.Split(string(source), "\n")
+	if node.Line < 1 || node.Line > len(lines) {
+		return nil
+	}
+	line := []rune(strings.TrimSuffix(lines[node.Line-1], "\r"))
+	if node.Column < 1 || node.Column > len(line) {
+		return nil
+	}
+	scalar, err := encodeScalar(op.Value, node.Style)
+	if err != nil {
+		return nil
+	}
+	replacement := string(line[:node.Column-1]) + scalar
+	if node.LineComment != "" {
+		replacement += " " + node.LineComment
+	}
+	return &models.SourceSuggestion{File: origin.SourceFile, Line: node.Line, Replacement: replacement}
+}
+
+// sourceDocumentOf returns the document of a source file declaring the resource, nil if none or ambiguous
+// Names may differ by the prefix/suffix added by the overlays
+func sourceDocumentOf(source []byte, res manifest.Resource) *yaml.Node {
+	decoder := yaml.NewDecoder(bytes.NewReader(source))
+	var found *yaml.Node
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil || len(doc.Content) == 0 {
+			return nil
+		}
+		root := doc.Content[0]
+		kind := mappingValue(root, "kind")
+		name := mappingValue(mappingValue(root, "metadata"), "name")
+		if kind == nil || name == nil || kind.Value != res.Kind || name.Value == "" || !strings.Contains(res.Name, name.Value) {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = root
+	}
+	return found
+}
+
+// nodeAt returns the node at the JSON pointer tokens, nil if not found
+func nodeAt(node *yaml.Node, tokens []string) *yaml.Node {
+	for _, token := range tokens {
+		switch node.Kind {
+		case yaml.MappingNode:
+			node = mappingValue(node, token)
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(node.Content) {
+				return nil
+			}
+			node = node.Content[i]
+		default:
+			return nil
+		}
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// encodeScalar renders a scalar as YAML, keeping the quoting style of the replaced string
+func encodeScalar(value interface{}, style yaml.Style) (string, error) {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return "", err
+	}
+	if _, ok := value.(string); ok && style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		node.Style = style
+	}
+	out, err := yaml.Marshal(&node)
+	if err != nil {
+		return "", err
+	}
+	scalar := strings.TrimSuffix(string(out), "\n")
+	if strings.Contains(scalar, "\n") {
+		return "", fmt.Errorf("multi-line scalar")
+	}
+	return scalar, nil
+}
+
+// ReplaceLines replaces lines of a file content by their 1-based number, keeping its line endings
+func ReplaceLines(content []byte, replacements map[int]string) ([]byte, error) {
+	lines := strings.SplitAfter(string(content), "\n")
+	for line, replacement := range replacements {
+		if line < 1 || line > len(lines) || lines[line-1] == "" {
+			return nil, fmt.Errorf("line %d out of range", line)
+		}
+		original := lines[line-1]
+		ending := original[len(strings.TrimRight(original, "\r\n")):]
+		lines[line-1] = replacement + ending
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
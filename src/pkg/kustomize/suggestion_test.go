@@ -0,0 +1,87 @@
+package kustomize
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestSourceSuggestionOf(t *testing.T) {
+	dir := t.TempDir()
+	source := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  replicas: 1 # scaled by the overlays
+  template:
+    spec:
+      containers:
+        - name: app
+          image: "nginx:1.25"
+`
+	writeTree(t, dir, map[string]string{"base/deployment.yaml": source})
+	overlayPath := filepath.Join(dir, "overlays")
+	origin := models.ResourceOrigin{SourceFile: "../base/deployment.yaml"}
+	res := manifest.NewResource(map[string]interface{}{
+		"apiVersion": "apps/v1", "kind": "Deployment",
+		"metadata": map[string]interface{}{"name": "prod-my-app", "namespace": "prod"},
+		"spec": map[string]interface{}{"replicas": 1, "template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "app", "image": "nginx:1.25"}},
+		}}},
+	})
+
+	tests := []struct {
+		name  string
+		patch []models.PatchOperation
+		want  *models.SourceSuggestion
+	}{
+		{
+			name:  "scalar with line comment",
+			patch: []models.PatchOperation{{Op: "replace", Path: "/spec/replicas", Value: 2.0}},
+			want:  &models.SourceSuggestion{File: "../base/deployment.yaml", Line: 11, Replacement: "  replicas: 2 # scaled by the overlays"},
+		},
+		{
+			name:  "quoted string in a list",
+			patch: []models.PatchOperation{{Op: "replace", Path: "/spec/template/spec/containers/0/image", Value: "nginx:1.27"}},
+			want:  &models.SourceSuggestion{File: "../base/deployment.yaml", Line: 16, Replacement: `          image: "nginx:1.27"`},
+		},
+		{name: "not a replace", patch: []models.PatchOperation{{Op: "add", Path: "/spec/paused", Value: true}}},
+		{name: "not declared in the source", patch: []models.PatchOperation{{Op: "replace", Path: "/metadata/namespace", Value: "stg"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SourceSuggestionOf(nil, overlayPath, origin, res, tt.patch)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("SourceSuggestionOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// the overlays changed the value, fixing the source line would not fix the resource
+	res.Object["spec"].(map[string]interface{})["replicas"] = 3
+	if got := SourceSuggestionOf(nil, overlayPath, origin, res, tests[0].patch); got != nil {
+		t.Errorf("SourceSuggestionOf() = %+v for a value set by the overlays, want nil", got)
+	}
+}
+
+func TestReplaceLines(t *testing.T) {
+	content := []byte("a: 1\r\nb: 2\nc: 3")
+	got, err := ReplaceLines(content, map[int]string{1: "a: 10", 3: "c: 30"})
+	if err != nil {
+		t.Fatalf("ReplaceLines() error = %v", err)
+	}
+	if want := "a: 10\r\nb: 2\nc: 30"; string(got) != want {
+		t.Errorf("ReplaceLines() = %q, want %q", got, want)
+	}
+	if _, err := ReplaceLines(content, map[int]string{4: "d: 4"}); err == nil {
+		t.Errorf("ReplaceLines() out of range line: expected an error")
+	}
+}
@@ -0,0 +1,85 @@
+package kustomize
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// wrapperOptions are the settings applied on top of an overlay by a wrapper kustomization
+type wrapperOptions struct {
+	Namespace     string   // namespace transformer, empty to keep the overlay namespaces
+	BuildMetadata []string // e.g. "originAnnotations", "transformerAnnotations"
+}
+
+// WRAPPER_DIR_NAME is the directory of the wrapper kustomization in the in-memory copies, one per build
+const WRAPPER_DIR_NAME = "kustomzchk-wrapper"
+
+// wrapOverlay writes a kustomization referencing the overlay in a temp directory
+// returns the directory to build and a cleanup function
+func wrapOverlay(overlayPath string, options wrapperOptions) (string, func(), error) {
+	wrapperDir, err := os.MkdirTemp("", WRAPPER_DIR_NAME+"-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create wrapper kustomization directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(wrapperDir) }
+
+	content, err := wrapperKustomization(wrapperDir, overlayPath, options)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	if err := os.WriteFile(filepath.Join(wrapperDir, KUSTOMIZE_FILE_NAMES[0]), content, 0644); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write wrapper kustomization: %w", err)
+	}
+	return wrapperDir, cleanup, nil
+}
+
+// wrapOverlayInMemory writes the wrapper kustomization in the in-memory copy of a krusty build
+// returns the directory to build, removed with the copy
+func wrapOverlayInMemory(memFS filesys.FileSystem, overlayPath string, options wrapperOptions) (string, error) {
+	wrapperDir := filepath.Join(os.TempDir(), WRAPPER_DIR_NAME)
+	content, err := wrapperKustomization(wrapperDir, overlayPath, options)
+	if err != nil {
+		return "", err
+	}
+	if err := memFS.WriteFile(filepath.Join(wrapperDir, KUSTOMIZE_FILE_NAMES[0]), content); err != nil {
+		return "", fmt.Errorf("failed to write wrapper kustomization: %w", err)
+	}
+	return wrapperDir, nil
+}
+
+// wrapperKustomization encodes the kustomization of a wrapper directory referencing the overlay
+func wrapperKustomization(wrapperDir, overlayPath string, options wrapperOptions) ([]byte, error) {
+	absOverlayPath, err := filepath.Abs(overlayPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve overlay path: %w", err)
+	}
+	// The overlay is referenced relative to the wrapper, or absolute when there is no relative path
+	// (on Windows, the temp dir and the overlay can be on different volumes)
+	overlayRef := absOverlayPath
+	if relOverlayPath, err := filepath.Rel(wrapperDir, absOverlayPath); err == nil {
+		overlayRef = relOverlayPath
+	}
+
+	kustomization := map[string]interface{}{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  []string{filepath.ToSlash(overlayRef)},
+	}
+	if options.Namespace != "" {
+		kustomization["namespace"] = options.Namespace
+	}
+	if len(options.BuildMetadata) > 0 {
+		kustomization["buildMetadata"] = options.BuildMetadata
+	}
+	content, err := yaml.Marshal(kustomization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode wrapper kustomization: %w", err)
+	}
+	return content, nil
+}
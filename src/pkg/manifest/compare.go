@@ -0,0 +1,50 @@
+package manifest
+
+import "reflect"
+
+// Change pairs the before and after versions of a resource that differs between two manifests
+// Before is nil for added resources, After is nil for removed resources
+type Change struct {
+	ID     string
+	Before *Resource
+	After  *Resource
+}
+
+// Current returns the after version of the resource, or the before version if it was removed
+func (c Change) Current() Resource {
+	if c.After != nil {
+		return *c.After
+	}
+	return *c.Before
+}
+
+// Compare matches resources by ID and returns the ones added, removed or modified
+// Order follows the after manifest, removed resources come last in before manifest order
+func Compare(before, after []Resource) []Change {
+	beforeByID := make(map[string]*Resource, len(before))
+	for i := range before {
+		beforeByID[before[i].ID()] = &before[i]
+	}
+
+	changes := []Change{}
+	seen := make(map[string]bool, len(after))
+	for i := range after {
+		id := after[i].ID()
+		seen[id] = true
+		prev, ok := beforeByID[id]
+		if !ok {
+			changes = append(changes, Change{ID: id, After: &after[i]})
+			continue
+		}
+		if !reflect.DeepEqual(prev.Object, after[i].Object) {
+			changes = append(changes, Change{ID: id, Before: prev, After: &after[i]})
+		}
+	}
+	for i := range before {
+		id := before[i].ID()
+		if !seen[id] {
+			changes = append(changes, Change{ID: id, Before: &before[i]})
+		}
+	}
+	return changes
+}
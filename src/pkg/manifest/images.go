@@ -0,0 +1,71 @@
+package manifest
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DEFAULT_REGISTRY is the registry of image references without a registry host
+const DEFAULT_REGISTRY = "docker.io"
+
+// containerListKeys are the pod spec fields listing containers
+var containerListKeys = map[string]bool{"containers": true, "initContainers": true, "ephemeralContainers": true}
+
+// IsContainerList reports whether a pod spec field lists containers, e.g. "initContainers"
+func IsContainerList(key string) bool {
+	return containerListKeys[key]
+}
+
+// ImageRef is a parsed container image reference, e.g. "ghcr.io/acme/web:v1@sha256:..."
+type ImageRef struct {
+	Name     string // reference without tag and digest, as written ("ghcr.io/acme/web")
+	Registry string // registry host, DEFAULT_REGISTRY if the reference has none
+	Tag      string // empty if none
+	Digest   string // "sha256:...", empty if none
+}
+
+// ParseImage splits an image reference into its name, registry, tag and digest
+func ParseImage(image string) ImageRef {
+	name, digest, _ := strings.Cut(image, "@")
+	ref := ImageRef{Name: name, Digest: digest, Registry: DEFAULT_REGISTRY}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Name, ref.Tag = name[:i], name[i+1:]
+	}
+	// the first path component is a registry host only if it looks like one, "acme/web" is on Docker Hub
+	if host, _, ok := strings.Cut(ref.Name, "/"); ok && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		ref.Registry = host
+	}
+	return ref
+}
+
+// ContainerImage is the image of a container of a resource
+type ContainerImage struct {
+	Container string
+	Image     string
+}
+
+// ContainerImages returns the images of the containers (and init and ephemeral containers) found anywhere in
+// a decoded object, so that pods, workload templates and custom resources embedding pod specs are all covered
+func ContainerImages(obj map[string]interface{}) []ContainerImage {
+	var images []ContainerImage
+	var walk func(value interface{}, inContainers bool)
+	walk = func(value interface{}, inContainers bool) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if image, ok := v["image"].(string); ok && inContainers {
+				name, _ := v["name"].(string)
+				images = append(images, ContainerImage{Container: name, Image: image})
+			}
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				walk(v[key], containerListKeys[key])
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child, inContainers)
+			}
+		}
+	}
+	walk(obj, false)
+	return images
+}
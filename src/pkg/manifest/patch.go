@@ -0,0 +1,241 @@
+package manifest
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// ParsePointer splits an RFC 6901 JSON pointer into its unescaped tokens, "" is the whole document
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q: must start with '/'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// Lookup returns the value at a JSON pointer of a decoded document, ok is false if not found
+func Lookup(obj map[string]interface{}, pointer string) (value interface{}, ok bool) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, false
+	}
+	value, err = getAt(obj, tokens)
+	return value, err == nil
+}
+
+// ApplyPatch applies RFC 6902 operations to a copy of a decoded document, obj is left untouched
+func ApplyPatch(obj map[string]interface{}, ops []models.PatchOperation) (map[string]interface{}, error) {
+	var doc interface{} = deepCopy(obj)
+	for i, op := range ops {
+		var err error
+		doc, err = applyOperation(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	patched, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("patched document is not an object")
+	}
+	return patched, nil
+}
+
+func applyOperation(doc interface{}, op models.PatchOperation) (interface{}, error) {
+	tokens, err := ParsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add":
+		return setAt(doc, tokens, deepCopy(op.Value), true)
+	case "replace":
+		if _, err := getAt(doc, tokens); err != nil {
+			return nil, err
+		}
+		return setAt(doc, tokens, deepCopy(op.Value), false)
+	case "remove":
+		return removeAt(doc, tokens)
+	case "test":
+		current, err := getAt(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !equalValues(current, op.Value) {
+			return nil, fmt.Errorf("test failed: value is %v", current)
+		}
+		return doc, nil
+	case "move", "copy":
+		fromTokens, err := ParsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := getAt(doc, fromTokens)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if doc, err = removeAt(doc, fromTokens); err != nil {
+				return nil, err
+			}
+		}
+		return setAt(doc, tokens, deepCopy(value), true)
+	default:
+		return nil, fmt.Errorf("unsupported operation %q", op.Op)
+	}
+}
+
+// getAt returns the value at the pointer tokens
+func getAt(doc interface{}, tokens []string) (interface{}, error) {
+	current := doc
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %q", token)
+			}
+			current = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("path not found: %q is not in an object or array", token)
+		}
+	}
+	return current, nil
+}
+
+// setAt sets the value at the pointer tokens, in arrays insert adds an element instead of replacing it
+// returns: the updated document (a new value when the whole document or an array is replaced)
+func setAt(doc interface{}, tokens []string, value interface{}, insert bool) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parent, err := getAt(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return doc, nil
+	case []interface{}:
+		var updated []interface{}
+		if insert {
+			i := len(node)
+			if last != "-" {
+				if i, err = arrayIndex(last, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			updated = append(append(append([]interface{}{}, node[:i]...), value), node[i:]...)
+		} else {
+			i, err := arrayIndex(last, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			updated = append([]interface{}{}, node...)
+			updated[i] = value
+		}
+		return setAt(doc, tokens[:len(tokens)-1], updated, false)
+	default:
+		return nil, fmt.Errorf("cannot set %q: parent is not an object or array", last)
+	}
+}
+
+func removeAt(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot remove the whole document")
+	}
+	parent, err := getAt(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := node[last]; !ok {
+			return nil, fmt.Errorf("path not found: %q", last)
+		}
+		delete(node, last)
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated := append(append([]interface{}{}, node[:i]...), node[i+1:]...)
+		return setAt(doc, tokens[:len(tokens)-1], updated, false)
+	default:
+		return nil, fmt.Errorf("cannot remove %q: parent is not an object or array", last)
+	}
+}
+
+// arrayIndex parses an array index token, accepted up to max
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+// equalValues compares decoded values, numbers by value whatever their decoded type (JSON float64, YAML int)
+func equalValues(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			copied[key] = deepCopy(value)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(node))
+		for i, value := range node {
+			copied[i] = deepCopy(value)
+		}
+		return copied
+	default:
+		return v
+	}
+}
@@ -0,0 +1,72 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestApplyPatch(t *testing.T) {
+	newObj := func() map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "app", "labels": map[string]interface{}{"app.kubernetes.io/name": "app"}},
+			"spec":     map[string]interface{}{"replicas": 1, "args": []interface{}{"a", "b"}},
+		}
+	}
+	tests := []struct {
+		name    string
+		ops     []models.PatchOperation
+		check   func(map[string]interface{}) interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:  "replace",
+			ops:   []models.PatchOperation{{Op: "replace", Path: "/spec/replicas", Value: 2.0}},
+			check: func(o map[string]interface{}) interface{} { return Nested(o, "spec", "replicas") },
+			want:  2.0,
+		},
+		{
+			name: "add escaped key",
+			ops:  []models.PatchOperation{{Op: "add", Path: "/metadata/labels/app.kubernetes.io~1part-of", Value: "shop"}},
+			check: func(o map[string]interface{}) interface{} {
+				return NestedString(o, "metadata", "labels", "app.kubernetes.io/part-of")
+			},
+			want: "shop",
+		},
+		{
+			name:  "add to array end and remove",
+			ops:   []models.PatchOperation{{Op: "add", Path: "/spec/args/-", Value: "c"}, {Op: "remove", Path: "/spec/args/0"}},
+			check: func(o map[string]interface{}) interface{} { return NestedSlice(o, "spec", "args") },
+			want:  []interface{}{"b", "c"},
+		},
+		{
+			name:  "test passes across number types",
+			ops:   []models.PatchOperation{{Op: "test", Path: "/spec/replicas", Value: 1.0}},
+			check: func(o map[string]interface{}) interface{} { return Nested(o, "spec", "replicas") },
+			want:  1,
+		},
+		{name: "replace missing path", ops: []models.PatchOperation{{Op: "replace", Path: "/spec/paused", Value: true}}, wantErr: true},
+		{name: "array index out of bounds", ops: []models.PatchOperation{{Op: "remove", Path: "/spec/args/2"}}, wantErr: true},
+		{name: "test fails", ops: []models.PatchOperation{{Op: "test", Path: "/spec/replicas", Value: 3}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := newObj()
+			got, err := ApplyPatch(obj, tt.ops)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(obj, newObj()) {
+				t.Errorf("ApplyPatch() modified its input: %v", obj)
+			}
+			if tt.wantErr {
+				return
+			}
+			if value := tt.check(got); !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("ApplyPatch() value = %#v, want %#v", value, tt.want)
+			}
+		})
+	}
+}
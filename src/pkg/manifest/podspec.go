@@ -0,0 +1,48 @@
+package manifest
+
+import (
+	"maps"
+	"slices"
+)
+
+// PodSpecs returns the pod specs found anywhere in a decoded object, the maps with a containers list, so that pods,
+// workload templates and custom resources embedding pod specs are all covered
+func PodSpecs(obj map[string]interface{}) []map[string]interface{} {
+	var specs []map[string]interface{}
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if _, ok := v["containers"].([]interface{}); ok {
+				specs = append(specs, v)
+				return
+			}
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				walk(v[key])
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(obj)
+	return specs
+}
+
+// Containers returns the containers of a pod spec, followed by its init containers when withInit is set
+func Containers(podSpec map[string]interface{}, withInit bool) []map[string]interface{} {
+	keys := []string{"containers"}
+	if withInit {
+		keys = append(keys, "initContainers")
+	}
+	var containers []map[string]interface{}
+	for _, key := range keys {
+		for _, item := range NestedSlice(podSpec, key) {
+			if container, ok := item.(map[string]interface{}); ok {
+				containers = append(containers, container)
+			}
+		}
+	}
+	return containers
+}
@@ -0,0 +1,123 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Resource is a single Kubernetes object of a built manifest
+type Resource struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+	Object     map[string]interface{} // Full decoded document
+}
+
+// Parse decodes a multi-document YAML manifest (kustomize build output) into resources
+// Empty documents and documents without kind are ignored
+func Parse(data []byte) ([]Resource, error) {
+	resources := []Resource{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		var obj map[string]interface{}
+		err := decoder.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse manifest document %d: %w", i, err)
+		}
+		if obj == nil {
+			continue
+		}
+		res := NewResource(obj)
+		if res.Kind == "" {
+			continue
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// NewResource builds a Resource from a decoded object
+func NewResource(obj map[string]interface{}) Resource {
+	return Resource{
+		APIVersion: NestedString(obj, "apiVersion"),
+		Kind:       NestedString(obj, "kind"),
+		Name:       NestedString(obj, "metadata", "name"),
+		Namespace:  NestedString(obj, "metadata", "namespace"),
+		Object:     obj,
+	}
+}
+
+// Marshal encodes resources back into a multi-document YAML manifest
+func Marshal(resources []Resource) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, res := range resources {
+		if err := encoder.Encode(res.Object); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", res.ID(), err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ID returns a stable identity of the resource: "Kind/namespace/name" (namespace omitted for cluster-scoped)
+func (r Resource) ID() string {
+	if r.Namespace == "" {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// Group returns the API group of the resource, empty for the core group
+func (r Resource) Group() string {
+	if idx := strings.Index(r.APIVersion, "/"); idx >= 0 {
+		return r.APIVersion[:idx]
+	}
+	return ""
+}
+
+// Nested returns the value at the given field path, nil if not found
+func Nested(obj map[string]interface{}, fields ...string) interface{} {
+	var current interface{} = obj
+	for _, field := range fields {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current, ok = m[field]
+		if !ok {
+			return nil
+		}
+	}
+	return current
+}
+
+// NestedString returns the string at the given field path, empty if not found or not a string
+func NestedString(obj map[string]interface{}, fields ...string) string {
+	s, _ := Nested(obj, fields...).(string)
+	return s
+}
+
+// NestedMap returns the map at the given field path, nil if not found or not a map
+func NestedMap(obj map[string]interface{}, fields ...string) map[string]interface{} {
+	m, _ := Nested(obj, fields...).(map[string]interface{})
+	return m
+}
+
+// NestedSlice returns the slice at the given field path, nil if not found or not a slice
+func NestedSlice(obj map[string]interface{}, fields ...string) []interface{} {
+	s, _ := Nested(obj, fields...).([]interface{})
+	return s
+}
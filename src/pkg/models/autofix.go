@@ -0,0 +1,23 @@
+package models
+
+const (
+	AutoFixModeCommit = "commit" // push the fixes to the head branch of the PR
+	AutoFixModePR     = "pr"     // push the fixes to a branch and open a PR against the head branch
+)
+
+// AutoFix is the outcome of --auto-fix: the suggested fixes applied to the source files and where they were pushed
+type AutoFix struct {
+	Mode           string       `json:"mode"`
+	Fixes          []AppliedFix `json:"fixes"`
+	Branch         string       `json:"branch,omitempty"`         // branch the fixes were pushed to
+	Commit         string       `json:"commit,omitempty"`         // SHA of the pushed commit
+	PullRequestURL string       `json:"pullRequestUrl,omitempty"` // follow-up PR (pr mode)
+	Error          string       `json:"error,omitempty"`          // why the fixes could not be pushed
+}
+
+// AppliedFix is a source line changed by --auto-fix
+type AppliedFix struct {
+	PolicyId string `json:"policyId"`
+	File     string `json:"file"` // relative to the repository root
+	Line     int    `json:"line"`
+}
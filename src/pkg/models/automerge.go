@@ -0,0 +1,24 @@
+package models
+
+const (
+	AutoMergeModeEnable = "enable" // enable GitHub auto-merge on the PR
+	AutoMergeModeLabel  = "label"  // add a label to the PR, for a merge bot to act on
+)
+
+const (
+	AutoMergeActionEnabled   = "enabled"
+	AutoMergeActionDisabled  = "disabled"
+	AutoMergeActionLabeled   = "labeled"
+	AutoMergeActionUnlabeled = "unlabeled"
+)
+
+// AutoMerge is the outcome of --auto-merge: whether the PR passed the merge gate and what was changed on it
+type AutoMerge struct {
+	Mode     string   `json:"mode"`
+	Method   string   `json:"method,omitempty"` // merge method (enable mode)
+	Label    string   `json:"label,omitempty"`  // label added or removed (label mode)
+	Eligible bool     `json:"eligible"`
+	Reasons  []string `json:"reasons,omitempty"` // why the PR is not eligible
+	Action   string   `json:"action,omitempty"`  // AutoMergeAction*, empty if the PR was already in the right state
+	Error    string   `json:"error,omitempty"`   // why the PR could not be updated
+}
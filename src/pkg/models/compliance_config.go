@@ -1,23 +1,274 @@
 package models
 
-import "time"
+import (
+	"path"
+	"time"
+)
 
 // ComplianceConfig represents the complete compliance configuration
 // - Policies: id -> PolicyConfig
 // - PolicyIDs: ordered list of policy IDs (preserves YAML order)
 type ComplianceConfig struct {
-	Policies  map[string]PolicyConfig `yaml:"policies"`
-	PolicyIDs []string                `yaml:"-"` // Not in YAML, populated during load
+	Policies     map[string]PolicyConfig `yaml:"policies"`
+	PolicyIDs    []string                `yaml:"-"`                      // Not in YAML, populated during load
+	ExternalData []ExternalDataSource    `yaml:"externalData,omitempty"` // Optional data fetched before evaluation, exposed as data.<name>
+
+	// Libraries are directories of helper Rego packages (relative to the policies directory) passed to conftest
+	// along with every policy, default "lib" when it exists
+	Libraries []string `yaml:"libraries,omitempty"`
+
+	// PolicySource tells where this policies directory is published, to link policy results to their code
+	PolicySource *PolicySourceConfig `yaml:"policySource,omitempty"`
+
+	// Profiles vary strictness and truncation by environment class, by profile name
+	// The first profile (in file order) matching an overlay key applies to it
+	Profiles     map[string]ProfileConfig `yaml:"profiles,omitempty"`
+	ProfileNames []string                 `yaml:"-"` // Not in YAML, populated during load
+
+	// RiskClassification overrides or extends the default risk categories of diff entries, by category name
+	RiskClassification map[string]RiskCategoryConfig `yaml:"riskClassification,omitempty"`
+
+	// Reviewers escalates the review of some changes to required teams (e.g. RBAC changes to the security team)
+	Reviewers []ReviewerRule `yaml:"reviewers,omitempty"`
+
+	// Environments rename and reorder the overlays in comments and reports, the first one matching an overlay key applies
+	Environments []EnvironmentConfig `yaml:"environments,omitempty"`
+
+	// Tiers raise the enforcement of the policies checked against the services of a tier (see service.yaml), by tier name
+	Tiers map[string]TierConfig `yaml:"tiers,omitempty"`
+
+	// ImageBumps recognizes the overlays changing only container image tags, to mark and relax them
+	ImageBumps *ImageBumpConfig `yaml:"imageBumps,omitempty"`
+
+	// PolicyPacks enables the built-in policy packs by pack name, each rule of a pack is a policy "<pack>.<rule>"
+	// following the user policies
+	PolicyPacks     map[string]PolicyPackConfig `yaml:"policyPacks,omitempty"`
+	PolicyPackNames []string                    `yaml:"-"` // Not in YAML, populated during load
+
+	// DestructiveChanges configures the built-in policy flagging the deletions of Namespaces, CRDs and PersistentVolumeClaims
+	DestructiveChanges *DestructiveChangesConfig `yaml:"destructiveChanges,omitempty"`
+
+	// OverrideTickets ties the override commands to a ticket, e.g. "/sp-override-ha PROJ-123"
+	OverrideTickets *OverrideTicketConfig `yaml:"overrideTickets,omitempty"`
+
+	// ArtifactEncryption are the recipients of the output files encrypted with --encrypt-artifacts
+	ArtifactEncryption *ArtifactEncryptionConfig `yaml:"artifactEncryption,omitempty"`
+
+	// Timezone is the IANA timezone of the audience (e.g. "Asia/Tokyo"), the times of the comment are rendered
+	// in it besides UTC
+	Timezone string `yaml:"timezone,omitempty"`
+}
+
+// ArtifactEncryptionConfig lists the recipients able to decrypt the output files, by encryption tool
+type ArtifactEncryptionConfig struct {
+	AgeRecipients []string `yaml:"ageRecipients,omitempty"` // age public keys, e.g. "age1..."
+	GPGRecipients []string `yaml:"gpgRecipients,omitempty"` // key IDs, fingerprints or emails of keys in the gpg keyring
+}
+
+// OverrideTicketConfig validates the ticket references of the override commands
+type OverrideTicketConfig struct {
+	// Pattern is the regular expression a ticket reference must match in full, e.g. "[A-Z][A-Z0-9]+-[0-9]+"
+	Pattern string `yaml:"pattern"`
+	// URL links a ticket in the reports, with a {ticket} placeholder, e.g. "https://acme.atlassian.net/browse/{ticket}"
+	URL string `yaml:"url,omitempty"`
+	// Required makes a ticket mandatory for overriding any policy, see OverrideConfig.RequireTicket for a single policy
+	Required bool `yaml:"required,omitempty"`
+	// Jira checks that each ticket exists (and is approved) in Jira, no check if nil
+	Jira *JiraConfig `yaml:"jira,omitempty"`
+}
+
+// JiraConfig is the Jira instance the override tickets are checked against
+type JiraConfig struct {
+	URL     string            `yaml:"url"`               // Base URL, e.g. https://acme.atlassian.net
+	Headers map[string]string `yaml:"headers,omitempty"` // Request headers (e.g. Authorization), values support $ENV expansion
+	// ApprovedStatuses are the statuses a ticket must be in for its override to be honored, any status if empty
+	ApprovedStatuses []string `yaml:"approvedStatuses,omitempty"`
+}
+
+// OverrideTicketRequired returns true if overriding a policy requires a ticket
+func (c *ComplianceConfig) OverrideTicketRequired(policyId string) bool {
+	if c.OverrideTickets == nil {
+		return false
+	}
+	return c.OverrideTickets.Required || c.Policies[policyId].Enforcement.Override.RequireTicket
+}
+
+// TierConfig is the enforcement strictness of the services of a tier
+type TierConfig struct {
+	// Escalate maps an enforcement level to the stricter level it is raised to, e.g. {WARNING: BLOCK}.
+	// Levels are raised once, {RECOMMEND: WARNING, WARNING: BLOCK} raises recommended policies to warning only
+	Escalate map[string]string `yaml:"escalate"`
+	// Policies limits the escalation to the policies matching these glob patterns of ids, default all
+	Policies []string `yaml:"policies,omitempty"`
+}
+
+// LevelOf returns the enforcement level of a policy for the tier, a nil tier keeps the level
+func (t *TierConfig) LevelOf(policyId, level string) string {
+	if t == nil {
+		return level
+	}
+	escalated, ok := t.Escalate[level]
+	if !ok {
+		return level
+	}
+	if len(t.Policies) == 0 {
+		return escalated
+	}
+	for _, pattern := range t.Policies {
+		if ok, _ := path.Match(pattern, policyId); ok {
+			return escalated
+		}
+	}
+	return level
+}
+
+// ReviewerRule requests reviews from teams when a changed resource or a failing policy of the PR matches it
+type ReviewerRule struct {
+	Teams      []string `yaml:"teams"`                // team slugs of the repository organization, e.g. "security" or "org/security"
+	Categories []string `yaml:"categories,omitempty"` // risk categories of changed resources, e.g. "rbac" (see riskClassification)
+	Kinds      []string `yaml:"kinds,omitempty"`      // kinds of changed resources, e.g. "NetworkPolicy"
+	Policies   []string `yaml:"policies,omitempty"`   // ids of failing policies, at any enforcement level
+}
+
+// ExternalDataSource defines an HTTP endpoint whose JSON response is injected as OPA data
+// e.g., an image vulnerability allowlist or a cluster quota API
+type ExternalDataSource struct {
+	Name    string            `yaml:"name"`              // Key under `data` in Rego, e.g. "allowlist" -> data.allowlist
+	URL     string            `yaml:"url"`               // Must return a JSON document
+	TTL     string            `yaml:"ttl,omitempty"`     // Cache duration (Go duration, e.g. "1h"), default 1h, "0" disables caching
+	Timeout string            `yaml:"timeout,omitempty"` // Request timeout (Go duration), default 10s
+	Headers map[string]string `yaml:"headers,omitempty"` // Extra request headers, values support $ENV expansion
+}
+
+// PolicySourceConfig locates the policies directory in a git repository or OCI artifact
+// Permalinks are built for GitHub, GitLab and Bitbucket hosts, other sources need a LinkTemplate
+type PolicySourceConfig struct {
+	Type         string `yaml:"type,omitempty"`         // "git" (default) or "oci"
+	URL          string `yaml:"url"`                    // e.g. https://github.com/org/policies, or oci://ghcr.io/org/policies
+	Ref          string `yaml:"ref"`                    // commit SHA, tag or digest; use an immutable ref for stable permalinks
+	Path         string `yaml:"path,omitempty"`         // directory of the compliance config within the source, default root
+	LinkTemplate string `yaml:"linkTemplate,omitempty"` // custom permalink with {url}, {ref}, {path} and {line} placeholders
+}
+
+const (
+	FailOnBlock     = "block"     // fail the run when a blocking policy fails
+	FailOnWarning   = "warning"   // ... or a warning one
+	FailOnRecommend = "recommend" // ... or a recommended one
+	FailOnNone      = "none"      // never fail the run on policy results (default)
+)
+
+// ProfileConfig is the behavior applied to a class of environments
+type ProfileConfig struct {
+	Environments []string `yaml:"environments,omitempty"` // glob patterns of overlay keys (e.g. "prod-*", "*/prod"), default the profile name
+	FailOn       string   `yaml:"failOn,omitempty"`       // block, warning, recommend or none
+	DiffLimit    int      `yaml:"diffLimit,omitempty"`    // max characters of diff shown inline in the comment, 0 keeps the default
+}
+
+// Matches tells whether the profile named name applies to an overlay key
+func (p ProfileConfig) Matches(name, overlayKey string) bool {
+	patterns := p.Environments
+	if len(patterns) == 0 {
+		patterns = []string{name}
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, overlayKey); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ProfileFor returns the first profile matching an overlay key, nil if none does
+func (c *ComplianceConfig) ProfileFor(overlayKey string) (string, *ProfileConfig) {
+	for _, name := range c.ProfileNames {
+		if profile := c.Profiles[name]; profile.Matches(name, overlayKey) {
+			return name, &profile
+		}
+	}
+	return "", nil
 }
 
 // PolicyConfig represents a single policy configuration
 type PolicyConfig struct {
-	Name         string            `yaml:"name"`
-	Description  string            `yaml:"description"`
-	Type         string            `yaml:"type"` // "opa" only for now
-	FilePath     string            `yaml:"filePath"`
-	ExternalLink string            `yaml:"externalLink,omitempty"` // Optional link to policy documentation
-	Enforcement  EnforcementConfig `yaml:"enforcement"`
+	Name         string                    `yaml:"name"`
+	Description  string                    `yaml:"description"`
+	Type         string                    `yaml:"type"`                   // "opa", "images", "metadata" or "availability" for the built-in policies, or "manual" for a checklist item
+	FilePath     string                    `yaml:"filePath"`               // Rego file of an "opa" policy
+	Images       *ImagePolicyConfig        `yaml:"images,omitempty"`       // Settings of an "images" policy
+	Metadata     *MetadataPolicyConfig     `yaml:"metadata,omitempty"`     // Rules of a "metadata" policy
+	Availability *AvailabilityPolicyConfig `yaml:"availability,omitempty"` // Settings of an "availability" policy, optional
+	Manual       *ManualPolicyConfig       `yaml:"manual,omitempty"`       // Checklist item of a "manual" policy
+	Namespaces   []string                  `yaml:"namespaces,omitempty"`   // Rego packages to evaluate (e.g. "main"), default all packages of the file
+	Input        string                    `yaml:"input,omitempty"`        // Input of an "opa" policy: "overlay" (default) or "environments" for all overlays at once
+	ExternalLink string                    `yaml:"externalLink,omitempty"` // Optional link to policy documentation
+	AutoFix      bool                      `yaml:"autoFix,omitempty"`      // Let --auto-fix push the fixes suggested by the policy
+	Enforcement  EnforcementConfig         `yaml:"enforcement"`
+
+	PackRule string `yaml:"-"` // "<pack>.<rule>" of a policy registered from policyPacks, not in YAML
+}
+
+// ImagePolicyConfig configures the built-in "images" policy, checking container image references without Rego
+type ImagePolicyConfig struct {
+	// RequireDigest rejects images not pinned by digest (name@sha256:...)
+	RequireDigest bool `yaml:"requireDigest,omitempty"`
+	// DisallowedTags are the mutable tags rejected when not pinned by digest, default ["latest"]
+	// An image without tag counts as "latest"
+	DisallowedTags []string `yaml:"disallowedTags,omitempty"`
+	// AllowedRegistries are glob patterns of the registry hosts images may come from ("ghcr.io", "*.amazonaws.com"),
+	// or of image names when containing a slash ("ghcr.io/acme/*"), any registry if empty
+	AllowedRegistries []string `yaml:"allowedRegistries,omitempty"`
+}
+
+// MetadataPolicyConfig configures the built-in "metadata" policy, checking the labels and annotations the GitOps
+// controllers and the org conventions require (sync waves, prune policies, ownership labels) without Rego
+type MetadataPolicyConfig struct {
+	// Rules are checked in order, a resource is checked by every rule selecting it
+	Rules []MetadataRuleConfig `yaml:"rules"`
+}
+
+// MetadataRuleConfig requires labels and annotations on the resources it selects. The patterns are globs, or
+// regular expressions prefixed with "re:" (e.g. "re:-?[0-9]+"), matched in full
+type MetadataRuleConfig struct {
+	// Kinds and Namespaces are patterns of the kinds and namespaces of the selected resources, all if empty
+	// Cluster-scoped resources have an empty namespace, selected by no namespace pattern but "*"
+	Kinds      []string `yaml:"kinds,omitempty"`
+	Namespaces []string `yaml:"namespaces,omitempty"`
+	// Labels and Annotations are the required keys, each with the pattern of its value, empty for any value
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// AvailabilityPolicyConfig configures the built-in "availability" policy, checking that the replicas, the
+// HorizontalPodAutoscalers and the PodDisruptionBudgets of the workloads are coherent without Rego
+type AvailabilityPolicyConfig struct {
+	// RequirePDB requires a PodDisruptionBudget selecting the pods of every workload running more than one replica
+	RequirePDB bool `yaml:"requirePDB,omitempty"`
+}
+
+// PolicyPackConfig enables a built-in policy pack, its rules are enforced with Enforcement unless they configure theirs
+type PolicyPackConfig struct {
+	// Version pins the version of the pack: the rules added by its later versions are not run until it is bumped.
+	// Unpinned packs run the version shipped with the tool
+	Version int `yaml:"version,omitempty"`
+	// Enforcement is the default enforcement of the rules, without override comment: each rule is overridden with
+	// its own command, default "/sp-override-<pack>-<rule>"
+	Enforcement EnforcementConfig `yaml:"enforcement"`
+	// Rules disable or schedule the rules of the pack by rule name, all rules are enabled by default
+	Rules map[string]PolicyPackRuleConfig `yaml:"rules,omitempty"`
+}
+
+// PolicyPackRuleConfig configures a rule of a policy pack
+type PolicyPackRuleConfig struct {
+	Disabled    bool               `yaml:"disabled,omitempty"`
+	Enforcement *EnforcementConfig `yaml:"enforcement,omitempty"` // replaces the enforcement of the pack
+}
+
+// ManualPolicyConfig configures a "manual" policy, a checklist item of the PR comment that a reviewer ticks
+type ManualPolicyConfig struct {
+	// Item is the text of the checklist item, e.g. "A DBA reviewed the schema change"
+	Item string `yaml:"item"`
+	// Required items block the PR until ticked, the others are recommended. The enforcement dates are not used
+	Required bool `yaml:"required,omitempty"`
 }
 
 // EnforcementConfig defines when and how a policy should be enforced
@@ -30,5 +281,6 @@ type EnforcementConfig struct {
 
 // OverrideConfig defines how a policy can be overridden
 type OverrideConfig struct {
-	Comment string `yaml:"comment"` // e.g., "/sp-override-ha"
+	Comment       string `yaml:"comment"`                 // e.g., "/sp-override-ha"
+	RequireTicket bool   `yaml:"requireTicket,omitempty"` // the command must reference a ticket, see OverrideTicketConfig
 }
@@ -0,0 +1,20 @@
+package models
+
+const (
+	ComponentStatusUnchanged = "unchanged"
+	ComponentStatusModified  = "modified"
+	ComponentStatusAdded     = "added"   // only used by the after overlay
+	ComponentStatusRemoved   = "removed" // only used by the before overlay
+)
+
+// ComponentUsage is a kustomize component used by an overlay, and how it changed between before and after
+type ComponentUsage struct {
+	Path   string `json:"path"`   // relative to the overlay directory, e.g. "../../components/monitoring"
+	Status string `json:"status"` // unchanged, modified, added or removed
+}
+
+// ResourceOrigin records where a built resource comes from, paths are relative to the overlay directory
+type ResourceOrigin struct {
+	SourceFile string   `json:"sourceFile,omitempty"` // file declaring the resource, e.g. "../../base/deployment.yaml"
+	PatchedBy  []string `json:"patchedBy,omitempty"`  // kustomizations whose patches/transformers changed the resource
+}
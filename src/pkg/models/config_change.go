@@ -0,0 +1,17 @@
+package models
+
+const (
+	ConfigFieldImages   = "images"
+	ConfigFieldReplicas = "replicas"
+	ConfigFieldPatches  = "patches"
+)
+
+// ConfigChange is a change of a semantic field of the overlay kustomization itself (images, replicas, patches),
+// shown next to the rendered diff as a configuration-level summary
+type ConfigChange struct {
+	Field  string `json:"field"`            // images, replicas or patches
+	Name   string `json:"name"`             // image name, replicas target, or patch path/target
+	Action string `json:"action"`           // added, removed or modified (ResourceChange* values)
+	Before string `json:"before,omitempty"` // e.g. "nginx:1.25" for images, "3" for replicas, empty for patches
+	After  string `json:"after,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// DestructiveChangesConfig configures the built-in destructive changes policy, which flags the deletions of the
+// resources holding other resources or data whatever the configured policies. It is blocking by default
+type DestructiveChangesConfig struct {
+	// Disabled removes the policy
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Kinds are the kinds whose deletion is flagged, default Namespace, CustomResourceDefinition and PersistentVolumeClaim
+	Kinds []string `yaml:"kinds,omitempty"`
+	// Enforcement replaces the default blocking enforcement, its override comment defaults to
+	// "/sp-override-destructive-changes"
+	Enforcement *EnforcementConfig `yaml:"enforcement,omitempty"`
+}
+
+// DestructiveChanges are the deletions flagged by the destructive changes policy, surfaced at the top of the report
+type DestructiveChanges struct {
+	Changes         map[string][]ResourceChange `json:"changes"`                   // the removed resources by overlay key
+	OverrideCommand string                      `json:"overrideCommand,omitempty"` // the comment confirming the deletions
+	Overridden      bool                        `json:"overridden"`                // the deletions are confirmed by an override
+}
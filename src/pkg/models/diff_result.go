@@ -3,6 +3,7 @@ package models
 const (
 	DiffContentTypeText       = "text"
 	DiffContentTypeGHArtifact = "ext_ghartifact"
+	DiffContentTypeRedacted   = "redacted" // content withheld from the comment (--no-manifest-content-in-comment)
 )
 
 type DiffResult struct {
@@ -12,3 +13,19 @@ type DiffResult struct {
 	AddedLineCount   int
 	DeletedLineCount int
 }
+
+const (
+	DiffLineFileHeader = "file"    // "--- before" / "+++ after"
+	DiffLineHunkHeader = "hunk"    // "@@ -1,3 +1,3 @@"
+	DiffLineContext    = "context" // unchanged line
+	DiffLineAdded      = "added"
+	DiffLineDeleted    = "deleted"
+	DiffLineNote       = "note"     // "\\ No newline at end of file"
+	DiffLineModified   = "modified" // "! spec.replicas: 1 → 3" of the dyff style
+)
+
+// DiffLine is a line of a unified (or dyff) diff in a sink-neutral form, rendered per sink (markdown, ANSI, HTML)
+type DiffLine struct {
+	Kind string // DiffLine* constants
+	Text string // the full line, including its "+", "-" or " " prefix
+}
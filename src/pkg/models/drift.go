@@ -0,0 +1,25 @@
+package models
+
+// EnvironmentDriftReport is the configuration drift of a service between two environments of the same ref,
+// exported as drift.json. Resources are identified by Kind/name, their namespaces usually differing per environment
+type EnvironmentDriftReport struct {
+	From             string       `json:"from"` // overlay key of the source environment, e.g. stg
+	To               string       `json:"to"`   // overlay key of the promotion target, e.g. prod
+	Images           []ImageDrift `json:"images,omitempty"`
+	MissingResources []string     `json:"missingResources,omitempty"` // in From, not in To
+	ExtraResources   []string     `json:"extraResources,omitempty"`   // in To, not in From
+}
+
+// HasDrift returns true if the environments differ by any image or resource
+func (r *EnvironmentDriftReport) HasDrift() bool {
+	return len(r.Images) > 0 || len(r.MissingResources) > 0 || len(r.ExtraResources) > 0
+}
+
+// ImageDrift is a container whose image differs between the two environments
+type ImageDrift struct {
+	ResourceID string `json:"resourceId"` // Kind/name
+	Container  string `json:"container"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Behind     bool   `json:"behind"` // To has a lower semver tag of the same image than From
+}
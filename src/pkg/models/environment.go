@@ -0,0 +1,68 @@
+package models
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// EnvironmentConfig is the reviewer-facing name and position of the overlays matching it, so that
+// comments and reports don't leak the overlay directory naming (e.g. "prd" shown as "Production", last)
+type EnvironmentConfig struct {
+	Match       string `yaml:"match"`                 // glob of overlay keys (e.g. "*/prd"), or of one of their segments (e.g. "prd")
+	DisplayName string `yaml:"displayName,omitempty"` // replaces the matched overlay key, or only the matched segment
+	Order       int    `yaml:"order,omitempty"`       // overlays are shown by ascending order, then in build order (default 0)
+}
+
+// environmentFor returns the first environment config matching an overlay key, and the index of the
+// matched segment (-1 when the whole key matched), nil if none matches
+func (c *ComplianceConfig) environmentFor(overlayKey string) (*EnvironmentConfig, int) {
+	for i := range c.Environments {
+		env := &c.Environments[i]
+		if ok, _ := path.Match(env.Match, overlayKey); ok {
+			return env, -1
+		}
+		for j, segment := range strings.Split(overlayKey, "/") {
+			if ok, _ := path.Match(env.Match, segment); ok {
+				return env, j
+			}
+		}
+	}
+	return nil, -1
+}
+
+// DisplayNameOf returns the display name of an overlay key, the key itself if no environment config renames it
+func (c *ComplianceConfig) DisplayNameOf(overlayKey string) string {
+	env, segment := c.environmentFor(overlayKey)
+	if env == nil || env.DisplayName == "" {
+		return overlayKey
+	}
+	if segment < 0 {
+		return env.DisplayName
+	}
+	segments := strings.Split(overlayKey, "/")
+	segments[segment] = env.DisplayName
+	return strings.Join(segments, "/")
+}
+
+// SortOverlayKeys returns a copy of the overlay keys sorted by the order of their environment config,
+// keeping the given order between overlays of the same order
+func (c *ComplianceConfig) SortOverlayKeys(overlayKeys []string) []string {
+	order := func(overlayKey string) int {
+		if env, _ := c.environmentFor(overlayKey); env != nil {
+			return env.Order
+		}
+		return 0
+	}
+	sorted := slices.Clone(overlayKeys)
+	slices.SortStableFunc(sorted, func(a, b string) int { return order(a) - order(b) })
+	return sorted
+}
+
+// DisplayName returns the display name of an overlay key of the report, the key itself if it is not renamed
+func (d *ReportData) DisplayName(overlayKey string) string {
+	if name, ok := d.DisplayNames[overlayKey]; ok {
+		return name
+	}
+	return overlayKey
+}
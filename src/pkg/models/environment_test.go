@@ -0,0 +1,37 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComplianceConfig_Environments(t *testing.T) {
+	cfg := &ComplianceConfig{Environments: []EnvironmentConfig{
+		{Match: "*/prd", DisplayName: "Production", Order: 10},
+		{Match: "stg", DisplayName: "Staging"},
+		{Match: "sandbox", Order: -1},
+	}}
+
+	names := map[string]string{
+		"eu/prd":  "Production",
+		"prd":     "prd",
+		"eu/stg":  "eu/Staging",
+		"stg":     "Staging",
+		"sandbox": "sandbox",
+		"dev":     "dev",
+	}
+	for key, want := range names {
+		if got := cfg.DisplayNameOf(key); got != want {
+			t.Errorf("DisplayNameOf(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	keys := []string{"eu/prd", "dev", "eu/stg", "sandbox", "us/prd"}
+	want := []string{"sandbox", "dev", "eu/stg", "eu/prd", "us/prd"}
+	if got := cfg.SortOverlayKeys(keys); !reflect.DeepEqual(got, want) {
+		t.Errorf("SortOverlayKeys() = %v, want %v", got, want)
+	}
+	if keys[0] != "eu/prd" {
+		t.Errorf("SortOverlayKeys() modified its input: %v", keys)
+	}
+}
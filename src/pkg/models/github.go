@@ -4,17 +4,20 @@ import "time"
 
 // PullRequest represents GitHub pull request information
 type PullRequest struct {
-	Number  int
-	Title   string
-	Body    string
-	BaseSHA string
-	HeadSHA string
-	BaseRef string
-	HeadRef string
-	State   string
-	Merged  bool
-	Created time.Time
-	Updated time.Time
+	Number    int
+	Title     string
+	Body      string
+	BaseSHA   string
+	HeadSHA   string
+	BaseRef   string
+	HeadRef   string
+	State     string
+	Merged    bool
+	NodeID    string   // GraphQL ID
+	Labels    []string // label names
+	AutoMerge bool     // whether auto-merge is enabled
+	Created   time.Time
+	Updated   time.Time
 }
 
 // Comment represents a GitHub comment
@@ -25,3 +28,32 @@ type Comment struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// CheckRun represents a completed GitHub check run of a commit, required checks block merges on its conclusion
+type CheckRun struct {
+	Name        string
+	HeadSHA     string
+	Conclusion  string // CheckConclusion*
+	Title       string
+	Summary     string // markdown
+	DetailsURL  string // optional link to the workflow run
+	Annotations []CheckAnnotation
+}
+
+// CheckAnnotation represents an annotation of a check run on a line of a file
+type CheckAnnotation struct {
+	Path    string // relative to the repository root
+	Line    int
+	Level   string // CheckAnnotation*
+	Title   string
+	Message string
+}
+
+const (
+	CheckConclusionSuccess = "success"
+	CheckConclusionFailure = "failure"
+
+	CheckAnnotationFailure = "failure"
+	CheckAnnotationWarning = "warning"
+	CheckAnnotationNotice  = "notice"
+)
@@ -0,0 +1,22 @@
+package models
+
+const (
+	GitOpsKindHelmRelease    = "HelmRelease"
+	GitOpsKindApplicationSet = "ApplicationSet"
+)
+
+// GitOpsResource describes a GitOps controller CR found in a built manifest (Flux HelmRelease, Argo ApplicationSet)
+// whose final workloads are only known after the controller renders it
+type GitOpsResource struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+
+	// Source is a human readable reference of what gets rendered
+	// e.g. "https://charts.example.com podinfo@6.5.0" or "list generator (3 elements)"
+	Source string `json:"source"`
+
+	Rendered              bool   `json:"rendered"`                        // true if rendered outputs were added to the manifest
+	RenderedResourceCount int    `json:"renderedResourceCount,omitempty"` // number of resources added by rendering
+	RenderError           string `json:"renderError,omitempty"`           // reason rendering failed or is unsupported
+}
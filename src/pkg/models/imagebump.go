@@ -0,0 +1,19 @@
+package models
+
+// ImageBumpConfig recognizes routine image bumps: overlays whose only changes are container image updates
+type ImageBumpConfig struct {
+	// Repositories are glob patterns (path.Match) of the image repositories bumps may update, any if empty
+	Repositories []string `yaml:"repositories,omitempty"`
+	// Semver requires the new tag to be a higher semantic version than the previous one
+	Semver bool `yaml:"semver,omitempty"`
+	// RelaxPolicies are downgraded from blocking to warning in the environments of a routine image bump
+	RelaxPolicies []string `yaml:"relaxPolicies,omitempty"`
+}
+
+// ImageBump is a container image update of a routine image bump
+type ImageBump struct {
+	ResourceID string `json:"resourceId"`
+	Container  string `json:"container"`
+	Before     string `json:"before"`
+	After      string `json:"after"`
+}
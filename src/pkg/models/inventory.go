@@ -0,0 +1,13 @@
+package models
+
+// InventoryItem is a resource of the after manifests of an overlay, normalized for asset-tracking systems
+type InventoryItem struct {
+	OverlayKey string            `json:"overlayKey"`
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Images     []string          `json:"images,omitempty"`   // container images, containers first (then ephemeral and init containers), deduplicated
+	Replicas   *int              `json:"replicas,omitempty"` // spec.replicas when set
+	Labels     map[string]string `json:"labels,omitempty"`   // the configured key labels the resource has
+}
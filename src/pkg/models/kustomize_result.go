@@ -9,6 +9,15 @@ type BuildManifestResult struct {
 	// OverlayKeys preserves the ordered list of overlay keys
 	// This maintains the order specified in --kustomize-build-values or --environments
 	OverlayKeys []string
+
+	// SkippedOverlays are the overlays matched but left out of the build by --max-environments sampling
+	SkippedOverlays []SkippedOverlay
+}
+
+// SkippedOverlay is an overlay that was not checked, and why
+type SkippedOverlay struct {
+	OverlayKey string `json:"overlayKey"`
+	Reason     string `json:"reason"`
 }
 
 type BuildEnvManifestResult struct {
@@ -23,10 +32,42 @@ type BuildEnvManifestResult struct {
 	// FullBuildPath is the actual path used for kustomize build (only for dynamic mode)
 	FullBuildPath string
 
+	// Variables are the path variable values of the overlay (only for dynamic mode)
+	Variables map[string]string
+
+	// BeforeBuildPath and AfterBuildPath are the overlay directories built on each side, empty if not found
+	BeforeBuildPath string
+	AfterBuildPath  string
+
 	BeforeManifest []byte
 	AfterManifest  []byte
 	Skipped        bool   // true if overlay doesn't exist and was skipped
 	SkipReason     string // reason for skipping (e.g., "overlay not found")
+	BuildError     string // excerpt of the build error when the overlay failed and the run continued (also Skipped)
+
+	// GitOpsResources lists the HelmRelease/ApplicationSet found in the after manifest (and whether they were rendered)
+	GitOpsResources []GitOpsResource
+
+	// Components lists the kustomize components used by the overlay and whether they changed
+	Components []ComponentUsage
+
+	// ConfigChanges are the images/replicas/patches changes of the overlay kustomization itself
+	ConfigChanges []ConfigChange
+
+	// BeforeOrigins and AfterOrigins map resource IDs to their source files (provenance mode only)
+	BeforeOrigins map[string]ResourceOrigin
+	AfterOrigins  map[string]ResourceOrigin
+}
+
+// Origin returns the origin of a resource, looked up in the after manifest first, nil if unknown
+func (r BuildEnvManifestResult) Origin(resourceID string) *ResourceOrigin {
+	if o, ok := r.AfterOrigins[resourceID]; ok {
+		return &o
+	}
+	if o, ok := r.BeforeOrigins[resourceID]; ok {
+		return &o
+	}
+	return nil
 }
 
 type PolicyEvaluateResult struct {
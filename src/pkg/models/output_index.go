@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// OUTPUT_LAYOUT_VERSION is the version of the output directory layout described by index.json,
+// bumped when files are moved or renamed
+const OUTPUT_LAYOUT_VERSION = 1
+
+// OutputIndex is the index.json of an output directory, the files produced by a run and what they hold
+type OutputIndex struct {
+	LayoutVersion int          `json:"layoutVersion"`
+	Service       string       `json:"service,omitempty"`
+	BaseCommit    string       `json:"baseCommit"`
+	HeadCommit    string       `json:"headCommit"`
+	Timestamp     time.Time    `json:"timestamp"`
+	Files         []OutputFile `json:"files"`
+}
+
+// OutputFile is a file of the output directory, Path is relative to it with forward slashes
+type OutputFile struct {
+	Path       string `json:"path"`
+	Kind       string `json:"kind"`                 // OutputKind* constant
+	OverlayKey string `json:"overlayKey,omitempty"` // overlay of a manifest
+	Side       string `json:"side,omitempty"`       // ManifestSide* constant of a manifest
+	Gzip       bool   `json:"gzip,omitempty"`
+	Encrypted  string `json:"encrypted,omitempty"` // tool the file was encrypted with, "age" or "gpg"
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+}
+
+const (
+	OutputKindReport       = "report"
+	OutputKindMarkdown     = "markdown"
+	OutputKindHTML         = "html"
+	OutputKindPolicyMatrix = "policy-matrix"
+	OutputKindInventory    = "inventory"
+	OutputKindManifest     = "manifest"
+	OutputKindDiff         = "diff"
+	OutputKindOther        = "other"
+
+	ManifestSideBefore = "before"
+	ManifestSideAfter  = "after"
+)
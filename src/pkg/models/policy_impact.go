@@ -0,0 +1,20 @@
+package models
+
+// PolicyImpactReport is the result of a policy impact analysis, exported as impact.json
+type PolicyImpactReport struct {
+	Against  string         `json:"against"` // git ref of the old policies
+	Corpus   string         `json:"corpus"`
+	Overlays int            `json:"overlays"`
+	Changes  []PolicyImpact `json:"changes"`
+}
+
+// PolicyImpact is the change of the result of a policy on an overlay of the corpus, between the old and new policies
+type PolicyImpact struct {
+	Kind        string   `json:"kind"`       // policy.IMPACT_*
+	OverlayKey  string   `json:"overlayKey"` // <service>/<env>
+	PolicyId    string   `json:"policyId"`
+	PolicyName  string   `json:"policyName"`
+	BeforeLevel string   `json:"beforeLevel,omitempty"` // enforcement level with the old policies, empty if the policy did not exist
+	AfterLevel  string   `json:"afterLevel,omitempty"`  // ... with the new policies, empty if the policy was removed
+	Messages    []string `json:"messages,omitempty"`    // fail messages with the new policies
+}
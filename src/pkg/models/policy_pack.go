@@ -0,0 +1,27 @@
+package models
+
+// PolicyPack describes a built-in policy pack: its version, and in a report the version pinned by the compliance
+// config, the rules run, the rules of the later versions left out by the pin and the deprecations to migrate from
+type PolicyPack struct {
+	Name          string                  `json:"name"`
+	Version       int                     `json:"version"`                 // version shipped with the tool
+	PinnedVersion int                     `json:"pinnedVersion,omitempty"` // version of the compliance config, 0 if not pinned
+	Rules         []PolicyPackRule        `json:"rules"`
+	NewerRules    []PolicyPackRule        `json:"newerRules,omitempty"` // rules added after PinnedVersion, not run
+	Deprecations  []PolicyPackDeprecation `json:"deprecations,omitempty"`
+}
+
+// PolicyPackRule is a rule of a policy pack
+type PolicyPackRule struct {
+	ID    string `json:"id"` // policy id, "<pack>.<rule>"
+	Title string `json:"title"`
+	Since int    `json:"since"` // version of the pack that added the rule
+}
+
+// PolicyPackDeprecation is a deprecated rule of a policy pack, still run until it is removed, or renamed and
+// configured under its old name
+type PolicyPackDeprecation struct {
+	Rule       string `json:"rule"`                 // rule name
+	ReplacedBy string `json:"replacedBy,omitempty"` // rule name of the replacement, empty if the rule is dropped
+	Hint       string `json:"hint"`                 // migration hint
+}
@@ -0,0 +1,44 @@
+package models
+
+// PolicyContext is the evaluation context injected into policies as data.context,
+// so that a single policy can apply different thresholds per environment
+type PolicyContext struct {
+	Environment string `json:"environment"` // ENV path variable, or the environment/overlay key
+	OverlayKey  string `json:"overlayKey"`
+	Service     string `json:"service,omitempty"` // SERVICE path variable, or --service
+	Cluster     string `json:"cluster,omitempty"` // CLUSTER path variable
+
+	// Owner and Tier come from the service.yaml of the service, if any
+	Owner string `json:"owner,omitempty"`
+	Tier  string `json:"tier,omitempty"`
+
+	// Variables are all the path variable values of the overlay (dynamic paths only)
+	Variables map[string]string `json:"variables,omitempty"`
+
+	// RoutineImageBump is set when the overlay changes only container images (see the imageBumps config)
+	RoutineImageBump bool `json:"routineImageBump,omitempty"`
+
+	// PullRequest is set in github mode
+	PullRequest *PullRequestContext `json:"pullRequest,omitempty"`
+
+	// Admission is set in the admission mode, for the policies gating the cluster applies only
+	Admission *AdmissionContext `json:"admission,omitempty"`
+}
+
+// AdmissionContext is the admission request metadata of the policy context
+type AdmissionContext struct {
+	Operation string `json:"operation"` // CREATE, UPDATE or CONNECT
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	User      string `json:"user"`
+	DryRun    bool   `json:"dryRun,omitempty"`
+}
+
+// PullRequestContext is the pull request metadata of the policy context
+type PullRequestContext struct {
+	Repo    string `json:"repo"`
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	BaseRef string `json:"baseRef"`
+	HeadRef string `json:"headRef"`
+}
@@ -0,0 +1,33 @@
+package models
+
+// ReleaseReport is the consolidated "what changes in this release" report of the services of a repository
+// between two tags, exported as release.json
+type ReleaseReport struct {
+	BaseTag    string           `json:"baseTag"`
+	HeadTag    string           `json:"headTag"`
+	BaseCommit string           `json:"baseCommit"`
+	HeadCommit string           `json:"headCommit"`
+	Services   []ReleaseService `json:"services"`
+}
+
+// ReleaseService is the summary of the changes of a service in a release
+type ReleaseService struct {
+	Service      string               `json:"service"`
+	Environments []ReleaseEnvironment `json:"environments,omitempty"` // changed overlays only
+	Error        string               `json:"error,omitempty"`        // the service failed to build or evaluate
+}
+
+// ReleaseEnvironment is the summary of the changes of an overlay of a service in a release
+type ReleaseEnvironment struct {
+	OverlayKey       string `json:"overlayKey"`
+	AddedLineCount   int    `json:"addedLineCount"`
+	DeletedLineCount int    `json:"deletedLineCount"`
+
+	AddedResources    []string `json:"addedResources,omitempty"` // resource ids
+	RemovedResources  []string `json:"removedResources,omitempty"`
+	ModifiedResources []string `json:"modifiedResources,omitempty"`
+	HighRiskResources []string `json:"highRiskResources,omitempty"`
+
+	BlockingPolicies []string `json:"blockingPolicies,omitempty"` // names of the failing blocking policies
+	WarningPolicies  []string `json:"warningPolicies,omitempty"`  // names of the failing warning policies
+}
@@ -2,16 +2,29 @@ package models
 
 import "time"
 
+// REPORT_SCHEMA_VERSION is the report.json schema version written by this version of the tool.
+// Reports without a schemaVersion are version 1, see pkg/report for the migrations of older versions
+const REPORT_SCHEMA_VERSION = 2
+
 // ReportData represents the complete report data structure
 type ReportData struct {
+	// SchemaVersion is the report.json schema version, REPORT_SCHEMA_VERSION when written by this version
+	SchemaVersion int `json:"schemaVersion"`
+
 	// Service is kept for backward compatibility (legacy mode)
 	// For dynamic mode, this may be empty or contain the SERVICE variable value
 	Service string `json:"service,omitempty"`
 
+	// ServiceMetadata is the service.yaml of the service directory, nil if it has none
+	ServiceMetadata *ServiceMetadata `json:"serviceMetadata,omitempty"`
+
 	Timestamp  time.Time `json:"timestamp"`
 	BaseCommit string    `json:"baseCommit"`
 	HeadCommit string    `json:"headCommit"`
 
+	// Stale is set when the head of the pull request moved past HeadCommit while the run evaluated it
+	Stale *StaleResult `json:"stale,omitempty"`
+
 	// Environments is kept for backward compatibility (legacy mode)
 	// Contains environment names like ["stg", "prod"]
 	Environments []string `json:"environments,omitempty"`
@@ -21,6 +34,12 @@ type ReportData struct {
 	// For dynamic mode: combined variable values like ["alpha/stg", "alpha/prod"]
 	OverlayKeys []string `json:"overlayKeys,omitempty"`
 
+	// DisplayNames maps the overlay keys renamed by the compliance config environments to their display name
+	DisplayNames map[string]string `json:"displayNames,omitempty"`
+
+	// Timezone is the compliance config timezone the times are rendered in besides UTC, see FormatTime
+	Timezone string `json:"timezone,omitempty"`
+
 	// KustomizeBuildPath and KustomizeBuildValues store the build configuration (dynamic mode only)
 	KustomizeBuildPath   string `json:"kustomizeBuildPath,omitempty"`
 	KustomizeBuildValues string `json:"kustomizeBuildValues,omitempty"`
@@ -32,8 +51,125 @@ type ReportData struct {
 	// Manifest changes per overlay key (or environment in legacy mode)
 	ManifestChanges map[string]EnvironmentDiff `json:"manifestChanges"`
 
+	// High-risk resource changes (CRD, RBAC, namespace, cluster-scoped) per overlay key, surfaced at the top of the report
+	HighRiskChanges map[string][]ResourceChange `json:"highRiskChanges,omitempty"`
+
+	// Deletions of resources holding data flagged by the built-in destructive changes policy, surfaced at the top of the report
+	DestructiveChanges *DestructiveChanges `json:"destructiveChanges,omitempty"`
+
 	// Policy evaluation results
 	PolicyEvaluation PolicyEvaluation `json:"policyEvaluation"`
+
+	// GitOps controller resources (HelmRelease, ApplicationSet) per overlay key, found in the after manifests
+	GitOpsResources map[string][]GitOpsResource `json:"gitopsResources,omitempty"`
+
+	// Kustomize components used per overlay key, and whether they changed
+	Components map[string][]ComponentUsage `json:"components,omitempty"`
+
+	// ConfigChanges are the configuration-level changes of each overlay kustomization, by overlay key
+	ConfigChanges map[string][]ConfigChange `json:"configChanges,omitempty"`
+
+	// External data injected into policy evaluation (name, url, digest)
+	ExternalData []ExternalDataRecord `json:"externalData,omitempty"`
+
+	// SkippedOverlays lists the overlays that were matched but not checked, to keep the run bounded
+	SkippedOverlays []SkippedOverlay `json:"skippedOverlays,omitempty"`
+
+	// Profiles maps overlay keys to the name of the compliance config profile applied to them
+	Profiles map[string]string `json:"profiles,omitempty"`
+
+	// Checklist are the items of the "manual" policies, for reviewers to tick in the comment
+	Checklist []ChecklistItem `json:"checklist,omitempty"`
+
+	// PolicyPacks are the built-in policy packs enabled by the compliance config, their versions and deprecations
+	PolicyPacks []PolicyPack `json:"policyPacks,omitempty"`
+
+	// NextSteps is the "what to do next" footer, composed from the evaluation state
+	NextSteps []NextStep `json:"nextSteps,omitempty"`
+
+	// Errors are the failures of the run, the report is partial when set
+	Errors []RunError `json:"errors,omitempty"`
+
+	// AutoFix records the suggested fixes pushed by --auto-fix, nil if disabled or nothing to fix
+	AutoFix *AutoFix `json:"autoFix,omitempty"`
+
+	// ReviewerEscalations are the teams whose review the changes require, per the compliance config reviewers
+	ReviewerEscalations []ReviewerEscalation `json:"reviewerEscalations,omitempty"`
+
+	// AutoMerge records the merge gate decision of --auto-merge, nil if disabled
+	AutoMerge *AutoMerge `json:"autoMerge,omitempty"`
+
+	// Timings are the stage durations of the run, also set when the performance report is disabled
+	Timings *Timings `json:"timings,omitempty"`
+
+	// Sources are the versions of the policies and templates of the run, set by the server mode
+	Sources *Sources `json:"sources,omitempty"`
+}
+
+// Sources are the versions of the policies and templates a report was produced with
+type Sources struct {
+	Policies  *SourceVersion `json:"policies,omitempty"`
+	Templates *SourceVersion `json:"templates,omitempty"`
+}
+
+// SourceVersion is a version of a directory, git or OCI source of the server mode
+type SourceVersion struct {
+	Source  string `json:"source"`  // e.g. ./policies, git+https://github.com/org/policies?ref=main or oci://ghcr.io/org/policies:v1
+	Version string `json:"version"` // the commit SHA of a git source, else "sha256:<hex>" of its files
+}
+
+// StaleResult is a report evaluated for a commit that is no longer the head of its pull request
+type StaleResult struct {
+	EvaluatedCommit string `json:"evaluatedCommit"`
+	HeadCommit      string `json:"headCommit"` // the head of the pull request when the run finished
+}
+
+const (
+	EscalationTriggerCategory = "category" // a changed resource is in a risk category
+	EscalationTriggerKind     = "kind"     // a resource of a kind changed
+	EscalationTriggerPolicy   = "policy"   // a policy fails
+)
+
+// ReviewerEscalation is a team whose review the changes require, and why
+type ReviewerEscalation struct {
+	Team        string             `json:"team"`
+	Reasons     []EscalationReason `json:"reasons"`
+	MoreReasons int                `json:"moreReasons,omitempty"` // reasons left out of Reasons, to keep the comment short
+	Requested   bool               `json:"requested"`             // the review was requested on the PR (github mode)
+	Error       string             `json:"error,omitempty"`       // why the review could not be requested
+}
+
+// EscalationReason is a change or policy result matching a reviewer rule
+type EscalationReason struct {
+	Trigger    string `json:"trigger"` // EscalationTrigger*
+	Value      string `json:"value"`   // the matched category, kind or policy id
+	OverlayKey string `json:"overlayKey"`
+	ResourceID string `json:"resourceId,omitempty"` // the changed resource, for category and kind triggers
+}
+
+// RunError is a categorized failure of the run, with a short remediation hint
+type RunError struct {
+	Category string `json:"category"` // e.g. "BuildError", empty when uncategorized
+	Message  string `json:"message"`
+	Hint     string `json:"hint,omitempty"`
+
+	// OverlayKey and PolicyId locate the failed part of a run that continued past it (--on-error=continue)
+	OverlayKey string `json:"overlayKey,omitempty"`
+	PolicyId   string `json:"policyId,omitempty"`
+}
+
+const (
+	NextStepOverride    = "override"    // an override command unblocks a failing policy
+	NextStepFix         = "fix"         // a blocking policy fails and can't be overridden
+	NextStepEnforcement = "enforcement" // a failing policy will be enforced more strictly
+	NextStepArtifact    = "artifact"    // content is only available in the workflow artifacts
+	NextStepChecklist   = "checklist"   // a required checklist item is not ticked
+)
+
+// NextStep is an actionable item of the comment footer, Text is markdown
+type NextStep struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
 }
 
 // EnvironmentDiff represents diff data for a single environment
@@ -43,8 +179,23 @@ type EnvironmentDiff struct {
 	DeletedLineCount int `json:"deletedLineCount"`
 
 	ContentGHFilePath *string `json:"contentGHFilePath"` // file path in the runner's output directory if the diff is too long
-	ContentType       string  `json:"contentType"`       // "text" or "ext_ghartifact"
+	ContentType       string  `json:"contentType"`       // "text", "ext_ghartifact" or "redacted"
 	Content           string  `json:"content"`           // diff text OR artifact URL
+
+	// Lines is the text diff in a sink-neutral form, empty when the content is not a text diff
+	Lines []DiffLine `json:"-"`
+
+	// ResourceChanges lists the resources added, removed or modified, with their risk classification
+	ResourceChanges []ResourceChange `json:"resourceChanges,omitempty"`
+
+	// BlastRadius estimates the rollouts, availability and traffic impact of the changes
+	BlastRadius BlastRadius `json:"blastRadius"`
+
+	// PrunedResources are the resources removed from the overlay, pruned (or orphaned) by the GitOps controller on sync
+	PrunedResources []PrunedResource `json:"prunedResources,omitempty"`
+
+	// ImageBumps are the image updates of a routine image bump, set only when they are all the overlay changes
+	ImageBumps []ImageBump `json:"imageBumps,omitempty"`
 }
 
 // PolicyEvaluationSummary represents the overall policy evaluation results
@@ -54,6 +205,32 @@ type PolicyEvaluation struct {
 
 	// Detailed policy matrix
 	PolicyMatrix map[string]PolicyMatrix `json:"policyMatrix"`
+
+	// EvaluatedAt is the time enforcement levels were evaluated at, set only when it is not the current time (--evaluate-at)
+	EvaluatedAt *time.Time `json:"evaluatedAt,omitempty"`
+
+	// Overrides are the honored override and revocation commands of the PR comments, and the rejected overrides
+	// (e.g. without a required ticket), in comment order
+	Overrides []OverrideAction `json:"overrides,omitempty"`
+}
+
+const (
+	OverrideActionOverride = "override" // the policy is overridden
+	OverrideActionRevoke   = "revoke"   // the override of the policy is cancelled
+	OverrideActionRejected = "rejected" // the override is not honored, see Reason
+)
+
+// OverrideAction is an override or revocation command commented on the PR
+type OverrideAction struct {
+	PolicyId string    `json:"policyId"`
+	Action   string    `json:"action"` // OverrideActionOverride, OverrideActionRevoke or OverrideActionRejected
+	User     string    `json:"user"`
+	At       time.Time `json:"at"`
+
+	Ticket       string `json:"ticket,omitempty"`       // Ticket referenced by the override command
+	TicketURL    string `json:"ticketUrl,omitempty"`    // Link of the ticket, when overrideTickets.url is set
+	TicketStatus string `json:"ticketStatus,omitempty"` // Jira status of the ticket, when checked
+	Reason       string `json:"reason,omitempty"`       // Why a rejected override is not honored
 }
 
 type EnvironmentSummaryEnv struct {
@@ -86,6 +263,8 @@ type PolicyCounts struct {
 	OverriddenFailedCount   int `json:"overriddenFailedCount"`
 	NotInEffectSuccessCount int `json:"notInEffectSuccessCount"`
 	NotInEffectFailedCount  int `json:"notInEffectFailedCount"`
+
+	ErroredCount int `json:"erroredCount"` // number of policies whose evaluation errored, also counted as failed
 }
 
 // PolicyMatrix represents the detailed policy evaluation matrix
@@ -103,9 +282,37 @@ type PolicyResult struct {
 	PolicyId        string   `json:"policyId"`
 	PolicyName      string   `json:"policyName"`
 	ExternalLink    string   `json:"externalLink,omitempty"`    // Optional link to policy documentation
+	SourceLink      string   `json:"sourceLink,omitempty"`      // Permalink to the policy rule, when the policy source is known
 	OverrideCommand string   `json:"overrideCommand,omitempty"` // Override comment command (e.g., "/sp-override-ha")
 	IsPassing       bool     `json:"isPassing"`                 // true or false, if false it means FailMessages is not empty
 	FailMessages    []string `json:"failMessages"`
+	EvalError       string   `json:"evalError,omitempty"`     // excerpt of the evaluation error, the policy then counts as failing
+	Relaxed         bool     `json:"relaxed,omitempty"`       // downgraded from blocking to warning on a routine image bump
+	EscalatedFrom   string   `json:"escalatedFrom,omitempty"` // enforcement level before the tier of the service raised it
+	OverriddenBy    string   `json:"overriddenBy,omitempty"`  // user of the honored override command, if overridden
+
+	// Violations pairs each fail message with the resource it was raised for, when known
+	Violations []PolicyViolation `json:"violations,omitempty"`
+}
+
+// ChecklistItem is the checklist item of a "manual" policy, rendered in the PR comment for a reviewer to tick
+type ChecklistItem struct {
+	PolicyId string `json:"policyId"`
+	Text     string `json:"text"`
+	Required bool   `json:"required"`
+	Checked  bool   `json:"checked"` // ticked in the comment of the previous run
+}
+
+// PolicyViolation is a single fail message of a policy, linked to the violating resource
+type PolicyViolation struct {
+	Message    string `json:"message"`
+	ResourceID string `json:"resourceId,omitempty"` // manifest.Resource ID, empty if the resource could not be identified
+	DiffAnchor string `json:"diffAnchor,omitempty"` // anchor of the resource in the diff section, empty if the resource did not change
+	// Environment is the overlay a violation of an "environments" input policy is raised for, empty for all of them
+	Environment string `json:"environment,omitempty"`
+
+	Origin     *ResourceOrigin `json:"origin,omitempty"`     // source file of the resource (provenance mode only)
+	Suggestion *Suggestion     `json:"suggestion,omitempty"` // fix suggested by the policy, if any
 }
 
 // ReportTemplateData represents the data structure for template rendering
@@ -113,3 +320,13 @@ type ReportTemplateData struct {
 	ReportData
 	RenderedMarkdown string `json:"renderedMarkdown"`
 }
+
+// ExternalDataRecord records which external data was injected into the evaluation, for reproducibility
+type ExternalDataRecord struct {
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Digest    string    `json:"digest"` // sha256 of the fetched document, e.g. "sha256:ab12..."
+	SizeBytes int       `json:"sizeBytes"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	FromCache bool      `json:"fromCache"`
+}
@@ -0,0 +1,66 @@
+package models
+
+const (
+	ResourceChangeAdded    = "added"
+	ResourceChangeRemoved  = "removed"
+	ResourceChangeModified = "modified"
+)
+
+// ResourceChange describes a single resource that differs between the before and after manifests
+type ResourceChange struct {
+	ID         string   `json:"id"` // "Kind/namespace/name", shared with policy violations
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Name       string   `json:"name"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Action     string   `json:"action"`               // added, removed or modified
+	Categories []string `json:"categories,omitempty"` // risk categories matched, e.g. ["rbac", "cluster-scoped"]
+	HighRisk   bool     `json:"highRisk"`             // true if any matched category is high-risk
+
+	Anchor           string   `json:"anchor,omitempty"`           // HTML anchor of the resource in the diff section
+	ViolatedPolicies []string `json:"violatedPolicies,omitempty"` // names of the policies failing on this resource
+
+	Origin *ResourceOrigin `json:"origin,omitempty"` // source file of the resource (provenance mode only)
+}
+
+// RiskCategoryConfig defines which resources belong to a risk category
+// A resource matches if its kind is in Kinds or its API group is in APIGroups
+type RiskCategoryConfig struct {
+	Kinds     []string `yaml:"kinds,omitempty"`
+	APIGroups []string `yaml:"apiGroups,omitempty"` // e.g. "rbac.authorization.k8s.io", "" is the core group
+	HighRisk  bool     `yaml:"highRisk"`            // changes are surfaced in the high-risk call-out
+}
+
+// BlastRadius estimates the runtime impact of merging the changes of an overlay
+type BlastRadius struct {
+	// Workloads (Deployment/StatefulSet/DaemonSet) whose pod template changed, merging triggers a rollout
+	RolloutWorkloads []string `json:"rolloutWorkloads,omitempty"`
+	// PodDisruptionBudget and HorizontalPodAutoscaler changes, affecting availability and scaling
+	AvailabilityChanges []string `json:"availabilityChanges,omitempty"`
+	// Service and Ingress (or Gateway API route) changes, affecting traffic routing
+	TrafficChanges []string `json:"trafficChanges,omitempty"`
+}
+
+// TriggersRollout is true if merging restarts at least one workload
+func (b BlastRadius) TriggersRollout() bool {
+	return len(b.RolloutWorkloads) > 0
+}
+
+// IsEmpty is true if no change with a runtime impact was found
+func (b BlastRadius) IsEmpty() bool {
+	return len(b.RolloutWorkloads) == 0 && len(b.AvailabilityChanges) == 0 && len(b.TrafficChanges) == 0
+}
+
+// PrunedResource is a resource of the before manifest absent from the after manifest: the GitOps controller deletes
+// it from the cluster on sync, unless pruning is disabled for it and it is left orphaned
+type PrunedResource struct {
+	ID string `json:"id"` // "Kind/namespace/name", shared with policy violations
+	// PruneDisabledBy is the annotation disabling the pruning of the resource, e.g.
+	// "argocd.argoproj.io/sync-options: Prune=false", empty if it is pruned
+	PruneDisabledBy string `json:"pruneDisabledBy,omitempty"`
+}
+
+// Orphaned is true if the resource stays in the cluster, no longer managed by the GitOps controller
+func (p PrunedResource) Orphaned() bool {
+	return p.PruneDisabledBy != ""
+}
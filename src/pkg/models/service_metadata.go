@@ -0,0 +1,46 @@
+package models
+
+import "path"
+
+// ServiceMetadata is the optional service.yaml of a service directory, describing the service to reviewers
+// and scoping the policies checked against it
+type ServiceMetadata struct {
+	DisplayName  string          `yaml:"displayName,omitempty" json:"displayName,omitempty"`   // shown instead of the service directory name
+	Owner        string          `yaml:"owner,omitempty" json:"owner,omitempty"`               // owning team, e.g. "payments" or "org/payments"
+	Tier         string          `yaml:"tier,omitempty" json:"tier,omitempty"`                 // criticality, e.g. "tier-1"
+	SlackChannel string          `yaml:"slackChannel,omitempty" json:"slackChannel,omitempty"` // e.g. "#payments-alerts"
+	Policies     *PolicySelector `yaml:"policies,omitempty" json:"policies,omitempty"`         // policies checked against the service, default all
+}
+
+// PolicySelector selects policies by glob patterns of their ids
+type PolicySelector struct {
+	Include []string `yaml:"include,omitempty" json:"include,omitempty"` // policies matching any pattern, default all
+	Exclude []string `yaml:"exclude,omitempty" json:"exclude,omitempty"` // minus the policies matching any pattern
+}
+
+// Selects tells whether a policy is selected, a nil selector selects all policies
+func (s *PolicySelector) Selects(policyId string) bool {
+	if s == nil {
+		return true
+	}
+	matchesAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, policyId); ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(s.Include) > 0 && !matchesAny(s.Include) {
+		return false
+	}
+	return !matchesAny(s.Exclude)
+}
+
+// ServiceName returns the display name of the service, from its metadata if set
+func (d *ReportData) ServiceName() string {
+	if d.ServiceMetadata != nil && d.ServiceMetadata.DisplayName != "" {
+		return d.ServiceMetadata.DisplayName
+	}
+	return d.Service
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// EnforcementSimulation is the what-if report of an evaluation, with the enforcement levels of another time
+type EnforcementSimulation struct {
+	EvaluatedAt time.Time `json:"evaluatedAt"`
+	SimulatedAt time.Time `json:"simulatedAt"`
+
+	// Outcomes summarizes the changes for the failing policies (e.g. "3 currently-warning policies would block")
+	Outcomes []string `json:"outcomes"`
+
+	// Policies are the policies whose enforcement level differs at SimulatedAt, in compliance config order
+	Policies []SimulatedPolicy `json:"policies"`
+}
+
+// SimulatedPolicy is a policy whose enforcement level changes between both times
+type SimulatedPolicy struct {
+	PolicyId        string   `json:"policyId"`
+	PolicyName      string   `json:"policyName"`
+	CurrentLevel    string   `json:"currentLevel"`
+	SimulatedLevel  string   `json:"simulatedLevel"`
+	FailingOverlays []string `json:"failingOverlays,omitempty"` // overlay keys where the policy fails, empty if it passes
+}
@@ -0,0 +1,26 @@
+package models
+
+// PatchOperation is an RFC 6902 JSON patch operation, suggested by a policy to fix its violation
+// e.g. {"op": "replace", "path": "/spec/replicas", "value": 2}
+type PatchOperation struct {
+	Op    string      `json:"op"` // add, remove, replace, move, copy or test
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"` // move and copy only
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Suggestion is the fix a policy suggested for a violation, as a patch of the violating resource
+type Suggestion struct {
+	Patch []PatchOperation `json:"patch"`
+	Diff  string           `json:"diff,omitempty"` // diff hunks of the resource before/after the patch, empty if it could not be applied
+
+	// Source is the equivalent one-line change of the overlay source files, when the patch maps to one (provenance mode only)
+	Source *SourceSuggestion `json:"source,omitempty"`
+}
+
+// SourceSuggestion is a line of a source file to replace, for SCM suggested changes (e.g. GitHub ```suggestion blocks)
+type SourceSuggestion struct {
+	File        string `json:"file"` // relative to the overlay directory, as ResourceOrigin.SourceFile
+	Line        int    `json:"line"` // 1-based
+	Replacement string `json:"replacement"`
+}
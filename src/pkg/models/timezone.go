@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	TimeFormat = "2006-01-02 15:04:05 MST" // format of the times of the comment
+	DateFormat = "2006-01-02"              // format of the dates of the comment, without Timezone
+)
+
+// FormatTime formats a time of the report in UTC, followed by the time in the report Timezone when set,
+// e.g. "2025-12-01 00:00:00 UTC (2025-12-01 09:00:00 JST)". An unknown Timezone renders UTC only
+func (d *ReportData) FormatTime(t time.Time) string {
+	formatted := t.UTC().Format(TimeFormat)
+	if local, ok := d.inTimezone(t); ok {
+		formatted += " (" + local.Format(TimeFormat) + ")"
+	}
+	return formatted
+}
+
+// FormatDate formats an enforcement date of the report, as FormatTime when the report has a Timezone since the
+// day may differ, else as a date in UTC
+func (d *ReportData) FormatDate(t time.Time) string {
+	if _, ok := d.inTimezone(t); ok {
+		return d.FormatTime(t)
+	}
+	return t.UTC().Format(DateFormat)
+}
+
+func (d *ReportData) inTimezone(t time.Time) (time.Time, bool) {
+	if d.Timezone == "" {
+		return t, false
+	}
+	loc, err := time.LoadLocation(d.Timezone)
+	if err != nil {
+		return t, false
+	}
+	return t.In(loc), true
+}
@@ -0,0 +1,12 @@
+package models
+
+// Timings are the durations of the run stages in milliseconds, recorded even when the performance
+// report is disabled. Stages that did not run are left out
+type Timings struct {
+	CheckoutMs   float64                       `json:"checkoutMs,omitempty"`   // base and head checkouts (github mode)
+	BuildMs      map[string]float64            `json:"buildMs,omitempty"`      // before and after builds, by overlay key
+	DiffMs       map[string]float64            `json:"diffMs,omitempty"`       // by overlay key
+	PolicyEvalMs map[string]map[string]float64 `json:"policyEvalMs,omitempty"` // by overlay key, then policy id
+	RenderMs     float64                       `json:"renderMs,omitempty"`     // templates rendering
+	PublishMs    float64                       `json:"publishMs,omitempty"`    // posting or updating the PR comment (github mode)
+}
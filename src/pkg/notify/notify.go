@@ -0,0 +1,237 @@
+// Package notify dispatches the outbound notifications of the runs, the SCM comments, Slack messages and webhooks,
+// with a rate limit per destination, batching and retries, so that many runs (e.g. of the server mode) neither
+// spam the channels nor hit the API limits
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "notify")
+
+// MAX_RECORDED_FAILURES bounds the failed sends returned by Close, the next ones are only logged and counted: the
+// dispatcher of the server mode lives as long as the process
+const MAX_RECORDED_FAILURES = 20
+
+// Message is a notification of a destination
+type Message struct {
+	Destination string // <kind>:<target>, e.g. "slack:#payments-alerts" or "webhook:https://hooks.acme.com/gitops"
+	Text        string // one-line text, the Slack message of a batch joins the texts
+	Data        any    // JSON document, the webhook of a batch receives the documents
+}
+
+// Sender delivers the batches of messages of the destinations of a kind
+type Sender interface {
+	// Send delivers a batch of messages to target, the destination without its kind
+	Send(ctx context.Context, target string, batch []Message) error
+}
+
+// Route configures the destinations of a kind
+type Route struct {
+	Sender      Sender        // nil for the destinations of synchronous calls (Do) only, e.g. the SCM comments
+	Interval    time.Duration // minimum time between two sends or calls of a destination
+	BatchWindow time.Duration // messages wait this long for the next ones of the destination, to be sent as a batch
+	MaxBatch    int           // maximum number of messages of a batch, 0 for no limit
+	MaxAttempts int           // attempts of a send or call, 1 if lower
+	Backoff     time.Duration // wait before the first retry, doubled for each next one
+	MaxWait     time.Duration // a retry is abandoned when the destination asks to wait longer, 0 for no limit
+	// Retryable reports whether a failed send or call is retried, with the wait the destination asks for (0 if none)
+	Retryable func(error) (time.Duration, bool)
+}
+
+// destination is the state of a destination: its rate limit and the messages waiting to be sent
+type destination struct {
+	name     string
+	target   string
+	route    Route
+	next     time.Time // earliest time of the next send or call
+	queue    []Message
+	flushing bool
+}
+
+// Dispatcher sends the messages of the destinations of its routes, it is safe for concurrent use
+type Dispatcher struct {
+	routes map[string]Route
+
+	mu           sync.Mutex
+	destinations map[string]*destination
+	closing      chan struct{}
+	closed       bool
+	errs         []error // the first MAX_RECORDED_FAILURES failed sends
+	unrecorded   int     // failed sends after them
+	wg           sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher of the routes of each destination kind
+func NewDispatcher(routes map[string]Route) *Dispatcher {
+	return &Dispatcher{routes: routes, destinations: map[string]*destination{}, closing: make(chan struct{})}
+}
+
+// destinationOf returns the state of a destination, d.mu must be held
+func (d *Dispatcher) destinationOf(name string) (*destination, error) {
+	if dest, ok := d.destinations[name]; ok {
+		return dest, nil
+	}
+	kind, target, ok := strings.Cut(name, ":")
+	route, known := d.routes[kind]
+	if !ok || !known {
+		return nil, fmt.Errorf("unknown notification destination %q", name)
+	}
+	dest := &destination{name: name, target: target, route: route}
+	d.destinations[name] = dest
+	return dest, nil
+}
+
+// Post queues a message, it is sent after the batch window of its destination along with the messages posted
+// meanwhile. The failures of the sends are returned by Close
+func (d *Dispatcher) Post(ctx context.Context, msg Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return fmt.Errorf("dispatcher is closed, %s notification dropped", msg.Destination)
+	}
+	dest, err := d.destinationOf(msg.Destination)
+	if err != nil {
+		return err
+	}
+	if dest.route.Sender == nil {
+		return fmt.Errorf("%s notifications cannot be posted", msg.Destination)
+	}
+	dest.queue = append(dest.queue, msg)
+	if !dest.flushing {
+		dest.flushing = true
+		d.wg.Add(1)
+		go d.flush(ctx, dest)
+	}
+	return nil
+}
+
+// Do runs a synchronous call of a destination (e.g. publishing a comment) within its rate limit, retried as
+// configured by its route
+func (d *Dispatcher) Do(ctx context.Context, destinationName string, call func() error) error {
+	d.mu.Lock()
+	dest, err := d.destinationOf(destinationName)
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return d.call(ctx, dest, call)
+}
+
+// Close sends the queued messages without waiting for the end of their batch window, then returns the failures
+// of the sends of the dispatcher
+func (d *Dispatcher) Close() error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.closing)
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	errs := d.errs
+	if d.unrecorded > 0 {
+		errs = append(errs, fmt.Errorf("%d more sends failed", d.unrecorded))
+	}
+	return errors.Join(errs...)
+}
+
+// flush sends the messages of a destination by batches, once its batch window is over
+func (d *Dispatcher) flush(ctx context.Context, dest *destination) {
+	defer d.wg.Done()
+	select {
+	case <-time.After(dest.route.BatchWindow):
+	case <-d.closing:
+	case <-ctx.Done():
+	}
+	for {
+		d.mu.Lock()
+		n := len(dest.queue)
+		if dest.route.MaxBatch > 0 && n > dest.route.MaxBatch {
+			n = dest.route.MaxBatch
+		}
+		if n == 0 {
+			dest.flushing = false
+			d.mu.Unlock()
+			return
+		}
+		batch := dest.queue[:n:n]
+		dest.queue = dest.queue[n:]
+		d.mu.Unlock()
+
+		err := d.call(ctx, dest, func() error { return dest.route.Sender.Send(ctx, dest.target, batch) })
+		if err != nil {
+			logger.WithField("destination", dest.name).WithField("messages", len(batch)).WithField("error", err).
+				Warn("Failed to send notifications")
+			d.mu.Lock()
+			if len(d.errs) < MAX_RECORDED_FAILURES {
+				d.errs = append(d.errs, fmt.Errorf("%d notifications of %s dropped: %w", len(batch), dest.name, err))
+			} else {
+				d.unrecorded++
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// call runs fn in the rate limit of the destination, retrying the retryable errors
+func (d *Dispatcher) call(ctx context.Context, dest *destination, fn func() error) error {
+	route := dest.route
+	backoff := route.Backoff
+	for attempt := 1; ; attempt++ {
+		if err := sleep(ctx, d.reserve(dest)); err != nil {
+			return err
+		}
+		err := fn()
+		if err == nil || attempt >= route.MaxAttempts || route.Retryable == nil {
+			return err
+		}
+		after, retryable := route.Retryable(err)
+		if !retryable || (route.MaxWait > 0 && after > route.MaxWait) {
+			return err
+		}
+		wait := max(after, backoff)
+		logger.WithField("destination", dest.name).WithField("attempt", attempt).WithField("wait", wait).
+			WithField("error", err).Info("Retrying notification")
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// reserve takes the next slot of the rate limit of a destination, returns the wait until it
+func (d *Dispatcher) reserve(dest *destination) time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	at := now
+	if dest.next.After(now) {
+		at = dest.next
+	}
+	dest.next = at.Add(dest.route.Interval)
+	return at.Sub(now)
+}
+
+func sleep(ctx context.Context, wait time.Duration) error {
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
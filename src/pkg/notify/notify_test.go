@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a sender recording its batches
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]Message
+	fail    int // the first sends fail with a 429
+}
+
+func (s *recorder) Send(ctx context.Context, target string, batch []Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return &HTTPError{StatusCode: http.StatusTooManyRequests, Message: "ratelimited"}
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func TestPostBatchesMessages(t *testing.T) {
+	sender := &recorder{}
+	d := NewDispatcher(map[string]Route{KIND_SLACK: {Sender: sender, BatchWindow: time.Hour, MaxBatch: 2}})
+	for _, text := range []string{"a", "b", "c"} {
+		if err := d.Post(context.Background(), Message{Destination: "slack:#alerts", Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Close does not wait for the batch window
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.batches) != 2 || len(sender.batches[0]) != 2 || sender.batches[1][0].Text != "c" {
+		t.Errorf("batches = %v, want [[a b] [c]]", sender.batches)
+	}
+	if err := d.Post(context.Background(), Message{Destination: "slack:#alerts"}); err == nil {
+		t.Error("Post() after Close() succeeded")
+	}
+}
+
+func TestPostUnknownDestination(t *testing.T) {
+	d := NewDispatcher(map[string]Route{"github": {}})
+	for _, destination := range []string{"email:ops@acme.com", "slack", "github:api"} {
+		if err := d.Post(context.Background(), Message{Destination: destination}); err == nil {
+			t.Errorf("Post(%s) succeeded, want an error", destination)
+		}
+	}
+}
+
+func TestPostRetriesThrottledSends(t *testing.T) {
+	sender := &recorder{fail: 2}
+	d := NewDispatcher(map[string]Route{KIND_WEBHOOK: {Sender: sender, MaxAttempts: 3, Backoff: time.Millisecond, Retryable: RetryableHTTP}})
+	if err := d.Post(context.Background(), Message{Destination: "webhook:https://hooks.acme.com/x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close() = %v, want the third attempt to succeed", err)
+	}
+
+	sender = &recorder{fail: 2}
+	d = NewDispatcher(map[string]Route{KIND_WEBHOOK: {Sender: sender, MaxAttempts: 2, Backoff: time.Millisecond, Retryable: RetryableHTTP}})
+	_ = d.Post(context.Background(), Message{Destination: "webhook:https://hooks.acme.com/x"})
+	if err := d.Close(); err == nil {
+		t.Error("Close() succeeded, want the dropped notification")
+	}
+}
+
+func TestCloseBoundsFailures(t *testing.T) {
+	sender := &recorder{fail: MAX_RECORDED_FAILURES + 5}
+	d := NewDispatcher(map[string]Route{KIND_SLACK: {Sender: sender, BatchWindow: time.Hour, MaxBatch: 1}})
+	for i := 0; i < MAX_RECORDED_FAILURES+5; i++ {
+		if err := d.Post(context.Background(), Message{Destination: "slack:#alerts"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := d.Close()
+	if err == nil {
+		t.Fatal("Close() succeeded, want the dropped notifications")
+	}
+	if got := strings.Count(err.Error(), "dropped"); got != MAX_RECORDED_FAILURES || !strings.Contains(err.Error(), "5 more sends failed") {
+		t.Errorf("Close() = %d failures recorded:\n%v\nwant %d and the count of the others", got, err, MAX_RECORDED_FAILURES)
+	}
+}
+
+func TestDoRateLimit(t *testing.T) {
+	d := NewDispatcher(map[string]Route{"github": {Interval: 20 * time.Millisecond}})
+	start := time.Now()
+	for range 3 {
+		if err := d.Do(context.Background(), "github:api", func() error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 calls took %v, want at least 2 intervals", elapsed)
+	}
+}
+
+func TestDoRetry(t *testing.T) {
+	throttled := errors.New("throttled")
+	tests := []struct {
+		name      string
+		maxWait   time.Duration
+		after     time.Duration
+		wantCalls int
+	}{
+		{"retried", 0, time.Millisecond, 3},
+		{"wait too long", time.Millisecond, time.Hour, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDispatcher(map[string]Route{"github": {MaxAttempts: 3, MaxWait: tt.maxWait,
+				Retryable: func(err error) (time.Duration, bool) { return tt.after, errors.Is(err, throttled) }}})
+			calls := 0
+			err := d.Do(context.Background(), "github:api", func() error {
+				calls++
+				return throttled
+			})
+			if !errors.Is(err, throttled) || calls != tt.wantCalls {
+				t.Errorf("Do() = %v after %d calls, want throttled after %d", err, calls, tt.wantCalls)
+			}
+		})
+	}
+}
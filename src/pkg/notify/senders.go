@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+)
+
+const (
+	// KIND_SLACK destinations are Slack channels, e.g. "slack:#payments-alerts"
+	KIND_SLACK = "slack"
+	// KIND_WEBHOOK destinations are http(s) URLs receiving the JSON documents, e.g. "webhook:https://hooks.acme.com/x"
+	KIND_WEBHOOK = "webhook"
+
+	// ENV_SLACK_TOKEN is the Slack bot token (chat:write scope) of the Slack notifications
+	ENV_SLACK_TOKEN = "SLACK_BOT_TOKEN"
+	// SLACK_API_URL is the Slack Web API
+	SLACK_API_URL = "https://slack.com/api"
+)
+
+// HTTPError is an error response of a destination
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration // the Retry-After of the response, 0 if none
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// RetryableHTTP retries the network errors, and the responses throttled (429) or failed by the server (5xx)
+func RetryableHTTP(err error) (time.Duration, bool) {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.RetryAfter, httpErr.StatusCode == http.StatusTooManyRequests || httpErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return 0, errors.As(err, &urlErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// SlackSender posts the batches to Slack channels with chat.postMessage, one message per batch
+type SlackSender struct {
+	APIURL string
+	Token  string
+	HTTP   *http.Client
+}
+
+// NewSlackSender creates a Slack sender authenticated with SLACK_BOT_TOKEN
+func NewSlackSender() (*SlackSender, error) {
+	token := os.Getenv(ENV_SLACK_TOKEN)
+	if token == "" {
+		return nil, fmt.Errorf("Slack token not found. Set the %s environment variable (a bot token with the chat:write scope)", ENV_SLACK_TOKEN)
+	}
+	return &SlackSender{APIURL: SLACK_API_URL, Token: token, HTTP: httpclient.New(0)}, nil
+}
+
+func (s *SlackSender) Send(ctx context.Context, channel string, batch []Message) error {
+	texts := make([]string, len(batch))
+	for i, msg := range batch {
+		texts[i] = msg.Text
+	}
+	body := map[string]any{"channel": channel, "text": strings.Join(texts, "\n"), "unfurl_links": false}
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := postJSON(ctx, s.HTTP, s.APIURL+"/chat.postMessage", "Bearer "+s.Token, body, &resp); err != nil {
+		return fmt.Errorf("failed to post to Slack channel %s: %w", channel, err)
+	}
+	if !resp.OK {
+		// Slack answers 200 to the API errors
+		status := http.StatusBadRequest
+		if resp.Error == "ratelimited" {
+			status = http.StatusTooManyRequests
+		}
+		return fmt.Errorf("failed to post to Slack channel %s: %w", channel, &HTTPError{StatusCode: status, Message: resp.Error})
+	}
+	return nil
+}
+
+// WebhookSender posts the batches to http(s) URLs, as {"notifications": [<data>...]}
+type WebhookSender struct {
+	HTTP *http.Client
+}
+
+// NewWebhookSender creates a webhook sender
+func NewWebhookSender() *WebhookSender {
+	return &WebhookSender{HTTP: httpclient.New(0)}
+}
+
+func (s *WebhookSender) Send(ctx context.Context, target string, batch []Message) error {
+	documents := make([]any, len(batch))
+	for i, msg := range batch {
+		documents[i] = msg.Data
+	}
+	if err := postJSON(ctx, s.HTTP, target, "", map[string]any{"notifications": documents}, nil); err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	return nil
+}
+
+// postJSON posts body as JSON to url, decoding the JSON response into out if not nil
+func postJSON(ctx context.Context, client *http.Client, url, authorization string, body, out any) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &HTTPError{StatusCode: resp.StatusCode, RetryAfter: RetryAfterOf(resp.Header), Message: strings.TrimSpace(string(message))}
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// RetryAfterOf returns the wait of the Retry-After header (in seconds) of a response, 0 if none
+func RetryAfterOf(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
 )
 
 // PathBuilder interpolates variables into build path templates
 type PathBuilder struct {
 	Template  string              // e.g., "/path/$SERVICE/clusters/$CLUSTER/$ENV"
 	Variables map[string][]string // e.g., {"SERVICE": ["my-app"], "CLUSTER": ["alpha","beta"], ...}
+	FS        fsys.FS             // Searched by ExpandPatterns, nil for the disk
 }
 
 // PathCombination represents a single interpolated path with its variable values
@@ -90,22 +93,28 @@ func ParseValues(valuesStr string) (map[string][]string, error) {
 
 // Validate checks that all $VARs in template have corresponding values
 func (pb *PathBuilder) Validate() error {
+	_, err := pb.validate()
+	return err
+}
+
+// validate is Validate returning the compiled pattern values of each pattern variable
+func (pb *PathBuilder) validate() (map[string][]valueMatcher, error) {
 	if pb.Template == "" {
-		return fmt.Errorf("template cannot be empty")
+		return nil, fmt.Errorf("template cannot be empty")
 	}
 
 	templateVars := ParseTemplate(pb.Template)
 	for _, varName := range templateVars {
 		values, exists := pb.Variables[varName]
 		if !exists {
-			return fmt.Errorf("variable $%s in template has no values defined", varName)
+			return nil, fmt.Errorf("variable $%s in template has no values defined", varName)
 		}
 		if len(values) == 0 {
-			return fmt.Errorf("variable $%s has empty values", varName)
+			return nil, fmt.Errorf("variable $%s has empty values", varName)
 		}
 	}
 
-	return nil
+	return pb.compilePatternVars()
 }
 
 // InterpolatePath performs single interpolation with given values
@@ -1,9 +1,13 @@
 package pathbuilder
 
 import (
+	"fmt"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
 )
 
 func TestParseTemplate(t *testing.T) {
@@ -177,6 +181,26 @@ func TestPathBuilder_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid regex pattern",
+			pb: &PathBuilder{
+				Template: "/path/[ENV]",
+				Variables: map[string][]string{
+					"ENV": {"re:prod-(us"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pattern variable not a whole segment",
+			pb: &PathBuilder{
+				Template: "/path/env-[ENV]",
+				Variables: map[string][]string{
+					"ENV": {"prod-*"},
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -424,3 +448,74 @@ func TestPathBuilder_GetRelativePaths(t *testing.T) {
 		t.Errorf("GetRelativePaths() = %v, want %v", paths, expected)
 	}
 }
+
+func TestPathBuilder_ExpandPatterns(t *testing.T) {
+	// the same tree on disk and in memory
+	root := t.TempDir()
+	filesystems := []fsys.FS{fsys.OS, fsys.NewMem()}
+	for _, files := range filesystems {
+		for _, dir := range []string{
+			"before/my-app/alpha/stg", "before/my-app/alpha/prod-us", "before/my-app/beta/prod-eu", "before/my-app/alpha/.prod-tmp",
+			"after/my-app/alpha/stg", "after/my-app/alpha/prod-us", "after/my-app/alpha/prod-ap", "after/other/alpha/prod-jp",
+		} {
+			if err := files.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := files.WriteFile(filepath.Join(root, "after/my-app/alpha/prod-file"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	template := "[SERVICE]/[CLUSTER]/[ENV]"
+	tests := []struct {
+		name    string
+		values  map[string][]string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name:   "glob across before and after",
+			values: map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"alpha"}, "ENV": {"prod-*"}},
+			want:   map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"alpha"}, "ENV": {"prod-ap", "prod-us"}},
+		},
+		{
+			name:   "literal values kept first",
+			values: map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"alpha"}, "ENV": {"stg", "prod-*"}},
+			want:   map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"alpha"}, "ENV": {"stg", "prod-ap", "prod-us"}},
+		},
+		{
+			name:   "regex with several pattern variables",
+			values: map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"*"}, "ENV": {"re:prod-(us|eu)"}},
+			want:   map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"alpha", "beta"}, "ENV": {"prod-eu", "prod-us"}},
+		},
+		{
+			name:   "no patterns",
+			values: map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"alpha"}, "ENV": {"stg"}},
+			want:   map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"alpha"}, "ENV": {"stg"}},
+		},
+		{
+			name:    "pattern matching nothing",
+			values:  map[string][]string{"SERVICE": {"my-app"}, "CLUSTER": {"alpha"}, "ENV": {"stg", "dev-*"}},
+			wantErr: true,
+		},
+	}
+
+	for _, files := range filesystems {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%T/%s", files, tt.name), func(t *testing.T) {
+				pb := &PathBuilder{Template: template, Variables: tt.values, FS: files}
+				got, err := pb.ExpandPatterns(filepath.Join(root, "before", template), filepath.Join(root, "after", template))
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("ExpandPatterns() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					return
+				}
+				if !reflect.DeepEqual(got.Variables, tt.want) {
+					t.Errorf("ExpandPatterns() = %v, want %v", got.Variables, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package pathbuilder
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+)
+
+// REGEX_VALUE_PREFIX marks a variable value as a regular expression (e.g. "ENV=re:prod-(us|eu)")
+// Values containing glob metacharacters (e.g. "ENV=prod-*") are matched as globs
+const REGEX_VALUE_PREFIX = "re:"
+
+// IsPattern reports whether a variable value is a glob or regex to expand against the overlay directories
+func IsPattern(value string) bool {
+	return strings.HasPrefix(value, REGEX_VALUE_PREFIX) || strings.ContainsAny(value, "*?[")
+}
+
+// valueMatcher is a variable value compiled once, matching directory names as a regex, a glob or literally
+type valueMatcher struct {
+	value string
+	re    *regexp.Regexp
+}
+
+// compileValue parses a variable value, checking the syntax of patterns
+func compileValue(value string) (valueMatcher, error) {
+	if expr, ok := strings.CutPrefix(value, REGEX_VALUE_PREFIX); ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return valueMatcher{}, err
+		}
+		return valueMatcher{value: value, re: re}, nil
+	}
+	if IsPattern(value) {
+		if _, err := path.Match(value, ""); err != nil {
+			return valueMatcher{}, err
+		}
+	}
+	return valueMatcher{value: value}, nil
+}
+
+// match reports whether a directory name matches the value
+func (m valueMatcher) match(name string) bool {
+	if m.re != nil {
+		return m.re.MatchString(name)
+	}
+	if !IsPattern(m.value) {
+		return m.value == name
+	}
+	ok, _ := path.Match(m.value, name)
+	return ok
+}
+
+// patternVars returns the template variables having at least one pattern value, in template order
+func (pb *PathBuilder) patternVars() []string {
+	var vars []string
+	for _, varName := range ParseTemplate(pb.Template) {
+		if slices.ContainsFunc(pb.Variables[varName], IsPattern) {
+			vars = append(vars, varName)
+		}
+	}
+	return vars
+}
+
+// ExpandPatterns returns a copy of the PathBuilder where glob/regex values are replaced by the
+// directory names matching them. Each of the templates (the path template joined with a checkout root,
+// pb.Template if none given) is searched, and a directory counts only if all pattern variables match it.
+// Literal values are kept in order, discovered ones follow sorted. A pattern matching nothing is an error.
+func (pb *PathBuilder) ExpandPatterns(templates ...string) (*PathBuilder, error) {
+	matchers, err := pb.validate()
+	if err != nil {
+		return nil, err
+	}
+	patternVars := pb.patternVars()
+	if len(patternVars) == 0 {
+		return pb, nil
+	}
+	if len(templates) == 0 {
+		templates = []string{pb.Template}
+	}
+
+	var literalVars []string
+	for _, varName := range ParseTemplate(pb.Template) {
+		if !slices.Contains(patternVars, varName) {
+			literalVars = append(literalVars, varName)
+		}
+	}
+
+	discovered := make(map[string][]string)
+	for _, template := range templates {
+		for _, combo := range pb.cartesianProduct(literalVars) {
+			names, err := pb.discover(template, combo, patternVars, matchers)
+			if err != nil {
+				return nil, err
+			}
+			for varName, values := range names {
+				discovered[varName] = append(discovered[varName], values...)
+			}
+		}
+	}
+
+	expanded := &PathBuilder{Template: pb.Template, Variables: make(map[string][]string, len(pb.Variables)), FS: pb.FS}
+	for varName, values := range pb.Variables {
+		expanded.Variables[varName] = values
+	}
+	for _, varName := range patternVars {
+		found := discovered[varName]
+		slices.Sort(found)
+		found = slices.Compact(found)
+
+		var values []string
+		for _, m := range matchers[varName] {
+			if !IsPattern(m.value) {
+				values = append(values, m.value)
+				continue
+			}
+			if !slices.ContainsFunc(found, m.match) {
+				return nil, fmt.Errorf("no overlay directory matches %s=%s", varName, m.value)
+			}
+		}
+		for _, name := range found {
+			if !slices.Contains(values, name) {
+				values = append(values, name)
+			}
+		}
+		expanded.Variables[varName] = values
+	}
+	return expanded, nil
+}
+
+// discover globs the directories of a template whose literal variables are set by combo,
+// and returns the names found for each pattern variable
+func (pb *PathBuilder) discover(template string, combo map[string]string, patternVars []string, matchers map[string][]valueMatcher) (map[string][]string, error) {
+	for varName, value := range combo {
+		template = strings.ReplaceAll(template, "["+varName+"]", value)
+	}
+	segments := strings.Split(filepath.Clean(template), string(filepath.Separator))
+	positions := make(map[string][]int)
+	for i, segment := range segments {
+		for _, varName := range patternVars {
+			if segment == "["+varName+"]" {
+				positions[varName] = append(positions[varName], i)
+				segments[i] = "*"
+			}
+		}
+	}
+
+	matches, err := fsys.Glob(pb.FS, strings.Join(segments, string(filepath.Separator)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search overlays of %s: %w", template, err)
+	}
+
+	names := make(map[string][]string)
+	for _, match := range matches {
+		if info, err := fsys.OrOS(pb.FS).Stat(match); err != nil || !info.IsDir() {
+			continue
+		}
+		matchSegments := strings.Split(match, string(filepath.Separator))
+		if len(matchSegments) != len(segments) {
+			continue
+		}
+		values, ok := pb.matchSegments(matchSegments, positions, matchers)
+		if !ok {
+			continue
+		}
+		for varName, value := range values {
+			names[varName] = append(names[varName], value)
+		}
+	}
+	return names, nil
+}
+
+// matchSegments extracts the pattern variable values of a globbed path, ok is false if any of them
+// is hidden, inconsistent across occurrences, or not accepted by the variable's values
+func (pb *PathBuilder) matchSegments(segments []string, positions map[string][]int, matchers map[string][]valueMatcher) (map[string]string, bool) {
+	values := make(map[string]string, len(positions))
+	for varName, indexes := range positions {
+		name := segments[indexes[0]]
+		if strings.HasPrefix(name, ".") {
+			return nil, false
+		}
+		for _, i := range indexes[1:] {
+			if segments[i] != name {
+				return nil, false
+			}
+		}
+		if !slices.ContainsFunc(matchers[varName], func(m valueMatcher) bool { return m.match(name) }) {
+			return nil, false
+		}
+		values[varName] = name
+	}
+	return values, true
+}
+
+// compilePatternVars compiles the values of pattern variables and checks that pattern variables
+// occupy whole path segments
+func (pb *PathBuilder) compilePatternVars() (map[string][]valueMatcher, error) {
+	segments := strings.Split(filepath.ToSlash(pb.Template), "/")
+	matchers := make(map[string][]valueMatcher)
+	for _, varName := range pb.patternVars() {
+		for _, value := range pb.Variables[varName] {
+			m, err := compileValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %s=%s: %w", varName, value, err)
+			}
+			matchers[varName] = append(matchers[varName], m)
+		}
+		for _, segment := range segments {
+			if segment != "["+varName+"]" && strings.Contains(segment, "["+varName+"]") {
+				return nil, fmt.Errorf("variable $%s has pattern values and must be a whole path segment, got %q", varName, segment)
+			}
+		}
+	}
+	return matchers, nil
+}
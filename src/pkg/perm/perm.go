@@ -0,0 +1,132 @@
+// Package perm sets the permissions of the files and directories the tool writes to its output directories
+// (reports, rendered manifests, diffs, profiles), stricter than the 0644/0755 defaults on shared runners
+package perm
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "perm")
+
+const (
+	DEFAULT_FILE_MODE fs.FileMode = 0644
+	DEFAULT_DIR_MODE  fs.FileMode = 0755
+)
+
+// Config is the permissions configuration, empty fields keep the defaults
+type Config struct {
+	FileMode string // octal mode of the written files, e.g. "0600"
+	DirMode  string // octal mode of the created directories, e.g. "0700"
+	Umask    string // octal process umask, also applied to the child processes and temp dirs (unix only)
+
+	// RequireOwner fails the writes to directories owned by another user (unix only), e.g. an output dir
+	// created in advance by another job of a shared runner
+	RequireOwner bool
+}
+
+var (
+	fileMode     = DEFAULT_FILE_MODE
+	dirMode      = DEFAULT_DIR_MODE
+	requireOwner = false
+)
+
+// ParseMode parses an octal permission mode, e.g. "0600" or "600"
+func ParseMode(value string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %q, expected octal permissions such as 0600", value)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// Configure applies a permissions configuration, it must be called before any output is written
+func Configure(cfg Config) error {
+	file, dir := DEFAULT_FILE_MODE, DEFAULT_DIR_MODE
+	var err error
+	if cfg.FileMode != "" {
+		if file, err = ParseMode(cfg.FileMode); err != nil {
+			return fmt.Errorf("output-file-mode: %w", err)
+		}
+	}
+	if cfg.DirMode != "" {
+		if dir, err = ParseMode(cfg.DirMode); err != nil {
+			return fmt.Errorf("output-dir-mode: %w", err)
+		}
+	}
+	if dir&0700 != 0700 {
+		return fmt.Errorf("output-dir-mode %#o must grant the owner rwx, or the tool cannot write to its directories", dir)
+	}
+	if cfg.Umask != "" {
+		mask, err := ParseMode(cfg.Umask)
+		if err != nil {
+			return fmt.Errorf("umask: %w", err)
+		}
+		setUmask(mask)
+	}
+	fileMode, dirMode, requireOwner = file, dir, cfg.RequireOwner
+	return nil
+}
+
+// File returns the mode of the written files
+func File() fs.FileMode {
+	return fileMode
+}
+
+// Dir returns the mode of the created directories
+func Dir() fs.FileMode {
+	return dirMode
+}
+
+// tighten removes the permissions of an existing file or directory not granted by mode, never adding any
+func tighten(path string, mode fs.FileMode) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if perm := info.Mode().Perm(); perm&^mode != 0 {
+		if err := os.Chmod(path, perm&mode); err != nil {
+			return fmt.Errorf("failed to restrict permissions of %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// MkdirAll creates a directory and its parents with Dir, restricting the permissions of the directory if it
+// already exists. With RequireOwner the directory must be owned by the running user
+func MkdirAll(path string) error {
+	if err := os.MkdirAll(path, dirMode); err != nil {
+		return err
+	}
+	if requireOwner {
+		if err := checkOwner(path); err != nil {
+			return err
+		}
+	}
+	return tighten(path, dirMode)
+}
+
+// WriteFile writes a file with File, restricting the permissions of the file if it already exists
+func WriteFile(path string, content []byte) error {
+	if err := os.WriteFile(path, content, fileMode); err != nil {
+		return err
+	}
+	return tighten(path, fileMode)
+}
+
+// Create creates or truncates a file with File, like os.Create
+func Create(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := tighten(path, fileMode); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
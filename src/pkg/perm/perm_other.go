@@ -0,0 +1,27 @@
+//go:build !unix
+
+package perm
+
+import (
+	"io/fs"
+	"sync"
+)
+
+var unsupportedWarnOnce sync.Once
+
+// setUmask is a no-op outside unix, the file and directory modes still apply
+func setUmask(mask fs.FileMode) {
+	unsupportedWarn()
+}
+
+// checkOwner is a no-op outside unix
+func checkOwner(path string) error {
+	unsupportedWarn()
+	return nil
+}
+
+func unsupportedWarn() {
+	unsupportedWarnOnce.Do(func() {
+		logger.Warn("umask and directory ownership checks are only supported on unix, they will not be applied")
+	})
+}
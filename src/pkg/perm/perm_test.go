@@ -0,0 +1,68 @@
+package perm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{"0600", 0600, false},
+		{"700", 0700, false},
+		{"1777", 0, true},
+		{"rw-------", 0, true},
+		{"0800", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseMode(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseMode(%q) = %#o, %v, want %#o (error %v)", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Cleanup(func() {
+		fileMode, dirMode, requireOwner = DEFAULT_FILE_MODE, DEFAULT_DIR_MODE, false
+	})
+	if err := Configure(Config{DirMode: "0600"}); err == nil {
+		t.Error("expected a directory mode without owner rwx to be rejected")
+	}
+	if err := Configure(Config{FileMode: "0600", DirMode: "0700", RequireOwner: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "output")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "report.json")
+	if err := os.WriteFile(path, []byte("old"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// existing files and directories are restricted too
+	if err := MkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	for p, want := range map[string]os.FileMode{dir: 0700, path: 0600} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != want {
+			t.Errorf("%s has mode %#o, want %#o", p, got, want)
+		}
+	}
+}
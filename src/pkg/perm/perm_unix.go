@@ -0,0 +1,32 @@
+//go:build unix
+
+package perm
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// setUmask sets the umask of the process, inherited by the child processes
+func setUmask(mask fs.FileMode) {
+	previous := syscall.Umask(int(mask))
+	logger.WithField("umask", fmt.Sprintf("%#o", mask)).WithField("previous", fmt.Sprintf("%#o", previous)).Debug("Set umask")
+}
+
+// checkOwner fails if a directory is not owned by the running user
+func checkOwner(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if uid := os.Getuid(); int(stat.Uid) != uid {
+		return fmt.Errorf("directory %s is owned by uid %d, not by the running user (uid %d)", path, stat.Uid, uid)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// ObjectEvaluation is the evaluation of a single object, e.g. of an admission request: the failing policies by
+// their current enforcement level
+type ObjectEvaluation struct {
+	Blocking  []models.PolicyResult
+	Warning   []models.PolicyResult
+	Recommend []models.PolicyResult
+}
+
+// EvaluateObject evaluates the policies against a manifest outside of a pull request, with the policy context pc
+// (environment, cluster, admission request). There are no overrides, the manual and cross-environment policies
+// are not evaluated: they need the pull request, its checklist and its other overlays. Safe for concurrent use,
+// with FetchExternalData refreshing the external data
+func (e *PolicyEvaluator) EvaluateObject(ctx context.Context, mf []byte, pc models.PolicyContext) (*ObjectEvaluation, error) {
+	e.external.RLock()
+	defer e.external.RUnlock()
+	violations, _, err := e.evaluateViolations(ctx, mf, &pc, false)
+	if err != nil {
+		return nil, err
+	}
+	levels, _, err := e.enforcementLevelsAt(e.now(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine the enforcement levels: %w", err)
+	}
+
+	evaluation := &ObjectEvaluation{}
+	for _, id := range e.policyIDs() {
+		policy := e.data.ComplianceConfig.Policies[id]
+		if policy.Type == POLICY_TYPE_MANUAL || len(violations[id]) == 0 {
+			continue
+		}
+		result := models.PolicyResult{
+			PolicyId:     id,
+			PolicyName:   policy.Name,
+			ExternalLink: policy.ExternalLink,
+			SourceLink:   e.data.sourceLinkOfPolicy[id],
+			FailMessages: violationMessages(violations[id]),
+			Violations:   violations[id],
+		}
+		switch levels[id] {
+		case POLICY_LEVEL_BLOCK:
+			evaluation.Blocking = append(evaluation.Blocking, result)
+		case POLICY_LEVEL_WARNING:
+			evaluation.Warning = append(evaluation.Warning, result)
+		case POLICY_LEVEL_RECOMMEND:
+			evaluation.Recommend = append(evaluation.Recommend, result)
+		}
+	}
+	return evaluation, nil
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestEvaluateObject(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{Clock: FixedClock(now)})
+	e.data.ComplianceConfig.PolicyIDs = []string{"images-block", "images-warn", "images-later", "review"}
+	e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+		"images-block": {Name: "Images", Type: POLICY_TYPE_IMAGES, Images: &models.ImagePolicyConfig{},
+			Enforcement: models.EnforcementConfig{IsBlockingAfter: &past}},
+		"images-warn": {Name: "Images", Type: POLICY_TYPE_IMAGES, Images: &models.ImagePolicyConfig{},
+			Enforcement: models.EnforcementConfig{IsWarningAfter: &past}},
+		"images-later": {Name: "Images", Type: POLICY_TYPE_IMAGES, Images: &models.ImagePolicyConfig{},
+			Enforcement: models.EnforcementConfig{InEffectAfter: &future}},
+		"review": {Name: "Review", Type: POLICY_TYPE_MANUAL, Manual: &models.ManualPolicyConfig{Item: "Reviewed", Required: true}},
+	}
+	// the object of an admission request is JSON
+	object := []byte(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "web", "namespace": "shop"},
+		"spec": {"containers": [{"name": "web", "image": "nginx:latest"}]}}`)
+
+	evaluation, err := e.EvaluateObject(context.Background(), object, models.PolicyContext{Environment: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evaluation.Blocking) != 1 || evaluation.Blocking[0].PolicyId != "images-block" {
+		t.Errorf("Blocking = %+v, want images-block only (manual policies are not evaluated)", evaluation.Blocking)
+	}
+	if len(evaluation.Warning) != 1 || len(evaluation.Recommend) != 0 {
+		t.Errorf("Warning = %+v, Recommend = %+v, want images-warn only", evaluation.Warning, evaluation.Recommend)
+	}
+	if violation := evaluation.Blocking[0].Violations[0]; violation.ResourceID == "" {
+		t.Errorf("violation %+v not linked to the object", violation)
+	}
+}
+
+// TestEvaluateObject_Concurrent evaluates objects concurrently with a shared evaluator while its external data is
+// refreshed, as the admission requests of a version of the policies do; run with -race
+func TestEvaluateObject_Concurrent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"registries":["ghcr.io"]}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writePolicyFiles(t, dir, map[string]string{
+		COMPLIANCE_CONFIG_FILENAME: `policies:
+  registries:
+    name: Registries
+    type: opa
+    filePath: registries.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+externalData:
+  - name: allowlist
+    url: ` + server.URL + `
+    ttl: "0"
+`,
+		"registries.rego": `package main
+
+import rego.v1
+
+deny contains msg if {
+	some doc in input
+	some c in doc.contents.spec.containers
+	not startswith(c.image, data.allowlist.registries[0])
+	msg := sprintf("image %s is not allowed", [c.image])
+}
+`,
+		"registries_test.rego": "package main\n",
+	})
+	e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{Engine: POLICY_ENGINE_EMBEDDED, ExternalDataCacheDir: t.TempDir()})
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	if err := e.FetchExternalData(context.Background()); err != nil {
+		t.Fatalf("FetchExternalData() error = %v", err)
+	}
+	defer func() { _ = e.Close() }()
+
+	mf := []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: web\nspec:\n  containers:\n  - name: web\n    image: docker.io/web\n")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			evaluation, err := e.EvaluateObject(context.Background(), mf, models.PolicyContext{Environment: "prod"})
+			if err != nil {
+				t.Errorf("EvaluateObject() error = %v", err)
+				return
+			}
+			if len(evaluation.Blocking) != 1 {
+				t.Errorf("blocking = %v, want the registries policy", evaluation.Blocking)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := e.FetchExternalData(context.Background()); err != nil {
+			t.Errorf("FetchExternalData() error = %v", err)
+		}
+	}()
+	wg.Wait()
+	if len(e.compiled) != 1 {
+		t.Errorf("compiled %d policies, want the policy compiled once", len(e.compiled))
+	}
+}
@@ -0,0 +1,215 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// availabilityWorkloadKinds are the workloads with replicas, scaled by HorizontalPodAutoscalers
+var availabilityWorkloadKinds = []string{"Deployment", "StatefulSet", "ReplicaSet"}
+
+// availabilityWorkload is a workload of the manifest with the autoscalers targeting it and the disruption budgets
+// selecting its pods
+type availabilityWorkload struct {
+	res  manifest.Resource
+	hpas []manifest.Resource
+	pdbs []manifest.Resource
+}
+
+// evaluateAvailabilityPolicy checks the coherence of the replicas, HorizontalPodAutoscalers and PodDisruptionBudgets
+// of the resources: budgets letting no pod be evicted, autoscalers fighting the declared replicas or each other,
+// invalid ranges, and with RequirePDB the replicated workloads without budget
+func evaluateAvailabilityPolicy(cfg *models.AvailabilityPolicyConfig, resources []manifest.Resource) []models.PolicyViolation {
+	violations := []models.PolicyViolation{}
+	add := func(res manifest.Resource, format string, args ...interface{}) {
+		violations = append(violations, models.PolicyViolation{Message: res.ID() + " " + fmt.Sprintf(format, args...), ResourceID: res.ID()})
+	}
+
+	var workloads []*availabilityWorkload
+	byID := make(map[string]*availabilityWorkload)
+	for _, res := range resources {
+		if slices.Contains(availabilityWorkloadKinds, res.Kind) {
+			w := &availabilityWorkload{res: res}
+			workloads = append(workloads, w)
+			byID[res.ID()] = w
+		}
+	}
+	for _, res := range resources {
+		switch res.Kind {
+		case "HorizontalPodAutoscaler":
+			minReplicas, maxReplicas := hpaRange(res)
+			if maxReplicas > 0 && minReplicas > maxReplicas {
+				add(res, "has minReplicas %d above maxReplicas %d", minReplicas, maxReplicas)
+			}
+			target := manifest.Resource{
+				Kind:      manifest.NestedString(res.Object, "spec", "scaleTargetRef", "kind"),
+				Name:      manifest.NestedString(res.Object, "spec", "scaleTargetRef", "name"),
+				Namespace: res.Namespace,
+			}
+			if w, ok := byID[target.ID()]; ok {
+				w.hpas = append(w.hpas, res)
+			}
+		case "PodDisruptionBudget":
+			selector, ok := manifest.Nested(res.Object, "spec", "selector").(map[string]interface{})
+			if !ok {
+				continue // a null selector selects no pod
+			}
+			for _, w := range workloads {
+				podLabels := manifest.NestedMap(w.res.Object, "spec", "template", "metadata", "labels")
+				if w.res.Namespace == res.Namespace && selectorMatches(selector, podLabels) {
+					w.pdbs = append(w.pdbs, res)
+				}
+			}
+		}
+	}
+
+	for _, w := range workloads {
+		replicas, source := workloadReplicas(w)
+		if source != "" {
+			source = " (" + source + ")"
+		}
+		if len(w.hpas) > 1 {
+			add(w.res, "is scaled by several HorizontalPodAutoscalers (%s), they fight over its replicas", resourceIDs(w.hpas))
+		} else if len(w.hpas) == 1 && manifest.Nested(w.res.Object, "spec", "replicas") != nil {
+			add(w.res, "sets spec.replicas while scaled by %s, the replicas are reset on each sync: remove spec.replicas", w.hpas[0].ID())
+		}
+		if len(w.pdbs) > 1 {
+			add(w.res, "has pods selected by several PodDisruptionBudgets (%s), the eviction API refuses to evict them", resourceIDs(w.pdbs))
+		}
+		for _, pdb := range w.pdbs {
+			if setting := pdbBlockingEvictions(pdb, replicas); setting != "" {
+				add(pdb, "has %s for the %d replicas of %s%s, no pod can be evicted and node drains are blocked", setting, replicas, w.res.ID(), source)
+			}
+		}
+		if cfg != nil && cfg.RequirePDB && len(w.pdbs) == 0 && workloadMaxReplicas(w) > 1 {
+			add(w.res, "runs more than one replica without a PodDisruptionBudget selecting its pods")
+		}
+	}
+	return violations
+}
+
+// hpaRange returns the minReplicas (default 1) and maxReplicas of a HorizontalPodAutoscaler
+func hpaRange(hpa manifest.Resource) (int, int) {
+	minReplicas, ok := manifest.Nested(hpa.Object, "spec", "minReplicas").(int)
+	if !ok {
+		minReplicas = 1
+	}
+	maxReplicas, _ := manifest.Nested(hpa.Object, "spec", "maxReplicas").(int)
+	return minReplicas, maxReplicas
+}
+
+// workloadReplicas returns the lowest replicas of a workload, the minReplicas of its autoscaler or its spec.replicas
+// (default 1), and the autoscaler it comes from if any
+func workloadReplicas(w *availabilityWorkload) (int, string) {
+	if len(w.hpas) > 0 {
+		minReplicas, _ := hpaRange(w.hpas[0])
+		return minReplicas, "minReplicas of " + w.hpas[0].ID()
+	}
+	replicas, ok := manifest.Nested(w.res.Object, "spec", "replicas").(int)
+	if !ok {
+		replicas = 1
+	}
+	return replicas, ""
+}
+
+// workloadMaxReplicas returns the highest replicas of a workload, the maxReplicas of its autoscaler or its replicas
+func workloadMaxReplicas(w *availabilityWorkload) int {
+	if len(w.hpas) > 0 {
+		_, maxReplicas := hpaRange(w.hpas[0])
+		return maxReplicas
+	}
+	replicas, _ := workloadReplicas(w)
+	return replicas
+}
+
+// pdbBlockingEvictions returns the setting of a PodDisruptionBudget allowing no eviction of the replicas of a
+// workload, empty if some pod can be evicted. A percentage of minAvailable is rounded up, like the disruption
+// controller does
+func pdbBlockingEvictions(pdb manifest.Resource, replicas int) string {
+	if value := manifest.Nested(pdb.Object, "spec", "minAvailable"); value != nil {
+		minAvailable, percent, ok := intOrPercent(value)
+		if !ok {
+			return ""
+		}
+		if percent {
+			minAvailable = (minAvailable*replicas + 99) / 100
+		}
+		if minAvailable >= replicas {
+			return fmt.Sprintf("minAvailable %v", value)
+		}
+		return ""
+	}
+	if value := manifest.Nested(pdb.Object, "spec", "maxUnavailable"); value != nil {
+		if maxUnavailable, _, ok := intOrPercent(value); ok && maxUnavailable == 0 {
+			return fmt.Sprintf("maxUnavailable %v", value)
+		}
+	}
+	return ""
+}
+
+// intOrPercent parses an int-or-string field, e.g. 2 or "50%"
+func intOrPercent(value interface{}) (int, bool, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, false, true
+	case string:
+		n, err := strconv.Atoi(strings.TrimSuffix(v, "%"))
+		return n, strings.HasSuffix(v, "%"), err == nil
+	}
+	return 0, false, false
+}
+
+// selectorMatches reports whether a label selector (matchLabels and matchExpressions) selects the labels, an
+// empty selector selects all
+func selectorMatches(selector map[string]interface{}, labels map[string]interface{}) bool {
+	for key, value := range manifest.NestedMap(selector, "matchLabels") {
+		if label, ok := labels[key]; !ok || fmt.Sprint(label) != fmt.Sprint(value) {
+			return false
+		}
+	}
+	expressions, _ := selector["matchExpressions"].([]interface{})
+	for _, item := range expressions {
+		expression, _ := item.(map[string]interface{})
+		key := manifest.NestedString(expression, "key")
+		var values []string
+		if list, ok := expression["values"].([]interface{}); ok {
+			for _, v := range list {
+				values = append(values, fmt.Sprint(v))
+			}
+		}
+		label, exists := labels[key]
+		switch manifest.NestedString(expression, "operator") {
+		case "In":
+			if !exists || !slices.Contains(values, fmt.Sprint(label)) {
+				return false
+			}
+		case "NotIn":
+			if exists && slices.Contains(values, fmt.Sprint(label)) {
+				return false
+			}
+		case "Exists":
+			if !exists {
+				return false
+			}
+		case "DoesNotExist":
+			if exists {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// resourceIDs joins the ids of resources, e.g. for a message
+func resourceIDs(resources []manifest.Resource) string {
+	ids := make([]string, len(resources))
+	for i, res := range resources {
+		ids[i] = res.ID()
+	}
+	return strings.Join(ids, ", ")
+}
@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const availabilityManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: web
+  namespace: app
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: web
+  minReplicas: 2
+  maxReplicas: 5
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: web
+  namespace: app
+spec:
+  minAvailable: 2
+  selector:
+    matchLabels:
+      app: web
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: app
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app: db
+        tier: data
+---
+apiVersion: policy/v1
+kind: PodDisruptionBudget
+metadata:
+  name: db
+  namespace: app
+spec:
+  maxUnavailable: 1
+  selector:
+    matchExpressions:
+      - {key: tier, operator: In, values: [data]}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+  namespace: app
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        app: worker
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: broken
+  namespace: app
+spec:
+  scaleTargetRef:
+    kind: Deployment
+    name: missing
+  minReplicas: 4
+  maxReplicas: 2
+`
+
+func TestEvaluateAvailabilityPolicy(t *testing.T) {
+	resources, err := manifest.Parse([]byte(availabilityManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cfg  *models.AvailabilityPolicyConfig
+		want []string
+	}{
+		{
+			name: "default settings",
+			want: []string{
+				"HorizontalPodAutoscaler/app/broken has minReplicas 4 above maxReplicas 2",
+				"Deployment/app/web sets spec.replicas while scaled by HorizontalPodAutoscaler/app/web, the replicas are reset on each sync: remove spec.replicas",
+				"PodDisruptionBudget/app/web has minAvailable 2 for the 2 replicas of Deployment/app/web (minReplicas of HorizontalPodAutoscaler/app/web), no pod can be evicted and node drains are blocked",
+			},
+		},
+		{
+			name: "pdb required",
+			cfg:  &models.AvailabilityPolicyConfig{RequirePDB: true},
+			want: []string{
+				"HorizontalPodAutoscaler/app/broken has minReplicas 4 above maxReplicas 2",
+				"Deployment/app/web sets spec.replicas while scaled by HorizontalPodAutoscaler/app/web, the replicas are reset on each sync: remove spec.replicas",
+				"PodDisruptionBudget/app/web has minAvailable 2 for the 2 replicas of Deployment/app/web (minReplicas of HorizontalPodAutoscaler/app/web), no pod can be evicted and node drains are blocked",
+				"Deployment/app/worker runs more than one replica without a PodDisruptionBudget selecting its pods",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := violationMessages(evaluateAvailabilityPolicy(tt.cfg, resources))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evaluateAvailabilityPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPdbBlockingEvictions(t *testing.T) {
+	tests := []struct {
+		spec     map[string]interface{}
+		replicas int
+		blocking bool
+	}{
+		{map[string]interface{}{"minAvailable": 1}, 2, false},
+		{map[string]interface{}{"minAvailable": 3}, 2, true},
+		{map[string]interface{}{"minAvailable": "50%"}, 1, true},
+		{map[string]interface{}{"minAvailable": "50%"}, 4, false},
+		{map[string]interface{}{"minAvailable": "100%"}, 4, true},
+		{map[string]interface{}{"maxUnavailable": 0}, 4, true},
+		{map[string]interface{}{"maxUnavailable": "0%"}, 4, true},
+		{map[string]interface{}{"maxUnavailable": "10%"}, 4, false},
+	}
+	for _, tt := range tests {
+		pdb := manifest.NewResource(map[string]interface{}{"kind": "PodDisruptionBudget", "spec": tt.spec})
+		if got := pdbBlockingEvictions(pdb, tt.replicas) != ""; got != tt.blocking {
+			t.Errorf("pdbBlockingEvictions(%v, %d) blocking = %v, want %v", tt.spec, tt.replicas, got, tt.blocking)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package policy
+
+import "time"
+
+// Clock is the time source of enforcement levels, so that the levels of a future date can be previewed
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock reads the current time, it is the default Clock
+var SystemClock Clock = systemClock{}
+
+// FixedClock always returns the same time (e.g. --evaluate-at)
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time { return time.Time(c) }
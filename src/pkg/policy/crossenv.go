@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	POLICY_INPUT_OVERLAY      = "overlay"      // the manifest of each overlay, evaluated once per overlay (default)
+	POLICY_INPUT_ENVIRONMENTS = "environments" // the manifests of all overlays as input.environments, evaluated once
+)
+
+// POLICY_INPUTS are the supported inputs of an "opa" policy
+var POLICY_INPUTS = []string{POLICY_INPUT_OVERLAY, POLICY_INPUT_ENVIRONMENTS}
+
+// VIOLATION_ENVIRONMENT_KEY is the field of a structured deny result of an "environments" input policy naming
+// the overlay it is raised for, e.g. {"msg": msg, "environment": "prod"}. Without it, it is raised for all overlays
+const VIOLATION_ENVIRONMENT_KEY = "environment"
+
+// crossEnvironmentInput is the input of the "environments" input policies, the resources of each overlay by overlay key
+type crossEnvironmentInput struct {
+	Environments map[string][]map[string]interface{} `json:"environments"`
+}
+
+// isCrossEnvironment tells if a policy is evaluated with the manifests of all overlays at once
+func (e *PolicyEvaluator) isCrossEnvironment(id string) bool {
+	return e.data.ComplianceConfig.Policies[id].Input == POLICY_INPUT_ENVIRONMENTS
+}
+
+// evaluateCrossEnvironment evaluates the "environments" input policies once with the manifests of the evaluated
+// overlays, those not skipped and built, and adds their results to the results of each of these overlays
+func (e *PolicyEvaluator) evaluateCrossEnvironment(
+	ctx context.Context,
+	envManifests map[string]models.BuildEnvManifestResult,
+	envToPolicyIdToResult map[string]map[string]models.PolicyResult,
+) error {
+	ids := slices.DeleteFunc(slices.Clone(e.policyIDs()), func(id string) bool { return !e.isCrossEnvironment(id) })
+	if len(ids) == 0 {
+		return nil
+	}
+
+	input := crossEnvironmentInput{Environments: make(map[string][]map[string]interface{})}
+	envResources := make(map[string][]manifest.Resource)
+	for env, build := range envManifests {
+		if build.BuildError != "" || build.Skipped {
+			continue
+		}
+		resources, err := manifest.Parse(build.AfterManifest)
+		if err != nil {
+			return fmt.Errorf("failed to parse manifest of environment %s for the cross-environment policies: %w", env, err)
+		}
+		objects := make([]map[string]interface{}, 0, len(resources))
+		for _, res := range resources {
+			objects = append(objects, res.Object)
+		}
+		input.Environments[env] = objects
+		envResources[env] = resources
+	}
+	if len(envResources) == 0 {
+		return nil
+	}
+	envs := make([]string, 0, len(envResources))
+	for env := range envResources {
+		envs = append(envs, env)
+	}
+	slices.Sort(envs)
+
+	content, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	// the run-wide context, overlay fields left empty. The input is a single document
+	pc := e.policyContext
+	session, err := e.newRegoSession(content, "environments-*.json", &pc)
+	if err != nil {
+		return err
+	}
+	defer session.close()
+	decisionContext := pc
+	decisionContext.OverlayKey = strings.Join(envs, ",")
+
+	var decisions []DecisionLogEvent
+	defer func() { e.logDecisions(ctx, decisions) }()
+	for _, id := range ids {
+		logger.WithField("policyId", id).WithField("envs", envs).Info("Evaluating cross-environment policy")
+		start := time.Now()
+		violations, evalErr := e.evaluateRegoPolicy(ctx, session, id, nil)
+		elapsed := time.Since(start)
+		if e.options.DecisionLogger != nil {
+			decisions = append(decisions, e.decisionOf(id, &decisionContext, nil, violations, evalErr, elapsed))
+		}
+		if evalErr != nil && !e.options.ContinueOnError {
+			return fmt.Errorf("failed to evaluate policy %s: %w", id, evalErr)
+		}
+		for _, v := range violations {
+			if v.Environment != "" && envResources[v.Environment] == nil {
+				logger.WithField("policyId", id).WithField("environment", v.Environment).
+					Warn("Violation raised for an environment that was not evaluated, raising it for all environments")
+			}
+		}
+
+		policy := e.data.ComplianceConfig.Policies[id]
+		for _, env := range envs {
+			if e.evalDurations[env] == nil {
+				e.evalDurations[env] = make(map[string]time.Duration)
+			}
+			e.evalDurations[env][id] = elapsed
+
+			result := models.PolicyResult{
+				PolicyId:        id,
+				PolicyName:      policy.Name,
+				ExternalLink:    policy.ExternalLink,
+				SourceLink:      e.data.sourceLinkOfPolicy[id],
+				OverrideCommand: policy.Enforcement.Override.Comment,
+			}
+			if evalErr != nil {
+				logger.WithField("env", env).WithField("policyId", id).WithField("error", evalErr).Error("Policy failed to evaluate, continuing")
+				excerpt := failure.Excerpt(evalErr)
+				result.FailMessages = []string{"Policy evaluation failed: " + excerpt}
+				result.EvalError = excerpt
+			} else {
+				result.Violations = violationsOfEnvironment(violations, env, envResources)
+				result.FailMessages = violationMessages(result.Violations)
+				result.IsPassing = len(result.FailMessages) == 0
+			}
+			envToPolicyIdToResult[env][id] = result
+		}
+	}
+	return nil
+}
+
+// violationsOfEnvironment returns the violations raised for an overlay, linked to its resources when their message
+// names one of them. Those raised for an overlay that was not evaluated are raised for all of them
+func violationsOfEnvironment(violations []models.PolicyViolation, env string, envResources map[string][]manifest.Resource) []models.PolicyViolation {
+	result := []models.PolicyViolation{}
+	for _, v := range violations {
+		if v.Environment != "" && v.Environment != env && envResources[v.Environment] != nil {
+			continue
+		}
+		if v.ResourceID == "" {
+			v.ResourceID = resolveViolationResource(nil, v.Message, envResources[env])
+		}
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+)
+
+func TestGeneratePolicyEvalResult_CrossEnvironment(t *testing.T) {
+	var input crossEnvironmentInput
+	executor := &sandbox.FakeExecutor{Handler: func(cmd sandbox.Command) (*sandbox.Result, error) {
+		content, err := os.ReadFile(cmd.Args[len(cmd.Args)-3])
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, &input); err != nil {
+			return nil, err
+		}
+		return &sandbox.Result{Stdout: []byte(`[{"filename": "environments.json", "namespace": "main", "failures": [
+			{"msg": "Deployment web has fewer replicas in prod than in stg", "metadata": {"environment": "prod"}},
+			{"msg": "chart versions differ across environments"}
+		]}]`)}, nil
+	}}
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{Executor: executor, Clock: FixedClock(since)})
+	e.data.ComplianceConfig.PolicyIDs = []string{"consistency"}
+	e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+		"consistency": {Name: "Consistency", Type: POLICY_TYPE_OPA, FilePath: "consistency.rego", Input: POLICY_INPUT_ENVIRONMENTS,
+			Enforcement: models.EnforcementConfig{IsBlockingAfter: &since}},
+	}
+	deployment := []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n")
+	build := models.BuildManifestResult{EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+		"stg":  {OverlayKey: "stg", AfterManifest: deployment},
+		"prod": {OverlayKey: "prod", AfterManifest: deployment},
+		"dev":  {OverlayKey: "dev", Skipped: true},
+	}}
+
+	eval, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+	if err != nil {
+		t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+	}
+	if commands := executor.Commands(); len(commands) != 1 || slices.Contains(commands[0].Args, "--combine") {
+		t.Fatalf("expected a single conftest run without --combine, got %v", commands)
+	}
+	if len(input.Environments) != 2 || len(input.Environments["prod"]) != 1 {
+		t.Errorf("input.environments = %v, want the resources of stg and prod", input.Environments)
+	}
+
+	messagesOf := func(env string) []string {
+		var messages []string
+		for _, p := range eval.PolicyMatrix[env].BlockingPolicies {
+			messages = append(messages, p.FailMessages...)
+		}
+		return messages
+	}
+	tests := []struct {
+		env  string
+		want []string
+	}{
+		{"stg", []string{"chart versions differ across environments"}},
+		{"prod", []string{"Deployment web has fewer replicas in prod than in stg", "chart versions differ across environments"}},
+		{"dev", nil},
+	}
+	for _, tt := range tests {
+		if got := messagesOf(tt.env); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s messages = %v, want %v", tt.env, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package policy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// DECISION_LOG_IMAGES_PATH is the decision path of the built-in images policy
+const DECISION_LOG_IMAGES_PATH = "gitops_kustomzchk/images"
+
+// DECISION_LOG_METADATA_PATH is the decision path of the built-in metadata policy
+const DECISION_LOG_METADATA_PATH = "gitops_kustomzchk/metadata"
+
+// DECISION_LOG_AVAILABILITY_PATH is the decision path of the built-in availability policy
+const DECISION_LOG_AVAILABILITY_PATH = "gitops_kustomzchk/availability"
+
+// DECISION_LOG_PACK_PATH is the decision path prefix of the rules of the built-in policy packs, e.g.
+// gitops_kustomzchk/packs/baseline/probes
+const DECISION_LOG_PACK_PATH = "gitops_kustomzchk/packs"
+
+// DECISION_LOG_DESTRUCTIVE_CHANGES_PATH is the decision path of the built-in destructive changes policy
+const DECISION_LOG_DESTRUCTIVE_CHANGES_PATH = "gitops_kustomzchk/destructive_changes"
+
+// DECISION_LOG_MANUAL_PATH is the decision path of the manual (checklist) policies
+const DECISION_LOG_MANUAL_PATH = "gitops_kustomzchk/manual"
+
+// DecisionLogEvent is a policy decision in the OPA decision log format, one per policy and environment
+// See https://www.openpolicyagent.org/docs/latest/management-decision-logs/
+type DecisionLogEvent struct {
+	Labels     map[string]string `json:"labels"`
+	DecisionID string            `json:"decision_id"`
+	Path       string            `json:"path"`
+	Input      any               `json:"input,omitempty"`
+	Result     DecisionResult    `json:"result"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Metrics    map[string]int64  `json:"metrics,omitempty"`
+}
+
+// DecisionResult is the result of a decision: the deny messages, or the evaluation error
+type DecisionResult struct {
+	Deny  []string `json:"deny"`
+	Error string   `json:"error,omitempty"`
+}
+
+// DecisionLogger ships the decisions of an evaluation to a decision log sink
+type DecisionLogger interface {
+	Log(ctx context.Context, events []DecisionLogEvent) error
+}
+
+// NewDecisionLogger returns the decision logger of a sink: an http(s) URL receives the events like an
+// OPA decision log service, anything else is a file the events are appended to as JSON lines
+func NewDecisionLogger(sink string) DecisionLogger {
+	if strings.HasPrefix(sink, "http://") || strings.HasPrefix(sink, "https://") {
+		return &HTTPDecisionLogger{URL: sink, Client: httpclient.New(30 * time.Second)}
+	}
+	return &FileDecisionLogger{Path: sink}
+}
+
+// FileDecisionLogger appends the events to a file, one JSON object per line
+type FileDecisionLogger struct {
+	Path string
+}
+
+func (l *FileDecisionLogger) Log(_ context.Context, events []DecisionLogEvent) error {
+	f, err := os.OpenFile(l.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open decision log: %w", err)
+	}
+	encoder := json.NewEncoder(f)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to write decision log: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// HTTPDecisionLogger posts the events as a gzipped JSON array, like OPA uploads to a decision log service
+type HTTPDecisionLogger struct {
+	URL    string
+	Client *http.Client
+}
+
+func (l *HTTPDecisionLogger) Log(ctx context.Context, events []DecisionLogEvent) error {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	if err := json.NewEncoder(gz).Encode(events); err != nil {
+		return fmt.Errorf("failed to encode decision log: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to encode decision log: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.URL, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create decision log request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload decision log: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to upload decision log: %s returned %s", l.URL, resp.Status)
+	}
+	return nil
+}
+
+// decisionOf builds the decision log event of a policy evaluated for an overlay
+func (e *PolicyEvaluator) decisionOf(
+	id string,
+	pc *models.PolicyContext,
+	resources []manifest.Resource,
+	violations []models.PolicyViolation,
+	evalErr error,
+	elapsed time.Duration,
+) DecisionLogEvent {
+	labels := map[string]string{"policy_id": id}
+	for key, value := range e.options.DecisionLogLabels {
+		labels[key] = value
+	}
+	if pc != nil {
+		labels["overlay_key"] = pc.OverlayKey
+		if pc.Service != "" {
+			labels["service"] = pc.Service
+		}
+		if pr := pc.PullRequest; pr != nil {
+			labels["pull_request"] = fmt.Sprintf("%s#%d", pr.Repo, pr.Number)
+		}
+	}
+
+	event := DecisionLogEvent{
+		Labels:     labels,
+		DecisionID: newDecisionID(),
+		Path:       decisionPathOf(e.data.ComplianceConfig.Policies[id]),
+		Result:     DecisionResult{Deny: violationMessages(violations)},
+		Timestamp:  time.Now().UTC(),
+		Metrics:    map[string]int64{"timer_rego_query_eval_ns": elapsed.Nanoseconds()},
+	}
+	if evalErr != nil {
+		event.Result.Error = evalErr.Error()
+	}
+	if !e.options.DecisionLogOmitInput && resources != nil {
+		objects := make([]map[string]interface{}, 0, len(resources))
+		for _, res := range resources {
+			objects = append(objects, res.Object)
+		}
+		event.Input = objects
+	}
+	return event
+}
+
+// decisionPathOf returns the query of a policy: its deny rule when it evaluates a single package, else all of data
+func decisionPathOf(policy models.PolicyConfig) string {
+	if policy.Type == POLICY_TYPE_IMAGES {
+		return DECISION_LOG_IMAGES_PATH
+	}
+	if policy.Type == POLICY_TYPE_METADATA {
+		return DECISION_LOG_METADATA_PATH
+	}
+	if policy.Type == POLICY_TYPE_AVAILABILITY {
+		return DECISION_LOG_AVAILABILITY_PATH
+	}
+	if policy.Type == POLICY_TYPE_PACK {
+		return DECISION_LOG_PACK_PATH + "/" + strings.ReplaceAll(policy.PackRule, ".", "/")
+	}
+	if policy.Type == POLICY_TYPE_MANUAL {
+		return DECISION_LOG_MANUAL_PATH
+	}
+	if policy.Type == POLICY_TYPE_DESTRUCTIVE {
+		return DECISION_LOG_DESTRUCTIVE_CHANGES_PATH
+	}
+	if len(policy.Namespaces) == 1 {
+		return strings.ReplaceAll(policy.Namespaces[0], ".", "/") + "/deny"
+	}
+	return ""
+}
+
+// logDecisions ships the decisions of an evaluation, a failing sink is logged and does not fail the run
+func (e *PolicyEvaluator) logDecisions(ctx context.Context, events []DecisionLogEvent) {
+	if e.options.DecisionLogger == nil || len(events) == 0 {
+		return
+	}
+	if err := e.options.DecisionLogger.Log(ctx, events); err != nil {
+		logger.WithField("error", err).Warn("Failed to ship the policy decision logs")
+	}
+}
+
+// newDecisionID returns a random (version 4) UUID
+func newDecisionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
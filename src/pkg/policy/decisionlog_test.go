@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestDecisionLoggers(t *testing.T) {
+	events := []DecisionLogEvent{
+		{DecisionID: "a", Path: "main/deny", Result: DecisionResult{Deny: []string{"too few replicas"}}},
+		{DecisionID: "b", Path: DECISION_LOG_IMAGES_PATH, Result: DecisionResult{Deny: []string{}}},
+	}
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "decisions.log")
+		logger := NewDecisionLogger(path)
+		for range 2 {
+			if err := logger.Log(context.Background(), events); err != nil {
+				t.Fatalf("Log() error = %v", err)
+			}
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		lines := 0
+		for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+			var event DecisionLogEvent
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Fatalf("line %d is not an event: %v", lines, err)
+			}
+		}
+		if lines != 4 {
+			t.Errorf("got %d lines, want 4 (appended)", lines)
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		var got []DecisionLogEvent
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil || r.Header.Get("Content-Encoding") != "gzip" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_ = json.NewDecoder(gz).Decode(&got)
+		}))
+		defer server.Close()
+
+		if err := NewDecisionLogger(server.URL).Log(context.Background(), events); err != nil {
+			t.Fatalf("Log() error = %v", err)
+		}
+		if len(got) != 2 || got[0].DecisionID != "a" || got[0].Result.Deny[0] != "too few replicas" {
+			t.Errorf("server received %+v", got)
+		}
+	})
+
+	t.Run("http error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		if err := NewDecisionLogger(server.URL).Log(context.Background(), events); err == nil {
+			t.Errorf("Log() error = nil, want an error")
+		}
+	})
+}
+
+func TestDecisionPathOf(t *testing.T) {
+	tests := []struct {
+		policy models.PolicyConfig
+		want   string
+	}{
+		{models.PolicyConfig{Type: POLICY_TYPE_OPA, Namespaces: []string{"kubernetes.ha"}}, "kubernetes/ha/deny"},
+		{models.PolicyConfig{Type: POLICY_TYPE_OPA}, ""},
+		{models.PolicyConfig{Type: POLICY_TYPE_IMAGES}, DECISION_LOG_IMAGES_PATH},
+		{models.PolicyConfig{Type: POLICY_TYPE_MANUAL}, DECISION_LOG_MANUAL_PATH},
+	}
+	for _, tt := range tests {
+		if got := decisionPathOf(tt.policy); got != tt.want {
+			t.Errorf("decisionPathOf(%+v) = %q, want %q", tt.policy, got, tt.want)
+		}
+	}
+}
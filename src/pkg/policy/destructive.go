@@ -0,0 +1,153 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	DESTRUCTIVE_CHANGES_POLICY_ID        = "destructive-changes" // reserved id of the built-in destructive changes policy
+	DESTRUCTIVE_CHANGES_POLICY_NAME      = "Destructive changes"
+	DESTRUCTIVE_CHANGES_OVERRIDE_COMMAND = "/sp-override-destructive-changes"
+)
+
+// DEFAULT_DESTRUCTIVE_KINDS are the kinds whose deletion loses data by default: the resources of a Namespace, the
+// custom resources of a CRD, the volume of a PersistentVolumeClaim
+var DEFAULT_DESTRUCTIVE_KINDS = []string{"Namespace", "CustomResourceDefinition", "PersistentVolumeClaim"}
+
+// destructiveMessages explain the data lost by a deletion, by kind
+var destructiveMessages = map[string]string{
+	"Namespace":                "deletes all the resources of the namespace",
+	"CustomResourceDefinition": "deletes all the custom resources of the definition",
+	"PersistentVolumeClaim":    "may delete the volume and its data, depending on its reclaim policy",
+}
+
+// isDestructiveChanges tells if a policy is the built-in destructive changes policy, evaluated with the before and
+// after manifests of each overlay
+func (e *PolicyEvaluator) isDestructiveChanges(id string) bool {
+	return e.data.ComplianceConfig.Policies[id].Type == POLICY_TYPE_DESTRUCTIVE
+}
+
+// registerDestructiveChangesPolicy adds the built-in destructive changes policy first, unless disabled. It is
+// blocking by default, overridden with DESTRUCTIVE_CHANGES_OVERRIDE_COMMAND
+func (e *PolicyEvaluator) registerDestructiveChangesPolicy() error {
+	cfg := e.data.ComplianceConfig.DestructiveChanges
+	if cfg != nil && cfg.Disabled {
+		return nil
+	}
+	if _, ok := e.data.ComplianceConfig.Policies[DESTRUCTIVE_CHANGES_POLICY_ID]; ok {
+		return fmt.Errorf("policy %s: the id is reserved by the built-in destructive changes policy, see destructiveChanges", DESTRUCTIVE_CHANGES_POLICY_ID)
+	}
+	enforcement := models.EnforcementConfig{IsBlockingAfter: &time.Time{}}
+	if cfg != nil && cfg.Enforcement != nil {
+		enforcement = *cfg.Enforcement
+	}
+	if enforcement.Override.Comment == "" {
+		enforcement.Override.Comment = DESTRUCTIVE_CHANGES_OVERRIDE_COMMAND
+	}
+	if e.data.ComplianceConfig.Policies == nil {
+		e.data.ComplianceConfig.Policies = make(map[string]models.PolicyConfig)
+	}
+	e.data.ComplianceConfig.Policies[DESTRUCTIVE_CHANGES_POLICY_ID] = models.PolicyConfig{
+		Name:        DESTRUCTIVE_CHANGES_POLICY_NAME,
+		Description: "Deleting these resources loses the data they hold",
+		Type:        POLICY_TYPE_DESTRUCTIVE,
+		Enforcement: enforcement,
+	}
+	e.data.ComplianceConfig.PolicyIDs = append([]string{DESTRUCTIVE_CHANGES_POLICY_ID}, e.data.ComplianceConfig.PolicyIDs...)
+	return nil
+}
+
+// destructiveKinds returns the kinds flagged by the destructive changes policy
+func (e *PolicyEvaluator) destructiveKinds() []string {
+	if cfg := e.data.ComplianceConfig.DestructiveChanges; cfg != nil && len(cfg.Kinds) > 0 {
+		return cfg.Kinds
+	}
+	return DEFAULT_DESTRUCTIVE_KINDS
+}
+
+// evaluateDestructiveChanges evaluates the destructive changes policy with the before and after manifests of the
+// evaluated overlays, those not skipped and built, and adds its result to the results of each of these overlays
+func (e *PolicyEvaluator) evaluateDestructiveChanges(
+	ctx context.Context,
+	envManifests map[string]models.BuildEnvManifestResult,
+	envToPolicyIdToResult map[string]map[string]models.PolicyResult,
+) error {
+	id := DESTRUCTIVE_CHANGES_POLICY_ID
+	if !slices.Contains(e.policyIDs(), id) || !e.isDestructiveChanges(id) {
+		return nil
+	}
+	policy := e.data.ComplianceConfig.Policies[id]
+	kinds := e.destructiveKinds()
+
+	var decisions []DecisionLogEvent
+	defer func() { e.logDecisions(ctx, decisions) }()
+	for env, build := range envManifests {
+		if build.BuildError != "" || build.Skipped {
+			continue
+		}
+		start := time.Now()
+		var violations []models.PolicyViolation
+		changes, evalErr := diff.ParseChanges(build.BeforeManifest, build.AfterManifest)
+		if evalErr == nil {
+			violations = destructiveViolations(changes, kinds)
+		}
+		elapsed := time.Since(start)
+		if e.evalDurations[env] == nil {
+			e.evalDurations[env] = make(map[string]time.Duration)
+		}
+		e.evalDurations[env][id] = elapsed
+		if e.options.DecisionLogger != nil {
+			pc := e.policyContextOf(build)
+			decisions = append(decisions, e.decisionOf(id, &pc, nil, violations, evalErr, elapsed))
+		}
+		if evalErr != nil && !e.options.ContinueOnError {
+			return fmt.Errorf("failed to evaluate policy %s for environment %s: %w", id, env, evalErr)
+		}
+
+		result := models.PolicyResult{
+			PolicyId:        id,
+			PolicyName:      policy.Name,
+			ExternalLink:    policy.ExternalLink,
+			OverrideCommand: policy.Enforcement.Override.Comment,
+		}
+		if evalErr != nil {
+			logger.WithField("env", env).WithField("policyId", id).WithField("error", evalErr).Error("Policy failed to evaluate, continuing")
+			excerpt := failure.Excerpt(evalErr)
+			result.FailMessages = []string{"Policy evaluation failed: " + excerpt}
+			result.EvalError = excerpt
+		} else {
+			result.Violations = violations
+			result.FailMessages = violationMessages(violations)
+			result.IsPassing = len(result.FailMessages) == 0
+		}
+		envToPolicyIdToResult[env][id] = result
+	}
+	return nil
+}
+
+// destructiveViolations returns a violation per removed resource of the kinds, in manifest order
+func destructiveViolations(changes []manifest.Change, kinds []string) []models.PolicyViolation {
+	violations := []models.PolicyViolation{}
+	for _, change := range changes {
+		if change.After != nil || !slices.Contains(kinds, change.Before.Kind) {
+			continue
+		}
+		message := destructiveMessages[change.Before.Kind]
+		if message == "" {
+			message = "deletes the resource and the data it holds"
+		}
+		violations = append(violations, models.PolicyViolation{
+			Message:    fmt.Sprintf("%s is removed, merging %s", change.ID, message),
+			ResourceID: change.ID,
+		})
+	}
+	return violations
+}
@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestGeneratePolicyEvalResult_DestructiveChanges(t *testing.T) {
+	before := []byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+  namespace: app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: app
+`)
+	e := NewPolicyEvaluator("")
+	if err := e.registerDestructiveChangesPolicy(); err != nil {
+		t.Fatalf("registerDestructiveChangesPolicy() error = %v", err)
+	}
+	build := models.BuildManifestResult{EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+		"prod": {OverlayKey: "prod", BeforeManifest: before},
+		"stg":  {OverlayKey: "stg", BeforeManifest: before, AfterManifest: before},
+	}}
+
+	eval, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+	if err != nil {
+		t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+	}
+	blocking := eval.PolicyMatrix["prod"].BlockingPolicies
+	if len(blocking) != 1 || blocking[0].PolicyId != DESTRUCTIVE_CHANGES_POLICY_ID {
+		t.Fatalf("prod blocking policies = %v, want the destructive changes policy", blocking)
+	}
+	want := []string{
+		"Namespace/app is removed, merging deletes all the resources of the namespace",
+		"PersistentVolumeClaim/app/data is removed, merging may delete the volume and its data, depending on its reclaim policy",
+	}
+	if got := blocking[0].FailMessages; !reflect.DeepEqual(got, want) {
+		t.Errorf("prod fail messages = %v, want %v", got, want)
+	}
+	if got := blocking[0].OverrideCommand; got != DESTRUCTIVE_CHANGES_OVERRIDE_COMMAND {
+		t.Errorf("override command = %s, want the default command", got)
+	}
+	if stg := eval.PolicyMatrix["stg"].BlockingPolicies; len(stg) != 1 || !stg[0].IsPassing {
+		t.Errorf("stg blocking policies = %v, want the passing destructive changes policy", stg)
+	}
+}
+
+func TestRegisterDestructiveChangesPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     models.ComplianceConfig
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name:    "registered first",
+			cfg:     models.ComplianceConfig{Policies: map[string]models.PolicyConfig{"ha": {}}, PolicyIDs: []string{"ha"}},
+			wantIDs: []string{DESTRUCTIVE_CHANGES_POLICY_ID, "ha"},
+		},
+		{
+			name: "disabled",
+			cfg: models.ComplianceConfig{Policies: map[string]models.PolicyConfig{"ha": {}}, PolicyIDs: []string{"ha"},
+				DestructiveChanges: &models.DestructiveChangesConfig{Disabled: true}},
+			wantIDs: []string{"ha"},
+		},
+		{
+			name: "reserved id",
+			cfg: models.ComplianceConfig{Policies: map[string]models.PolicyConfig{DESTRUCTIVE_CHANGES_POLICY_ID: {}},
+				PolicyIDs: []string{DESTRUCTIVE_CHANGES_POLICY_ID}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluator("")
+			e.data.ComplianceConfig = tt.cfg
+			err := e.registerDestructiveChangesPolicy()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("registerDestructiveChangesPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(e.data.ComplianceConfig.PolicyIDs, tt.wantIDs) {
+				t.Errorf("policy ids = %v, want %v", e.data.ComplianceConfig.PolicyIDs, tt.wantIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/loader"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/tester"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	POLICY_ENGINE_CONFTEST = "conftest" // a conftest process per policy evaluation
+	POLICY_ENGINE_EMBEDDED = "embedded" // the OPA rego package, each policy compiled once and evaluated in-process
+)
+
+// POLICY_ENGINES are the supported engines of the "opa" policies
+var POLICY_ENGINES = []string{POLICY_ENGINE_CONFTEST, POLICY_ENGINE_EMBEDDED}
+
+// EMBEDDED_INPUT_PATH is the path of the manifest in the combined input of the embedded engine, conftest gives
+// the path of its temp file
+const EMBEDDED_INPUT_PATH = "manifest.yaml"
+
+// failureRulePattern matches the rules producing failures, like conftest: deny, violation and their _suffixed variants
+var failureRulePattern = regexp.MustCompile(`^(deny|violation)(_[a-zA-Z0-9]+)*$`)
+
+// regoFailure is a failure of a deny or violation rule: its message, and the query and the other fields of a
+// structured result as metadata
+type regoFailure struct {
+	Msg      string                 `json:"msg"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// compiledPolicy is an "opa" policy compiled with the libraries, and the queries of its failure rules
+type compiledPolicy struct {
+	compiler *ast.Compiler
+	queries  []string // e.g. data.main.deny
+}
+
+// regoSession evaluates the "opa" policies against an input, the manifest of an overlay or the input of the
+// cross-environment policies, with the policy context as data.context when set. Close removes its files
+type regoSession struct {
+	e  *PolicyEvaluator
+	pc *models.PolicyContext
+
+	// conftest engine, the files given to conftest
+	inputPath  string
+	contextDir string
+
+	// embedded engine, the decoded documents of the input and the data
+	documents []interface{}
+	store     storage.Store
+}
+
+// newRegoSession prepares the evaluation of content, written to a temp file named after pattern for conftest
+// (its extension selects the parser), decoded for the embedded engine
+func (e *PolicyEvaluator) newRegoSession(content []byte, pattern string, pc *models.PolicyContext) (*regoSession, error) {
+	s := &regoSession{e: e, pc: pc}
+	if e.options.Engine == POLICY_ENGINE_EMBEDDED {
+		documents, err := decodeDocuments(content)
+		if err != nil {
+			return nil, err
+		}
+		store, err := e.regoStore(pc)
+		if err != nil {
+			return nil, err
+		}
+		s.documents, s.store = documents, store
+		return s, nil
+	}
+
+	tmpFile, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	s.inputPath = tmpFile.Name()
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		s.close()
+		return nil, fmt.Errorf("failed to write input to temp file: %w", err)
+	}
+	if pc != nil {
+		if s.contextDir, err = writePolicyContext(*pc); err != nil {
+			s.close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// failures evaluates a policy, with --combine semantics but for the cross-environment ones
+func (s *regoSession) failures(ctx context.Context, id string) ([]regoFailure, error) {
+	combine := !s.e.isCrossEnvironment(id)
+	if s.store == nil {
+		return s.e.conftestFailures(ctx, id, s.inputPath, s.contextDir, combine)
+	}
+	return s.e.embeddedFailures(ctx, id, s.documents, s.store, combine)
+}
+
+func (s *regoSession) close() {
+	if s.inputPath != "" {
+		_ = os.Remove(s.inputPath)
+	}
+	if s.contextDir != "" {
+		_ = os.RemoveAll(s.contextDir)
+	}
+}
+
+// decodeDocuments decodes the non-empty documents of a YAML (or JSON) input, as conftest parses its files
+func decodeDocuments(content []byte) ([]interface{}, error) {
+	var documents []interface{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for i := 0; ; i++ {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return documents, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse input document %d: %w", i, err)
+		}
+		if document != nil {
+			documents = append(documents, document)
+		}
+	}
+}
+
+// regoStore returns the data of an embedded evaluation: the external data and the policy context
+func (e *PolicyEvaluator) regoStore(pc *models.PolicyContext) (storage.Store, error) {
+	e.mu.Lock()
+	if e.externalDocuments == nil && e.data.externalDataDir != "" {
+		result, err := loader.NewFileLoader().Filtered([]string{e.data.externalDataDir}, onlyDataFiles)
+		if err != nil {
+			e.mu.Unlock()
+			return nil, fmt.Errorf("failed to load the external data: %w", err)
+		}
+		e.externalDocuments = result.Documents
+	}
+	documents := maps.Clone(e.externalDocuments)
+	e.mu.Unlock()
+	if documents == nil {
+		documents = make(map[string]interface{})
+	}
+	if pc != nil {
+		// the JSON of the context, as conftest reads it from writePolicyContext
+		content, err := json.Marshal(pc)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := json.Unmarshal(content, &value); err != nil {
+			return nil, err
+		}
+		documents[POLICY_CONTEXT_DATA_NAME] = value
+	}
+	return inmem.NewFromObject(documents), nil
+}
+
+// compiledPolicyOf compiles a policy with the libraries on its first evaluation
+func (e *PolicyEvaluator) compiledPolicyOf(id string) (*compiledPolicy, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if compiled, ok := e.compiled[id]; ok {
+		return compiled, nil
+	}
+	result, err := loader.NewFileLoader().Filtered(append([]string{e.data.fullPathToPolicy[id]}, e.data.libraryPaths...), onlyPolicyFiles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the policy: %w", err)
+	}
+	compiler, err := result.Compiler()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile the policy: %w", err)
+	}
+	compiled := &compiledPolicy{compiler: compiler, queries: failureQueries(compiler, e.data.ComplianceConfig.Policies[id].Namespaces)}
+	e.compiled[id] = compiled
+	return compiled, nil
+}
+
+// failureQueries returns the queries of the failure rules of the namespaces, of all the packages when none is
+// configured, in module then rule order
+func failureQueries(compiler *ast.Compiler, namespaces []string) []string {
+	names := slices.Sorted(maps.Keys(compiler.Modules))
+	var queries []string
+	for _, name := range names {
+		module := compiler.Modules[name]
+		pkg := strings.TrimPrefix(module.Package.Path.String(), "data.")
+		if len(namespaces) > 0 && !slices.Contains(namespaces, pkg) {
+			continue
+		}
+		for _, rule := range module.Rules {
+			ref := rule.Head.Ref()
+			if len(ref) != 1 || !failureRulePattern.MatchString(ref[0].Value.String()) {
+				continue
+			}
+			if query := "data." + pkg + "." + ref[0].Value.String(); !slices.Contains(queries, query) {
+				queries = append(queries, query)
+			}
+		}
+	}
+	return queries
+}
+
+// embeddedFailures evaluates a policy in-process, the documents combined into one input like conftest --combine
+// or each its own input. The evaluation is bounded by the timeout of the exec limits, as a conftest process
+func (e *PolicyEvaluator) embeddedFailures(
+	ctx context.Context,
+	id string,
+	documents []interface{},
+	store storage.Store,
+	combine bool,
+) ([]regoFailure, error) {
+	logger.Infof("evaluating policy %s", id)
+	compiled, err := e.compiledPolicyOf(id)
+	if err != nil {
+		return nil, err
+	}
+	if timeout := e.options.ExecLimits.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	inputs := documents
+	if combine {
+		combined := make([]interface{}, 0, len(documents))
+		for _, document := range documents {
+			combined = append(combined, map[string]interface{}{"path": EMBEDDED_INPUT_PATH, "contents": document})
+		}
+		inputs = []interface{}{combined}
+	}
+	failures := []regoFailure{}
+	for _, input := range inputs {
+		value, err := ast.InterfaceToValue(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert the input: %w", err)
+		}
+		for _, query := range compiled.queries {
+			resultSet, err := rego.New(
+				rego.Query(query), rego.Compiler(compiled.compiler), rego.Store(store), rego.ParsedInput(value),
+			).Eval(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate %s: %w", query, err)
+			}
+			for _, result := range resultSet {
+				for _, expression := range result.Expressions {
+					// a set rule is a list of results, other values (e.g. an undefined or boolean rule) raise none
+					values, _ := expression.Value.([]interface{})
+					for _, value := range values {
+						failure, err := failureOf(value, query)
+						if err != nil {
+							return nil, err
+						}
+						failures = append(failures, failure)
+					}
+				}
+			}
+		}
+	}
+	return failures, nil
+}
+
+// failureOf converts a result of a failure rule: a message, or a structured result with a msg field
+func failureOf(value interface{}, query string) (regoFailure, error) {
+	failure := regoFailure{Metadata: map[string]interface{}{"query": query}}
+	switch v := value.(type) {
+	case string:
+		failure.Msg = v
+	case map[string]interface{}:
+		msg, ok := v["msg"].(string)
+		if !ok {
+			return failure, fmt.Errorf("%s: structured result without a string msg field: %v", query, v)
+		}
+		failure.Msg = msg
+		for key, field := range v {
+			if key != "msg" {
+				failure.Metadata[key] = field
+			}
+		}
+	default:
+		return failure, fmt.Errorf("%s: result must be a string or an object with a msg field, got %v", query, v)
+	}
+	return failure, nil
+}
+
+// verifyLibrariesEmbedded compiles the libraries and runs their tests in-process, as conftest verify
+func (e *PolicyEvaluator) verifyLibrariesEmbedded(ctx context.Context) error {
+	paths := slices.Clone(e.data.libraryPaths)
+	if e.data.externalDataDir != "" {
+		paths = append(paths, e.data.externalDataDir)
+	}
+	results, err := tester.Run(ctx, paths...)
+	if err != nil {
+		return fmt.Errorf("libraries failed to compile: %w", err)
+	}
+	var failed []string
+	for _, result := range results {
+		if result.Fail || result.Error != nil {
+			failed = append(failed, result.String())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("libraries failed their tests:\n%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// onlyPolicyFiles skips all but the Rego files of the policies and libraries, and their tests
+func onlyPolicyFiles(abspath string, info fs.FileInfo, depth int) bool {
+	return !info.IsDir() && (!strings.HasSuffix(info.Name(), ".rego") || strings.HasSuffix(info.Name(), "_test.rego"))
+}
+
+// onlyDataFiles skips all but the JSON and YAML files of the data directories
+func onlyDataFiles(abspath string, info fs.FileInfo, depth int) bool {
+	return !info.IsDir() && !slices.Contains([]string{".json", ".yaml", ".yml"}, filepath.Ext(info.Name()))
+}
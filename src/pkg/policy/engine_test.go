@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func writePolicyFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEvaluateViolations_EmbeddedEngine(t *testing.T) {
+	dir := t.TempDir()
+	writePolicyFiles(t, dir, map[string]string{
+		COMPLIANCE_CONFIG_FILENAME: `policies:
+  ha:
+    name: HA
+    type: opa
+    filePath: ha.rego
+  labels:
+    name: Labels
+    type: opa
+    filePath: labels.rego
+    namespaces: [k8s.labels]
+`,
+		"ha.rego": `package main
+
+import rego.v1
+import data.lib.k8s
+
+deny contains msg if {
+	some doc in k8s.deployments
+	data.context.environment == "prod"
+	doc.spec.replicas < 2
+	msg := sprintf("Deployment %s must have at least 2 replicas in prod", [doc.metadata.name])
+}
+`,
+		"ha_test.rego": "package main\n",
+		"labels.rego": `package k8s.labels
+
+import rego.v1
+
+violation_team contains {"msg": msg, "resource": {"kind": doc.kind, "name": doc.metadata.name}} if {
+	some item in input
+	doc := item.contents
+	not doc.metadata.labels.team
+	msg := sprintf("%s %s has no team label", [doc.kind, doc.metadata.name])
+}
+
+warn contains "warnings are not failures"
+`,
+		"labels_test.rego": "package k8s.labels\n",
+		"lib/k8s.rego": `package lib.k8s
+
+import rego.v1
+
+deployments contains item.contents if {
+	some item in input
+	item.contents.kind == "Deployment"
+}
+`,
+		"lib/k8s_test.rego": `package lib.k8s
+
+import rego.v1
+
+test_deployments if {
+	count(deployments) == 1 with input as [{"contents": {"kind": "Deployment"}}, {"contents": {"kind": "Service"}}]
+}
+`,
+	})
+	e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{Engine: POLICY_ENGINE_EMBEDDED})
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	if err := e.VerifyLibraries(context.Background()); err != nil {
+		t.Fatalf("VerifyLibraries() error = %v", err)
+	}
+
+	mf := []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  labels:
+    team: payments
+`)
+	results, _, err := e.evaluateViolations(context.Background(), mf, &models.PolicyContext{Environment: "prod"}, false)
+	if err != nil {
+		t.Fatalf("evaluateViolations() error = %v", err)
+	}
+	if got := violationMessages(results["labels"]); !reflect.DeepEqual(got, []string{"Deployment web has no team label"}) {
+		t.Errorf("labels messages = %v, want the Deployment without team label", got)
+	}
+	if got := results["labels"][0].ResourceID; got != "Deployment/web" {
+		t.Errorf("labels resource = %s, want the resource of the structured result", got)
+	}
+	if got := violationMessages(results["ha"]); !reflect.DeepEqual(got, []string{"Deployment web must have at least 2 replicas in prod"}) {
+		t.Errorf("ha messages = %v, want the prod replicas of the context", got)
+	}
+	if len(e.compiled) != 2 {
+		t.Errorf("compiled %d policies, want each compiled once", len(e.compiled))
+	}
+}
+
+func TestFailureOf(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    regoFailure
+		wantErr bool
+	}{
+		{"msg", regoFailure{Msg: "msg", Metadata: map[string]interface{}{"query": "data.main.deny"}}, false},
+		{map[string]interface{}{"msg": "msg", "environment": "prod"},
+			regoFailure{Msg: "msg", Metadata: map[string]interface{}{"query": "data.main.deny", "environment": "prod"}}, false},
+		{map[string]interface{}{"message": "msg"}, regoFailure{}, true},
+		{true, regoFailure{}, true},
+	}
+	for _, tt := range tests {
+		got, err := failureOf(tt.value, "data.main.deny")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("failureOf(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("failureOf(%v) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -2,15 +2,27 @@ package policy
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"os/exec"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/datasource"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/failure"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
 	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
 	"gopkg.in/yaml.v2"
 
 	log "github.com/sirupsen/logrus"
@@ -24,6 +36,21 @@ const (
 	COMPLIANCE_CONFIG_FILENAME = "compliance-config.yaml"
 )
 
+// DEFAULT_LIBRARY_DIR is the library directory used when the compliance config sets no libraries
+const DEFAULT_LIBRARY_DIR = "lib"
+
+// OVERRIDE_REVOKE_SUFFIX follows the override command of a policy to revoke its override, e.g. "/sp-override-ha revoke"
+const OVERRIDE_REVOKE_SUFFIX = " revoke"
+
+// POLICY_CONTEXT_DATA_NAME is the key of the evaluation context under `data` in Rego, reserved for external data
+const POLICY_CONTEXT_DATA_NAME = "context"
+
+// regoPackagePattern matches Rego package paths, e.g. "main" or "k8s.ha"
+var regoPackagePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// externalDataNamePattern ensures external data can be referenced as data.<name> in Rego
+var externalDataNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // // PolicyEvaluator defines the interface for policy evaluation operations
 // type PolicyEvaluator interface {
 // 	// LoadAndValidate loads and validates the compliance configuration
@@ -43,10 +70,21 @@ type PolicyEvaluatorInterface interface {
 	GeneratePolicyEvalResultForManifests(
 		ctx context.Context,
 		envManifests map[string][]byte,
-		ghComments []string,
+		ghComments []*models.Comment,
 	) (*models.PolicyEvaluation, error)
 }
 
+const (
+	POLICY_TYPE_OPA          = "opa"          // Rego policy evaluated with conftest or the embedded engine
+	POLICY_TYPE_IMAGES       = "images"       // built-in container image policy
+	POLICY_TYPE_METADATA     = "metadata"     // built-in labels and annotations policy
+	POLICY_TYPE_AVAILABILITY = "availability" // built-in replicas, HPA and PDB coherence policy
+	POLICY_TYPE_MANUAL       = "manual"       // checklist item ticked by a reviewer in the comment
+	POLICY_TYPE_PACK         = "pack"         // rule of a built-in policy pack, registered from policyPacks only
+
+	POLICY_TYPE_DESTRUCTIVE = "destructive" // built-in destructive changes policy, registered by the evaluator only
+)
+
 const (
 	POLICY_LEVEL_RECOMMEND     = "RECOMMEND"
 	POLICY_LEVEL_WARNING       = "WARNING"
@@ -56,36 +94,99 @@ const (
 	POLICY_LEVEL_UNKNOWN       = ""
 )
 
+// ESCALATION_LEVELS are the enforcement levels a tier can raise, from the least to the most strict
+var ESCALATION_LEVELS = []string{POLICY_LEVEL_NOT_IN_EFFECT, POLICY_LEVEL_RECOMMEND, POLICY_LEVEL_WARNING, POLICY_LEVEL_BLOCK}
+
 type EvaluatorData struct {
 	models.ComplianceConfig
 
 	// map policy id to full path to policy file
 	fullPathToPolicy    map[string]string
+	sourceLinkOfPolicy  map[string]string
+	libraryPaths        []string // full paths of the library directories
 	evalFailMsgOfPolicy map[string][]string
 
 	// enforcements levels of policies Ids
 	overrideCmdToPolicyId map[string]string
+	ticketPattern         *regexp.Regexp // overrideTickets.pattern, anchored
+
+	// directory containing fetched external data files, passed to conftest as --data or loaded by the embedded engine
+	externalDataDir     string
+	externalDataRecords []models.ExternalDataRecord
+}
+
+// EvaluatorOptions holds optional settings of the PolicyEvaluator
+type EvaluatorOptions struct {
+	ExternalDataCacheDir string           // Cache directory for external data sources, empty uses a temp dir
+	Engine               string           // POLICY_ENGINE_CONFTEST or POLICY_ENGINE_EMBEDDED, empty uses conftest
+	ExecLimits           sandbox.Limits   // Resource limits applied to each conftest process, the timeout to each embedded evaluation
+	Executor             sandbox.Executor // Runs conftest, nil uses sandbox.DefaultExecutor
+	Clock                Clock            // Time source of enforcement levels, nil uses SystemClock
+	ContinueOnError      bool             // Record policies failing to evaluate as errored instead of failing the run
+	NoPolicies           bool             // Diff-only runs: LoadAndValidate reads no compliance config, no policy is evaluated
+
+	DecisionLogger       DecisionLogger    // Ships a decision log event per policy evaluation, nil disables decision logs
+	DecisionLogLabels    map[string]string // Labels added to every decision log event (e.g. id, version)
+	DecisionLogOmitInput bool              // Leave the evaluated manifests out of the decision log events
 }
 
 type PolicyEvaluator struct {
-	policiesPath string
-	data         EvaluatorData
+	policiesPath  string
+	options       EvaluatorOptions
+	data          EvaluatorData
+	policyContext models.PolicyContext   // run-wide part of the context injected as data.context
+	selector      *models.PolicySelector // policies selected by the service metadata, nil selects all
+
+	checklist     map[string]bool                     // ticked checklist items, by "manual" policy id
+	evalDurations map[string]map[string]time.Duration // overlay key -> policy id -> evaluation duration
+	imageBumpEnvs map[string]bool                     // environments of the last evaluation changing only container images
+	tickets       map[string]ticketCheck              // Jira lookups of the override tickets, by ticket
+
+	compiled          map[string]*compiledPolicy // "opa" policies compiled by the embedded engine, by policy id
+	externalDocuments map[string]interface{}     // external data loaded by the embedded engine
+	policyPacks       []models.PolicyPack        // policy packs enabled by the compliance config, see registerPolicyPacks
+
+	// EvaluateObject is safe for concurrent use, e.g. by the admission requests sharing the evaluator of a version
+	// of the policies: mu guards the caches filled by the evaluations, external the external data a new fetch replaces
+	mu       sync.Mutex
+	external sync.RWMutex
 }
 
 func NewPolicyEvaluator(policiesPath string) *PolicyEvaluator {
+	return NewPolicyEvaluatorWithOptions(policiesPath, EvaluatorOptions{})
+}
+
+// NewPolicyEvaluatorWithOptions creates a new policy evaluator with custom options
+func NewPolicyEvaluatorWithOptions(policiesPath string, options EvaluatorOptions) *PolicyEvaluator {
 	return &PolicyEvaluator{
 		policiesPath: policiesPath,
+		options:      options,
 		data: EvaluatorData{
 			fullPathToPolicy:      make(map[string]string),
+			sourceLinkOfPolicy:    make(map[string]string),
 			evalFailMsgOfPolicy:   make(map[string][]string),
 			overrideCmdToPolicyId: make(map[string]string),
 		},
+		evalDurations: make(map[string]map[string]time.Duration),
+		compiled:      make(map[string]*compiledPolicy),
 	}
 }
 
+// now returns the time enforcement levels are evaluated at
+func (e *PolicyEvaluator) now() time.Time {
+	if e.options.Clock == nil {
+		return SystemClock.Now()
+	}
+	return e.options.Clock.Now()
+}
+
 // LoadAndValidate loads and validates the compliance configuration
 func (e *PolicyEvaluator) LoadAndValidate() error {
 	logger.Info("LoadAndValidate: starting...")
+	if e.options.NoPolicies {
+		logger.Info("LoadAndValidate: policies disabled, nothing to load.")
+		return nil
+	}
 
 	// Load configuration
 	logger.Info("LoadAndValidate: loading compliance configuration...")
@@ -93,15 +194,27 @@ func (e *PolicyEvaluator) LoadAndValidate() error {
 		return err
 	}
 
-	// Validate configuration structure
+	// Validate configuration structure, with the policies of the packs
 	logger.Info("LoadAndValidate: validating compliance configuration...")
+	if err := e.registerPolicyPacks(); err != nil {
+		return err
+	}
 	if err := e.validateComplianceConfig(); err != nil {
 		return err
 	}
+	if err := e.registerDestructiveChangesPolicy(); err != nil {
+		return err
+	}
 
 	// Validate policy files exist and check for tests
 	logger.Info("LoadAndValidate: validating policy files...")
 	for id, policy := range e.data.ComplianceConfig.Policies {
+		if err := e.registerOverrideCommand(id, policy); err != nil {
+			return err
+		}
+		if policy.Type != POLICY_TYPE_OPA {
+			continue // built-in policies have no file
+		}
 		policyPath := filepath.Join(e.policiesPath, policy.FilePath)
 		if _, err := os.Stat(policyPath); os.IsNotExist(err) {
 			return fmt.Errorf("policy %s: file not found: %s", id, policyPath)
@@ -121,21 +234,97 @@ func (e *PolicyEvaluator) LoadAndValidate() error {
 
 		// Set full path to policy file
 		e.data.fullPathToPolicy[id] = policyPath
+		if link := policySourceLink(e.data.ComplianceConfig.PolicySource, policy.FilePath, policyPath); link != "" {
+			e.data.sourceLinkOfPolicy[id] = link
+		}
+	}
 
-		// check override cmd
-		if policy.Enforcement.Override.Comment == "" {
-			continue
+	libraryPaths, err := e.resolveLibraries()
+	if err != nil {
+		return err
+	}
+	e.data.libraryPaths = libraryPaths
+
+	logger.Infof("LoadAndValidate: done, loaded %d policies and %d libraries.", len(e.data.ComplianceConfig.Policies), len(libraryPaths))
+	return nil
+}
+
+// registerOverrideCommand maps the override command of a policy to it, commands must be unique
+func (e *PolicyEvaluator) registerOverrideCommand(id string, policy models.PolicyConfig) error {
+	if policy.Enforcement.Override.Comment == "" {
+		return nil
+	}
+	if _, ok := e.data.overrideCmdToPolicyId[policy.Enforcement.Override.Comment]; ok {
+		return fmt.Errorf("policy %s: use another command, this override command already exists: %s", id, policy.Enforcement.Override.Comment)
+	}
+	e.data.overrideCmdToPolicyId[policy.Enforcement.Override.Comment] = id
+	return nil
+}
+
+// resolveLibraries returns the full paths of the configured library directories, which must exist,
+// or of DEFAULT_LIBRARY_DIR if none is configured and it exists
+func (e *PolicyEvaluator) resolveLibraries() ([]string, error) {
+	libraries := e.data.ComplianceConfig.Libraries
+	if len(libraries) == 0 {
+		defaultPath := filepath.Join(e.policiesPath, DEFAULT_LIBRARY_DIR)
+		if info, err := os.Stat(defaultPath); err == nil && info.IsDir() {
+			return []string{defaultPath}, nil
 		}
-		if _, ok := e.data.overrideCmdToPolicyId[policy.Enforcement.Override.Comment]; ok {
-			return fmt.Errorf("policy %s: use another command, this override command already exists: %s", id, policy.Enforcement.Override.Comment)
+		return nil, nil
+	}
+	paths := make([]string, 0, len(libraries))
+	for _, library := range libraries {
+		libraryPath := filepath.Join(e.policiesPath, library)
+		info, err := os.Stat(libraryPath)
+		if err != nil {
+			return nil, fmt.Errorf("library %s: %w", library, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("library %s: not a directory", library)
 		}
-		e.data.overrideCmdToPolicyId[policy.Enforcement.Override.Comment] = id
+		paths = append(paths, libraryPath)
 	}
+	return paths, nil
+}
 
-	logger.Infof("LoadAndValidate: done, loaded %d policies.", len(e.data.ComplianceConfig.Policies))
+// VerifyLibraries checks that the libraries compile (and pass their tests) with conftest verify, or in-process
+// with the embedded engine, so that a broken helper fails the run early instead of every policy evaluation.
+// No-op without libraries
+func (e *PolicyEvaluator) VerifyLibraries(ctx context.Context) error {
+	if len(e.data.libraryPaths) == 0 {
+		return nil
+	}
+	logger.Infof("VerifyLibraries: verifying %d libraries...", len(e.data.libraryPaths))
+	if e.options.Engine == POLICY_ENGINE_EMBEDDED {
+		if err := e.verifyLibrariesEmbedded(ctx); err != nil {
+			return err
+		}
+		logger.Info("VerifyLibraries: done.")
+		return nil
+	}
+	args := []string{"verify"}
+	for _, libraryPath := range e.data.libraryPaths {
+		args = append(args, "--policy", libraryPath)
+	}
+	if e.data.externalDataDir != "" {
+		args = append(args, "--data", e.data.externalDataDir)
+	}
+	res, err := e.conftest(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("conftest verify execution failed: %w", err)
+	}
+	if res.ExitCode != 0 {
+		return fmt.Errorf("libraries failed to compile or pass their tests:\n%s%s", res.Stdout, res.Stderr)
+	}
+	logger.Info("VerifyLibraries: done.")
 	return nil
 }
 
+// conftest runs conftest with the executor and limits of the evaluator
+func (e *PolicyEvaluator) conftest(ctx context.Context, args ...string) (*sandbox.Result, error) {
+	return sandbox.OrDefault(e.options.Executor).Run(ctx, sandbox.Command{Name: "conftest", Args: args, Limits: e.options.ExecLimits})
+}
+
 // LoadComplianceConfig loads the compliance configuration from a YAML file
 func (e *PolicyEvaluator) loadComplianceConfig() error {
 	configPath := filepath.Join(e.policiesPath, COMPLIANCE_CONFIG_FILENAME)
@@ -154,21 +343,31 @@ func (e *PolicyEvaluator) loadComplianceConfig() error {
 		return fmt.Errorf("failed to parse compliance config for ordering: %w", err)
 	}
 
-	// Find the "policies" key and extract ordered policy IDs
+	// Find the "policies" and "profiles" keys and extract ordered IDs
+	e.data.ComplianceConfig.PolicyIDs = orderedKeys(rawConfig, "policies")
+	e.data.ComplianceConfig.ProfileNames = orderedKeys(rawConfig, "profiles")
+	e.data.ComplianceConfig.PolicyPackNames = orderedKeys(rawConfig, "policyPacks")
+
+	return nil
+}
+
+// orderedKeys returns the keys of a top-level mapping of the config in file order, nil if absent
+func orderedKeys(rawConfig yaml.MapSlice, name string) []string {
 	for _, item := range rawConfig {
-		if key, ok := item.Key.(string); ok && key == "policies" {
-			if policiesSlice, ok := item.Value.(yaml.MapSlice); ok {
-				e.data.ComplianceConfig.PolicyIDs = make([]string, 0, len(policiesSlice))
-				for _, policyItem := range policiesSlice {
-					if policyID, ok := policyItem.Key.(string); ok {
-						e.data.ComplianceConfig.PolicyIDs = append(e.data.ComplianceConfig.PolicyIDs, policyID)
-					}
+		if key, ok := item.Key.(string); ok && key == name {
+			slice, ok := item.Value.(yaml.MapSlice)
+			if !ok {
+				return nil
+			}
+			keys := make([]string, 0, len(slice))
+			for _, entry := range slice {
+				if k, ok := entry.Key.(string); ok {
+					keys = append(keys, k)
 				}
 			}
-			break
+			return keys
 		}
 	}
-
 	return nil
 }
 
@@ -185,11 +384,43 @@ func (e *PolicyEvaluator) validateComplianceConfig() error {
 		if policy.Type == "" {
 			return fmt.Errorf("policy %s: type is required", id)
 		}
-		if policy.Type != "opa" {
-			return fmt.Errorf("policy %s: unsupported type %s (only 'opa' is supported)", id, policy.Type)
+		switch policy.Type {
+		case POLICY_TYPE_OPA:
+			if policy.FilePath == "" {
+				return fmt.Errorf("policy %s: filePath is required", id)
+			}
+			if policy.Input != "" && !slices.Contains(POLICY_INPUTS, policy.Input) {
+				return fmt.Errorf("policy %s: unsupported input %s (must be one of %v)", id, policy.Input, POLICY_INPUTS)
+			}
+		case POLICY_TYPE_IMAGES:
+			if err := validateImagePolicy(policy.Images); err != nil {
+				return fmt.Errorf("policy %s: %w", id, err)
+			}
+		case POLICY_TYPE_METADATA:
+			if err := validateMetadataPolicy(policy.Metadata); err != nil {
+				return fmt.Errorf("policy %s: %w", id, err)
+			}
+		case POLICY_TYPE_AVAILABILITY:
+			// all the settings are optional
+		case POLICY_TYPE_PACK:
+			if policy.PackRule == "" {
+				return fmt.Errorf("policy %s: type %s is reserved to the rules of policyPacks", id, POLICY_TYPE_PACK)
+			}
+		case POLICY_TYPE_MANUAL:
+			if err := validateManualPolicy(policy); err != nil {
+				return fmt.Errorf("policy %s: %w", id, err)
+			}
+		default:
+			return fmt.Errorf("policy %s: unsupported type %s (must be '%s', '%s', '%s', '%s' or '%s')",
+				id, policy.Type, POLICY_TYPE_OPA, POLICY_TYPE_IMAGES, POLICY_TYPE_METADATA, POLICY_TYPE_AVAILABILITY, POLICY_TYPE_MANUAL)
 		}
-		if policy.FilePath == "" {
-			return fmt.Errorf("policy %s: filePath is required", id)
+		if policy.Input != "" && policy.Type != POLICY_TYPE_OPA {
+			return fmt.Errorf("policy %s: input is only supported by '%s' policies", id, POLICY_TYPE_OPA)
+		}
+		for _, namespace := range policy.Namespaces {
+			if !regoPackagePattern.MatchString(namespace) {
+				return fmt.Errorf("policy %s: namespace %q is not a valid Rego package", id, namespace)
+			}
 		}
 
 		// Validate enforcement dates are in order if set
@@ -210,13 +441,299 @@ func (e *PolicyEvaluator) validateComplianceConfig() error {
 		}
 	}
 
+	seenDataNames := make(map[string]bool)
+	for i, src := range e.data.ComplianceConfig.ExternalData {
+		if !externalDataNamePattern.MatchString(src.Name) {
+			return fmt.Errorf("externalData[%d]: name %q must be a valid Rego identifier", i, src.Name)
+		}
+		if src.Name == POLICY_CONTEXT_DATA_NAME {
+			return fmt.Errorf("externalData[%d]: name %q is reserved for the evaluation context", i, src.Name)
+		}
+		if seenDataNames[src.Name] {
+			return fmt.Errorf("externalData[%d]: duplicated name %s", i, src.Name)
+		}
+		seenDataNames[src.Name] = true
+		if !strings.HasPrefix(src.URL, "https://") && !strings.HasPrefix(src.URL, "http://") {
+			return fmt.Errorf("externalData %s: url must be http(s), got: %q", src.Name, src.URL)
+		}
+	}
+
+	if err := validatePolicySource(e.data.ComplianceConfig.PolicySource); err != nil {
+		return err
+	}
+
+	for name, profile := range e.data.ComplianceConfig.Profiles {
+		switch profile.FailOn {
+		case "", models.FailOnBlock, models.FailOnWarning, models.FailOnRecommend, models.FailOnNone:
+		default:
+			return fmt.Errorf("profile %s: unsupported failOn %q (must be block, warning, recommend or none)", name, profile.FailOn)
+		}
+		if profile.DiffLimit < 0 {
+			return fmt.Errorf("profile %s: diffLimit cannot be negative", name)
+		}
+		for _, pattern := range profile.Environments {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("profile %s: invalid environment pattern %q: %w", name, pattern, err)
+			}
+		}
+	}
+
+	for name, tier := range e.data.ComplianceConfig.Tiers {
+		for from, to := range tier.Escalate {
+			fromIdx, toIdx := slices.Index(ESCALATION_LEVELS, from), slices.Index(ESCALATION_LEVELS, to)
+			if fromIdx < 0 || toIdx < 0 {
+				return fmt.Errorf("tier %s: unsupported escalation %s -> %s (levels must be one of %v)", name, from, to, ESCALATION_LEVELS)
+			}
+			if toIdx <= fromIdx {
+				return fmt.Errorf("tier %s: escalation %s -> %s is not stricter", name, from, to)
+			}
+		}
+		for _, pattern := range tier.Policies {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("tier %s: invalid policy pattern %q: %w", name, pattern, err)
+			}
+		}
+	}
+
+	for i, env := range e.data.ComplianceConfig.Environments {
+		if env.Match == "" {
+			return fmt.Errorf("environments[%d]: match is required", i)
+		}
+		if _, err := path.Match(env.Match, ""); err != nil {
+			return fmt.Errorf("environments[%d]: invalid match pattern %q: %w", i, env.Match, err)
+		}
+	}
+
+	if tz := e.data.ComplianceConfig.Timezone; tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", tz, err)
+		}
+	}
+
+	categories := diff.DefaultRiskClassification()
+	for i, rule := range e.data.ComplianceConfig.Reviewers {
+		if len(rule.Teams) == 0 {
+			return fmt.Errorf("reviewers[%d]: teams is required", i)
+		}
+		if len(rule.Categories) == 0 && len(rule.Kinds) == 0 && len(rule.Policies) == 0 {
+			return fmt.Errorf("reviewers[%d]: at least one of categories, kinds or policies is required", i)
+		}
+		for _, category := range rule.Categories {
+			_, isDefault := categories[category]
+			if _, isConfigured := e.data.ComplianceConfig.RiskClassification[category]; !isDefault && !isConfigured {
+				return fmt.Errorf("reviewers[%d]: unknown risk category %q", i, category)
+			}
+		}
+		for _, id := range rule.Policies {
+			if _, ok := e.data.ComplianceConfig.Policies[id]; !ok {
+				return fmt.Errorf("reviewers[%d]: unknown policy %q", i, id)
+			}
+		}
+	}
+
+	if err := e.validateOverrideTickets(); err != nil {
+		return err
+	}
+
+	if bumps := e.data.ComplianceConfig.ImageBumps; bumps != nil {
+		for _, pattern := range bumps.Repositories {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("imageBumps: invalid repository pattern %q: %w", pattern, err)
+			}
+		}
+		for _, id := range bumps.RelaxPolicies {
+			if _, ok := e.data.ComplianceConfig.Policies[id]; !ok {
+				return fmt.Errorf("imageBumps: unknown policy %q in relaxPolicies", id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// FetchExternalData fetches the configured external data sources so they are injected as OPA data on evaluation
+// Must be called after LoadAndValidate, no-op if no source is configured. A new fetch replaces the data of the
+// previous one, Close removes it
+func (e *PolicyEvaluator) FetchExternalData(ctx context.Context) error {
+	sources := e.data.ComplianceConfig.ExternalData
+	if len(sources) == 0 {
+		return nil
+	}
+	logger.Infof("FetchExternalData: fetching %d sources...", len(sources))
+
+	dataDir, err := os.MkdirTemp("", "external-data-*")
+	if err != nil {
+		return fmt.Errorf("failed to create external data directory: %w", err)
+	}
+
+	fetcher := datasource.NewFetcher(e.options.ExternalDataCacheDir)
+	records, err := fetcher.FetchAll(ctx, sources, dataDir)
+	if err != nil {
+		_ = os.RemoveAll(dataDir)
+		return err
+	}
+
+	e.external.Lock()
+	defer e.external.Unlock()
+	if e.data.externalDataDir != "" {
+		_ = os.RemoveAll(e.data.externalDataDir)
+	}
+	e.data.externalDataDir = dataDir
+	e.data.externalDataRecords = records
+	e.mu.Lock()
+	e.externalDocuments = nil
+	e.mu.Unlock()
+	logger.Info("FetchExternalData: done.")
 	return nil
 }
 
+// Close removes the external data fetched by FetchExternalData, the evaluator evaluates no policy with it after
+func (e *PolicyEvaluator) Close() error {
+	e.external.Lock()
+	defer e.external.Unlock()
+	if e.data.externalDataDir == "" {
+		return nil
+	}
+	dir := e.data.externalDataDir
+	e.data.externalDataDir = ""
+	e.mu.Lock()
+	e.externalDocuments = nil
+	e.mu.Unlock()
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove the external data directory: %w", err)
+	}
+	return nil
+}
+
+// SetPolicyContext sets the run-wide part (service, pull request) of the context injected into policies,
+// the overlay fields are filled on evaluation
+func (e *PolicyEvaluator) SetPolicyContext(pc models.PolicyContext) {
+	e.policyContext = pc
+}
+
+// SetServiceMetadata scopes the evaluation to a service: its owner and tier are added to the policy context,
+// and only the policies its selector selects are evaluated. Must be called after SetPolicyContext
+func (e *PolicyEvaluator) SetServiceMetadata(metadata *models.ServiceMetadata) {
+	if metadata == nil {
+		return
+	}
+	e.policyContext.Owner = metadata.Owner
+	e.policyContext.Tier = metadata.Tier
+	e.selector = metadata.Policies
+}
+
+// SetService moves the evaluation to the next service of a multi-service run: the service of the policy context,
+// without the service metadata scope, checklist and evaluation durations of the previous one
+func (e *PolicyEvaluator) SetService(service string) {
+	e.policyContext.Service = service
+	e.policyContext.Owner, e.policyContext.Tier = "", ""
+	e.selector = nil
+	e.checklist = nil
+	e.evalDurations = make(map[string]map[string]time.Duration)
+}
+
+// policyIDs returns the ids of the evaluated policies, in config order
+func (e *PolicyEvaluator) policyIDs() []string {
+	if e.selector == nil {
+		return e.data.ComplianceConfig.PolicyIDs
+	}
+	var ids []string
+	for _, id := range e.data.ComplianceConfig.PolicyIDs {
+		if e.selector.Selects(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// policyContextOf returns the context of an overlay, its SERVICE, CLUSTER and ENV path variables fill the matching fields
+func (e *PolicyEvaluator) policyContextOf(build models.BuildEnvManifestResult) models.PolicyContext {
+	pc := e.policyContext
+	pc.Environment = build.Environment
+	pc.OverlayKey = build.OverlayKey
+	pc.Variables = build.Variables
+	if env, ok := build.Variables["ENV"]; ok {
+		pc.Environment = env
+	}
+	if cluster, ok := build.Variables["CLUSTER"]; ok {
+		pc.Cluster = cluster
+	}
+	if service, ok := build.Variables["SERVICE"]; ok {
+		pc.Service = service
+	}
+	return pc
+}
+
+// writePolicyContext writes the context into a new data directory as {"context": <context>}, passed to conftest as --data
+func writePolicyContext(pc models.PolicyContext) (string, error) {
+	dir, err := os.MkdirTemp("", "policy-context-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create policy context directory: %w", err)
+	}
+	content, err := json.Marshal(map[string]models.PolicyContext{POLICY_CONTEXT_DATA_NAME: pc})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, POLICY_CONTEXT_DATA_NAME+".json"), content, 0644); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to write policy context: %w", err)
+	}
+	return dir, nil
+}
+
+// Config returns the loaded compliance configuration, only valid after LoadAndValidate
+func (e *PolicyEvaluator) Config() *models.ComplianceConfig {
+	return &e.data.ComplianceConfig
+}
+
+// EvalDurations returns the evaluation duration of each policy, by overlay key then policy id
+func (e *PolicyEvaluator) EvalDurations() map[string]map[string]time.Duration {
+	return e.evalDurations
+}
+
+// ExternalDataRecords returns the records of the external data injected into the evaluation
+func (e *PolicyEvaluator) ExternalDataRecords() []models.ExternalDataRecord {
+	return e.data.externalDataRecords
+}
+
+// IsOverrideCommand returns true if a comment is the override or revocation command of a policy
+func (e *PolicyEvaluator) IsOverrideCommand(comment string) bool {
+	_, _, _, ok := e.parseOverrideCommand(comment)
+	return ok
+}
+
+// PoliciesDigest returns the sha256 of the files of the policies directory (compliance config, policies
+// and libraries), the evaluation results of two runs with the same digest and manifests are the same
+func (e *PolicyEvaluator) PoliciesDigest() (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(e.policiesPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(e.policiesPath, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, _ = io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+		_, _ = h.Write(content)
+		_, _ = h.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash policies %s: %w", e.policiesPath, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
 func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 	ctx context.Context,
 	build models.BuildManifestResult,
-	ghComments []string,
+	ghComments []*models.Comment,
 ) (
 	*models.PolicyEvaluation,
 	error,
@@ -225,21 +742,29 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 
 	envToPolicyIdToResult := make(map[string]map[string]models.PolicyResult)
 	envManifests := build.EnvManifestBuild
+	imageBumpEnvs := make(map[string]bool) // environments changing only container images
 
 	// 1. Evaluate policies for each environment and store results (can goroutine)
 	complianceCfg := e.data.ComplianceConfig
 	for env, manifest := range envManifests {
+		// An overlay that failed to build has no policy results at all
+		if manifest.BuildError != "" {
+			logger.WithField("env", env).Info("Skipping policy evaluation for environment (build failed)")
+			envToPolicyIdToResult[env] = make(map[string]models.PolicyResult)
+			continue
+		}
 		// Skip policy evaluation if environment was skipped during build
 		if manifest.Skipped {
 			logger.WithField("env", env).WithField("reason", manifest.SkipReason).Info("Skipping policy evaluation for environment (overlay not found)")
 			// Create empty results for skipped environments
 			policyIdToResult := make(map[string]models.PolicyResult)
-			for policyId := range complianceCfg.Policies {
+			for _, policyId := range e.policyIDs() {
 				policy := complianceCfg.Policies[policyId]
 				policyIdToResult[policyId] = models.PolicyResult{
 					PolicyId:        policyId,
 					PolicyName:      policy.Name,
 					ExternalLink:    policy.ExternalLink,
+					SourceLink:      e.data.sourceLinkOfPolicy[policyId],
 					OverrideCommand: policy.Enforcement.Override.Comment,
 					IsPassing:       true, // Mark as passing since there's nothing to evaluate
 					FailMessages:    []string{},
@@ -252,45 +777,135 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 		logger.WithField("env", env).Info("Evaluating policies for environment")
 		policyIdToResult := make(map[string]models.PolicyResult)
 
-		failMsgs, err := e.Evaluate(ctx, manifest.AfterManifest)
+		pc := e.policyContextOf(manifest)
+		if complianceCfg.ImageBumps != nil && diff.RoutineImageBump(manifest.BeforeManifest, manifest.AfterManifest, complianceCfg.ImageBumps) {
+			pc.RoutineImageBump = true
+			imageBumpEnvs[env] = true
+		}
+		policyViolations, policyErrs, err := e.evaluateViolations(ctx, manifest.AfterManifest, &pc, e.options.ContinueOnError)
 		if err != nil {
 			return nil, fmt.Errorf("failed to evaluate policy for environment %s: %w", env, err)
 		}
+		for policyId, evalErr := range policyErrs {
+			logger.WithField("env", env).WithField("policyId", policyId).WithField("error", evalErr).Error("Policy failed to evaluate, continuing")
+			policy := complianceCfg.Policies[policyId]
+			excerpt := failure.Excerpt(evalErr)
+			policyIdToResult[policyId] = models.PolicyResult{
+				PolicyId:        policyId,
+				PolicyName:      policy.Name,
+				ExternalLink:    policy.ExternalLink,
+				SourceLink:      e.data.sourceLinkOfPolicy[policyId],
+				OverrideCommand: policy.Enforcement.Override.Comment,
+				IsPassing:       false, // fail closed, an errored policy is not known to pass
+				FailMessages:    []string{"Policy evaluation failed: " + excerpt},
+				EvalError:       excerpt,
+			}
+		}
 
-		for policyId, failMsgs := range failMsgs {
+		for policyId, violations := range policyViolations {
+			failMsgs := violationMessages(violations)
 			logger.WithField("policyId", policyId).WithField("failMsgs", failMsgs).Debug("Evaluated policy")
 			policy := complianceCfg.Policies[policyId]
 			polResult := models.PolicyResult{
 				PolicyId:        policyId,
 				PolicyName:      policy.Name,
 				ExternalLink:    policy.ExternalLink,
+				SourceLink:      e.data.sourceLinkOfPolicy[policyId],
 				OverrideCommand: policy.Enforcement.Override.Comment,
 				IsPassing:       len(failMsgs) == 0,
 				FailMessages:    failMsgs,
+				Violations:      violations,
 			}
 			policyIdToResult[policyId] = polResult
 		}
 
 		envToPolicyIdToResult[env] = policyIdToResult
 	}
+	if err := e.evaluateCrossEnvironment(ctx, envManifests, envToPolicyIdToResult); err != nil {
+		return nil, err
+	}
+	if err := e.evaluateDestructiveChanges(ctx, envManifests, envToPolicyIdToResult); err != nil {
+		return nil, err
+	}
+	e.imageBumpEnvs = imageBumpEnvs
+
+	e.checkTickets(ctx, ghComments)
+	return e.summarize(envToPolicyIdToResult, imageBumpEnvs, ghComments)
+}
+
+// ReapplyEnforcement recomputes the enforcement levels of a previous evaluation with the current comments,
+// reusing its policy results: a new override is reflected without evaluating the manifests again.
+// imageBumpEnvs are the ImageBumpEnvironments of the run that evaluated it
+func (e *PolicyEvaluator) ReapplyEnforcement(
+	ctx context.Context,
+	previous models.PolicyEvaluation,
+	imageBumpEnvs []string,
+	ghComments []*models.Comment,
+) (*models.PolicyEvaluation, error) {
+	envToPolicyIdToResult := make(map[string]map[string]models.PolicyResult, len(previous.PolicyMatrix))
+	for env, matrix := range previous.PolicyMatrix {
+		policyIdToResult := make(map[string]models.PolicyResult)
+		for _, policies := range [][]models.PolicyResult{
+			matrix.BlockingPolicies, matrix.WarningPolicies, matrix.RecommendPolicies,
+			matrix.OverriddenPolicies, matrix.NotInEffectPolicies,
+		} {
+			for _, result := range policies {
+				result.Relaxed, result.EscalatedFrom, result.OverriddenBy = false, "", ""
+				policyIdToResult[result.PolicyId] = result
+			}
+		}
+		envToPolicyIdToResult[env] = policyIdToResult
+	}
+	bumps := make(map[string]bool, len(imageBumpEnvs))
+	for _, env := range imageBumpEnvs {
+		bumps[env] = true
+	}
+	e.checkTickets(ctx, ghComments)
+	return e.summarize(envToPolicyIdToResult, bumps, ghComments)
+}
+
+// ImageBumpEnvironments returns the environments of the last evaluation changing only container images, sorted
+func (e *PolicyEvaluator) ImageBumpEnvironments() []string {
+	envs := make([]string, 0, len(e.imageBumpEnvs))
+	for env := range e.imageBumpEnvs {
+		envs = append(envs, env)
+	}
+	slices.Sort(envs)
+	return envs
+}
+
+// summarize sorts the policy results of each environment by enforcement level and counts them
+func (e *PolicyEvaluator) summarize(
+	envToPolicyIdToResult map[string]map[string]models.PolicyResult,
+	imageBumpEnvs map[string]bool,
+	ghComments []*models.Comment,
+) (*models.PolicyEvaluation, error) {
+	complianceCfg := e.data.ComplianceConfig
 
 	// 2. Get EnforcementLevel (can goroutine)
-	policyIdToEnforcementLevel, err := e.DetermineEnforcementLevel(ghComments)
+	policyIdToEnforcementLevel, escalatedFrom, err := e.enforcementLevelsAt(e.now(), ghComments)
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine enforcement level: %w", err)
 	}
+	overrides, honoredOverrides := e.overridesOf(ghComments)
 
 	// 3. Crafting PolicyEvaluation
 	results := models.PolicyEvaluation{
 		EnvironmentSummary: make(map[string]models.EnvironmentSummaryEnv),
 		PolicyMatrix:       make(map[string]models.PolicyMatrix),
+		Overrides:          honoredOverrides,
 	}
-	for env := range envManifests {
+	if e.options.Clock != nil {
+		evaluatedAt := e.now()
+		results.EvaluatedAt = &evaluatedAt
+	}
+	for env := range envToPolicyIdToResult {
 		logger.WithField("env", env).Info("Crafting policy evaluation for environment")
 
 		totalCnt, failedCnt, omittedCnt, successCnt := 0, 0, 0, 0
 		blockingSuccessCnt, warningSuccessCnt, recommendSuccessCnt, overriddenSuccessCnt, notInEffectSuccessCnt := 0, 0, 0, 0, 0
 		blockingFailedCnt, warningFailedCnt, recommendFailedCnt, overriddenFailedCnt, notInEffectFailedCnt := 0, 0, 0, 0, 0
+		erroredCnt := 0
 
 		blockingPolicies := []models.PolicyResult{}
 		warningPolicies := []models.PolicyResult{}
@@ -307,8 +922,18 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 			if result.IsPassing {
 				successCnt++
 			}
+			if result.EvalError != "" {
+				erroredCnt++
+			}
 
 			enforcementLevel := policyIdToEnforcementLevel[policyId]
+			if enforcementLevel == POLICY_LEVEL_BLOCK && imageBumpEnvs[env] &&
+				slices.Contains(complianceCfg.ImageBumps.RelaxPolicies, policyId) {
+				enforcementLevel = POLICY_LEVEL_WARNING
+				result.Relaxed = true
+			} else if level, ok := escalatedFrom[policyId]; ok {
+				result.EscalatedFrom = level
+			}
 			switch enforcementLevel {
 			case POLICY_LEVEL_BLOCK:
 				blockingPolicies = append(blockingPolicies, result)
@@ -335,6 +960,7 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 					recommendSuccessCnt++
 				}
 			case POLICY_LEVEL_OVERRIDE:
+				result.OverriddenBy = overrides[policyId].User
 				overriddenPolicies = append(overriddenPolicies, result)
 				if !result.IsPassing {
 					overriddenFailedCnt++
@@ -386,6 +1012,8 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 				OverriddenFailedCount:   overriddenFailedCnt,
 				NotInEffectSuccessCount: notInEffectSuccessCnt,
 				NotInEffectFailedCount:  notInEffectFailedCnt,
+
+				ErroredCount: erroredCnt,
 			},
 		}
 	}
@@ -393,67 +1021,195 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 	return &results, nil
 }
 
-// Evaluate evaluates all policies against the manifest using conftest and store the evaluation results in the EvaluatorData
+// Evaluate evaluates all policies against the manifest using the policy engine and store the evaluation results in the EvaluatorData
 // returns: policyId -> failure messages
 func (e *PolicyEvaluator) Evaluate(
 	ctx context.Context,
 	manifest []byte,
 ) (map[string][]string, error) {
+	policyViolations, err := e.EvaluateViolations(ctx, manifest)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string][]string, len(policyViolations))
+	for id, violations := range policyViolations {
+		results[id] = violationMessages(violations)
+	}
+	return results, nil
+}
+
+// EvaluateViolations evaluates all policies against the manifest using the policy engine
+// returns: policyId -> violations, each linked to the violating resource when it can be identified
+func (e *PolicyEvaluator) EvaluateViolations(
+	ctx context.Context,
+	mf []byte,
+) (map[string][]models.PolicyViolation, error) {
+	results, _, err := e.evaluateViolations(ctx, mf, nil, false)
+	return results, err
+}
+
+// evaluateViolations is EvaluateViolations with the policy context injected as data.context when pc is set.
+// When continueOnError is set the policies failing to evaluate are returned in policyErrs (policyId -> error)
+// instead of failing the evaluation
+func (e *PolicyEvaluator) evaluateViolations(
+	ctx context.Context,
+	mf []byte,
+	pc *models.PolicyContext,
+	continueOnError bool,
+) (map[string][]models.PolicyViolation, map[string]error, error) {
 	logger.Info("Evaluate: starting...")
-	results := make(map[string][]string)
+	results := make(map[string][]models.PolicyViolation)
+	policyErrs := make(map[string]error)
 
-	// Write manifest to temporary file for conftest
-	tmpFile, err := os.CreateTemp("", "manifest-*.yaml")
+	// Resources are only used to identify violating resources, so parsing is best-effort
+	resources, err := manifest.Parse(mf)
+	if err != nil {
+		logger.WithField("error", err).Warn("Evaluate: failed to parse manifest, violations will not be linked to resources")
+	}
+	// Prepare the manifest and context for the "opa" policies, a temp file for conftest
+	session, err := e.newRegoSession(mf, "manifest-*.yaml", pc)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, nil, err
 	}
-	defer func() {
-		if err := tmpFile.Close(); err != nil {
-			// Log error but don't fail the operation
-			fmt.Printf("Warning: failed to close temp file: %v\n", err)
+	defer session.close()
+
+	// Evaluate each policy using the policy engine (in order from config), built-in policies natively
+	var decisions []DecisionLogEvent
+	defer func() { e.logDecisions(ctx, decisions) }()
+	for _, id := range e.policyIDs() {
+		if e.isCrossEnvironment(id) {
+			continue // evaluated once for all overlays, see evaluateCrossEnvironment
+		}
+		if e.isDestructiveChanges(id) {
+			continue // evaluated with the before manifest, see evaluateDestructiveChanges
 		}
-		if err := os.Remove(tmpFile.Name()); err != nil {
-			// Log error but don't fail the operation
-			fmt.Printf("Warning: failed to remove temp file %s: %v\n", tmpFile.Name(), err)
+		start := time.Now()
+		var violations []models.PolicyViolation
+		var err error
+		if policy := e.data.ComplianceConfig.Policies[id]; policy.Type == POLICY_TYPE_IMAGES {
+			if resources == nil {
+				err = fmt.Errorf("failed to parse manifest for the built-in policy")
+			} else {
+				violations = evaluateImagePolicy(policy.Images, resources)
+			}
+		} else if policy.Type == POLICY_TYPE_METADATA {
+			if resources == nil {
+				err = fmt.Errorf("failed to parse manifest for the built-in policy")
+			} else {
+				violations = evaluateMetadataPolicy(policy.Metadata, resources)
+			}
+		} else if policy.Type == POLICY_TYPE_AVAILABILITY {
+			if resources == nil {
+				err = fmt.Errorf("failed to parse manifest for the built-in policy")
+			} else {
+				violations = evaluateAvailabilityPolicy(policy.Availability, resources)
+			}
+		} else if policy.Type == POLICY_TYPE_PACK {
+			if resources == nil {
+				err = fmt.Errorf("failed to parse manifest for the built-in policy")
+			} else {
+				violations = evaluatePackRule(policy.PackRule, resources)
+			}
+		} else if policy.Type == POLICY_TYPE_MANUAL {
+			violations = evaluateManualPolicy(policy.Manual, e.checklist[id])
+		} else {
+			violations, err = e.evaluateRegoPolicy(ctx, session, id, resources)
+		}
+		elapsed := time.Since(start)
+		if pc != nil {
+			e.mu.Lock()
+			if e.evalDurations[pc.OverlayKey] == nil {
+				e.evalDurations[pc.OverlayKey] = make(map[string]time.Duration)
+			}
+			e.evalDurations[pc.OverlayKey][id] = elapsed
+			e.mu.Unlock()
 		}
-	}()
+		if e.options.DecisionLogger != nil {
+			decisions = append(decisions, e.decisionOf(id, pc, resources, violations, err, elapsed))
+		}
+		if err != nil {
+			if !continueOnError {
+				return nil, nil, fmt.Errorf("failed to evaluate policy %s: %w", id, err)
+			}
+			policyErrs[id] = err
+			continue
+		}
+		results[id] = violations
+	}
+
+	return results, policyErrs, nil
+}
 
-	if _, err := tmpFile.Write(manifest); err != nil {
-		return nil, fmt.Errorf("failed to write manifest to temp file: %w", err)
+func violationMessages(violations []models.PolicyViolation) []string {
+	msgs := make([]string, 0, len(violations))
+	for _, v := range violations {
+		msgs = append(msgs, v.Message)
 	}
+	return msgs
+}
 
-	// Evaluate each policy using conftest (in order from config)
-	for _, id := range e.data.ComplianceConfig.PolicyIDs {
-		failMsgs, err := e.evaluatePolicyWithConftest(
-			ctx, id, e.data.fullPathToPolicy[id], tmpFile.Name(),
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to evaluate policy %s: %w", id, err)
+// evaluateRegoPolicy evaluates a single "opa" policy of a session
+// returns: violations, evalError
+func (e *PolicyEvaluator) evaluateRegoPolicy(
+	ctx context.Context,
+	session *regoSession,
+	id string,
+	resources []manifest.Resource,
+) ([]models.PolicyViolation, error) {
+	failures, err := session.failures(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	violations := []models.PolicyViolation{}
+	for _, failure := range failures {
+		resourceID := resolveViolationResource(failure.Metadata, failure.Msg, resources)
+		violation := models.PolicyViolation{
+			Message:    failure.Msg,
+			ResourceID: resourceID,
+			Suggestion: suggestionOf(failure.Metadata, resourceID, resources),
 		}
-		results[id] = failMsgs
+		if e.isCrossEnvironment(id) {
+			violation.Environment, _ = failure.Metadata[VIOLATION_ENVIRONMENT_KEY].(string)
+		}
+		violations = append(violations, violation)
 	}
-
-	return results, nil
+	return violations, nil
 }
 
-// evaluatePolicyWithConftest evaluates a single policy using conftest
-// returns: failureMsgs, evalError
-func (e *PolicyEvaluator) evaluatePolicyWithConftest(
+// conftestFailures evaluates a single policy using conftest
+func (e *PolicyEvaluator) conftestFailures(
 	ctx context.Context,
 	id string,
-	singlePolicyPath string, manifestPath string,
-) ([]string, error) {
+	inputPath string, contextDir string,
+	combine bool,
+) ([]regoFailure, error) {
 	logger.Infof("evaluating policy %s", id)
 
-	cmd := exec.CommandContext(ctx,
-		"conftest", "test", "--all-namespaces", "--combine",
-		"--policy", singlePolicyPath,
-		manifestPath,
-		"-o", "json",
-	)
+	args := []string{"test"}
+	args = append(args, namespaceArgs(e.data.ComplianceConfig.Policies[id].Namespaces)...)
+	if combine {
+		// all documents of the manifest are a single input, an "environments" input is a single document
+		args = append(args, "--combine")
+	}
+	args = append(args, "--policy", e.data.fullPathToPolicy[id])
+	for _, libraryPath := range e.data.libraryPaths {
+		args = append(args, "--policy", libraryPath)
+	}
+	if e.data.externalDataDir != "" {
+		args = append(args, "--data", e.data.externalDataDir)
+	}
+	if contextDir != "" {
+		args = append(args, "--data", contextDir)
+	}
+	args = append(args, inputPath, "-o", "json")
 
-	// If policy eval not passing, the program exit with code 1, we will omit error here
-	outputBytes, _ := cmd.CombinedOutput()
+	// If policy eval not passing, the program exit with code 1, we will omit exit code here
+	// but a process killed for exceeding its limits is an evaluation error
+	res, err := e.conftest(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("conftest execution failed: %w", err)
+	}
+	outputBytes := res.Stdout
 	logger.Debugf("conftest output: %s", string(outputBytes))
 
 	// Sample conftest output
@@ -473,18 +1229,13 @@ func (e *PolicyEvaluator) evaluatePolicyWithConftest(
 	//   }
 	// ]
 	outputJson := []struct {
-		Filename  string `json:"filename"`
-		Namespace string `json:"namespace"`
-		Successes int    `json:"successes"`
-		Failures  []struct {
-			Msg      string `json:"msg"`
-			Metadata struct {
-				Query string `json:"query"`
-			}
-		}
+		Filename  string        `json:"filename"`
+		Namespace string        `json:"namespace"`
+		Successes int           `json:"successes"`
+		Failures  []regoFailure `json:"failures"` // metadata: "query", plus the extra fields of structured deny results
 	}{}
 	if err := json.Unmarshal(outputBytes, &outputJson); err != nil {
-		return nil, fmt.Errorf("failed to parse conftest output: %w", err)
+		return nil, fmt.Errorf("failed to parse conftest output: %w\nStderr: %s", err, string(res.Stderr))
 	}
 
 	if len(outputJson) == 0 {
@@ -497,29 +1248,45 @@ func (e *PolicyEvaluator) evaluatePolicyWithConftest(
 	// 			"successes": 3
 	//	 }
 	// ]
-	if len(outputJson[0].Failures) == 0 {
-		return []string{}, nil
+	// There is one result per evaluated namespace, helper packages simply have no failures
+	failures := []regoFailure{}
+	for _, result := range outputJson {
+		failures = append(failures, result.Failures...)
 	}
+	return failures, nil
+}
 
-	failureMsgs := []string{}
-	for _, failure := range outputJson[0].Failures {
-		failureMsgs = append(failureMsgs, failure.Msg)
+// namespaceArgs selects the Rego packages conftest evaluates, all of them when none is configured
+func namespaceArgs(namespaces []string) []string {
+	if len(namespaces) == 0 {
+		return []string{"--all-namespaces"}
+	}
+	args := make([]string, 0, 2*len(namespaces))
+	for _, namespace := range namespaces {
+		args = append(args, "--namespace", namespace)
 	}
-	return failureMsgs, nil
+	return args
 }
 
-// DetermineEnforcementLevel determines the current enforcement level based on time and overrides
-// Set the results to internal struct data
+// DetermineEnforcementLevel determines the current enforcement level based on time and overrides,
+// the override and revocation commands of the comments are replayed in creation order
 func (e *PolicyEvaluator) DetermineEnforcementLevel(
-	comments []string,
+	comments []*models.Comment,
 ) (map[string]string, error) {
+	levels, _, err := e.enforcementLevelsAt(e.now(), comments)
+	return levels, err
+}
+
+// enforcementLevelsAt determines the enforcement level of each policy at a given time, raised per the tier of
+// the service. The policies raised by the tier are returned with their level before escalation
+func (e *PolicyEvaluator) enforcementLevelsAt(now time.Time, comments []*models.Comment) (map[string]string, map[string]string, error) {
 	results := make(map[string]string)
-	now := time.Now()
+	escalatedFrom := make(map[string]string)
+	tier := e.tierConfig()
 
-	for _, comment := range comments {
-		if _, ok := e.data.overrideCmdToPolicyId[comment]; ok {
-			results[e.data.overrideCmdToPolicyId[comment]] = POLICY_LEVEL_OVERRIDE
-		}
+	overrides, _ := e.overridesOf(comments)
+	for policyId := range overrides {
+		results[policyId] = POLICY_LEVEL_OVERRIDE
 	}
 
 	for policyId, policy := range e.data.ComplianceConfig.Policies {
@@ -529,6 +1296,9 @@ func (e *PolicyEvaluator) DetermineEnforcementLevel(
 
 		enforcementLevel := POLICY_LEVEL_UNKNOWN
 		enforcement := policy.Enforcement
+		if policy.Type == POLICY_TYPE_MANUAL {
+			enforcementLevel = manualPolicyLevel(policy.Manual)
+		}
 
 		if enforcement.InEffectAfter != nil && now.Before(*enforcement.InEffectAfter) {
 			enforcementLevel = POLICY_LEVEL_NOT_IN_EFFECT
@@ -543,8 +1313,65 @@ func (e *PolicyEvaluator) DetermineEnforcementLevel(
 			enforcementLevel = POLICY_LEVEL_BLOCK
 		}
 
+		if level := tier.LevelOf(policyId, enforcementLevel); level != enforcementLevel {
+			escalatedFrom[policyId] = enforcementLevel
+			enforcementLevel = level
+		}
 		results[policyId] = enforcementLevel
 	}
 
-	return results, nil
+	return results, escalatedFrom, nil
+}
+
+// overridesOf replays the override and revocation commands of the comments in creation order. It returns the
+// honored override of each overridden policy, and all the honored commands and rejected overrides: an override
+// of an overridden policy and a revocation of a policy that is not overridden are ignored, an override without
+// a valid ticket (see overrideTickets) is rejected
+func (e *PolicyEvaluator) overridesOf(comments []*models.Comment) (map[string]models.OverrideAction, []models.OverrideAction) {
+	ordered := slices.Clone(comments)
+	slices.SortStableFunc(ordered, func(a, b *models.Comment) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	overrides := make(map[string]models.OverrideAction)
+	var honored []models.OverrideAction
+	for _, comment := range ordered {
+		if comment == nil {
+			continue
+		}
+		policyId, action, ticket, ok := e.parseOverrideCommand(comment.Body)
+		if !ok {
+			continue
+		}
+		_, overridden := overrides[policyId]
+		if overridden == (action == models.OverrideActionOverride) {
+			continue // nothing to change
+		}
+
+		record := models.OverrideAction{PolicyId: policyId, Action: action, User: comment.User, At: comment.CreatedAt, Ticket: ticket}
+		if action == models.OverrideActionOverride && e.data.ComplianceConfig.OverrideTickets != nil {
+			if reason := e.rejectionOfTicket(&record); reason != "" {
+				record.Action, record.Reason = models.OverrideActionRejected, reason
+				honored = append(honored, record)
+				continue
+			}
+		}
+		honored = append(honored, record)
+		if action == models.OverrideActionOverride {
+			overrides[policyId] = record
+		} else {
+			delete(overrides, policyId)
+		}
+	}
+	return overrides, honored
+}
+
+// tierConfig returns the strictness of the tier of the service, nil if it has none or the tier is not configured
+func (e *PolicyEvaluator) tierConfig() *models.TierConfig {
+	if e.policyContext.Tier == "" {
+		return nil
+	}
+	tier, ok := e.data.ComplianceConfig.Tiers[e.policyContext.Tier]
+	if !ok {
+		return nil
+	}
+	return &tier
 }
@@ -0,0 +1,539 @@
+package policy
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestLoadComplianceConfig_Profiles(t *testing.T) {
+	dir := t.TempDir()
+	config := `policies:
+  ha:
+    name: HA
+    type: opa
+    filePath: ha.rego
+profiles:
+  prod:
+    environments: ["prod-*", "*/prod"]
+    failOn: block
+    diffLimit: 5000
+  everything-else:
+    environments: ["*", "*/*"]
+    failOn: none
+`
+	if err := os.WriteFile(filepath.Join(dir, COMPLIANCE_CONFIG_FILENAME), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := NewPolicyEvaluator(dir)
+	if err := e.loadComplianceConfig(); err != nil {
+		t.Fatalf("loadComplianceConfig() error = %v", err)
+	}
+	if err := e.validateComplianceConfig(); err != nil {
+		t.Fatalf("validateComplianceConfig() error = %v", err)
+	}
+
+	tests := []struct {
+		overlayKey string
+		want       string
+	}{
+		{overlayKey: "prod-eu", want: "prod"},
+		{overlayKey: "api/prod", want: "prod"},
+		{overlayKey: "stg", want: "everything-else"},
+		{overlayKey: "api/us/prod", want: ""},
+	}
+	for _, tt := range tests {
+		name, profile := e.Config().ProfileFor(tt.overlayKey)
+		if name != tt.want || (profile == nil) != (tt.want == "") {
+			t.Errorf("ProfileFor(%q) = %q, want %q", tt.overlayKey, name, tt.want)
+		}
+	}
+	if _, profile := e.Config().ProfileFor("prod-us"); profile.DiffLimit != 5000 || profile.FailOn != "block" {
+		t.Errorf("ProfileFor(prod-us) = %+v, want the prod profile", profile)
+	}
+}
+
+func TestLoadAndValidate_NoPolicies(t *testing.T) {
+	dir := t.TempDir()
+	if err := NewPolicyEvaluator(dir).LoadAndValidate(); err == nil {
+		t.Fatal("LoadAndValidate() without a compliance config succeeded, want an error")
+	}
+	e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{NoPolicies: true})
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v, want the compliance config ignored", err)
+	}
+	if len(e.Config().Policies) != 0 {
+		t.Errorf("policies = %v, want none, the destructive changes policy included", slices.Collect(maps.Keys(e.Config().Policies)))
+	}
+}
+
+func TestFetchExternalData_Close(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"images":["nginx:1.25"]}`))
+	}))
+	defer server.Close()
+
+	e := NewPolicyEvaluatorWithOptions(t.TempDir(), EvaluatorOptions{ExternalDataCacheDir: t.TempDir()})
+	e.data.ComplianceConfig.ExternalData = []models.ExternalDataSource{{Name: "allowlist", URL: server.URL, TTL: "0"}}
+	if err := e.FetchExternalData(context.Background()); err != nil {
+		t.Fatalf("FetchExternalData() error = %v", err)
+	}
+	first := e.data.externalDataDir
+	if err := e.FetchExternalData(context.Background()); err != nil {
+		t.Fatalf("FetchExternalData() error = %v", err)
+	}
+	second := e.data.externalDataDir
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("data of the previous fetch %s not removed, stat error = %v", first, err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := os.Stat(second); !os.IsNotExist(err) {
+		t.Errorf("external data %s not removed by Close, stat error = %v", second, err)
+	}
+}
+
+func TestDetermineEnforcementLevel_Clock(t *testing.T) {
+	dir := t.TempDir()
+	config := `policies:
+  ha:
+    name: HA
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      inEffectAfter: 2026-01-01T00:00:00Z
+      isWarningAfter: 2026-02-01T00:00:00Z
+      isBlockingAfter: 2026-03-01T00:00:00Z
+`
+	if err := os.WriteFile(filepath.Join(dir, COMPLIANCE_CONFIG_FILENAME), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{at: "2025-12-31T23:59:59Z", want: POLICY_LEVEL_NOT_IN_EFFECT},
+		{at: "2026-01-01T00:00:00Z", want: POLICY_LEVEL_RECOMMEND},
+		{at: "2026-02-15T00:00:00Z", want: POLICY_LEVEL_WARNING},
+		{at: "2026-03-01T00:00:00Z", want: POLICY_LEVEL_BLOCK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.at, func(t *testing.T) {
+			at, err := time.Parse(time.RFC3339, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{Clock: FixedClock(at)})
+			if err := e.loadComplianceConfig(); err != nil {
+				t.Fatalf("loadComplianceConfig() error = %v", err)
+			}
+			levels, err := e.DetermineEnforcementLevel(nil)
+			if err != nil {
+				t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+			}
+			if levels["ha"] != tt.want {
+				t.Errorf("DetermineEnforcementLevel() at %s = %q, want %q", tt.at, levels["ha"], tt.want)
+			}
+		})
+	}
+}
+
+func TestDetermineEnforcementLevel_Tier(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tiers := map[string]models.TierConfig{
+		"tier-1": {Escalate: map[string]string{POLICY_LEVEL_RECOMMEND: POLICY_LEVEL_WARNING, POLICY_LEVEL_WARNING: POLICY_LEVEL_BLOCK}},
+		"tier-2": {Escalate: map[string]string{POLICY_LEVEL_WARNING: POLICY_LEVEL_BLOCK}, Policies: []string{"k8s-*"}},
+	}
+	tests := []struct {
+		tier          string
+		want          map[string]string
+		wantEscalated []string
+	}{
+		{tier: "", want: map[string]string{"ha": POLICY_LEVEL_WARNING, "k8s-pdb": POLICY_LEVEL_WARNING, "docs": POLICY_LEVEL_RECOMMEND}},
+		{tier: "tier-3", want: map[string]string{"ha": POLICY_LEVEL_WARNING, "k8s-pdb": POLICY_LEVEL_WARNING, "docs": POLICY_LEVEL_RECOMMEND}},
+		{
+			tier:          "tier-1",
+			want:          map[string]string{"ha": POLICY_LEVEL_BLOCK, "k8s-pdb": POLICY_LEVEL_BLOCK, "docs": POLICY_LEVEL_WARNING},
+			wantEscalated: []string{"docs", "ha", "k8s-pdb"},
+		},
+		{
+			tier:          "tier-2",
+			want:          map[string]string{"ha": POLICY_LEVEL_WARNING, "k8s-pdb": POLICY_LEVEL_BLOCK, "docs": POLICY_LEVEL_RECOMMEND},
+			wantEscalated: []string{"k8s-pdb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tier, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{Clock: FixedClock(since)})
+			e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+				"ha":      {Enforcement: models.EnforcementConfig{IsWarningAfter: &since}},
+				"k8s-pdb": {Enforcement: models.EnforcementConfig{IsWarningAfter: &since}},
+				"docs":    {Enforcement: models.EnforcementConfig{InEffectAfter: &since}},
+			}
+			e.data.ComplianceConfig.Tiers = tiers
+			e.SetServiceMetadata(&models.ServiceMetadata{Tier: tt.tier})
+
+			levels, escalatedFrom, err := e.enforcementLevelsAt(e.now(), nil)
+			if err != nil {
+				t.Fatalf("enforcementLevelsAt() error = %v", err)
+			}
+			if !reflect.DeepEqual(levels, tt.want) {
+				t.Errorf("enforcementLevelsAt() = %v, want %v", levels, tt.want)
+			}
+			escalated := slices.Sorted(maps.Keys(escalatedFrom))
+			if !slices.Equal(escalated, tt.wantEscalated) {
+				t.Errorf("enforcementLevelsAt() escalated %v, want %v", escalated, tt.wantEscalated)
+			}
+		})
+	}
+}
+
+func TestReapplyEnforcement(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{Clock: FixedClock(since)})
+	e.data.ComplianceConfig.PolicyIDs = []string{"ha", "pdb"}
+	e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+		"ha":  {Enforcement: models.EnforcementConfig{IsBlockingAfter: &since, Override: models.OverrideConfig{Comment: "/sp-override-ha"}}},
+		"pdb": {Enforcement: models.EnforcementConfig{IsBlockingAfter: &since}},
+	}
+	e.data.ComplianceConfig.ImageBumps = &models.ImageBumpConfig{RelaxPolicies: []string{"pdb"}}
+	e.data.overrideCmdToPolicyId["/sp-override-ha"] = "ha"
+
+	ha := models.PolicyResult{PolicyId: "ha", FailMessages: []string{"replicas < 2"}}
+	pdb := models.PolicyResult{PolicyId: "pdb", FailMessages: []string{"no PDB"}, Relaxed: true}
+	previous := models.PolicyEvaluation{PolicyMatrix: map[string]models.PolicyMatrix{
+		"prod": {BlockingPolicies: []models.PolicyResult{ha}, WarningPolicies: []models.PolicyResult{pdb}},
+	}}
+
+	tests := []struct {
+		name           string
+		imageBumpEnvs  []string
+		comments       []*models.Comment
+		wantBlocking   []string
+		wantOverridden []string
+	}{
+		{name: "no new comment", imageBumpEnvs: []string{"prod"}, wantBlocking: []string{"ha"}},
+		{name: "override", imageBumpEnvs: []string{"prod"}, comments: []*models.Comment{{Body: "LGTM"}, {Body: "/sp-override-ha", User: "alice"}}, wantOverridden: []string{"ha"}},
+		{name: "not an image bump", comments: []*models.Comment{{Body: "/sp-override-ha", User: "alice"}}, wantBlocking: []string{"pdb"}, wantOverridden: []string{"ha"}},
+	}
+	idsOf := func(results []models.PolicyResult) []string {
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.PolicyId)
+		}
+		return ids
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluation, err := e.ReapplyEnforcement(context.Background(), previous, tt.imageBumpEnvs, tt.comments)
+			if err != nil {
+				t.Fatalf("ReapplyEnforcement() error = %v", err)
+			}
+			matrix := evaluation.PolicyMatrix["prod"]
+			if blocking := idsOf(matrix.BlockingPolicies); !slices.Equal(blocking, tt.wantBlocking) {
+				t.Errorf("ReapplyEnforcement() blocking = %v, want %v", blocking, tt.wantBlocking)
+			}
+			if overridden := idsOf(matrix.OverriddenPolicies); !slices.Equal(overridden, tt.wantOverridden) {
+				t.Errorf("ReapplyEnforcement() overridden = %v, want %v", overridden, tt.wantOverridden)
+			}
+			if len(matrix.OverriddenPolicies) > 0 && matrix.OverriddenPolicies[0].OverriddenBy != "alice" {
+				t.Errorf("ReapplyEnforcement() overridden by %q, want alice", matrix.OverriddenPolicies[0].OverriddenBy)
+			}
+			summary := evaluation.EnvironmentSummary["prod"]
+			if summary.PassingStatus.PassBlockingCheck != (len(tt.wantBlocking) == 0) || summary.PolicyCounts.TotalCount != 2 {
+				t.Errorf("ReapplyEnforcement() summary = %+v", summary)
+			}
+		})
+	}
+}
+
+func TestDetermineEnforcementLevel_Revocation(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{Clock: FixedClock(since)})
+	e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+		"ha": {Enforcement: models.EnforcementConfig{IsBlockingAfter: &since, Override: models.OverrideConfig{Comment: "/sp-override-ha"}}},
+	}
+	e.data.overrideCmdToPolicyId["/sp-override-ha"] = "ha"
+	comment := func(body, user string, minute int) *models.Comment {
+		return &models.Comment{Body: body, User: user, CreatedAt: since.Add(time.Duration(minute) * time.Minute)}
+	}
+
+	tests := []struct {
+		name        string
+		comments    []*models.Comment
+		want        string
+		wantHonored []string // action@user
+	}{
+		{name: "override", comments: []*models.Comment{comment("/sp-override-ha", "alice", 1)},
+			want: POLICY_LEVEL_OVERRIDE, wantHonored: []string{"override@alice"}},
+		{name: "revoked", comments: []*models.Comment{comment("/sp-override-ha", "alice", 1), comment("/sp-override-ha revoke", "bob", 2)},
+			want: POLICY_LEVEL_BLOCK, wantHonored: []string{"override@alice", "revoke@bob"}},
+		{name: "overridden again", comments: []*models.Comment{
+			comment("/sp-override-ha", "alice", 1), comment("/sp-override-ha revoke", "bob", 2), comment("/sp-override-ha", "carol", 3),
+		}, want: POLICY_LEVEL_OVERRIDE, wantHonored: []string{"override@alice", "revoke@bob", "override@carol"}},
+		{name: "ordered by creation time", comments: []*models.Comment{comment("/sp-override-ha revoke", "bob", 2), comment("/sp-override-ha", "alice", 1)},
+			want: POLICY_LEVEL_BLOCK, wantHonored: []string{"override@alice", "revoke@bob"}},
+		{name: "revocation before override", comments: []*models.Comment{comment("/sp-override-ha revoke", "bob", 1), comment("/sp-override-ha", "alice", 2)},
+			want: POLICY_LEVEL_OVERRIDE, wantHonored: []string{"override@alice"}},
+		{name: "repeated override", comments: []*models.Comment{comment("/sp-override-ha", "alice", 1), comment("/sp-override-ha", "carol", 2)},
+			want: POLICY_LEVEL_OVERRIDE, wantHonored: []string{"override@alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels, err := e.DetermineEnforcementLevel(tt.comments)
+			if err != nil {
+				t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+			}
+			if levels["ha"] != tt.want {
+				t.Errorf("DetermineEnforcementLevel() = %q, want %q", levels["ha"], tt.want)
+			}
+			var honored []string
+			_, actions := e.overridesOf(tt.comments)
+			for _, action := range actions {
+				honored = append(honored, action.Action+"@"+action.User)
+			}
+			if !slices.Equal(honored, tt.wantHonored) {
+				t.Errorf("overridesOf() honored %v, want %v", honored, tt.wantHonored)
+			}
+		})
+	}
+}
+
+func TestPolicyContextOf(t *testing.T) {
+	pr := &models.PullRequestContext{Repo: "org/repo", Number: 42}
+	tests := []struct {
+		name  string
+		build models.BuildEnvManifestResult
+		want  models.PolicyContext
+	}{
+		{
+			name:  "legacy",
+			build: models.BuildEnvManifestResult{OverlayKey: "prod", Environment: "prod"},
+			want:  models.PolicyContext{Environment: "prod", OverlayKey: "prod", Service: "my-app", PullRequest: pr},
+		},
+		{
+			name: "dynamic",
+			build: models.BuildEnvManifestResult{OverlayKey: "api/alpha/stg", Environment: "api/alpha/stg",
+				Variables: map[string]string{"SERVICE": "api", "CLUSTER": "alpha", "ENV": "stg"}},
+			want: models.PolicyContext{Environment: "stg", OverlayKey: "api/alpha/stg", Service: "api", Cluster: "alpha",
+				Variables: map[string]string{"SERVICE": "api", "CLUSTER": "alpha", "ENV": "stg"}, PullRequest: pr},
+		},
+	}
+	e := NewPolicyEvaluator("")
+	e.SetPolicyContext(models.PolicyContext{Service: "my-app", PullRequest: pr})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.policyContextOf(tt.build); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("policyContextOf() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateComplianceConfig_Namespaces(t *testing.T) {
+	tests := []struct {
+		namespaces []string
+		wantErr    bool
+	}{
+		{namespaces: nil},
+		{namespaces: []string{"main", "k8s.ha"}},
+		{namespaces: []string{"ha-check"}, wantErr: true},
+		{namespaces: []string{"main."}, wantErr: true},
+	}
+	for _, tt := range tests {
+		e := NewPolicyEvaluator("")
+		e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+			"ha": {Name: "HA", Type: "opa", FilePath: "ha.rego", Namespaces: tt.namespaces},
+		}
+		if err := e.validateComplianceConfig(); (err != nil) != tt.wantErr {
+			t.Errorf("validateComplianceConfig() with namespaces %v error = %v, wantErr %v", tt.namespaces, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateComplianceConfig_Reviewers(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    models.ReviewerRule
+		wantErr bool
+	}{
+		{name: "default category", rule: models.ReviewerRule{Teams: []string{"security"}, Categories: []string{"rbac"}}},
+		{name: "configured category", rule: models.ReviewerRule{Teams: []string{"network"}, Categories: []string{"networking"}}},
+		{name: "kind and policy", rule: models.ReviewerRule{Teams: []string{"sre"}, Kinds: []string{"PodDisruptionBudget"}, Policies: []string{"ha"}}},
+		{name: "no team", rule: models.ReviewerRule{Categories: []string{"rbac"}}, wantErr: true},
+		{name: "no trigger", rule: models.ReviewerRule{Teams: []string{"security"}}, wantErr: true},
+		{name: "unknown category", rule: models.ReviewerRule{Teams: []string{"security"}, Categories: []string{"secrets"}}, wantErr: true},
+		{name: "unknown policy", rule: models.ReviewerRule{Teams: []string{"sre"}, Policies: []string{"pdb"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		e := NewPolicyEvaluator("")
+		e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{"ha": {Name: "HA", Type: "opa", FilePath: "ha.rego"}}
+		e.data.ComplianceConfig.RiskClassification = map[string]models.RiskCategoryConfig{"networking": {Kinds: []string{"NetworkPolicy"}}}
+		e.data.ComplianceConfig.Reviewers = []models.ReviewerRule{tt.rule}
+		if err := e.validateComplianceConfig(); (err != nil) != tt.wantErr {
+			t.Errorf("validateComplianceConfig() %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateComplianceConfig_Tiers(t *testing.T) {
+	tests := []struct {
+		name    string
+		tier    models.TierConfig
+		wantErr bool
+	}{
+		{name: "warning as blocking", tier: models.TierConfig{Escalate: map[string]string{"WARNING": "BLOCK"}}},
+		{name: "scoped", tier: models.TierConfig{Escalate: map[string]string{"NOT_IN_EFFECT": "RECOMMEND"}, Policies: []string{"k8s-*"}}},
+		{name: "relaxing", tier: models.TierConfig{Escalate: map[string]string{"BLOCK": "WARNING"}}, wantErr: true},
+		{name: "override", tier: models.TierConfig{Escalate: map[string]string{"OVERRIDE": "BLOCK"}}, wantErr: true},
+		{name: "invalid pattern", tier: models.TierConfig{Escalate: map[string]string{"WARNING": "BLOCK"}, Policies: []string{"[k8s"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		e := NewPolicyEvaluator("")
+		e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{"ha": {Name: "HA", Type: "opa", FilePath: "ha.rego"}}
+		e.data.ComplianceConfig.Tiers = map[string]models.TierConfig{"tier-1": tt.tier}
+		if err := e.validateComplianceConfig(); (err != nil) != tt.wantErr {
+			t.Errorf("validateComplianceConfig() %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateComplianceConfig_Environments(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     models.EnvironmentConfig
+		wantErr bool
+	}{
+		{name: "segment", env: models.EnvironmentConfig{Match: "prd", DisplayName: "Production"}},
+		{name: "glob", env: models.EnvironmentConfig{Match: "*/stg*", Order: -1}},
+		{name: "no match", env: models.EnvironmentConfig{DisplayName: "Production"}, wantErr: true},
+		{name: "invalid match", env: models.EnvironmentConfig{Match: "[prd"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		e := NewPolicyEvaluator("")
+		e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{"ha": {Name: "HA", Type: "opa", FilePath: "ha.rego"}}
+		e.data.ComplianceConfig.Environments = []models.EnvironmentConfig{tt.env}
+		if err := e.validateComplianceConfig(); (err != nil) != tt.wantErr {
+			t.Errorf("validateComplianceConfig() %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSetServiceMetadata(t *testing.T) {
+	e := NewPolicyEvaluator("")
+	e.data.ComplianceConfig.PolicyIDs = []string{"ha", "k8s-pdb", "images"}
+	e.SetPolicyContext(models.PolicyContext{Service: "payments"})
+	e.SetServiceMetadata(&models.ServiceMetadata{
+		Owner:    "org/payments",
+		Tier:     "tier-1",
+		Policies: &models.PolicySelector{Exclude: []string{"k8s-*"}},
+	})
+
+	if got, want := e.policyIDs(), []string{"ha", "images"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("policyIDs() = %v, want %v", got, want)
+	}
+	pc := e.policyContextOf(models.BuildEnvManifestResult{OverlayKey: "prod", Environment: "prod"})
+	if pc.Service != "payments" || pc.Owner != "org/payments" || pc.Tier != "tier-1" {
+		t.Errorf("policyContextOf() = %+v", pc)
+	}
+}
+
+func TestResolveLibraries(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"lib", "shared/k8s"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ha.rego"), []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		dir       string
+		libraries []string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "default lib dir", dir: dir, want: []string{filepath.Join(dir, "lib")}},
+		{name: "no default lib dir", dir: filepath.Join(dir, "shared")},
+		{name: "configured", dir: dir, libraries: []string{"shared/k8s"}, want: []string{filepath.Join(dir, "shared/k8s")}},
+		{name: "missing", dir: dir, libraries: []string{"helpers"}, wantErr: true},
+		{name: "not a directory", dir: dir, libraries: []string{"ha.rego"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluator(tt.dir)
+			e.data.ComplianceConfig.Libraries = tt.libraries
+			got, err := e.resolveLibraries()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveLibraries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveLibraries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingDecisionLogger keeps the logged events in memory
+type recordingDecisionLogger struct {
+	events []DecisionLogEvent
+}
+
+func (l *recordingDecisionLogger) Log(_ context.Context, events []DecisionLogEvent) error {
+	l.events = append(l.events, events...)
+	return nil
+}
+
+func TestEvaluateViolations_DurationsAndDecisions(t *testing.T) {
+	decisionLogger := &recordingDecisionLogger{}
+	e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{
+		DecisionLogger:       decisionLogger,
+		DecisionLogLabels:    map[string]string{"id": "gitops-kustomzchk"},
+		DecisionLogOmitInput: true,
+	})
+	e.data.ComplianceConfig.PolicyIDs = []string{"images"}
+	e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+		"images": {Name: "Images", Type: POLICY_TYPE_IMAGES, Images: &models.ImagePolicyConfig{}},
+	}
+	mf := []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:latest
+`)
+	pc := &models.PolicyContext{OverlayKey: "alpha/stg"}
+	if _, _, err := e.evaluateViolations(context.Background(), mf, pc, false); err != nil {
+		t.Fatalf("evaluateViolations() error = %v", err)
+	}
+
+	if _, ok := e.EvalDurations()["alpha/stg"]["images"]; !ok {
+		t.Errorf("EvalDurations() = %v, want a duration of images in alpha/stg", e.EvalDurations())
+	}
+	if len(decisionLogger.events) != 1 {
+		t.Fatalf("logged %d decisions, want 1", len(decisionLogger.events))
+	}
+	event := decisionLogger.events[0]
+	if event.Path != DECISION_LOG_IMAGES_PATH || event.Input != nil || len(event.Result.Deny) != 1 {
+		t.Errorf("decision = %+v", event)
+	}
+	if event.Labels["overlay_key"] != "alpha/stg" || event.Labels["policy_id"] != "images" || event.Labels["id"] != "gitops-kustomzchk" {
+		t.Errorf("decision labels = %v", event.Labels)
+	}
+}
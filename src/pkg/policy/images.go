@@ -0,0 +1,87 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// DEFAULT_DISALLOWED_TAGS are the mutable tags rejected by an images policy configuring none
+var DEFAULT_DISALLOWED_TAGS = []string{"latest"}
+
+// validateImagePolicy checks the settings of an images policy
+func validateImagePolicy(cfg *models.ImagePolicyConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("images is required")
+	}
+	for _, pattern := range cfg.AllowedRegistries {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowedRegistries pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// evaluateImagePolicy checks the container images of the resources against an images policy,
+// one violation per offending container and rule
+func evaluateImagePolicy(cfg *models.ImagePolicyConfig, resources []manifest.Resource) []models.PolicyViolation {
+	disallowedTags := cfg.DisallowedTags
+	if len(disallowedTags) == 0 {
+		disallowedTags = DEFAULT_DISALLOWED_TAGS
+	}
+
+	violations := []models.PolicyViolation{}
+	for _, res := range resources {
+		for _, container := range manifest.ContainerImages(res.Object) {
+			ref := manifest.ParseImage(container.Image)
+			subject := fmt.Sprintf("%s container '%s' image '%s'", res.ID(), container.Container, container.Image)
+			add := func(message string) {
+				violations = append(violations, models.PolicyViolation{Message: subject + " " + message, ResourceID: res.ID()})
+			}
+
+			if ref.Digest == "" {
+				tag := ref.Tag
+				if tag == "" {
+					tag = "latest"
+				}
+				switch {
+				case slices.Contains(disallowedTags, tag):
+					add(fmt.Sprintf("uses the mutable tag '%s', pin a version or a digest", tag))
+				case cfg.RequireDigest:
+					add("is not pinned by digest (@sha256:...)")
+				}
+			}
+			if len(cfg.AllowedRegistries) > 0 && !registryAllowed(cfg.AllowedRegistries, ref) {
+				add(fmt.Sprintf("comes from the registry '%s', not in the allowed registries %v", ref.Registry, cfg.AllowedRegistries))
+			}
+		}
+	}
+	return violations
+}
+
+// registryAllowed matches an image against allowed registry hosts, or image names for patterns with a slash
+// Docker Hub names are matched in full, e.g. "nginx" as "docker.io/library/nginx"
+func registryAllowed(patterns []string, ref manifest.ImageRef) bool {
+	name := ref.Name
+	if ref.Registry == manifest.DEFAULT_REGISTRY {
+		name = strings.TrimPrefix(name, manifest.DEFAULT_REGISTRY+"/")
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+		name = manifest.DEFAULT_REGISTRY + "/" + name
+	}
+	for _, pattern := range patterns {
+		subject := ref.Registry
+		if strings.Contains(pattern, "/") {
+			subject = name
+		}
+		if ok, _ := path.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
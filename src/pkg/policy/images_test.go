@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const imagesManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: ghcr.io/acme/migrate@sha256:abc
+      containers:
+        - name: web
+          image: ghcr.io/acme/web:v1.2.3
+        - name: proxy
+          image: envoyproxy/envoy
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+  namespace: app
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: cleanup
+              image: registry.internal:5000/tools/cleanup:edge
+`
+
+func TestEvaluateImagePolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  models.ImagePolicyConfig
+		want []string // resource ID and container of each violation
+	}{
+		{
+			name: "latest by default",
+			want: []string{"Deployment/app/web proxy"},
+		},
+		{
+			name: "disallowed tags",
+			cfg:  models.ImagePolicyConfig{DisallowedTags: []string{"latest", "edge"}},
+			want: []string{"Deployment/app/web proxy", "CronJob/app/cleanup cleanup"},
+		},
+		{
+			name: "require digest",
+			cfg:  models.ImagePolicyConfig{RequireDigest: true},
+			want: []string{"Deployment/app/web web", "Deployment/app/web proxy", "CronJob/app/cleanup cleanup"},
+		},
+		{
+			name: "allowed registries",
+			cfg:  models.ImagePolicyConfig{DisallowedTags: []string{"none"}, AllowedRegistries: []string{"ghcr.io", "docker.io/envoyproxy/*"}},
+			want: []string{"CronJob/app/cleanup cleanup"},
+		},
+		{
+			name: "allowed image names",
+			cfg:  models.ImagePolicyConfig{DisallowedTags: []string{"none"}, AllowedRegistries: []string{"ghcr.io/acme/*", "registry.internal:5000"}},
+			want: []string{"Deployment/app/web proxy"},
+		},
+	}
+	resources, err := manifest.Parse([]byte(imagesManifest))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	containerPattern := regexp.MustCompile(`container '([^']*)'`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range evaluateImagePolicy(&tt.cfg, resources) {
+				got = append(got, v.ResourceID+" "+containerPattern.FindStringSubmatch(v.Message)[1])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evaluateImagePolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		image string
+		want  manifest.ImageRef
+	}{
+		{"nginx", manifest.ImageRef{Name: "nginx", Registry: "docker.io"}},
+		{"acme/web:1.0", manifest.ImageRef{Name: "acme/web", Registry: "docker.io", Tag: "1.0"}},
+		{"localhost:5000/web", manifest.ImageRef{Name: "localhost:5000/web", Registry: "localhost:5000"}},
+		{"ghcr.io/acme/web:v1@sha256:abc", manifest.ImageRef{Name: "ghcr.io/acme/web", Registry: "ghcr.io", Tag: "v1", Digest: "sha256:abc"}},
+	}
+	for _, tt := range tests {
+		if got := manifest.ParseImage(tt.image); got != tt.want {
+			t.Errorf("ParseImage(%q) = %+v, want %+v", tt.image, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/worktree"
+)
+
+// IMPACT_* are the kinds of impact of a policy change on an overlay
+const (
+	IMPACT_NEWLY_FAILING  = "newly-failing"  // fails with the new policies, passed (or did not exist) with the old ones
+	IMPACT_NEWLY_BLOCKING = "newly-blocking" // fails with both, but blocks with the new policies only
+	IMPACT_NEWLY_PASSING  = "newly-passing"  // failed with the old policies, passes (or was removed) with the new ones
+)
+
+// policyOutcome is the result of a policy on an overlay and its enforcement level
+type policyOutcome struct {
+	result  models.PolicyResult
+	level   string
+	failing bool // fails at an enforced level, overridden and not in effect policies never fail
+}
+
+// outcomesOf returns the outcome of each policy of a policy matrix, by policy id
+func outcomesOf(matrix models.PolicyMatrix) map[string]policyOutcome {
+	outcomes := make(map[string]policyOutcome)
+	for _, level := range []struct {
+		level    string
+		policies []models.PolicyResult
+		enforced bool
+	}{
+		{POLICY_LEVEL_BLOCK, matrix.BlockingPolicies, true},
+		{POLICY_LEVEL_WARNING, matrix.WarningPolicies, true},
+		{POLICY_LEVEL_RECOMMEND, matrix.RecommendPolicies, true},
+		{POLICY_LEVEL_OVERRIDE, matrix.OverriddenPolicies, false},
+		{POLICY_LEVEL_NOT_IN_EFFECT, matrix.NotInEffectPolicies, false},
+	} {
+		for _, result := range level.policies {
+			outcomes[result.PolicyId] = policyOutcome{result: result, level: level.level, failing: level.enforced && !result.IsPassing}
+		}
+	}
+	return outcomes
+}
+
+// CompareEvaluations returns the policy results changed from the old to the new evaluation of the same
+// overlays, in overlay order then by policy id
+func CompareEvaluations(before, after *models.PolicyEvaluation, overlayKeys []string) []models.PolicyImpact {
+	var impacts []models.PolicyImpact
+	for _, overlayKey := range overlayKeys {
+		beforeOutcomes := outcomesOf(before.PolicyMatrix[overlayKey])
+		afterOutcomes := outcomesOf(after.PolicyMatrix[overlayKey])
+
+		var ids []string
+		for id := range beforeOutcomes {
+			ids = append(ids, id)
+		}
+		for id := range afterOutcomes {
+			if _, ok := beforeOutcomes[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+
+		for _, id := range ids {
+			prev, curr := beforeOutcomes[id], afterOutcomes[id]
+			var kind string
+			switch {
+			case curr.failing && !prev.failing:
+				kind = IMPACT_NEWLY_FAILING
+			case curr.failing && curr.level == POLICY_LEVEL_BLOCK && prev.level != POLICY_LEVEL_BLOCK:
+				kind = IMPACT_NEWLY_BLOCKING
+			case prev.failing && !curr.failing:
+				kind = IMPACT_NEWLY_PASSING
+			default:
+				continue
+			}
+			name := curr.result.PolicyName
+			if name == "" {
+				name = prev.result.PolicyName
+			}
+			impact := models.PolicyImpact{
+				Kind:        kind,
+				OverlayKey:  overlayKey,
+				PolicyId:    id,
+				PolicyName:  name,
+				BeforeLevel: prev.level,
+				AfterLevel:  curr.level,
+			}
+			if curr.failing {
+				impact.Messages = curr.result.FailMessages
+			}
+			impacts = append(impacts, impact)
+		}
+	}
+	return impacts
+}
+
+// CheckoutPoliciesAt checks out the policies directory of a git repository at another ref, in a temporary
+// worktree. Returns the policies directory of the worktree and the function removing the worktree
+func CheckoutPoliciesAt(ctx context.Context, policiesPath, ref string) (string, func(), error) {
+	dir, cleanup, err := worktree.CheckoutAt(ctx, policiesPath, ref)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to check out the policies at %s: %w", ref, err)
+	}
+	return dir, cleanup, nil
+}
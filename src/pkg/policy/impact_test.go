@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestCompareEvaluations(t *testing.T) {
+	pass := func(id string) models.PolicyResult {
+		return models.PolicyResult{PolicyId: id, PolicyName: id, IsPassing: true}
+	}
+	fail := func(id string) models.PolicyResult {
+		return models.PolicyResult{PolicyId: id, PolicyName: id, FailMessages: []string{id + " failed"}}
+	}
+	before := &models.PolicyEvaluation{PolicyMatrix: map[string]models.PolicyMatrix{
+		"app/prod": {
+			BlockingPolicies:    []models.PolicyResult{pass("ha")},
+			WarningPolicies:     []models.PolicyResult{fail("pdb"), fail("limits")},
+			NotInEffectPolicies: []models.PolicyResult{fail("labels")},
+		},
+		"app/stg": {WarningPolicies: []models.PolicyResult{fail("old")}},
+	}}
+	after := &models.PolicyEvaluation{PolicyMatrix: map[string]models.PolicyMatrix{
+		"app/prod": {
+			BlockingPolicies:  []models.PolicyResult{fail("ha"), fail("pdb")},
+			WarningPolicies:   []models.PolicyResult{fail("limits"), fail("images")},
+			RecommendPolicies: []models.PolicyResult{fail("labels")},
+		},
+		"app/stg": {},
+	}}
+
+	want := []models.PolicyImpact{
+		{Kind: IMPACT_NEWLY_FAILING, OverlayKey: "app/prod", PolicyId: "ha", PolicyName: "ha", BeforeLevel: POLICY_LEVEL_BLOCK, AfterLevel: POLICY_LEVEL_BLOCK, Messages: []string{"ha failed"}},
+		{Kind: IMPACT_NEWLY_FAILING, OverlayKey: "app/prod", PolicyId: "images", PolicyName: "images", AfterLevel: POLICY_LEVEL_WARNING, Messages: []string{"images failed"}},
+		{Kind: IMPACT_NEWLY_FAILING, OverlayKey: "app/prod", PolicyId: "labels", PolicyName: "labels", BeforeLevel: POLICY_LEVEL_NOT_IN_EFFECT, AfterLevel: POLICY_LEVEL_RECOMMEND, Messages: []string{"labels failed"}},
+		{Kind: IMPACT_NEWLY_BLOCKING, OverlayKey: "app/prod", PolicyId: "pdb", PolicyName: "pdb", BeforeLevel: POLICY_LEVEL_WARNING, AfterLevel: POLICY_LEVEL_BLOCK, Messages: []string{"pdb failed"}},
+		{Kind: IMPACT_NEWLY_PASSING, OverlayKey: "app/stg", PolicyId: "old", PolicyName: "old", BeforeLevel: POLICY_LEVEL_WARNING},
+	}
+	if got := CompareEvaluations(before, after, []string{"app/prod", "app/stg"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("CompareEvaluations() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestCheckoutPoliciesAt(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	policies := filepath.Join(repo, "policies")
+	if err := os.MkdirAll(policies, 0755); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(policies, COMPLIANCE_CONFIG_FILENAME), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "-q")
+	write("old")
+	git("add", "-A")
+	git("commit", "-q", "-m", "old")
+	git("tag", "v1")
+	write("new")
+	git("commit", "-q", "-am", "new")
+
+	dir, cleanup, err := CheckoutPoliciesAt(context.Background(), policies, "v1")
+	if err != nil {
+		t.Fatalf("CheckoutPoliciesAt() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, COMPLIANCE_CONFIG_FILENAME))
+	if err != nil || string(content) != "old" {
+		t.Errorf("CheckoutPoliciesAt() config = %q, %v, want %q", content, err, "old")
+	}
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("cleanup() left %s", dir)
+	}
+}
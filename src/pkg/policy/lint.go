@@ -0,0 +1,170 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+)
+
+const (
+	LINT_LEVEL_ERROR   = "error"
+	LINT_LEVEL_WARNING = "warning"
+)
+
+// LintOptions holds the settings of a policy lint
+type LintOptions struct {
+	Regal      bool             // Also run the Regal linter, on top of `opa check --strict`
+	ExecLimits sandbox.Limits   // Resource limits applied to each linter process
+	Executor   sandbox.Executor // Runs the linters, nil uses sandbox.DefaultExecutor
+}
+
+// LintDiagnostic is a finding of a linter on a policy file
+type LintDiagnostic struct {
+	Tool    string `json:"tool"`  // "opa" or "regal"
+	Level   string `json:"level"` // LINT_LEVEL_ERROR or LINT_LEVEL_WARNING
+	File    string `json:"file,omitempty"`
+	Row     int    `json:"row,omitempty"`
+	Col     int    `json:"col,omitempty"`
+	Code    string `json:"code,omitempty"` // e.g. "rego_type_error", or the Regal rule
+	Message string `json:"message"`
+}
+
+func (d LintDiagnostic) String() string {
+	location := d.File
+	if location == "" {
+		location = "(policies)"
+	}
+	if d.Row > 0 {
+		location += fmt.Sprintf(":%d:%d", d.Row, d.Col)
+	}
+	msg := fmt.Sprintf("%s: %s: %s", location, d.Level, d.Message)
+	if d.Code != "" {
+		msg += fmt.Sprintf(" (%s %s)", d.Tool, d.Code)
+	}
+	return msg
+}
+
+// Lint runs `opa check --strict` (and Regal if enabled) over the policies directory
+// returns: the diagnostics, an error only if a linter could not be run
+func Lint(ctx context.Context, policiesPath string, options LintOptions) ([]LintDiagnostic, error) {
+	executor := sandbox.OrDefault(options.Executor)
+	res, err := executor.Run(ctx, sandbox.Command{
+		Name: "opa", Args: []string{"check", "--strict", "--format", "json", policiesPath}, Limits: options.ExecLimits,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("opa check execution failed: %w", err)
+	}
+	diagnostics, err := parseOpaCheck(res)
+	if err != nil {
+		return nil, err
+	}
+	if !options.Regal {
+		return diagnostics, nil
+	}
+
+	res, err = executor.Run(ctx, sandbox.Command{
+		Name: "regal", Args: []string{"lint", "--format", "json", policiesPath}, Limits: options.ExecLimits,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("regal lint execution failed: %w", err)
+	}
+	regalDiagnostics, err := parseRegalLint(res)
+	if err != nil {
+		return nil, err
+	}
+	return append(diagnostics, regalDiagnostics...), nil
+}
+
+// HasLintErrors reports whether any diagnostic is an error
+func HasLintErrors(diagnostics []LintDiagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Level == LINT_LEVEL_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatLintDiagnostics renders the diagnostics one per line, compiler style
+func FormatLintDiagnostics(diagnostics []LintDiagnostic) string {
+	lines := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type lintLocation struct {
+	File string `json:"file"`
+	Row  int    `json:"row"`
+	Col  int    `json:"col"`
+}
+
+// parseOpaCheck parses the json errors of `opa check`, written to stdout or stderr depending on the version
+func parseOpaCheck(res *sandbox.Result) ([]LintDiagnostic, error) {
+	if res.ExitCode == 0 {
+		return nil, nil
+	}
+	output := struct {
+		Errors []struct {
+			Message  string        `json:"message"`
+			Code     string        `json:"code"`
+			Location *lintLocation `json:"location"`
+		} `json:"errors"`
+	}{}
+	raw := res.Stdout
+	if len(strings.TrimSpace(string(raw))) == 0 {
+		raw = res.Stderr
+	}
+	if err := json.Unmarshal(raw, &output); err != nil || len(output.Errors) == 0 {
+		// not a compilation error report, e.g. a usage error
+		return nil, fmt.Errorf("opa check failed (exit code %d): %s", res.ExitCode, strings.TrimSpace(string(raw)))
+	}
+
+	diagnostics := make([]LintDiagnostic, 0, len(output.Errors))
+	for _, e := range output.Errors {
+		d := LintDiagnostic{Tool: "opa", Level: LINT_LEVEL_ERROR, Code: e.Code, Message: e.Message}
+		if e.Location != nil {
+			d.File, d.Row, d.Col = e.Location.File, e.Location.Row, e.Location.Col
+		}
+		diagnostics = append(diagnostics, d)
+	}
+	return diagnostics, nil
+}
+
+// parseRegalLint parses the json report of `regal lint`, which exits non-zero when it found error-level violations
+func parseRegalLint(res *sandbox.Result) ([]LintDiagnostic, error) {
+	output := struct {
+		Violations []struct {
+			Title       string       `json:"title"`
+			Description string       `json:"description"`
+			Category    string       `json:"category"`
+			Level       string       `json:"level"`
+			Location    lintLocation `json:"location"`
+		} `json:"violations"`
+	}{}
+	if err := json.Unmarshal(res.Stdout, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse regal lint output (exit code %d): %w\nStderr: %s", res.ExitCode, err, string(res.Stderr))
+	}
+
+	diagnostics := make([]LintDiagnostic, 0, len(output.Violations))
+	for _, v := range output.Violations {
+		level := LINT_LEVEL_WARNING
+		if v.Level == LINT_LEVEL_ERROR {
+			level = LINT_LEVEL_ERROR
+		}
+		diagnostics = append(diagnostics, LintDiagnostic{
+			Tool:    "regal",
+			Level:   level,
+			File:    v.Location.File,
+			Row:     v.Location.Row,
+			Col:     v.Location.Col,
+			Code:    v.Category + "/" + v.Title,
+			Message: v.Description,
+		})
+	}
+	return diagnostics, nil
+}
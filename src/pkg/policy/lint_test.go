@@ -0,0 +1,63 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+)
+
+func TestParseOpaCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		res     sandbox.Result
+		want    []LintDiagnostic
+		wantErr bool
+	}{
+		{name: "clean", res: sandbox.Result{}},
+		{
+			name: "errors on stderr",
+			res: sandbox.Result{ExitCode: 1, Stderr: []byte(`{"errors":[{"message":"var x is unsafe","code":"rego_unsafe_var_error",` +
+				`"location":{"file":"policies/ha.rego","row":7,"col":3}}]}`)},
+			want: []LintDiagnostic{{Tool: "opa", Level: LINT_LEVEL_ERROR, File: "policies/ha.rego", Row: 7, Col: 3,
+				Code: "rego_unsafe_var_error", Message: "var x is unsafe"}},
+		},
+		{name: "not a report", res: sandbox.Result{ExitCode: 1, Stderr: []byte("unknown flag: --strict")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOpaCheck(&tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOpaCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseOpaCheck() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRegalLint(t *testing.T) {
+	res := &sandbox.Result{ExitCode: 3, Stdout: []byte(`{"violations":[` +
+		`{"title":"prefer-snake-case","description":"Prefer snake_case for names","category":"style","level":"error",` +
+		`"location":{"file":"ha.rego","row":4,"col":1}},` +
+		`{"title":"line-length","description":"Line too long","category":"style","level":"warning",` +
+		`"location":{"file":"ha.rego","row":9,"col":121}}]}`)}
+	got, err := parseRegalLint(res)
+	if err != nil {
+		t.Fatalf("parseRegalLint() error = %v", err)
+	}
+	want := []LintDiagnostic{
+		{Tool: "regal", Level: LINT_LEVEL_ERROR, File: "ha.rego", Row: 4, Col: 1, Code: "style/prefer-snake-case", Message: "Prefer snake_case for names"},
+		{Tool: "regal", Level: LINT_LEVEL_WARNING, File: "ha.rego", Row: 9, Col: 121, Code: "style/line-length", Message: "Line too long"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRegalLint() = %+v, want %+v", got, want)
+	}
+	if !HasLintErrors(got) {
+		t.Errorf("HasLintErrors() = false, want true")
+	}
+	if s := got[0].String(); s != "ha.rego:4:1: error: Prefer snake_case for names (regal style/prefer-snake-case)" {
+		t.Errorf("String() = %q", s)
+	}
+}
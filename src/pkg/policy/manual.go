@@ -0,0 +1,59 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// validateManualPolicy checks the settings of a manual policy, its level comes from required, not from dates
+func validateManualPolicy(policy models.PolicyConfig) error {
+	if policy.Manual == nil || policy.Manual.Item == "" {
+		return fmt.Errorf("manual.item is required")
+	}
+	enforcement := policy.Enforcement
+	if enforcement.InEffectAfter != nil || enforcement.IsWarningAfter != nil || enforcement.IsBlockingAfter != nil {
+		return fmt.Errorf("a manual policy has no enforcement dates, set manual.required to make it blocking")
+	}
+	return nil
+}
+
+// evaluateManualPolicy fails a manual policy until its checklist item is ticked
+func evaluateManualPolicy(cfg *models.ManualPolicyConfig, checked bool) []models.PolicyViolation {
+	if checked {
+		return []models.PolicyViolation{}
+	}
+	return []models.PolicyViolation{{Message: "Checklist item not ticked: " + cfg.Item}}
+}
+
+// manualPolicyLevel returns the enforcement level of a manual policy, before tier escalation
+func manualPolicyLevel(cfg *models.ManualPolicyConfig) string {
+	if cfg.Required {
+		return POLICY_LEVEL_BLOCK
+	}
+	return POLICY_LEVEL_RECOMMEND
+}
+
+// SetChecklist sets the ticked checklist items, by manual policy id, read from the comment of the previous run.
+// Must be called before evaluation, the items not ticked fail
+func (e *PolicyEvaluator) SetChecklist(checked map[string]bool) {
+	e.checklist = checked
+}
+
+// Checklist returns the checklist items of the evaluated manual policies, in config order
+func (e *PolicyEvaluator) Checklist() []models.ChecklistItem {
+	var items []models.ChecklistItem
+	for _, id := range e.policyIDs() {
+		policy := e.data.ComplianceConfig.Policies[id]
+		if policy.Type != POLICY_TYPE_MANUAL {
+			continue
+		}
+		items = append(items, models.ChecklistItem{
+			PolicyId: id,
+			Text:     policy.Manual.Item,
+			Required: policy.Manual.Required,
+			Checked:  e.checklist[id],
+		})
+	}
+	return items
+}
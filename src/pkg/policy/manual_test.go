@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestValidateManualPolicy(t *testing.T) {
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		policy  models.PolicyConfig
+		wantErr bool
+	}{
+		{name: "item", policy: models.PolicyConfig{Manual: &models.ManualPolicyConfig{Item: "DBA reviewed the schema change", Required: true}}},
+		{name: "missing settings", policy: models.PolicyConfig{}, wantErr: true},
+		{name: "missing item", policy: models.PolicyConfig{Manual: &models.ManualPolicyConfig{Required: true}}, wantErr: true},
+		{
+			name: "enforcement dates",
+			policy: models.PolicyConfig{
+				Manual:      &models.ManualPolicyConfig{Item: "DBA reviewed the schema change"},
+				Enforcement: models.EnforcementConfig{IsBlockingAfter: &since},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		if err := validateManualPolicy(tt.policy); (err != nil) != tt.wantErr {
+			t.Errorf("validateManualPolicy() %s error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestManualPolicies(t *testing.T) {
+	e := NewPolicyEvaluator("")
+	e.data.ComplianceConfig.PolicyIDs = []string{"dba", "runbook"}
+	e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+		"dba":     {Type: POLICY_TYPE_MANUAL, Manual: &models.ManualPolicyConfig{Item: "DBA reviewed the schema change", Required: true}},
+		"runbook": {Type: POLICY_TYPE_MANUAL, Manual: &models.ManualPolicyConfig{Item: "Runbook updated"}},
+	}
+	e.SetChecklist(map[string]bool{"runbook": true})
+
+	levels, _, err := e.enforcementLevelsAt(e.now(), nil)
+	if err != nil {
+		t.Fatalf("enforcementLevelsAt() error = %v", err)
+	}
+	if want := map[string]string{"dba": POLICY_LEVEL_BLOCK, "runbook": POLICY_LEVEL_RECOMMEND}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("enforcementLevelsAt() = %v, want %v", levels, want)
+	}
+
+	wantItems := []models.ChecklistItem{
+		{PolicyId: "dba", Text: "DBA reviewed the schema change", Required: true},
+		{PolicyId: "runbook", Text: "Runbook updated", Checked: true},
+	}
+	if items := e.Checklist(); !reflect.DeepEqual(items, wantItems) {
+		t.Errorf("Checklist() = %+v, want %+v", items, wantItems)
+	}
+	if violations := evaluateManualPolicy(e.data.ComplianceConfig.Policies["dba"].Manual, false); len(violations) != 1 {
+		t.Errorf("evaluateManualPolicy() unticked = %v, want 1 violation", violations)
+	}
+	if violations := evaluateManualPolicy(e.data.ComplianceConfig.Policies["runbook"].Manual, true); len(violations) != 0 {
+		t.Errorf("evaluateManualPolicy() ticked = %v, want none", violations)
+	}
+}
@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/pathbuilder"
+)
+
+// validateMetadataPolicy checks the rules of a metadata policy
+func validateMetadataPolicy(cfg *models.MetadataPolicyConfig) error {
+	if cfg == nil || len(cfg.Rules) == 0 {
+		return fmt.Errorf("metadata.rules is required")
+	}
+	for i, rule := range cfg.Rules {
+		if len(rule.Labels) == 0 && len(rule.Annotations) == 0 {
+			return fmt.Errorf("metadata rule %d: labels or annotations is required", i)
+		}
+		patterns := slices.Concat(rule.Kinds, rule.Namespaces, slices.Collect(maps.Values(rule.Labels)), slices.Collect(maps.Values(rule.Annotations)))
+		for _, pattern := range patterns {
+			if _, err := matchMetadataPattern(pattern, ""); err != nil {
+				return fmt.Errorf("metadata rule %d: invalid pattern %q: %w", i, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// evaluateMetadataPolicy checks the labels and annotations of the resources against the rules of a metadata
+// policy, one violation per resource and missing or mismatching key
+func evaluateMetadataPolicy(cfg *models.MetadataPolicyConfig, resources []manifest.Resource) []models.PolicyViolation {
+	violations := []models.PolicyViolation{}
+	for _, res := range resources {
+		for _, rule := range cfg.Rules {
+			if !metadataRuleSelects(rule, res) {
+				continue
+			}
+			fields := []struct {
+				name     string
+				required map[string]string
+			}{{"label", rule.Labels}, {"annotation", rule.Annotations}}
+			for _, field := range fields {
+				values := manifest.NestedMap(res.Object, "metadata", field.name+"s")
+				for _, key := range slices.Sorted(maps.Keys(field.required)) {
+					message := metadataViolation(field.name, key, field.required[key], values)
+					if message != "" {
+						violations = append(violations, models.PolicyViolation{Message: res.ID() + " " + message, ResourceID: res.ID()})
+					}
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// metadataRuleSelects reports whether a rule checks a resource, by its kind and namespace
+func metadataRuleSelects(rule models.MetadataRuleConfig, res manifest.Resource) bool {
+	matchesAny := func(patterns []string, value string) bool {
+		return len(patterns) == 0 || slices.ContainsFunc(patterns, func(pattern string) bool {
+			ok, _ := matchMetadataPattern(pattern, value)
+			return ok
+		})
+	}
+	return matchesAny(rule.Kinds, res.Kind) && matchesAny(rule.Namespaces, res.Namespace)
+}
+
+// metadataViolation returns the violation of a required label or annotation, empty if satisfied
+func metadataViolation(field, key, pattern string, values map[string]interface{}) string {
+	raw, ok := values[key]
+	if !ok {
+		return fmt.Sprintf("is missing the %s '%s'", field, key)
+	}
+	if pattern == "" {
+		return ""
+	}
+	value := fmt.Sprint(raw)
+	if ok, _ := matchMetadataPattern(pattern, value); !ok {
+		return fmt.Sprintf("has the %s '%s: %s', expected a value matching '%s'", field, key, value, pattern)
+	}
+	return ""
+}
+
+// matchMetadataPattern matches a value with a glob, or in full with a regular expression prefixed with "re:"
+func matchMetadataPattern(pattern, value string) (bool, error) {
+	if expr, ok := strings.CutPrefix(pattern, pathbuilder.REGEX_VALUE_PREFIX); ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(value), nil
+	}
+	return path.Match(pattern, value)
+}
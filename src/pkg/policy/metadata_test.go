@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const metadataManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+  labels:
+    app.kubernetes.io/part-of: shop
+    team: payments
+  annotations:
+    argocd.argoproj.io/sync-wave: 1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: app
+  annotations:
+    argocd.argoproj.io/sync-wave: early
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`
+
+func TestEvaluateMetadataPolicy(t *testing.T) {
+	resources, err := manifest.Parse([]byte(metadataManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		rule models.MetadataRuleConfig
+		want []string
+	}{
+		{
+			name: "required label of all resources",
+			rule: models.MetadataRuleConfig{Labels: map[string]string{"team": ""}},
+			want: []string{
+				"ConfigMap/app/settings is missing the label 'team'",
+				"Namespace/app is missing the label 'team'",
+			},
+		},
+		{
+			name: "annotation value pattern of the namespaced resources",
+			rule: models.MetadataRuleConfig{Namespaces: []string{"app"}, Annotations: map[string]string{"argocd.argoproj.io/sync-wave": "re:-?[0-9]+"}},
+			want: []string{
+				"ConfigMap/app/settings has the annotation 'argocd.argoproj.io/sync-wave: early', expected a value matching 're:-?[0-9]+'",
+			},
+		},
+		{
+			name: "kinds and label value glob",
+			rule: models.MetadataRuleConfig{Kinds: []string{"Deployment", "Stateful*"}, Labels: map[string]string{"app.kubernetes.io/part-of": "sh*", "team": "checkout"}},
+			want: []string{
+				"Deployment/app/web has the label 'team: payments', expected a value matching 'checkout'",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &models.MetadataPolicyConfig{Rules: []models.MetadataRuleConfig{tt.rule}}
+			if err := validateMetadataPolicy(cfg); err != nil {
+				t.Fatalf("validateMetadataPolicy() error = %v", err)
+			}
+			got := violationMessages(evaluateMetadataPolicy(cfg, resources))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evaluateMetadataPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMetadataPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *models.MetadataPolicyConfig
+	}{
+		{"no rules", &models.MetadataPolicyConfig{}},
+		{"nothing required", &models.MetadataPolicyConfig{Rules: []models.MetadataRuleConfig{{Kinds: []string{"Deployment"}}}}},
+		{"invalid regex", &models.MetadataPolicyConfig{Rules: []models.MetadataRuleConfig{{Labels: map[string]string{"team": "re:("}}}}},
+		{"invalid glob", &models.MetadataPolicyConfig{Rules: []models.MetadataRuleConfig{{Kinds: []string{"[Deploy"}, Labels: map[string]string{"team": ""}}}}},
+	}
+	for _, tt := range tests {
+		if err := validateMetadataPolicy(tt.cfg); err == nil {
+			t.Errorf("validateMetadataPolicy(%s) succeeded, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package policy
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// POLICY_PACK_BASELINE is the pack of the workload hygiene rules most platform teams start with
+const POLICY_PACK_BASELINE = "baseline"
+
+// policyPack is a built-in policy pack. Its version is bumped when a rule is added, so that the packs pinned to an
+// earlier version keep running the same rules
+type policyPack struct {
+	version      int
+	rules        []packRule // in registration order
+	deprecations []packDeprecation
+}
+
+// packRule is a rule of a built-in policy pack, it checks a pod spec of a resource and returns the messages of its
+// violations, each prefixed with the resource id
+type packRule struct {
+	name  string
+	title string
+	since int      // version of the pack that added the rule
+	kinds []string // kinds of the checked resources, all resources with a pod spec if empty
+	check func(podSpec map[string]interface{}) []string
+}
+
+// packDeprecation is a deprecated rule of a pack: a rule still run until it is removed, or the old name of the
+// rule replacedBy, its configuration then applied to the replacement
+type packDeprecation struct {
+	rule       string
+	replacedBy string
+	hint       string
+}
+
+// policyPacks are the built-in policy packs by name
+var policyPacks = map[string]policyPack{
+	POLICY_PACK_BASELINE: {
+		version: 2,
+		rules: []packRule{
+			{name: "probes", title: "Readiness and Liveness Probes", since: 1, kinds: []string{"Deployment", "StatefulSet", "DaemonSet"}, check: checkProbes},
+			{name: "resource-limits", title: "Resource Requests and Memory Limits", since: 1, check: checkResources},
+			{name: "non-root", title: "Non-Root Containers", since: 1, check: checkNonRoot},
+			{name: "image-pull-policy", title: "Explicit Image Pull Policy", since: 1, check: checkImagePullPolicy},
+			{name: "no-privileged", title: "No Privileged Pods", since: 1, check: checkPrivileged},
+			{name: "read-only-root-filesystem", title: "Read-Only Root Filesystem", since: 2, check: checkReadOnlyRootFilesystem},
+		},
+	},
+}
+
+// BuiltinPolicyPacks describes the built-in policy packs with all their rules, by pack name
+func BuiltinPolicyPacks() []models.PolicyPack {
+	var packs []models.PolicyPack
+	for _, name := range slices.Sorted(maps.Keys(policyPacks)) {
+		pack := policyPacks[name]
+		info := models.PolicyPack{Name: name, Version: pack.version, Deprecations: packDeprecations(pack, nil)}
+		for _, rule := range pack.rules {
+			info.Rules = append(info.Rules, packRuleInfo(name, rule))
+		}
+		packs = append(packs, info)
+	}
+	return packs
+}
+
+// PolicyPacks describes the policy packs enabled by the compliance config, the rules run and the deprecations
+// the config is concerned by
+func (e *PolicyEvaluator) PolicyPacks() []models.PolicyPack {
+	return e.policyPacks
+}
+
+// registerPolicyPacks adds a policy per enabled rule of the configured packs after the user policies, in pack then
+// rule order, with the enforcement of the rule or of its pack and a default override command per rule. The rules
+// added after the pinned version of a pack are left out, the deprecated rules run or configured are logged
+func (e *PolicyEvaluator) registerPolicyPacks() error {
+	cfg := &e.data.ComplianceConfig
+	e.policyPacks = nil
+	names := cfg.PolicyPackNames
+	if len(names) == 0 {
+		names = slices.Sorted(maps.Keys(cfg.PolicyPacks))
+	}
+	for _, name := range names {
+		packCfg, ok := cfg.PolicyPacks[name]
+		if !ok {
+			continue
+		}
+		pack, ok := policyPacks[name]
+		if !ok {
+			return fmt.Errorf("policyPacks: unknown pack %s (must be one of %v)", name, slices.Sorted(maps.Keys(policyPacks)))
+		}
+		if packCfg.Version < 0 || packCfg.Version > pack.version {
+			return fmt.Errorf("policyPacks.%s: version %d is not supported, this version of gitops-kustomzchk ships version %d",
+				name, packCfg.Version, pack.version)
+		}
+		if packCfg.Enforcement.Override.Comment != "" {
+			return fmt.Errorf("policyPacks.%s: the override comments are set per rule", name)
+		}
+		ruleCfgs, renamed, err := packRuleConfigs(name, pack, packCfg.Rules)
+		if err != nil {
+			return err
+		}
+
+		version := packCfg.Version
+		if version == 0 {
+			version = pack.version
+		}
+		info := models.PolicyPack{Name: name, Version: pack.version, PinnedVersion: packCfg.Version}
+		var run []string
+		for _, rule := range pack.rules {
+			ruleCfg := ruleCfgs[rule.name]
+			if rule.since > version {
+				info.NewerRules = append(info.NewerRules, packRuleInfo(name, rule))
+				continue
+			}
+			if ruleCfg.Disabled {
+				continue
+			}
+			id := name + "." + rule.name
+			if _, ok := cfg.Policies[id]; ok {
+				return fmt.Errorf("policy %s: the id is reserved by the policy pack %s", id, name)
+			}
+			enforcement := packCfg.Enforcement
+			if ruleCfg.Enforcement != nil {
+				enforcement = *ruleCfg.Enforcement
+			}
+			if enforcement.Override.Comment == "" {
+				enforcement.Override.Comment = "/sp-override-" + name + "-" + rule.name
+			}
+			if cfg.Policies == nil {
+				cfg.Policies = make(map[string]models.PolicyConfig)
+			}
+			cfg.Policies[id] = models.PolicyConfig{Name: rule.title, Type: POLICY_TYPE_PACK, PackRule: id, Enforcement: enforcement}
+			cfg.PolicyIDs = append(cfg.PolicyIDs, id)
+			info.Rules = append(info.Rules, packRuleInfo(name, rule))
+			run = append(run, rule.name)
+		}
+		info.Deprecations = packDeprecations(pack, append(run, renamed...))
+		for _, deprecation := range info.Deprecations {
+			logger.Warnf("policy pack %s: rule %s is deprecated: %s", name, deprecation.Rule, deprecation.Hint)
+		}
+		e.policyPacks = append(e.policyPacks, info)
+	}
+	return nil
+}
+
+// packRuleConfigs returns the configurations of the rules of a pack by rule name, the ones set under the old name
+// of a renamed rule moved to the replacement, and the old names used
+func packRuleConfigs(name string, pack policyPack, rules map[string]models.PolicyPackRuleConfig) (map[string]models.PolicyPackRuleConfig, []string, error) {
+	configs := make(map[string]models.PolicyPackRuleConfig)
+	var renamed []string
+	for _, ruleName := range slices.Sorted(maps.Keys(rules)) {
+		if slices.ContainsFunc(pack.rules, func(rule packRule) bool { return rule.name == ruleName }) {
+			configs[ruleName] = rules[ruleName]
+			continue
+		}
+		index := slices.IndexFunc(pack.deprecations, func(d packDeprecation) bool { return d.rule == ruleName && d.replacedBy != "" })
+		if index < 0 {
+			return nil, nil, fmt.Errorf("policyPacks.%s: unknown rule %s", name, ruleName)
+		}
+		replacement := pack.deprecations[index].replacedBy
+		if _, ok := rules[replacement]; ok {
+			return nil, nil, fmt.Errorf("policyPacks.%s: rule %s is renamed %s, configure it once", name, ruleName, replacement)
+		}
+		configs[replacement] = rules[ruleName]
+		renamed = append(renamed, ruleName)
+	}
+	return configs, renamed, nil
+}
+
+// packDeprecations returns the deprecations of a pack concerning the rule names, all of them if nil
+func packDeprecations(pack policyPack, ruleNames []string) []models.PolicyPackDeprecation {
+	var deprecations []models.PolicyPackDeprecation
+	for _, d := range pack.deprecations {
+		if ruleNames == nil || slices.Contains(ruleNames, d.rule) {
+			deprecations = append(deprecations, models.PolicyPackDeprecation{Rule: d.rule, ReplacedBy: d.replacedBy, Hint: d.hint})
+		}
+	}
+	return deprecations
+}
+
+func packRuleInfo(pack string, rule packRule) models.PolicyPackRule {
+	return models.PolicyPackRule{ID: pack + "." + rule.name, Title: rule.title, Since: rule.since}
+}
+
+// evaluatePackRule checks the pod specs of the resources with a rule of a pack, "<pack>.<rule>"
+func evaluatePackRule(packRuleID string, resources []manifest.Resource) []models.PolicyViolation {
+	pack, ruleName, _ := strings.Cut(packRuleID, ".")
+	rules := policyPacks[pack].rules
+	index := slices.IndexFunc(rules, func(rule packRule) bool { return rule.name == ruleName })
+	if index < 0 {
+		return nil
+	}
+	rule := rules[index]
+
+	violations := []models.PolicyViolation{}
+	for _, res := range resources {
+		if len(rule.kinds) > 0 && !slices.Contains(rule.kinds, res.Kind) {
+			continue
+		}
+		for _, podSpec := range manifest.PodSpecs(res.Object) {
+			for _, message := range rule.check(podSpec) {
+				violations = append(violations, models.PolicyViolation{Message: res.ID() + " " + message, ResourceID: res.ID()})
+			}
+		}
+	}
+	return violations
+}
+
+// checkProbes requires a readiness and a liveness probe on the containers
+func checkProbes(podSpec map[string]interface{}) []string {
+	var messages []string
+	for _, container := range manifest.Containers(podSpec, false) {
+		for _, probe := range []string{"readinessProbe", "livenessProbe"} {
+			if container[probe] == nil {
+				messages = append(messages, fmt.Sprintf("container '%s' has no %s", manifest.NestedString(container, "name"), probe))
+			}
+		}
+	}
+	return messages
+}
+
+// checkResources requires cpu and memory requests and a memory limit on the containers and init containers, a cpu
+// limit throttles the container and is not required
+func checkResources(podSpec map[string]interface{}) []string {
+	var messages []string
+	for _, container := range manifest.Containers(podSpec, true) {
+		var missing []string
+		for _, field := range [][]string{{"requests", "cpu"}, {"requests", "memory"}, {"limits", "memory"}} {
+			if manifest.Nested(container, "resources", field[0], field[1]) == nil {
+				missing = append(missing, "resources."+field[0]+"."+field[1])
+			}
+		}
+		if len(missing) > 0 {
+			messages = append(messages, fmt.Sprintf("container '%s' sets no %s", manifest.NestedString(container, "name"), strings.Join(missing, ", ")))
+		}
+	}
+	return messages
+}
+
+// checkNonRoot requires runAsNonRoot on the containers and init containers, set on them or on the pod, and no
+// runAsUser 0
+func checkNonRoot(podSpec map[string]interface{}) []string {
+	var messages []string
+	for _, container := range manifest.Containers(podSpec, true) {
+		name := manifest.NestedString(container, "name")
+		setting := func(field string) interface{} {
+			if value := manifest.Nested(container, "securityContext", field); value != nil {
+				return value
+			}
+			return manifest.Nested(podSpec, "securityContext", field)
+		}
+		if user, ok := setting("runAsUser").(int); ok && user == 0 {
+			messages = append(messages, fmt.Sprintf("container '%s' runs as root (runAsUser: 0)", name))
+		} else if nonRoot, _ := setting("runAsNonRoot").(bool); !nonRoot {
+			messages = append(messages, fmt.Sprintf("container '%s' does not set securityContext.runAsNonRoot: true", name))
+		}
+	}
+	return messages
+}
+
+// checkImagePullPolicy requires an explicit imagePullPolicy on the containers and init containers, other than Never
+func checkImagePullPolicy(podSpec map[string]interface{}) []string {
+	var messages []string
+	for _, container := range manifest.Containers(podSpec, true) {
+		name := manifest.NestedString(container, "name")
+		switch policy := manifest.NestedString(container, "imagePullPolicy"); policy {
+		case "":
+			messages = append(messages, fmt.Sprintf("container '%s' sets no imagePullPolicy, its default depends on the image tag", name))
+		case "Never":
+			messages = append(messages, fmt.Sprintf("container '%s' has imagePullPolicy Never, the image must be preloaded on the nodes", name))
+		}
+	}
+	return messages
+}
+
+// checkPrivileged rejects the privileged containers, the privilege escalation and the host namespaces
+func checkPrivileged(podSpec map[string]interface{}) []string {
+	var messages []string
+	for _, field := range []string{"hostNetwork", "hostPID", "hostIPC"} {
+		if enabled, _ := podSpec[field].(bool); enabled {
+			messages = append(messages, fmt.Sprintf("uses the host namespace %s", field))
+		}
+	}
+	for _, container := range manifest.Containers(podSpec, true) {
+		name := manifest.NestedString(container, "name")
+		if privileged, _ := manifest.Nested(container, "securityContext", "privileged").(bool); privileged {
+			messages = append(messages, fmt.Sprintf("container '%s' is privileged", name))
+		}
+		if escalation, _ := manifest.Nested(container, "securityContext", "allowPrivilegeEscalation").(bool); escalation {
+			messages = append(messages, fmt.Sprintf("container '%s' allows privilege escalation", name))
+		}
+	}
+	return messages
+}
+
+// checkReadOnlyRootFilesystem requires a read-only root filesystem on the containers and init containers, the
+// writable paths mounted as volumes
+func checkReadOnlyRootFilesystem(podSpec map[string]interface{}) []string {
+	var messages []string
+	for _, container := range manifest.Containers(podSpec, true) {
+		if readOnly, _ := manifest.Nested(container, "securityContext", "readOnlyRootFilesystem").(bool); !readOnly {
+			messages = append(messages, fmt.Sprintf("container '%s' does not set securityContext.readOnlyRootFilesystem: true", manifest.NestedString(container, "name")))
+		}
+	}
+	return messages
+}
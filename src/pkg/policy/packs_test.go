@@ -0,0 +1,211 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const packManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  template:
+    spec:
+      securityContext:
+        runAsNonRoot: true
+      initContainers:
+      - name: migrate
+        image: migrate:1.0
+        securityContext:
+          runAsUser: 0
+      containers:
+      - name: web
+        image: web:1.0
+        imagePullPolicy: IfNotPresent
+        readinessProbe:
+          httpGet: {path: /ready, port: 8080}
+        resources:
+          requests: {cpu: 100m, memory: 128Mi}
+          limits: {memory: 256Mi}
+        securityContext:
+          allowPrivilegeEscalation: true
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+  namespace: app
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          hostNetwork: true
+          containers:
+          - name: cleanup
+            image: cleanup:1.0
+            imagePullPolicy: Never
+            securityContext:
+              privileged: true
+`
+
+func TestEvaluatePackRule(t *testing.T) {
+	resources, err := manifest.Parse([]byte(packManifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"baseline.probes", []string{
+			"Deployment/app/web container 'web' has no livenessProbe",
+		}},
+		{"baseline.resource-limits", []string{
+			"Deployment/app/web container 'migrate' sets no resources.requests.cpu, resources.requests.memory, resources.limits.memory",
+			"CronJob/app/cleanup container 'cleanup' sets no resources.requests.cpu, resources.requests.memory, resources.limits.memory",
+		}},
+		{"baseline.non-root", []string{
+			"Deployment/app/web container 'migrate' runs as root (runAsUser: 0)",
+			"CronJob/app/cleanup container 'cleanup' does not set securityContext.runAsNonRoot: true",
+		}},
+		{"baseline.image-pull-policy", []string{
+			"Deployment/app/web container 'migrate' sets no imagePullPolicy, its default depends on the image tag",
+			"CronJob/app/cleanup container 'cleanup' has imagePullPolicy Never, the image must be preloaded on the nodes",
+		}},
+		{"baseline.no-privileged", []string{
+			"Deployment/app/web container 'web' allows privilege escalation",
+			"CronJob/app/cleanup uses the host namespace hostNetwork",
+			"CronJob/app/cleanup container 'cleanup' is privileged",
+		}},
+		{"baseline.read-only-root-filesystem", []string{
+			"Deployment/app/web container 'web' does not set securityContext.readOnlyRootFilesystem: true",
+			"Deployment/app/web container 'migrate' does not set securityContext.readOnlyRootFilesystem: true",
+			"CronJob/app/cleanup container 'cleanup' does not set securityContext.readOnlyRootFilesystem: true",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			if got := violationMessages(evaluatePackRule(tt.rule, resources)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evaluatePackRule() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterPolicyPacks(t *testing.T) {
+	warning, blocking := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		cfg     models.ComplianceConfig
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name: "rules of the pinned version with the pack enforcement, after the user policies",
+			cfg: models.ComplianceConfig{
+				Policies:  map[string]models.PolicyConfig{"ha": {Name: "HA", Type: POLICY_TYPE_OPA}},
+				PolicyIDs: []string{"ha"},
+				PolicyPacks: map[string]models.PolicyPackConfig{POLICY_PACK_BASELINE: {
+					Version:     1,
+					Enforcement: models.EnforcementConfig{IsWarningAfter: &warning},
+					Rules: map[string]models.PolicyPackRuleConfig{
+						"non-root":          {Enforcement: &models.EnforcementConfig{IsBlockingAfter: &blocking}},
+						"image-pull-policy": {Disabled: true},
+					},
+				}},
+			},
+			wantIDs: []string{"ha", "baseline.probes", "baseline.resource-limits", "baseline.non-root", "baseline.no-privileged"},
+		},
+		{
+			name:    "version newer than the pack",
+			cfg:     models.ComplianceConfig{PolicyPacks: map[string]models.PolicyPackConfig{POLICY_PACK_BASELINE: {Version: 3}}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown pack",
+			cfg:     models.ComplianceConfig{PolicyPacks: map[string]models.PolicyPackConfig{"strict": {}}},
+			wantErr: true,
+		},
+		{
+			name: "unknown rule",
+			cfg: models.ComplianceConfig{PolicyPacks: map[string]models.PolicyPackConfig{POLICY_PACK_BASELINE: {
+				Rules: map[string]models.PolicyPackRuleConfig{"probe": {Disabled: true}},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "id of a user policy",
+			cfg: models.ComplianceConfig{
+				Policies:    map[string]models.PolicyConfig{"baseline.probes": {Name: "Probes", Type: POLICY_TYPE_OPA}},
+				PolicyPacks: map[string]models.PolicyPackConfig{POLICY_PACK_BASELINE: {}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &PolicyEvaluator{data: EvaluatorData{ComplianceConfig: tt.cfg}}
+			err := e.registerPolicyPacks()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("registerPolicyPacks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			cfg := e.data.ComplianceConfig
+			if !reflect.DeepEqual(cfg.PolicyIDs, tt.wantIDs) {
+				t.Errorf("PolicyIDs = %v, want %v", cfg.PolicyIDs, tt.wantIDs)
+			}
+			probes, nonRoot := cfg.Policies["baseline.probes"], cfg.Policies["baseline.non-root"]
+			if probes.Enforcement.IsWarningAfter != &warning || probes.Enforcement.Override.Comment != "/sp-override-baseline-probes" {
+				t.Errorf("baseline.probes enforcement = %+v, want the pack enforcement and the default override", probes.Enforcement)
+			}
+			if nonRoot.Enforcement.IsBlockingAfter != &blocking || nonRoot.Enforcement.IsWarningAfter != nil {
+				t.Errorf("baseline.non-root enforcement = %+v, want the rule enforcement", nonRoot.Enforcement)
+			}
+			newer := e.PolicyPacks()[0].NewerRules
+			if len(newer) != 1 || newer[0].ID != "baseline.read-only-root-filesystem" {
+				t.Errorf("NewerRules = %+v, want the rule of version 2", newer)
+			}
+		})
+	}
+}
+
+func TestRegisterPolicyPacks_Deprecations(t *testing.T) {
+	policyPacks["legacy"] = policyPack{
+		version: 1,
+		rules: []packRule{
+			{name: "probes", title: "Probes", since: 1, check: checkProbes},
+			{name: "limits", title: "Limits", since: 1, check: checkResources},
+		},
+		deprecations: []packDeprecation{
+			{rule: "limits", hint: "use baseline.resource-limits"},
+			{rule: "liveness", replacedBy: "probes", hint: "liveness is renamed probes"},
+		},
+	}
+	t.Cleanup(func() { delete(policyPacks, "legacy") })
+
+	e := &PolicyEvaluator{data: EvaluatorData{ComplianceConfig: models.ComplianceConfig{
+		PolicyPacks: map[string]models.PolicyPackConfig{"legacy": {Rules: map[string]models.PolicyPackRuleConfig{"liveness": {Disabled: true}}}},
+	}}}
+	if err := e.registerPolicyPacks(); err != nil {
+		t.Fatalf("registerPolicyPacks() error = %v", err)
+	}
+	if got := e.data.ComplianceConfig.PolicyIDs; !reflect.DeepEqual(got, []string{"legacy.limits"}) {
+		t.Errorf("PolicyIDs = %v, want the renamed rule disabled", got)
+	}
+	want := []models.PolicyPackDeprecation{
+		{Rule: "limits", Hint: "use baseline.resource-limits"},
+		{Rule: "liveness", ReplacedBy: "probes", Hint: "liveness is renamed probes"},
+	}
+	if got := e.PolicyPacks()[0].Deprecations; !reflect.DeepEqual(got, want) {
+		t.Errorf("Deprecations = %+v, want %+v", got, want)
+	}
+}
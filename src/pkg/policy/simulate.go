@@ -0,0 +1,99 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// levelAdjectives and levelVerbs phrase the simulation outcomes, e.g. "3 currently-warning policies would block"
+var (
+	levelAdjectives = map[string]string{
+		POLICY_LEVEL_BLOCK:         "blocking",
+		POLICY_LEVEL_WARNING:       "warning",
+		POLICY_LEVEL_RECOMMEND:     "recommended",
+		POLICY_LEVEL_OVERRIDE:      "overridden",
+		POLICY_LEVEL_NOT_IN_EFFECT: "not-in-effect",
+		POLICY_LEVEL_UNKNOWN:       "unenforced",
+	}
+	levelVerbs = map[string]string{
+		POLICY_LEVEL_BLOCK:         "block",
+		POLICY_LEVEL_WARNING:       "warn",
+		POLICY_LEVEL_RECOMMEND:     "only be recommended",
+		POLICY_LEVEL_OVERRIDE:      "be overridden",
+		POLICY_LEVEL_NOT_IN_EFFECT: "not be in effect",
+		POLICY_LEVEL_UNKNOWN:       "not be enforced",
+	}
+)
+
+// Simulate reports how the enforcement outcomes of an evaluation would change at the given time,
+// the manifests and the override comments being the same
+func (e *PolicyEvaluator) Simulate(eval *models.PolicyEvaluation, at time.Time, comments []*models.Comment) (*models.EnforcementSimulation, error) {
+	evaluatedAt := e.now()
+	current, _, err := e.enforcementLevelsAt(evaluatedAt, comments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine current enforcement level: %w", err)
+	}
+	simulated, _, err := e.enforcementLevelsAt(at, comments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine simulated enforcement level: %w", err)
+	}
+	failing := failingOverlaysOf(eval)
+
+	sim := &models.EnforcementSimulation{EvaluatedAt: evaluatedAt, SimulatedAt: at}
+	transitions := make(map[[2]string]int)
+	var transitionOrder [][2]string
+	for _, policyId := range e.policyIDs() {
+		if current[policyId] == simulated[policyId] {
+			continue
+		}
+		sim.Policies = append(sim.Policies, models.SimulatedPolicy{
+			PolicyId:        policyId,
+			PolicyName:      e.data.ComplianceConfig.Policies[policyId].Name,
+			CurrentLevel:    current[policyId],
+			SimulatedLevel:  simulated[policyId],
+			FailingOverlays: failing[policyId],
+		})
+		if len(failing[policyId]) == 0 {
+			continue
+		}
+		transition := [2]string{current[policyId], simulated[policyId]}
+		if transitions[transition] == 0 {
+			transitionOrder = append(transitionOrder, transition)
+		}
+		transitions[transition]++
+	}
+
+	for _, transition := range transitionOrder {
+		policies := "policies"
+		if transitions[transition] == 1 {
+			policies = "policy"
+		}
+		sim.Outcomes = append(sim.Outcomes, fmt.Sprintf("%d currently-%s %s would %s",
+			transitions[transition], levelAdjectives[transition[0]], policies, levelVerbs[transition[1]]))
+	}
+	return sim, nil
+}
+
+// failingOverlaysOf maps the policy IDs to the sorted overlay keys where they fail
+func failingOverlaysOf(eval *models.PolicyEvaluation) map[string][]string {
+	results := make(map[string][]string)
+	for overlayKey, matrix := range eval.PolicyMatrix {
+		for _, policies := range [][]models.PolicyResult{
+			matrix.BlockingPolicies, matrix.WarningPolicies, matrix.RecommendPolicies,
+			matrix.OverriddenPolicies, matrix.NotInEffectPolicies,
+		} {
+			for _, policy := range policies {
+				if !policy.IsPassing {
+					results[policy.PolicyId] = append(results[policy.PolicyId], overlayKey)
+				}
+			}
+		}
+	}
+	for policyId := range results {
+		slices.Sort(results[policyId])
+	}
+	return results
+}
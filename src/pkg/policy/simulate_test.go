@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestSimulate(t *testing.T) {
+	dir := t.TempDir()
+	config := `policies:
+  ha:
+    name: HA
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isWarningAfter: 2026-01-01T00:00:00Z
+      isBlockingAfter: 2026-03-01T00:00:00Z
+  limits:
+    name: Limits
+    type: opa
+    filePath: limits.rego
+    enforcement:
+      isWarningAfter: 2026-01-01T00:00:00Z
+      isBlockingAfter: 2026-03-01T00:00:00Z
+  labels:
+    name: Labels
+    type: opa
+    filePath: labels.rego
+    enforcement:
+      isBlockingAfter: 2026-03-01T00:00:00Z
+  probes:
+    name: Probes
+    type: opa
+    filePath: probes.rego
+    enforcement:
+      isBlockingAfter: 2025-01-01T00:00:00Z
+`
+	if err := os.WriteFile(filepath.Join(dir, COMPLIANCE_CONFIG_FILENAME), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{Clock: FixedClock(now)})
+	if err := e.loadComplianceConfig(); err != nil {
+		t.Fatalf("loadComplianceConfig() error = %v", err)
+	}
+
+	eval := &models.PolicyEvaluation{PolicyMatrix: map[string]models.PolicyMatrix{
+		"stg": {WarningPolicies: []models.PolicyResult{{PolicyId: "ha"}, {PolicyId: "limits"}}, BlockingPolicies: []models.PolicyResult{{PolicyId: "probes"}}},
+		"prod": {WarningPolicies: []models.PolicyResult{{PolicyId: "ha"}, {PolicyId: "limits", IsPassing: true}},
+			BlockingPolicies: []models.PolicyResult{{PolicyId: "probes", IsPassing: true}}},
+	}}
+	at := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	sim, err := e.Simulate(eval, at, nil)
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+
+	wantPolicies := []models.SimulatedPolicy{
+		{PolicyId: "ha", PolicyName: "HA", CurrentLevel: POLICY_LEVEL_WARNING, SimulatedLevel: POLICY_LEVEL_BLOCK, FailingOverlays: []string{"prod", "stg"}},
+		{PolicyId: "limits", PolicyName: "Limits", CurrentLevel: POLICY_LEVEL_WARNING, SimulatedLevel: POLICY_LEVEL_BLOCK, FailingOverlays: []string{"stg"}},
+		{PolicyId: "labels", PolicyName: "Labels", CurrentLevel: POLICY_LEVEL_UNKNOWN, SimulatedLevel: POLICY_LEVEL_BLOCK},
+	}
+	if !reflect.DeepEqual(sim.Policies, wantPolicies) {
+		t.Errorf("Simulate() policies = %+v, want %+v", sim.Policies, wantPolicies)
+	}
+	wantOutcomes := []string{"2 currently-warning policies would block"}
+	if !reflect.DeepEqual(sim.Outcomes, wantOutcomes) {
+		t.Errorf("Simulate() outcomes = %v, want %v", sim.Outcomes, wantOutcomes)
+	}
+	if !sim.EvaluatedAt.Equal(now) || !sim.SimulatedAt.Equal(at) {
+		t.Errorf("Simulate() times = %v, %v, want %v, %v", sim.EvaluatedAt, sim.SimulatedAt, now, at)
+	}
+}
@@ -0,0 +1,105 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	POLICY_SOURCE_TYPE_GIT = "git"
+	POLICY_SOURCE_TYPE_OCI = "oci"
+)
+
+// ruleHeadPattern matches the head of the conftest rules producing failures
+var ruleHeadPattern = regexp.MustCompile(`^(deny|violation|warn)(_[A-Za-z0-9_]+)?\b`)
+
+// validatePolicySource checks the policySource section, nil is valid
+func validatePolicySource(src *models.PolicySourceConfig) error {
+	if src == nil {
+		return nil
+	}
+	switch src.Type {
+	case "", POLICY_SOURCE_TYPE_GIT, POLICY_SOURCE_TYPE_OCI:
+	default:
+		return fmt.Errorf("policySource: unsupported type %q (must be 'git' or 'oci')", src.Type)
+	}
+	if src.URL == "" || src.Ref == "" {
+		return fmt.Errorf("policySource: url and ref are required")
+	}
+	if src.LinkTemplate == "" && permalinkFormat(src) == "" {
+		return fmt.Errorf("policySource: linkTemplate is required for %s", src.URL)
+	}
+	return nil
+}
+
+// permalinkFormat returns the permalink template of well-known git hosts, empty if unknown
+func permalinkFormat(src *models.PolicySourceConfig) string {
+	if src.Type == POLICY_SOURCE_TYPE_OCI {
+		return ""
+	}
+	u, err := url.Parse(src.URL)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case u.Host == "github.com" || strings.HasPrefix(u.Host, "github."):
+		return "{url}/blob/{ref}/{path}#L{line}"
+	case u.Host == "gitlab.com" || strings.HasPrefix(u.Host, "gitlab."):
+		return "{url}/-/blob/{ref}/{path}#L{line}"
+	case u.Host == "bitbucket.org":
+		return "{url}/src/{ref}/{path}#lines-{line}"
+	}
+	return ""
+}
+
+// policySourceLink returns the permalink to the first rule of a policy file, empty if the source is not configured
+// filePath is relative to the policies directory, fullPath is used to find the line of the rule
+func policySourceLink(src *models.PolicySourceConfig, filePath, fullPath string) string {
+	if src == nil {
+		return ""
+	}
+	format := src.LinkTemplate
+	if format == "" {
+		format = permalinkFormat(src)
+	}
+	line, err := ruleLine(fullPath)
+	if err != nil {
+		logger.WithField("filePath", filePath).WithField("error", err).Warn("Failed to find policy rule line")
+	}
+	if line == 0 {
+		line = 1 // link to the top of the file when the rule can't be located
+	}
+	return strings.NewReplacer(
+		"{url}", strings.TrimSuffix(src.URL, "/"),
+		"{ref}", src.Ref,
+		"{path}", path.Join(src.Path, filePath),
+		"{line}", strconv.Itoa(line),
+	).Replace(format)
+}
+
+// ruleLine returns the 1-based line of the first deny/violation/warn rule of a rego file, 0 if there is none
+func ruleLine(fullPath string) (int, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if ruleHeadPattern.MatchString(scanner.Text()) {
+			return line, nil
+		}
+	}
+	return 0, scanner.Err()
+}
@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestPolicySourceLink(t *testing.T) {
+	fullPath := filepath.Join(t.TempDir(), "ha.rego")
+	rego := "package main\n\nimport rego.v1\n\ndeny contains msg if {\n\tinput.kind == \"Deployment\"\n}\n"
+	if err := os.WriteFile(fullPath, []byte(rego), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		src  *models.PolicySourceConfig
+		want string
+	}{
+		{name: "no source", src: nil, want: ""},
+		{
+			name: "github",
+			src:  &models.PolicySourceConfig{URL: "https://github.com/org/policies/", Ref: "abc123", Path: "compliance"},
+			want: "https://github.com/org/policies/blob/abc123/compliance/ha.rego#L5",
+		},
+		{
+			name: "gitlab",
+			src:  &models.PolicySourceConfig{URL: "https://gitlab.example.com/org/policies", Ref: "v1.2.0"},
+			want: "https://gitlab.example.com/org/policies/-/blob/v1.2.0/ha.rego#L5",
+		},
+		{
+			name: "oci with template",
+			src: &models.PolicySourceConfig{Type: POLICY_SOURCE_TYPE_OCI, URL: "oci://ghcr.io/org/policies", Ref: "sha256:def",
+				LinkTemplate: "https://policies.example.com/{ref}/{path}?line={line}"},
+			want: "https://policies.example.com/sha256:def/ha.rego?line=5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policySourceLink(tt.src, "ha.rego", fullPath); got != tt.want {
+				t.Errorf("policySourceLink() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePolicySource(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     *models.PolicySourceConfig
+		wantErr bool
+	}{
+		{name: "unset", src: nil},
+		{name: "github", src: &models.PolicySourceConfig{URL: "https://github.com/org/policies", Ref: "main"}},
+		{name: "missing ref", src: &models.PolicySourceConfig{URL: "https://github.com/org/policies"}, wantErr: true},
+		{name: "unknown host", src: &models.PolicySourceConfig{URL: "https://git.example.com/policies", Ref: "main"}, wantErr: true},
+		{name: "oci without template", src: &models.PolicySourceConfig{Type: "oci", URL: "oci://ghcr.io/org/p", Ref: "v1"}, wantErr: true},
+		{name: "unknown type", src: &models.PolicySourceConfig{Type: "s3", URL: "s3://bucket", Ref: "v1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validatePolicySource(tt.src); (err != nil) != tt.wantErr {
+				t.Errorf("validatePolicySource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// SUGGESTION_METADATA_KEY is the field of structured deny results holding the suggested fix, a JSON patch
+// of the violating resource:
+//
+//	deny contains {"msg": msg, "resource": {...}, "patch": [{"op": "replace", "path": "/spec/replicas", "value": 2}]}
+const SUGGESTION_METADATA_KEY = "patch"
+
+var patchOps = []string{"add", "remove", "replace", "move", "copy", "test"}
+
+// suggestionOf returns the fix suggested by a conftest failure, nil if none or invalid
+// The patch is applied to the violating resource for the diff, kept without it if the resource is unknown
+func suggestionOf(metadata map[string]interface{}, resourceID string, resources []manifest.Resource) *models.Suggestion {
+	raw, ok := metadata[SUGGESTION_METADATA_KEY]
+	if !ok {
+		return nil
+	}
+	patch, err := parsePatch(raw)
+	if err != nil {
+		logger.WithField("resource", resourceID).WithField("error", err).Warn("Ignoring invalid suggested patch")
+		return nil
+	}
+
+	suggestion := &models.Suggestion{Patch: patch}
+	idx := slices.IndexFunc(resources, func(res manifest.Resource) bool { return res.ID() == resourceID })
+	if resourceID == "" || idx < 0 {
+		return suggestion
+	}
+	res := resources[idx]
+	patched, err := manifest.ApplyPatch(res.Object, patch)
+	if err != nil {
+		logger.WithField("resource", resourceID).WithField("error", err).Warn("Suggested patch does not apply to the resource")
+		return suggestion
+	}
+	before, err := manifest.Marshal([]manifest.Resource{res})
+	if err != nil {
+		return suggestion
+	}
+	after, err := manifest.Marshal([]manifest.Resource{manifest.NewResource(patched)})
+	if err != nil {
+		return suggestion
+	}
+	suggestion.Diff = diff.Hunks(before, after)
+	return suggestion
+}
+
+// parsePatch decodes and checks the operations of a suggested patch
+func parsePatch(raw interface{}) ([]models.PatchOperation, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var patch []models.PatchOperation
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, fmt.Errorf("%s must be a list of JSON patch operations: %w", SUGGESTION_METADATA_KEY, err)
+	}
+	if len(patch) == 0 {
+		return nil, fmt.Errorf("%s is empty", SUGGESTION_METADATA_KEY)
+	}
+	for i, op := range patch {
+		if !slices.Contains(patchOps, op.Op) {
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i, op.Op)
+		}
+		if _, err := manifest.ParsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return patch, nil
+}
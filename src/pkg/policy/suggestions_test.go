@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+)
+
+func TestSuggestionOf(t *testing.T) {
+	resources := []manifest.Resource{manifest.NewResource(map[string]interface{}{
+		"apiVersion": "apps/v1", "kind": "Deployment",
+		"metadata": map[string]interface{}{"name": "my-app", "namespace": "prod"},
+		"spec":     map[string]interface{}{"replicas": 1},
+	})}
+	patch := []interface{}{map[string]interface{}{"op": "replace", "path": "/spec/replicas", "value": 2.0}}
+
+	tests := []struct {
+		name       string
+		metadata   map[string]interface{}
+		resourceID string
+		wantNil    bool
+		wantDiff   string
+	}{
+		{name: "no patch", metadata: map[string]interface{}{"query": "data.main.deny"}, resourceID: "Deployment/prod/my-app", wantNil: true},
+		{
+			name:       "applied to the resource",
+			metadata:   map[string]interface{}{"patch": patch},
+			resourceID: "Deployment/prod/my-app",
+			wantDiff:   "@@ -4,4 +4,4 @@\n   name: my-app\n   namespace: prod\n spec:\n-  replicas: 1\n+  replicas: 2\n",
+		},
+		{name: "unknown resource", metadata: map[string]interface{}{"patch": patch}, resourceID: ""},
+		{name: "invalid op", metadata: map[string]interface{}{"patch": []interface{}{map[string]interface{}{"op": "merge", "path": "/spec"}}}, wantNil: true},
+		{name: "not a list", metadata: map[string]interface{}{"patch": "replicas: 2"}, wantNil: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := suggestionOf(tt.metadata, tt.resourceID, resources)
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("suggestionOf() = %+v, wantNil %v", got, tt.wantNil)
+			}
+			if got == nil {
+				return
+			}
+			if len(got.Patch) != 1 || got.Patch[0].Op != "replace" {
+				t.Errorf("suggestionOf() patch = %+v", got.Patch)
+			}
+			if got.Diff != tt.wantDiff {
+				t.Errorf("suggestionOf() diff = %q, want %q", got.Diff, tt.wantDiff)
+			}
+		})
+	}
+}
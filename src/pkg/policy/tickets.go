@@ -0,0 +1,176 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/httpclient"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	// OVERRIDE_TICKET_TOKEN is replaced by the ticket reference in overrideTickets.url
+	OVERRIDE_TICKET_TOKEN = "{ticket}"
+	// JIRA_TIMEOUT is the timeout of a Jira ticket lookup
+	JIRA_TIMEOUT = 10 * time.Second
+)
+
+// ticketCheck is the Jira lookup of a ticket, err when it is not found or the lookup failed
+type ticketCheck struct {
+	status string
+	err    error
+}
+
+// validateOverrideTickets checks the ticket settings and compiles the ticket pattern
+func (e *PolicyEvaluator) validateOverrideTickets() error {
+	cfg := e.data.ComplianceConfig.OverrideTickets
+	if cfg == nil {
+		for id, policy := range e.data.ComplianceConfig.Policies {
+			if policy.Enforcement.Override.RequireTicket {
+				return fmt.Errorf("policy %s: requireTicket needs the overrideTickets settings", id)
+			}
+		}
+		return nil
+	}
+	if cfg.Pattern == "" {
+		return fmt.Errorf("overrideTickets: pattern is required")
+	}
+	pattern, err := regexp.Compile(`^(?:` + cfg.Pattern + `)$`)
+	if err != nil {
+		return fmt.Errorf("overrideTickets: invalid pattern %q: %w", cfg.Pattern, err)
+	}
+	if cfg.URL != "" && !strings.Contains(cfg.URL, OVERRIDE_TICKET_TOKEN) {
+		return fmt.Errorf("overrideTickets: url must contain %s, got: %q", OVERRIDE_TICKET_TOKEN, cfg.URL)
+	}
+	if cfg.Jira != nil && !strings.HasPrefix(cfg.Jira.URL, "https://") && !strings.HasPrefix(cfg.Jira.URL, "http://") {
+		return fmt.Errorf("overrideTickets: jira.url must be http(s), got: %q", cfg.Jira.URL)
+	}
+	e.data.ticketPattern = pattern
+	return nil
+}
+
+// parseOverrideCommand returns the policy, action and ticket reference of an override or revocation command,
+// ok is false if the comment is not one. A ticket follows the override command, e.g. "/sp-override-ha PROJ-123"
+func (e *PolicyEvaluator) parseOverrideCommand(body string) (policyId, action, ticket string, ok bool) {
+	if policyId, ok := e.data.overrideCmdToPolicyId[body]; ok {
+		return policyId, models.OverrideActionOverride, "", true
+	}
+	if command, isRevoke := strings.CutSuffix(body, OVERRIDE_REVOKE_SUFFIX); isRevoke {
+		if policyId, ok := e.data.overrideCmdToPolicyId[command]; ok {
+			return policyId, models.OverrideActionRevoke, "", true
+		}
+	}
+	if e.data.ComplianceConfig.OverrideTickets == nil {
+		return "", "", "", false
+	}
+	command, ticket, found := strings.Cut(body, " ")
+	if policyId, ok := e.data.overrideCmdToPolicyId[command]; ok && found {
+		return policyId, models.OverrideActionOverride, strings.TrimSpace(ticket), true
+	}
+	return "", "", "", false
+}
+
+// rejectionOfTicket fills the ticket fields of an override and returns why it is rejected, empty if it is not
+func (e *PolicyEvaluator) rejectionOfTicket(record *models.OverrideAction) string {
+	cfg := e.data.ComplianceConfig.OverrideTickets
+	if record.Ticket == "" {
+		if e.data.ComplianceConfig.OverrideTicketRequired(record.PolicyId) {
+			return "a ticket is required"
+		}
+		return ""
+	}
+	if !e.data.ticketPattern.MatchString(record.Ticket) {
+		return fmt.Sprintf("ticket %q does not match %s", record.Ticket, cfg.Pattern)
+	}
+	if cfg.URL != "" {
+		record.TicketURL = strings.ReplaceAll(cfg.URL, OVERRIDE_TICKET_TOKEN, url.PathEscape(record.Ticket))
+	}
+	if cfg.Jira == nil {
+		return ""
+	}
+
+	check, ok := e.tickets[record.Ticket]
+	switch {
+	case !ok:
+		return fmt.Sprintf("ticket %s was not checked in Jira", record.Ticket)
+	case check.err != nil:
+		return fmt.Sprintf("ticket %s: %v", record.Ticket, check.err)
+	}
+	record.TicketStatus = check.status
+	approved := cfg.Jira.ApprovedStatuses
+	if len(approved) > 0 && !slices.ContainsFunc(approved, func(s string) bool { return strings.EqualFold(s, check.status) }) {
+		return fmt.Sprintf("ticket %s is %s, not %s", record.Ticket, check.status, strings.Join(approved, " or "))
+	}
+	return ""
+}
+
+// checkTickets looks up in Jira the tickets of the override commands of the comments, once per ticket.
+// No-op without overrideTickets.jira, a failed lookup rejects the override and does not fail the run
+func (e *PolicyEvaluator) checkTickets(ctx context.Context, comments []*models.Comment) {
+	cfg := e.data.ComplianceConfig.OverrideTickets
+	if cfg == nil || cfg.Jira == nil {
+		return
+	}
+	if e.tickets == nil {
+		e.tickets = make(map[string]ticketCheck)
+	}
+	client := httpclient.New(JIRA_TIMEOUT)
+	for _, comment := range comments {
+		if comment == nil {
+			continue
+		}
+		_, _, ticket, ok := e.parseOverrideCommand(comment.Body)
+		if _, checked := e.tickets[ticket]; !ok || checked || !e.data.ticketPattern.MatchString(ticket) {
+			continue
+		}
+		status, err := jiraStatusOf(ctx, client, cfg.Jira, ticket)
+		if err != nil {
+			logger.WithField("ticket", ticket).WithField("error", err).Warn("Failed to check the override ticket in Jira")
+		}
+		e.tickets[ticket] = ticketCheck{status: status, err: err}
+	}
+}
+
+// jiraStatusOf returns the status name of a Jira issue
+func jiraStatusOf(ctx context.Context, client *http.Client, cfg *models.JiraConfig, ticket string) (string, error) {
+	target := strings.TrimSuffix(cfg.URL, "/") + "/rest/api/2/issue/" + url.PathEscape(ticket) + "?fields=status"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create Jira request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, os.ExpandEnv(value))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query Jira: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", fmt.Errorf("not found in Jira")
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("Jira returned %s", resp.Status)
+	}
+
+	var issue struct {
+		Fields struct {
+			Status struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
+		return "", fmt.Errorf("failed to parse Jira issue: %w", err)
+	}
+	return issue.Fields.Status.Name, nil
+}
@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestOverrideTickets(t *testing.T) {
+	statuses := map[string]string{"PROJ-1": "Approved", "PROJ-2": "Open"}
+	jira := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		status, ok := statuses[r.URL.Path[len("/rest/api/2/issue/"):]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"fields": {"status": {"name": "` + status + `"}}}`))
+	}))
+	defer jira.Close()
+	t.Setenv("JIRA_TOKEN", "s3cret")
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{Clock: FixedClock(since)})
+	e.data.ComplianceConfig.Policies = map[string]models.PolicyConfig{
+		"ha": {Name: "HA", Type: POLICY_TYPE_OPA, FilePath: "ha.rego", Enforcement: models.EnforcementConfig{
+			IsBlockingAfter: &since, Override: models.OverrideConfig{Comment: "/sp-override-ha", RequireTicket: true},
+		}},
+	}
+	e.data.ComplianceConfig.OverrideTickets = &models.OverrideTicketConfig{
+		Pattern: "[A-Z]+-[0-9]+",
+		URL:     "https://acme.atlassian.net/browse/{ticket}",
+		Jira: &models.JiraConfig{
+			URL:              jira.URL,
+			Headers:          map[string]string{"Authorization": "Bearer $JIRA_TOKEN"},
+			ApprovedStatuses: []string{"approved", "done"},
+		},
+	}
+	if err := e.validateOverrideTickets(); err != nil {
+		t.Fatalf("validateOverrideTickets() error = %v", err)
+	}
+	e.data.overrideCmdToPolicyId["/sp-override-ha"] = "ha"
+
+	tests := []struct {
+		body       string
+		want       string
+		wantAction string
+		wantReason string
+	}{
+		{body: "/sp-override-ha PROJ-1", want: POLICY_LEVEL_OVERRIDE, wantAction: models.OverrideActionOverride},
+		{body: "/sp-override-ha", want: POLICY_LEVEL_BLOCK, wantAction: models.OverrideActionRejected, wantReason: "a ticket is required"},
+		{body: "/sp-override-ha proj-1", want: POLICY_LEVEL_BLOCK, wantAction: models.OverrideActionRejected,
+			wantReason: `ticket "proj-1" does not match [A-Z]+-[0-9]+`},
+		{body: "/sp-override-ha PROJ-2", want: POLICY_LEVEL_BLOCK, wantAction: models.OverrideActionRejected,
+			wantReason: "ticket PROJ-2 is Open, not approved or done"},
+		{body: "/sp-override-ha PROJ-3", want: POLICY_LEVEL_BLOCK, wantAction: models.OverrideActionRejected,
+			wantReason: "ticket PROJ-3: not found in Jira"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			comments := []*models.Comment{{Body: tt.body, User: "alice", CreatedAt: since}}
+			e.checkTickets(context.Background(), comments)
+			levels, err := e.DetermineEnforcementLevel(comments)
+			if err != nil {
+				t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+			}
+			if levels["ha"] != tt.want {
+				t.Errorf("DetermineEnforcementLevel() = %q, want %q", levels["ha"], tt.want)
+			}
+			_, actions := e.overridesOf(comments)
+			if len(actions) != 1 || actions[0].Action != tt.wantAction || actions[0].Reason != tt.wantReason {
+				t.Fatalf("overridesOf() = %+v, want %s (%q)", actions, tt.wantAction, tt.wantReason)
+			}
+			if tt.wantAction == models.OverrideActionOverride &&
+				(actions[0].TicketURL != "https://acme.atlassian.net/browse/PROJ-1" || actions[0].TicketStatus != "Approved") {
+				t.Errorf("overridesOf() ticket = %+v", actions[0])
+			}
+		})
+	}
+
+	if !e.IsOverrideCommand("/sp-override-ha PROJ-1") || e.IsOverrideCommand("/sp-override-hax PROJ-1") {
+		t.Errorf("IsOverrideCommand() does not recognize the ticket commands")
+	}
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+)
+
+// resolveViolationResource identifies the resource a conftest failure was raised for
+//
+// Policies can return structured results to be explicit:
+//
+//	deny contains {"msg": msg, "resource": {"kind": "Deployment", "namespace": ns, "name": name}}
+//
+// otherwise the resource whose kind and name both appear in the message is picked (longest name wins)
+func resolveViolationResource(metadata map[string]interface{}, msg string, resources []manifest.Resource) string {
+	if ref, ok := metadata["resource"].(map[string]interface{}); ok {
+		kind, _ := ref["kind"].(string)
+		name, _ := ref["name"].(string)
+		namespace, _ := ref["namespace"].(string)
+		if kind != "" && name != "" {
+			return manifest.Resource{Kind: kind, Name: name, Namespace: namespace}.ID()
+		}
+	}
+
+	matched := ""
+	matchedNameLen := 0
+	for _, res := range resources {
+		if res.Name == "" || len(res.Name) <= matchedNameLen {
+			continue
+		}
+		if !containsWord(msg, res.Kind) || !containsWord(msg, res.Name) {
+			continue
+		}
+		matched = res.ID()
+		matchedNameLen = len(res.Name)
+	}
+	return matched
+}
+
+// containsWord reports whether word appears in s, not as part of a longer kubernetes name
+func containsWord(s, word string) bool {
+	if word == "" {
+		return false
+	}
+	for offset := 0; offset+len(word) <= len(s); {
+		i := strings.Index(s[offset:], word)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(word)
+		if (start == 0 || !isNameByte(s[start-1], true)) && (end == len(s) || !isNameByte(s[end], false)) {
+			return true
+		}
+		offset = start + 1
+	}
+	return false
+}
+
+// isNameByte reports whether c extends a kubernetes name around a match, dots only count before it
+func isNameByte(c byte, before bool) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+		return true
+	case c == '.':
+		return before
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/manifest"
+)
+
+func TestResolveViolationResource(t *testing.T) {
+	resources := []manifest.Resource{
+		{Kind: "Deployment", Name: "web", Namespace: "app"},
+		{Kind: "Deployment", Name: "web-api", Namespace: "app"},
+		{Kind: "Service", Name: "web", Namespace: "app"},
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		msg      string
+		want     string
+	}{
+		{
+			name: "structured result",
+			metadata: map[string]interface{}{
+				"resource": map[string]interface{}{"kind": "Ingress", "namespace": "app", "name": "public"},
+			},
+			msg:  "whatever",
+			want: "Ingress/app/public",
+		},
+		{
+			name: "kind and name in message",
+			msg:  "Deployment 'web' must have at least 2 replicas for high availability, found: 1",
+			want: "Deployment/app/web",
+		},
+		{
+			name: "longest name wins",
+			msg:  "Deployment 'web-api' must have PodAntiAffinity",
+			want: "Deployment/app/web-api",
+		},
+		{
+			name: "no match",
+			msg:  "Ingress must use TLS",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveViolationResource(tt.metadata, tt.msg, resources); got != tt.want {
+				t.Errorf("resolveViolationResource() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
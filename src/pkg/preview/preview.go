@@ -0,0 +1,179 @@
+// Package preview renders the comment templates with a stored report or sample data, so that template authors
+// can iterate on the comment design against real data without pushing commits
+package preview
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/report"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "preview")
+
+const (
+	// ENV_PREVIEW_TOKEN is the bearer token of the preview endpoint
+	ENV_PREVIEW_TOKEN = "GITOPS_KUSTOMZCHK_PREVIEW_TOKEN"
+	// PREVIEW_PATH is the path of the preview endpoint
+	PREVIEW_PATH = "/preview"
+	// REPORT_FILE_NAME is the report file of a run in its output dir
+	REPORT_FILE_NAME = "report.json"
+)
+
+// ErrNotFound is returned by a Store for a pull request without stored report
+var ErrNotFound = errors.New("no stored report")
+
+// repoPattern matches the owner/name of a repository
+var repoPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+//go:embed sample_report.json
+var sampleReport []byte
+
+// SampleReport returns the report of the sample service, for templates previewed without a stored report
+func SampleReport() (*models.ReportData, error) {
+	return report.Parse(sampleReport)
+}
+
+// Store finds the reports stored by past runs
+type Store interface {
+	// Latest returns the latest report of a pull request, ErrNotFound if there is none
+	Latest(repo string, pr int) (*models.ReportData, error)
+}
+
+// DirStore reads the reports of a directory of run output dirs, laid out as <Dir>/<owner>/<repo>/<pr>/report.json,
+// or <Dir>/<owner>/<repo>/<pr>/<run>/report.json for several runs of a PR (the latest timestamp wins)
+type DirStore struct {
+	Dir string
+}
+
+func (s *DirStore) Latest(repo string, pr int) (*models.ReportData, error) {
+	if !repoPattern.MatchString(repo) || strings.Contains(repo, "..") || pr <= 0 {
+		return nil, fmt.Errorf("invalid pull request %s#%d", repo, pr)
+	}
+	prDir := filepath.Join(s.Dir, filepath.FromSlash(repo), strconv.Itoa(pr))
+	paths, err := filepath.Glob(filepath.Join(prDir, "*", REPORT_FILE_NAME))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(prDir, REPORT_FILE_NAME)); err == nil {
+		paths = append(paths, filepath.Join(prDir, REPORT_FILE_NAME))
+	}
+
+	var latest *models.ReportData
+	for _, path := range paths {
+		data, err := report.Load(path)
+		if err != nil {
+			logger.WithField("path", path).WithField("error", err).Warn("Skipping unreadable report")
+			continue
+		}
+		if latest == nil || data.Timestamp.After(latest.Timestamp) {
+			latest = data
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%w for %s#%d", ErrNotFound, repo, pr)
+	}
+	return latest, nil
+}
+
+// Handler serves the comment rendered by the templates of TemplatesPath, read on every request:
+//
+//	GET /preview?repo=<owner/repo>&pr=<number>  with the latest stored report of the PR
+//	GET /preview?sample=true                    with SampleReport
+//
+// Requests must carry "Authorization: Bearer <Token>", an empty Token rejects them all
+type Handler struct {
+	Renderer      *template.Renderer
+	TemplatesPath string
+	// Templates returns the templates directory of a request instead of TemplatesPath, and releases it once
+	// rendered, e.g. the current snapshot of a reloaded source. nil uses TemplatesPath
+	Templates func() (string, func())
+	Store     Store // nil serves the sample data only
+	Token     string
+	// Redact returns the report as published in the comments, e.g. without manifest content with
+	// --no-manifest-content-in-comment. nil renders the report as is
+	Redact func(*models.ReportData) *models.ReportData
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gitops-kustomzchk"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	data, status, err := h.reportOf(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if h.Redact != nil {
+		data = h.Redact(data)
+	}
+	templatesPath := h.TemplatesPath
+	if h.Templates != nil {
+		var release func()
+		templatesPath, release = h.Templates()
+		defer release()
+	}
+	rendered, err := h.Renderer.RenderWithTemplates(templatesPath, template.NewTemplateData(data))
+	if err != nil {
+		// the template error is what the author is after
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(rendered))
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && h.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) == 1
+}
+
+// reportOf returns the report of the request parameters, or the status code of the error
+func (h *Handler) reportOf(r *http.Request) (*models.ReportData, int, error) {
+	query := r.URL.Query()
+	if sample, _ := strconv.ParseBool(query.Get("sample")); sample {
+		data, err := SampleReport()
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return data, http.StatusOK, nil
+	}
+
+	repo := query.Get("repo")
+	pr, err := strconv.Atoi(query.Get("pr"))
+	if repo == "" || err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("repo and pr are required, or sample=true")
+	}
+	if h.Store == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("no report store, only sample=true is available")
+	}
+	data, err := h.Store.Latest(repo, pr)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return nil, http.StatusNotFound, err
+	case err != nil:
+		logger.WithField("repo", repo).WithField("pr", pr).WithField("error", err).Warn("Failed to load the stored report")
+		return nil, http.StatusBadRequest, err
+	}
+	return data, http.StatusOK, nil
+}
@@ -0,0 +1,116 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeReport(t *testing.T, path, headCommit string, timestamp time.Time) {
+	t.Helper()
+	content, err := json.Marshal(&models.ReportData{
+		SchemaVersion: models.REPORT_SCHEMA_VERSION, Service: "my-app", HeadCommit: headCommit, Timestamp: timestamp,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, path, string(content))
+}
+
+func TestHandler(t *testing.T) {
+	templates := t.TempDir()
+	writeFile(t, filepath.Join(templates, template.FileNameCommentTemplate), "{{ .Service }}@{{ .HeadCommit }}")
+	writeFile(t, filepath.Join(templates, template.FileNameDiffTemplate), "")
+	writeFile(t, filepath.Join(templates, template.FileNamePolicyTemplate), "")
+
+	reports := t.TempDir()
+	now := time.Now()
+	writeReport(t, filepath.Join(reports, "org/repo/12/run-1/report.json"), "old", now.Add(-time.Hour))
+	writeReport(t, filepath.Join(reports, "org/repo/12/run-2/report.json"), "new", now)
+	writeReport(t, filepath.Join(reports, "org/repo/13/report.json"), "only", now)
+
+	handler := &Handler{Renderer: template.NewRenderer(), TemplatesPath: templates, Store: &DirStore{Dir: reports}, Token: "s3cret"}
+	tests := []struct {
+		name       string
+		target     string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"latest run of a PR", "/preview?repo=org/repo&pr=12", "s3cret", http.StatusOK, "my-app@new"},
+		{"single run of a PR", "/preview?repo=org/repo&pr=13", "s3cret", http.StatusOK, "my-app@only"},
+		{"sample data", "/preview?sample=true", "s3cret", http.StatusOK, "my-app@head"},
+		{"missing token", "/preview?sample=true", "", http.StatusUnauthorized, "unauthorized"},
+		{"wrong token", "/preview?sample=true", "guess", http.StatusUnauthorized, "unauthorized"},
+		{"PR without report", "/preview?repo=org/repo&pr=14", "s3cret", http.StatusNotFound, "no stored report"},
+		{"path traversal", "/preview?repo=../etc&pr=1", "s3cret", http.StatusBadRequest, "invalid pull request"},
+		{"missing parameters", "/preview", "s3cret", http.StatusBadRequest, "required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus || !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s = %d %q, want %d containing %q", tt.target, rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+
+	// the report is rendered as the comments publish it
+	redacting := *handler
+	redacting.Redact = func(data *models.ReportData) *models.ReportData {
+		redacted := *data
+		redacted.HeadCommit = "redacted"
+		return &redacted
+	}
+	req := httptest.NewRequest(http.MethodGet, "/preview?repo=org/repo&pr=13", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	redacting.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "my-app@redacted" {
+		t.Errorf("expected the redacted report, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// a broken template is reported to its author
+	writeFile(t, filepath.Join(templates, template.FileNameCommentTemplate), "{{ .Service ")
+	req = httptest.NewRequest(http.MethodGet, "/preview?sample=true", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "failed to parse comment template") {
+		t.Errorf("expected the template error, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandler_EmptyToken(t *testing.T) {
+	handler := &Handler{Renderer: template.NewRenderer()}
+	req := httptest.NewRequest(http.MethodGet, "/preview?sample=true", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected an empty token to reject every request, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"sort"
+	"strconv"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	STATUS_PASS  = "PASS"
+	STATUS_FAIL  = "FAIL"
+	STATUS_ERROR = "ERROR"
+)
+
+// POLICY_CSV_HEADER are the columns of the policy matrix CSV
+var POLICY_CSV_HEADER = []string{"service", "environment", "policyId", "level", "status", "messagesCount"}
+
+// matrixLevels are the enforcement levels of the policy matrix, in report order, named like the compliance config
+var matrixLevels = []struct {
+	name     string
+	policies func(models.PolicyMatrix) []models.PolicyResult
+}{
+	{"BLOCK", func(m models.PolicyMatrix) []models.PolicyResult { return m.BlockingPolicies }},
+	{"WARNING", func(m models.PolicyMatrix) []models.PolicyResult { return m.WarningPolicies }},
+	{"RECOMMEND", func(m models.PolicyMatrix) []models.PolicyResult { return m.RecommendPolicies }},
+	{"OVERRIDE", func(m models.PolicyMatrix) []models.PolicyResult { return m.OverriddenPolicies }},
+	{"NOT_IN_EFFECT", func(m models.PolicyMatrix) []models.PolicyResult { return m.NotInEffectPolicies }},
+}
+
+// PolicyMatrixCSV flattens the policy matrix of a report to one CSV row per environment and policy,
+// environments in report order (sorted if unknown) and policies by enforcement level
+func PolicyMatrixCSV(data *models.ReportData) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(POLICY_CSV_HEADER); err != nil {
+		return nil, err
+	}
+	for _, env := range matrixEnvironmentsOf(data) {
+		matrix := data.PolicyEvaluation.PolicyMatrix[env]
+		for _, level := range matrixLevels {
+			for _, policy := range level.policies(matrix) {
+				row := []string{data.Service, env, policy.PolicyId, level.name, policyStatusOf(policy), strconv.Itoa(len(policy.FailMessages))}
+				if err := w.Write(row); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// policyStatusOf returns the STATUS_* of a policy result, an evaluation error counts as an error, not a failure
+func policyStatusOf(policy models.PolicyResult) string {
+	switch {
+	case policy.IsPassing:
+		return STATUS_PASS
+	case policy.EvalError != "":
+		return STATUS_ERROR
+	default:
+		return STATUS_FAIL
+	}
+}
+
+// matrixEnvironmentsOf returns the overlay keys of the policy matrix, in report order (sorted if unknown)
+func matrixEnvironmentsOf(data *models.ReportData) []string {
+	var keys []string
+	for _, key := range data.OverlayKeys {
+		if _, ok := data.PolicyEvaluation.PolicyMatrix[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		return keys
+	}
+	for key := range data.PolicyEvaluation.PolicyMatrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,34 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestPolicyMatrixCSV(t *testing.T) {
+	data := &models.ReportData{
+		Service:     "my-app",
+		OverlayKeys: []string{"stg", "prod"},
+		PolicyEvaluation: models.PolicyEvaluation{PolicyMatrix: map[string]models.PolicyMatrix{
+			"prod": {
+				BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", FailMessages: []string{"a, b", "c"}}},
+				WarningPolicies:  []models.PolicyResult{{PolicyId: "limits", IsPassing: true}},
+			},
+			"stg": {
+				RecommendPolicies: []models.PolicyResult{{PolicyId: "ha", EvalError: "boom", FailMessages: []string{"boom"}}},
+			},
+		}},
+	}
+	got, err := PolicyMatrixCSV(data)
+	if err != nil {
+		t.Fatalf("PolicyMatrixCSV() error = %v", err)
+	}
+	want := "service,environment,policyId,level,status,messagesCount\n" +
+		"my-app,stg,ha,RECOMMEND,ERROR,1\n" +
+		"my-app,prod,ha,BLOCK,FAIL,2\n" +
+		"my-app,prod,limits,WARNING,PASS,0\n"
+	if string(got) != want {
+		t.Errorf("PolicyMatrixCSV() = %q, want %q", got, want)
+	}
+}
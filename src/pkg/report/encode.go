@@ -0,0 +1,76 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FORMAT_JSON = "json"
+	FORMAT_YAML = "yaml"
+)
+
+// FORMATS are the supported report formats, each written to report.<format>
+var FORMATS = []string{FORMAT_JSON, FORMAT_YAML}
+
+// YAML11_BOOLS are the plain scalars YAML 1.1 resolves as booleans, on top of true and false
+var YAML11_BOOLS = []string{"y", "yes", "n", "no", "on", "off"}
+
+// Encode encodes a report in a format. JSON is compact unless pretty, YAML is always block style,
+// with the fields in the order and under the names of the JSON report
+func Encode(data *models.ReportData, format string, pretty bool) ([]byte, error) {
+	switch format {
+	case FORMAT_JSON:
+		if pretty {
+			return json.MarshalIndent(data, "", "  ")
+		}
+		return json.Marshal(data)
+	case FORMAT_YAML:
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return jsonToYAML(encoded)
+	default:
+		return nil, fmt.Errorf("unsupported report format %q, must be one of %v", format, FORMATS)
+	}
+}
+
+// jsonToYAML converts a JSON document to block style YAML, keeping the key order. JSON is valid
+// (flow style) YAML, so the document is decoded as a node tree and re-encoded without its styles
+func jsonToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to convert report to yaml: %w", err)
+	}
+	clearStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, fmt.Errorf("failed to convert report to yaml: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to convert report to yaml: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// clearStyle resets the styles of a node tree, letting the encoder pick block style and quote only when needed.
+// Strings that YAML 1.1 parsers read as booleans (e.g. "yes", "off") stay quoted
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && slices.Contains(YAML11_BOOLS, strings.ToLower(node.Value)) {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
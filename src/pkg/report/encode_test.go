@@ -0,0 +1,43 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestEncode(t *testing.T) {
+	data := &models.ReportData{SchemaVersion: 2, Service: "my-app", Environments: []string{"stg"}, BaseCommit: "yes"}
+	tests := []struct {
+		format  string
+		pretty  bool
+		want    string
+		wantErr bool
+	}{
+		{format: FORMAT_JSON, want: `{"schemaVersion":2,"service":"my-app","timestamp":"0001-01-01T00:00:00Z","baseCommit":"yes","headCommit":"","environments":["stg"],"manifestChanges":null,"policyEvaluation":{"environmentSummary":null,"policyMatrix":null}}`},
+		{format: FORMAT_YAML, want: `schemaVersion: 2
+service: my-app
+timestamp: "0001-01-01T00:00:00Z"
+baseCommit: "yes"
+headCommit: ""
+environments:
+  - stg
+manifestChanges: null
+policyEvaluation:
+  environmentSummary: null
+  policyMatrix: null
+`},
+		{format: "toml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := Encode(data, tt.format, tt.pretty)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Encode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Encode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package report
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/encrypt"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+)
+
+const (
+	// INDEX_FILE_NAME is the index of the files of the output directory
+	INDEX_FILE_NAME = "index.json"
+	// MANIFESTS_DIR is the directory of the exported manifests, as manifests/<overlay key>/<side>.yaml[.gz]
+	MANIFESTS_DIR = "manifests"
+)
+
+// manifestPathUnsafe matches the characters replaced in the overlay key segments of a manifest path
+var manifestPathUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// ManifestPath returns the path, relative to the output directory, of the exported manifest of an overlay side
+func ManifestPath(overlayKey, side string, gzipped bool) string {
+	segments := []string{MANIFESTS_DIR}
+	for _, segment := range strings.Split(overlayKey, "/") {
+		segment = manifestPathUnsafe.ReplaceAllString(segment, "_")
+		if segment == "" || segment == "." || segment == ".." {
+			segment = "_"
+		}
+		segments = append(segments, segment)
+	}
+	name := side + ".yaml"
+	if gzipped {
+		name += ".gz"
+	}
+	return path.Join(append(segments, name)...)
+}
+
+// WriteManifest writes a built manifest to its ManifestPath in the output directory, gzipped or not
+func WriteManifest(outputDir, overlayKey, side string, manifest []byte, gzipped bool) (models.OutputFile, error) {
+	file := models.OutputFile{
+		Path: ManifestPath(overlayKey, side, gzipped), Kind: models.OutputKindManifest,
+		OverlayKey: overlayKey, Side: side, Gzip: gzipped,
+	}
+	content := manifest
+	if gzipped {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(manifest); err != nil {
+			return file, fmt.Errorf("failed to compress manifest: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return file, fmt.Errorf("failed to compress manifest: %w", err)
+		}
+		content = buf.Bytes()
+	}
+
+	filePath := filepath.Join(outputDir, filepath.FromSlash(file.Path))
+	if err := perm.MkdirAll(filepath.Dir(filePath)); err != nil {
+		return file, fmt.Errorf("failed to create manifests directory: %w", err)
+	}
+	if err := perm.WriteFile(filePath, content); err != nil {
+		return file, fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return file, nil
+}
+
+// OutputKindOf returns the kind of an output file from its path, encrypted or not
+func OutputKindOf(relPath string) string {
+	relPath = encrypt.TrimExtension(relPath)
+	name := path.Base(relPath)
+	switch {
+	case strings.HasPrefix(relPath, MANIFESTS_DIR+"/"):
+		return models.OutputKindManifest
+	case name == "report.md":
+		return models.OutputKindMarkdown
+	case name == "report.html":
+		return models.OutputKindHTML
+	case strings.TrimSuffix(name, path.Ext(name)) == "report":
+		return models.OutputKindReport
+	case name == "policies.csv" || name == JUNIT_FILE_NAME:
+		return models.OutputKindPolicyMatrix
+	case strings.TrimSuffix(name, path.Ext(name)) == "inventory":
+		return models.OutputKindInventory
+	case strings.HasPrefix(name, "diff-") && path.Ext(name) == ".txt":
+		return models.OutputKindDiff
+	}
+	return models.OutputKindOther
+}
+
+// Index lists the files of the output directory with their size and digest. Known files (e.g. the manifests
+// written by WriteManifest) keep their description, the others get the kind of their name
+func Index(outputDir string, data *models.ReportData, known []models.OutputFile) (*models.OutputIndex, error) {
+	byPath := map[string]models.OutputFile{}
+	for _, file := range known {
+		byPath[file.Path] = file
+	}
+
+	index := &models.OutputIndex{LayoutVersion: models.OUTPUT_LAYOUT_VERSION, Files: []models.OutputFile{}}
+	if data != nil {
+		index.Service, index.BaseCommit, index.HeadCommit, index.Timestamp = data.Service, data.BaseCommit, data.HeadCommit, data.Timestamp
+	}
+	err := filepath.WalkDir(outputDir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(outputDir, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == INDEX_FILE_NAME {
+			return nil
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		file, ok := byPath[rel]
+		if !ok {
+			file = models.OutputFile{Path: rel, Kind: OutputKindOf(rel), Gzip: path.Ext(encrypt.TrimExtension(rel)) == ".gz"}
+		}
+		file.Encrypted = encrypt.ToolOf(rel)
+		sum := sha256.Sum256(content)
+		file.Size, file.SHA256 = int64(len(content)), hex.EncodeToString(sum[:])
+		index.Files = append(index.Files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to index output directory: %w", err)
+	}
+	return index, nil
+}
+
+// WriteIndex writes the Index of the output directory to its index.json, returning the path written
+func WriteIndex(outputDir string, data *models.ReportData, known []models.OutputFile) (string, error) {
+	index, err := Index(outputDir, data, known)
+	if err != nil {
+		return "", err
+	}
+	content, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode output index: %w", err)
+	}
+	filePath := filepath.Join(outputDir, INDEX_FILE_NAME)
+	if err := perm.WriteFile(filePath, content); err != nil {
+		return "", fmt.Errorf("failed to write output index: %w", err)
+	}
+	return filePath, nil
+}
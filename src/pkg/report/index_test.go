@@ -0,0 +1,96 @@
+package report
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestManifestPath(t *testing.T) {
+	tests := []struct {
+		overlayKey string
+		side       string
+		gzipped    bool
+		want       string
+	}{
+		{"stg", models.ManifestSideBefore, false, "manifests/stg/before.yaml"},
+		{"my-app/alpha/prod", models.ManifestSideAfter, true, "manifests/my-app/alpha/prod/after.yaml.gz"},
+		{"../etc/a b", models.ManifestSideAfter, false, "manifests/_/etc/a_b/after.yaml"},
+	}
+	for _, tt := range tests {
+		if got := ManifestPath(tt.overlayKey, tt.side, tt.gzipped); got != tt.want {
+			t.Errorf("ManifestPath(%q, %q, %v) = %q, want %q", tt.overlayKey, tt.side, tt.gzipped, got, tt.want)
+		}
+	}
+}
+
+func TestWriteIndex(t *testing.T) {
+	dir := t.TempDir()
+	manifest, err := WriteManifest(dir, "alpha/stg", models.ManifestSideAfter, []byte("kind: Deployment\n"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"report.json": "{}", "policies.csv": "", "junit.xml": "", "report.html": "", "diff-pr1-stg-app.txt": "+a"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, "manifests/alpha/stg/after.yaml.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := io.ReadAll(zr); string(content) != "kind: Deployment\n" {
+		t.Errorf("gzipped manifest = %q", content)
+	}
+
+	// a second write indexes the files, without the index itself
+	data := &models.ReportData{Service: "app", HeadCommit: "head"}
+	for range 2 {
+		if _, err := WriteIndex(dir, data, []models.OutputFile{manifest}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index, err := Index(dir, data, []models.OutputFile{manifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index.LayoutVersion != models.OUTPUT_LAYOUT_VERSION || index.Service != "app" || index.HeadCommit != "head" {
+		t.Errorf("unexpected index header: %+v", index)
+	}
+	kinds := map[string]models.OutputFile{}
+	for _, file := range index.Files {
+		kinds[file.Path] = file
+		if len(file.SHA256) != 64 {
+			t.Errorf("%s has digest %q", file.Path, file.SHA256)
+		}
+	}
+	want := map[string]string{
+		"report.json":                       models.OutputKindReport,
+		"policies.csv":                      models.OutputKindPolicyMatrix,
+		"junit.xml":                         models.OutputKindPolicyMatrix,
+		"report.html":                       models.OutputKindHTML,
+		"diff-pr1-stg-app.txt":              models.OutputKindDiff,
+		"manifests/alpha/stg/after.yaml.gz": models.OutputKindManifest,
+	}
+	if len(kinds) != len(want) {
+		t.Errorf("indexed %d files, want %d: %+v", len(kinds), len(want), index.Files)
+	}
+	for path, kind := range want {
+		if kinds[path].Kind != kind {
+			t.Errorf("%s has kind %q, want %q", path, kinds[path].Kind, kind)
+		}
+	}
+	if got := kinds["manifests/alpha/stg/after.yaml.gz"]; got.OverlayKey != "alpha/stg" || got.Side != models.ManifestSideAfter || !got.Gzip {
+		t.Errorf("manifest description lost: %+v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// JUNIT_FILE_NAME is the JUnit XML report of the policy matrix in the output directory
+const JUNIT_FILE_NAME = "junit.xml"
+
+// JUNIT_SUITES_NAME names the test suites of the JUnit report
+const JUNIT_SUITES_NAME = "gitops-kustomzchk"
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	Skipped   *junitProblem `xml:"skipped,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// PolicyMatrixJUnit converts the policy matrix of a report to a JUnit XML report: a test suite per environment (in
+// report order, sorted if unknown) and a test case per policy. A failing policy is a failure typed with its level,
+// or skipped when overridden or not in effect yet; a policy failing to evaluate is an error
+func PolicyMatrixJUnit(data *models.ReportData) ([]byte, error) {
+	suites := junitTestSuites{Name: JUNIT_SUITES_NAME, Suites: []junitTestSuite{}}
+	for _, env := range matrixEnvironmentsOf(data) {
+		suite := junitTestSuite{Name: env, Cases: []junitTestCase{}}
+		if data.Service != "" {
+			suite.Name = data.Service + "/" + env
+		}
+		if !data.Timestamp.IsZero() {
+			suite.Timestamp = data.Timestamp.UTC().Format("2006-01-02T15:04:05")
+		}
+		matrix := data.PolicyEvaluation.PolicyMatrix[env]
+		for _, level := range matrixLevels {
+			for _, policy := range level.policies(matrix) {
+				testCase := junitTestCase{Name: policy.PolicyId, ClassName: suite.Name}
+				if data.Timings != nil {
+					testCase.Time = junitSeconds(data.Timings.PolicyEvalMs[env][policy.PolicyId])
+				}
+				if policy.PolicyName != "" {
+					testCase.Name = policy.PolicyId + ": " + policy.PolicyName
+				}
+				switch {
+				case policyStatusOf(policy) == STATUS_ERROR:
+					testCase.Error = &junitProblem{Message: "policy evaluation failed", Text: policy.EvalError}
+					suite.Errors++
+				case policy.IsPassing:
+				case level.name == "OVERRIDE" || level.name == "NOT_IN_EFFECT":
+					testCase.Skipped = &junitProblem{Message: junitSkipMessage(level.name, policy), Text: strings.Join(policy.FailMessages, "\n")}
+					suite.Skipped++
+				default:
+					testCase.Failure = &junitProblem{
+						Message: fmt.Sprintf("%d violation(s)", len(policy.FailMessages)),
+						Type:    level.name,
+						Text:    strings.Join(policy.FailMessages, "\n"),
+					}
+					suite.Failures++
+				}
+				suite.Time = junitSeconds(suite.Time*1000 + testCase.Time*1000)
+				suite.Cases = append(suite.Cases, testCase)
+			}
+		}
+		suite.Tests = len(suite.Cases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// junitSeconds converts milliseconds to the seconds of the time attributes, rounded to the microsecond
+func junitSeconds(ms float64) float64 {
+	return math.Round(ms*1000) / 1e6
+}
+
+// junitSkipMessage tells why a failing policy does not fail its test case
+func junitSkipMessage(level string, policy models.PolicyResult) string {
+	if level == "NOT_IN_EFFECT" {
+		return "failing, not in effect yet"
+	}
+	if policy.OverriddenBy != "" {
+		return "failing, overridden by @" + policy.OverriddenBy
+	}
+	return "failing, overridden"
+}
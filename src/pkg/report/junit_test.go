@@ -0,0 +1,50 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestPolicyMatrixJUnit(t *testing.T) {
+	data := &models.ReportData{
+		Service:     "my-app",
+		OverlayKeys: []string{"stg", "prod"},
+		Timings:     &models.Timings{PolicyEvalMs: map[string]map[string]float64{"prod": {"ha": 1500}}},
+		PolicyEvaluation: models.PolicyEvaluation{PolicyMatrix: map[string]models.PolicyMatrix{
+			"prod": {
+				BlockingPolicies:   []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", FailMessages: []string{"a & b", "c"}}},
+				WarningPolicies:    []models.PolicyResult{{PolicyId: "limits", IsPassing: true}},
+				OverriddenPolicies: []models.PolicyResult{{PolicyId: "tags", OverriddenBy: "alice", FailMessages: []string{"d"}}},
+			},
+			"stg": {
+				RecommendPolicies: []models.PolicyResult{{PolicyId: "ha", EvalError: "boom", FailMessages: []string{"boom"}}},
+			},
+		}},
+	}
+	got, err := PolicyMatrixJUnit(data)
+	if err != nil {
+		t.Fatalf("PolicyMatrixJUnit() error = %v", err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="gitops-kustomzchk" tests="4" failures="1" errors="1" skipped="1">
+  <testsuite name="my-app/stg" tests="1" failures="0" errors="1" skipped="0" time="0">
+    <testcase name="ha" classname="my-app/stg" time="0">
+      <error message="policy evaluation failed">boom</error>
+    </testcase>
+  </testsuite>
+  <testsuite name="my-app/prod" tests="3" failures="1" errors="0" skipped="1" time="1.5">
+    <testcase name="ha: HA" classname="my-app/prod" time="1.5">
+      <failure message="2 violation(s)" type="BLOCK">a &amp; b&#xA;c</failure>
+    </testcase>
+    <testcase name="limits" classname="my-app/prod" time="0"></testcase>
+    <testcase name="tags" classname="my-app/prod" time="0">
+      <skipped message="failing, overridden by @alice">d</skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if string(got) != want {
+		t.Errorf("PolicyMatrixJUnit() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+const (
+	// METRICS_PREFIX prefixes the names of the exported metrics
+	METRICS_PREFIX = "gitops_kustomzchk_"
+	// REPLACE_RETRIES is the number of retries of a metrics file replacement blocked by a reader (windows only)
+	REPLACE_RETRIES = 5
+)
+
+// labelValueEscaper escapes label values like the text exposition format expects
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsFileNameUnsafe matches the characters replaced in the service part of the metrics file name
+var metricsFileNameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// metricsWriter appends metric families in the Prometheus text exposition format
+type metricsWriter struct {
+	buf strings.Builder
+}
+
+// family starts a metric family, all exported metrics are gauges of the last run
+func (w *metricsWriter) family(name, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s%s %s\n# TYPE %s%s gauge\n", METRICS_PREFIX, name, help, METRICS_PREFIX, name)
+}
+
+// sample appends a sample of the current family, labels are name/value pairs
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelValueEscaper.Replace(labels[i+1])))
+	}
+	fmt.Fprintf(&w.buf, "%s%s{%s} %g\n", METRICS_PREFIX, name, strings.Join(pairs, ","), value)
+}
+
+// Metrics renders the metrics of a run in the Prometheus text exposition format read by the
+// node_exporter textfile collector: policy counts by environment, level and status, diff sizes,
+// errors, and the run timestamp
+func Metrics(data *models.ReportData) []byte {
+	w := &metricsWriter{}
+	service := data.Service
+	envs := matrixEnvironmentsOf(data)
+
+	w.family("last_run_timestamp_seconds", "Time of the last check run, in seconds since the epoch.")
+	w.sample("last_run_timestamp_seconds", float64(data.Timestamp.Unix()), "service", service)
+
+	w.family("passing", "Whether no blocking policy fails in any environment (1) or some does (0).")
+	passing := 1.0
+	for _, summary := range data.PolicyEvaluation.EnvironmentSummary {
+		if summary.PolicyCounts.BlockingFailedCount > 0 {
+			passing = 0
+		}
+	}
+	w.sample("passing", passing, "service", service)
+
+	w.family("run_errors", "Number of failed parts of the last run, the results are incomplete when non-zero.")
+	w.sample("run_errors", float64(len(data.Errors)), "service", service)
+
+	w.family("policies", "Number of policies by environment, enforcement level and status.")
+	for _, env := range envs {
+		matrix := data.PolicyEvaluation.PolicyMatrix[env]
+		for _, level := range matrixLevels {
+			counts := map[string]int{STATUS_PASS: 0, STATUS_FAIL: 0, STATUS_ERROR: 0}
+			for _, policy := range level.policies(matrix) {
+				counts[policyStatusOf(policy)]++
+			}
+			for _, status := range []string{STATUS_PASS, STATUS_FAIL, STATUS_ERROR} {
+				w.sample("policies", float64(counts[status]),
+					"service", service, "environment", env, "level", level.name, "status", status)
+			}
+		}
+	}
+
+	w.family("diff_lines", "Number of added and deleted manifest lines by environment.")
+	for _, env := range envs {
+		changes, ok := data.ManifestChanges[env]
+		if !ok {
+			continue
+		}
+		w.sample("diff_lines", float64(changes.AddedLineCount), "service", service, "environment", env, "change", "added")
+		w.sample("diff_lines", float64(changes.DeletedLineCount), "service", service, "environment", env, "change", "deleted")
+	}
+	return []byte(w.buf.String())
+}
+
+// MetricsFileName returns the textfile collector file name of a service, one file per service
+// so that runs of several services on the same runner don't overwrite each other
+func MetricsFileName(service string) string {
+	if service = metricsFileNameUnsafe.ReplaceAllString(service, "_"); service != "" {
+		return "gitops_kustomzchk_" + service + ".prom"
+	}
+	return "gitops_kustomzchk.prom"
+}
+
+// WriteMetrics writes the metrics of a run to the textfile collector directory. The file is written
+// then renamed, so the collector never reads a partial file
+func WriteMetrics(dir string, data *models.ReportData) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	filePath := filepath.Join(dir, MetricsFileName(data.Service))
+	tmp, err := os.CreateTemp(dir, ".gitops_kustomzchk-*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("failed to create metrics file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(Metrics(data)); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := replaceFile(tmp.Name(), filePath); err != nil {
+		return "", fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return filePath, nil
+}
+
+// replaceFile renames src over dst. Windows refuses to replace a file another process has open (e.g. the
+// collector reading the previous metrics), so the rename is retried there for a short while
+func replaceFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	for attempt := 1; err != nil && runtime.GOOS == "windows" && attempt <= REPLACE_RETRIES; attempt++ {
+		time.Sleep(time.Duration(attempt) * 50 * time.Millisecond)
+		err = os.Rename(src, dst)
+	}
+	return err
+}
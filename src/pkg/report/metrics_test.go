@@ -0,0 +1,55 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestMetrics(t *testing.T) {
+	data := &models.ReportData{
+		Service:     `my"app`,
+		Timestamp:   time.Unix(1700000000, 0),
+		OverlayKeys: []string{"stg"},
+		ManifestChanges: map[string]models.EnvironmentDiff{
+			"stg": {AddedLineCount: 3, DeletedLineCount: 1},
+		},
+		PolicyEvaluation: models.PolicyEvaluation{
+			EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{"stg": {PolicyCounts: models.PolicyCounts{BlockingFailedCount: 1}}},
+			PolicyMatrix: map[string]models.PolicyMatrix{"stg": {
+				BlockingPolicies: []models.PolicyResult{{PolicyId: "ha"}, {PolicyId: "limits", IsPassing: true}},
+			}},
+		},
+	}
+	got := string(Metrics(data))
+	for _, want := range []string{
+		"# TYPE gitops_kustomzchk_passing gauge\n",
+		`gitops_kustomzchk_last_run_timestamp_seconds{service="my\"app"} 1.7e+09`,
+		`gitops_kustomzchk_passing{service="my\"app"} 0`,
+		`gitops_kustomzchk_policies{service="my\"app",environment="stg",level="BLOCK",status="PASS"} 1`,
+		`gitops_kustomzchk_policies{service="my\"app",environment="stg",level="BLOCK",status="FAIL"} 1`,
+		`gitops_kustomzchk_policies{service="my\"app",environment="stg",level="WARNING",status="FAIL"} 0`,
+		`gitops_kustomzchk_diff_lines{service="my\"app",environment="stg",change="added"} 3`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Metrics() does not contain %q:\n%s", want, got)
+		}
+	}
+
+	dir := t.TempDir()
+	filePath, err := WriteMetrics(dir, data)
+	if err != nil {
+		t.Fatalf("WriteMetrics() error = %v", err)
+	}
+	if filePath != filepath.Join(dir, "gitops_kustomzchk_my_app.prom") {
+		t.Errorf("WriteMetrics() file = %s", filePath)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("WriteMetrics() left %d files, want 1", len(entries))
+	}
+}
@@ -0,0 +1,137 @@
+// Package report loads report.json files of any schema version, migrating older versions to the
+// current schema so that archived reports stay readable across tool upgrades
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// LEGACY_SCHEMA_VERSION is the version of the reports written before schemaVersion was recorded
+const LEGACY_SCHEMA_VERSION = 1
+
+// migration upgrades a decoded report from its version to the next one, in place
+type migration func(report map[string]any) error
+
+// migrations are indexed by the version they upgrade from, there must be one per version
+// from LEGACY_SCHEMA_VERSION up to models.REPORT_SCHEMA_VERSION-1
+var migrations = map[int]migration{
+	1: migrateV1,
+}
+
+// SchemaVersionOf returns the schema version of an encoded report
+func SchemaVersionOf(data []byte) (int, error) {
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, fmt.Errorf("failed to decode report: %w", err)
+	}
+	if header.SchemaVersion == 0 {
+		return LEGACY_SCHEMA_VERSION, nil
+	}
+	return header.SchemaVersion, nil
+}
+
+// Parse decodes a report of any supported schema version into the current schema
+func Parse(data []byte) (*models.ReportData, error) {
+	version, err := SchemaVersionOf(data)
+	if err != nil {
+		return nil, err
+	}
+	if version > models.REPORT_SCHEMA_VERSION {
+		return nil, fmt.Errorf("report schema version %d is newer than the supported version %d, upgrade the tool",
+			version, models.REPORT_SCHEMA_VERSION)
+	}
+	if version < LEGACY_SCHEMA_VERSION {
+		return nil, fmt.Errorf("invalid report schema version %d", version)
+	}
+
+	if version < models.REPORT_SCHEMA_VERSION {
+		var report map[string]any
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("failed to decode report: %w", err)
+		}
+		for ; version < models.REPORT_SCHEMA_VERSION; version++ {
+			if err := migrations[version](report); err != nil {
+				return nil, fmt.Errorf("failed to migrate report from schema version %d: %w", version, err)
+			}
+		}
+		report["schemaVersion"] = models.REPORT_SCHEMA_VERSION
+		if data, err = json.Marshal(report); err != nil {
+			return nil, fmt.Errorf("failed to encode migrated report: %w", err)
+		}
+	}
+
+	var reportData models.ReportData
+	if err := json.Unmarshal(data, &reportData); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+	return &reportData, nil
+}
+
+// Load reads a report.json file of any supported schema version into the current schema
+func Load(path string) (*models.ReportData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	reportData, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	return reportData, nil
+}
+
+// Migrate re-encodes a report of any supported schema version in the current schema, fields
+// unknown to the current schema are dropped
+func Migrate(data []byte) ([]byte, error) {
+	reportData, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(reportData, "", "  ")
+}
+
+// migrateV1 upgrades the legacy reports: overlayKeys only existed in dynamic mode, and policy
+// results only had failMessages, not the violations pairing them with resources
+func migrateV1(report map[string]any) error {
+	if keys, _ := report["overlayKeys"].([]any); len(keys) == 0 {
+		if envs, ok := report["environments"].([]any); ok {
+			report["overlayKeys"] = envs
+		}
+	}
+
+	evaluation, _ := report["policyEvaluation"].(map[string]any)
+	matrices, _ := evaluation["policyMatrix"].(map[string]any)
+	for env, value := range matrices {
+		matrix, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("policyMatrix.%s is not an object", env)
+		}
+		for level, value := range matrix {
+			policies, _ := value.([]any)
+			for _, value := range policies {
+				policy, ok := value.(map[string]any)
+				if !ok {
+					return fmt.Errorf("policyMatrix.%s.%s has a non-object policy", env, level)
+				}
+				if _, ok := policy["violations"]; ok {
+					continue
+				}
+				messages, _ := policy["failMessages"].([]any)
+				var violations []any
+				for _, message := range messages {
+					violations = append(violations, map[string]any{"message": message})
+				}
+				if len(violations) > 0 {
+					policy["violations"] = violations
+				}
+			}
+		}
+	}
+	return nil
+}
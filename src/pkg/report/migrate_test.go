@@ -0,0 +1,89 @@
+package report
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		check   func(t *testing.T, r *models.ReportData)
+		wantErr string
+	}{
+		{
+			name: "legacy report",
+			data: `{"service":"my-app","environments":["stg","prod"],"unknownField":1,
+				"policyEvaluation":{"policyMatrix":{"stg":{"blockingPolicies":[{"policyId":"ha","failMessages":["too few replicas"]}]}}}}`,
+			check: func(t *testing.T, r *models.ReportData) {
+				if r.SchemaVersion != models.REPORT_SCHEMA_VERSION {
+					t.Errorf("SchemaVersion = %d", r.SchemaVersion)
+				}
+				if strings.Join(r.OverlayKeys, ",") != "stg,prod" {
+					t.Errorf("OverlayKeys = %v", r.OverlayKeys)
+				}
+				violations := r.PolicyEvaluation.PolicyMatrix["stg"].BlockingPolicies[0].Violations
+				if len(violations) != 1 || violations[0].Message != "too few replicas" {
+					t.Errorf("Violations = %+v", violations)
+				}
+			},
+		},
+		{
+			name: "current report is kept",
+			data: `{"schemaVersion":2,"environments":["stg"],"overlayKeys":["alpha/stg"]}`,
+			check: func(t *testing.T, r *models.ReportData) {
+				if strings.Join(r.OverlayKeys, ",") != "alpha/stg" {
+					t.Errorf("OverlayKeys = %v", r.OverlayKeys)
+				}
+			},
+		},
+		{
+			name:    "newer report",
+			data:    `{"schemaVersion":99}`,
+			wantErr: "newer than the supported version",
+		},
+		{
+			name:    "not json",
+			data:    `report`,
+			wantErr: "failed to decode report",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			tt.check(t, r)
+		})
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for version := LEGACY_SCHEMA_VERSION; version < models.REPORT_SCHEMA_VERSION; version++ {
+		if migrations[version] == nil {
+			t.Errorf("no migration from schema version %d", version)
+		}
+	}
+
+	out, err := Migrate([]byte(`{"environments":["stg"]}`))
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if version, _ := SchemaVersionOf(out); version != models.REPORT_SCHEMA_VERSION {
+		t.Errorf("migrated schema version = %d", version)
+	}
+	if !json.Valid(out) {
+		t.Errorf("Migrate() output is not valid JSON")
+	}
+}
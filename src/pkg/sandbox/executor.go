@@ -0,0 +1,98 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+)
+
+// Command is an external command run by an Executor
+type Command struct {
+	Name   string
+	Args   []string
+	Dir    string   // Working directory, empty for the current one
+	Env    []string // Added to the environment of the process ("KEY=value"), e.g. credentials kept off the command line
+	Limits Limits   // Zero values disable the corresponding limit
+}
+
+// String returns the command line, with its credentials redacted
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, trace.RedactArgs(c.Args)...), " ")
+}
+
+// Executor runs the external commands of all packages (kustomize, conftest, opa, helm, git, diff), so that
+// they share the limits, tracing, logging and redaction of Run, and tests can replace them
+// A non-zero exit code is not an error, check Result.ExitCode or use Output
+type Executor interface {
+	Run(ctx context.Context, cmd Command) (*Result, error)
+}
+
+// ProcessExecutor runs the commands as child processes within their limits, see Run
+type ProcessExecutor struct{}
+
+func (ProcessExecutor) Run(ctx context.Context, cmd Command) (*Result, error) {
+	return run(ctx, cmd)
+}
+
+// DefaultExecutor runs the commands of the packages whose executor is not set
+var DefaultExecutor Executor = ProcessExecutor{}
+
+// OrDefault returns the executor, or DefaultExecutor if nil
+func OrDefault(executor Executor) Executor {
+	if executor == nil {
+		return DefaultExecutor
+	}
+	return executor
+}
+
+// ExitError is the error of a command exiting with a non-zero code, its stderr redacted
+type ExitError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%s: exit status %d\nStderr: %s", e.Command, e.ExitCode, e.Stderr)
+}
+
+// Output runs a command and returns its stdout, a non-zero exit code is an *ExitError
+func Output(ctx context.Context, executor Executor, cmd Command) ([]byte, error) {
+	res, err := OrDefault(executor).Run(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	if res.ExitCode != 0 {
+		return nil, &ExitError{Command: cmd.Name, ExitCode: res.ExitCode, Stderr: trace.RedactText(string(res.Stderr))}
+	}
+	return res.Stdout, nil
+}
+
+// FakeExecutor records the commands instead of running them, for tests. Handler answers each command,
+// a nil Handler succeeds with empty outputs
+type FakeExecutor struct {
+	Handler func(cmd Command) (*Result, error)
+
+	mu       sync.Mutex
+	commands []Command
+}
+
+func (f *FakeExecutor) Run(_ context.Context, cmd Command) (*Result, error) {
+	f.mu.Lock()
+	f.commands = append(f.commands, cmd)
+	f.mu.Unlock()
+	if f.Handler == nil {
+		return &Result{}, nil
+	}
+	return f.Handler(cmd)
+}
+
+// Commands returns the commands run so far, in order
+func (f *FakeExecutor) Commands() []Command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Command(nil), f.commands...)
+}
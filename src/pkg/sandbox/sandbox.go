@@ -0,0 +1,153 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "sandbox")
+
+const (
+	DEFAULT_TIMEOUT          = 5 * time.Minute
+	DEFAULT_MAX_OUTPUT_BYTES = 100 << 20 // 100MiB per stream
+	// Grace period for pipes to close after the process was killed
+	WAIT_DELAY = 5 * time.Second
+)
+
+var (
+	// ErrTimeout indicates the process was killed because it exceeded Limits.Timeout
+	ErrTimeout = errors.New("process exceeded time limit")
+	// ErrOutputLimitExceeded indicates the process was killed because it wrote more than Limits.MaxOutputBytes
+	ErrOutputLimitExceeded = errors.New("process exceeded output size limit")
+)
+
+// Limits defines the resources an external process (conftest, kustomize and its exec plugins) may consume
+// Zero values disable the corresponding limit
+type Limits struct {
+	Timeout        time.Duration // Wall clock limit
+	MaxOutputBytes int64         // Limit for each of stdout and stderr
+	MaxMemoryBytes uint64        // Address space limit (RLIMIT_AS), linux only
+	MaxCPUSeconds  uint64        // CPU time limit (RLIMIT_CPU), linux only
+}
+
+// DefaultLimits returns limits preventing hangs and runaway outputs, without memory/cpu caps
+func DefaultLimits() Limits {
+	return Limits{
+		Timeout:        DEFAULT_TIMEOUT,
+		MaxOutputBytes: DEFAULT_MAX_OUTPUT_BYTES,
+	}
+}
+
+// Result holds the outputs of a finished process
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// Run executes the command within the limits
+// A non-zero exit code is not an error: callers decide (e.g. conftest exits 1 on policy failures), check Result.ExitCode
+// returns: ErrTimeout / ErrOutputLimitExceeded (wrapped) if a limit was hit, or the error starting the process
+func Run(ctx context.Context, limits Limits, dir string, name string, args ...string) (*Result, error) {
+	return run(ctx, Command{Name: name, Args: args, Dir: dir, Limits: limits})
+}
+
+func run(ctx context.Context, c Command) (*Result, error) {
+	limits, name, args := c.Limits, c.Name, c.Args
+	if limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+	}
+	ctx, cancelOnOverflow := context.WithCancelCause(ctx)
+	defer cancelOnOverflow(nil)
+
+	_, span := trace.StartExecSpan(ctx, name, args...)
+	name, args = wrapWithResourceLimits(limits, name, args)
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.WaitDelay = WAIT_DELAY
+	configureProcessGroup(cmd)
+
+	stdout := newCappedBuffer(limits.MaxOutputBytes, func() { cancelOnOverflow(ErrOutputLimitExceeded) })
+	stderr := newCappedBuffer(limits.MaxOutputBytes, func() { cancelOnOverflow(ErrOutputLimitExceeded) })
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	runErr := cmd.Run()
+	result := &Result{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: cmd.ProcessState.ExitCode(),
+	}
+	logger.WithField("cmd", name).WithField("args", trace.RedactArgs(args)).WithField("exitCode", result.ExitCode).WithField("duration", time.Since(start)).Debug("Process finished")
+
+	err := runError(ctx, limits, name, runErr)
+	span.End(result.ExitCode, int64(len(result.Stdout)), int64(len(result.Stderr)), err)
+	return result, err
+}
+
+// runError maps the outcome of cmd.Run to the error of Run, nil for a non-zero exit code
+func runError(ctx context.Context, limits Limits, name string, runErr error) error {
+	if errors.Is(context.Cause(ctx), ErrOutputLimitExceeded) {
+		return fmt.Errorf("%s: %w (%d bytes)", name, ErrOutputLimitExceeded, limits.MaxOutputBytes)
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s: %w (%s)", name, ErrTimeout, limits.Timeout)
+	}
+	var exitErr *exec.ExitError
+	if runErr != nil && !errors.As(runErr, &exitErr) {
+		return runErr
+	}
+	return nil
+}
+
+// cappedBuffer is a bytes.Buffer that stops accepting data past its limit and notifies once
+type cappedBuffer struct {
+	mu         sync.Mutex
+	buf        bytes.Buffer
+	limit      int64
+	onOverflow func()
+	overflowed bool
+}
+
+func newCappedBuffer(limit int64, onOverflow func()) *cappedBuffer {
+	return &cappedBuffer{limit: limit, onOverflow: onOverflow}
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit <= 0 {
+		return b.buf.Write(p)
+	}
+	if b.overflowed {
+		return len(p), nil // discard, the process is being killed
+	}
+	if remaining := b.limit - int64(b.buf.Len()); int64(len(p)) > remaining {
+		b.buf.Write(p[:remaining])
+		b.overflowed = true
+		b.onOverflow()
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
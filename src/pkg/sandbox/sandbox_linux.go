@@ -0,0 +1,35 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+var prlimitWarnOnce sync.Once
+
+// wrapWithResourceLimits prefixes the command with prlimit(1) to apply RLIMIT_AS and RLIMIT_CPU to the child
+func wrapWithResourceLimits(limits Limits, name string, args []string) (string, []string) {
+	if limits.MaxMemoryBytes == 0 && limits.MaxCPUSeconds == 0 {
+		return name, args
+	}
+	prlimitPath, err := exec.LookPath("prlimit")
+	if err != nil {
+		prlimitWarnOnce.Do(func() {
+			logger.Warn("prlimit not found in PATH, memory and cpu limits will not be applied")
+		})
+		return name, args
+	}
+
+	wrapped := []string{}
+	if limits.MaxMemoryBytes > 0 {
+		wrapped = append(wrapped, "--as="+strconv.FormatUint(limits.MaxMemoryBytes, 10))
+	}
+	if limits.MaxCPUSeconds > 0 {
+		wrapped = append(wrapped, "--cpu="+strconv.FormatUint(limits.MaxCPUSeconds, 10))
+	}
+	wrapped = append(wrapped, "--", name)
+	return prlimitPath, append(wrapped, args...)
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"sync"
+)
+
+var unsupportedWarnOnce sync.Once
+
+// wrapWithResourceLimits is a no-op outside linux, only time and output limits apply
+func wrapWithResourceLimits(limits Limits, name string, args []string) (string, []string) {
+	if limits.MaxMemoryBytes > 0 || limits.MaxCPUSeconds > 0 {
+		unsupportedWarnOnce.Do(func() {
+			logger.Warn("memory and cpu limits are only supported on linux, they will not be applied")
+		})
+	}
+	return name, args
+}
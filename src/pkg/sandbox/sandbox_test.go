@@ -0,0 +1,66 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		limits       Limits
+		script       string
+		wantErr      error
+		wantExitCode int
+		wantStdout   string
+	}{
+		{
+			name:       "success within limits",
+			limits:     DefaultLimits(),
+			script:     "echo hello",
+			wantStdout: "hello\n",
+		},
+		{
+			name:         "non-zero exit is not an error",
+			limits:       DefaultLimits(),
+			script:       "echo failing; exit 1",
+			wantExitCode: 1,
+			wantStdout:   "failing\n",
+		},
+		{
+			name:    "timeout",
+			limits:  Limits{Timeout: 100 * time.Millisecond},
+			script:  "sleep 5",
+			wantErr: ErrTimeout,
+		},
+		{
+			name:    "output limit",
+			limits:  Limits{MaxOutputBytes: 16},
+			script:  "while true; do echo aaaaaaaaaaaaaaaa; done",
+			wantErr: ErrOutputLimitExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := Run(context.Background(), tt.limits, "", "sh", "-c", tt.script)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() unexpected error = %v", err)
+			}
+			if res.ExitCode != tt.wantExitCode {
+				t.Errorf("Run() exitCode = %d, want %d", res.ExitCode, tt.wantExitCode)
+			}
+			if string(res.Stdout) != tt.wantStdout {
+				t.Errorf("Run() stdout = %q, want %q", string(res.Stdout), tt.wantStdout)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package sandbox
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// configureProcessGroup kills the whole tree (e.g. kustomize exec plugins) on cancel with taskkill /T,
+// Windows has no process groups to signal. The direct child is still killed if taskkill fails
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		if cmd.Process == nil {
+			return nil
+		}
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+// Package service loads the metadata file of a service directory
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+	"gopkg.in/yaml.v2"
+)
+
+// METADATA_FILE is the name of the metadata file in a service directory
+const METADATA_FILE = "service.yaml"
+
+// LoadMetadata reads and validates the metadata file of a service directory of files (nil for the disk),
+// nil if the directory has none
+func LoadMetadata(files fsys.FS, dir string) (*models.ServiceMetadata, error) {
+	content, err := fsys.OrOS(files).ReadFile(filepath.Join(dir, METADATA_FILE))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filepath.Join(dir, METADATA_FILE), err)
+	}
+
+	var metadata models.ServiceMetadata
+	if err := yaml.UnmarshalStrict(content, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Join(dir, METADATA_FILE), err)
+	}
+	if err := validateMetadata(&metadata); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", filepath.Join(dir, METADATA_FILE), err)
+	}
+	return &metadata, nil
+}
+
+func validateMetadata(metadata *models.ServiceMetadata) error {
+	if metadata.Policies == nil {
+		return nil
+	}
+	for _, pattern := range slices.Concat(metadata.Policies.Include, metadata.Policies.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("policies: invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+)
+
+func TestLoadMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string // empty for no service.yaml
+		wantNil  bool
+		wantErr  bool
+		selected map[string]bool
+	}{
+		{name: "no metadata", wantNil: true},
+		{
+			name: "selectors",
+			content: `displayName: Payments
+owner: org/payments
+tier: tier-1
+slackChannel: "#payments"
+policies:
+  include: ["k8s-*", "images"]
+  exclude: ["k8s-hpa"]
+`,
+			selected: map[string]bool{"k8s-pdb": true, "images": true, "k8s-hpa": false, "ha": false},
+		},
+		{name: "no selectors", content: "owner: payments\n", selected: map[string]bool{"ha": true}},
+		{name: "unknown field", content: "team: payments\n", wantErr: true},
+		{name: "invalid pattern", content: "policies:\n  exclude: [\"[ha\"]\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, dir := fsys.NewMem(), filepath.Join(string(filepath.Separator), "payments")
+			if tt.content != "" {
+				if err := files.WriteFile(filepath.Join(dir, METADATA_FILE), []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			metadata, err := LoadMetadata(files, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (metadata == nil) != tt.wantNil {
+				t.Fatalf("LoadMetadata() = %+v, wantNil %v", metadata, tt.wantNil)
+			}
+			for id, want := range tt.selected {
+				if got := metadata.Policies.Selects(id); got != want {
+					t.Errorf("Selects(%q) = %v, want %v", id, got, want)
+				}
+			}
+		})
+	}
+}
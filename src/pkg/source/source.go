@@ -0,0 +1,263 @@
+// Package source fetches the policies and templates of the server mode from a directory, a git repository or an
+// OCI artifact, and keeps a validated snapshot of their current version, reloaded when a new one is published
+package source
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/fsys"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "source")
+
+const (
+	KIND_DIR = "dir"
+	KIND_GIT = "git"
+	KIND_OCI = "oci"
+
+	// GIT_PREFIX and OCI_PREFIX start the git and OCI sources, the other ones are directories
+	GIT_PREFIX = "git+"
+	OCI_PREFIX = "oci://"
+
+	// ENV_SOURCE_TOKEN is the token of the HTTPS git sources, for private repositories
+	ENV_SOURCE_TOKEN = "GITOPS_KUSTOMZCHK_SOURCE_TOKEN"
+)
+
+// commitSHAPattern matches a full commit SHA, a git ref that never moves
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Fetcher gets the files of a source
+type Fetcher interface {
+	// Kind is the KIND_* of the source
+	Kind() string
+	// Version returns the version the source is at, empty when it is only known by fetching it
+	Version(ctx context.Context) (string, error)
+	// Fetch writes the files of the source to dir, an empty directory, returns their version
+	Fetch(ctx context.Context, dir string) (string, error)
+}
+
+// Parse returns the fetcher of a source:
+//   - a directory, e.g. ./policies, versioned by the sha256 of its files
+//   - git+https://github.com/org/policies?ref=main&path=policies, versioned by commit SHA. ref defaults to
+//     the default branch, path to the root of the repository
+//   - oci://ghcr.io/org/policies:v1, pulled with conftest and versioned by the sha256 of its files
+func Parse(spec string) (Fetcher, error) {
+	switch {
+	case strings.HasPrefix(spec, GIT_PREFIX):
+		u, err := url.Parse(strings.TrimPrefix(spec, GIT_PREFIX))
+		if err != nil || !slices.Contains([]string{"https", "http", "file"}, u.Scheme) || (u.Host == "" && u.Scheme != "file") {
+			return nil, fmt.Errorf("invalid git source %s, expected git+https://<host>/<repo>?ref=<ref>&path=<dir>", spec)
+		}
+		query := u.Query()
+		u.RawQuery = ""
+		subPath := path.Clean("/" + query.Get("path"))[1:]
+		return &gitFetcher{URL: u.String(), Ref: cmp.Or(query.Get("ref"), "HEAD"), Path: subPath}, nil
+	case strings.HasPrefix(spec, OCI_PREFIX):
+		if len(spec) == len(OCI_PREFIX) {
+			return nil, fmt.Errorf("invalid OCI source %s, expected oci://<registry>/<repository>:<tag>", spec)
+		}
+		return &ociFetcher{Ref: spec}, nil
+	case strings.Contains(spec, "://"):
+		return nil, fmt.Errorf("unsupported source %s, expected a directory, %s<url> or %s<ref>", spec, GIT_PREFIX, OCI_PREFIX)
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("empty source")
+	}
+	return &dirFetcher{Path: spec}, nil
+}
+
+// IsDir reports whether a source is a directory
+func IsDir(spec string) bool {
+	f, err := Parse(spec)
+	return err == nil && f.Kind() == KIND_DIR
+}
+
+// dirFetcher copies a directory
+type dirFetcher struct {
+	Path string
+}
+
+func (f *dirFetcher) Kind() string { return KIND_DIR }
+
+func (f *dirFetcher) Version(ctx context.Context) (string, error) {
+	return digestOf(f.Path)
+}
+
+func (f *dirFetcher) Fetch(ctx context.Context, dir string) (string, error) {
+	if _, err := os.Stat(f.Path); err != nil {
+		return "", err
+	}
+	if err := fsys.CopyDir(f.Path, dir); err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", f.Path, err)
+	}
+	// the version of the copy, the directory may have changed since Version
+	return digestOf(dir)
+}
+
+// gitFetcher checks out a directory of a git repository at a ref
+type gitFetcher struct {
+	URL  string
+	Ref  string
+	Path string // slash-separated, empty for the root
+}
+
+func (f *gitFetcher) Kind() string { return KIND_GIT }
+
+// Version resolves the ref with git ls-remote, the peeled commit of an annotated tag
+func (f *gitFetcher) Version(ctx context.Context) (string, error) {
+	if commitSHAPattern.MatchString(f.Ref) {
+		return f.Ref, nil
+	}
+	out, err := f.git(ctx, "", "ls-remote", f.URL, f.Ref, f.Ref+"^{}")
+	if err != nil {
+		return "", err
+	}
+	version := ""
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if version == "" || strings.HasSuffix(fields[1], "^{}") {
+			version = fields[0]
+		}
+	}
+	if version == "" {
+		return "", fmt.Errorf("ref %s not found in %s", f.Ref, f.URL)
+	}
+	return version, nil
+}
+
+func (f *gitFetcher) Fetch(ctx context.Context, dir string) (string, error) {
+	repo, err := os.MkdirTemp("", "kustomzchk-source-git-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create the git checkout dir: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(repo)
+	}()
+	steps := [][]string{
+		{"init", "-q"},
+		{"fetch", "-q", "--depth", "1", f.URL, f.Ref},
+		{"-c", "core.autocrlf=false", "-c", "core.longpaths=true", "checkout", "-q", "--detach", "FETCH_HEAD"},
+	}
+	for _, args := range steps {
+		if _, err := f.git(ctx, repo, args...); err != nil {
+			return "", err
+		}
+	}
+	version, err := runGit(ctx, repo, nil, "rev-parse", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return "", err
+	}
+	subDir := filepath.Join(repo, filepath.FromSlash(f.Path))
+	if info, err := os.Stat(subDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("path %s not found in %s at %s", f.Path, f.URL, f.Ref)
+	}
+	if err := fsys.CopyDir(subDir, dir); err != nil {
+		return "", fmt.Errorf("failed to copy the checkout of %s: %w", f.URL, err)
+	}
+	return version, nil
+}
+
+// git runs a git command with the credentials of the repository
+func (f *gitFetcher) git(ctx context.Context, dir string, args ...string) (string, error) {
+	return runGit(ctx, dir, f.credentials(), args...)
+}
+
+// credentials returns the environment passing the ENV_SOURCE_TOKEN of an HTTPS repository to git as an
+// Authorization header of its host, so that the token is neither in the URL nor on the command line. The user of
+// the URL defaults to x-access-token (GitHub)
+func (f *gitFetcher) credentials() []string {
+	token := os.Getenv(ENV_SOURCE_TOKEN)
+	u, err := url.Parse(f.URL)
+	if token == "" || err != nil || u.Scheme != "https" {
+		return nil
+	}
+	basic := base64.StdEncoding.EncodeToString([]byte(cmp.Or(u.User.Username(), "x-access-token") + ":" + token))
+	return []string{
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=http.https://" + u.Host + "/.extraHeader",
+		"GIT_CONFIG_VALUE_0=Authorization: Basic " + basic,
+	}
+}
+
+// ociFetcher pulls an OCI artifact with conftest, with the registry credentials of the docker config
+type ociFetcher struct {
+	Ref string
+}
+
+func (f *ociFetcher) Kind() string { return KIND_OCI }
+
+// Version is only known by pulling the artifact
+func (f *ociFetcher) Version(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+func (f *ociFetcher) Fetch(ctx context.Context, dir string) (string, error) {
+	cmd := sandbox.Command{Name: "conftest", Args: []string{"pull", f.Ref, "--policy", dir}}
+	if _, err := sandbox.Output(ctx, sandbox.DefaultExecutor, cmd); err != nil {
+		return "", fmt.Errorf("failed to pull %s: %w", f.Ref, err)
+	}
+	return digestOf(dir)
+}
+
+// runGit runs a git command in dir with the environment variables env, returns its trimmed stdout
+func runGit(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	stdout, err := sandbox.Output(ctx, sandbox.DefaultExecutor, sandbox.Command{Name: "git", Args: args, Dir: dir, Env: env})
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", strings.Join(trace.RedactArgs(args), " "), err)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// digestOf returns "sha256:<hex>" of the paths and contents of the files of a directory, but fsys.SKIPPED_DIRS,
+// the files of the symlinks included as fsys.CopyDir copies them
+func digestOf(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if filePath != dir && slices.Contains(fsys.SKIPPED_DIRS, d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := os.Stat(filePath)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, filePath)
+		_, _ = io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+		_, _ = h.Write(content)
+		_, _ = h.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", dir, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
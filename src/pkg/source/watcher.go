@@ -0,0 +1,170 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// POLL_INTERVAL is how often a watched source is checked for a new version
+const POLL_INTERVAL = time.Minute
+
+// Snapshot is a fetched version of a source, its files in Dir. It is removed from disk once replaced by a new
+// version and released by the runs using it
+type Snapshot struct {
+	Source  string
+	Version string
+	Dir     string
+
+	refs    int
+	retired bool
+}
+
+// Watcher keeps the current validated snapshot of a source
+// It is safe for concurrent use
+type Watcher struct {
+	Source   string
+	Validate func(dir string) error // checks a new version before it replaces the current one, nil for none
+	Removed  func(dir string)       // called with the dir of each snapshot once removed, e.g. to drop what Validate loaded from it
+
+	fetcher   Fetcher
+	reloading sync.Mutex
+	mu        sync.Mutex
+	current   *Snapshot
+}
+
+// NewWatcher fetches the current version of a source (see Parse), it fails if the version is invalid
+func NewWatcher(ctx context.Context, spec string, validate func(dir string) error) (*Watcher, error) {
+	fetcher, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{Source: spec, Validate: validate, fetcher: fetcher}
+	if _, err := w.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Acquire returns the current snapshot, kept on disk until release is called
+func (w *Watcher) Acquire() (*Snapshot, func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	snapshot := w.current
+	snapshot.refs++
+	var once sync.Once
+	return snapshot, func() {
+		once.Do(func() {
+			w.mu.Lock()
+			snapshot.refs--
+			remove := snapshot.retired && snapshot.refs == 0
+			w.mu.Unlock()
+			if remove {
+				w.remove(snapshot)
+			}
+		})
+	}
+}
+
+// Current returns the version of the current snapshot
+func (w *Watcher) Current() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current.Version
+}
+
+// Reload fetches the source if its version changed, and makes it the current snapshot once validated. Reports
+// whether it was reloaded, an invalid version is not loaded and the current one stays in use
+func (w *Watcher) Reload(ctx context.Context) (bool, error) {
+	w.reloading.Lock()
+	defer w.reloading.Unlock()
+	version, err := w.fetcher.Version(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get the version of %s: %w", w.Source, err)
+	}
+	w.mu.Lock()
+	currentVersion := ""
+	if w.current != nil {
+		currentVersion = w.current.Version
+	}
+	w.mu.Unlock()
+	if version != "" && version == currentVersion {
+		return false, nil
+	}
+
+	dir, err := os.MkdirTemp("", "kustomzchk-source-*")
+	if err != nil {
+		return false, fmt.Errorf("failed to create the snapshot dir of %s: %w", w.Source, err)
+	}
+	snapshot := &Snapshot{Source: w.Source, Dir: dir}
+	if snapshot.Version, err = w.fetcher.Fetch(ctx, dir); err != nil {
+		w.remove(snapshot)
+		return false, fmt.Errorf("failed to fetch %s: %w", w.Source, err)
+	}
+	if snapshot.Version == currentVersion {
+		w.remove(snapshot)
+		return false, nil
+	}
+	if w.Validate != nil {
+		if err := w.Validate(dir); err != nil {
+			w.remove(snapshot)
+			return false, fmt.Errorf("invalid version %s of %s: %w", snapshot.Version, w.Source, err)
+		}
+	}
+
+	w.mu.Lock()
+	previous := w.current
+	w.current = snapshot
+	w.mu.Unlock()
+	if previous != nil {
+		w.retire(previous)
+	}
+	logger.WithField("source", w.Source).WithField("version", snapshot.Version).WithField("previous", currentVersion).Info("Loaded source version")
+	return true, nil
+}
+
+// Watch reloads the source every interval until ctx is done, a failed reload is logged
+func (w *Watcher) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if _, err := w.Reload(ctx); err != nil {
+			logger.WithField("source", w.Source).WithField("error", err).Error("Failed to reload source, keeping the current version")
+		}
+	}
+}
+
+// Close removes the current snapshot once released
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	current := w.current
+	w.mu.Unlock()
+	w.retire(current)
+}
+
+// retire removes a replaced snapshot, at once if no run uses it
+func (w *Watcher) retire(snapshot *Snapshot) {
+	w.mu.Lock()
+	snapshot.retired = true
+	remove := snapshot.refs == 0
+	w.mu.Unlock()
+	if remove {
+		w.remove(snapshot)
+	}
+}
+
+func (w *Watcher) remove(snapshot *Snapshot) {
+	if err := os.RemoveAll(snapshot.Dir); err != nil {
+		logger.WithField("dir", snapshot.Dir).WithField("error", err).Warn("Failed to remove source snapshot")
+	}
+	if w.Removed != nil {
+		w.Removed(snapshot.Dir)
+	}
+}
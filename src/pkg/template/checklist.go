@@ -0,0 +1,49 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// checklistLinePattern matches a checklist item of the tool comment, with its tick and policy id
+var checklistLinePattern = regexp.MustCompile(`^\s*[-*] \[([ xX])\] .*` +
+	strings.ReplaceAll(regexp.QuoteMeta(ToolChecklistSignature), regexp.QuoteMeta(ToolChecklistKeyToken), `(\S+)`) + `\s*$`)
+
+// ChecklistMarkdown renders the checklist items as a markdown task list, each item tagged with
+// ToolChecklistSignature for ParseChecklist to read its tick back from the comment
+func ChecklistMarkdown(items []models.ChecklistItem) string {
+	var b strings.Builder
+	for _, item := range items {
+		tick := " "
+		if item.Checked {
+			tick = "x"
+		}
+		required := ""
+		if item.Required {
+			required = " **(required)**"
+		}
+		signature := strings.ReplaceAll(ToolChecklistSignature, ToolChecklistKeyToken, item.PolicyId)
+		fmt.Fprintf(&b, "- [%s] %s%s %s\n", tick, escapeChecklistText(item.Text), required, signature)
+	}
+	return b.String()
+}
+
+// ParseChecklist returns the ticked state of the checklist items of a comment rendered with ChecklistMarkdown,
+// by policy id
+func ParseChecklist(body string) map[string]bool {
+	checked := make(map[string]bool)
+	for _, line := range strings.Split(body, "\n") {
+		if match := checklistLinePattern.FindStringSubmatch(strings.TrimRight(line, "\r")); match != nil {
+			checked[match[2]] = match[1] != " "
+		}
+	}
+	return checked
+}
+
+// escapeChecklistText keeps an item on its line, so that its tick is read back
+func escapeChecklistText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
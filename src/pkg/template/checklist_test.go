@@ -0,0 +1,30 @@
+package template
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestChecklist(t *testing.T) {
+	items := []models.ChecklistItem{
+		{PolicyId: "dba-review", Text: "A DBA reviewed\nthe schema change", Required: true},
+		{PolicyId: "runbook", Text: "The runbook is updated", Checked: true},
+	}
+	rendered := ChecklistMarkdown(items)
+	want := "- [ ] A DBA reviewed the schema change **(required)** <!-- gitops-kustomzchk-checklist: dba-review -->\n" +
+		"- [x] The runbook is updated <!-- gitops-kustomzchk-checklist: runbook -->\n"
+	if rendered != want {
+		t.Fatalf("ChecklistMarkdown() = %q, want %q", rendered, want)
+	}
+
+	// a reviewer ticks the first item, GitHub rewrites its box only
+	ticked := "## Checklist\r\n- [x] A DBA reviewed the schema change **(required)** <!-- gitops-kustomzchk-checklist: dba-review -->\r\n" +
+		"- [ ] The runbook is updated <!-- gitops-kustomzchk-checklist: runbook -->\r\n" +
+		"- [x] a task of someone else\n"
+	got := ParseChecklist(ticked)
+	if wantChecked := map[string]bool{"dba-review": true, "runbook": false}; !reflect.DeepEqual(got, wantChecked) {
+		t.Errorf("ParseChecklist() = %v, want %v", got, wantChecked)
+	}
+}
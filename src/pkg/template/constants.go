@@ -5,7 +5,28 @@ package template
 const (
 	ToolCommentServiceToken = "$SERVICE$"
 	ToolCommentSignature    = `<!-- gitops-kustomzchk: $SERVICE$ - auto-generated comment, please do not remove -->`
+	// ToolCommentSignatureMarkdown is the marker of the SCMs rendering no HTML (Bitbucket), a link reference
+	// definition is not rendered either
+	ToolCommentSignatureMarkdown = `[//]: # (gitops-kustomzchk: $SERVICE$ - auto-generated comment, please do not remove)`
+	// ToolSuggestionSignature marks the review comments suggesting a policy fix, $KEY$ identifies the suggested change
+	ToolSuggestionKeyToken  = "$KEY$"
+	ToolSuggestionSignature = `<!-- gitops-kustomzchk-suggestion: $KEY$ -->`
+	// ToolChecklistSignature marks the checklist items of the comment, $POLICY$ is the id of their manual policy
+	ToolChecklistKeyToken   = "$POLICY$"
+	ToolChecklistSignature  = `<!-- gitops-kustomzchk-checklist: $POLICY$ -->`
 	FileNameCommentTemplate = "comment.md.tmpl"
 	FileNameDiffTemplate    = "diff.md.tmpl"
 	FileNamePolicyTemplate  = "policy.md.tmpl"
+
+	// ToolLeaseSignature marks the run holding the tool comment of a service with --run-lock, $LEASE$ is its start
+	// time and holder. ToolLeaseSignatureMarkdown is the marker of the SCMs rendering no HTML
+	ToolLeaseKeyToken          = "$LEASE$"
+	ToolLeaseSignature         = `<!-- gitops-kustomzchk-lease: $LEASE$ -->`
+	ToolLeaseSignatureMarkdown = `[//]: # (gitops-kustomzchk-lease: $LEASE$)`
+
+	// ToolHeadSignature records the head commit a tool comment was evaluated for, $HEAD$ is its SHA.
+	// ToolHeadSignatureMarkdown is the marker of the SCMs rendering no HTML
+	ToolHeadKeyToken          = "$HEAD$"
+	ToolHeadSignature         = `<!-- gitops-kustomzchk-head: $HEAD$ -->`
+	ToolHeadSignatureMarkdown = `[//]: # (gitops-kustomzchk-head: $HEAD$)`
 )
@@ -0,0 +1,153 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// Overall statuses of a report, from the most to the least severe
+const (
+	STATUS_FAIL    = "FAIL"    // a blocking policy fails
+	STATUS_ERROR   = "ERROR"   // part of the run failed, the results are incomplete
+	STATUS_WARNING = "WARNING" // a warning policy fails
+	STATUS_PASS    = "PASS"
+)
+
+// EnvironmentStatus is the policy status of one evaluated environment
+type EnvironmentStatus struct {
+	OverlayKey string
+	Status     string // STATUS_FAIL, STATUS_WARNING or STATUS_PASS
+	Counts     models.PolicyCounts
+}
+
+// TemplateData is the data the report templates are rendered with. The report fields are promoted
+// as-is, the computed fields below are the stable contract: prefer them over walking the report maps
+type TemplateData struct {
+	*models.ReportData
+
+	// HasBlockingFailures is true if a blocking policy fails in any environment
+	HasBlockingFailures bool
+	// OverallStatus is the most severe status of the report, one of STATUS_*
+	OverallStatus string
+	// EnvironmentStatuses are the statuses of the evaluated environments, in report order
+	EnvironmentStatuses []EnvironmentStatus
+	// PerEnvStatusLine is a one-line markdown summary of EnvironmentStatuses, e.g. "`stg` ✅ · `prod` 🚫 1 blocking"
+	PerEnvStatusLine string
+	// StatusTitle is a plain-text one-line summary of the report for check titles, failing environments first,
+	// e.g. "2 blocking failures in prod; stg clean; 1 override active"
+	StatusTitle string
+
+	// SummaryTable and PolicyMatrixTable are the pre-built markdown policy tables
+	SummaryTable      string
+	PolicyMatrixTable string
+	// ChecklistMarkdown is the task list of the Checklist items, their ticks are read back on the next run
+	ChecklistMarkdown string
+
+	// CommonBlockingFailures, CommonWarningFailures and CommonRecommendFailures are the policies failing with the
+	// same messages in every evaluated environment, to list once for "all environments" (see IsCommonFailure)
+	CommonBlockingFailures  []models.PolicyResult
+	CommonWarningFailures   []models.PolicyResult
+	CommonRecommendFailures []models.PolicyResult
+
+	commonFailures map[string][]models.PolicyResult
+}
+
+// NewTemplateData computes the template data of a report
+func NewTemplateData(data *models.ReportData) *TemplateData {
+	td := &TemplateData{
+		ReportData:        data,
+		OverallStatus:     STATUS_PASS,
+		SummaryTable:      SummaryTable(data),
+		PolicyMatrixTable: PolicyMatrixTable(data),
+		ChecklistMarkdown: ChecklistMarkdown(data.Checklist),
+		commonFailures:    commonFailuresOf(data),
+	}
+	td.CommonBlockingFailures = td.commonFailures[LEVEL_BLOCK]
+	td.CommonWarningFailures = td.commonFailures[LEVEL_WARNING]
+	td.CommonRecommendFailures = td.commonFailures[LEVEL_RECOMMEND]
+
+	hasWarningFailures := false
+	var line []string
+	for _, env := range environmentsOf(data) {
+		counts := data.PolicyEvaluation.EnvironmentSummary[env].PolicyCounts
+		status := EnvironmentStatus{OverlayKey: env, Status: STATUS_PASS, Counts: counts}
+
+		var failures []string
+		if counts.BlockingFailedCount > 0 {
+			status.Status = STATUS_FAIL
+			td.HasBlockingFailures = true
+			failures = append(failures, fmt.Sprintf("🚫 %d blocking", counts.BlockingFailedCount))
+		}
+		if counts.WarningFailedCount > 0 {
+			if status.Status == STATUS_PASS {
+				status.Status = STATUS_WARNING
+			}
+			hasWarningFailures = true
+			failures = append(failures, fmt.Sprintf("⚠️ %d warning", counts.WarningFailedCount))
+		}
+		if len(failures) == 0 {
+			failures = append(failures, "✅")
+		}
+		line = append(line, fmt.Sprintf("`%s` %s", data.DisplayName(env), strings.Join(failures, ", ")))
+		td.EnvironmentStatuses = append(td.EnvironmentStatuses, status)
+	}
+	td.PerEnvStatusLine = strings.Join(line, " · ")
+	td.StatusTitle = statusTitleOf(data, td.EnvironmentStatuses)
+
+	switch {
+	case td.HasBlockingFailures:
+		td.OverallStatus = STATUS_FAIL
+	case len(data.Errors) > 0:
+		td.OverallStatus = STATUS_ERROR
+	case hasWarningFailures:
+		td.OverallStatus = STATUS_WARNING
+	}
+	return td
+}
+
+// statusTitleOf summarizes the environment statuses in plain text: the failing environments with their most severe
+// failures, then the clean ones, the active overrides and whether the run is incomplete
+func statusTitleOf(data *models.ReportData, statuses []EnvironmentStatus) string {
+	var parts, clean []string
+	for _, status := range statuses {
+		name := data.DisplayName(status.OverlayKey)
+		switch status.Status {
+		case STATUS_FAIL:
+			parts = append(parts, fmt.Sprintf("%s in %s", plural(status.Counts.BlockingFailedCount, "blocking failure"), name))
+		case STATUS_WARNING:
+			parts = append(parts, fmt.Sprintf("%s in %s", plural(status.Counts.WarningFailedCount, "warning"), name))
+		default:
+			clean = append(clean, name)
+		}
+	}
+	if len(clean) > 0 {
+		parts = append(parts, strings.Join(clean, ", ")+" clean")
+	}
+	if len(statuses) == 0 {
+		parts = append(parts, "no environment evaluated")
+	}
+
+	overridden := make(map[string]bool)
+	for _, matrix := range data.PolicyEvaluation.PolicyMatrix {
+		for _, policy := range matrix.OverriddenPolicies {
+			overridden[policy.PolicyId] = true
+		}
+	}
+	if len(overridden) > 0 {
+		parts = append(parts, plural(len(overridden), "override")+" active")
+	}
+	if len(data.Errors) > 0 {
+		parts = append(parts, "check incomplete")
+	}
+	return strings.Join(parts, "; ")
+}
+
+// plural returns a count of a noun, e.g. "1 warning" and "2 warnings"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
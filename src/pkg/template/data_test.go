@@ -0,0 +1,119 @@
+package template
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestNewTemplateData(t *testing.T) {
+	summary := func(blocking, warning int) models.EnvironmentSummaryEnv {
+		return models.EnvironmentSummaryEnv{PolicyCounts: models.PolicyCounts{BlockingFailedCount: blocking, WarningFailedCount: warning}}
+	}
+	tests := []struct {
+		name       string
+		data       *models.ReportData
+		wantStatus string
+		wantLine   string
+		wantTitle  string
+	}{
+		{
+			name: "blocking failure",
+			data: &models.ReportData{
+				OverlayKeys:      []string{"stg", "prod"},
+				PolicyEvaluation: models.PolicyEvaluation{EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{"stg": summary(0, 0), "prod": summary(1, 2)}},
+			},
+			wantStatus: STATUS_FAIL,
+			wantLine:   "`stg` ✅ · `prod` 🚫 1 blocking, ⚠️ 2 warning",
+			wantTitle:  "1 blocking failure in prod; stg clean",
+		},
+		{
+			name: "override active",
+			data: &models.ReportData{
+				OverlayKeys: []string{"stg", "prod"},
+				PolicyEvaluation: models.PolicyEvaluation{
+					EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{"stg": summary(0, 0), "prod": summary(2, 0)},
+					PolicyMatrix: map[string]models.PolicyMatrix{
+						"stg":  {OverriddenPolicies: []models.PolicyResult{{PolicyId: "ha"}}},
+						"prod": {OverriddenPolicies: []models.PolicyResult{{PolicyId: "ha"}}},
+					},
+				},
+			},
+			wantStatus: STATUS_FAIL,
+			wantLine:   "`stg` ✅ · `prod` 🚫 2 blocking",
+			wantTitle:  "2 blocking failures in prod; stg clean; 1 override active",
+		},
+		{
+			name: "run error outranks warning",
+			data: &models.ReportData{
+				OverlayKeys:      []string{"stg"},
+				PolicyEvaluation: models.PolicyEvaluation{EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{"stg": summary(0, 1)}},
+				Errors:           []models.RunError{{Message: "boom"}},
+			},
+			wantStatus: STATUS_ERROR,
+			wantLine:   "`stg` ⚠️ 1 warning",
+			wantTitle:  "1 warning in stg; check incomplete",
+		},
+		{
+			name: "passing",
+			data: &models.ReportData{
+				OverlayKeys:      []string{"stg"},
+				PolicyEvaluation: models.PolicyEvaluation{EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{"stg": summary(0, 0)}},
+			},
+			wantStatus: STATUS_PASS,
+			wantLine:   "`stg` ✅",
+			wantTitle:  "stg clean",
+		},
+		{
+			name: "display names",
+			data: &models.ReportData{
+				OverlayKeys:      []string{"stg", "prd"},
+				DisplayNames:     map[string]string{"prd": "Production"},
+				PolicyEvaluation: models.PolicyEvaluation{EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{"stg": summary(0, 0), "prd": summary(0, 1)}},
+			},
+			wantStatus: STATUS_WARNING,
+			wantLine:   "`stg` ✅ · `Production` ⚠️ 1 warning",
+			wantTitle:  "1 warning in Production; stg clean",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			td := NewTemplateData(tt.data)
+			if td.OverallStatus != tt.wantStatus {
+				t.Errorf("OverallStatus = %q, want %q", td.OverallStatus, tt.wantStatus)
+			}
+			if td.HasBlockingFailures != (tt.wantStatus == STATUS_FAIL) {
+				t.Errorf("HasBlockingFailures = %v", td.HasBlockingFailures)
+			}
+			if td.PerEnvStatusLine != tt.wantLine {
+				t.Errorf("PerEnvStatusLine = %q, want %q", td.PerEnvStatusLine, tt.wantLine)
+			}
+			if td.StatusTitle != tt.wantTitle {
+				t.Errorf("StatusTitle = %q, want %q", td.StatusTitle, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	at := time.Date(2025, 11, 30, 20, 0, 0, 0, time.UTC)
+	tests := []struct {
+		timezone string
+		wantTime string
+		wantDate string
+	}{
+		{"", "2025-11-30 20:00:00 UTC", "2025-11-30"},
+		{"Asia/Tokyo", "2025-11-30 20:00:00 UTC (2025-12-01 05:00:00 JST)", "2025-11-30 20:00:00 UTC (2025-12-01 05:00:00 JST)"},
+		{"Not/AZone", "2025-11-30 20:00:00 UTC", "2025-11-30"},
+	}
+	for _, tt := range tests {
+		td := NewTemplateData(&models.ReportData{Timezone: tt.timezone})
+		if got := td.FormatTime(at); got != tt.wantTime {
+			t.Errorf("FormatTime() in %q = %q, want %q", tt.timezone, got, tt.wantTime)
+		}
+		if got := td.FormatDate(at); got != tt.wantDate {
+			t.Errorf("FormatDate() in %q = %q, want %q", tt.timezone, got, tt.wantDate)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package template
+
+import (
+	"slices"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// Enforcement levels of the failures deduplicated across environments, as in the policy matrix
+const (
+	LEVEL_BLOCK     = "BLOCK"
+	LEVEL_WARNING   = "WARNING"
+	LEVEL_RECOMMEND = "RECOMMEND"
+)
+
+// dedupLevels are the levels whose failures are deduplicated, overridden and not in effect policies are
+// specific to each environment
+var dedupLevels = []struct {
+	level    string
+	policies func(models.PolicyMatrix) []models.PolicyResult
+}{
+	{LEVEL_BLOCK, func(m models.PolicyMatrix) []models.PolicyResult { return m.BlockingPolicies }},
+	{LEVEL_WARNING, func(m models.PolicyMatrix) []models.PolicyResult { return m.WarningPolicies }},
+	{LEVEL_RECOMMEND, func(m models.PolicyMatrix) []models.PolicyResult { return m.RecommendPolicies }},
+}
+
+// commonFailuresOf returns, by level, the policies failing at that level with the same messages in every evaluated
+// environment, e.g. base-level violations. None with less than two environments. The results are the ones of
+// the first environment, in its order
+func commonFailuresOf(data *models.ReportData) map[string][]models.PolicyResult {
+	common := map[string][]models.PolicyResult{}
+	envs := environmentsOf(data)
+	if len(envs) < 2 {
+		return common
+	}
+	for _, level := range dedupLevels {
+		for _, first := range level.policies(data.PolicyEvaluation.PolicyMatrix[envs[0]]) {
+			if first.IsPassing {
+				continue
+			}
+			same := true
+			for _, env := range envs[1:] {
+				i := slices.IndexFunc(level.policies(data.PolicyEvaluation.PolicyMatrix[env]), func(p models.PolicyResult) bool {
+					return p.PolicyId == first.PolicyId
+				})
+				if i < 0 || !sameFailure(first, level.policies(data.PolicyEvaluation.PolicyMatrix[env])[i]) {
+					same = false
+					break
+				}
+			}
+			if same {
+				common[level.level] = append(common[level.level], first)
+			}
+		}
+	}
+	return common
+}
+
+// sameFailure tells if two results of a policy fail with the same messages
+func sameFailure(a, b models.PolicyResult) bool {
+	return !a.IsPassing && !b.IsPassing && a.EvalError == b.EvalError && slices.Equal(a.FailMessages, b.FailMessages)
+}
+
+// IsCommonFailure tells if a policy is listed once among the common failures, instead of under each environment
+func (td *TemplateData) IsCommonFailure(policyId string) bool {
+	for _, policies := range td.commonFailures {
+		if slices.ContainsFunc(policies, func(p models.PolicyResult) bool { return p.PolicyId == policyId }) {
+			return true
+		}
+	}
+	return false
+}
+
+// UniqueFailedCount returns the number of policies failing at a level (LEVEL_*) in an environment, leaving out
+// the common failures
+func (td *TemplateData) UniqueFailedCount(env, level string) int {
+	count := 0
+	for _, l := range dedupLevels {
+		if l.level != level {
+			continue
+		}
+		for _, policy := range l.policies(td.PolicyEvaluation.PolicyMatrix[env]) {
+			if !policy.IsPassing && !td.IsCommonFailure(policy.PolicyId) {
+				count++
+			}
+		}
+	}
+	return count
+}
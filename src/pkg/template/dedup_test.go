@@ -0,0 +1,84 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+var summaries = map[string]models.EnvironmentSummaryEnv{"stg": {}, "prod": {}}
+
+func TestCommonFailures(t *testing.T) {
+	failing := func(id string, messages ...string) models.PolicyResult {
+		return models.PolicyResult{PolicyId: id, PolicyName: id, FailMessages: messages}
+	}
+	data := &models.ReportData{
+		OverlayKeys: []string{"stg", "prod"},
+		PolicyEvaluation: models.PolicyEvaluation{EnvironmentSummary: summaries, PolicyMatrix: map[string]models.PolicyMatrix{
+			"stg": {
+				BlockingPolicies: []models.PolicyResult{failing("base", "no limits"), failing("replicas", "1 replica")},
+				WarningPolicies:  []models.PolicyResult{failing("labels", "no team label"), {PolicyId: "probes", IsPassing: true}},
+			},
+			"prod": {
+				BlockingPolicies: []models.PolicyResult{failing("replicas", "2 replicas"), failing("base", "no limits")},
+				WarningPolicies:  []models.PolicyResult{{PolicyId: "probes", IsPassing: true}},
+				// failing at another level in prod is not common
+				RecommendPolicies: []models.PolicyResult{failing("labels", "no team label")},
+			},
+		}},
+	}
+	td := NewTemplateData(data)
+
+	tests := []struct {
+		policyId   string
+		wantCommon bool
+	}{
+		{"base", true},
+		{"replicas", false},
+		{"labels", false},
+		{"probes", false},
+	}
+	for _, tt := range tests {
+		if got := td.IsCommonFailure(tt.policyId); got != tt.wantCommon {
+			t.Errorf("IsCommonFailure(%q) = %v, want %v", tt.policyId, got, tt.wantCommon)
+		}
+	}
+	if len(td.CommonBlockingFailures) != 1 || len(td.CommonWarningFailures) != 0 {
+		t.Errorf("unexpected common failures %+v / %+v", td.CommonBlockingFailures, td.CommonWarningFailures)
+	}
+	if got := td.UniqueFailedCount("prod", LEVEL_BLOCK); got != 1 {
+		t.Errorf("UniqueFailedCount(prod, BLOCK) = %d, want 1", got)
+	}
+
+	// a single environment has nothing to deduplicate
+	data.OverlayKeys = []string{"stg"}
+	if td := NewTemplateData(data); td.IsCommonFailure("base") {
+		t.Error("expected no common failure with a single environment")
+	}
+}
+
+func TestCommonFailures_PolicyTemplate(t *testing.T) {
+	data := &models.ReportData{
+		OverlayKeys: []string{"stg", "prod"},
+		PolicyEvaluation: models.PolicyEvaluation{EnvironmentSummary: summaries, PolicyMatrix: map[string]models.PolicyMatrix{
+			"stg":  {BlockingPolicies: []models.PolicyResult{{PolicyId: "base", PolicyName: "Base Limits", FailMessages: []string{"no limits"}}}},
+			"prod": {BlockingPolicies: []models.PolicyResult{{PolicyId: "base", PolicyName: "Base Limits", FailMessages: []string{"no limits"}}}},
+		}},
+	}
+	for _, dir := range []string{"../../templates", "../../../sample/templates"} {
+		content, err := os.ReadFile(filepath.Join(dir, FileNamePolicyTemplate))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rendered, err := NewRenderer().RenderString(string(content), NewTemplateData(data))
+		if err != nil {
+			t.Fatalf("%s: %v", dir, err)
+		}
+		if strings.Count(rendered, "Policy `Base Limits`") != 1 || !strings.Contains(rendered, "All environments") {
+			t.Errorf("%s: expected the failure listed once for all environments, got:\n%s", dir, rendered)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package template
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// HTML_REPORT_FILE_NAME is the file of the HTML report in the output directory
+const HTML_REPORT_FILE_NAME = "report.html"
+
+//go:embed report.html.tmpl
+var htmlReportTemplate string
+
+// htmlReport is the data of the HTML report: the template data and the sections of each environment
+type htmlReport struct {
+	*TemplateData
+	Environments []htmlEnvironment
+	Matrix       []policyMatrixRow
+	MatrixEnvs   []string // display names of the columns of Matrix
+}
+
+// htmlEnvironment is the section of an environment of the HTML report, its failing policies and its diff
+type htmlEnvironment struct {
+	Name     string
+	Status   *EnvironmentStatus // nil if the environment was not evaluated
+	Failures []htmlFailure
+	Diff     models.EnvironmentDiff
+	DiffHTML htmltemplate.HTML // the text diff rendered for the html sink, empty for the artifacts and redacted diffs
+}
+
+// htmlFailure is a failing policy of an environment with its level, one of LEVEL_*
+type htmlFailure struct {
+	Level  string
+	Policy models.PolicyResult
+}
+
+// RenderHTML renders a standalone HTML report, styles included, with the summary table, the policy matrix and
+// the failures and collapsible diffs of each environment. It needs no template directory, the report is meant
+// to be shared as a CI artifact
+func (r *Renderer) RenderHTML(data *TemplateData) (string, error) {
+	tmpl, err := htmltemplate.New("report").Funcs(htmltemplate.FuncMap{"gt": func(a, b int) bool { return a > b }}).Parse(htmlReportTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse html report template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newHTMLReport(data)); err != nil {
+		return "", fmt.Errorf("failed to execute html report template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// newHTMLReport computes the sections of the report, the overlays in report order
+func newHTMLReport(data *TemplateData) htmlReport {
+	report := htmlReport{TemplateData: data}
+	envs := environmentsOf(data.ReportData)
+	report.Matrix = policyMatrixRows(data.ReportData, envs)
+	for _, env := range envs {
+		report.MatrixEnvs = append(report.MatrixEnvs, data.DisplayName(env))
+	}
+
+	statuses := make(map[string]*EnvironmentStatus)
+	for i := range data.EnvironmentStatuses {
+		statuses[data.EnvironmentStatuses[i].OverlayKey] = &data.EnvironmentStatuses[i]
+	}
+	overlayKeys := data.OverlayKeys
+	if len(overlayKeys) == 0 {
+		overlayKeys = envs
+	}
+	for _, overlayKey := range overlayKeys {
+		section := htmlEnvironment{Name: data.DisplayName(overlayKey), Status: statuses[overlayKey], Diff: data.ManifestChanges[overlayKey]}
+		for _, level := range dedupLevels {
+			for _, policy := range level.policies(data.PolicyEvaluation.PolicyMatrix[overlayKey]) {
+				if !policy.IsPassing {
+					section.Failures = append(section.Failures, htmlFailure{Level: level.level, Policy: policy})
+				}
+			}
+		}
+		if section.Diff.ContentType == models.DiffContentTypeText {
+			// the html sink escapes the diff lines
+			section.DiffHTML = htmltemplate.HTML(diff.RenderEnvironmentDiff(section.Diff, diff.DIFF_SINK_HTML))
+		}
+		report.Environments = append(report.Environments, section)
+	}
+	return report
+}
@@ -0,0 +1,45 @@
+package template
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestRenderHTML(t *testing.T) {
+	data := &models.ReportData{
+		Service:     "my-app",
+		OverlayKeys: []string{"stg", "prod"},
+		ManifestChanges: map[string]models.EnvironmentDiff{
+			"stg": {LineCount: 2, AddedLineCount: 1, DeletedLineCount: 1, ContentType: models.DiffContentTypeText,
+				Content: "@@ -1 +1 @@\n-image: web:1.0\n+image: web:<2.0>\n"},
+			"prod": {LineCount: 40, ContentType: models.DiffContentTypeGHArtifact, Content: "https://example.com/artifacts/1"},
+		},
+		PolicyEvaluation: models.PolicyEvaluation{
+			EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{
+				"stg":  {PolicyCounts: models.PolicyCounts{TotalFailed: 1, BlockingFailedCount: 1}},
+				"prod": {PolicyCounts: models.PolicyCounts{TotalSuccess: 1}},
+			},
+			PolicyMatrix: map[string]models.PolicyMatrix{
+				"stg":  {BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", FailMessages: []string{"Deployment <web> has 1 replica"}}}},
+				"prod": {BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", IsPassing: true}}},
+			},
+		},
+	}
+	rendered, err := NewRenderer().RenderHTML(NewTemplateData(data))
+	if err != nil {
+		t.Fatalf("RenderHTML() error = %v", err)
+	}
+	for _, want := range []string{
+		`<span class="status status-FAIL">FAIL</span>`,
+		`<tr><td>HA</td><td>🚫</td><td>❌ FAIL</td><td>✅ PASS</td></tr>`,
+		`<li>Deployment &lt;web&gt; has 1 replica</li>`,
+		`<span class="diff-added">+image: web:&lt;2.0&gt;</span>`,
+		`<a href="https://example.com/artifacts/1">diff artifact</a>`,
+	} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("RenderHTML() is missing %s", want)
+		}
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/diff"
 )
 
 // TemplateRenderer defines the interface for rendering markdown templates
@@ -29,6 +32,16 @@ func NewRenderer() *Renderer {
 	return &Renderer{
 		funcMap: template.FuncMap{
 			"gt": func(a, b int) bool { return a > b },
+			// renderDiff renders an EnvironmentDiff for a sink: "markdown", "html", "ansi" or "text"
+			"renderDiff": diff.RenderEnvironmentDiff,
+			// indent prefixes each line of s with n spaces, to nest blocks in list items
+			"indent": func(n int, s string) string {
+				pad := strings.Repeat(" ", n)
+				return pad + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n"+pad) + "\n"
+			},
+			// summaryTable and policyMatrixTable return the policy tables of the report, columns in environment order
+			"summaryTable":      func(data *TemplateData) string { return data.SummaryTable },
+			"policyMatrixTable": func(data *TemplateData) string { return data.PolicyMatrixTable },
 		},
 	}
 }
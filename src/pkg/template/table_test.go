@@ -0,0 +1,44 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+func TestTable_Markdown(t *testing.T) {
+	got := NewTable("Name", "Value").AddRow("a|b", "line 1\nline 2").AddRow("only").Markdown()
+	want := "| Name | Value |\n|---|---|\n| a\\|b | line 1<br>line 2 |\n| only |  |\n"
+	if got != want {
+		t.Errorf("Markdown() = %q, want %q", got, want)
+	}
+}
+
+func TestPolicyMatrixTable(t *testing.T) {
+	data := &models.ReportData{
+		OverlayKeys: []string{"stg", "prod", "dev"},
+		PolicyEvaluation: models.PolicyEvaluation{
+			EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{"stg": {}, "prod": {}},
+			PolicyMatrix: map[string]models.PolicyMatrix{
+				"stg": {
+					BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", IsPassing: true}},
+					WarningPolicies:  []models.PolicyResult{{PolicyId: "img", PolicyName: "Images", Relaxed: true}},
+				},
+				"prod": {
+					BlockingPolicies: []models.PolicyResult{
+						{PolicyId: "img", PolicyName: "Images", EvalError: "boom"},
+						{PolicyId: "ha", PolicyName: "HA", ExternalLink: "https://docs/ha"},
+					},
+					RecommendPolicies: []models.PolicyResult{{PolicyId: "labels", PolicyName: "Labels", IsPassing: true}},
+				},
+			},
+		},
+	}
+	want := "| Policy Name | Level | `stg` | `prod` |\n|---|---|---|---|\n" +
+		"| HA | 🚫 | ✅ PASS | ❌ FAIL |\n" +
+		"| Images | 🚫 | ❌ FAIL (relaxed) | 💥 ERROR |\n" +
+		"| Labels | 💡 | ➖ | ✅ PASS |\n"
+	if got := PolicyMatrixTable(data); got != want {
+		t.Errorf("PolicyMatrixTable() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package template
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/models"
+)
+
+// policyLevels are the enforcement levels of the policy matrix, in report order, with their icon
+var policyLevels = []struct {
+	icon     string
+	policies func(models.PolicyMatrix) []models.PolicyResult
+}{
+	{"🚫", func(m models.PolicyMatrix) []models.PolicyResult { return m.BlockingPolicies }},
+	{"⚠️", func(m models.PolicyMatrix) []models.PolicyResult { return m.WarningPolicies }},
+	{"💡", func(m models.PolicyMatrix) []models.PolicyResult { return m.RecommendPolicies }},
+	{"⏭️", func(m models.PolicyMatrix) []models.PolicyResult { return m.OverriddenPolicies }},
+	{"⏭️", func(m models.PolicyMatrix) []models.PolicyResult { return m.NotInEffectPolicies }},
+}
+
+// environmentsOf returns the evaluated overlay keys of the report, in report order (sorted if unknown)
+func environmentsOf(data *models.ReportData) []string {
+	var keys []string
+	for _, key := range data.OverlayKeys {
+		if _, ok := data.PolicyEvaluation.EnvironmentSummary[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		return keys
+	}
+	for key := range data.PolicyEvaluation.EnvironmentSummary {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SummaryTable renders the policy counts of each environment, one row per environment in report order
+func SummaryTable(data *models.ReportData) string {
+	table := NewTable("**Environments**", "**Success**", "**Omitted**", "**Failed**",
+		"**F(Blocking)**", "**F(Warning)**", "**F(Recommend)**")
+	for _, env := range environmentsOf(data) {
+		counts := data.PolicyEvaluation.EnvironmentSummary[env].PolicyCounts
+		table.AddRow(
+			fmt.Sprintf("`%s`", data.DisplayName(env)),
+			fmt.Sprintf("`%d`✅", counts.TotalSuccess),
+			fmt.Sprintf("`%d`⏭️", counts.TotalOmitted),
+			fmt.Sprintf("`%d`❌", counts.TotalFailed),
+			fmt.Sprintf("`%d`🚫", counts.BlockingFailedCount),
+			fmt.Sprintf("`%d`⚠️", counts.WarningFailedCount),
+			fmt.Sprintf("`%d`💡", counts.RecommendFailedCount),
+		)
+	}
+	return table.Markdown()
+}
+
+// PolicyMatrixTable renders the status of each policy (rows, by enforcement level then config order) in each
+// environment (columns, in report order). The level is the one of the first environment having the policy,
+// a policy missing from an environment shows ➖
+func PolicyMatrixTable(data *models.ReportData) string {
+	envs := environmentsOf(data)
+	header := []string{"Policy Name", "Level"}
+	for _, env := range envs {
+		header = append(header, fmt.Sprintf("`%s`", data.DisplayName(env)))
+	}
+	table := NewTable(header...)
+	for _, row := range policyMatrixRows(data, envs) {
+		name := row.Policy.PolicyName
+		if row.Policy.ExternalLink != "" {
+			name = fmt.Sprintf("[%s](%s)", row.Policy.PolicyName, row.Policy.ExternalLink)
+		}
+		table.AddRow(append([]string{name, row.Icon}, row.Statuses...)...)
+	}
+	return table.Markdown()
+}
+
+// policyMatrixRow is a row of the policy matrix: a policy, the icon of its level and its status cell in each
+// environment
+type policyMatrixRow struct {
+	Policy   models.PolicyResult
+	Icon     string
+	Statuses []string
+}
+
+// policyMatrixRows returns the rows of the policy matrix of the environments, by enforcement level then config order
+func policyMatrixRows(data *models.ReportData, envs []string) []policyMatrixRow {
+	var rows []policyMatrixRow
+	seen := make(map[string]bool)
+	for _, level := range policyLevels {
+		for _, env := range envs {
+			for _, policy := range level.policies(data.PolicyEvaluation.PolicyMatrix[env]) {
+				if seen[policy.PolicyId] {
+					continue
+				}
+				seen[policy.PolicyId] = true
+
+				row := policyMatrixRow{Policy: policy, Icon: level.icon}
+				for _, env := range envs {
+					row.Statuses = append(row.Statuses, policyStatusIn(data.PolicyEvaluation.PolicyMatrix[env], policy.PolicyId))
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// policyStatusIn returns the status cell of a policy in the matrix of an environment
+func policyStatusIn(matrix models.PolicyMatrix, policyId string) string {
+	for _, level := range policyLevels {
+		for _, policy := range level.policies(matrix) {
+			if policy.PolicyId != policyId {
+				continue
+			}
+			status := "❌ FAIL"
+			switch {
+			case policy.IsPassing:
+				status = "✅ PASS"
+			case policy.EvalError != "":
+				status = "💥 ERROR"
+			}
+			if policy.Relaxed {
+				status += " (relaxed)"
+			}
+			if policy.EscalatedFrom != "" {
+				status += " (escalated)"
+			}
+			return status
+		}
+	}
+	return "➖"
+}
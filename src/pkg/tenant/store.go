@@ -0,0 +1,108 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RELOAD_INTERVAL is how often a watched config directory is checked for changes
+const RELOAD_INTERVAL = 10 * time.Second
+
+// Store holds the current tenants of a config directory, reloaded when its files change
+// It is safe for concurrent use
+type Store struct {
+	Dir      string
+	Validate func(*Tenant) error // checks the settings of each tenant on top, nil for none
+
+	mu          sync.RWMutex
+	config      *Config
+	fingerprint string
+}
+
+// NewStore loads the tenants of a directory, it fails if they are invalid
+func NewStore(dir string, validate func(*Tenant) error) (*Store, error) {
+	s := &Store{Dir: dir, Validate: validate}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Current returns the tenants last loaded
+func (s *Store) Current() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config
+}
+
+// Reload loads the tenants again if the files of the directory changed, reports whether they were reloaded
+// Invalid tenants are not loaded, the current ones stay in use
+func (s *Store) Reload() (bool, error) {
+	fingerprint, err := fingerprintOf(s.Dir)
+	if err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	unchanged := s.config != nil && fingerprint == s.fingerprint
+	s.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	config, err := Load(s.Dir, s.Validate)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config, s.fingerprint = config, fingerprint
+	return true, nil
+}
+
+// Watch reloads the tenants every interval until ctx is done, a failed reload is logged
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := s.Reload()
+		if err != nil {
+			logger.WithField("dir", s.Dir).WithField("error", err).Error("Failed to reload tenants, keeping the current ones")
+			continue
+		}
+		if reloaded {
+			logger.WithField("dir", s.Dir).WithField("tenants", len(s.Current().Tenants)).Info("Reloaded tenants")
+		}
+	}
+}
+
+// fingerprintOf identifies the state of the tenant files of a directory by their names, sizes and modification times
+func fingerprintOf(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read tenants dir: %w", err)
+	}
+	var parts []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", filepath.Join(dir, entry.Name()), err)
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d:%d", entry.Name(), info.Size(), info.ModTime().UnixNano()))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "\n"), nil
+}
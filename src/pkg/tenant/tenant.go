@@ -0,0 +1,142 @@
+// Package tenant loads the tenants of the server mode: the settings of the repositories of a team (credentials,
+// policies, templates, notification targets), one YAML file per tenant in a config directory
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+var logger = log.WithField("package", "tenant")
+
+// FILE_EXTENSIONS are the extensions of the tenant files of the config directory, the other files are ignored
+var FILE_EXTENSIONS = []string{".yaml", ".yml"}
+
+// Tenant is the settings of the repositories matching its patterns, the file name (without extension) is its name
+type Tenant struct {
+	Name string `yaml:"-"`
+	// Repos are the path.Match patterns of the "owner/repo" of its repositories, e.g. "acme/*"
+	Repos []string `yaml:"repos"`
+	// TokenEnv is the environment variable of its GitHub token, GitHubApp authenticates as an app installation
+	// instead. Without either the runs use the token of the server
+	TokenEnv  string                 `yaml:"tokenEnv,omitempty"`
+	GitHubApp *github.AppCredentials `yaml:"githubApp,omitempty"`
+	// PoliciesPath and TemplatesPath replace the ones of the server flags when set, a directory or a git or OCI
+	// source (see source.Parse)
+	PoliciesPath  string `yaml:"policiesPath,omitempty"`
+	TemplatesPath string `yaml:"templatesPath,omitempty"`
+	// Notify replaces the notification flags of the server when set
+	Notify *Notify `yaml:"notify,omitempty"`
+}
+
+// Notify is the notification targets of a tenant
+type Notify struct {
+	Slack    bool     `yaml:"slack,omitempty"`    // post to the slackChannel of the service.yaml of the services
+	Webhooks []string `yaml:"webhooks,omitempty"` // POST to these URLs
+	On       string   `yaml:"on,omitempty"`       // failure (default) or always
+}
+
+// Token returns the GitHub token of the tenant, empty for the token of the server
+func (t *Tenant) Token(ctx context.Context) (string, error) {
+	if t.GitHubApp != nil {
+		return t.GitHubApp.InstallationToken(ctx)
+	}
+	if t.TokenEnv == "" {
+		return "", nil
+	}
+	token := os.Getenv(t.TokenEnv)
+	if token == "" {
+		return "", fmt.Errorf("GitHub token of tenant %s not found, %s is not set", t.Name, t.TokenEnv)
+	}
+	return token, nil
+}
+
+// Matches reports whether a repository ("owner/repo") is of the tenant, case-insensitively as GitHub names are
+func (t *Tenant) Matches(repo string) bool {
+	for _, pattern := range t.Repos {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(repo)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Config is the tenants of a config directory, in file name order
+type Config struct {
+	Tenants []*Tenant
+}
+
+// Match returns the tenant of a repository, the first one in file name order matching it, nil if none
+func (c *Config) Match(repo string) *Tenant {
+	for _, t := range c.Tenants {
+		if t.Matches(repo) {
+			return t
+		}
+	}
+	return nil
+}
+
+// Load reads and validates the tenant files of a directory, validate checks the settings of each tenant
+// on top (nil for none)
+func Load(dir string, validate func(*Tenant) error) (*Config, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenants dir: %w", err)
+	}
+	config := &Config{}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || !slices.Contains(FILE_EXTENSIONS, ext) {
+			continue
+		}
+		filePath := filepath.Join(dir, entry.Name())
+		t, err := loadTenant(filePath, strings.TrimSuffix(entry.Name(), ext))
+		if err == nil && validate != nil {
+			err = validate(t)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tenant %s: %w", filePath, err)
+		}
+		config.Tenants = append(config.Tenants, t)
+	}
+	if len(config.Tenants) == 0 {
+		return nil, fmt.Errorf("no tenant found in %s, expected %v files", dir, FILE_EXTENSIONS)
+	}
+	return config, nil
+}
+
+func loadTenant(filePath, name string) (*Tenant, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tenant{}
+	if err := yaml.UnmarshalStrict(content, t); err != nil {
+		return nil, fmt.Errorf("failed to parse: %w", err)
+	}
+	t.Name = name
+	if len(t.Repos) == 0 {
+		return nil, fmt.Errorf("repos must list the repository patterns of the tenant")
+	}
+	for _, pattern := range t.Repos {
+		if _, err := path.Match(pattern, ""); err != nil || strings.Count(pattern, "/") != 1 {
+			return nil, fmt.Errorf("repos: invalid pattern %q, expected owner/repo", pattern)
+		}
+	}
+	if t.TokenEnv != "" && t.GitHubApp != nil {
+		return nil, fmt.Errorf("tokenEnv and githubApp are exclusive")
+	}
+	if app := t.GitHubApp; app != nil && (app.AppID <= 0 || app.InstallationID <= 0 || app.PrivateKeyPath == "") {
+		return nil, fmt.Errorf("githubApp requires appId, installationId and privateKeyPath")
+	}
+	return t, nil
+}
@@ -0,0 +1,112 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTenant(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadAndMatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTenant(t, dir, "a-payments.yaml", "repos: [acme/payments-*]\ntokenEnv: PAYMENTS_GH_TOKEN\npoliciesPath: /etc/payments/policies\n")
+	writeTenant(t, dir, "b-acme.yml", "repos: [acme/*]\nnotify:\n  slack: true\n")
+	writeTenant(t, dir, "README.md", "not a tenant")
+
+	config, err := Load(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		repo string
+		want string
+	}{
+		{"acme/payments-api", "a-payments"},
+		{"Acme/Payments-API", "a-payments"},
+		{"acme/web", "b-acme"},
+		{"other/web", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if tenant := config.Match(tt.repo); tenant != nil {
+			got = tenant.Name
+		}
+		if got != tt.want {
+			t.Errorf("Match(%s) = %q, want %q", tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no repos", "tokenEnv: X\n"},
+		{"bad pattern", "repos: [acme]\n"},
+		{"unknown field", "repos: [acme/*]\npolicies: x\n"},
+		{"exclusive credentials", "repos: [acme/*]\ntokenEnv: X\ngithubApp: {appId: 1, installationId: 2, privateKeyPath: k.pem}\n"},
+		{"incomplete app", "repos: [acme/*]\ngithubApp: {appId: 1}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTenant(t, dir, "acme.yaml", tt.content)
+			if _, err := Load(dir, nil); err == nil {
+				t.Error("Load() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	writeTenant(t, dir, "acme.yaml", "repos: [acme/*]\n")
+	store, err := NewStore(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := store.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() of unchanged files = %v, %v, want false", reloaded, err)
+	}
+
+	// an invalid change keeps the current tenants
+	writeTenant(t, dir, "acme.yaml", "repos: [acme]\n")
+	bump(t, dir, "acme.yaml")
+	if _, err := store.Reload(); err == nil {
+		t.Error("Reload() of an invalid tenant succeeded")
+	}
+	if store.Current().Match("acme/web") == nil {
+		t.Error("invalid reload dropped the current tenants")
+	}
+
+	writeTenant(t, dir, "acme.yaml", "repos: [globex/*]\n")
+	bump(t, dir, "acme.yaml")
+	if reloaded, err := store.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload() = %v, %v, want reloaded", reloaded, err)
+	}
+	if store.Current().Match("acme/web") != nil || store.Current().Match("globex/web") == nil {
+		t.Error("Reload() did not apply the new tenant")
+	}
+}
+
+// bump moves the modification time of a file forward, for filesystems of coarse timestamps
+func bump(t *testing.T, dir, name string) {
+	t.Helper()
+	filePath := filepath.Join(dir, name)
+	info, err := os.Stat(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(filePath, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,128 @@
+package trace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	EXEC_SPAN_PREFIX = "Exec."
+	REDACTED         = "REDACTED"
+
+	ATTR_EXEC_ARGV         = "exec.argv"
+	ATTR_EXEC_EXIT_CODE    = "exec.exit_code"
+	ATTR_EXEC_STDOUT_BYTES = "exec.stdout_bytes"
+	ATTR_EXEC_STDERR_BYTES = "exec.stderr_bytes"
+)
+
+// secretArgPattern matches key=value arguments whose value is a credential (e.g. --token=abc, PASSWORD=abc)
+var secretArgPattern = regexp.MustCompile(`(?i)^(-*[A-Za-z0-9_.-]*(?:token|secret|password|passwd|credential|api[_-]?key)[A-Za-z0-9_.-]*=).+$`)
+
+// credentialsInURLPattern matches the user info of the URLs quoted in free text, e.g. in git errors
+var credentialsInURLPattern = regexp.MustCompile(`://[^/@\s]+@`)
+
+// ExecSpan is the child span of an external command
+type ExecSpan struct {
+	span trace.Span
+}
+
+// StartExecSpan starts the span of an external command, named "Exec.<command>" with its redacted argv
+func StartExecSpan(ctx context.Context, name string, args ...string) (context.Context, *ExecSpan) {
+	ctx, span := StartSpan(ctx, EXEC_SPAN_PREFIX+filepath.Base(name))
+	span.SetAttributes(attribute.StringSlice(ATTR_EXEC_ARGV, RedactArgs(append([]string{name}, args...))))
+	return ctx, &ExecSpan{span: span}
+}
+
+// End records the exit code (-1 if the process didn't run) and the output sizes, and the error if any
+func (s *ExecSpan) End(exitCode int, stdoutBytes, stderrBytes int64, err error) {
+	s.span.SetAttributes(
+		attribute.Int(ATTR_EXEC_EXIT_CODE, exitCode),
+		attribute.Int64(ATTR_EXEC_STDOUT_BYTES, stdoutBytes),
+		attribute.Int64(ATTR_EXEC_STDERR_BYTES, stderrBytes),
+	)
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+// RunCommand runs the command within an exec span, counting what it writes to cmd.Stdout and cmd.Stderr
+// Set both to the same writer for combined output, it is then counted as stdout
+func RunCommand(ctx context.Context, cmd *exec.Cmd) error {
+	_, span := StartExecSpan(ctx, cmd.Args[0], cmd.Args[1:]...)
+	stdout, stderr := &countingWriter{w: cmd.Stdout}, &countingWriter{w: cmd.Stderr}
+	if cmd.Stdout != nil {
+		if cmd.Stdout == cmd.Stderr {
+			cmd.Stderr = stdout
+		} else if cmd.Stderr != nil {
+			cmd.Stderr = stderr
+		}
+		cmd.Stdout = stdout
+	} else if cmd.Stderr != nil {
+		cmd.Stderr = stderr
+	}
+
+	err := cmd.Run()
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	var spanErr error
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		spanErr = err // a non-zero exit code is for the caller to judge, and recorded as such
+	}
+	span.End(exitCode, stdout.n, stderr.n, spanErr)
+	return err
+}
+
+// RedactArgs returns a copy of argv with credentials replaced: URL user info and secret key=value arguments
+func RedactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = redactArg(arg)
+	}
+	return redacted
+}
+
+// RedactText returns the text with the user info of the URLs it quotes replaced, e.g. stderr of an external command
+func RedactText(text string) string {
+	return credentialsInURLPattern.ReplaceAllString(text, "://"+REDACTED+"@")
+}
+
+func redactArg(arg string) string {
+	if strings.Contains(arg, "://") {
+		if u, err := url.Parse(arg); err == nil && u.User != nil {
+			u.User = url.User(REDACTED)
+			return u.String()
+		}
+	}
+	if m := secretArgPattern.FindStringSubmatch(arg); m != nil {
+		return fmt.Sprintf("%s%s", m[1], REDACTED)
+	}
+	return arg
+}
+
+// countingWriter forwards writes and counts the bytes written
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
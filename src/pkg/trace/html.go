@@ -0,0 +1,154 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WATERFALL_TOP_STAGES is the number of slowest stages listed above the waterfall
+const WATERFALL_TOP_STAGES = 5
+
+// waterfallRow is a span of the waterfall, positioned in percents of the run
+type waterfallRow struct {
+	Name       string
+	Depth      int
+	OffsetPct  float64
+	WidthPct   float64
+	DurationMs float64
+	Details    string // attributes, one per line, shown on hover
+}
+
+// waterfallStage is one of the slowest spans, with its share of the run
+type waterfallStage struct {
+	Name       string
+	DurationMs float64
+	SharePct   float64
+}
+
+type waterfall struct {
+	Timestamp       string
+	TotalDurationMs float64
+	Stages          []waterfallStage
+	Rows            []waterfallRow
+}
+
+var waterfallTemplate = template.Must(template.New("waterfall").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>gitops-kustomzchk performance report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #24292f; }
+table { border-collapse: collapse; width: 100%; }
+td, th { padding: 2px 8px; font-size: 13px; white-space: nowrap; text-align: left; }
+tr:hover { background: #f6f8fa; }
+.track { position: relative; width: 100%; min-width: 400px; height: 14px; }
+.bar { position: absolute; height: 14px; min-width: 1px; border-radius: 2px; }
+.d0 { background: #0969da; } .d1 { background: #1f883d; } .d2 { background: #bf8700; } .d3 { background: #8250df; } .dn { background: #cf222e; }
+.ms { text-align: right; font-variant-numeric: tabular-nums; }
+</style>
+</head>
+<body>
+<h1>Performance report</h1>
+<p>{{printf "%.1f" .TotalDurationMs}} ms in total, exported at {{.Timestamp}}.</p>
+<h2>Slowest stages</h2>
+<table>
+<tr><th>Stage</th><th class="ms">Duration (ms)</th><th class="ms">Share</th></tr>
+{{range .Stages}}<tr><td>{{.Name}}</td><td class="ms">{{printf "%.1f" .DurationMs}}</td><td class="ms">{{printf "%.0f" .SharePct}}%</td></tr>
+{{end}}</table>
+<h2>Waterfall</h2>
+<table>
+<tr><th>Span</th><th class="ms">Duration (ms)</th><th style="width:100%">Timeline</th></tr>
+{{range .Rows}}<tr title="{{.Details}}"><td style="padding-left:{{.Depth}}em">{{.Name}}</td><td class="ms">{{printf "%.1f" .DurationMs}}</td><td><div class="track"><div class="bar {{if lt .Depth 4}}d{{.Depth}}{{else}}dn{{end}}" style="left:{{printf "%.3f" .OffsetPct}}%;width:{{printf "%.3f" .WidthPct}}%"></div></div></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// RenderWaterfall renders the performance report as a standalone HTML page: the slowest stages, then every
+// span on a timeline, nested by parent
+func RenderWaterfall(report PerformanceReport) ([]byte, error) {
+	start, end := reportBounds(report.Spans)
+	w := waterfall{Timestamp: report.Timestamp, TotalDurationMs: report.TotalDurationMs}
+	total := float64(end.Sub(start).Microseconds()) / 1000.0
+
+	var stages []waterfallStage
+	var walk func(spans []SpanInfo, depth int)
+	walk = func(spans []SpanInfo, depth int) {
+		spans = append([]SpanInfo(nil), spans...)
+		sort.SliceStable(spans, func(i, j int) bool { return startOf(spans[i]).Before(startOf(spans[j])) })
+		for _, span := range spans {
+			row := waterfallRow{Name: span.Name, Depth: depth, DurationMs: span.DurationMs, Details: detailsOf(span)}
+			if total > 0 {
+				row.OffsetPct = float64(startOf(span).Sub(start).Microseconds()) / 1000.0 / total * 100
+				row.WidthPct = span.DurationMs / total * 100
+			}
+			w.Rows = append(w.Rows, row)
+			if depth == 1 || (depth == 0 && len(span.Children) == 0) {
+				stages = append(stages, waterfallStage{Name: span.Name, DurationMs: span.DurationMs})
+			}
+			walk(span.Children, depth+1)
+		}
+	}
+	walk(report.Spans, 0)
+
+	sort.SliceStable(stages, func(i, j int) bool { return stages[i].DurationMs > stages[j].DurationMs })
+	if len(stages) > WATERFALL_TOP_STAGES {
+		stages = stages[:WATERFALL_TOP_STAGES]
+	}
+	for i := range stages {
+		if total > 0 {
+			stages[i].SharePct = stages[i].DurationMs / total * 100
+		}
+	}
+	w.Stages = stages
+
+	var buf bytes.Buffer
+	if err := waterfallTemplate.Execute(&buf, w); err != nil {
+		return nil, fmt.Errorf("failed to render waterfall: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// startOf parses the start time of a span, zero if invalid
+func startOf(span SpanInfo) time.Time {
+	start, _ := time.Parse(time.RFC3339Nano, span.Start)
+	return start
+}
+
+// reportBounds returns the earliest start and latest end of the root spans
+func reportBounds(spans []SpanInfo) (time.Time, time.Time) {
+	var start, end time.Time
+	for _, span := range spans {
+		spanStart, errStart := time.Parse(time.RFC3339Nano, span.Start)
+		spanEnd, errEnd := time.Parse(time.RFC3339Nano, span.End)
+		if errStart != nil || errEnd != nil {
+			continue
+		}
+		if start.IsZero() || spanStart.Before(start) {
+			start = spanStart
+		}
+		if spanEnd.After(end) {
+			end = spanEnd
+		}
+	}
+	return start, end
+}
+
+// detailsOf formats the span attributes, sorted by key
+func detailsOf(span SpanInfo) string {
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		lines[i] = fmt.Sprintf("%s: %v", key, span.Attributes[key])
+	}
+	return strings.Join(lines, "\n")
+}
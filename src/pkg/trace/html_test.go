@@ -0,0 +1,40 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderWaterfall(t *testing.T) {
+	report := PerformanceReport{
+		TotalDurationMs: 1000,
+		Spans: []SpanInfo{{
+			Name: "Process", DurationMs: 1000, Start: "2026-01-01T00:00:00Z", End: "2026-01-01T00:00:01Z",
+			Children: []SpanInfo{
+				{Name: "EvaluatePolicies", DurationMs: 700, Start: "2026-01-01T00:00:00.3Z", End: "2026-01-01T00:00:01Z",
+					Children: []SpanInfo{{Name: "Exec.conftest", DurationMs: 600, Start: "2026-01-01T00:00:00.35Z", End: "2026-01-01T00:00:00.95Z",
+						Attributes: map[string]interface{}{ATTR_EXEC_EXIT_CODE: 1}}}},
+				{Name: "BuildManifests", DurationMs: 250, Start: "2026-01-01T00:00:00Z", End: "2026-01-01T00:00:00.25Z"},
+			},
+		}},
+	}
+	page, err := RenderWaterfall(report)
+	if err != nil {
+		t.Fatalf("RenderWaterfall() error = %v", err)
+	}
+	html := string(page)
+
+	// Stages are sorted by duration, spans by start time
+	if i, j := strings.Index(html, "<td>EvaluatePolicies</td><td class=\"ms\">700.0</td><td class=\"ms\">70%</td>"), strings.Index(html, "<td>BuildManifests</td>"); i < 0 || j < i {
+		t.Errorf("RenderWaterfall() stages not sorted by duration:\n%s", html)
+	}
+	if i, j := strings.Index(html, "padding-left:1em\">BuildManifests"), strings.Index(html, "padding-left:1em\">EvaluatePolicies"); i < 0 || j < i {
+		t.Errorf("RenderWaterfall() rows not sorted by start:\n%s", html)
+	}
+	if !strings.Contains(html, "left:35.000%;width:60.000%") {
+		t.Errorf("RenderWaterfall() exec bar not positioned at 35%% for 60%%:\n%s", html)
+	}
+	if !strings.Contains(html, `title="exec.exit_code: 1"`) {
+		t.Errorf("RenderWaterfall() missing the span attributes:\n%s", html)
+	}
+}
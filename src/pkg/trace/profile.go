@@ -0,0 +1,69 @@
+package trace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+)
+
+const (
+	CPU_PROFILE_FILENAME = "cpu.pprof"
+	MEM_PROFILE_FILENAME = "mem.pprof"
+)
+
+// StartProfiling starts the CPU profile and/or schedules the heap profile of the run, written to outDir
+// The returned stop function writes them, open them with `go tool pprof <file>`
+func StartProfiling(outDir string, cpu, mem bool) (func(), error) {
+	if !cpu && !mem {
+		return func() {}, nil
+	}
+	if err := perm.MkdirAll(outDir); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	var cpuFile *os.File
+	if cpu {
+		var err error
+		cpuFile, err = perm.Create(filepath.Join(outDir, CPU_PROFILE_FILENAME))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			_ = cpuFile.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+	}
+
+	stop := func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			_ = cpuFile.Close()
+		}
+		if mem {
+			if err := writeHeapProfile(filepath.Join(outDir, MEM_PROFILE_FILENAME)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+	}
+	return stop, nil
+}
+
+// writeHeapProfile writes the allocations of the run (alloc_space shows the blowups, inuse_space what is left)
+func writeHeapProfile(path string) error {
+	f, err := perm.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create memory profile: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	runtime.GC() // up-to-date in-use statistics
+	if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+		return fmt.Errorf("failed to write memory profile: %w", err)
+	}
+	return nil
+}
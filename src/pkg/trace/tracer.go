@@ -4,12 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"sort"
 	"time"
 
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
@@ -32,14 +33,17 @@ type spanRecord struct {
 	End      time.Time
 	ParentID string
 	SpanID   string
+
+	Attributes map[string]interface{}
 }
 
 type SpanInfo struct {
-	Name       string     `json:"name"`
-	DurationMs float64    `json:"durationMs"`
-	Start      string     `json:"start"`
-	End        string     `json:"end"`
-	Children   []SpanInfo `json:"children,omitempty"`
+	Name       string                 `json:"name"`
+	DurationMs float64                `json:"durationMs"`
+	Start      string                 `json:"start"`
+	End        string                 `json:"end"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"` // e.g. argv, exit code and output sizes of exec spans
+	Children   []SpanInfo             `json:"children,omitempty"`
 }
 
 type PerformanceReport struct {
@@ -124,10 +128,30 @@ func (p *recordingSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
 			End:      s.EndTime(),
 			SpanID:   s.SpanContext().SpanID().String(),
 			ParentID: parentID,
+
+			Attributes: attributesOf(s),
 		})
 	}
 }
 
+// attributesOf returns the span attributes for the report, nil if none
+func attributesOf(s sdktrace.ReadOnlySpan) map[string]interface{} {
+	var attributes map[string]interface{}
+	for _, kv := range s.Attributes() {
+		if attributes == nil {
+			attributes = make(map[string]interface{})
+		}
+		attributes[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	if s.Status().Code == codes.Error {
+		if attributes == nil {
+			attributes = make(map[string]interface{})
+		}
+		attributes["error"] = s.Status().Description
+	}
+	return attributes
+}
+
 func (p *recordingSpanProcessor) Shutdown(ctx context.Context) error   { return nil }
 func (p *recordingSpanProcessor) ForceFlush(ctx context.Context) error { return nil }
 
@@ -158,7 +182,7 @@ func ExportReport() error {
 	}
 
 	// Ensure output directory exists
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
+	if err := perm.MkdirAll(outputDir); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
@@ -169,7 +193,16 @@ func ExportReport() error {
 		return fmt.Errorf("failed to marshal report: %w", err)
 	}
 
-	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+	if err := perm.WriteFile(reportPath, data); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	// Write the waterfall next to it, to be opened from the run artifacts
+	page, err := RenderWaterfall(report)
+	if err != nil {
+		return err
+	}
+	if err := perm.WriteFile(filepath.Join(outputDir, "performance-report.html"), page); err != nil {
 		return fmt.Errorf("failed to write report: %w", err)
 	}
 
@@ -189,6 +222,7 @@ func buildHierarchy(records []spanRecord) []SpanInfo {
 			DurationMs: float64(record.Duration.Microseconds()) / 1000.0,
 			Start:      record.Start.Format(time.RFC3339Nano),
 			End:        record.End.Format(time.RFC3339Nano),
+			Attributes: record.Attributes,
 			Children:   []SpanInfo{},
 		}
 		spanMap[record.SpanID] = &spanInfo
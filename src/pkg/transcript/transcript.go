@@ -0,0 +1,96 @@
+// Package transcript records the mutations a run performs on the SCM (comments, labels, check runs, pushes...)
+// for --publish-transcript, an audit of what was published and the exact bodies sent
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/perm"
+)
+
+// FILE_NAME is the file of the transcript in the output directory
+const FILE_NAME = "transcript.json"
+
+// Operations of the SCM mutations
+const (
+	OP_CREATE_COMMENT        = "create-comment"
+	OP_UPDATE_COMMENT        = "update-comment"
+	OP_CREATE_REVIEW_COMMENT = "create-review-comment"
+	OP_CREATE_CHECK_RUN      = "create-check-run"
+	OP_ADD_LABEL             = "add-label"
+	OP_REMOVE_LABEL          = "remove-label"
+	OP_REQUEST_REVIEWERS     = "request-reviewers"
+	OP_ENABLE_AUTO_MERGE     = "enable-auto-merge"
+	OP_DISABLE_AUTO_MERGE    = "disable-auto-merge"
+	OP_PUSH                  = "push"
+	OP_CREATE_PULL_REQUEST   = "create-pull-request"
+)
+
+// Entry is a mutation of the SCM, recorded whether it succeeded or not
+type Entry struct {
+	Time      time.Time         `json:"time"`
+	SCM       string            `json:"scm"`       // "github", "gitlab" or "bitbucket"
+	Operation string            `json:"operation"` // OP_* constant
+	Target    string            `json:"target"`    // e.g. "org/repo#12", the pull request or the comment mutated
+	Body      string            `json:"body,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"` // the other parameters, e.g. the label or the conclusion
+	Error     string            `json:"error,omitempty"`
+}
+
+// Transcript is the list of the mutations of a run, safe for concurrent use.
+// A nil Transcript records nothing, for the clients to record unconditionally
+type Transcript struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// New creates an empty transcript
+func New() *Transcript {
+	return &Transcript{}
+}
+
+// Record appends a mutation with its outcome, err is nil when it succeeded
+func (t *Transcript) Record(entry Entry, err error) {
+	if t == nil {
+		return
+	}
+	entry.Time = time.Now()
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = append(t.entries, entry)
+}
+
+// Entries returns the mutations recorded, in order
+func (t *Transcript) Entries() []Entry {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Entry{}, t.entries...)
+}
+
+// Write writes the transcript as indented JSON to FILE_NAME in dir, returns the path of the file
+func (t *Transcript) Write(dir string) (string, error) {
+	content, err := json.MarshalIndent(struct {
+		Entries []Entry `json:"entries"`
+	}{Entries: t.Entries()}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode transcript: %w", err)
+	}
+	if err := perm.MkdirAll(dir); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	filePath := filepath.Join(dir, FILE_NAME)
+	if err := perm.WriteFile(filePath, append(content, '\n')); err != nil {
+		return "", fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return filePath, nil
+}
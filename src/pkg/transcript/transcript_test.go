@@ -0,0 +1,42 @@
+package transcript
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestRecordAndWrite(t *testing.T) {
+	var disabled *Transcript
+	disabled.Record(Entry{Operation: OP_ADD_LABEL}, nil)
+	if len(disabled.Entries()) != 0 {
+		t.Errorf("nil transcript recorded %v", disabled.Entries())
+	}
+
+	tr := New()
+	tr.Record(Entry{SCM: "github", Operation: OP_CREATE_COMMENT, Target: "org/repo#1", Body: "report"}, nil)
+	tr.Record(Entry{SCM: "github", Operation: OP_ADD_LABEL, Target: "org/repo#1", Fields: map[string]string{"label": "automerge"}},
+		errors.New("403 Forbidden"))
+
+	filePath, err := tr.Write(t.TempDir())
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Entries []Entry `json:"entries"`
+	}
+	if err := json.Unmarshal(content, &got); err != nil {
+		t.Fatalf("transcript is not JSON: %v", err)
+	}
+	if len(got.Entries) != 2 || got.Entries[0].Body != "report" || got.Entries[0].Error != "" || got.Entries[0].Time.IsZero() {
+		t.Errorf("entries = %+v", got.Entries)
+	}
+	if got.Entries[1].Error != "403 Forbidden" || got.Entries[1].Fields["label"] != "automerge" {
+		t.Errorf("failed mutation = %+v, want its error and fields", got.Entries[1])
+	}
+}
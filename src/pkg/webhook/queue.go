@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"sync"
+)
+
+// Queue runs the events with at most a number of concurrent runs and one run at a time per pull request.
+// The events of a pull request received during its run are coalesced into a single next run
+type Queue struct {
+	run func(context.Context, Event)
+	sem chan struct{}
+
+	mu      sync.Mutex
+	running map[string]bool
+	pending map[string]Event
+	wg      sync.WaitGroup
+}
+
+// NewQueue creates a queue of run, at most maxConcurrent at a time (1 if lower)
+func NewQueue(maxConcurrent int, run func(context.Context, Event)) *Queue {
+	return &Queue{
+		run:     run,
+		sem:     make(chan struct{}, max(maxConcurrent, 1)),
+		running: map[string]bool{},
+		pending: map[string]Event{},
+	}
+}
+
+// Add runs the event, after the run in progress of its pull request if any
+func (q *Queue) Add(ctx context.Context, event Event) {
+	key := event.Key()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running[key] {
+		q.pending[key] = coalesce(q.pending[key], event)
+		return
+	}
+	q.running[key] = true
+	q.wg.Add(1)
+	go q.work(ctx, key, event)
+}
+
+// coalesce returns the event of the next run of a pull request: the latest, but a full run (pull_request) is
+// kept over the comments, it reads them anyway
+func coalesce(pending, event Event) Event {
+	if pending.Name == EVENT_PULL_REQUEST && event.Name != EVENT_PULL_REQUEST {
+		return pending
+	}
+	return event
+}
+
+func (q *Queue) work(ctx context.Context, key string, event Event) {
+	defer q.wg.Done()
+	for {
+		select {
+		case q.sem <- struct{}{}:
+		case <-ctx.Done():
+			q.mu.Lock()
+			delete(q.running, key)
+			delete(q.pending, key)
+			q.mu.Unlock()
+			return
+		}
+		q.run(ctx, event)
+		<-q.sem
+
+		q.mu.Lock()
+		next, ok := q.pending[key]
+		delete(q.pending, key)
+		if !ok {
+			delete(q.running, key)
+		}
+		q.mu.Unlock()
+		if !ok {
+			return
+		}
+		event = next
+	}
+}
+
+// Wait waits for the runs in progress and pending
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestQueueCoalescesRunsOfAPullRequest(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var runs []string
+	q := NewQueue(2, func(ctx context.Context, e Event) {
+		mu.Lock()
+		runs = append(runs, e.Delivery)
+		first := len(runs) == 1
+		mu.Unlock()
+		if first {
+			close(started)
+			<-release
+		}
+	})
+
+	pr := Event{Name: EVENT_PULL_REQUEST, Repo: "org/repo", Number: 7}
+	comment := Event{Name: "issue_comment", Repo: "org/repo", Number: 7}
+	first, push, override := pr, pr, comment
+	first.Delivery, push.Delivery, override.Delivery = "first", "push", "override"
+
+	q.Add(context.Background(), first)
+	<-started
+	// received during the first run: the push wins over the comment, its run reads the comments
+	q.Add(context.Background(), push)
+	q.Add(context.Background(), override)
+	close(release)
+	q.Wait()
+
+	if len(runs) != 2 || runs[0] != "first" || runs[1] != "push" {
+		t.Errorf("runs = %v, want [first push]", runs)
+	}
+}
@@ -0,0 +1,186 @@
+// Package webhook receives the GitHub webhook deliveries of the server mode: it verifies their signature and
+// turns the pull_request and issue_comment events into the runs of their pull requests
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/template"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "webhook")
+
+const (
+	// ENV_WEBHOOK_SECRET is the secret of the webhook, the deliveries are signed with
+	ENV_WEBHOOK_SECRET = "GITOPS_KUSTOMZCHK_WEBHOOK_SECRET"
+	// WEBHOOK_PATH is the path of the webhook endpoint
+	WEBHOOK_PATH = "/webhook"
+
+	HEADER_EVENT     = "X-GitHub-Event"
+	HEADER_DELIVERY  = "X-GitHub-Delivery"
+	HEADER_SIGNATURE = "X-Hub-Signature-256"
+
+	EVENT_PULL_REQUEST = "pull_request"
+	EVENT_PING         = "ping"
+
+	// MAX_PAYLOAD_BYTES is the maximum payload of a delivery, GitHub caps them at 25 MB
+	MAX_PAYLOAD_BYTES = 25 << 20
+)
+
+// PULL_REQUEST_ACTIONS are the pull_request actions running a check, the ones changing its commits or reopening it
+var PULL_REQUEST_ACTIONS = []string{"opened", "synchronize", "reopened", "ready_for_review"}
+
+// deliveryPattern matches the GUID of a delivery, it names the output dir of its run
+var deliveryPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// Event is a delivery running the check of a pull request
+type Event struct {
+	Delivery string // GUID of the delivery
+	Name     string // EVENT_PULL_REQUEST or github.EVENT_ISSUE_COMMENT
+	Repo     string // owner/repo
+	Number   int
+	// Comment is the comment of the issue_comment events, for the overrides to be re-applied to a cached evaluation
+	Comment *github.CommentEvent
+}
+
+// Key identifies the pull request of the event, its runs are serialized
+func (e *Event) Key() string {
+	return fmt.Sprintf("%s#%d", e.Repo, e.Number)
+}
+
+// Verify reports whether signature (X-Hub-Signature-256) is the HMAC-SHA256 of payload with secret
+func Verify(secret string, payload []byte, signature string) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok || secret == "" {
+		return false
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Parse returns the event of a delivery, nil if it runs nothing: other events and actions, draft pull requests,
+// comments on issues, edited or deleted comments, and the comments of bots (the tool comment included)
+func Parse(name, delivery string, payload []byte) (*Event, error) {
+	if name != EVENT_PULL_REQUEST && name != github.EVENT_ISSUE_COMMENT {
+		return nil, nil
+	}
+	if !deliveryPattern.MatchString(delivery) {
+		return nil, fmt.Errorf("invalid delivery id %q", delivery)
+	}
+
+	var p struct {
+		Action     string `json:"action"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+		PullRequest struct {
+			Number int  `json:"number"`
+			Draft  bool `json:"draft"`
+		} `json:"pull_request"`
+		Issue struct {
+			Number      int              `json:"number"`
+			PullRequest *json.RawMessage `json:"pull_request"`
+		} `json:"issue"`
+		Comment struct {
+			Body string `json:"body"`
+			User struct {
+				Login string `json:"login"`
+				Type  string `json:"type"`
+			} `json:"user"`
+		} `json:"comment"`
+	}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse the %s payload: %w", name, err)
+	}
+	event := &Event{Delivery: delivery, Name: name, Repo: p.Repository.FullName}
+
+	switch name {
+	case EVENT_PULL_REQUEST:
+		if !slices.Contains(PULL_REQUEST_ACTIONS, p.Action) || p.PullRequest.Draft {
+			return nil, nil
+		}
+		event.Number = p.PullRequest.Number
+	case github.EVENT_ISSUE_COMMENT:
+		if p.Action != github.COMMENT_ACTION_CREATED || p.Issue.PullRequest == nil ||
+			p.Comment.User.Type == "Bot" || strings.Contains(p.Comment.Body, toolCommentPrefix) {
+			return nil, nil
+		}
+		event.Number = p.Issue.Number
+		event.Comment = &github.CommentEvent{
+			Action:        p.Action,
+			Number:        p.Issue.Number,
+			Body:          p.Comment.Body,
+			User:          p.Comment.User.Login,
+			IsPullRequest: true,
+		}
+	}
+	if event.Repo == "" || event.Number <= 0 {
+		return nil, fmt.Errorf("%s payload without repository or pull request", name)
+	}
+	return event, nil
+}
+
+// toolCommentPrefix starts the marker of the tool comments of every service
+var toolCommentPrefix = template.ToolCommentSignature[:strings.Index(template.ToolCommentSignature, template.ToolCommentServiceToken)]
+
+// Handler verifies the deliveries signed with Secret and passes their events to Dispatch, which must not block:
+// the delivery is acknowledged (202) before its run, GitHub giving up on deliveries after 10 seconds
+type Handler struct {
+	Secret   string
+	Dispatch func(Event)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_PAYLOAD_BYTES))
+	if err != nil {
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !Verify(h.Secret, payload, r.Header.Get(HEADER_SIGNATURE)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	name, delivery := r.Header.Get(HEADER_EVENT), r.Header.Get(HEADER_DELIVERY)
+	lg := logger.WithField("event", name).WithField("delivery", delivery)
+	if name == EVENT_PING {
+		_, _ = w.Write([]byte("pong"))
+		return
+	}
+	event, err := Parse(name, delivery, payload)
+	if err != nil {
+		lg.WithField("error", err).Warn("Rejected webhook delivery")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if event == nil {
+		lg.Debug("Ignored webhook delivery")
+		_, _ = w.Write([]byte("ignored"))
+		return
+	}
+	lg.WithField("pr", event.Key()).Info("Accepted webhook delivery")
+	h.Dispatch(*event)
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("accepted"))
+}
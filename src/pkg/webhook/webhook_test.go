@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		event      string
+		payload    string
+		wantNumber int // 0 for no run
+		wantErr    bool
+	}{
+		{"opened", "pull_request", `{"action": "opened", "repository": {"full_name": "org/repo"}, "pull_request": {"number": 7}}`, 7, false},
+		{"synchronize", "pull_request", `{"action": "synchronize", "repository": {"full_name": "org/repo"}, "pull_request": {"number": 7}}`, 7, false},
+		{"draft", "pull_request", `{"action": "opened", "repository": {"full_name": "org/repo"}, "pull_request": {"number": 7, "draft": true}}`, 0, false},
+		{"closed", "pull_request", `{"action": "closed", "repository": {"full_name": "org/repo"}, "pull_request": {"number": 7}}`, 0, false},
+		{"override comment", "issue_comment", `{"action": "created", "repository": {"full_name": "org/repo"},
+			"issue": {"number": 7, "pull_request": {}}, "comment": {"body": "/override-policy x", "user": {"login": "alice", "type": "User"}}}`, 7, false},
+		{"issue comment", "issue_comment", `{"action": "created", "repository": {"full_name": "org/repo"},
+			"issue": {"number": 7}, "comment": {"body": "hi", "user": {"login": "alice", "type": "User"}}}`, 0, false},
+		{"edited comment", "issue_comment", `{"action": "edited", "repository": {"full_name": "org/repo"},
+			"issue": {"number": 7, "pull_request": {}}, "comment": {"body": "hi", "user": {"login": "alice", "type": "User"}}}`, 0, false},
+		{"bot comment", "issue_comment", `{"action": "created", "repository": {"full_name": "org/repo"},
+			"issue": {"number": 7, "pull_request": {}}, "comment": {"body": "hi", "user": {"login": "ci[bot]", "type": "Bot"}}}`, 0, false},
+		{"tool comment", "issue_comment", `{"action": "created", "repository": {"full_name": "org/repo"},
+			"issue": {"number": 7, "pull_request": {}}, "comment": {"body": "<!-- gitops-kustomzchk: app - auto-generated comment, please do not remove -->", "user": {"login": "alice", "type": "User"}}}`, 0, false},
+		{"other event", "push", `{}`, 0, false},
+		{"no repository", "pull_request", `{"action": "opened", "pull_request": {"number": 7}}`, 0, true},
+		{"malformed", "pull_request", `{`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := Parse(tt.event, "72d3162e-cc78-11e3-81ab-4c9367dc0958", []byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			number := 0
+			if event != nil {
+				number = event.Number
+			}
+			if number != tt.wantNumber {
+				t.Errorf("Parse() = %+v, want a run of #%d", event, tt.wantNumber)
+			}
+			if event != nil && tt.event == "issue_comment" && (event.Comment == nil || event.Comment.User != "alice") {
+				t.Errorf("comment = %+v, want the comment of the event", event.Comment)
+			}
+		})
+	}
+
+	if _, err := Parse("pull_request", "../../etc", []byte(`{}`)); err == nil {
+		t.Error("Parse() accepted a delivery id with a path")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var dispatched []Event
+	h := &Handler{Secret: "s3cret", Dispatch: func(e Event) { dispatched = append(dispatched, e) }}
+	payload := `{"action": "opened", "repository": {"full_name": "org/repo"}, "pull_request": {"number": 7}}`
+
+	tests := []struct {
+		name       string
+		event      string
+		signature  string
+		wantStatus int
+	}{
+		{"valid", "pull_request", sign("s3cret", payload), http.StatusAccepted},
+		{"wrong secret", "pull_request", sign("other", payload), http.StatusUnauthorized},
+		{"unsigned", "pull_request", "", http.StatusUnauthorized},
+		{"ping", "ping", sign("s3cret", payload), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, WEBHOOK_PATH, strings.NewReader(payload))
+			req.Header.Set(HEADER_EVENT, tt.event)
+			req.Header.Set(HEADER_DELIVERY, "72d3162e-cc78-11e3-81ab-4c9367dc0958")
+			req.Header.Set(HEADER_SIGNATURE, tt.signature)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+		})
+	}
+	if len(dispatched) != 1 || dispatched[0].Key() != "org/repo#7" {
+		t.Errorf("dispatched %+v, want the valid delivery only", dispatched)
+	}
+}
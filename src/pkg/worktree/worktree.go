@@ -0,0 +1,60 @@
+// Package worktree checks out other refs of a local git repository in temporary worktrees, e.g. the policies
+// of the base branch or the manifests of a release tag
+package worktree
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/sandbox"
+	"github.com/gh-nvat/gitops-kustomzchk/src/pkg/trace"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "worktree")
+
+// CheckoutAt checks out the repository of a directory at a ref, in a temporary worktree. Returns the same
+// directory in the worktree and the function removing the worktree
+func CheckoutAt(ctx context.Context, path, ref string) (string, func(), error) {
+	top, err := runGit(ctx, path, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", nil, fmt.Errorf("%s is not in a git repository: %w", path, err)
+	}
+	prefix, err := runGit(ctx, path, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.MkdirTemp("", "worktree-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	worktree := filepath.Join(dir, "worktree")
+	if _, err := runGit(ctx, top, "-c", "core.autocrlf=false", "-c", "core.longpaths=true", "worktree", "add", "--detach", worktree, ref); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", nil, fmt.Errorf("failed to check out %s: %w", ref, err)
+	}
+	cleanup := func() {
+		if _, err := runGit(context.Background(), top, "worktree", "remove", "--force", worktree); err != nil {
+			logger.WithField("ref", ref).WithField("error", err).Warn("Failed to remove the worktree")
+		}
+		_ = os.RemoveAll(dir)
+	}
+	return filepath.Join(worktree, prefix), cleanup, nil
+}
+
+// CommitOf returns the commit SHA of a ref of the repository of a directory, e.g. of a tag
+func CommitOf(ctx context.Context, path, ref string) (string, error) {
+	return runGit(ctx, path, "rev-parse", "--verify", ref+"^{commit}")
+}
+
+// runGit runs a git command in dir, returns its trimmed stdout
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	stdout, err := sandbox.Output(ctx, sandbox.DefaultExecutor, sandbox.Command{Name: "git", Args: args, Dir: dir})
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", strings.Join(trace.RedactArgs(args), " "), err)
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}